@@ -0,0 +1,95 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AliasArgs is the subcommand and the arguments of an alias in [alias]
+// section of config.toml. It is written as an array of strings
+// (e.g. ["get", "-u"]) or a string of the words (e.g. "get -u").
+type AliasArgs []string
+
+// UnmarshalTOML implements toml.Unmarshaler.
+func (args *AliasArgs) UnmarshalTOML(data interface{}) error {
+	switch v := data.(type) {
+	case string:
+		words, err := splitWords(v)
+		if err != nil {
+			return err
+		}
+		*args = words
+	case []interface{}:
+		words := make([]string, 0, len(v))
+		for i := range v {
+			s, ok := v[i].(string)
+			if !ok {
+				return fmt.Errorf("expected string but got %v", v[i])
+			}
+			words = append(words, s)
+		}
+		*args = words
+	default:
+		return fmt.Errorf("expected string or array of strings but got %v", data)
+	}
+	return nil
+}
+
+// String returns the words joined by spaces. The words which have spaces or
+// quotes are quoted.
+func (args AliasArgs) String() string {
+	words := make([]string, 0, len(args))
+	for _, w := range args {
+		if w == "" || strings.ContainsAny(w, " \t'\"\\") {
+			w = "'" + strings.Replace(w, "'", `'\''`, -1) + "'"
+		}
+		words = append(words, w)
+	}
+	return strings.Join(words, " ")
+}
+
+// splitWords splits s into words by whitespaces like shell.
+// Single quotes, double quotes, and backslashes escape whitespaces.
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, c := range s {
+		switch {
+		case escaped:
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or backslash: " + s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
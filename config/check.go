@@ -0,0 +1,215 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// Problem is a problem of config.toml found by Check().
+type Problem struct {
+	// File is the name of the file which has the problem if it is not
+	// config.toml (e.g. "config.local.toml")
+	File string
+	// Key is the key of config.toml (e.g. "build.jobs"), or "" if the problem
+	// is about the whole file
+	Key string
+	// Msg describes the problem and how to fix it
+	Msg string
+	// Warning is true if volt works in spite of the problem (e.g. an unknown
+	// key is ignored)
+	Warning bool
+}
+
+func (p *Problem) String() string {
+	s := p.Msg
+	if p.Key != "" {
+		s = p.Key + ": " + s
+	}
+	if p.File != "" {
+		s = p.File + ": " + s
+	}
+	return s
+}
+
+// tableKeys are the keys of config.toml whose values are tables.
+var tableKeys = map[string]bool{
+	"alias":         true,
+	"git.args":      true,
+	"git.protocols": true,
+}
+
+// Check returns the problems of config.toml, config.local.toml, and the
+// environment variables: syntax errors, the values of wrong types or out of
+// range, and unknown keys.
+// isFlag reports whether a key in [{section}] section is a flag of
+// 'volt {section}' (see CommandFlags()).
+func Check(isFlag func(section, name string) bool) ([]Problem, error) {
+	var problems []Problem
+	for _, file := range configFiles() {
+		var name string
+		if file != pathutil.ConfigTOML() {
+			name = filepath.Base(file)
+		}
+		raw, _, err := readRaw(file)
+		if err != nil {
+			problems = append(problems, Problem{File: name, Msg: err.Error()})
+			continue
+		}
+		for _, p := range checkTables(raw, isFlag) {
+			p.File = name
+			problems = append(problems, p)
+		}
+	}
+	for _, p := range problems {
+		if !p.Warning {
+			return problems, nil
+		}
+	}
+	// Check the environment variables and the relations between the keys
+	if _, err := Read(); err != nil {
+		problems = append(problems, Problem{Msg: err.Error()})
+	}
+	return problems, nil
+}
+
+func checkTables(raw map[string]interface{}, isFlag func(section, name string) bool) []Problem {
+	var problems []Problem
+	for _, section := range sortedKeys(raw) {
+		if section == "version" {
+			// Already validated by readRaw()
+			continue
+		}
+		table, ok := raw[section].(map[string]interface{})
+		if !ok {
+			problems = append(problems, unknownKey(section))
+			continue
+		}
+		if section == "alias" {
+			for _, name := range sortedKeys(table) {
+				var args AliasArgs
+				if err := args.UnmarshalTOML(table[name]); err != nil {
+					problems = append(problems, Problem{Key: "alias." + name, Msg: err.Error()})
+				}
+			}
+			continue
+		}
+		for _, name := range sortedKeys(table) {
+			key := section + "." + name
+			if def, exists := keyDefs[key]; exists {
+				if msg := checkValue(def, table[name]); msg != "" {
+					problems = append(problems, Problem{Key: key, Msg: msg})
+				}
+			} else if !tableKeys[key] && !isFlag(section, name) {
+				problems = append(problems, unknownKey(key))
+			}
+		}
+	}
+	return problems
+}
+
+// checkValue returns the problem of v which is the value of def, or "" if v
+// is valid.
+func checkValue(def keyDef, v interface{}) string {
+	s, ok := v.(string)
+	if !ok {
+		s = fmt.Sprint(v)
+	}
+	parsed, err := def.parse(s)
+	if err != nil {
+		return fmt.Sprintf("invalid value %v: %s", v, err.Error())
+	}
+	if expected, actual := typeName(parsed), typeName(v); expected != actual {
+		return fmt.Sprintf("must be %s, but is %s (%v)", expected, actual, v)
+	}
+	return ""
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case int, int64:
+		return "an integer"
+	case float64:
+		return "a float"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "a table"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// unknownKey returns the problem of unknown key with the most similar key
+// as a suggestion.
+func unknownKey(key string) Problem {
+	msg := "unknown key (ignored)"
+	best, bestDist := "", len(key)/2+1
+	for known := range keyDefs {
+		if d := editDistance(key, known); d < bestDist || d == bestDist && known < best {
+			best, bestDist = known, d
+		}
+	}
+	if best != "" {
+		msg += fmt.Sprintf(": did you mean '%s'?", best)
+	}
+	return Problem{Key: key, Msg: msg, Warning: true}
+}
+
+// editDistance returns Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Effective returns the TOML document of cfg, which is the configuration
+// used by volt (default values, config.toml, config.local.toml, and
+// environment variables).
+func Effective(cfg *Config) (string, error) {
+	var buf strings.Builder
+	if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
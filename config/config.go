@@ -1,7 +1,15 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/vim-volt/volt/pathutil"
@@ -9,22 +17,163 @@ import (
 
 // Config is marshallable content of config.toml
 type Config struct {
-	Alias map[string][]string `toml:"alias"`
-	Build configBuild         `toml:"build"`
-	Get   configGet           `toml:"get"`
+	Version  int64                `toml:"version"`
+	Alias    map[string]AliasArgs `toml:"alias"`
+	Build    configBuild          `toml:"build"`
+	Debug    configDebug          `toml:"debug"`
+	Get      configGet            `toml:"get"`
+	Git      configGit            `toml:"git"`
+	Lock     configLock           `toml:"lock"`
+	Log      configLog            `toml:"log"`
+	Metadata configMetadata       `toml:"metadata"`
+	Network  configNetwork        `toml:"network"`
+	UI       configUI             `toml:"ui"`
 }
 
 // configBuild is a config for 'volt build'.
 type configBuild struct {
-	Strategy string `toml:"strategy"`
+	Strategy       string `toml:"strategy"`
+	CompileLua     *bool  `toml:"compile_lua"`
+	Jobs           int    `toml:"jobs"`
+	MergeFtdetect  *bool  `toml:"merge_ftdetect"`
+	Slim           *bool  `toml:"slim"`
+	SlimDoc        *bool  `toml:"slim_doc"`
+	Deterministic  *bool  `toml:"deterministic"`
+	CachedProfiles *int   `toml:"cached_profiles"`
+}
+
+// configDebug is a config for diagnosing volt.
+type configDebug struct {
+	Timings *bool `toml:"timings"`
 }
 
 // configGet is a config for 'volt get'.
 type configGet struct {
-	CreateSkeletonPlugconf *bool `toml:"create_skeleton_plugconf"`
-	FallbackGitCmd         *bool `toml:"fallback_git_cmd"`
+	CreateSkeletonPlugconf *bool   `toml:"create_skeleton_plugconf"`
+	FallbackGitCmd         *bool   `toml:"fallback_git_cmd"`
+	Jobs                   int     `toml:"jobs"`
+	OnForcePush            string  `toml:"on_force_push"`
+	PlugconfTemplateURL    *string `toml:"plugconf_template_url"`
+}
+
+// configGit is a config for git operations.
+type configGit struct {
+	Backend          string              `toml:"backend"`
+	CloneDepth       *int                `toml:"clone_depth"`
+	CloneFilter      string              `toml:"clone_filter"`
+	VerifySignatures *bool               `toml:"verify_signatures"`
+	Protocol         string              `toml:"protocol"`
+	Protocols        map[string]string   `toml:"protocols"`
+	Command          string              `toml:"command"`
+	Args             map[string][]string `toml:"args"`
+}
+
+// configLock is a config for the lock of $VOLTPATH.
+type configLock struct {
+	Wait       string `toml:"wait"`
+	OnConflict string `toml:"on_conflict"`
+}
+
+// configLog is a config for the history of transactions ('volt log').
+type configLog struct {
+	MaxCount   *int `toml:"max_count"`
+	MaxAgeDays *int `toml:"max_age_days"`
+	MaxSizeMB  *int `toml:"max_size_mb"`
+}
+
+// configMetadata is a config for the metadata of plugins (descriptions,
+// categories, and popularity).
+type configMetadata struct {
+	Source string `toml:"source"`
+}
+
+// configNetwork is a config for network operations.
+type configNetwork struct {
+	Proxy           string `toml:"proxy"`
+	NoProxy         string `toml:"no_proxy"`
+	RetryAttempts   int    `toml:"retry_attempts"`
+	RetryBackoff    string `toml:"retry_backoff"`
+	RetryMaxBackoff string `toml:"retry_max_backoff"`
+	JobsPerHost     int    `toml:"jobs_per_host"`
+	ConnectTimeout  string `toml:"connect_timeout"`
+	RequestTimeout  string `toml:"request_timeout"`
+	GitHubToken     string `toml:"github_token"`
+}
+
+// configUI is a config for the output of volt.
+type configUI struct {
+	Color   string `toml:"color"`
+	Unicode string `toml:"unicode"`
+	Emoji   *bool  `toml:"emoji"`
+}
+
+// DefaultCloneDepth is the default depth of history which is fetched when
+// cloning a repository. 0 means all history.
+const DefaultCloneDepth = 1
+
+// DefaultPlugconfTemplateURL is the default location of plugconf templates
+// which are fetched when installing plugins.
+const DefaultPlugconfTemplateURL = "https://raw.githubusercontent.com/vim-volt/plugconf-templates/master/templates"
+
+// DefaultRetryAttempts is the default maximum number of attempts of a
+// network operation.
+const DefaultRetryAttempts = 3
+
+// DefaultRetryBackoff is the default wait before the first retry of a
+// network operation.
+const DefaultRetryBackoff = "1s"
+
+// DefaultRetryMaxBackoff is the default upper limit of the wait before a
+// retry of a network operation.
+const DefaultRetryMaxBackoff = "30s"
+
+// DefaultJobsPerHost is the default maximum number of network operations
+// which access the same host at the same time.
+const DefaultJobsPerHost = 4
+
+// DefaultConnectTimeout is the default timeout of connecting to a server and
+// waiting for the response headers.
+const DefaultConnectTimeout = "30s"
+
+// DefaultRequestTimeout is the default timeout of a whole HTTP request
+// including reading the response body. "0s" means no limit.
+const DefaultRequestTimeout = "0s"
+
+// DefaultLockWait is the default time to wait for other volt process to
+// finish. "0s" means exiting immediately.
+const DefaultLockWait = "0s"
+
+// DefaultLogMaxCount is the default maximum number of transactions kept in
+// the history. 0 means unlimited.
+const DefaultLogMaxCount = 50
+
+// DefaultLogMaxAgeDays is the default maximum age (days) of transactions
+// kept in the history. 0 means unlimited.
+const DefaultLogMaxAgeDays = 90
+
+// DefaultLogMaxSizeMB is the default maximum total size (MB) of the history
+// of transactions. 0 means unlimited.
+const DefaultLogMaxSizeMB = 1024
+
+// DefaultBuildJobs is the default number of repositories which 'volt build'
+// processes at the same time.
+const DefaultBuildJobs = 8
+
+// DefaultCachedProfiles is the default number of profiles whose built
+// runtime is kept to switch profiles without building it again.
+// 0 means the runtime is built in "(vim dir)/pack/volt" directly.
+// It is 0 on Windows because creating symlinks needs a privilege there.
+func DefaultCachedProfiles() int {
+	if runtime.GOOS == "windows" {
+		return 0
+	}
+	return 3
 }
 
+// DefaultGetJobs is the default number of repositories which 'volt get'
+// processes at the same time.
+const DefaultGetJobs = 8
+
 const (
 	// SymlinkBuilder creates symlinks when 'volt build'.
 	SymlinkBuilder = "symlink"
@@ -32,32 +181,158 @@ const (
 	CopyBuilder = "copy"
 )
 
+const (
+	// ForcePushAsk asks what to do when upstream history was rewritten.
+	ForcePushAsk = "ask"
+	// ForcePushKeep keeps local commit when upstream history was rewritten.
+	ForcePushKeep = "keep"
+	// ForcePushReset resets to new upstream commit when upstream history was
+	// rewritten.
+	ForcePushReset = "reset"
+)
+
+const (
+	// ConflictAsk asks what to do when lock.json was changed by other
+	// programs while volt was changing it.
+	ConflictAsk = "ask"
+	// ConflictRebase applies the changes by volt to the changed lock.json.
+	ConflictRebase = "rebase"
+	// ConflictAbort does not write lock.json, and rolls back the changes.
+	ConflictAbort = "abort"
+)
+
+const (
+	// MetadataVimAwesome fetches the metadata of plugins from VimAwesome API.
+	MetadataVimAwesome = "vimawesome"
+	// MetadataNone does not fetch the metadata of plugins, and uses only
+	// the cache.
+	MetadataNone = "none"
+)
+
+const (
+	// GoGitBackend uses go-git for git operations.
+	GoGitBackend = "go-git"
+	// CLIGitBackend executes git command for git operations.
+	CLIGitBackend = "cli"
+)
+
+const (
+	// HTTPSProtocol clones repositories by "https://{host}/{path}".
+	HTTPSProtocol = "https"
+	// SSHProtocol clones repositories by "ssh://git@{host}/{path}".
+	SSHProtocol = "ssh"
+)
+
+const (
+	// ColorAuto colors the output only if it is a terminal.
+	ColorAuto = "auto"
+	// ColorAlways always colors the output.
+	ColorAlways = "always"
+	// ColorNever never colors the output.
+	ColorNever = "never"
+)
+
+const (
+	// UnicodeAuto uses Unicode symbols only if the output is a terminal which
+	// can show them.
+	UnicodeAuto = "auto"
+	// UnicodeAlways always uses Unicode symbols.
+	UnicodeAlways = "always"
+	// UnicodeNever uses only ASCII symbols.
+	UnicodeNever = "never"
+)
+
 func initialConfigTOML() *Config {
 	trueValue := true
 	falseValue := false
+	cloneDepth := DefaultCloneDepth
+	logMaxCount := DefaultLogMaxCount
+	logMaxAgeDays := DefaultLogMaxAgeDays
+	logMaxSizeMB := DefaultLogMaxSizeMB
+	plugconfTemplateURL := DefaultPlugconfTemplateURL
+	cachedProfiles := DefaultCachedProfiles()
 	return &Config{
+		Version: configVersion,
 		Build: configBuild{
-			Strategy: SymlinkBuilder,
+			Strategy:       SymlinkBuilder,
+			CompileLua:     &falseValue,
+			Jobs:           DefaultBuildJobs,
+			MergeFtdetect:  &trueValue,
+			Slim:           &falseValue,
+			SlimDoc:        &falseValue,
+			Deterministic:  &falseValue,
+			CachedProfiles: &cachedProfiles,
+		},
+		Debug: configDebug{
+			Timings: &falseValue,
 		},
 		Get: configGet{
 			CreateSkeletonPlugconf: &trueValue,
 			FallbackGitCmd:         &falseValue,
+			Jobs:                   DefaultGetJobs,
+			OnForcePush:            ForcePushAsk,
+			PlugconfTemplateURL:    &plugconfTemplateURL,
+		},
+		Git: configGit{
+			Backend:          GoGitBackend,
+			CloneDepth:       &cloneDepth,
+			Protocol:         HTTPSProtocol,
+			VerifySignatures: &falseValue,
+		},
+		Lock: configLock{
+			Wait:       DefaultLockWait,
+			OnConflict: ConflictAsk,
+		},
+		Log: configLog{
+			MaxCount:   &logMaxCount,
+			MaxAgeDays: &logMaxAgeDays,
+			MaxSizeMB:  &logMaxSizeMB,
+		},
+		Metadata: configMetadata{
+			Source: MetadataVimAwesome,
+		},
+		Network: configNetwork{
+			RetryAttempts:   DefaultRetryAttempts,
+			RetryBackoff:    DefaultRetryBackoff,
+			RetryMaxBackoff: DefaultRetryMaxBackoff,
+			JobsPerHost:     DefaultJobsPerHost,
+			ConnectTimeout:  DefaultConnectTimeout,
+			RequestTimeout:  DefaultRequestTimeout,
+		},
+		UI: configUI{
+			Color:   ColorAuto,
+			Unicode: UnicodeAuto,
+			Emoji:   &falseValue,
 		},
 	}
 }
 
-// Read reads from config.toml and returns Config
+// Read reads from config.toml and returns Config.
+// The values are overridden by config.local.toml, and by environment
+// variables (see EnvName()), so the precedence is: environment variables >
+// config.local.toml > config.toml > default values.
 func Read() (*Config, error) {
-	// Return initial lock.json struct if lockfile does not exist
-	configFile := pathutil.ConfigTOML()
 	initCfg := initialConfigTOML()
-	if !pathutil.Exists(configFile) {
+	env, err := readEnv()
+	if err != nil {
+		return nil, err
+	}
+	// Return initial config struct if config files and environment variables
+	// do not exist
+	if !pathutil.Exists(pathutil.ConfigTOML()) && !pathutil.Exists(pathutil.ConfigLocalTOML()) && env == "" {
 		return initCfg, nil
 	}
 
 	var cfg Config
-	if _, err := toml.DecodeFile(configFile, &cfg); err != nil {
-		return nil, err
+	for _, file := range configFiles() {
+		if _, err := decodeFile(file, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	if env != "" {
+		if _, err := toml.Decode(env, &cfg); err != nil {
+			return nil, err
+		}
 	}
 	merge(&cfg, initCfg)
 	if err := validate(&cfg); err != nil {
@@ -66,21 +341,308 @@ func Read() (*Config, error) {
 	return &cfg, nil
 }
 
+// configFiles returns config.toml and config.local.toml which overrides
+// config.toml. config.local.toml is for the settings of the machine (e.g.
+// proxy, tokens), so config.toml can be shared by dotfiles repository.
+func configFiles() []string {
+	return []string{pathutil.ConfigTOML(), pathutil.ConfigLocalTOML()}
+}
+
+// CommandFlags returns the default values of the flags of 'volt {name}',
+// which are the keys in [{name}] section of config.toml (and
+// config.local.toml) except the keys of Config (e.g. "jobs" of [get] is
+// "get.jobs", not a flag).
+func CommandFlags(name string) (map[string]interface{}, error) {
+	flags := make(map[string]interface{})
+	for _, file := range configFiles() {
+		var cfg Config
+		md, err := decodeFile(file, &cfg)
+		if err != nil {
+			return nil, err
+		}
+		raw, _, err := readRaw(file)
+		if err != nil {
+			return nil, err
+		}
+		section, _ := raw[name].(map[string]interface{})
+		for _, key := range md.Undecoded() {
+			if len(key) == 2 && key[0] == name {
+				flags[key[1]] = section[key[1]]
+			}
+		}
+	}
+	return flags, nil
+}
+
+// decodeFile decodes file migrated to the latest version into cfg.
+// Nothing is decoded if file does not exist.
+func decodeFile(file string, cfg *Config) (toml.MetaData, error) {
+	md, err := decodeMigratedFile(file, cfg)
+	if err != nil && file == pathutil.ConfigLocalTOML() {
+		err = errors.New(filepath.Base(file) + ": " + err.Error())
+	}
+	return md, err
+}
+
+func decodeMigratedFile(file string, cfg *Config) (toml.MetaData, error) {
+	raw, changed, err := readRaw(file)
+	if err != nil || raw == nil {
+		return toml.MetaData{}, err
+	}
+	if !changed {
+		// Decode the file itself to show the line numbers in errors
+		return toml.DecodeFile(file, cfg)
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(raw); err != nil {
+		return toml.MetaData{}, err
+	}
+	return toml.Decode(buf.String(), cfg)
+}
+
 func merge(cfg, initCfg *Config) {
+	if cfg.Version == 0 {
+		cfg.Version = initCfg.Version
+	}
 	if cfg.Build.Strategy == "" {
 		cfg.Build.Strategy = initCfg.Build.Strategy
 	}
+	if cfg.Build.CompileLua == nil {
+		cfg.Build.CompileLua = initCfg.Build.CompileLua
+	}
+	if cfg.Build.Jobs == 0 {
+		cfg.Build.Jobs = initCfg.Build.Jobs
+	}
+	if cfg.Build.MergeFtdetect == nil {
+		cfg.Build.MergeFtdetect = initCfg.Build.MergeFtdetect
+	}
+	if cfg.Build.Slim == nil {
+		cfg.Build.Slim = initCfg.Build.Slim
+	}
+	if cfg.Build.SlimDoc == nil {
+		cfg.Build.SlimDoc = initCfg.Build.SlimDoc
+	}
+	if cfg.Build.Deterministic == nil {
+		cfg.Build.Deterministic = initCfg.Build.Deterministic
+	}
+	if cfg.Build.CachedProfiles == nil {
+		cfg.Build.CachedProfiles = initCfg.Build.CachedProfiles
+	}
+	if cfg.Debug.Timings == nil {
+		cfg.Debug.Timings = initCfg.Debug.Timings
+	}
 	if cfg.Get.CreateSkeletonPlugconf == nil {
 		cfg.Get.CreateSkeletonPlugconf = initCfg.Get.CreateSkeletonPlugconf
 	}
 	if cfg.Get.FallbackGitCmd == nil {
 		cfg.Get.FallbackGitCmd = initCfg.Get.FallbackGitCmd
 	}
+	if cfg.Get.Jobs == 0 {
+		cfg.Get.Jobs = initCfg.Get.Jobs
+	}
+	if cfg.Get.OnForcePush == "" {
+		cfg.Get.OnForcePush = initCfg.Get.OnForcePush
+	}
+	if cfg.Get.PlugconfTemplateURL == nil {
+		cfg.Get.PlugconfTemplateURL = initCfg.Get.PlugconfTemplateURL
+	}
+	if cfg.Git.Backend == "" {
+		cfg.Git.Backend = initCfg.Git.Backend
+	}
+	if cfg.Git.CloneDepth == nil {
+		cfg.Git.CloneDepth = initCfg.Git.CloneDepth
+	}
+	if cfg.Git.Protocol == "" {
+		cfg.Git.Protocol = initCfg.Git.Protocol
+	}
+	if cfg.Git.VerifySignatures == nil {
+		cfg.Git.VerifySignatures = initCfg.Git.VerifySignatures
+	}
+	if cfg.Lock.Wait == "" {
+		cfg.Lock.Wait = initCfg.Lock.Wait
+	}
+	if cfg.Lock.OnConflict == "" {
+		cfg.Lock.OnConflict = initCfg.Lock.OnConflict
+	}
+	if cfg.Log.MaxCount == nil {
+		cfg.Log.MaxCount = initCfg.Log.MaxCount
+	}
+	if cfg.Log.MaxAgeDays == nil {
+		cfg.Log.MaxAgeDays = initCfg.Log.MaxAgeDays
+	}
+	if cfg.Log.MaxSizeMB == nil {
+		cfg.Log.MaxSizeMB = initCfg.Log.MaxSizeMB
+	}
+	if cfg.Metadata.Source == "" {
+		cfg.Metadata.Source = initCfg.Metadata.Source
+	}
+	if cfg.Network.RetryAttempts == 0 {
+		cfg.Network.RetryAttempts = initCfg.Network.RetryAttempts
+	}
+	if cfg.Network.RetryBackoff == "" {
+		cfg.Network.RetryBackoff = initCfg.Network.RetryBackoff
+	}
+	if cfg.Network.RetryMaxBackoff == "" {
+		cfg.Network.RetryMaxBackoff = initCfg.Network.RetryMaxBackoff
+	}
+	if cfg.Network.JobsPerHost == 0 {
+		cfg.Network.JobsPerHost = initCfg.Network.JobsPerHost
+	}
+	if cfg.Network.ConnectTimeout == "" {
+		cfg.Network.ConnectTimeout = initCfg.Network.ConnectTimeout
+	}
+	if cfg.Network.RequestTimeout == "" {
+		cfg.Network.RequestTimeout = initCfg.Network.RequestTimeout
+	}
+	if cfg.UI.Color == "" {
+		cfg.UI.Color = initCfg.UI.Color
+	}
+	if cfg.UI.Unicode == "" {
+		cfg.UI.Unicode = initCfg.UI.Unicode
+	}
+	if cfg.UI.Emoji == nil {
+		cfg.UI.Emoji = initCfg.UI.Emoji
+	}
 }
 
 func validate(cfg *Config) error {
+	for name, args := range cfg.Alias {
+		if len(args) == 0 {
+			return fmt.Errorf("alias.%s is empty: must be a subcommand and its arguments", name)
+		}
+	}
 	if cfg.Build.Strategy != "symlink" && cfg.Build.Strategy != "copy" {
 		return fmt.Errorf("build.strategy is %q: valid values are %q or %q", cfg.Build.Strategy, "symlink", "copy")
 	}
+	if cfg.Build.Jobs < 1 {
+		return fmt.Errorf("build.jobs is %d: must be 1 or greater", cfg.Build.Jobs)
+	}
+	if *cfg.Build.CachedProfiles < 0 {
+		return fmt.Errorf("build.cached_profiles is %d: must be 0 or greater", *cfg.Build.CachedProfiles)
+	}
+	if cfg.Get.Jobs < 1 {
+		return fmt.Errorf("get.jobs is %d: must be 1 or greater", cfg.Get.Jobs)
+	}
+	if cfg.Get.OnForcePush != ForcePushAsk && cfg.Get.OnForcePush != ForcePushKeep && cfg.Get.OnForcePush != ForcePushReset {
+		return fmt.Errorf("get.on_force_push is %q: valid values are %q, %q, or %q", cfg.Get.OnForcePush, ForcePushAsk, ForcePushKeep, ForcePushReset)
+	}
+	if err := validateTemplateURL(*cfg.Get.PlugconfTemplateURL); err != nil {
+		return fmt.Errorf("get.plugconf_template_url is %q: %s", *cfg.Get.PlugconfTemplateURL, err.Error())
+	}
+	if cfg.Git.Backend != GoGitBackend && cfg.Git.Backend != CLIGitBackend {
+		return fmt.Errorf("git.backend is %q: valid values are %q or %q", cfg.Git.Backend, GoGitBackend, CLIGitBackend)
+	}
+	if *cfg.Git.CloneDepth < 0 {
+		return fmt.Errorf("git.clone_depth is %d: must be 0 or greater", *cfg.Git.CloneDepth)
+	}
+	if err := ValidateCloneFilter(cfg.Git.CloneFilter); err != nil {
+		return fmt.Errorf("git.clone_filter is %q: %s", cfg.Git.CloneFilter, err.Error())
+	}
+	if cfg.Git.Protocol != HTTPSProtocol && cfg.Git.Protocol != SSHProtocol {
+		return fmt.Errorf("git.protocol is %q: valid values are %q or %q", cfg.Git.Protocol, HTTPSProtocol, SSHProtocol)
+	}
+	for host, protocol := range cfg.Git.Protocols {
+		if protocol != HTTPSProtocol && protocol != SSHProtocol {
+			return fmt.Errorf("git.protocols.%s is %q: valid values are %q or %q", host, protocol, HTTPSProtocol, SSHProtocol)
+		}
+	}
+	for op, args := range cfg.Git.Args {
+		for i := range args {
+			if args[i] == "" {
+				return fmt.Errorf("git.args.%s has an empty argument", op)
+			}
+		}
+	}
+	if d, err := time.ParseDuration(cfg.Lock.Wait); err != nil || d < 0 {
+		return fmt.Errorf("lock.wait is %q: must be a duration like \"30s\" or \"1m\"", cfg.Lock.Wait)
+	}
+	if cfg.Lock.OnConflict != ConflictAsk && cfg.Lock.OnConflict != ConflictRebase && cfg.Lock.OnConflict != ConflictAbort {
+		return fmt.Errorf("lock.on_conflict is %q: valid values are %q, %q, or %q", cfg.Lock.OnConflict, ConflictAsk, ConflictRebase, ConflictAbort)
+	}
+	if *cfg.Log.MaxCount < 0 {
+		return fmt.Errorf("log.max_count is %d: must be 0 or greater", *cfg.Log.MaxCount)
+	}
+	if *cfg.Log.MaxAgeDays < 0 {
+		return fmt.Errorf("log.max_age_days is %d: must be 0 or greater", *cfg.Log.MaxAgeDays)
+	}
+	if *cfg.Log.MaxSizeMB < 0 {
+		return fmt.Errorf("log.max_size_mb is %d: must be 0 or greater", *cfg.Log.MaxSizeMB)
+	}
+	if err := validateTemplateURL(cfg.Metadata.Source); err != nil {
+		return fmt.Errorf("metadata.source is %q: %s", cfg.Metadata.Source, err.Error())
+	}
+	if cfg.Network.Proxy != "" {
+		if err := validateProxy(cfg.Network.Proxy); err != nil {
+			return fmt.Errorf("network.proxy is %q: %s", cfg.Network.Proxy, err.Error())
+		}
+	}
+	if cfg.Network.RetryAttempts < 1 {
+		return fmt.Errorf("network.retry_attempts is %d: must be 1 or greater", cfg.Network.RetryAttempts)
+	}
+	if d, err := time.ParseDuration(cfg.Network.RetryBackoff); err != nil || d < 0 {
+		return fmt.Errorf("network.retry_backoff is %q: must be a duration like \"1s\" or \"500ms\"", cfg.Network.RetryBackoff)
+	}
+	if d, err := time.ParseDuration(cfg.Network.RetryMaxBackoff); err != nil || d < 0 {
+		return fmt.Errorf("network.retry_max_backoff is %q: must be a duration like \"30s\" or \"1m\"", cfg.Network.RetryMaxBackoff)
+	}
+	if cfg.Network.JobsPerHost < 1 {
+		return fmt.Errorf("network.jobs_per_host is %d: must be 1 or greater", cfg.Network.JobsPerHost)
+	}
+	if d, err := time.ParseDuration(cfg.Network.ConnectTimeout); err != nil || d <= 0 {
+		return fmt.Errorf("network.connect_timeout is %q: must be a duration like \"30s\" or \"1m\"", cfg.Network.ConnectTimeout)
+	}
+	if d, err := time.ParseDuration(cfg.Network.RequestTimeout); err != nil || d < 0 {
+		return fmt.Errorf("network.request_timeout is %q: must be a duration like \"5m\" or \"0s\"", cfg.Network.RequestTimeout)
+	}
+	if cfg.UI.Color != ColorAuto && cfg.UI.Color != ColorAlways && cfg.UI.Color != ColorNever {
+		return fmt.Errorf("ui.color is %q: valid values are %q, %q, or %q", cfg.UI.Color, ColorAuto, ColorAlways, ColorNever)
+	}
+	if cfg.UI.Unicode != UnicodeAuto && cfg.UI.Unicode != UnicodeAlways && cfg.UI.Unicode != UnicodeNever {
+		return fmt.Errorf("ui.unicode is %q: valid values are %q, %q, or %q", cfg.UI.Unicode, UnicodeAuto, UnicodeAlways, UnicodeNever)
+	}
+	return nil
+}
+
+var rxCloneFilter = regexp.MustCompile(`^(blob:none|blob:limit=[0-9]+[kmg]?|tree:[0-9]+)$`)
+
+// ValidateCloneFilter returns an error if filter is not a valid filter of
+// partial clone ("git clone --filter={filter}"). Empty filter is valid and
+// means full clone.
+func ValidateCloneFilter(filter string) error {
+	if filter != "" && !rxCloneFilter.MatchString(filter) {
+		return errors.New(`must be "blob:none", "blob:limit={size}", or "tree:{depth}"`)
+	}
+	return nil
+}
+
+// validateTemplateURL accepts http(s) URL, local directory path, or empty
+// string (which disables fetching templates).
+func validateTemplateURL(templateURL string) error {
+	if !strings.HasPrefix(templateURL, "http://") && !strings.HasPrefix(templateURL, "https://") {
+		return nil
+	}
+	u, err := url.Parse(templateURL)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" {
+		return fmt.Errorf("host is empty")
+	}
+	return nil
+}
+
+func validateProxy(proxy string) error {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("scheme must be %q, %q, or %q", "http", "https", "socks5")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("host is empty")
+	}
 	return nil
 }
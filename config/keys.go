@@ -0,0 +1,476 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// keyDef defines a key of config.toml which can be read or written by
+// 'volt config'.
+type keyDef struct {
+	// description is shown by 'volt config -help'
+	description string
+	// get returns the string representation of the value in cfg
+	get func(cfg *Config) string
+	// parse converts given string to the value which is written to config.toml
+	parse func(value string) (interface{}, error)
+}
+
+var keyDefs = map[string]keyDef{
+	"build.strategy": {
+		description: `"symlink" or "copy"`,
+		get:         func(cfg *Config) string { return cfg.Build.Strategy },
+		parse:       parseEnum(SymlinkBuilder, CopyBuilder),
+	},
+	"build.compile_lua": {
+		description: "compile Lua plugconf files to bytecode by Neovim",
+		get:         func(cfg *Config) string { return formatBool(cfg.Build.CompileLua) },
+		parse:       parseBool,
+	},
+	"build.jobs": {
+		description: "the number of repositories processed at the same time",
+		get:         func(cfg *Config) string { return strconv.Itoa(cfg.Build.Jobs) },
+		parse:       parseMinInt(1),
+	},
+	"build.merge_ftdetect": {
+		description: "merge ftdetect scripts of plugins into one file",
+		get:         func(cfg *Config) string { return formatBool(cfg.Build.MergeFtdetect) },
+		parse:       parseBool,
+	},
+	"build.slim": {
+		description: "exclude test suites, CI config, and images from copied plugins",
+		get:         func(cfg *Config) string { return formatBool(cfg.Build.Slim) },
+		parse:       parseBool,
+	},
+	"build.slim_doc": {
+		description: `also exclude "doc" directory from copied plugins if build.slim is true`,
+		get:         func(cfg *Config) string { return formatBool(cfg.Build.SlimDoc) },
+		parse:       parseBool,
+	},
+	"build.deterministic": {
+		description: "make lock.json and the files generated by \"volt build\" reproducible (sorted, no timestamps, LF line endings)",
+		get:         func(cfg *Config) string { return formatBool(cfg.Build.Deterministic) },
+		parse:       parseBool,
+	},
+	"build.cached_profiles": {
+		description: "the number of profiles whose built runtime is kept to switch profiles instantly (0 disables it)",
+		get:         func(cfg *Config) string { return strconv.Itoa(*cfg.Build.CachedProfiles) },
+		parse:       parseMinInt(0),
+	},
+	"debug.timings": {
+		description: "print the time spent in each phase (network, checkout, build, ...) after each command",
+		get:         func(cfg *Config) string { return formatBool(cfg.Debug.Timings) },
+		parse:       parseBool,
+	},
+	"get.create_skeleton_plugconf": {
+		description: "create skeleton plugconf file when installing plugins",
+		get:         func(cfg *Config) string { return formatBool(cfg.Get.CreateSkeletonPlugconf) },
+		parse:       parseBool,
+	},
+	"get.fallback_git_cmd": {
+		description: `fall back to "git" command when installing / upgrading plugins failed`,
+		get:         func(cfg *Config) string { return formatBool(cfg.Get.FallbackGitCmd) },
+		parse:       parseBool,
+	},
+	"get.jobs": {
+		description: "the number of repositories processed at the same time",
+		get:         func(cfg *Config) string { return strconv.Itoa(cfg.Get.Jobs) },
+		parse:       parseMinInt(1),
+	},
+	"get.on_force_push": {
+		description: `"ask", "keep" (local commit), or "reset" (to new upstream commit) when upstream history was rewritten`,
+		get:         func(cfg *Config) string { return cfg.Get.OnForcePush },
+		parse:       parseEnum(ForcePushAsk, ForcePushKeep, ForcePushReset),
+	},
+	"get.plugconf_template_url": {
+		description: `URL or local directory of plugconf templates ("" disables fetching templates)`,
+		get:         func(cfg *Config) string { return *cfg.Get.PlugconfTemplateURL },
+		parse:       parseString,
+	},
+	"git.backend": {
+		description: `"go-git" or "cli" (execute git command)`,
+		get:         func(cfg *Config) string { return cfg.Git.Backend },
+		parse:       parseEnum(GoGitBackend, CLIGitBackend),
+	},
+	"git.protocol": {
+		description: `the protocol of cloning repositories: "https" or "ssh" (authenticated by ssh-agent), overridden per host by [git.protocols]`,
+		get:         func(cfg *Config) string { return cfg.Git.Protocol },
+		parse:       parseEnum(HTTPSProtocol, SSHProtocol),
+	},
+	"git.clone_depth": {
+		description: "the depth of history fetched when cloning (0 means all history)",
+		get:         func(cfg *Config) string { return strconv.Itoa(*cfg.Git.CloneDepth) },
+		parse:       parseMinInt(0),
+	},
+	"git.clone_filter": {
+		description: `the filter of partial clone (e.g. "blob:none"), empty means full clone`,
+		get:         func(cfg *Config) string { return cfg.Git.CloneFilter },
+		parse: func(value string) (interface{}, error) {
+			if err := ValidateCloneFilter(value); err != nil {
+				return nil, err
+			}
+			return value, nil
+		},
+	},
+	"git.command": {
+		description: `the path of "git" command (empty means "git" in $PATH)`,
+		get:         func(cfg *Config) string { return cfg.Git.Command },
+		parse:       parseString,
+	},
+	"git.verify_signatures": {
+		description: "verify signatures of installed / upgraded commits by keys in $VOLTPATH/trusted_keys.asc",
+		get:         func(cfg *Config) string { return formatBool(cfg.Git.VerifySignatures) },
+		parse:       parseBool,
+	},
+	"lock.on_conflict": {
+		description: `"ask", "rebase" (apply changes to new lock.json), or "abort" when lock.json was changed by other programs`,
+		get:         func(cfg *Config) string { return cfg.Lock.OnConflict },
+		parse:       parseEnum(ConflictAsk, ConflictRebase, ConflictAbort),
+	},
+	"lock.wait": {
+		description: `the time to wait for other volt process to finish (e.g. "30s"), "0s" means exiting immediately`,
+		get:         func(cfg *Config) string { return cfg.Lock.Wait },
+		parse:       parseString,
+	},
+	"log.max_count": {
+		description: "the maximum number of transactions kept in the history (0 means unlimited)",
+		get:         func(cfg *Config) string { return strconv.Itoa(*cfg.Log.MaxCount) },
+		parse:       parseMinInt(0),
+	},
+	"log.max_age_days": {
+		description: "the maximum age (days) of transactions kept in the history (0 means unlimited)",
+		get:         func(cfg *Config) string { return strconv.Itoa(*cfg.Log.MaxAgeDays) },
+		parse:       parseMinInt(0),
+	},
+	"log.max_size_mb": {
+		description: "the maximum total size (MB) of the history of transactions (0 means unlimited)",
+		get:         func(cfg *Config) string { return strconv.Itoa(*cfg.Log.MaxSizeMB) },
+		parse:       parseMinInt(0),
+	},
+	"metadata.source": {
+		description: `where the descriptions of plugins are fetched: "vimawesome", "none" (cache only), or URL or local file of JSON index`,
+		get:         func(cfg *Config) string { return cfg.Metadata.Source },
+		parse:       parseString,
+	},
+	"network.proxy": {
+		description: `proxy URL for all network operations (e.g. "http://proxy:8080", "socks5://proxy:1080")`,
+		get:         func(cfg *Config) string { return cfg.Network.Proxy },
+		parse:       parseString,
+	},
+	"network.no_proxy": {
+		description: `comma-separated hosts which are accessed without proxy (e.g. "localhost,.example.com")`,
+		get:         func(cfg *Config) string { return cfg.Network.NoProxy },
+		parse:       parseString,
+	},
+	"network.retry_attempts": {
+		description: "the maximum number of attempts of a network operation (1 means no retry)",
+		get:         func(cfg *Config) string { return strconv.Itoa(cfg.Network.RetryAttempts) },
+		parse:       parseMinInt(1),
+	},
+	"network.retry_backoff": {
+		description: `the wait before the first retry, doubled on each retry (e.g. "1s", "500ms")`,
+		get:         func(cfg *Config) string { return cfg.Network.RetryBackoff },
+		parse:       parseString,
+	},
+	"network.retry_max_backoff": {
+		description: `the upper limit of the wait before a retry (e.g. "30s", "0s" means no limit)`,
+		get:         func(cfg *Config) string { return cfg.Network.RetryMaxBackoff },
+		parse:       parseString,
+	},
+	"network.jobs_per_host": {
+		description: "the maximum number of network operations which access the same host at the same time",
+		get:         func(cfg *Config) string { return strconv.Itoa(cfg.Network.JobsPerHost) },
+		parse:       parseMinInt(1),
+	},
+	"network.github_token": {
+		description: "the token of GitHub API, which raises the rate limit (default: $GITHUB_TOKEN)",
+		get:         func(cfg *Config) string { return cfg.Network.GitHubToken },
+		parse:       parseString,
+	},
+	"network.connect_timeout": {
+		description: `the timeout of connecting to a server and waiting for the response (e.g. "30s", "1m")`,
+		get:         func(cfg *Config) string { return cfg.Network.ConnectTimeout },
+		parse:       parseString,
+	},
+	"network.request_timeout": {
+		description: `the timeout of a whole HTTP request including the response body (e.g. "5m", "0s" means no limit)`,
+		get:         func(cfg *Config) string { return cfg.Network.RequestTimeout },
+		parse:       parseString,
+	},
+	"ui.color": {
+		description: `color the output: "auto" (only on a terminal, unless $NO_COLOR is set), "always", or "never"`,
+		get:         func(cfg *Config) string { return cfg.UI.Color },
+		parse:       parseEnum(ColorAuto, ColorAlways, ColorNever),
+	},
+	"ui.unicode": {
+		description: `use Unicode symbols in the output: "auto" (only on a UTF-8 terminal), "always", or "never"`,
+		get:         func(cfg *Config) string { return cfg.UI.Unicode },
+		parse:       parseEnum(UnicodeAuto, UnicodeAlways, UnicodeNever),
+	},
+	"ui.emoji": {
+		description: "use emoji in the output if ui.unicode is enabled",
+		get:         func(cfg *Config) string { return formatBool(cfg.UI.Emoji) },
+		parse:       parseBool,
+	},
+}
+
+// Keys returns all keys of config.toml which can be read or written by
+// 'volt config', in sorted order.
+func Keys() []string {
+	keys := make([]string, 0, len(keyDefs))
+	for key := range keyDefs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// KeyDescription returns the description of key.
+func KeyDescription(key string) string {
+	return keyDefs[key].description
+}
+
+// GetValue returns the string representation of the value of key in cfg.
+func GetValue(cfg *Config, key string) (string, error) {
+	def, exists := keyDefs[key]
+	if !exists {
+		return "", fmt.Errorf("unknown key '%s'", key)
+	}
+	return def.get(cfg), nil
+}
+
+// EnvName returns the name of environment variable which overrides key
+// (e.g. "VOLT_BUILD_STRATEGY" for "build.strategy").
+func EnvName(key string) string {
+	return "VOLT_" + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+// readEnv returns the TOML document of the values given by environment
+// variables, or "" if none of them is set. Empty variables are ignored.
+func readEnv() (string, error) {
+	raw := make(map[string]map[string]interface{})
+	for key, def := range keyDefs {
+		value := os.Getenv(EnvName(key))
+		if value == "" {
+			continue
+		}
+		v, err := def.parse(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid value of %s: %s", EnvName(key), err.Error())
+		}
+		names := strings.SplitN(key, ".", 2)
+		if raw[names[0]] == nil {
+			raw[names[0]] = make(map[string]interface{})
+		}
+		raw[names[0]][names[1]] = v
+	}
+	if len(raw) == 0 {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(raw); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Set validates value and writes it to key of config.toml, or
+// config.local.toml if local is true.
+// Only the line of key is rewritten, so other keys, sections and comments in
+// the file are kept.
+func Set(key, value string, local bool) error {
+	def, exists := keyDefs[key]
+	if !exists {
+		return fmt.Errorf("unknown key '%s'", key)
+	}
+	v, err := def.parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid value of '%s': %s", key, err.Error())
+	}
+
+	file := pathutil.ConfigTOML()
+	if local {
+		file = pathutil.ConfigLocalTOML()
+	}
+	// The file which does not exist is written with the latest version
+	content := []byte(fmt.Sprintf("version = %d\n", configVersion))
+	if pathutil.Exists(file) {
+		content, err = ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+	}
+	names := strings.SplitN(key, ".", 2)
+	content = setKeyLine(content, names[0], names[1], formatTOMLValue(v))
+
+	raw := make(map[string]interface{})
+	if _, err := toml.Decode(string(content), &raw); err != nil {
+		return err
+	}
+	if section, ok := raw[names[0]].(map[string]interface{}); !ok || fmt.Sprint(section[names[1]]) != fmt.Sprint(v) {
+		return fmt.Errorf("could not rewrite '%s' in %s", key, file)
+	}
+	return writeFile(file, content)
+}
+
+// IsSetLocally returns true if key is written in config.local.toml, which
+// overrides config.toml.
+func IsSetLocally(key string) bool {
+	raw, _, err := readRaw(pathutil.ConfigLocalTOML())
+	if err != nil {
+		return false
+	}
+	names := strings.SplitN(key, ".", 2)
+	section, _ := raw[names[0]].(map[string]interface{})
+	_, exists := section[names[1]]
+	return exists
+}
+
+// writeRaw validates raw and writes it to file.
+func writeRaw(file string, raw map[string]interface{}) error {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(raw); err != nil {
+		return err
+	}
+	return writeFile(file, buf.Bytes())
+}
+
+// writeFile validates content migrated to the latest version, and writes
+// content to file.
+func writeFile(file string, content []byte) error {
+	// Validate whole config before writing
+	raw := make(map[string]interface{})
+	if _, err := toml.Decode(string(content), &raw); err != nil {
+		return err
+	}
+	if _, _, err := migrate(raw); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(raw); err != nil {
+		return err
+	}
+	var cfg Config
+	if _, err := toml.Decode(buf.String(), &cfg); err != nil {
+		return err
+	}
+	merge(&cfg, initialConfigTOML())
+	if err := validate(&cfg); err != nil {
+		return err
+	}
+
+	os.MkdirAll(filepath.Dir(file), 0755)
+	return ioutil.WriteFile(file, content, 0644)
+}
+
+var (
+	rxSectionLine = regexp.MustCompile(`^\s*\[\s*([^\[\]\s]+)\s*\]\s*(#.*)?$`)
+	rxKeyLine     = regexp.MustCompile(`^\s*([A-Za-z0-9_-]+)\s*=`)
+)
+
+// setKeyLine returns content whose key in [section] is set to value.
+// If the key exists, its line is replaced. Otherwise the line is appended to
+// the section, or new section is appended to content.
+func setKeyLine(content []byte, section, key, value string) []byte {
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		lines[len(lines)-1] += "\n"
+	}
+	newLine := key + " = " + value + "\n"
+
+	inSection := false
+	lastKeyIdx := -1
+	for i, line := range lines {
+		if m := rxSectionLine.FindStringSubmatch(line); m != nil {
+			if inSection {
+				break
+			}
+			if m[1] == section {
+				inSection = true
+				lastKeyIdx = i
+			}
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if m := rxKeyLine.FindStringSubmatch(line); m != nil {
+			if m[1] == key {
+				indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				lines[i] = indent + newLine
+				return []byte(strings.Join(lines, ""))
+			}
+			lastKeyIdx = i
+		}
+	}
+
+	if lastKeyIdx < 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "\n")
+		}
+		lines = append(lines, "["+section+"]\n", newLine)
+		return []byte(strings.Join(lines, ""))
+	}
+	lines = append(lines[:lastKeyIdx+1], append([]string{newLine}, lines[lastKeyIdx+1:]...)...)
+	return []byte(strings.Join(lines, ""))
+}
+
+// formatTOMLValue returns TOML representation of v which is returned by
+// keyDef.parse.
+func formatTOMLValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(v)
+}
+
+func formatBool(b *bool) string {
+	return strconv.FormatBool(b != nil && *b)
+}
+
+func parseString(value string) (interface{}, error) {
+	return value, nil
+}
+
+func parseBool(value string) (interface{}, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, errors.New("must be true or false")
+	}
+	return b, nil
+}
+
+func parseMinInt(min int) func(string) (interface{}, error) {
+	return func(value string) (interface{}, error) {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < min {
+			return nil, fmt.Errorf("must be an integer of %d or greater", min)
+		}
+		return n, nil
+	}
+}
+
+func parseEnum(values ...string) func(string) (interface{}, error) {
+	return func(value string) (interface{}, error) {
+		for i := range values {
+			if value == values[i] {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("valid values are %q", values)
+	}
+}
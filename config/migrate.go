@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// configVersion is the version of the structure of config.toml. It is
+// incremented when keys are renamed or restructured, and migrateFunc
+// converts config.toml of old versions. config.toml without "version" is
+// version 1.
+const configVersion = 2
+
+var migrateFunc = []func(raw map[string]interface{}) bool{
+	migrate1To2,
+}
+
+// Rename "network.timeout" to "network.connect_timeout", because it is not
+// the timeout of a whole request ("network.request_timeout")
+func migrate1To2(raw map[string]interface{}) bool {
+	return renameKey(raw, "network", "timeout", "connect_timeout")
+}
+
+// renameKey renames key from to key to in section of raw. The value of to
+// is kept if both exist. It returns true if raw was changed.
+func renameKey(raw map[string]interface{}, section, from, to string) bool {
+	table, ok := raw[section].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	v, exists := table[from]
+	if !exists {
+		return false
+	}
+	if _, exists := table[to]; !exists {
+		table[to] = v
+	}
+	delete(table, from)
+	return true
+}
+
+// rawVersion returns "version" of raw.
+func rawVersion(raw map[string]interface{}) (int64, error) {
+	v, exists := raw["version"]
+	if !exists {
+		return 1, nil
+	}
+	version, ok := v.(int64)
+	if !ok || version < 1 {
+		return 0, fmt.Errorf("config.toml version is '%v' (must be an integer of 1 or greater)", v)
+	}
+	if version > configVersion {
+		return 0, fmt.Errorf("this config.toml version is '%d' which volt cannot recognize. please upgrade volt to process this file", version)
+	}
+	return version, nil
+}
+
+// migrate converts raw of old version to the latest version.
+// It returns the version before the migration, and whether any key was
+// changed. "version" is set to the latest version.
+func migrate(raw map[string]interface{}) (int64, bool, error) {
+	version, err := rawVersion(raw)
+	if err != nil {
+		return 0, false, err
+	}
+	changed := false
+	for v := version; v < configVersion; v++ {
+		if migrateFunc[v-1](raw) {
+			changed = true
+		}
+	}
+	raw["version"] = int64(configVersion)
+	return version, changed, nil
+}
+
+// readRaw reads file (config.toml or config.local.toml) as a generic table
+// and migrates it to the latest version. It returns nil if file does not
+// exist.
+func readRaw(file string) (map[string]interface{}, bool, error) {
+	if !pathutil.Exists(file) {
+		return nil, false, nil
+	}
+	raw := make(map[string]interface{})
+	if _, err := toml.DecodeFile(file, &raw); err != nil {
+		return nil, false, err
+	}
+	version, changed, err := migrate(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	if changed {
+		warnMigration(file, version)
+	}
+	return raw, changed, nil
+}
+
+var migrationWarned = make(map[string]bool)
+var migrationWarnedMu sync.Mutex
+
+// warnMigration shows the message of the migration in memory only once for
+// each file, because config.toml is read several times in a command.
+func warnMigration(file string, version int64) {
+	migrationWarnedMu.Lock()
+	defer migrationWarnedMu.Unlock()
+	if migrationWarned[file] {
+		return
+	}
+	migrationWarned[file] = true
+	logger.Warnf("Performing auto-migration of %s: v%d -> v%d", filepath.Base(file), version, configVersion)
+	logger.Warn("Please run 'volt migrate config' to write the migrated " + filepath.Base(file))
+}
+
+// Migrate writes config.toml and config.local.toml migrated to the latest
+// version. Comments in them are not kept.
+func Migrate() error {
+	for _, file := range configFiles() {
+		raw, _, err := readRaw(file)
+		if err != nil {
+			return fmt.Errorf("%s: %s", filepath.Base(file), err.Error())
+		}
+		if raw == nil {
+			continue
+		}
+		if err := writeRaw(file, raw); err != nil {
+			return fmt.Errorf("%s: %s", filepath.Base(file), err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Package diskcache caches the results of remote lookups (e.g. fetched
+// upstream commits, release data) in $VOLTPATH/cache, so repeated commands
+// within their TTL do not access the network. It also caches the results of
+// expensive local work (e.g. the tags files of help files).
+package diskcache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// entry is the content of a cache file.
+type entry struct {
+	Key      string          `json:"key"`
+	StoredAt time.Time       `json:"stored_at"`
+	Value    json.RawMessage `json:"value"`
+}
+
+// path returns "$VOLTPATH/cache/{namespace}/{hash of key}.json".
+func path(namespace, key string) string {
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(pathutil.CacheDir(), namespace, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get reads the value of key in namespace to v, and returns true if it was
+// stored within ttl.
+func Get(namespace, key string, ttl time.Duration, v interface{}) bool {
+	content, err := ioutil.ReadFile(path(namespace, key))
+	if err != nil {
+		return false
+	}
+	var e entry
+	if json.Unmarshal(content, &e) != nil || e.Key != key {
+		return false
+	}
+	if age := time.Since(e.StoredAt); age < 0 || age >= ttl {
+		return false
+	}
+	return json.Unmarshal(e.Value, v) == nil
+}
+
+// Put stores v as the value of key in namespace.
+func Put(namespace, key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(&entry{Key: key, StoredAt: time.Now().UTC(), Value: value})
+	if err != nil {
+		return err
+	}
+	p := path(namespace, key)
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, content, 0644)
+}
+
+// Remove removes the value of key in namespace.
+func Remove(namespace, key string) error {
+	err := os.Remove(path(namespace, key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
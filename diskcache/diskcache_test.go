@@ -0,0 +1,39 @@
+package diskcache
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGetPut(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldVoltPath := os.Getenv("VOLTPATH")
+	os.Setenv("VOLTPATH", dir)
+	defer os.Setenv("VOLTPATH", oldVoltPath)
+
+	var value string
+	if Get("test", "key", time.Minute, &value) {
+		t.Error("expected no value")
+	}
+	if err := Put("test", "key", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if !Get("test", "key", time.Minute, &value) || value != "value" {
+		t.Errorf("expected %q but got %q", "value", value)
+	}
+	if Get("test", "key", 0, &value) {
+		t.Error("expected the value is expired")
+	}
+	if err := Remove("test", "key"); err != nil {
+		t.Fatal(err)
+	}
+	if Get("test", "key", time.Minute, &value) {
+		t.Error("expected the value was removed")
+	}
+}
@@ -38,8 +38,9 @@ import (
 // CopyFile copies the contents of the file named src to the file named
 // by dst. The file will be created if it does not already exist. If the
 // destination file exists, all it's contents will be replaced by the contents
-// of the source file. The file mode is set to perm and
-// the copied data is synced/flushed to stable storage.
+// of the source file. The file mode is set to perm.
+// The copied data is not synced to stable storage: call SyncDir() after
+// copying many files.
 func CopyFile(src, dst string, buf []byte, perm os.FileMode) (err error) {
 	r, err := os.Open(src)
 	if err != nil {
@@ -62,10 +63,5 @@ func CopyFile(src, dst string, buf []byte, perm os.FileMode) (err error) {
 	}()
 
 	_, err = io.CopyBuffer(w, r, buf)
-	if err != nil {
-		return
-	}
-
-	err = w.Sync()
 	return
 }
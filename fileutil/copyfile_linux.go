@@ -39,13 +39,15 @@ import (
 // CopyFile copies the contents of the file named src to the file named
 // by dst. The file will be created if it does not already exist. If the
 // destination file exists, all it's contents will be replaced by the contents
-// of the source file. The file mode is set to perm and
-// the copied data is synced/flushed to stable storage.
+// of the source file. The file mode is set to perm.
+// The copied data is not synced to stable storage: call SyncDir() after
+// copying many files.
 func CopyFile(src, dst string, buf []byte, perm os.FileMode) error {
 	r, err := os.Open(src)
 	if err != nil {
 		return err
 	}
+	defer r.Close()
 	fi, err := r.Stat()
 	if err != nil {
 		return err
@@ -54,6 +56,7 @@ func CopyFile(src, dst string, buf []byte, perm os.FileMode) error {
 	if err != nil {
 		return err
 	}
+	defer w.Close()
 
 	wfd := int(w.Fd())
 	rfd := int(r.Fd())
@@ -29,9 +29,11 @@
 package fileutil
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 )
 
 // TryLinkDir recursively copies a directory tree, attempting to preserve permissions.
@@ -71,11 +73,28 @@ func TryLinkDir(src, dst string, buf []byte, perm os.FileMode, ignoreType os.Fil
 	return nil
 }
 
-// TryLinkFile tries os.Link() at first, but if it failed call CopyFile to copy
-// the contents of src to dst
+var errReflinkUnsupported = errors.New("reflink is not supported")
+
+// noReflink is set to 1 when reflinkFile failed with errReflinkUnsupported,
+// so it is not tried for each file
+var noReflink int32
+
+// TryLinkFile tries to make dst a reflink (copy-on-write clone) of src at
+// first, which shares the data blocks but is modified independently. If the
+// filesystem does not support it, it tries os.Link(), and if it also failed
+// call CopyFile to copy the contents of src to dst.
 func TryLinkFile(src, dst string, buf []byte, perm os.FileMode) error {
+	if atomic.LoadInt32(&noReflink) == 0 {
+		err := reflinkFile(src, dst, perm)
+		if err == nil {
+			return nil
+		}
+		if err == errReflinkUnsupported {
+			atomic.StoreInt32(&noReflink, 1)
+		}
+	}
 	if err := os.Link(src, dst); err == nil {
-		return err
+		return nil
 	}
 	return CopyFile(src, dst, buf, perm)
 }
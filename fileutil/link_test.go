@@ -0,0 +1,45 @@
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTryLinkFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	if err = ioutil.WriteFile(src, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = TryLinkFile(src, dst, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "content" {
+		t.Errorf("expected %q but got %q", "content", content)
+	}
+
+	// dst must be a reflink or a hard link on the same filesystem
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstInfo, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if noReflink != 0 && !os.SameFile(srcInfo, dstInfo) {
+		t.Error("expected a hard link because reflink is not supported")
+	}
+}
@@ -0,0 +1,34 @@
+//go:build linux
+// +build linux
+
+package fileutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl request (_IOW(0x94, 9, int)).
+const ficlone = 0x40049409
+
+// reflinkFile makes dst a copy-on-write clone of src on the filesystems
+// which support it (e.g. btrfs, XFS). dst must not exist.
+// errReflinkUnsupported is returned if the filesystem does not support it.
+func reflinkFile(src, dst string, perm os.FileMode) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, w.Fd(), ficlone, r.Fd())
+	w.Close()
+	if errno != 0 {
+		os.Remove(dst)
+		return errReflinkUnsupported
+	}
+	return nil
+}
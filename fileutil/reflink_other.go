@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package fileutil
+
+import (
+	"os"
+)
+
+func reflinkFile(src, dst string, perm os.FileMode) error {
+	return errReflinkUnsupported
+}
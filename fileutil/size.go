@@ -0,0 +1,22 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DirSize returns the total size of regular files under dir.
+// Symbolic links are not followed.
+func DirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,22 @@
+// +build linux
+
+package fileutil
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// SyncDir flushes the files written under dir to stable storage.
+// This calls syncfs(2) once for the filesystem which contains dir instead of
+// fsync(2) for each file, which is much faster on slow disks when many small
+// files were written.
+func SyncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return unix.Syncfs(int(f.Fd()))
+}
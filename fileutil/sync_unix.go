@@ -0,0 +1,25 @@
+// +build !windows,!linux
+
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// SyncDir flushes the files written under dir to stable storage.
+// Each regular file and each directory (which has the entries of the files)
+// is flushed.
+func SyncDir(dir string) error {
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() && !fi.IsDir() {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return f.Sync()
+	})
+}
@@ -0,0 +1,24 @@
+// +build windows
+
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// SyncDir flushes the files written under dir to stable storage.
+// Windows does not have sync(2), so each regular file is flushed.
+func SyncDir(dir string) error {
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return f.Sync()
+	})
+}
@@ -0,0 +1,640 @@
+package gitutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/netutil"
+	"github.com/vim-volt/volt/pathutil"
+	git "gopkg.in/src-d/go-git.v4"
+	gitconfig "gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+// Clone, Update, and ResetToVersion perform git operations with the backend
+// selected by "git.backend" in config.toml:
+//
+// * "go-git" (default): Use go-git (pure Go implementation).
+//   git command is not needed. If the operation failed and
+//   "get.fallback_git_cmd" is true, git command is executed instead.
+// * "cli": Always execute git command.
+//
+// Network operations are retried on transient errors (see netutil.RetryPolicy).
+// If the remote requires authentication, go-git backend obtains credentials
+// from git credential helpers (see withCredential). git command uses them by
+// itself.
+
+// Clone clones cloneURL to dstDir.
+// Submodules are not cloned (see UpdateSubmodules).
+// If "git.clone_depth" is not 0, the history is truncated to the depth
+// (shallow clone). The history is fetched later by Unshallow() if needed.
+//
+// The repository is cloned to "$VOLTPATH/tmp/clone/..." at first, and it is
+// renamed to dstDir after the clone succeeded. So dstDir does not exist if the
+// clone was interrupted (e.g. by Ctrl-C or network error), and the next clone
+// restarts cleanly.
+//
+// If the clone cache of dstDir exists (see MoveToCache), the objects in it are
+// reused, and only new objects are fetched.
+//
+// If filter is not empty, the repository is partially cloned by git command
+// (e.g. "blob:none" does not fetch file contents until they are checked out).
+func Clone(cloneURL, dstDir, filter string, cfg *config.Config) error {
+	cloneURL = withProtocol(cloneURL, cfg)
+	tmpDir := tempDirOf("clone", dstDir)
+	if pathutil.Exists(tmpDir) {
+		logger.Warnf("Removing %s which was left by interrupted clone ...", tmpDir)
+	}
+	var err error
+	if filter != "" {
+		err = clonePartially(cloneURL, tmpDir, cacheDirOf(dstDir), filter, cfg)
+	} else {
+		err = cloneToDir(cloneURL, tmpDir, cacheDirOf(dstDir), cfg)
+	}
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	if err = os.Rename(tmpDir, dstDir); err != nil {
+		return err
+	}
+	// The clone does not refer to the cache (--dissociate)
+	removeCache(dstDir)
+	return nil
+}
+
+// tempDirOf returns "$VOLTPATH/tmp/{kind}/{path}" where {path} is the relative
+// path of dir from $VOLTPATH (e.g. "repos/github.com/tyru/caw.vim").
+// It is used as a temporary directory which is renamed to dir, or dir is
+// renamed to. It is not under $VOLTPATH/repos, so it is never confused with
+// repositories, and "volt gc" can remove it safely.
+func tempDirOf(kind, dir string) string {
+	return filepath.Join(pathutil.TempDir(), kind, relPathOf(dir))
+}
+
+// relPathOf returns the relative path of dir from $VOLTPATH, or the base name
+// of dir if it is not under $VOLTPATH.
+func relPathOf(dir string) string {
+	rel, err := filepath.Rel(pathutil.VoltPath(), dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.Base(dir)
+	}
+	return rel
+}
+
+// withProtocol converts "https://{host}/" URL to "ssh://git@{host}/" URL,
+// or vice versa, according to the protocol of the host in config.toml
+// ("git.protocols.{host}" or "git.protocol"). Other URLs are not changed.
+func withProtocol(cloneURL string, cfg *config.Config) string {
+	const httpsPrefix, sshPrefix = "https://", "ssh://git@"
+	var rest string
+	switch {
+	case strings.HasPrefix(cloneURL, httpsPrefix):
+		rest = strings.TrimPrefix(cloneURL, httpsPrefix)
+	case strings.HasPrefix(cloneURL, sshPrefix):
+		rest = strings.TrimPrefix(cloneURL, sshPrefix)
+	default:
+		return cloneURL
+	}
+	host := strings.SplitN(rest, "/", 2)[0]
+	protocol, exists := cfg.Git.Protocols[host]
+	if !exists {
+		protocol = cfg.Git.Protocol
+	}
+	if protocol == config.SSHProtocol {
+		return sshPrefix + rest
+	}
+	return httpsPrefix + rest
+}
+
+// setRemoteProtocol changes the URL of remote by withProtocol(), so the
+// protocol in config.toml is also applied to the repositories which were
+// cloned before it was changed.
+// SSH URL is changed to HTTPS URL only if "git.protocols.{host}" is "https",
+// because the default protocol must not break the remotes which were changed
+// to SSH by the user (e.g. private repositories).
+func setRemoteProtocol(r *git.Repository, reposCfg *gitconfig.Config, remote string, cfg *config.Config) error {
+	remoteCfg, exists := reposCfg.Remotes[remote]
+	if !exists || len(remoteCfg.URLs) == 0 {
+		return nil
+	}
+	newURL := withProtocol(remoteCfg.URLs[0], cfg)
+	if newURL == remoteCfg.URLs[0] {
+		return nil
+	}
+	if rest := strings.TrimPrefix(newURL, "https://"); rest != newURL {
+		host := strings.SplitN(rest, "/", 2)[0]
+		if cfg.Git.Protocols[host] != config.HTTPSProtocol {
+			return nil
+		}
+	}
+	logger.Debugf("Changing the URL of remote '%s' from %s to %s ...", remote, remoteCfg.URLs[0], newURL)
+	remoteCfg.URLs[0] = newURL
+	return r.Storer.SetConfig(reposCfg)
+}
+
+func cloneToDir(cloneURL, dstDir, cacheDir string, cfg *config.Config) error {
+	depth := *cfg.Git.CloneDepth
+	policy := netutil.NewRetryPolicy(cfg)
+	if cfg.Git.Backend == config.CLIGitBackend {
+		return policy.Retry("git clone "+cloneURL, func() error {
+			return cloneByCLI(cloneURL, dstDir, depth, referenceArgs(cacheDir)...)
+		})
+	}
+
+	var r *git.Repository
+	var err error
+	if pathutil.Exists(cacheDir) {
+		r, err = cloneFromCache(cloneURL, dstDir, cacheDir, policy)
+		if err == nil {
+			return SetUpstreamRemote(r, "origin")
+		}
+		logger.Debugf("Could not clone from the cache %s: %s", cacheDir, err.Error())
+	}
+	err = policy.Retry("clone "+cloneURL, func() error {
+		return withCredential(cloneURL, func(auth transport.AuthMethod) error {
+			// Remove the directory which the previous attempt created
+			if err := os.RemoveAll(dstDir); err != nil {
+				return err
+			}
+			var err error
+			isBare := false
+			r, err = git.PlainClone(dstDir, isBare, &git.CloneOptions{
+				URL:   cloneURL,
+				Depth: depth,
+				Auth:  auth,
+			})
+			return err
+		})
+	})
+	if err == nil {
+		return SetUpstreamRemote(r, "origin")
+	}
+	if !canFallback(cfg) {
+		return err
+	}
+	logger.Warnf("failed to clone, try to execute \"git clone %s %s\" instead...: %s", cloneURL, dstDir, err.Error())
+	return policy.Retry("git clone "+cloneURL, func() error {
+		return cloneByCLI(cloneURL, dstDir, depth, referenceArgs(cacheDir)...)
+	})
+}
+
+// clonePartially executes "git clone --filter={filter}", because go-git does
+// not support partial clone.
+func clonePartially(cloneURL, dstDir, cacheDir, filter string, cfg *config.Config) error {
+	if !HasGitCmd() {
+		return errors.New("git command is required for partial clone")
+	}
+	depth := *cfg.Git.CloneDepth
+	policy := netutil.NewRetryPolicy(cfg)
+	return policy.Retry("git clone "+cloneURL, func() error {
+		opts := append(referenceArgs(cacheDir), "--filter="+filter)
+		return cloneByCLI(cloneURL, dstDir, depth, opts...)
+	})
+}
+
+// cloneByCLI removes dstDir (which may be created by the previous attempt),
+// and executes "git clone" with opts.
+func cloneByCLI(cloneURL, dstDir string, depth int, opts ...string) error {
+	if err := os.RemoveAll(dstDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dstDir), 0755); err != nil {
+		return err
+	}
+	args := append([]string{"clone"}, opts...)
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	args = append(args, cloneURL, dstDir)
+	_, err := execGit("", args...)
+	return err
+}
+
+// IsShallow returns true if r is a shallow repository.
+func IsShallow(r *git.Repository) (bool, error) {
+	commits, err := r.Storer.Shallow()
+	if err != nil {
+		return false, err
+	}
+	return len(commits) > 0, nil
+}
+
+// Unshallow fetches all history of reposPath if it is a shallow repository.
+// If git command is not installed, the repository is cloned again by go-git
+// and submodules may be removed, so UpdateSubmodules must be called after
+// that.
+func Unshallow(reposPath pathutil.ReposPath, cfg *config.Config) error {
+	fullpath := reposPath.FullPath()
+	r, err := git.PlainOpen(fullpath)
+	if err != nil {
+		return err
+	}
+	if shallow, err := IsShallow(r); err != nil || !shallow {
+		return err
+	}
+	remote, err := GetUpstreamRemote(r)
+	if err != nil {
+		return err
+	}
+	reposCfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	cfg = backendConfig(reposCfg, cfg)
+
+	logger.Debugf("Fetching all history of %s ...", reposPath)
+	policy := netutil.NewRetryPolicy(cfg)
+	// The existing clone is reused if possible, so git command is used even
+	// if "git.backend" is "go-git"
+	if cfg.Git.Backend == config.CLIGitBackend || HasGitCmd() {
+		return policy.Retry("git fetch --unshallow "+remote, func() error {
+			_, err := execGit(fullpath, "fetch", "--unshallow", remote)
+			return err
+		})
+	}
+
+	// go-git cannot deepen the existing shallow repository.
+	// Clone all history to a temporary directory and replace the repository
+	// only if git command is not installed.
+	// Local changes would be lost by the replacement, so refuse it if the
+	// repository has them.
+	if err = checkReplaceable(r); err != nil {
+		return errors.New("cannot replace the shallow repository: " + err.Error())
+	}
+	remoteCfg, exists := reposCfg.Remotes[remote]
+	if !exists || len(remoteCfg.URLs) == 0 {
+		return errors.New("could not find the URL of remote '" + remote + "'")
+	}
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+	tmpDir := tempDirOf("unshallow", fullpath)
+	var newRepos *git.Repository
+	err = policy.Retry("clone "+remoteCfg.URLs[0], func() error {
+		return withCredential(remoteCfg.URLs[0], func(auth transport.AuthMethod) error {
+			if err := os.RemoveAll(tmpDir); err != nil {
+				return err
+			}
+			var err error
+			newRepos, err = git.PlainClone(tmpDir, reposCfg.Core.IsBare, &git.CloneOptions{
+				URL:  remoteCfg.URLs[0],
+				Auth: auth,
+			})
+			return err
+		})
+	})
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	if _, err = newRepos.CommitObject(head.Hash()); err != nil {
+		os.RemoveAll(tmpDir)
+		return errors.New("HEAD commit " + head.Hash().String() + " does not exist in remote '" + remote + "'")
+	}
+	if !reposCfg.Core.IsBare {
+		if err = SetUpstreamRemote(newRepos, "origin"); err != nil {
+			os.RemoveAll(tmpDir)
+			return err
+		}
+	}
+	return replaceDir(tmpDir, fullpath)
+}
+
+// checkReplaceable returns non-nil error if r has the changes which exist
+// only in local: changes of worktree, stashes, and other branches than
+// current branch.
+func checkReplaceable(r *git.Repository) error {
+	if _, err := r.Reference(plumbing.ReferenceName("refs/stash"), false); err == nil {
+		return errors.New("repository has stashes")
+	}
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+	branches, err := r.Branches()
+	if err != nil {
+		return err
+	}
+	err = branches.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Name() != head.Name() {
+			return errors.New("repository has local branch '" + ref.Name().Short() + "'")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	reposCfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	if reposCfg.Core.IsBare {
+		return nil
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return err
+	}
+	if !status.IsClean() {
+		return errors.New("worktree has changes")
+	}
+	return nil
+}
+
+// replaceDir replaces dst with src.
+// dst is renamed aside before renaming src, and is restored if renaming src
+// failed. So dst is not lost even if an error occurred.
+func replaceDir(src, dst string) error {
+	backup := tempDirOf("old", dst)
+	if err := os.RemoveAll(backup); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(backup), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(dst, backup); err != nil {
+		os.RemoveAll(src)
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		os.Rename(backup, dst)
+		os.RemoveAll(src)
+		return err
+	}
+	return os.RemoveAll(backup)
+}
+
+// Update fetches objects from upstream remote of reposPath.
+// If the repository is non-bare, the worktree is also updated (like "git pull").
+// If there are no changes, git.NoErrAlreadyUpToDate is returned.
+// If the current commit is not reachable from the upstream branch,
+// *HistoryRewrittenError (upstream history was rewritten) or *DivergedError
+// (the current branch has local commits) is returned and the worktree is not
+// changed.
+// If Fetch was called within FetchHeadTTL, the fetched commit is used without
+// accessing the remote. If the remote cannot be accessed (e.g. offline), the
+// commit fetched by Fetch before is used regardless of its age.
+func Update(reposPath pathutil.ReposPath, cfg *config.Config) error {
+	err := update(reposPath, cfg)
+	if err == nil || !netutil.IsTransient(err) {
+		return err
+	}
+	r, e := git.PlainOpen(reposPath.FullPath())
+	if e != nil {
+		return err
+	}
+	reposCfg, e := r.Config()
+	if e != nil || reposCfg.Core.IsBare {
+		return err
+	}
+	remote, e := GetUpstreamRemote(r)
+	if e != nil {
+		return err
+	}
+	e = updateFromFetchHead(r, reposPath, remote, backendConfig(reposCfg, cfg), 0)
+	if e == errNoFetchHead {
+		return err
+	}
+	if e == nil {
+		logger.Warnf("%s: updated to the prefetched commit because the remote could not be accessed: %s", reposPath, err.Error())
+	}
+	return e
+}
+
+func update(reposPath pathutil.ReposPath, cfg *config.Config) error {
+	fullpath := reposPath.FullPath()
+	r, err := git.PlainOpen(fullpath)
+	if err != nil {
+		return err
+	}
+	reposCfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	cfg = backendConfig(reposCfg, cfg)
+	remote, err := GetUpstreamRemote(r)
+	if err != nil {
+		return err
+	}
+	if err = setRemoteProtocol(r, reposCfg, remote, cfg); err != nil {
+		return err
+	}
+	isBare := reposCfg.Core.IsBare
+	policy := netutil.NewRetryPolicy(cfg)
+	oldUpstream := upstreamHash(r, remote)
+
+	// Use the upstream commit fetched by Fetch recently
+	if !isBare {
+		if err = updateFromFetchHead(r, reposPath, remote, cfg, FetchHeadTTL); err != errNoFetchHead {
+			return err
+		}
+	}
+
+	if cfg.Git.Backend != config.CLIGitBackend {
+		err = policy.Retry("fetch "+reposPath.String(), func() error {
+			return withCredential(remoteURL(reposCfg, remote), func(auth transport.AuthMethod) error {
+				if isBare {
+					return r.Fetch(&git.FetchOptions{
+						RemoteName: remote,
+						Auth:       auth,
+					})
+				}
+				return pullByGoGit(r, remote, auth)
+			})
+		})
+		// go-git does not export the error of non-fast-forward update
+		if err != nil && strings.HasPrefix(err.Error(), "non-fast-forward update") {
+			if e := newNonFastForwardError(r, remote, oldUpstream); e != nil {
+				return e
+			}
+		}
+		if err == nil || err == git.NoErrAlreadyUpToDate || !canFallback(cfg) {
+			return err
+		}
+		if isBare {
+			logger.Warnf("failed to fetch, try to execute \"git fetch %s\" instead...: %s", remote, err.Error())
+		} else {
+			logger.Warnf("failed to pull, try to execute \"git pull\" instead...: %s", err.Error())
+		}
+	}
+
+	before, err := GetHEADRepository(r)
+	if err != nil {
+		return err
+	}
+	err = policy.Retry("git fetch "+reposPath.String(), func() error {
+		var err error
+		if isBare {
+			_, err = execGit(fullpath, "fetch", remote)
+		} else {
+			_, err = execGit(fullpath, "pull", "--ff-only")
+		}
+		return err
+	})
+	if cerr, ok := err.(*cliError); ok && strings.Contains(cerr.out, "Not possible to fast-forward") {
+		// Reopen to read the objects fetched by git command
+		if r, e := git.PlainOpen(fullpath); e == nil {
+			if e = newNonFastForwardError(r, remote, oldUpstream); e != nil {
+				return e
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	// Reopen to read the objects which were fetched into a new packfile
+	if r, err = git.PlainOpen(fullpath); err != nil {
+		return err
+	}
+	after, err := GetHEADRepository(r)
+	if err != nil {
+		return err
+	}
+	if before == after {
+		return git.NoErrAlreadyUpToDate
+	}
+	return nil
+}
+
+func pullByGoGit(r *git.Repository, remote string, auth transport.AuthMethod) error {
+	wt, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	return wt.Pull(&git.PullOptions{
+		RemoteName: remote,
+		Auth:       auth,
+		// TODO: Temporarily recursive clone is disabled, because go-git does
+		// not support relative submodule url in .gitmodules and it causes an
+		// error
+		RecurseSubmodules: 0,
+	})
+}
+
+// ResetToVersion moves current branch of reposPath to the commit of version,
+// and updates the index and the worktree (like "git reset --hard {version}").
+// Bare repositories are not changed because they have no worktree.
+// Submodules are not updated, so UpdateSubmodules must be called after that.
+func ResetToVersion(reposPath pathutil.ReposPath, version string, cfg *config.Config) error {
+	fullpath := reposPath.FullPath()
+	r, err := git.PlainOpen(fullpath)
+	if err != nil {
+		return err
+	}
+	reposCfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	cfg = backendConfig(reposCfg, cfg)
+	if reposCfg.Core.IsBare {
+		return nil
+	}
+
+	if r, err = fetchVersion(r, reposPath, version, cfg); err != nil {
+		return err
+	}
+
+	if cfg.Git.Backend != config.CLIGitBackend {
+		wt, err := r.Worktree()
+		if err != nil {
+			return err
+		}
+		err = wt.Reset(&git.ResetOptions{
+			Commit: plumbing.NewHash(version),
+			Mode:   git.HardReset,
+		})
+		if err == nil || !canFallback(cfg) {
+			return err
+		}
+		logger.Warnf("failed to reset, try to execute \"git reset --hard %s\" instead...: %s", version, err.Error())
+	}
+
+	_, err = execGit(fullpath, "reset", "--hard", version)
+	return err
+}
+
+// fetchVersion fetches older history if the commit of version is not in the
+// shallow history of r, and returns the reopened repository.
+func fetchVersion(r *git.Repository, reposPath pathutil.ReposPath, version string, cfg *config.Config) (*git.Repository, error) {
+	_, err := r.CommitObject(plumbing.NewHash(version))
+	if err != plumbing.ErrObjectNotFound {
+		return r, nil
+	}
+	if err = Unshallow(reposPath, cfg); err != nil {
+		return nil, errors.New("could not fetch all history: " + err.Error())
+	}
+	return git.PlainOpen(reposPath.FullPath())
+}
+
+// IsPartialClone returns true if reposPath was partially cloned (see Clone).
+func IsPartialClone(reposPath pathutil.ReposPath) bool {
+	r, err := git.PlainOpen(reposPath.FullPath())
+	if err != nil {
+		return false
+	}
+	reposCfg, err := r.Config()
+	if err != nil {
+		return false
+	}
+	return isPartialClone(reposCfg)
+}
+
+func isPartialClone(reposCfg *gitconfig.Config) bool {
+	// Older git sets "extensions.partialClone", and newer git sets
+	// "remote.{remote}.promisor"
+	if reposCfg.Raw.Section("extensions").Option("partialclone") != "" {
+		return true
+	}
+	for _, subsec := range reposCfg.Raw.Section("remote").Subsections {
+		if subsec.Option("promisor") == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// backendConfig returns cfg whose "git.backend" is "cli" if the repository of
+// reposCfg was partially cloned, because go-git cannot fetch missing objects.
+func backendConfig(reposCfg *gitconfig.Config, cfg *config.Config) *config.Config {
+	if cfg.Git.Backend == config.CLIGitBackend || !isPartialClone(reposCfg) {
+		return cfg
+	}
+	c := *cfg
+	c.Git.Backend = config.CLIGitBackend
+	return &c
+}
+
+// remoteURL returns the first URL of remote, or "" if it is not found.
+func remoteURL(reposCfg *gitconfig.Config, remote string) string {
+	if remoteCfg, exists := reposCfg.Remotes[remote]; exists && len(remoteCfg.URLs) > 0 {
+		return remoteCfg.URLs[0]
+	}
+	return ""
+}
+
+// canFallback returns true if git command can be executed when go-git
+// operation failed.
+func canFallback(cfg *config.Config) bool {
+	return *cfg.Get.FallbackGitCmd && HasGitCmd()
+}
+
+// CheckBackend returns non-nil error if the backend selected by config.toml
+// is not available.
+func CheckBackend(cfg *config.Config) error {
+	if cfg.Git.Backend == config.CLIGitBackend && !HasGitCmd() {
+		return errors.New("git.backend is \"cli\" but git command is not found")
+	}
+	return nil
+}
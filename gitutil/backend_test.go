@@ -0,0 +1,371 @@
+package gitutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// setUpGitEnv sets $HOME and $VOLTPATH to temporary directories, and puts
+// fake git command which logs arguments to returned file in front of $PATH.
+// It also creates a repository which has 2 commits, and returns its URL.
+func setUpGitEnv(t *testing.T) (cloneURL, gitLog string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake git command is a shell script")
+	}
+	realGit, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git command is not found")
+	}
+	tempDir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range []string{"HOME", "VOLTPATH", "PATH"} {
+		env := env
+		old, exists := os.LookupEnv(env)
+		t.Cleanup(func() {
+			if exists {
+				os.Setenv(env, old)
+			} else {
+				os.Unsetenv(env)
+			}
+		})
+	}
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+	os.Setenv("HOME", filepath.Join(tempDir, "home"))
+	os.Setenv("VOLTPATH", filepath.Join(tempDir, "volt"))
+	for _, env := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME", "GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		env := env
+		os.Setenv(env, "volt")
+		t.Cleanup(func() { os.Unsetenv(env) })
+	}
+
+	binDir := filepath.Join(tempDir, "bin")
+	os.MkdirAll(binDir, 0755)
+	gitLog = filepath.Join(tempDir, "git.log")
+	script := "#!/bin/sh\necho \"$*\" >>'" + gitLog + "'\nexec '" + realGit + "' \"$@\"\n"
+	if err := ioutil.WriteFile(filepath.Join(binDir, "git"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := filepath.Join(tempDir, "remote")
+	os.MkdirAll(remote, 0755)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"commit", "-q", "--allow-empty", "-m", "first"},
+		{"commit", "-q", "--allow-empty", "-m", "second"},
+	} {
+		if out, err := exec.Command(realGit, append([]string{"-C", remote}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s: %s", strings.Join(args, " "), err.Error(), string(out))
+		}
+	}
+	os.Setenv("PATH", binDir+string(filepath.ListSeparator)+os.Getenv("PATH"))
+	return "file://" + filepath.ToSlash(remote), gitLog
+}
+
+func testConfig(backend string, fallback bool) *config.Config {
+	depth := 0
+	cfg := &config.Config{}
+	cfg.Get.FallbackGitCmd = &fallback
+	cfg.Git.Backend = backend
+	cfg.Git.CloneDepth = &depth
+	return cfg
+}
+
+func readGitLog(t *testing.T, gitLog string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(gitLog)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// * Clone with "go-git" backend does not execute git command
+// * Clone with "go-git" backend executes git command if it failed and
+//   get.fallback_git_cmd is true
+// * Clone with "cli" backend executes git command
+func TestBackendClone(t *testing.T) {
+	for _, tt := range []struct {
+		backend  string
+		fallback bool
+		execGit  bool
+	}{
+		{config.GoGitBackend, false, false},
+		{config.GoGitBackend, true, true},
+		{config.CLIGitBackend, false, true},
+	} {
+		t.Run(fmt.Sprintf("backend=%s,fallback=%v", tt.backend, tt.fallback), func(t *testing.T) {
+			cloneURL, gitLog := setUpGitEnv(t)
+			cfg := testConfig(tt.backend, tt.fallback)
+			reposPath := pathutil.ReposPath("localhost/test/repos")
+
+			// go-git cannot clone the repository which does not exist, and
+			// git command cannot either
+			Clone(cloneURL+"-not-found", reposPath.FullPath(), "", cfg)
+			if got := strings.Contains(readGitLog(t, gitLog), "clone"); got != tt.execGit {
+				t.Errorf("expected git clone executed = %v, but got %v", tt.execGit, got)
+			}
+		})
+	}
+}
+
+// * The URL is not changed if git.protocol is "https"
+// * "https://" URL is changed to "ssh://git@" URL if git.protocol is "ssh"
+// * "git.protocols.{host}" overrides git.protocol, also for "ssh://git@" URL
+// * The URL which is neither "https://" nor "ssh://git@" is not changed
+func TestWithProtocol(t *testing.T) {
+	protocols := map[string]string{
+		"github.com":         config.SSHProtocol,
+		"gitlab.example.com": config.HTTPSProtocol,
+	}
+	for _, tt := range []struct {
+		protocol string
+		cloneURL string
+		expected string
+	}{
+		{config.HTTPSProtocol, "https://bitbucket.org/user/name", "https://bitbucket.org/user/name"},
+		{config.SSHProtocol, "https://bitbucket.org/user/name", "ssh://git@bitbucket.org/user/name"},
+		{config.HTTPSProtocol, "https://github.com/tyru/caw.vim", "ssh://git@github.com/tyru/caw.vim"},
+		{config.HTTPSProtocol, "ssh://git@github.com/tyru/caw.vim", "ssh://git@github.com/tyru/caw.vim"},
+		{config.SSHProtocol, "ssh://git@gitlab.example.com/user/name", "https://gitlab.example.com/user/name"},
+		{config.SSHProtocol, "git@github.com:tyru/caw.vim", "git@github.com:tyru/caw.vim"},
+		{config.SSHProtocol, "file:///tmp/caw.vim", "file:///tmp/caw.vim"},
+	} {
+		cfg := testConfig(config.GoGitBackend, false)
+		cfg.Git.Protocol = tt.protocol
+		cfg.Git.Protocols = protocols
+		if got := withProtocol(tt.cloneURL, cfg); got != tt.expected {
+			t.Errorf("withProtocol(%q) with git.protocol=%q: expected %q but got %q", tt.cloneURL, tt.protocol, tt.expected, got)
+		}
+	}
+}
+
+// * ResetToVersion with "go-git" backend does not execute git command
+// * ResetToVersion with "cli" backend executes git command
+func TestBackendResetToVersion(t *testing.T) {
+	for _, tt := range []struct {
+		backend string
+		execGit bool
+	}{
+		{config.GoGitBackend, false},
+		{config.CLIGitBackend, true},
+	} {
+		t.Run("backend="+tt.backend, func(t *testing.T) {
+			cloneURL, gitLog := setUpGitEnv(t)
+			cfg := testConfig(tt.backend, false)
+			reposPath := pathutil.ReposPath("localhost/test/repos")
+			if err := cloneByCLI(cloneURL, reposPath.FullPath(), 0); err != nil {
+				t.Fatal(err)
+			}
+			out, err := execGit(reposPath.FullPath(), "rev-parse", "HEAD~1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			version := strings.TrimSpace(string(out))
+
+			if err := ResetToVersion(reposPath, version, cfg); err != nil {
+				t.Fatal("ResetToVersion() failed: " + err.Error())
+			}
+			if got := strings.Contains(readGitLog(t, gitLog), "reset --hard "+version); got != tt.execGit {
+				t.Errorf("expected git reset executed = %v, but got %v", tt.execGit, got)
+			}
+			head, err := GetHEAD(reposPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if head != version {
+				t.Errorf("expected HEAD is %s but got %s", version, head)
+			}
+		})
+	}
+}
+
+// * CheckBackend returns an error if "cli" backend is selected but git
+//   command is not found
+func TestCheckBackend(t *testing.T) {
+	setUpGitEnv(t)
+	os.Setenv("PATH", "")
+	if err := CheckBackend(testConfig(config.GoGitBackend, false)); err != nil {
+		t.Errorf("expected nil but got %s", err.Error())
+	}
+	if err := CheckBackend(testConfig(config.CLIGitBackend, false)); err == nil {
+		t.Error("expected an error but got nil")
+	}
+}
+
+// * Unshallow with "go-git" backend refuses to replace the repository which
+//   has local changes if git command is not installed
+// * Unshallow fetches all history into the repository which has local
+//   changes if git command is installed
+func TestUnshallowLocalChanges(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		prepare []string
+	}{
+		{"worktree has changes", []string{"status"}},
+		{"repository has local branch", []string{"branch", "local"}},
+		{"repository has stashes", []string{"stash", "-q", "-u"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cloneURL, _ := setUpGitEnv(t)
+			cfg := testConfig(config.GoGitBackend, false)
+			reposPath := pathutil.ReposPath("localhost/test/repos")
+			if err := cloneByCLI(cloneURL, reposPath.FullPath(), 1); err != nil {
+				t.Fatal(err)
+			}
+			localFile := filepath.Join(reposPath.FullPath(), "local.txt")
+			if err := ioutil.WriteFile(localFile, []byte("local"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.name == "repository has local branch" {
+				if _, err := execGit(reposPath.FullPath(), "add", "local.txt"); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := execGit(reposPath.FullPath(), "commit", "-q", "-m", "local"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := execGit(reposPath.FullPath(), tt.prepare...); err != nil {
+				t.Fatal(err)
+			}
+
+			// Hide git command, so that go-git replaces the repository
+			path := os.Getenv("PATH")
+			os.Setenv("PATH", "")
+			err := Unshallow(reposPath, cfg)
+			os.Setenv("PATH", path)
+			if err == nil || !strings.Contains(err.Error(), tt.name) {
+				t.Errorf("expected error %q but got %v", tt.name, err)
+			}
+			shallowFile := filepath.Join(reposPath.FullPath(), ".git", "shallow")
+			if !pathutil.Exists(shallowFile) {
+				t.Error("the repository was replaced")
+			}
+
+			if err = Unshallow(reposPath, cfg); err != nil {
+				t.Fatal("Unshallow() failed: " + err.Error())
+			}
+			if pathutil.Exists(shallowFile) {
+				t.Error("all history was not fetched")
+			}
+		})
+	}
+}
+
+// * replaceDir replaces dst with src
+// * replaceDir keeps dst if src could not be renamed
+func TestReplaceDir(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	defer os.Setenv("VOLTPATH", os.Getenv("VOLTPATH"))
+	os.Setenv("VOLTPATH", tempDir)
+	src := filepath.Join(tempDir, "src")
+	dst := filepath.Join(tempDir, "repos", "dst")
+	for _, dir := range []string{src, dst} {
+		os.MkdirAll(dir, 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, "file"), []byte(dir), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := replaceDir(filepath.Join(tempDir, "not-found"), dst); err == nil {
+		t.Error("expected an error but got nil")
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dst, "file")); err != nil || string(b) != dst {
+		t.Errorf("dst was not kept: %q, %v", string(b), err)
+	}
+
+	if err := replaceDir(src, dst); err != nil {
+		t.Fatal("replaceDir() failed: " + err.Error())
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dst, "file")); err != nil || string(b) != src {
+		t.Errorf("dst was not replaced: %q, %v", string(b), err)
+	}
+	if pathutil.Exists(src) || pathutil.Exists(tempDirOf("old", dst)) {
+		t.Error("src or backup of dst remains")
+	}
+}
+
+// * Clone reuses the objects of the clone cache, and removes the cache
+//   (with "cli" backend, and "go-git" backend falling back to git command)
+// * MoveToCache does not cache shallow repositories
+func TestCloneFromCache(t *testing.T) {
+	for _, tt := range []struct {
+		backend  string
+		fallback bool
+	}{
+		{config.CLIGitBackend, false},
+		{config.GoGitBackend, true},
+	} {
+		t.Run(fmt.Sprintf("backend=%s,fallback=%v", tt.backend, tt.fallback), func(t *testing.T) {
+			cloneURL, gitLog := setUpGitEnv(t)
+			cfg := testConfig(tt.backend, tt.fallback)
+			reposPath := pathutil.ReposPath("localhost/test/repos")
+			fullpath := reposPath.FullPath()
+			if err := cloneByCLI(cloneURL, fullpath, 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := MoveToCache(reposPath, os.Rename); err != nil {
+				t.Fatal("MoveToCache() failed: " + err.Error())
+			}
+			os.RemoveAll(fullpath)
+			cacheDir := cacheDirOf(fullpath)
+			if !pathutil.Exists(cacheDir) {
+				t.Fatal("the cache was not created: " + cacheDir)
+			}
+			remote := strings.TrimPrefix(cloneURL, "file://")
+			if _, err := execGit(remote, "commit", "-q", "--allow-empty", "-m", "third"); err != nil {
+				t.Fatal(err)
+			}
+			out, err := execGit(remote, "rev-parse", "HEAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := strings.TrimSpace(string(out))
+
+			if err := Clone(cloneURL, fullpath, "", cfg); err != nil {
+				t.Fatal("Clone() failed: " + err.Error())
+			}
+			if !strings.Contains(readGitLog(t, gitLog), "--reference-if-able "+cacheDir+" --dissociate") {
+				t.Error("the cache was not used")
+			}
+			if head, err := GetHEAD(reposPath); err != nil || head != expected {
+				t.Errorf("expected HEAD is %s but got %s (%v)", expected, head, err)
+			}
+			if pathutil.Exists(filepath.Join(fullpath, ".git", "objects", "info", "alternates")) {
+				t.Error("the repository refers to the cache")
+			}
+			if pathutil.Exists(cacheDir) {
+				t.Error("the cache was not removed")
+			}
+		})
+	}
+
+	t.Run("shallow repository", func(t *testing.T) {
+		cloneURL, _ := setUpGitEnv(t)
+		reposPath := pathutil.ReposPath("localhost/test/repos")
+		if err := cloneByCLI(cloneURL, reposPath.FullPath(), 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := MoveToCache(reposPath, os.Rename); err != nil {
+			t.Fatal("MoveToCache() failed: " + err.Error())
+		}
+		if pathutil.Exists(cacheDirOf(reposPath.FullPath())) {
+			t.Error("shallow repository was cached")
+		}
+	})
+}
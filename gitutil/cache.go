@@ -0,0 +1,159 @@
+package gitutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/vim-volt/volt/fileutil"
+	"github.com/vim-volt/volt/netutil"
+	"github.com/vim-volt/volt/pathutil"
+	git "gopkg.in/src-d/go-git.v4"
+	gitconfig "gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+// The clone cache keeps .git directories of removed repositories in
+// "$VOLTPATH/cache/{path}" where {path} is the relative path of the
+// repository from $VOLTPATH (e.g. "repos/github.com/tyru/caw.vim").
+// When the repository is cloned again, Clone borrows the objects from the
+// cache (like "git clone --reference --dissociate"), so only new objects are
+// transferred. The cache is removed after that.
+
+// cacheDirOf returns the directory of the clone cache of dir.
+func cacheDirOf(dir string) string {
+	return filepath.Join(pathutil.CacheDir(), relPathOf(dir))
+}
+
+// MoveToCache moves .git directory of reposPath to the clone cache by rename
+// (e.g. os.Rename, or transaction.Rename to move it back on rollback).
+// The worktree is not moved. Bare repositories and shallow repositories are
+// not cached (git cannot borrow objects from shallow repositories).
+func MoveToCache(reposPath pathutil.ReposPath, rename func(oldpath, newpath string) error) error {
+	fullpath := reposPath.FullPath()
+	gitDir := filepath.Join(fullpath, ".git")
+	if fi, err := os.Stat(gitDir); err != nil || !fi.IsDir() {
+		return nil
+	}
+	if pathutil.Exists(filepath.Join(gitDir, "shallow")) {
+		return nil
+	}
+	cacheDir := cacheDirOf(fullpath)
+	if err := os.RemoveAll(cacheDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cacheDir), 0755); err != nil {
+		return err
+	}
+	return rename(gitDir, cacheDir)
+}
+
+// removeCache removes the clone cache of dir if it exists.
+func removeCache(dir string) {
+	cacheDir := cacheDirOf(dir)
+	if pathutil.Exists(cacheDir) {
+		os.RemoveAll(cacheDir)
+		fileutil.RemoveDirs(filepath.Dir(cacheDir))
+	}
+}
+
+// referenceArgs returns the arguments of "git clone" which borrow the objects
+// from cacheDir, or nil if cacheDir does not exist.
+func referenceArgs(cacheDir string) []string {
+	if !pathutil.Exists(cacheDir) {
+		return nil
+	}
+	return []string{"--reference-if-able", cacheDir, "--dissociate"}
+}
+
+// cloneFromCache moves cacheDir to "{dstDir}/.git", fetches new objects from
+// cloneURL, and checks out the branch which was checked out in the cache
+// (go-git does not support "git clone --reference").
+// If it failed, cacheDir is moved back.
+func cloneFromCache(cloneURL, dstDir, cacheDir string, policy *netutil.RetryPolicy) (*git.Repository, error) {
+	if err := os.RemoveAll(dstDir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return nil, err
+	}
+	gitDir := filepath.Join(dstDir, ".git")
+	if err := os.Rename(cacheDir, gitDir); err != nil {
+		return nil, err
+	}
+	r, err := fetchIntoCache(cloneURL, dstDir, policy)
+	if err != nil {
+		if os.Rename(gitDir, cacheDir) == nil {
+			os.RemoveAll(dstDir)
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+func fetchIntoCache(cloneURL, dstDir string, policy *netutil.RetryPolicy) (*git.Repository, error) {
+	r, err := git.PlainOpen(dstDir)
+	if err != nil {
+		return nil, err
+	}
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return nil, err
+	}
+	if head.Type() != plumbing.SymbolicReference {
+		return nil, errors.New("HEAD of the cache is detached")
+	}
+	branch := head.Target()
+
+	// Fetch from cloneURL even if the cache was cloned from another URL
+	reposCfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+	reposCfg.Remotes["origin"] = &gitconfig.RemoteConfig{
+		Name:  "origin",
+		URLs:  []string{cloneURL},
+		Fetch: []gitconfig.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+	}
+	if err = r.Storer.SetConfig(reposCfg); err != nil {
+		return nil, err
+	}
+	err = policy.Retry("fetch "+cloneURL, func() error {
+		return withCredential(cloneURL, func(auth transport.AuthMethod) error {
+			return r.Fetch(&git.FetchOptions{
+				RemoteName: "origin",
+				Auth:       auth,
+				Tags:       git.AllTags,
+			})
+		})
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, err
+	}
+
+	// Move the branch to the fetched commit, and check out it
+	upstream, err := r.Reference(plumbing.ReferenceName("refs/remotes/origin/"+branch.Short()), true)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.Storer.SetReference(plumbing.NewHashReference(branch, upstream.Hash())); err != nil {
+		return nil, err
+	}
+	// The index is of the removed worktree
+	if err = os.Remove(filepath.Join(dstDir, ".git", "index")); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	err = wt.Reset(&git.ResetOptions{
+		Commit: upstream.Hash(),
+		Mode:   git.HardReset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,117 @@
+package gitutil
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vim-volt/volt/pathutil"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// CommitInfo is a commit in the result of Changelog.
+type CommitInfo struct {
+	Hash    string
+	Subject string
+	Author  string
+	Date    time.Time
+}
+
+// Changelog returns the commits which are reachable from to but not from
+// from (like "git log {from}..{to}"), in reverse chronological order.
+// from and to are revisions (e.g. a commit hash, a tag, or a branch).
+// If the commits are not in the local repository, call FetchAllRefs before
+// this.
+func Changelog(reposPath pathutil.ReposPath, from, to string) ([]CommitInfo, error) {
+	r, err := git.PlainOpen(reposPath.FullPath())
+	if err != nil {
+		return nil, err
+	}
+	fromHash, err := resolveRevision(r, from)
+	if err != nil {
+		return nil, errors.New("could not resolve '" + from + "': " + err.Error())
+	}
+	toHash, err := resolveRevision(r, to)
+	if err != nil {
+		return nil, errors.New("could not resolve '" + to + "': " + err.Error())
+	}
+
+	// Mark all commits reachable from "from"
+	excluded := make(map[plumbing.Hash]bool)
+	walkCommits(r, fromHash, func(hash plumbing.Hash) bool {
+		if excluded[hash] {
+			return false
+		}
+		excluded[hash] = true
+		return true
+	})
+
+	var commits []CommitInfo
+	visited := make(map[plumbing.Hash]bool)
+	walkCommits(r, toHash, func(hash plumbing.Hash) bool {
+		if excluded[hash] || visited[hash] {
+			return false
+		}
+		visited[hash] = true
+		c, err := r.CommitObject(hash)
+		if err != nil {
+			return false
+		}
+		commits = append(commits, CommitInfo{
+			Hash:    c.Hash.String(),
+			Subject: strings.SplitN(strings.TrimSpace(c.Message), "\n", 2)[0],
+			Author:  c.Author.Name,
+			Date:    c.Author.When,
+		})
+		return true
+	})
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commits[i].Date.After(commits[j].Date)
+	})
+	return commits, nil
+}
+
+// resolveRevision returns the commit of rev, which is "HEAD", a local branch,
+// or a tag, a remote branch, or a commit hash (see resolveRef).
+func resolveRevision(r *git.Repository, rev string) (plumbing.Hash, error) {
+	if rev == "HEAD" {
+		head, err := r.Head()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		return head.Hash(), nil
+	}
+	if ref, err := r.Reference(plumbing.ReferenceName("refs/heads/"+rev), true); err == nil {
+		return ref.Hash(), nil
+	}
+	remote, err := GetUpstreamRemote(r)
+	if err != nil {
+		remote = "origin"
+	}
+	target, err := resolveRef(r, remote, rev)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return target.hash, nil
+}
+
+// walkCommits calls f with start and its ancestors in breadth-first order.
+// The parents of a commit are walked only if f returns true.
+// Commits beyond shallow history are not walked.
+func walkCommits(r *git.Repository, start plumbing.Hash, f func(plumbing.Hash) bool) {
+	queue := []plumbing.Hash{start}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if !f(hash) {
+			continue
+		}
+		c, err := r.CommitObject(hash)
+		if err != nil {
+			continue
+		}
+		queue = append(queue, c.ParentHashes...)
+	}
+}
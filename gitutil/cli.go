@@ -0,0 +1,101 @@
+package gitutil
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/vim-volt/volt/config"
+)
+
+// gitCommand is "command" and "args" in [git] section of config.toml.
+var gitCommand struct {
+	path string
+	args map[string][]string
+}
+
+// SetUpCommand sets the path and extra arguments of git command from
+// "git.command" and "git.args" of config.toml.
+// This must be called before executing git command.
+func SetUpCommand(cfg *config.Config) {
+	gitCommand.path = cfg.Git.Command
+	gitCommand.args = cfg.Git.Args
+}
+
+// gitExecutable returns the name of git command.
+func gitExecutable() string {
+	if gitCommand.path != "" {
+		return gitCommand.path
+	}
+	if runtime.GOOS == "windows" {
+		return "git.exe"
+	}
+	return "git"
+}
+
+// gitCmd returns exec.Cmd of git command with args.
+// Extra arguments in config.toml ("git.args.all" and "git.args.{subcommand}")
+// are inserted before args.
+func gitCmd(args ...string) *exec.Cmd {
+	var cmdArgs []string
+	cmdArgs = append(cmdArgs, gitCommand.args["all"]...)
+	if len(args) > 0 {
+		cmdArgs = append(cmdArgs, gitCommand.args[args[0]]...)
+	}
+	cmdArgs = append(cmdArgs, args...)
+	return exec.Command(gitExecutable(), cmdArgs...)
+}
+
+// HasGitCmd returns true if git command is installed.
+func HasGitCmd() bool {
+	_, err := exec.LookPath(gitExecutable())
+	return err == nil
+}
+
+// cliError is returned when git command failed.
+type cliError struct {
+	args []string
+	out  string
+	err  error
+}
+
+func (e *cliError) Error() string {
+	return fmt.Sprintf("\"git %s\" failed, out=%s: %s",
+		strings.Join(e.args, " "), e.out, e.err.Error())
+}
+
+// transientOutputs are the messages of git command which mean the operation
+// may succeed when it is retried. Unknown hosts and refused connections are
+// not retried, like netutil.IsTransient().
+var transientOutputs = []string{
+	"Connection reset",
+	"Connection timed out",
+	"Operation timed out",
+	"early EOF",
+	"the remote end hung up unexpectedly",
+	"The requested URL returned error: 5",
+	"RPC failed",
+}
+
+// Temporary returns true if the output of git command shows a network error.
+func (e *cliError) Temporary() bool {
+	for _, msg := range transientOutputs {
+		if strings.Contains(e.out, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// execGit executes git command with args in dir.
+// Returned error contains the output of git command.
+func execGit(dir string, args ...string) ([]byte, error) {
+	cmd := gitCmd(args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return out, &cliError{args: args, out: strings.TrimSpace(string(out)), err: err}
+	}
+	return out, nil
+}
@@ -0,0 +1,29 @@
+package gitutil
+
+import (
+	"errors"
+	"testing"
+)
+
+// * cliError is temporary if the output of git command shows a network error
+//   which may be resolved by retrying
+// * cliError is not temporary if the host is unknown or refused the
+//   connection, like netutil.IsTransient()
+func TestCLIErrorTemporary(t *testing.T) {
+	for _, tt := range []struct {
+		out      string
+		expected bool
+	}{
+		{"fatal: unable to access 'https://github.com/tyru/caw.vim/': Connection reset by peer", true},
+		{"fatal: the remote end hung up unexpectedly", true},
+		{"fatal: unable to access 'https://github.com/tyru/caw.vim/': The requested URL returned error: 502", true},
+		{"fatal: unable to access 'https://githb.com/tyru/caw.vim/': Could not resolve host: githb.com", false},
+		{"fatal: unable to access 'https://localhost/tyru/caw.vim/': Failed to connect to localhost port 443: Connection refused", false},
+		{"fatal: repository 'https://github.com/tyru/no-such-repos/' not found", false},
+	} {
+		err := &cliError{args: []string{"clone"}, out: tt.out, err: errors.New("exit status 128")}
+		if got := err.Temporary(); got != tt.expected {
+			t.Errorf("%q: expected %v but got %v", tt.out, tt.expected, got)
+		}
+	}
+}
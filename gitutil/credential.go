@@ -0,0 +1,191 @@
+package gitutil
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/vim-volt/volt/logger"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+)
+
+// credential is a pair of username and password for a host.
+type credential struct {
+	username string
+	password string
+	// fromGit is true if the credential was obtained by "git credential fill"
+	fromGit bool
+}
+
+// credentials are the cache of credentials obtained in this process.
+// The key is "{protocol}://{host}".
+// Credentials are never written to files by volt, and are stored by git
+// credential helpers if configured.
+var credentials = struct {
+	sync.Mutex
+	m map[string]*credential
+}{m: make(map[string]*credential)}
+
+// withCredential calls f without credential at first. If the remote of
+// remoteURL requires authentication, it obtains the credential and calls f
+// again with it (only for http and https).
+//
+// The credential is obtained from git credential helpers by
+// "git credential fill" (which also asks it by GIT_ASKPASS, core.askPass, or
+// the terminal). If git command is not installed, GIT_ASKPASS or SSH_ASKPASS
+// program is executed to ask it.
+func withCredential(remoteURL string, f func(auth transport.AuthMethod) error) error {
+	err := f(nil)
+	if err != transport.ErrAuthenticationRequired && err != transport.ErrAuthorizationFailed {
+		return err
+	}
+	u, perr := url.Parse(remoteURL)
+	if perr != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return err
+	}
+
+	cred, cerr := getCredential(u)
+	if cerr != nil {
+		return errors.New(err.Error() + " (could not get credential: " + cerr.Error() + ")")
+	}
+	err = f(http.NewBasicAuth(cred.username, cred.password))
+	switch err {
+	case nil:
+		approveCredential(u, cred)
+	case transport.ErrAuthenticationRequired, transport.ErrAuthorizationFailed:
+		rejectCredential(u, cred)
+	}
+	return err
+}
+
+func getCredential(u *url.URL) (*credential, error) {
+	// Ask one by one because it may use the terminal
+	credentials.Lock()
+	defer credentials.Unlock()
+
+	key := u.Scheme + "://" + u.Host
+	if cred, exists := credentials.m[key]; exists {
+		return cred, nil
+	}
+	var cred *credential
+	var err error
+	if HasGitCmd() {
+		cred, err = fillCredentialByGit(u)
+	} else {
+		cred, err = fillCredentialByAskPass(u)
+	}
+	if err != nil {
+		return nil, err
+	}
+	credentials.m[key] = cred
+	return cred, nil
+}
+
+// fillCredentialByGit executes "git credential fill".
+func fillCredentialByGit(u *url.URL) (*credential, error) {
+	cmd := gitCmd("credential", "fill")
+	cmd.Stdin = strings.NewReader(credentialInput(u, nil))
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.New("\"git credential fill\" failed: " + err.Error())
+	}
+	cred := &credential{fromGit: true}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "username":
+			cred.username = kv[1]
+		case "password":
+			cred.password = kv[1]
+		}
+	}
+	if cred.username == "" && cred.password == "" {
+		return nil, errors.New("no credential was given")
+	}
+	return cred, nil
+}
+
+// fillCredentialByAskPass executes GIT_ASKPASS or SSH_ASKPASS program.
+func fillCredentialByAskPass(u *url.URL) (*credential, error) {
+	askPass := os.Getenv("GIT_ASKPASS")
+	if askPass == "" {
+		askPass = os.Getenv("SSH_ASKPASS")
+	}
+	if askPass == "" {
+		return nil, errors.New("git command is not found, and GIT_ASKPASS or SSH_ASKPASS is not set")
+	}
+	ask := func(prompt string) (string, error) {
+		out, err := exec.Command(askPass, prompt).Output()
+		if err != nil {
+			return "", errors.New("\"" + askPass + "\" failed: " + err.Error())
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+	site := u.Scheme + "://" + u.Host
+	username, err := ask("Username for '" + site + "': ")
+	if err != nil {
+		return nil, err
+	}
+	password, err := ask("Password for '" + u.Scheme + "://" + username + "@" + u.Host + "': ")
+	if err != nil {
+		return nil, err
+	}
+	return &credential{username: username, password: password}, nil
+}
+
+// approveCredential lets git credential helpers store cred.
+func approveCredential(u *url.URL, cred *credential) {
+	if !cred.fromGit {
+		return
+	}
+	if err := execCredential("approve", u, cred); err != nil {
+		logger.Debug("\"git credential approve\" failed: " + err.Error())
+	}
+}
+
+// rejectCredential lets git credential helpers erase cred, and removes it
+// from the cache.
+func rejectCredential(u *url.URL, cred *credential) {
+	credentials.Lock()
+	delete(credentials.m, u.Scheme+"://"+u.Host)
+	credentials.Unlock()
+	if !cred.fromGit {
+		return
+	}
+	if err := execCredential("reject", u, cred); err != nil {
+		logger.Debug("\"git credential reject\" failed: " + err.Error())
+	}
+}
+
+func execCredential(action string, u *url.URL, cred *credential) error {
+	cmd := gitCmd("credential", action)
+	cmd.Stdin = strings.NewReader(credentialInput(u, cred))
+	return cmd.Run()
+}
+
+// credentialInput returns the input of "git credential" command.
+func credentialInput(u *url.URL, cred *credential) string {
+	var buf bytes.Buffer
+	buf.WriteString("protocol=" + u.Scheme + "\n")
+	buf.WriteString("host=" + u.Host + "\n")
+	if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+		buf.WriteString("path=" + path + "\n")
+	}
+	if cred != nil {
+		buf.WriteString("username=" + cred.username + "\n")
+		buf.WriteString("password=" + cred.password + "\n")
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
@@ -0,0 +1,188 @@
+package gitutil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/pathutil"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+const (
+	testUsername = "volt"
+	testPassword = "s3cret-password"
+)
+
+// setUpCredentialEnv starts the server which requires basic authentication,
+// and sets GIT_ASKPASS to the program which answers username and password.
+// It returns the URL of a repository, the file which the program logs
+// prompts to, and the number of requests which the server authenticated.
+func setUpCredentialEnv(t *testing.T, password string) (cloneURL, askPassLog string, authorized *int32) {
+	t.Helper()
+	setUpGitEnv(t)
+	credentials.Lock()
+	credentials.m = make(map[string]*credential)
+	credentials.Unlock()
+
+	authorized = new(int32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		username, password, ok := req.BasicAuth()
+		if !ok || username != testUsername || password != testPassword {
+			w.Header().Set("WWW-Authenticate", `Basic realm="volt"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		atomic.AddInt32(authorized, 1)
+		// Empty repository
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+		w.Write([]byte("001e# service=git-upload-pack\n0000" +
+			"003e0000000000000000000000000000000000000000 capabilities^{}\x00\n0000"))
+	}))
+	t.Cleanup(server.Close)
+
+	askPassLog = filepath.Join(os.Getenv("HOME"), "askpass.log")
+	askPass := filepath.Join(os.Getenv("HOME"), "askpass")
+	script := "#!/bin/sh\necho \"$1\" >>'" + askPassLog + "'\n" +
+		"case \"$1\" in\nUsername*) echo '" + testUsername + "' ;;\n*) echo '" + password + "' ;;\nesac\n"
+	os.MkdirAll(filepath.Dir(askPass), 0755)
+	if err := ioutil.WriteFile(askPass, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for env, value := range map[string]string{
+		"GIT_ASKPASS":         askPass,
+		"GIT_CONFIG_NOSYSTEM": "1",
+		"GIT_TERMINAL_PROMPT": "0",
+	} {
+		env := env
+		old, exists := os.LookupEnv(env)
+		t.Cleanup(func() {
+			if exists {
+				os.Setenv(env, old)
+			} else {
+				os.Unsetenv(env)
+			}
+		})
+		os.Setenv(env, value)
+	}
+	return server.URL + "/private.vim", askPassLog, authorized
+}
+
+func credentialConfig() *config.Config {
+	cfg := testConfig(config.GoGitBackend, false)
+	cfg.Network.RetryAttempts = 1
+	return cfg
+}
+
+// checkAuthorized fails if the server did not authenticate the request.
+// The server returns empty repository, so Clone fails even then.
+func checkAuthorized(t *testing.T, err error, authorized *int32) {
+	t.Helper()
+	if err == transport.ErrAuthenticationRequired || err == transport.ErrAuthorizationFailed {
+		t.Errorf("expected authentication succeeded but got %v", err)
+	}
+	if atomic.LoadInt32(authorized) == 0 {
+		t.Error("server did not authenticate any request")
+	}
+}
+
+// checkPasswordNotWritten fails if any file under $VOLTPATH contains the
+// password.
+func checkPasswordNotWritten(t *testing.T) {
+	t.Helper()
+	filepath.Walk(pathutil.VoltPath(), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if b, err := ioutil.ReadFile(path); err == nil && strings.Contains(string(b), testPassword) {
+			t.Errorf("password was written to %s", path)
+		}
+		return nil
+	})
+}
+
+// * Clone with "go-git" backend obtains the credential by
+//   "git credential fill" (which asks it by GIT_ASKPASS)
+// * Clone with "go-git" backend obtains the credential by GIT_ASKPASS if git
+//   command is not installed
+// * Clone with "go-git" backend uses the cached credential, and does not ask
+//   it again
+// * Clone with "go-git" backend fails with wrong credential, and does not
+//   cache it
+// * The credential is not written under $VOLTPATH
+func TestCloneWithCredential(t *testing.T) {
+	dst := func() string {
+		return pathutil.ReposPath("localhost/test/private.vim").FullPath()
+	}
+	countPrompts := func(t *testing.T, askPassLog string) int {
+		t.Helper()
+		b, err := ioutil.ReadFile(askPassLog)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		return strings.Count(string(b), "\n")
+	}
+
+	t.Run("git credential fill", func(t *testing.T) {
+		cloneURL, askPassLog, authorized := setUpCredentialEnv(t, testPassword)
+
+		// The server accepted the credential, and returned empty repository
+		err := Clone(cloneURL, dst(), "", credentialConfig())
+		checkAuthorized(t, err, authorized)
+		if n := countPrompts(t, askPassLog); n != 2 {
+			t.Errorf("expected username and password were asked but asked %d times", n)
+		}
+		checkPasswordNotWritten(t)
+	})
+
+	t.Run("GIT_ASKPASS without git command", func(t *testing.T) {
+		cloneURL, askPassLog, authorized := setUpCredentialEnv(t, testPassword)
+		os.Setenv("PATH", "")
+
+		err := Clone(cloneURL, dst(), "", credentialConfig())
+		checkAuthorized(t, err, authorized)
+		if n := countPrompts(t, askPassLog); n != 2 {
+			t.Errorf("expected username and password were asked but asked %d times", n)
+		}
+		checkPasswordNotWritten(t)
+	})
+
+	t.Run("cached credential", func(t *testing.T) {
+		cloneURL, askPassLog, authorized := setUpCredentialEnv(t, testPassword)
+
+		for i := 0; i < 2; i++ {
+			err := Clone(cloneURL, dst(), "", credentialConfig())
+			checkAuthorized(t, err, authorized)
+		}
+		if n := countPrompts(t, askPassLog); n != 2 {
+			t.Errorf("expected the credential was asked once but asked %d times", n)
+		}
+	})
+
+	t.Run("wrong credential", func(t *testing.T) {
+		cloneURL, _, authorized := setUpCredentialEnv(t, "wrong-password")
+
+		err := Clone(cloneURL, dst(), "", credentialConfig())
+		if err != transport.ErrAuthenticationRequired && err != transport.ErrAuthorizationFailed {
+			t.Errorf("expected authentication error but got %v", err)
+		}
+		if n := atomic.LoadInt32(authorized); n != 0 {
+			t.Errorf("server authenticated %d requests", n)
+		}
+		credentials.Lock()
+		n := len(credentials.m)
+		credentials.Unlock()
+		if n != 0 {
+			t.Error("wrong credential was cached")
+		}
+		if pathutil.Exists(dst()) {
+			t.Error("the repository was created")
+		}
+	})
+}
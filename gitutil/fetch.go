@@ -0,0 +1,165 @@
+package gitutil
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/netutil"
+	"github.com/vim-volt/volt/pathutil"
+	git "gopkg.in/src-d/go-git.v4"
+	gitconfig "gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+// fetchHeadFile is the file in .git directory which records the upstream
+// commit fetched by Fetch.
+const fetchHeadFile = "VOLT_FETCH_HEAD"
+
+// FetchHeadTTL is the duration while the upstream commit fetched by Fetch is
+// used by Update without accessing the remote again. The older one is used
+// only when the remote cannot be accessed.
+const FetchHeadTTL = 10 * time.Minute
+
+var errNoFetchHead = errors.New("no fetched upstream commit")
+
+// FetchResult is the result of Fetch.
+type FetchResult struct {
+	// Local is the current commit hash
+	Local string
+	// Remote is the fetched commit hash of the upstream branch
+	Remote string
+}
+
+// Fetch fetches the upstream branch of reposPath to the remote-tracking
+// branch (like "git fetch"). The worktree and the current branch are not
+// changed.
+// The fetched commit is recorded, and Update within FetchHeadTTL updates the
+// repository to it without accessing the remote again.
+func Fetch(reposPath pathutil.ReposPath, cfg *config.Config) (*FetchResult, error) {
+	fullpath := reposPath.FullPath()
+	r, err := git.PlainOpen(fullpath)
+	if err != nil {
+		return nil, err
+	}
+	reposCfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+	cfg = backendConfig(reposCfg, cfg)
+	if reposCfg.Core.IsBare {
+		return nil, errors.New("bare repository cannot be fetched without updating it")
+	}
+	remote, err := GetUpstreamRemote(r)
+	if err != nil {
+		return nil, err
+	}
+	if err = setRemoteProtocol(r, reposCfg, remote, cfg); err != nil {
+		return nil, err
+	}
+	refName, err := upstreamRef(r, remote)
+	if err != nil {
+		return nil, err
+	}
+	branch := strings.TrimPrefix(refName.String(), "refs/remotes/"+remote+"/")
+	refSpec := "+refs/heads/" + branch + ":" + refName.String()
+	policy := netutil.NewRetryPolicy(cfg)
+
+	fetched := false
+	if cfg.Git.Backend != config.CLIGitBackend {
+		err = policy.Retry("fetch "+reposPath.String(), func() error {
+			return withCredential(remoteURL(reposCfg, remote), func(auth transport.AuthMethod) error {
+				return r.Fetch(&git.FetchOptions{
+					RemoteName: remote,
+					RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(refSpec)},
+					Auth:       auth,
+				})
+			})
+		})
+		if err == git.NoErrAlreadyUpToDate {
+			err = nil
+		}
+		if err != nil && !canFallback(cfg) {
+			return nil, err
+		}
+		if err != nil {
+			logger.Warnf("failed to fetch, try to execute \"git fetch %s\" instead...: %s", remote, err.Error())
+		} else {
+			fetched = true
+		}
+	}
+	if !fetched {
+		err = policy.Retry("git fetch "+reposPath.String(), func() error {
+			_, err := execGit(fullpath, "fetch", remote, refSpec)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	local, err := GetHEADRepository(r)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := r.Reference(refName, true)
+	if err != nil {
+		return nil, err
+	}
+	result := &FetchResult{Local: local, Remote: ref.Hash().String()}
+	err = ioutil.WriteFile(filepath.Join(fullpath, ".git", fetchHeadFile), []byte(result.Remote+"\n"), 0644)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// updateFromFetchHead updates the current branch of r to the upstream commit
+// recorded by Fetch without accessing the remote.
+// If the record does not exist, is older than ttl (0 means no limit), or the
+// update is not a fast-forward, errNoFetchHead is returned and the repository
+// is not changed. The record is removed unless it is older than ttl, which is
+// kept for the update when the remote cannot be accessed (see Update).
+func updateFromFetchHead(r *git.Repository, reposPath pathutil.ReposPath, remote string, cfg *config.Config, ttl time.Duration) error {
+	path := filepath.Join(reposPath.FullPath(), ".git", fetchHeadFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		return errNoFetchHead
+	}
+	if ttl > 0 && time.Since(info.ModTime()) > ttl {
+		return errNoFetchHead
+	}
+	defer os.Remove(path)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errNoFetchHead
+	}
+	fetched := plumbing.NewHash(strings.TrimSpace(string(content)))
+
+	refName, err := upstreamRef(r, remote)
+	if err != nil {
+		return errNoFetchHead
+	}
+	ref, err := r.Reference(refName, true)
+	if err != nil || ref.Hash() != fetched {
+		return errNoFetchHead
+	}
+	head, err := r.Head()
+	if err != nil {
+		return errNoFetchHead
+	}
+	if head.Hash() == fetched {
+		return git.NoErrAlreadyUpToDate
+	}
+	if !isAncestor(r, head.Hash(), fetched) {
+		return errNoFetchHead
+	}
+	logger.Debugf("Updating %s to fetched commit %s ...", reposPath, fetched)
+	return ResetToVersion(reposPath, fetched.String(), cfg)
+}
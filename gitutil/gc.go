@@ -0,0 +1,18 @@
+package gitutil
+
+import (
+	"errors"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// GC removes unreachable objects, and packs loose objects of reposPath
+// (like "git gc --prune=now").
+// go-git cannot do this, so git command is always executed.
+func GC(reposPath pathutil.ReposPath) error {
+	if !HasGitCmd() {
+		return errors.New("git command is not found")
+	}
+	_, err := execGit(reposPath.FullPath(), "gc", "--prune=now", "--quiet")
+	return err
+}
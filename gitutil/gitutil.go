@@ -89,6 +89,7 @@ func SetUpstreamRemote(r *git.Repository, remote string) error {
 }
 
 // GetUpstreamRemote gets current branch's upstream remote name (e.g. "origin").
+// If HEAD is detached (see IsDetachedHEAD), "origin" is returned if it exists.
 func GetUpstreamRemote(r *git.Repository) (string, error) {
 	cfg, err := r.Config()
 	if err != nil {
@@ -100,6 +101,12 @@ func GetUpstreamRemote(r *git.Repository) (string, error) {
 		return "", err
 	}
 
+	if head.Name() == plumbing.HEAD {
+		if _, exists := cfg.Remotes["origin"]; !exists {
+			return "", errors.New("HEAD is detached and remote 'origin' is not found")
+		}
+		return "origin", nil
+	}
 	refBranch := head.Name().String()
 	branch := refHeadsRx.FindStringSubmatch(refBranch)
 	if len(branch) == 0 {
@@ -113,3 +120,18 @@ func GetUpstreamRemote(r *git.Repository) (string, error) {
 	}
 	return remote, nil
 }
+
+// IsDetachedHEAD returns true if HEAD of reposPath points to a commit
+// directly, not a branch. It means the version is pinned by
+// "volt get {repository}@{tag or commit}".
+func IsDetachedHEAD(reposPath pathutil.ReposPath) (bool, error) {
+	r, err := git.PlainOpen(reposPath.FullPath())
+	if err != nil {
+		return false, err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return false, err
+	}
+	return head.Name() == plumbing.HEAD, nil
+}
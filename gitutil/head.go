@@ -0,0 +1,155 @@
+package gitutil
+
+import (
+	"sort"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// GetHEADState returns the state of HEAD of reposPath, and the branch or tag
+// name:
+//
+//   - lockjson.HeadBranch and the branch name if a branch is checked out
+//   - lockjson.HeadTag and the tag name if HEAD is detached at a tag
+//   - lockjson.HeadDetached and "" if HEAD is detached at other commit
+//
+// Bare repositories return "" and "" because they have no worktree.
+func GetHEADState(reposPath pathutil.ReposPath) (string, string, error) {
+	r, err := git.PlainOpen(reposPath.FullPath())
+	if err != nil {
+		return "", "", err
+	}
+	reposCfg, err := r.Config()
+	if err != nil {
+		return "", "", err
+	}
+	if reposCfg.Core.IsBare {
+		return "", "", nil
+	}
+	head, err := r.Head()
+	if err != nil {
+		return "", "", err
+	}
+	if branch := refHeadsRx.FindStringSubmatch(head.Name().String()); len(branch) > 0 {
+		return lockjson.HeadBranch, branch[1], nil
+	}
+
+	// HEAD is detached
+	tags, err := tagsAt(r, head.Hash())
+	if err != nil {
+		return "", "", err
+	}
+	if len(tags) > 0 {
+		return lockjson.HeadTag, tags[0], nil
+	}
+	return lockjson.HeadDetached, "", nil
+}
+
+// tagsAt returns the sorted names of tags which point to the commit of hash.
+func tagsAt(r *git.Repository, hash plumbing.Hash) ([]string, error) {
+	iter, err := r.Tags()
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		target := ref.Hash()
+		// Peel annotated tag
+		if tag, err := r.TagObject(target); err == nil {
+			target = tag.Target
+		}
+		if target == hash {
+			tags = append(tags, ref.Name().Short())
+		}
+		return nil
+	})
+	sort.Strings(tags)
+	return tags, err
+}
+
+// CheckoutVersion checks out the commit of version of reposPath, and
+// restores the state of HEAD returned by GetHEADState:
+//
+//   - lockjson.HeadBranch: branch headRef is checked out and moved to version.
+//     If the branch does not exist, it is created (and tracks the remote
+//     branch of the same name if it exists).
+//   - lockjson.HeadTag, lockjson.HeadDetached: HEAD is detached at version.
+//   - "": current branch is moved to version (see ResetToVersion).
+//
+// Bare repositories are not changed because they have no worktree.
+// Submodules are not updated, so UpdateSubmodules must be called after that.
+func CheckoutVersion(reposPath pathutil.ReposPath, version, head, headRef string, cfg *config.Config) error {
+	if head == "" {
+		return ResetToVersion(reposPath, version, cfg)
+	}
+	fullpath := reposPath.FullPath()
+	r, err := git.PlainOpen(fullpath)
+	if err != nil {
+		return err
+	}
+	reposCfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	cfg = backendConfig(reposCfg, cfg)
+	if reposCfg.Core.IsBare {
+		return nil
+	}
+
+	if head == lockjson.HeadBranch {
+		current, err := r.Head()
+		if err != nil {
+			return err
+		}
+		branchRef := plumbing.ReferenceName("refs/heads/" + headRef)
+		if current.Name() == branchRef {
+			return ResetToVersion(reposPath, version, cfg)
+		}
+		if _, err = r.Reference(branchRef, false); err != nil {
+			// Check out the remote branch to track it
+			if err = Checkout(reposPath, headRef, cfg); err == nil {
+				return ResetToVersion(reposPath, version, cfg)
+			}
+			logger.Debugf("'%s' is not found in remote of %s, creating local branch ...", headRef, reposPath)
+		}
+	}
+
+	if r, err = fetchVersion(r, reposPath, version, cfg); err != nil {
+		return err
+	}
+	hash := plumbing.NewHash(version)
+	if cfg.Git.Backend != config.CLIGitBackend {
+		if head == lockjson.HeadBranch {
+			err = checkoutLocalBranchByGoGit(r, headRef, hash)
+		} else {
+			err = detachHEADByGoGit(r, hash)
+		}
+		if err == nil || !canFallback(cfg) {
+			return err
+		}
+		logger.Warnf("failed to check out, try to execute \"git checkout\" instead...: %s", err.Error())
+	}
+
+	if head == lockjson.HeadBranch {
+		_, err = execGit(fullpath, "checkout", "-B", headRef, version)
+	} else {
+		_, err = execGit(fullpath, "checkout", "--detach", version)
+	}
+	return err
+}
+
+func detachHEADByGoGit(r *git.Repository, hash plumbing.Hash) error {
+	wt, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	return wt.Checkout(&git.CheckoutOptions{
+		Hash:  hash,
+		Force: true,
+	})
+}
@@ -0,0 +1,78 @@
+package gitutil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// * CheckoutVersion checks out the branch, and moves it to the version
+//   (HeadBranch)
+// * CheckoutVersion creates the branch if it does not exist (HeadBranch)
+// * CheckoutVersion detaches HEAD at the tag (HeadTag)
+// * CheckoutVersion detaches HEAD at the version (HeadDetached)
+// * GetHEADState returns the state which CheckoutVersion restored
+func TestCheckoutVersion(t *testing.T) {
+	for _, backend := range []string{config.GoGitBackend, config.CLIGitBackend} {
+		for _, tt := range []struct {
+			name string
+			// version is "first" or "second" commit
+			version string
+			head    string
+			headRef string
+		}{
+			{"branch", "first", lockjson.HeadBranch, ""},
+			{"new branch", "first", lockjson.HeadBranch, "local"},
+			{"tag", "second", lockjson.HeadTag, "v1.0.0"},
+			{"detached", "first", lockjson.HeadDetached, ""},
+		} {
+			t.Run("backend="+backend+","+tt.name, func(t *testing.T) {
+				cloneURL, _ := setUpGitEnv(t)
+				cfg := testConfig(backend, false)
+				cfg.Network.RetryAttempts = 1
+				remote := strings.TrimPrefix(cloneURL, "file://")
+				if _, err := execGit(remote, "tag", "-a", "-m", "v1.0.0", "v1.0.0"); err != nil {
+					t.Fatal(err)
+				}
+				reposPath := pathutil.ReposPath("localhost/test/repos")
+				if err := cloneByCLI(cloneURL, reposPath.FullPath(), 0); err != nil {
+					t.Fatal(err)
+				}
+				revParse := func(args ...string) string {
+					out, err := execGit(reposPath.FullPath(), append([]string{"rev-parse"}, args...)...)
+					if err != nil {
+						t.Fatal(err)
+					}
+					return strings.TrimSpace(string(out))
+				}
+				versions := map[string]string{"first": revParse("HEAD~1"), "second": revParse("HEAD")}
+				headRef := tt.headRef
+				if tt.name == "branch" {
+					headRef = revParse("--abbrev-ref", "HEAD")
+				}
+				// Detach HEAD to see that the branch is checked out again
+				if _, err := execGit(reposPath.FullPath(), "checkout", "-q", "--detach", "HEAD"); err != nil {
+					t.Fatal(err)
+				}
+
+				version := versions[tt.version]
+				if err := CheckoutVersion(reposPath, version, tt.head, headRef, cfg); err != nil {
+					t.Fatal("CheckoutVersion() failed: " + err.Error())
+				}
+				if head, err := GetHEAD(reposPath); err != nil || head != version {
+					t.Errorf("expected HEAD is %s but got %s (%v)", version, head, err)
+				}
+				head, ref, err := GetHEADState(reposPath)
+				if err != nil {
+					t.Fatal("GetHEADState() failed: " + err.Error())
+				}
+				if head != tt.head || ref != headRef {
+					t.Errorf("expected state is %q %q but got %q %q", tt.head, headRef, head, ref)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package gitutil
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/netutil"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// lfsPointerPrefix is the beginning of a Git LFS pointer file which is
+// checked out instead of the actual content.
+var lfsPointerPrefix = []byte("version https://git-lfs.github.com/spec/v1\n")
+
+// UsesLFS returns true if .gitattributes of reposPath has files which are
+// stored by Git LFS ("filter=lfs").
+func UsesLFS(reposPath pathutil.ReposPath) bool {
+	content, err := ioutil.ReadFile(filepath.Join(reposPath.FullPath(), ".gitattributes"))
+	if err != nil {
+		return false
+	}
+	return bytes.Contains(content, []byte("filter=lfs"))
+}
+
+// HasLFSCmd returns true if git-lfs is installed.
+func HasLFSCmd() bool {
+	if !HasGitCmd() {
+		return false
+	}
+	_, err := execGit("", "lfs", "version")
+	return err == nil
+}
+
+// PullLFS downloads the files stored by Git LFS in reposPath, and replaces
+// the pointer files in the worktree with them (like "git lfs pull").
+// If reposPath does not use Git LFS, it does nothing.
+//
+// git-lfs is required because go-git does not support Git LFS. If it is not
+// installed, the paths of pointer files (placeholders of the actual files)
+// are returned without error. The paths are relative to reposPath.
+func PullLFS(reposPath pathutil.ReposPath, cfg *config.Config) ([]string, error) {
+	if !UsesLFS(reposPath) {
+		return nil, nil
+	}
+	fullpath := reposPath.FullPath()
+	if !HasLFSCmd() {
+		return lfsPointerFiles(fullpath)
+	}
+	policy := netutil.NewRetryPolicy(cfg)
+	return nil, policy.Retry("git lfs pull "+reposPath.String(), func() error {
+		_, err := execGit(fullpath, "lfs", "pull")
+		return err
+	})
+}
+
+// lfsPointerFiles returns the paths of Git LFS pointer files in the worktree
+// of dir. The paths are relative to dir, and separated by "/".
+func lfsPointerFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || info.Size() > 1024 {
+			return nil
+		}
+		isPointer, err := isLFSPointer(path)
+		if err != nil {
+			return err
+		}
+		if isPointer {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return files, err
+}
+
+func isLFSPointer(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	buf := make([]byte, len(lfsPointerPrefix))
+	if _, err = io.ReadFull(f, buf); err != nil {
+		// The file is shorter than the prefix
+		return false, nil
+	}
+	return bytes.Equal(buf, lfsPointerPrefix), nil
+}
@@ -0,0 +1,121 @@
+package gitutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+const testLFSPointer = "version https://git-lfs.github.com/spec/v1\n" +
+	"oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\n" +
+	"size 12345\n"
+
+// setUpLFSEnv creates a repository which has files under $VOLTPATH, and puts
+// fake git command in front of $PATH. The fake git command logs arguments to
+// returned file, and succeeds "git lfs ..." only if hasLFS is true.
+func setUpLFSEnv(t *testing.T, files map[string]string, hasLFS bool) (pathutil.ReposPath, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake git command is a shell script")
+	}
+	tempDir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range []string{"VOLTPATH", "PATH"} {
+		env := env
+		old, exists := os.LookupEnv(env)
+		t.Cleanup(func() {
+			if exists {
+				os.Setenv(env, old)
+			} else {
+				os.Unsetenv(env)
+			}
+		})
+	}
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+	os.Setenv("VOLTPATH", filepath.Join(tempDir, "volt"))
+
+	reposPath := pathutil.ReposPath("localhost/test/lfs.vim")
+	for name, content := range files {
+		path := filepath.Join(reposPath.FullPath(), filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	binDir := filepath.Join(tempDir, "bin")
+	os.MkdirAll(binDir, 0755)
+	gitLog := filepath.Join(tempDir, "git.log")
+	lfsExit := "1"
+	if hasLFS {
+		lfsExit = "0"
+	}
+	script := "#!/bin/sh\necho \"$*\" >>'" + gitLog + "'\n" +
+		"if [ \"$1\" = lfs ]; then exit " + lfsExit + "; fi\n"
+	if err := ioutil.WriteFile(filepath.Join(binDir, "git"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("PATH", binDir)
+	return reposPath, gitLog
+}
+
+// * PullLFS does nothing if the repository does not use Git LFS
+// * PullLFS executes "git lfs pull" if git-lfs is installed
+// * PullLFS returns the paths of pointer files if git-lfs is not installed
+//   * Files in .git, large files, and other files are not regarded as
+//     pointer files
+func TestPullLFS(t *testing.T) {
+	cfg := testConfig(config.GoGitBackend, false)
+	cfg.Network.RetryAttempts = 1
+	lfsFiles := map[string]string{
+		".gitattributes":       "*.png filter=lfs diff=lfs merge=lfs -text\n",
+		"plugin/lfs.vim":       "\" lfs.vim\n",
+		"doc/screenshot.png":   testLFSPointer,
+		"assets/large.png":     testLFSPointer + strings.Repeat("x", 2048),
+		".git/lfs/pointer.png": testLFSPointer,
+	}
+
+	t.Run("repository does not use Git LFS", func(t *testing.T) {
+		reposPath, gitLog := setUpLFSEnv(t, map[string]string{"doc/screenshot.png": testLFSPointer}, true)
+		placeholders, err := PullLFS(reposPath, cfg)
+		if err != nil || len(placeholders) != 0 {
+			t.Errorf("expected nil but got %v, %v", placeholders, err)
+		}
+		if log := readGitLog(t, gitLog); log != "" {
+			t.Errorf("git command was executed: %s", log)
+		}
+	})
+
+	t.Run("git-lfs is installed", func(t *testing.T) {
+		reposPath, gitLog := setUpLFSEnv(t, lfsFiles, true)
+		placeholders, err := PullLFS(reposPath, cfg)
+		if err != nil || len(placeholders) != 0 {
+			t.Errorf("expected nil but got %v, %v", placeholders, err)
+		}
+		if !strings.Contains(readGitLog(t, gitLog), "lfs pull") {
+			t.Error("git lfs pull was not executed")
+		}
+	})
+
+	t.Run("git-lfs is not installed", func(t *testing.T) {
+		reposPath, gitLog := setUpLFSEnv(t, lfsFiles, false)
+		placeholders, err := PullLFS(reposPath, cfg)
+		if err != nil {
+			t.Fatal("PullLFS() failed: " + err.Error())
+		}
+		if strings.Join(placeholders, ",") != "doc/screenshot.png" {
+			t.Errorf("expected [doc/screenshot.png] but got %v", placeholders)
+		}
+		if strings.Contains(readGitLog(t, gitLog), "lfs pull") {
+			t.Error("git lfs pull was executed")
+		}
+	})
+}
@@ -0,0 +1,270 @@
+package gitutil
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/netutil"
+	"github.com/vim-volt/volt/pathutil"
+	git "gopkg.in/src-d/go-git.v4"
+	gitconfig "gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+var errRefNotFound = errors.New("ref is not found")
+
+var rxCommitHash = regexp.MustCompile(`^[0-9a-f]{4,40}$`)
+
+// resolvedRef is a commit which ref points to.
+type resolvedRef struct {
+	hash plumbing.Hash
+	// branch is the branch name if ref is a branch
+	branch string
+}
+
+// Checkout checks out ref of reposPath.
+// ref is a tag, a branch, or a commit hash (which can be abbreviated).
+// If ref is not found in the local repository, all history, branches, and
+// tags are fetched from upstream remote.
+//
+// If ref is a branch, the local branch of the same name is checked out, and
+// its upstream is set to the remote branch. Then "volt get -u" follows
+// the branch.
+// Otherwise, the commit is checked out as detached HEAD, and branches are not
+// changed. Then "volt get -u" does not upgrade the repository (see
+// IsDetachedHEAD).
+// Submodules are not updated, so UpdateSubmodules must be called after that.
+func Checkout(reposPath pathutil.ReposPath, ref string, cfg *config.Config) error {
+	fullpath := reposPath.FullPath()
+	r, err := git.PlainOpen(fullpath)
+	if err != nil {
+		return err
+	}
+	reposCfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	cfg = backendConfig(reposCfg, cfg)
+	if reposCfg.Core.IsBare {
+		return errors.New("cannot check out ref in bare repository")
+	}
+	remote, err := GetUpstreamRemote(r)
+	if err != nil {
+		return err
+	}
+
+	target, err := resolveRef(r, remote, ref)
+	if err == errRefNotFound {
+		logger.Debugf("'%s' is not found in %s, fetching all refs ...", ref, reposPath)
+		if err = FetchAllRefs(reposPath, cfg); err != nil {
+			return errors.New("could not fetch refs: " + err.Error())
+		}
+		if r, err = git.PlainOpen(fullpath); err != nil {
+			return err
+		}
+		target, err = resolveRef(r, remote, ref)
+	}
+	if err != nil {
+		return fmt.Errorf("'%s': %s", ref, err.Error())
+	}
+
+	if target.branch == "" {
+		return checkoutDetached(r, fullpath, target, cfg)
+	}
+	return checkoutBranch(r, fullpath, remote, target, cfg)
+}
+
+// checkoutDetached checks out target.hash as detached HEAD.
+func checkoutDetached(r *git.Repository, fullpath string, target *resolvedRef, cfg *config.Config) error {
+	if cfg.Git.Backend != config.CLIGitBackend {
+		wt, err := r.Worktree()
+		if err != nil {
+			return err
+		}
+		err = wt.Checkout(&git.CheckoutOptions{
+			Hash:  target.hash,
+			Force: true,
+		})
+		if err == nil || !canFallback(cfg) {
+			return err
+		}
+		logger.Warnf("failed to check out, try to execute \"git checkout --detach %s\" instead...: %s", target.hash, err.Error())
+	}
+
+	_, err := execGit(fullpath, "checkout", "-f", "--detach", target.hash.String())
+	return err
+}
+
+// resolveRef finds a tag, a remote branch, or a commit of ref in this order.
+func resolveRef(r *git.Repository, remote, ref string) (*resolvedRef, error) {
+	// Tag
+	if tagRef, err := r.Reference(plumbing.ReferenceName("refs/tags/"+ref), true); err == nil {
+		hash := tagRef.Hash()
+		// Peel annotated tag
+		if tag, err := r.TagObject(hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				return nil, err
+			}
+			hash = commit.Hash
+		}
+		return &resolvedRef{hash: hash}, nil
+	}
+
+	// Branch
+	if branchRef, err := r.Reference(plumbing.ReferenceName("refs/remotes/"+remote+"/"+ref), true); err == nil {
+		return &resolvedRef{hash: branchRef.Hash(), branch: ref}, nil
+	}
+
+	// Commit hash
+	if !rxCommitHash.MatchString(ref) {
+		return nil, errRefNotFound
+	}
+	if len(ref) == 40 {
+		if _, err := r.CommitObject(plumbing.NewHash(ref)); err != nil {
+			return nil, errRefNotFound
+		}
+		return &resolvedRef{hash: plumbing.NewHash(ref)}, nil
+	}
+	commits, err := r.CommitObjects()
+	if err != nil {
+		return nil, err
+	}
+	var found []plumbing.Hash
+	commits.ForEach(func(c *object.Commit) error {
+		if strings.HasPrefix(c.Hash.String(), ref) {
+			found = append(found, c.Hash)
+		}
+		return nil
+	})
+	switch len(found) {
+	case 0:
+		return nil, errRefNotFound
+	case 1:
+		return &resolvedRef{hash: found[0]}, nil
+	default:
+		return nil, errors.New("ambiguous commit hash")
+	}
+}
+
+// FetchAllRefs fetches all history, branches, and tags from upstream remote of
+// reposPath. Submodules may be removed by go-git backend (see Unshallow), so
+// UpdateSubmodules must be called after that.
+func FetchAllRefs(reposPath pathutil.ReposPath, cfg *config.Config) error {
+	if err := Unshallow(reposPath, cfg); err != nil {
+		return err
+	}
+	fullpath := reposPath.FullPath()
+	r, err := git.PlainOpen(fullpath)
+	if err != nil {
+		return err
+	}
+	remote, err := GetUpstreamRemote(r)
+	if err != nil {
+		return err
+	}
+	branches := fmt.Sprintf("+refs/heads/*:refs/remotes/%s/*", remote)
+	tags := "+refs/tags/*:refs/tags/*"
+	policy := netutil.NewRetryPolicy(cfg)
+
+	// Track all branches of remote, because shallow clone tracks only default
+	// branch (like "git remote set-branches {remote} '*'")
+	reposCfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	cfg = backendConfig(reposCfg, cfg)
+	if remoteCfg, exists := reposCfg.Remotes[remote]; exists {
+		remoteCfg.Fetch = []gitconfig.RefSpec{gitconfig.RefSpec(branches)}
+		if err = r.Storer.SetConfig(reposCfg); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Git.Backend != config.CLIGitBackend {
+		err = policy.Retry("fetch "+reposPath.String(), func() error {
+			return withCredential(remoteURL(reposCfg, remote), func(auth transport.AuthMethod) error {
+				return r.Fetch(&git.FetchOptions{
+					RemoteName: remote,
+					RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(branches), gitconfig.RefSpec(tags)},
+					Auth:       auth,
+				})
+			})
+		})
+		if err == nil || err == git.NoErrAlreadyUpToDate || !canFallback(cfg) {
+			if err == git.NoErrAlreadyUpToDate {
+				return nil
+			}
+			return err
+		}
+		logger.Warnf("failed to fetch, try to execute \"git fetch %s\" instead...: %s", remote, err.Error())
+	}
+
+	return policy.Retry("git fetch "+reposPath.String(), func() error {
+		_, err := execGit(fullpath, "fetch", remote, branches, tags)
+		return err
+	})
+}
+
+// checkoutBranch checks out local branch target.branch at target.hash, and
+// sets its upstream to remote.
+func checkoutBranch(r *git.Repository, fullpath, remote string, target *resolvedRef, cfg *config.Config) error {
+	if cfg.Git.Backend != config.CLIGitBackend {
+		err := checkoutBranchByGoGit(r, remote, target)
+		if err == nil || !canFallback(cfg) {
+			return err
+		}
+		logger.Warnf("failed to check out, try to execute \"git checkout -B %s\" instead...: %s", target.branch, err.Error())
+	}
+
+	_, err := execGit(fullpath, "checkout", "-B", target.branch, target.hash.String())
+	if err != nil {
+		return err
+	}
+	_, err = execGit(fullpath, "branch", "--set-upstream-to", remote+"/"+target.branch)
+	return err
+}
+
+func checkoutBranchByGoGit(r *git.Repository, remote string, target *resolvedRef) error {
+	if err := checkoutLocalBranchByGoGit(r, target.branch, target.hash); err != nil {
+		return err
+	}
+	return SetUpstreamRemote(r, remote)
+}
+
+// checkoutLocalBranchByGoGit checks out local branch at hash
+// (like "git checkout -B {branch} {hash}").
+func checkoutLocalBranchByGoGit(r *git.Repository, branch string, hash plumbing.Hash) error {
+	wt, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	branchRef := plumbing.ReferenceName("refs/heads/" + branch)
+	_, err = r.Reference(branchRef, false)
+	exists := err == nil
+	opts := &git.CheckoutOptions{
+		Branch: branchRef,
+		Force:  true,
+	}
+	if !exists {
+		opts.Hash = hash
+		opts.Create = true
+	}
+	if err = wt.Checkout(opts); err != nil {
+		return err
+	}
+	if exists {
+		// Move existing local branch to hash
+		return wt.Reset(&git.ResetOptions{
+			Commit: hash,
+			Mode:   git.HardReset,
+		})
+	}
+	return nil
+}
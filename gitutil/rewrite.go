@@ -0,0 +1,127 @@
+package gitutil
+
+import (
+	"fmt"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// HistoryRewrittenError is returned by Update when the current commit is not
+// reachable from the upstream branch, because upstream history was rewritten
+// (e.g. force-pushed).
+type HistoryRewrittenError struct {
+	// Local is the current commit hash
+	Local string
+	// Remote is the commit hash of the upstream branch
+	Remote string
+}
+
+func (e *HistoryRewrittenError) Error() string {
+	return fmt.Sprintf("upstream history was rewritten (e.g. force-pushed): "+
+		"current commit %s is not reachable from upstream commit %s", e.Local, e.Remote)
+}
+
+// DivergedError is returned by Update when the current branch has local
+// commits which are not in the upstream branch, and the upstream branch was
+// updated without rewriting its history.
+type DivergedError struct {
+	// Local is the current commit hash
+	Local string
+	// Remote is the commit hash of the upstream branch
+	Remote string
+}
+
+func (e *DivergedError) Error() string {
+	return fmt.Sprintf("current commit %s has local commits which are not in upstream commit %s: "+
+		"merge or rebase them manually", e.Local, e.Remote)
+}
+
+// upstreamRef returns the remote-tracking reference of the upstream branch of
+// the current branch (e.g. "refs/remotes/origin/master").
+func upstreamRef(r *git.Repository, remote string) (plumbing.ReferenceName, error) {
+	cfg, err := r.Config()
+	if err != nil {
+		return "", err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return "", err
+	}
+	branch := refHeadsRx.FindStringSubmatch(head.Name().String())
+	if len(branch) == 0 {
+		return "", fmt.Errorf("HEAD is not matched to refs/heads/...: %s", head.Name())
+	}
+	merge := cfg.Raw.Section("branch").Subsection(branch[1]).Option("merge")
+	upstream := refHeadsRx.FindStringSubmatch(merge)
+	if len(upstream) == 0 {
+		return "", fmt.Errorf("gitconfig 'branch.%s.merge' is not matched to refs/heads/...: %s", branch[1], merge)
+	}
+	return plumbing.ReferenceName("refs/remotes/" + remote + "/" + upstream[1]), nil
+}
+
+// newNonFastForwardError returns the error of the current branch of r which
+// cannot be fast-forwarded to the upstream branch.
+// oldUpstream is the commit of the upstream branch before fetching (zero hash
+// if it was unknown). If the new upstream commit descends from oldUpstream,
+// the upstream history was not rewritten, so *DivergedError is returned.
+// Otherwise *HistoryRewrittenError is returned.
+// If the commits could not be read, nil is returned.
+// The upstream branch must be fetched before calling this.
+func newNonFastForwardError(r *git.Repository, remote string, oldUpstream plumbing.Hash) error {
+	local, err := GetHEADRepository(r)
+	if err != nil {
+		return nil
+	}
+	ref, err := upstreamReference(r, remote)
+	if err != nil {
+		return nil
+	}
+	if !oldUpstream.IsZero() && isAncestor(r, oldUpstream, ref.Hash()) {
+		return &DivergedError{Local: local, Remote: ref.Hash().String()}
+	}
+	return &HistoryRewrittenError{Local: local, Remote: ref.Hash().String()}
+}
+
+// isAncestor returns true if ancestor is reachable from descendant.
+// Commits which are not fetched (e.g. beyond the depth of shallow
+// repository) are regarded as unreachable.
+func isAncestor(r *git.Repository, ancestor, descendant plumbing.Hash) bool {
+	if ancestor == descendant {
+		return true
+	}
+	commit, err := r.CommitObject(descendant)
+	if err != nil {
+		return false
+	}
+	found := false
+	object.NewCommitPreorderIter(commit, nil).ForEach(func(c *object.Commit) error {
+		if c.Hash == ancestor {
+			found = true
+			return errStopIteration
+		}
+		return nil
+	})
+	return found
+}
+
+// upstreamReference returns the reference of the upstream branch of the
+// current branch of r.
+func upstreamReference(r *git.Repository, remote string) (*plumbing.Reference, error) {
+	refName, err := upstreamRef(r, remote)
+	if err != nil {
+		return nil, err
+	}
+	return r.Reference(refName, true)
+}
+
+// upstreamHash returns the commit of the upstream branch of the current
+// branch of r, or zero hash if it is not found.
+func upstreamHash(r *git.Repository, remote string) plumbing.Hash {
+	ref, err := upstreamReference(r, remote)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	return ref.Hash()
+}
@@ -0,0 +1,71 @@
+package gitutil
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vim-volt/volt/pathutil"
+	git "gopkg.in/src-d/go-git.v4"
+)
+
+// SetSparseCheckout restricts the files in the worktree of reposPath to
+// patterns (the syntax is same as .gitignore, see "git help read-tree").
+// If patterns is empty, sparse checkout is disabled and all files are
+// checked out.
+//
+// git command is required because go-git does not support sparse checkout.
+// go-git also ignores the patterns when it updates the worktree, so this must
+// be called again after that.
+// Bare repositories are not changed because they have no worktree.
+func SetSparseCheckout(reposPath pathutil.ReposPath, patterns []string) error {
+	fullpath := reposPath.FullPath()
+	r, err := git.PlainOpen(fullpath)
+	if err != nil {
+		return err
+	}
+	reposCfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	if reposCfg.Core.IsBare {
+		return nil
+	}
+	enabled := reposCfg.Raw.Section("core").Option("sparseCheckout") == "true"
+	if len(patterns) == 0 && !enabled {
+		return nil
+	}
+	if !HasGitCmd() {
+		return errors.New("git command is required for sparse checkout")
+	}
+
+	sparseFile := filepath.Join(fullpath, ".git", "info", "sparse-checkout")
+	content := strings.Join(patterns, "\n") + "\n"
+	if len(patterns) == 0 {
+		content = "/*\n"
+	}
+	if err = os.MkdirAll(filepath.Dir(sparseFile), 0755); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(sparseFile, []byte(content), 0644); err != nil {
+		return err
+	}
+	if _, err = execGit(fullpath, "config", "core.sparseCheckout", "true"); err != nil {
+		return err
+	}
+	// Update the worktree by the patterns
+	if _, err = execGit(fullpath, "read-tree", "-mu", "HEAD"); err != nil {
+		return err
+	}
+	if len(patterns) > 0 {
+		return nil
+	}
+
+	// Disable sparse checkout after all files were checked out
+	if _, err = execGit(fullpath, "config", "--unset", "core.sparseCheckout"); err != nil {
+		return err
+	}
+	return os.Remove(sparseFile)
+}
@@ -0,0 +1,185 @@
+package gitutil
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/netutil"
+	"github.com/vim-volt/volt/pathutil"
+	git "gopkg.in/src-d/go-git.v4"
+)
+
+// UpdateSubmodules initializes and updates submodules of reposPath to the
+// commits recorded in the current commit (like "git submodule update --init").
+// mode is one of the followings ("" is same as lockjson.SubmodulesRecursive):
+//
+//   - lockjson.SubmodulesNone: Submodules are not changed.
+//   - lockjson.SubmodulesShallow: Only top-level submodules are updated, and
+//     their history is truncated to depth 1.
+//   - lockjson.SubmodulesRecursive: All nested submodules are updated, and
+//     their history is truncated by "git.clone_depth" in config.toml
+//     (go-git backend fetches all history).
+//
+// Bare repositories are not changed because they have no worktree.
+func UpdateSubmodules(reposPath pathutil.ReposPath, mode string, cfg *config.Config) error {
+	if mode == lockjson.SubmodulesNone {
+		return nil
+	}
+	fullpath := reposPath.FullPath()
+	r, err := git.PlainOpen(fullpath)
+	if err != nil {
+		return err
+	}
+	reposCfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	cfg = backendConfig(reposCfg, cfg)
+	if reposCfg.Core.IsBare || !pathutil.Exists(filepath.Join(fullpath, ".gitmodules")) {
+		return nil
+	}
+	recursive := mode != lockjson.SubmodulesShallow
+	policy := netutil.NewRetryPolicy(cfg)
+
+	if cfg.Git.Backend != config.CLIGitBackend {
+		update := func(noFetch bool) error {
+			wt, err := r.Worktree()
+			if err != nil {
+				return err
+			}
+			subs, err := wt.Submodules()
+			if err != nil {
+				return err
+			}
+			opts := &git.SubmoduleUpdateOptions{Init: true, NoFetch: noFetch}
+			if recursive {
+				opts.RecurseSubmodules = git.DefaultSubmoduleRecursionDepth
+			}
+			return subs.Update(opts)
+		}
+		// go-git always fetches submodules unless NoFetch is specified.
+		// Try without fetching at first because the commits often exist.
+		err = update(true)
+		if err != nil {
+			err = policy.Retry("update submodules of "+reposPath.String(), func() error {
+				return update(false)
+			})
+		}
+		if err == nil || !canFallback(cfg) {
+			return err
+		}
+		logger.Warnf("failed to update submodules, try to execute \"git submodule update --init\" instead...: %s", err.Error())
+	}
+
+	args := []string{"submodule", "update", "--init"}
+	if recursive {
+		args = append(args, "--recursive")
+		if depth := *cfg.Git.CloneDepth; depth > 0 {
+			args = append(args, "--depth", strconv.Itoa(depth))
+		}
+	} else {
+		args = append(args, "--depth", "1")
+	}
+	return policy.Retry("git submodule update "+reposPath.String(), func() error {
+		_, err := execGit(fullpath, args...)
+		return err
+	})
+}
+
+// SubmoduleVersions returns the map of the path and the checked out commit
+// hash of submodules in reposPath. The path is relative to reposPath, and
+// separated by "/".
+// Nested submodules are also included if mode is lockjson.SubmodulesRecursive
+// (or ""). Submodules which are not initialized are not included.
+func SubmoduleVersions(reposPath pathutil.ReposPath, mode string, cfg *config.Config) (map[string]string, error) {
+	if mode == lockjson.SubmodulesNone {
+		return nil, nil
+	}
+	fullpath := reposPath.FullPath()
+	r, err := git.PlainOpen(fullpath)
+	if err != nil {
+		return nil, err
+	}
+	reposCfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+	cfg = backendConfig(reposCfg, cfg)
+	if reposCfg.Core.IsBare || !pathutil.Exists(filepath.Join(fullpath, ".gitmodules")) {
+		return nil, nil
+	}
+	recursive := mode != lockjson.SubmodulesShallow
+
+	if cfg.Git.Backend != config.CLIGitBackend {
+		versions := make(map[string]string)
+		err = collectSubmoduleVersions(r, "", recursive, versions)
+		if err == nil || !canFallback(cfg) {
+			return versions, err
+		}
+		logger.Warnf("failed to get submodule status, try to execute \"git submodule status\" instead...: %s", err.Error())
+	}
+
+	args := []string{"submodule", "status"}
+	if recursive {
+		args = append(args, "--recursive")
+	}
+	out, err := execGit(fullpath, args...)
+	if err != nil {
+		return nil, err
+	}
+	// Each line is "{status}{hash} {path}" or "{status}{hash} {path} ({describe})"
+	// where {status} is " ", "-" (not initialized), "+", or "U"
+	versions := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '-' {
+			continue
+		}
+		fields := strings.Fields(line[1:])
+		if len(fields) >= 2 {
+			versions[fields[1]] = fields[0]
+		}
+	}
+	return versions, scanner.Err()
+}
+
+func collectSubmoduleVersions(r *git.Repository, prefix string, recursive bool, versions map[string]string) error {
+	wt, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	subs, err := wt.Submodules()
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		status, err := sub.Status()
+		if err != nil {
+			return err
+		}
+		if status.Current.IsZero() {
+			continue
+		}
+		path := prefix + sub.Config().Path
+		versions[path] = status.Current.String()
+		if !recursive {
+			continue
+		}
+		subRepos, err := sub.Repository()
+		if err != nil {
+			return err
+		}
+		err = collectSubmoduleVersions(subRepos, path+"/", recursive, versions)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package gitutil
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/vim-volt/volt/pathutil"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+const pgpSignatureBegin = "-----BEGIN PGP SIGNATURE-----"
+
+// VerifyHEAD verifies that HEAD commit of reposPath (see GetHEADRepository),
+// or an annotated tag which points to the commit, is signed by a key in
+// $VOLTPATH/trusted_keys.asc.
+func VerifyHEAD(reposPath pathutil.ReposPath) error {
+	keyring, err := readTrustedKeys()
+	if err != nil {
+		return err
+	}
+	r, err := git.PlainOpen(reposPath.FullPath())
+	if err != nil {
+		return err
+	}
+	head, err := GetHEADRepository(r)
+	if err != nil {
+		return err
+	}
+	hash := plumbing.NewHash(head)
+
+	commitErr := verifyCommit(r, hash, keyring)
+	if commitErr == nil {
+		return nil
+	}
+	if verifyTags(r, hash, keyring) == nil {
+		return nil
+	}
+	return errors.New("commit " + head + ": " + commitErr.Error())
+}
+
+// readTrustedKeys reads all armored public keys in trusted_keys.asc.
+func readTrustedKeys() (openpgp.EntityList, error) {
+	path := pathutil.TrustedKeys()
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New("could not read trusted keys: " + err.Error())
+	}
+	defer f.Close()
+
+	// armor.Decode() reads ahead with bufio.Reader, which is reused if given
+	// reader is bufio.Reader. So the following blocks are not lost.
+	in := bufio.NewReader(f)
+	var keyring openpgp.EntityList
+	for {
+		block, err := armor.Decode(in)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("could not parse " + path + ": " + err.Error())
+		}
+		entities, err := openpgp.ReadKeyRing(block.Body)
+		if err != nil {
+			return nil, errors.New("could not parse " + path + ": " + err.Error())
+		}
+		keyring = append(keyring, entities...)
+	}
+	if len(keyring) == 0 {
+		return nil, errors.New("no keys are found in " + path)
+	}
+	return keyring, nil
+}
+
+func verifyCommit(r *git.Repository, hash plumbing.Hash, keyring openpgp.EntityList) error {
+	content, err := readRawObject(r, plumbing.CommitObject, hash)
+	if err != nil {
+		return err
+	}
+	payload, signature := splitCommitSignature(content)
+	if signature == "" {
+		return errors.New("not signed")
+	}
+	return checkSignature(keyring, payload, signature)
+}
+
+// verifyTags returns nil if one of annotated tags which point to hash is
+// signed by a trusted key.
+func verifyTags(r *git.Repository, hash plumbing.Hash, keyring openpgp.EntityList) error {
+	tags, err := r.Tags()
+	if err != nil {
+		return err
+	}
+	verified := errors.New("no signed tags")
+	tags.ForEach(func(ref *plumbing.Reference) error {
+		tag, err := r.TagObject(ref.Hash())
+		if err != nil || tag.Target != hash || tag.TargetType != plumbing.CommitObject {
+			return nil // lightweight tag or the tag of other commit
+		}
+		content, err := readRawObject(r, plumbing.TagObject, tag.Hash)
+		if err != nil {
+			return nil
+		}
+		idx := bytes.Index(content, []byte(pgpSignatureBegin))
+		if idx < 0 {
+			return nil
+		}
+		if checkSignature(keyring, content[:idx], string(content[idx:])) == nil {
+			verified = nil
+			return errStopIteration
+		}
+		return nil
+	})
+	return verified
+}
+
+var errStopIteration = errors.New("stop iteration")
+
+func readRawObject(r *git.Repository, t plumbing.ObjectType, hash plumbing.Hash) ([]byte, error) {
+	obj, err := r.Storer.EncodedObject(t, hash)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := obj.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// splitCommitSignature splits raw commit object into the signed payload
+// (the commit without "gpgsig" header) and the armored signature.
+func splitCommitSignature(content []byte) ([]byte, string) {
+	var payload bytes.Buffer
+	var signature []string
+	inHeader := true
+	inSignature := false
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		switch {
+		case inHeader && line == "\n":
+			inHeader = false
+			inSignature = false
+		case inHeader && strings.HasPrefix(line, "gpgsig "):
+			inSignature = true
+			signature = append(signature, strings.TrimPrefix(line, "gpgsig "))
+			continue
+		case inSignature && strings.HasPrefix(line, " "):
+			signature = append(signature, strings.TrimPrefix(line, " "))
+			continue
+		default:
+			inSignature = false
+		}
+		payload.WriteString(line)
+	}
+	return payload.Bytes(), strings.Join(signature, "")
+}
+
+func checkSignature(keyring openpgp.EntityList, payload []byte, signature string) error {
+	_, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(payload), strings.NewReader(signature))
+	if err != nil {
+		return errors.New("signature is not verified by trusted keys: " + err.Error())
+	}
+	return nil
+}
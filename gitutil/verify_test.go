@@ -0,0 +1,208 @@
+package gitutil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/pathutil"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+const testSignature = "volt <volt@example.com> 1500000000 +0000"
+
+// setUpVerifyEnv sets $VOLTPATH to a temporary directory, and returns the
+// path of a new repository under it.
+func setUpVerifyEnv(t *testing.T) (pathutil.ReposPath, *git.Repository) {
+	t.Helper()
+	tempDir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, exists := os.LookupEnv("VOLTPATH")
+	t.Cleanup(func() {
+		if exists {
+			os.Setenv("VOLTPATH", old)
+		} else {
+			os.Unsetenv("VOLTPATH")
+		}
+		os.RemoveAll(tempDir)
+	})
+	os.Setenv("VOLTPATH", tempDir)
+
+	reposPath := pathutil.ReposPath("localhost/test/signed")
+	r, err := git.PlainInit(reposPath.FullPath(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reposPath, r
+}
+
+func newTestEntity(t *testing.T, name string) *openpgp.Entity {
+	t.Helper()
+	entity, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sign the identity and the subkey, which is needed by Serialize()
+	if err := entity.SerializePrivate(ioutil.Discard, nil); err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
+
+func writeTrustedKeys(t *testing.T, entities ...*openpgp.Entity) {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, entity := range entities {
+		w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := entity.Serialize(w); err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+		buf.WriteString("\n")
+	}
+	if err := ioutil.WriteFile(pathutil.TrustedKeys(), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func armoredSignature(t *testing.T, entity *openpgp.Entity, payload string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&buf, entity, strings.NewReader(payload), nil); err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(buf.String()) + "\n"
+}
+
+func storeObject(t *testing.T, r *git.Repository, typ plumbing.ObjectType, content string) plumbing.Hash {
+	t.Helper()
+	obj := r.Storer.NewEncodedObject()
+	obj.SetType(typ)
+	w, err := obj.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(content))
+	w.Close()
+	hash, err := r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// commitHEAD creates a commit which is signed by signer (if it is not nil),
+// and sets it to HEAD.
+func commitHEAD(t *testing.T, r *git.Repository, signer *openpgp.Entity) plumbing.Hash {
+	t.Helper()
+	tree := storeObject(t, r, plumbing.TreeObject, "")
+	header := "tree " + tree.String() + "\nauthor " + testSignature + "\ncommitter " + testSignature + "\n"
+	message := "\ncommit\n"
+	content := header + message
+	if signer != nil {
+		signature := armoredSignature(t, signer, content)
+		lines := strings.SplitAfter(signature, "\n")
+		gpgsig := "gpgsig " + lines[0] + " " + strings.Join(lines[1:len(lines)-1], " ")
+		content = header + gpgsig + message
+	}
+	hash := storeObject(t, r, plumbing.CommitObject, content)
+	ref := plumbing.NewHashReference(plumbing.ReferenceName("refs/heads/master"), hash)
+	if err := r.Storer.SetReference(ref); err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// tagCommit creates an annotated tag of commit which is signed by signer.
+func tagCommit(t *testing.T, r *git.Repository, commit plumbing.Hash, signer *openpgp.Entity) {
+	t.Helper()
+	content := "object " + commit.String() + "\ntype commit\ntag v1.0.0\ntagger " + testSignature + "\n\nv1.0.0\n"
+	content += armoredSignature(t, signer, content)
+	hash := storeObject(t, r, plumbing.TagObject, content)
+	ref := plumbing.NewHashReference(plumbing.ReferenceName("refs/tags/v1.0.0"), hash)
+	if err := r.Storer.SetReference(ref); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// * VerifyHEAD succeeds if HEAD commit is signed by a trusted key
+// * VerifyHEAD fails if HEAD commit is signed by an untrusted key
+// * VerifyHEAD fails if HEAD commit is not signed
+// * VerifyHEAD succeeds if HEAD commit is not signed but an annotated tag of
+//   the commit is signed by a trusted key
+// * VerifyHEAD fails if trusted_keys.asc does not exist
+func TestVerifyHEAD(t *testing.T) {
+	trusted := newTestEntity(t, "trusted")
+	untrusted := newTestEntity(t, "untrusted")
+
+	t.Run("signed commit", func(t *testing.T) {
+		reposPath, r := setUpVerifyEnv(t)
+		writeTrustedKeys(t, trusted)
+		commitHEAD(t, r, trusted)
+		if err := VerifyHEAD(reposPath); err != nil {
+			t.Errorf("expected nil but got %s", err.Error())
+		}
+	})
+
+	t.Run("commit signed by untrusted key", func(t *testing.T) {
+		reposPath, r := setUpVerifyEnv(t)
+		writeTrustedKeys(t, trusted)
+		commitHEAD(t, r, untrusted)
+		if err := VerifyHEAD(reposPath); err == nil {
+			t.Error("expected an error but got nil")
+		}
+	})
+
+	t.Run("unsigned commit", func(t *testing.T) {
+		reposPath, r := setUpVerifyEnv(t)
+		writeTrustedKeys(t, trusted)
+		commitHEAD(t, r, nil)
+		err := VerifyHEAD(reposPath)
+		if err == nil || !strings.Contains(err.Error(), "not signed") {
+			t.Errorf("expected \"not signed\" error but got %v", err)
+		}
+	})
+
+	t.Run("unsigned commit with signed tag", func(t *testing.T) {
+		reposPath, r := setUpVerifyEnv(t)
+		writeTrustedKeys(t, untrusted, trusted)
+		commit := commitHEAD(t, r, nil)
+		tagCommit(t, r, commit, trusted)
+		if err := VerifyHEAD(reposPath); err != nil {
+			t.Errorf("expected nil but got %s", err.Error())
+		}
+	})
+
+	t.Run("unsigned commit with tag signed by untrusted key", func(t *testing.T) {
+		reposPath, r := setUpVerifyEnv(t)
+		writeTrustedKeys(t, trusted)
+		commit := commitHEAD(t, r, nil)
+		tagCommit(t, r, commit, untrusted)
+		if err := VerifyHEAD(reposPath); err == nil {
+			t.Error("expected an error but got nil")
+		}
+	})
+
+	t.Run("trusted_keys.asc does not exist", func(t *testing.T) {
+		reposPath, r := setUpVerifyEnv(t)
+		commitHEAD(t, r, trusted)
+		err := VerifyHEAD(reposPath)
+		if err == nil || !strings.Contains(err.Error(), "trusted keys") {
+			t.Errorf("expected \"trusted keys\" error but got %v", err)
+		}
+		if pathutil.Exists(filepath.Join(pathutil.VoltPath(), "trusted_keys.asc")) {
+			t.Error("trusted_keys.asc was created")
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package httputil
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/timing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+)
+
+// httpClient is used by all HTTP requests of volt and go-git. The
+// connections are kept alive and reused.
+var httpClient = newClient(30*time.Second, 0)
+
+// newClient returns the HTTP client. timeout is the limit of each phase
+// (connecting, TLS handshake, and waiting for the response headers), and
+// requestTimeout is the limit of a whole request (0 means no limit).
+func newClient(timeout, requestTimeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	return &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   config.DefaultJobsPerHost * 2,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+// SetUpClient sets up the HTTP client of volt and go-git with
+// "network.connect_timeout" and "network.request_timeout" of config.toml, and the
+// token of GitHub API.
+// This must be called after SetUpProxy().
+func SetUpClient(cfg *config.Config) {
+	// Timeouts are already validated by config.Read()
+	timeout, _ := time.ParseDuration(cfg.Network.ConnectTimeout)
+	requestTimeout, _ := time.ParseDuration(cfg.Network.RequestTimeout)
+	httpClient = newClient(timeout, requestTimeout)
+	setUpGitHubToken(cfg)
+	client.InstallProtocol("https", githttp.NewClient(httpClient))
+	client.InstallProtocol("http", githttp.NewClient(httpClient))
+}
+
+// cacheMeta is the validators of a cached response, which are sent in
+// conditional requests.
+type cacheMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// cachePath returns the paths of the body and the validators of the cached
+// response of url.
+func cachePath(url string) (string, string) {
+	sum := sha1.Sum([]byte(url))
+	base := filepath.Join(pathutil.HTTPCacheDir(), hex.EncodeToString(sum[:]))
+	return base + ".body", base + ".json"
+}
+
+// readCache returns the cached response of url, or nil if it does not exist.
+func readCache(url string) (*cacheMeta, []byte) {
+	bodyPath, metaPath := cachePath(url)
+	content, err := ioutil.ReadFile(metaPath)
+	if err != nil {
+		return nil, nil
+	}
+	var meta cacheMeta
+	if json.Unmarshal(content, &meta) != nil || meta.URL != url {
+		return nil, nil
+	}
+	body, err := ioutil.ReadFile(bodyPath)
+	if err != nil {
+		return nil, nil
+	}
+	return &meta, body
+}
+
+// writeCache saves the response of url if it has validators.
+// The errors are ignored because the cache is optional.
+func writeCache(url string, header http.Header, body []byte) {
+	meta := &cacheMeta{
+		URL:          url,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+	}
+	if meta.ETag == "" && meta.LastModified == "" {
+		return
+	}
+	content, err := json.Marshal(meta)
+	if err != nil {
+		return
+	}
+	bodyPath, metaPath := cachePath(url)
+	if os.MkdirAll(filepath.Dir(bodyPath), 0755) != nil {
+		return
+	}
+	if ioutil.WriteFile(bodyPath, body, 0644) == nil {
+		ioutil.WriteFile(metaPath, content, 0644)
+	}
+}
+
+// getConditional fetches url with the validators of the cached response
+// (If-None-Match and If-Modified-Since), and returns the cached content if
+// the server returned "304 Not Modified".
+func getConditional(url string) ([]byte, error) {
+	defer timing.Start(timing.Network)()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	meta, cached := readCache(url)
+	if meta != nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+	// http.Client allows up to 10 redirects
+	res, err := doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotModified && meta != nil {
+		return cached, nil
+	}
+	if res.StatusCode/100 != 2 {
+		return nil, &StatusError{URL: url, Status: res.Status, StatusCode: res.StatusCode}
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	writeCache(url, res.Header, body)
+	return body, nil
+}
@@ -0,0 +1,46 @@
+package httputil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestGetConditional(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldVoltPath := os.Getenv("VOLTPATH")
+	os.Setenv("VOLTPATH", dir)
+	defer os.Setenv("VOLTPATH", oldVoltPath)
+
+	requests, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		content, err := getConditional(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "content" {
+			t.Errorf("expected %q but got %q", "content", content)
+		}
+	}
+	if requests != 2 || notModified != 1 {
+		t.Errorf("expected the second request is conditional (requests=%d, notModified=%d)", requests, notModified)
+	}
+}
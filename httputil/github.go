@@ -0,0 +1,133 @@
+package httputil
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/logger"
+)
+
+// githubAPIHost is the host of GitHub REST API. The token and the rate limit
+// are applied only to the requests to this host.
+var githubAPIHost = "api.github.com"
+
+// githubMaxWait is the longest time to wait for the reset of the rate limit
+// of GitHub API. If it is reset later, the request fails immediately.
+const githubMaxWait = time.Minute
+
+// githubToken is the token sent to GitHub API, or "" (unauthenticated
+// requests have much lower rate limit).
+var githubToken string
+
+// setUpGitHubToken reads the token of GitHub API from "network.github_token"
+// of config.toml, or $GITHUB_TOKEN.
+func setUpGitHubToken(cfg *config.Config) {
+	githubToken = cfg.Network.GitHubToken
+	if githubToken == "" {
+		githubToken = os.Getenv("GITHUB_TOKEN")
+	}
+}
+
+// RateLimitError is returned when the rate limit of GitHub API is exceeded.
+type RateLimitError struct {
+	URL   string
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	msg := "GitHub API rate limit exceeded (reset at " + e.Reset.Format("15:04:05") + "): " + e.URL
+	if githubToken == "" {
+		msg += "\nSet network.github_token in config.toml or GITHUB_TOKEN environment variable to raise the limit"
+	}
+	return msg
+}
+
+// Temporary returns true if the rate limit is reset soon, then the request
+// is retried after waiting for it.
+func (e *RateLimitError) Temporary() bool {
+	return time.Until(e.Reset) <= githubMaxWait
+}
+
+// githubRateLimit is the rate limit reported by the last response of GitHub
+// API ("X-RateLimit-Remaining" and "X-RateLimit-Reset" headers).
+var githubRateLimit struct {
+	sync.Mutex
+	known     bool
+	remaining int
+	reset     time.Time
+}
+
+// doRequest sends req. The requests to GitHub API are authenticated if a
+// token is configured, and wait for the reset of the rate limit (or fail)
+// when no request remains instead of getting "403 Forbidden".
+func doRequest(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != githubAPIHost {
+		return httpClient.Do(req)
+	}
+	if err := waitGitHubRateLimit(req.URL.String()); err != nil {
+		return nil, err
+	}
+	if githubToken != "" {
+		req.Header.Set("Authorization", "token "+githubToken)
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = recordGitHubRateLimit(req.URL.String(), res); err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	return res, nil
+}
+
+func waitGitHubRateLimit(url string) error {
+	githubRateLimit.Lock()
+	known, remaining, reset := githubRateLimit.known, githubRateLimit.remaining, githubRateLimit.reset
+	githubRateLimit.Unlock()
+	if !known || remaining > 0 {
+		return nil
+	}
+	wait := time.Until(reset)
+	if wait <= 0 {
+		return nil
+	}
+	if wait > githubMaxWait {
+		return &RateLimitError{URL: url, Reset: reset}
+	}
+	logger.Warnf("GitHub API rate limit exceeded, waiting %s for the reset ...", wait.Round(time.Second))
+	time.Sleep(wait)
+	return nil
+}
+
+// recordGitHubRateLimit saves the rate limit in the headers of res, and
+// returns RateLimitError if res is the error of the rate limit (including
+// secondary rate limits with "Retry-After" header).
+func recordGitHubRateLimit(url string, res *http.Response) error {
+	remaining, errRemaining := strconv.Atoi(res.Header.Get("X-RateLimit-Remaining"))
+	resetUnix, errReset := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64)
+	retryAfter, errRetryAfter := strconv.Atoi(res.Header.Get("Retry-After"))
+	limited := res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusTooManyRequests
+
+	githubRateLimit.Lock()
+	defer githubRateLimit.Unlock()
+	if errRemaining == nil && errReset == nil {
+		githubRateLimit.known = true
+		githubRateLimit.remaining = remaining
+		githubRateLimit.reset = time.Unix(resetUnix, 0)
+	}
+	switch {
+	case limited && errRetryAfter == nil:
+		githubRateLimit.known = true
+		githubRateLimit.remaining = 0
+		githubRateLimit.reset = time.Now().Add(time.Duration(retryAfter) * time.Second)
+	case limited && errRemaining == nil && remaining == 0:
+	default:
+		return nil
+	}
+	return &RateLimitError{URL: url, Reset: githubRateLimit.reset}
+}
@@ -0,0 +1,90 @@
+package httputil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func setUpGitHubServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, func()) {
+	dir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldVoltPath := os.Getenv("VOLTPATH")
+	os.Setenv("VOLTPATH", dir)
+	server := httptest.NewServer(handler)
+	u, _ := url.Parse(server.URL)
+	oldHost, oldToken := githubAPIHost, githubToken
+	githubAPIHost = u.Host
+	return server, func() {
+		server.Close()
+		githubAPIHost, githubToken = oldHost, oldToken
+		githubRateLimit.known = false
+		os.Setenv("VOLTPATH", oldVoltPath)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestGitHubRateLimit(t *testing.T) {
+	var requests int32
+	server, tearDown := setUpGitHubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("Authorization") != "token secret" {
+			t.Errorf("expected the token is sent but got %q", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.Write([]byte("content"))
+	})
+	defer tearDown()
+	githubToken = "secret"
+
+	if _, err := getConditional(server.URL + "/a"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := getConditional(server.URL + "/b")
+	if _, ok := err.(*RateLimitError); !ok {
+		t.Errorf("expected RateLimitError but got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("expected the request is not sent after the limit was exceeded, but %d requests were sent", requests)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	var requests int32
+	server, tearDown := setUpGitHubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("content"))
+	})
+	defer tearDown()
+
+	u := server.URL + "/coalesced"
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content, err := GetContent(u)
+			if err != nil || string(content) != "content" {
+				t.Errorf("expected %q but got %q, %v", "content", content, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := GetContent(u); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request but %d requests were sent", requests)
+	}
+}
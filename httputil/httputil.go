@@ -1,34 +1,130 @@
 package httputil
 
 import (
-	"errors"
 	"io"
-	"io/ioutil"
 	"net/http"
+	"sync"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/netutil"
+	"github.com/vim-volt/volt/timing"
 )
 
+// StatusError is returned when the server returned non-successful status.
+type StatusError struct {
+	URL        string
+	Status     string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return e.URL + " returned non-successful status: " + e.Status
+}
+
+// Temporary returns true if the request may succeed when it is retried.
+func (e *StatusError) Temporary() bool {
+	return netutil.IsTransientStatus(e.StatusCode)
+}
+
 // GetContentReader fetches url and returns io.ReadCloser.
 // Caller must close the reader.
+// The request is retried on transient errors (see netutil.RetryPolicy).
 func GetContentReader(url string) (io.ReadCloser, error) {
-	// http.Get() allows up to 10 redirects
-	res, err := http.Get(url)
+	cfg, err := config.Read()
+	if err != nil {
+		return nil, err
+	}
+	var body io.ReadCloser
+	err = netutil.NewRetryPolicy(cfg).Retry("GET "+url, func() error {
+		var err error
+		body, err = get(url)
+		return err
+	})
+	return body, err
+}
+
+func get(url string) (io.ReadCloser, error) {
+	defer timing.Start(timing.Network)()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// http.Client allows up to 10 redirects
+	res, err := doRequest(req)
 	if err != nil {
 		return nil, err
 	}
 	if res.StatusCode/100 != 2 {
-		return nil, errors.New(url + " returned non-successful status: " + res.Status)
+		res.Body.Close()
+		return nil, &StatusError{URL: url, Status: res.Status, StatusCode: res.StatusCode}
 	}
 	return res.Body, nil
 }
 
 // GetContent fetches url and returns []byte.
+// The response is cached in $VOLTPATH/cache/http, and the next request is
+// sent as a conditional request (ETag / Last-Modified), so the content is
+// not downloaded again if it is not changed.
+// The requests of the same URL in one command run are coalesced: concurrent
+// callers share one request, and later callers reuse the successful
+// response. Callers must not modify the returned content.
 func GetContent(url string) ([]byte, error) {
-	r, err := GetContentReader(url)
-	if err != nil {
-		return nil, err
+	return coalesce(url, func() ([]byte, error) {
+		cfg, err := config.Read()
+		if err != nil {
+			return nil, err
+		}
+		var content []byte
+		err = netutil.NewRetryPolicy(cfg).Retry("GET "+url, func() error {
+			var err error
+			content, err = getConditional(url)
+			return err
+		})
+		return content, err
+	})
+}
+
+// contentCall is a request of GetContent which is running or succeeded.
+type contentCall struct {
+	done    chan struct{}
+	content []byte
+	err     error
+}
+
+var contentCalls = struct {
+	sync.Mutex
+	m map[string]*contentCall
+}{m: make(map[string]*contentCall)}
+
+// coalesce calls fetch once for concurrent calls of the same url, and
+// returns the saved content if it already succeeded. Failed calls are not
+// saved, so the next call sends the request again.
+func coalesce(url string, fetch func() ([]byte, error)) ([]byte, error) {
+	contentCalls.Lock()
+	if c, exists := contentCalls.m[url]; exists {
+		contentCalls.Unlock()
+		<-c.done
+		return c.content, c.err
+	}
+	c := &contentCall{done: make(chan struct{})}
+	contentCalls.m[url] = c
+	contentCalls.Unlock()
+
+	c.content, c.err = fetch()
+	if c.err != nil {
+		contentCalls.Lock()
+		delete(contentCalls.m, url)
+		contentCalls.Unlock()
 	}
-	defer r.Close()
-	return ioutil.ReadAll(r)
+	close(c.done)
+	return c.content, c.err
+}
+
+// TryGetContent fetches url and returns []byte like GetContent, but the
+// request is not retried. It is used when the caller has a fallback for the
+// failure (e.g. offline).
+func TryGetContent(url string) ([]byte, error) {
+	return getConditional(url)
 }
 
 // GetContentString fetches url and returns string.
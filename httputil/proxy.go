@@ -0,0 +1,29 @@
+package httputil
+
+import (
+	"os"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/logger"
+)
+
+// SetUpProxy sets proxy environment variables from "network.proxy" and
+// "network.no_proxy" of config.toml.
+// The environment variables are honored by HTTP requests of volt, go-git,
+// and spawned git processes. If "network.proxy" is empty, existing
+// HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables are used as is.
+// This must be called before the first HTTP request, because net/http reads
+// the environment variables only once.
+func SetUpProxy(cfg *config.Config) {
+	if cfg.Network.Proxy != "" {
+		logger.Debugf("Using proxy %s", cfg.Network.Proxy)
+		for _, name := range []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy", "ALL_PROXY", "all_proxy"} {
+			os.Setenv(name, cfg.Network.Proxy)
+		}
+	}
+	if cfg.Network.NoProxy != "" {
+		for _, name := range []string{"NO_PROXY", "no_proxy"} {
+			os.Setenv(name, cfg.Network.NoProxy)
+		}
+	}
+}
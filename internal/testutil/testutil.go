@@ -59,11 +59,17 @@ func SetUpEnv(t *testing.T) {
 }
 
 func RunVolt(args ...string) ([]byte, error) {
-	cmd := exec.Command(voltCommand, args...)
+	cmd := VoltCommand(args...)
 	// cmd.Env = append(os.Environ(), "VOLTPATH="+voltpath)
 	return cmd.CombinedOutput()
 }
 
+// VoltCommand returns exec.Cmd of volt command with args, to run it
+// asynchronously (e.g. to send a signal).
+func VoltCommand(args ...string) *exec.Cmd {
+	return exec.Command(voltCommand, args...)
+}
+
 func SuccessExit(t *testing.T, out []byte, err error) {
 	t.Helper()
 	outstr := string(out)
@@ -148,7 +154,17 @@ func SetUpRepos(t *testing.T, testdataName string, rType lockjson.ReposType, rep
 				out, err := RunVolt("get", reposPath.String())
 				SuccessExit(t, out, err)
 			case lockjson.ReposStaticType:
-				err := os.Setenv("VOLTPATH", tmpVoltpath)
+				// Do not build into vim dir of the test
+				home := os.Getenv("HOME")
+				tmpHome, err := ioutil.TempDir("", "volt-test-home-")
+				if err != nil {
+					t.Fatalf("failed to create temp dir: %s", err)
+				}
+				if err := os.Setenv("HOME", tmpHome); err != nil {
+					t.Fatalf("failed to set HOME: %s", err)
+				}
+				defer os.Setenv("HOME", home)
+				err = os.Setenv("VOLTPATH", tmpVoltpath)
 				if err != nil {
 					t.Fatalf("failed to set VOLTPATH: %s", err)
 				}
@@ -225,3 +241,56 @@ func DefaultMatrix(t *testing.T, f func(*testing.T, bool, string)) {
 func AvailableStrategies() []string {
 	return []string{config.SymlinkBuilder, config.CopyBuilder}
 }
+
+// SetUpRemoteRepos creates a git repository which is cloned instead of
+// https://{reposPath} by "volt get", and returns its path.
+// The repository has a commit which adds "plugin/{name}.vim".
+//
+// git command redirects the URL by "url.{dir}.insteadOf" in $HOME/.gitconfig,
+// so config.toml is written to use "cli" git backend if it does not exist.
+func SetUpRemoteRepos(t *testing.T, reposPath pathutil.ReposPath) string {
+	t.Helper()
+	home := os.Getenv("HOME")
+	remote := filepath.Join(home, "remote", reposPath.String())
+	if err := os.MkdirAll(filepath.Join(remote, "plugin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	gitconfig := filepath.Join(home, ".gitconfig")
+	content, _ := ioutil.ReadFile(gitconfig)
+	if !strings.Contains(string(content), "[user]") {
+		content = append(content, "[user]\n\tname = volt\n\temail = volt@example.com\n[protocol \"file\"]\n\tallow = always\n"...)
+	}
+	content = append(content, fmt.Sprintf("[url \"file://%s\"]\n\tinsteadOf = https://%s\n", filepath.ToSlash(remote), reposPath.String())...)
+	if err := ioutil.WriteFile(gitconfig, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if configTOML := pathutil.ConfigTOML(); !pathutil.Exists(configTOML) {
+		os.MkdirAll(filepath.Dir(configTOML), 0755)
+		if err := ioutil.WriteFile(configTOML, []byte("[git]\nbackend = \"cli\"\n\n[network]\nretry_attempts = 1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	name := filepath.Base(reposPath.String())
+	plugin := filepath.Join(remote, "plugin", strings.TrimSuffix(name, ".vim")+".vim")
+	if err := ioutil.WriteFile(plugin, []byte("\" "+name+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	Git(t, remote, "init", "-q")
+	Git(t, remote, "add", "-A")
+	Git(t, remote, "commit", "-q", "-m", "first")
+	return remote
+}
+
+// Git executes git command with args in dir, and returns the trimmed output.
+func Git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %s: %s", strings.Join(args, " "), err.Error(), string(out))
+	}
+	return strings.TrimSpace(string(out))
+}
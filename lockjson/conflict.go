@@ -0,0 +1,211 @@
+package lockjson
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// ErrConflict is returned by Write when lock.json was changed by other
+// programs after it was read, and the changes were not rebased.
+var ErrConflict = errors.New("lock.json was changed by other program after volt read it " +
+	"(set \"on_conflict\" in [lock] section of config.toml to \"rebase\" to apply the changes to it)")
+
+// lastWritten is the hash of the content written by Write last time.
+var lastWritten string
+
+// WrittenByVolt returns true if content is the content of lock.json written
+// by Write last time in this process.
+func WrittenByVolt(content []byte) bool {
+	return lastWritten != "" && newReadContent(content).hash == lastWritten
+}
+
+// readContent is the content of lock.json read by Read.
+type readContent struct {
+	// content is nil if lock.json did not exist
+	content []byte
+	// hash is the SHA-256 hash of content, or empty string if lock.json did
+	// not exist
+	hash string
+}
+
+func newReadContent(content []byte) *readContent {
+	sum := sha256.Sum256(content)
+	return &readContent{content: content, hash: hex.EncodeToString(sum[:])}
+}
+
+// resolveConflict checks if lock.json was changed by other programs after
+// lockJSON was read. If it was changed, the changes of lockJSON are rebased
+// onto the new lock.json, or ErrConflict is returned according to
+// "on_conflict" in [lock] section of config.toml.
+func (lockJSON *LockJSON) resolveConflict() error {
+	if lockJSON.base == nil {
+		return nil
+	}
+	current := &readContent{}
+	content, err := ioutil.ReadFile(pathutil.LockJSON())
+	if err == nil {
+		current = newReadContent(content)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if current.hash == lockJSON.base.hash {
+		return nil
+	}
+
+	cfg, err := config.Read()
+	if err != nil {
+		return errors.New("could not read config.toml: " + err.Error())
+	}
+	action := cfg.Lock.OnConflict
+	if action == config.ConflictAsk {
+		action = askConflict()
+	}
+	if action != config.ConflictRebase {
+		return ErrConflict
+	}
+
+	err = lockJSON.rebase(current)
+	if err != nil {
+		return errors.New("lock.json was changed by other program after volt read it, " +
+			"and could not apply the changes to it: " + err.Error())
+	}
+	logger.Warn("lock.json was changed by other program after volt read it: applied the changes to it")
+	return nil
+}
+
+// askConflict asks whether the changes are rebased or not, and returns
+// config.ConflictRebase or config.ConflictAbort.
+// If stdin is not a terminal, config.ConflictAbort is returned.
+func askConflict() string {
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return config.ConflictAbort
+	}
+	fmt.Println("lock.json was changed by other program (e.g. editor, dotfiles sync) after volt read it.")
+	stdin := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Print("[r]ebase the changes by volt onto it, or [a]bort (roll back the changes)? [r/a]: ")
+		line, err := stdin.ReadString('\n')
+		switch strings.TrimSpace(line) {
+		case "r":
+			return config.ConflictRebase
+		case "a":
+			return config.ConflictAbort
+		}
+		if err != nil {
+			fmt.Println()
+			return config.ConflictAbort
+		}
+	}
+}
+
+// rebase applies the changes of lockJSON (from the base content) to current
+// content of lock.json, by three-way merge of each repository, profile, and
+// the current profile name.
+// It returns an error if both of lockJSON and current content changed the
+// same element differently.
+func (lockJSON *LockJSON) rebase(current *readContent) error {
+	base := initialLockJSON()
+	if lockJSON.base.content != nil {
+		var err error
+		if base, err = parse(lockJSON.base.content, false); err != nil {
+			return err
+		}
+	}
+	theirs := initialLockJSON()
+	if current.content != nil {
+		var err error
+		if theirs, err = parse(current.content, false); err != nil {
+			return err
+		}
+	}
+
+	name, ok := merge3(base.CurrentProfileName, lockJSON.CurrentProfileName, theirs.CurrentProfileName)
+	if !ok {
+		return errors.New("both changed current profile")
+	}
+	merged := &LockJSON{
+		Version:            lockJSON.Version,
+		CurrentProfileName: name.(string),
+		Repos:              make(ReposList, 0, len(theirs.Repos)),
+		Profiles:           make(ProfileList, 0, len(theirs.Profiles)),
+	}
+
+	reposPathList := make([]pathutil.ReposPath, 0, len(theirs.Repos)+len(lockJSON.Repos))
+	for i := range theirs.Repos {
+		reposPathList = append(reposPathList, theirs.Repos[i].Path)
+	}
+	for i := range lockJSON.Repos {
+		if !theirs.Repos.Contains(lockJSON.Repos[i].Path) {
+			reposPathList = append(reposPathList, lockJSON.Repos[i].Path)
+		}
+	}
+	for _, reposPath := range reposPathList {
+		find := func(reposList ReposList) *Repos {
+			repos, _ := reposList.FindByPath(reposPath)
+			return repos
+		}
+		repos, ok := merge3(find(base.Repos), find(lockJSON.Repos), find(theirs.Repos))
+		if !ok {
+			return errors.New("both changed repository " + reposPath.String())
+		}
+		if repos := repos.(*Repos); repos != nil {
+			merged.Repos = append(merged.Repos, *repos)
+		}
+	}
+
+	names := make([]string, 0, len(theirs.Profiles)+len(lockJSON.Profiles))
+	for i := range theirs.Profiles {
+		names = append(names, theirs.Profiles[i].Name)
+	}
+	for i := range lockJSON.Profiles {
+		if theirs.Profiles.FindIndexByName(lockJSON.Profiles[i].Name) < 0 {
+			names = append(names, lockJSON.Profiles[i].Name)
+		}
+	}
+	for _, name := range names {
+		find := func(profiles ProfileList) *Profile {
+			profile, _ := profiles.FindByName(name)
+			return profile
+		}
+		profile, ok := merge3(find(base.Profiles), find(lockJSON.Profiles), find(theirs.Profiles))
+		if !ok {
+			return errors.New("both changed profile " + name)
+		}
+		if profile := profile.(*Profile); profile != nil {
+			merged.Profiles = append(merged.Profiles, *profile)
+		}
+	}
+
+	if err := validate(merged); err != nil {
+		return err
+	}
+	lockJSON.Version = merged.Version
+	lockJSON.CurrentProfileName = merged.CurrentProfileName
+	lockJSON.Repos = merged.Repos
+	lockJSON.Profiles = merged.Profiles
+	return nil
+}
+
+// merge3 returns the result of three-way merge of base, mine, and theirs.
+// It returns false if both of mine and theirs were changed differently.
+func merge3(base, mine, theirs interface{}) (interface{}, bool) {
+	switch {
+	case reflect.DeepEqual(mine, base):
+		return theirs, true
+	case reflect.DeepEqual(theirs, base), reflect.DeepEqual(mine, theirs):
+		return mine, true
+	}
+	return nil, false
+}
@@ -0,0 +1,171 @@
+package lockjson
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func TestWriteConflict(t *testing.T) {
+	var tests = []struct {
+		name       string
+		onConflict string
+		mine       func(*LockJSON)
+		theirs     func(*LockJSON)
+		err        error
+		repos      []pathutil.ReposPath
+		profiles   []string
+	}{
+		{
+			name:     "not changed",
+			mine:     addRepos("localhost/local/mine"),
+			repos:    []pathutil.ReposPath{"localhost/local/base", "localhost/local/mine"},
+			profiles: []string{"default"},
+		},
+		{
+			name:       "rebase",
+			onConflict: "rebase",
+			mine:       addRepos("localhost/local/mine"),
+			theirs:     addProfile("theirs"),
+			repos:      []pathutil.ReposPath{"localhost/local/base", "localhost/local/mine"},
+			profiles:   []string{"default", "theirs"},
+		},
+		{
+			name:       "rebase removed repository",
+			onConflict: "rebase",
+			mine:       removeRepos("localhost/local/base"),
+			theirs:     addProfile("theirs"),
+			repos:      []pathutil.ReposPath{},
+			profiles:   []string{"default", "theirs"},
+		},
+		{
+			name:       "rebase same repository",
+			onConflict: "rebase",
+			mine:       setVersion("localhost/local/base", "mine"),
+			theirs:     setVersion("localhost/local/base", "theirs"),
+			err:        errors.New("both changed repository localhost/local/base"),
+		},
+		{
+			name:       "abort",
+			onConflict: "abort",
+			mine:       addRepos("localhost/local/mine"),
+			theirs:     addProfile("theirs"),
+			err:        ErrConflict,
+		},
+		{
+			name:   "ask without terminal",
+			mine:   addRepos("localhost/local/mine"),
+			theirs: addProfile("theirs"),
+			err:    ErrConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setUpVoltPath(t, tt.onConflict)
+			defer os.RemoveAll(pathutil.VoltPath())
+
+			lockJSON, err := Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.mine(lockJSON)
+			if tt.theirs != nil {
+				// Changed by other program after lockJSON was read
+				theirs, err := Read()
+				if err != nil {
+					t.Fatal(err)
+				}
+				tt.theirs(theirs)
+				if err = theirs.Write(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = lockJSON.Write()
+			if tt.err != nil {
+				if err == nil || !strings.Contains(err.Error(), tt.err.Error()) {
+					t.Errorf("expected error %q but got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			written, err := Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(written.Repos) != len(tt.repos) {
+				t.Errorf("expected repos %v but got %v", tt.repos, written.Repos)
+			}
+			for _, reposPath := range tt.repos {
+				if !written.Repos.Contains(reposPath) {
+					t.Errorf("%s is not in repos: %v", reposPath, written.Repos)
+				}
+			}
+			if len(written.Profiles) != len(tt.profiles) {
+				t.Errorf("expected profiles %v but got %v", tt.profiles, written.Profiles)
+			}
+			for _, name := range tt.profiles {
+				if written.Profiles.FindIndexByName(name) < 0 {
+					t.Errorf("%s is not in profiles: %v", name, written.Profiles)
+				}
+			}
+		})
+	}
+}
+
+// setUpVoltPath creates $VOLTPATH with lock.json which has
+// "localhost/local/base", and config.toml.
+func setUpVoltPath(t *testing.T, onConflict string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "volt-lockjson-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("VOLTPATH", dir)
+	if onConflict != "" {
+		content := []byte("[lock]\non_conflict = \"" + onConflict + "\"\n")
+		if err = ioutil.WriteFile(filepath.Join(dir, "config.toml"), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lockJSON := initialLockJSON()
+	addRepos("localhost/local/base")(lockJSON)
+	if err = lockJSON.Write(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func addRepos(reposPath pathutil.ReposPath) func(*LockJSON) {
+	return func(lockJSON *LockJSON) {
+		lockJSON.Repos = append(lockJSON.Repos, Repos{Type: ReposStaticType, Path: reposPath})
+		lockJSON.Profiles[0].ReposPath = append(lockJSON.Profiles[0].ReposPath, reposPath)
+	}
+}
+
+func removeRepos(reposPath pathutil.ReposPath) func(*LockJSON) {
+	return func(lockJSON *LockJSON) {
+		lockJSON.Repos.RemoveAllReposPath(reposPath)
+		lockJSON.Profiles.RemoveAllReposPath(reposPath)
+	}
+}
+
+func addProfile(name string) func(*LockJSON) {
+	return func(lockJSON *LockJSON) {
+		lockJSON.Profiles = append(lockJSON.Profiles, Profile{Name: name, ReposPath: make([]pathutil.ReposPath, 0)})
+	}
+}
+
+func setVersion(reposPath pathutil.ReposPath, version string) func(*LockJSON) {
+	return func(lockJSON *LockJSON) {
+		repos, _ := lockJSON.Repos.FindByPath(reposPath)
+		repos.Type = ReposGitType
+		repos.Version = version
+	}
+}
@@ -0,0 +1,216 @@
+package lockjson
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/vim-volt/volt/fileutil"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// Index is the summary of lock.json in $VOLTPATH/lock.index.json, which is
+// updated whenever lock.json is written. The commands which only show the
+// repositories (e.g. completion) read it instead of reading and validating
+// the whole lock.json and scanning the repositories.
+type Index struct {
+	Version int `json:"version"`
+	// LockJSONSize and LockJSONModTime are the size and the modification
+	// time (UnixNano) of lock.json which the index was made from.
+	// If lock.json was changed by other programs, the index is made again.
+	LockJSONSize       int64        `json:"lock_json_size"`
+	LockJSONModTime    int64        `json:"lock_json_mtime"`
+	CurrentProfileName string       `json:"current_profile_name"`
+	Profiles           []string     `json:"profiles"`
+	Repos              []IndexEntry `json:"repos"`
+}
+
+// IndexEntry is a repository in Index.
+type IndexEntry struct {
+	Path    pathutil.ReposPath `json:"path"`
+	Type    ReposType          `json:"type"`
+	Version string             `json:"version,omitempty"`
+	// Size is the total size of the files of the repository in bytes, or -1
+	// if it is unknown. It is computed when the repository was added or its
+	// version was changed.
+	Size     int64    `json:"size"`
+	Profiles []string `json:"profiles,omitempty"`
+}
+
+const indexVersion = 1
+
+// ReadIndex reads $VOLTPATH/lock.index.json. If it does not exist or it is
+// older than lock.json, it is made from lock.json again (the unknown sizes
+// are -1 until lock.json is written).
+func ReadIndex() (*Index, error) {
+	fi, err := os.Stat(pathutil.LockJSON())
+	if os.IsNotExist(err) {
+		return newIndex(initialLockJSON(), nil, nil, false), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	old := readIndexFile()
+	if old != nil && old.LockJSONSize == fi.Size() && old.LockJSONModTime == fi.ModTime().UnixNano() {
+		return old, nil
+	}
+
+	logger.Debug("Updating " + pathutil.LockIndexJSON() + " ...")
+	lockJSON, err := ReadOnly()
+	if err != nil {
+		return nil, err
+	}
+	index := newIndex(lockJSON, fi, old, false)
+	if err = index.write(); err != nil {
+		logger.Debug("Could not write " + pathutil.LockIndexJSON() + ": " + err.Error())
+	}
+	return index, nil
+}
+
+// FindByPath returns the entry of reposPath, or nil if it is not found.
+func (index *Index) FindByPath(reposPath pathutil.ReposPath) *IndexEntry {
+	for i := range index.Repos {
+		if index.Repos[i].Path == reposPath {
+			return &index.Repos[i]
+		}
+	}
+	return nil
+}
+
+// HasProfile returns true if profile name exists.
+func (index *Index) HasProfile(name string) bool {
+	for i := range index.Profiles {
+		if index.Profiles[i] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// InProfile returns true if the repository of entry is in profile name.
+func (entry *IndexEntry) InProfile(name string) bool {
+	for i := range entry.Profiles {
+		if entry.Profiles[i] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readIndexFile returns the content of $VOLTPATH/lock.index.json, or nil if
+// it cannot be read.
+func readIndexFile() *Index {
+	content, err := ioutil.ReadFile(pathutil.LockIndexJSON())
+	if err != nil {
+		return nil
+	}
+	var index Index
+	if json.Unmarshal(content, &index) != nil || index.Version != indexVersion {
+		return nil
+	}
+	return &index
+}
+
+// updateIndex makes the index from content of lock.json written by
+// WriteContent. The errors are ignored because the index can be made again
+// from lock.json.
+func updateIndex(content []byte) {
+	fi, err := os.Stat(pathutil.LockJSON())
+	if err != nil {
+		return
+	}
+	lockJSON, err := parse(content, false)
+	if err != nil {
+		logger.Debug("Could not parse lock.json to update the index: " + err.Error())
+		return
+	}
+	if err = newIndex(lockJSON, fi, readIndexFile(), true).write(); err != nil {
+		logger.Debug("Could not write " + pathutil.LockIndexJSON() + ": " + err.Error())
+	}
+}
+
+// newIndex makes the index of lockJSON. fi is the file info of lock.json, or
+// nil if it does not exist. The sizes of the repositories whose versions
+// were not changed are copied from old. If computeSize is false, the other
+// sizes are -1, otherwise they are computed.
+func newIndex(lockJSON *LockJSON, fi os.FileInfo, old *Index, computeSize bool) *Index {
+	index := &Index{
+		Version:            indexVersion,
+		CurrentProfileName: lockJSON.CurrentProfileName,
+		Profiles:           make([]string, 0, len(lockJSON.Profiles)),
+		Repos:              make([]IndexEntry, 0, len(lockJSON.Repos)),
+	}
+	if fi != nil {
+		index.LockJSONSize = fi.Size()
+		index.LockJSONModTime = fi.ModTime().UnixNano()
+	}
+	profiles := make(map[pathutil.ReposPath][]string, len(lockJSON.Repos))
+	for i := range lockJSON.Profiles {
+		name := lockJSON.Profiles[i].Name
+		index.Profiles = append(index.Profiles, name)
+		for _, reposPath := range lockJSON.Profiles[i].ReposPath {
+			profiles[reposPath] = append(profiles[reposPath], name)
+		}
+	}
+	type sizeKey struct {
+		path    pathutil.ReposPath
+		version string
+	}
+	oldSizes := make(map[sizeKey]int64)
+	if old != nil {
+		for i := range old.Repos {
+			// The files of static repositories are changed without changing
+			// the version
+			if old.Repos[i].Type == ReposGitType && old.Repos[i].Size >= 0 {
+				oldSizes[sizeKey{old.Repos[i].Path, old.Repos[i].Version}] = old.Repos[i].Size
+			}
+		}
+	}
+	for i := range lockJSON.Repos {
+		repos := &lockJSON.Repos[i]
+		size, known := oldSizes[sizeKey{repos.Path, repos.Version}]
+		if !known {
+			size = -1
+			if computeSize {
+				if s, err := fileutil.DirSize(repos.Path.FullPath()); err == nil {
+					size = s
+				}
+			}
+		}
+		index.Repos = append(index.Repos, IndexEntry{
+			Path:     repos.Path,
+			Type:     repos.Type,
+			Version:  repos.Version,
+			Size:     size,
+			Profiles: profiles[repos.Path],
+		})
+	}
+	return index
+}
+
+// write writes index to $VOLTPATH/lock.index.json atomically, so other
+// processes do not read half-written index.
+func (index *Index) write() error {
+	content, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	file := pathutil.LockIndexJSON()
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package lockjson
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func TestReadIndex(t *testing.T) {
+	setUpVoltPath(t, "")
+	defer os.RemoveAll(os.Getenv("VOLTPATH"))
+
+	// The index is updated by Write
+	file := filepath.Join(pathutil.ReposPath("localhost/local/mine").FullPath(), "plugin", "mine.vim")
+	os.MkdirAll(filepath.Dir(file), 0755)
+	if err := ioutil.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lockJSON, err := Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addRepos("localhost/local/mine")(lockJSON)
+	addProfile("other")(lockJSON)
+	if err = lockJSON.Write(); err != nil {
+		t.Fatal(err)
+	}
+	index, err := ReadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(index.Profiles, []string{"default", "other"}) {
+		t.Errorf("unexpected profiles: %v", index.Profiles)
+	}
+	entry := index.FindByPath("localhost/local/mine")
+	if entry == nil || entry.Size != 5 || !entry.InProfile("default") || entry.InProfile("other") {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	// The index is made again if lock.json was changed by other programs
+	content, err := ioutil.ReadFile(pathutil.LockJSON())
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs, err := Parse(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removeRepos("localhost/local/mine")(theirs)
+	theirs.Profiles = theirs.Profiles[:1]
+	if err = writeByOtherProgram(theirs); err != nil {
+		t.Fatal(err)
+	}
+	index, err = ReadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.FindByPath("localhost/local/mine") != nil || index.HasProfile("other") {
+		t.Errorf("the index was not made again: %+v", index)
+	}
+	if readIndexFile() == nil {
+		t.Error("the index was not written")
+	}
+}
+
+// writeByOtherProgram writes lockJSON to lock.json without updating the index.
+func writeByOtherProgram(lockJSON *LockJSON) error {
+	content, err := json.MarshalIndent(lockJSON, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(pathutil.LockJSON(), content, 0644); err != nil {
+		return err
+	}
+	// The modification time may not be changed in the same tick
+	future := time.Now().Add(time.Second)
+	return os.Chtimes(pathutil.LockJSON(), future, future)
+}
@@ -7,10 +7,13 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 
+	"github.com/vim-volt/volt/config"
 	"github.com/vim-volt/volt/logger"
 	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/timing"
 )
 
 // ReposList = []Repos
@@ -25,6 +28,15 @@ type LockJSON struct {
 	CurrentProfileName string      `json:"current_profile_name"`
 	Repos              ReposList   `json:"repos"`
 	Profiles           ProfileList `json:"profiles"`
+
+	// base is the content of lock.json when it was read by Read, which is
+	// used to detect the changes by other programs (see Write)
+	base *readContent
+	// readOnly is true if it was read by ReadOnly
+	readOnly bool
+	// reposIndex maps repos[]/path to the index of Repos, which is built when
+	// the repositories of a profile are looked up at first (see findRepos)
+	reposIndex map[pathutil.ReposPath]int
 }
 
 // ReposType = string
@@ -44,8 +56,44 @@ type Repos struct {
 	Type    ReposType          `json:"type"`
 	Path    pathutil.ReposPath `json:"path"`
 	Version string             `json:"version"`
+	// Submodules is how submodules are initialized and updated
+	// (SubmodulesNone, SubmodulesShallow, or SubmodulesRecursive).
+	// Empty string is same as SubmodulesRecursive.
+	Submodules string `json:"submodules,omitempty"`
+	// SubmoduleVersions is the map of submodule path and its commit hash
+	SubmoduleVersions map[string]string `json:"submodule_versions,omitempty"`
+	// SparseCheckout is the patterns of files checked out in the worktree
+	// (e.g. ["/*", "!/screenshots"]). Empty means all files.
+	SparseCheckout []string `json:"sparse_checkout,omitempty"`
+	// CloneFilter is the filter of partial clone (e.g. "blob:none").
+	// Empty means full clone.
+	CloneFilter string `json:"clone_filter,omitempty"`
+	// Head is the state of HEAD when the version was locked
+	// (HeadBranch, HeadTag, or HeadDetached).
+	// Empty string is same as HeadBranch of current branch.
+	Head string `json:"head,omitempty"`
+	// HeadRef is the branch name (HeadBranch) or the tag name (HeadTag)
+	HeadRef string `json:"head_ref,omitempty"`
 }
 
+const (
+	// SubmodulesNone does not initialize submodules
+	SubmodulesNone = "none"
+	// SubmodulesShallow initializes only top-level submodules with depth 1
+	SubmodulesShallow = "shallow"
+	// SubmodulesRecursive initializes all nested submodules
+	SubmodulesRecursive = "recursive"
+)
+
+const (
+	// HeadBranch means a branch was checked out
+	HeadBranch = "branch"
+	// HeadTag means a tag was checked out (HEAD was detached at the tag)
+	HeadTag = "tag"
+	// HeadDetached means a commit was checked out (HEAD was detached)
+	HeadDetached = "detached"
+)
+
 type profReposPath []pathutil.ReposPath
 
 // Profile is a element of LockJSON.Profiles
@@ -80,11 +128,53 @@ func ReadNoMigrationMsg() (*LockJSON, error) {
 	return read(false)
 }
 
+// ErrReadOnly is returned when LockJSON read by ReadOnly is written.
+var ErrReadOnly = errors.New("lock.json was read as read-only")
+
+// ReadOnly reads lock.json for the commands which only show it (e.g.
+// "volt list"). It does not check the file before reading it, nor record the
+// content to detect the changes by other programs, so the result cannot be
+// written (Write returns ErrReadOnly). No migration message is printed.
+// lock.json is decoded as a stream (see decodeStream) unless it must be
+// migrated.
+func ReadOnly() (*LockJSON, error) {
+	f, err := os.Open(pathutil.LockJSON())
+	if os.IsNotExist(err) {
+		lockJSON := initialLockJSON()
+		lockJSON.readOnly = true
+		return lockJSON, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lockJSON, err := decodeStream(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	if lockJSON.Version < lockJSONVersion {
+		// Migration needs the whole content
+		bytes, err := ioutil.ReadFile(pathutil.LockJSON())
+		if err != nil {
+			return nil, err
+		}
+		if lockJSON, err = parse(bytes, false); err != nil {
+			return nil, err
+		}
+	} else if err = validate(lockJSON); err != nil {
+		return nil, errors.New("validation failed: lock.json: " + err.Error())
+	}
+	lockJSON.readOnly = true
+	return lockJSON, nil
+}
+
 func read(doLog bool) (*LockJSON, error) {
 	// Return initial lock.json struct if lockfile does not exist
 	lockfile := pathutil.LockJSON()
 	if !pathutil.Exists(lockfile) {
-		return initialLockJSON(), nil
+		lockJSON := initialLockJSON()
+		lockJSON.base = &readContent{}
+		return lockJSON, nil
 	}
 
 	// Read lock.json
@@ -92,8 +182,24 @@ func read(doLog bool) (*LockJSON, error) {
 	if err != nil {
 		return nil, err
 	}
+	lockJSON, err := parse(bytes, doLog)
+	if err != nil {
+		return nil, err
+	}
+	lockJSON.base = newReadContent(bytes)
+	return lockJSON, nil
+}
+
+// Parse parses content as lock.json and returns LockJSON.
+// Old structure is migrated to the latest version, and the result is
+// validated as same as Read.
+func Parse(content []byte) (*LockJSON, error) {
+	return parse(content, false)
+}
+
+func parse(bytes []byte, doLog bool) (*LockJSON, error) {
 	var lockJSON LockJSON
-	err = json.Unmarshal(bytes, &lockJSON)
+	err := json.Unmarshal(bytes, &lockJSON)
 	if err != nil {
 		return nil, err
 	}
@@ -140,6 +246,23 @@ func validate(lockJSON *LockJSON) error {
 		if _, err := pathutil.NormalizeRepos(repos.Path.String()); err != nil {
 			return errors.New("'" + repos.Path.String() + "' is invalid repos path")
 		}
+		// Validate if repos[]/submodules is invalid value
+		switch repos.Submodules {
+		case "", SubmodulesNone, SubmodulesShallow, SubmodulesRecursive:
+		default:
+			return fmt.Errorf("'%s' has invalid submodules value %q (must be %q, %q, or %q)",
+				repos.Path, repos.Submodules, SubmodulesNone, SubmodulesShallow, SubmodulesRecursive)
+		}
+		// Validate if repos[]/head is invalid value
+		switch repos.Head {
+		case "", HeadBranch, HeadTag, HeadDetached:
+		default:
+			return fmt.Errorf("'%s' has invalid head value %q (must be %q, %q, or %q)",
+				repos.Path, repos.Head, HeadBranch, HeadTag, HeadDetached)
+		}
+		if (repos.Head == HeadBranch || repos.Head == HeadTag) && repos.HeadRef == "" {
+			return fmt.Errorf("'%s' has head value %q, but head_ref is empty", repos.Path, repos.Head)
+		}
 		// Validate if duplicate repos[]/path exist
 		if _, exists := dup[repos.Path.String()]; exists {
 			return errors.New("duplicate repos '" + repos.Path.String() + "'")
@@ -252,28 +375,87 @@ func validateMissing(lockJSON *LockJSON) error {
 	return nil
 }
 
+// Write writes lockJSON to lock.json.
+// If lockJSON was read by Read and lock.json was changed by other programs
+// after that, the changes of lockJSON are rebased onto the new lock.json, or
+// ErrConflict is returned (see resolveConflict).
 func (lockJSON *LockJSON) Write() error {
+	if lockJSON.readOnly {
+		return ErrReadOnly
+	}
+	defer timing.Start(timing.LockWrite)()
+
 	// Validate lock.json
 	err := validate(lockJSON)
 	if err != nil {
 		return err
 	}
 
+	// Do not overwrite the changes by other programs after lock.json was read
+	err = lockJSON.resolveConflict()
+	if err != nil {
+		return err
+	}
+
+	if cfg, err := config.Read(); err == nil && *cfg.Build.Deterministic {
+		lockJSON.sort()
+	}
+
+	bytes, err := json.MarshalIndent(lockJSON, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = WriteContent(bytes); err != nil {
+		return err
+	}
+	if lockJSON.base != nil {
+		lockJSON.base = newReadContent(bytes)
+	}
+	return nil
+}
+
+// sort sorts repos by path, profiles by name, and the repositories of each
+// profile by path, so that lock.json does not depend on the order of the
+// operations ("build.deterministic" of config.toml).
+func (lockJSON *LockJSON) sort() {
+	sort.Slice(lockJSON.Repos, func(i, j int) bool {
+		return lockJSON.Repos[i].Path < lockJSON.Repos[j].Path
+	})
+	sort.Slice(lockJSON.Profiles, func(i, j int) bool {
+		return lockJSON.Profiles[i].Name < lockJSON.Profiles[j].Name
+	})
+	for i := range lockJSON.Profiles {
+		reposPath := lockJSON.Profiles[i].ReposPath
+		sort.Slice(reposPath, func(i, j int) bool {
+			return reposPath[i] < reposPath[j]
+		})
+	}
+}
+
+// WriteContent writes content to lock.json as it is (e.g. the content in the
+// history of transactions), and updates the index (see ReadIndex).
+func WriteContent(content []byte) error {
 	// Mkdir all if lock.json's directory does not exist
 	lockfile := pathutil.LockJSON()
 	if !pathutil.Exists(filepath.Dir(lockfile)) {
-		err = os.MkdirAll(filepath.Dir(lockfile), 0755)
+		err := os.MkdirAll(filepath.Dir(lockfile), 0755)
 		if err != nil {
 			return err
 		}
 	}
 
-	// Write to lock.json
-	bytes, err := json.MarshalIndent(lockJSON, "", "  ")
-	if err != nil {
+	// Write to temporary file and rename it, not to let other processes read
+	// half-written lock.json
+	tmp := lockfile + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0644); err != nil {
 		return err
 	}
-	return ioutil.WriteFile(pathutil.LockJSON(), bytes, 0644)
+	if err := os.Rename(tmp, lockfile); err != nil {
+		return err
+	}
+	lastWritten = newReadContent(content).hash
+	updateIndex(content)
+	return nil
 }
 
 // GetCurrentReposList returns current profile's repositories.
@@ -385,7 +567,7 @@ func (reposPathList profReposPath) IndexOf(reposPath pathutil.ReposPath) int {
 func (lockJSON *LockJSON) GetReposListByProfile(profile *Profile) (ReposList, error) {
 	reposList := make(ReposList, 0, len(profile.ReposPath))
 	for _, reposPath := range profile.ReposPath {
-		repos, err := lockJSON.Repos.FindByPath(reposPath)
+		repos, err := lockJSON.findRepos(reposPath)
 		if err != nil {
 			return nil, err
 		}
@@ -393,3 +575,20 @@ func (lockJSON *LockJSON) GetReposListByProfile(profile *Profile) (ReposList, er
 	}
 	return reposList, nil
 }
+
+// findRepos is same as lockJSON.Repos.FindByPath, but looks up reposIndex.
+// The index is not updated when Repos is changed, so each hit is checked and
+// it falls back to FindByPath if the index is stale.
+func (lockJSON *LockJSON) findRepos(reposPath pathutil.ReposPath) (*Repos, error) {
+	if lockJSON.reposIndex == nil {
+		lockJSON.reposIndex = make(map[pathutil.ReposPath]int, len(lockJSON.Repos))
+		for i := range lockJSON.Repos {
+			lockJSON.reposIndex[lockJSON.Repos[i].Path] = i
+		}
+	}
+	if i, exists := lockJSON.reposIndex[reposPath]; exists &&
+		i < len(lockJSON.Repos) && lockJSON.Repos[i].Path == reposPath {
+		return &lockJSON.Repos[i], nil
+	}
+	return lockJSON.Repos.FindByPath(reposPath)
+}
@@ -0,0 +1,65 @@
+package lockjson
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	setUpVoltPath(t, "")
+	defer os.RemoveAll(os.Getenv("VOLTPATH"))
+
+	lockJSON, err := ReadOnly()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lockJSON.Repos.Contains("localhost/local/base") {
+		t.Errorf("expected localhost/local/base in repos: %+v", lockJSON.Repos)
+	}
+	addRepos("localhost/local/mine")(lockJSON)
+	if err = lockJSON.Write(); err != ErrReadOnly {
+		t.Errorf("expected ErrReadOnly but got %v", err)
+	}
+}
+
+func TestDecodeStream(t *testing.T) {
+	content := `{
+  "version": 2,
+  "unknown": {"key": [1, 2, {"nested": null}]},
+  "current_profile_name": "default",
+  "repos": [
+    {"type": "static", "path": "localhost/local/a", "version": "#static#"},
+    {"type": "git", "path": "github.com/tyru/b", "version": "abc", "head_ref": "master"}
+  ],
+  "profiles": [
+    {"name": "default", "repos_path": ["github.com/tyru/b", "localhost/local/a"]}
+  ]
+}`
+	lockJSON, err := decodeStream(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected LockJSON
+	if err = json.Unmarshal([]byte(content), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lockJSON, &expected) {
+		t.Errorf("expected %+v but got %+v", expected, *lockJSON)
+	}
+
+	reposList, err := lockJSON.GetCurrentReposList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reposList) != 2 || reposList[0].Path != "github.com/tyru/b" || reposList[1].Path != "localhost/local/a" {
+		t.Errorf("unexpected repos list: %+v", reposList)
+	}
+	// The index must not return a removed repository
+	lockJSON.Repos.RemoveAllReposPath("localhost/local/a")
+	if _, err = lockJSON.GetCurrentReposList(); err == nil {
+		t.Error("expected an error for a removed repository")
+	}
+}
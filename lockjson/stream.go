@@ -0,0 +1,93 @@
+package lockjson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// decodeStream decodes lock.json from r token by token. Each element of
+// "repos" and "profiles" is decoded one at a time, so the whole content is
+// not held in memory. Unknown keys are skipped.
+// The result is not migrated nor validated.
+func decodeStream(r io.Reader) (*LockJSON, error) {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	var lockJSON LockJSON
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected token %v", t)
+		}
+		switch key {
+		case "version":
+			err = dec.Decode(&lockJSON.Version)
+		case "current_profile_name":
+			err = dec.Decode(&lockJSON.CurrentProfileName)
+		case "repos":
+			err = decodeArray(dec, func() {
+				lockJSON.Repos = make(ReposList, 0)
+			}, func() error {
+				lockJSON.Repos = append(lockJSON.Repos, Repos{})
+				return dec.Decode(&lockJSON.Repos[len(lockJSON.Repos)-1])
+			})
+		case "profiles":
+			err = decodeArray(dec, func() {
+				lockJSON.Profiles = make(ProfileList, 0)
+			}, func() error {
+				lockJSON.Profiles = append(lockJSON.Profiles, Profile{})
+				return dec.Decode(&lockJSON.Profiles[len(lockJSON.Profiles)-1])
+			})
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	return &lockJSON, nil
+}
+
+// decodeArray calls begin and decodeElem for each element of the array at
+// the current position of dec. Nothing is called if the value is null.
+func decodeArray(dec *json.Decoder, begin func(), decodeElem func() error) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return nil
+	}
+	if d, ok := t.(json.Delim); !ok || d != '[' {
+		return errors.New("expected array but got " + fmt.Sprint(t))
+	}
+	begin()
+	for dec.More() {
+		if err = decodeElem(); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected %q but got %v", delim, t)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package lockjson
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// NextTrxID allocates the ID of a transaction, which is greater than all IDs
+// allocated before.
+// It must be called while current process has $VOLTPATH/trx.lock (see
+// transaction.Create).
+//
+// The last ID is kept in $VOLTPATH/trx.id instead of lock.json, because
+// lock.json is restored to older content by rollback and "volt undo".
+// IDs in $VOLTPATH/trx/ are also considered, so IDs are not reused even if
+// trx.id was lost.
+func NextTrxID() (int, error) {
+	if err := checkTrxLock(); err != nil {
+		return 0, err
+	}
+
+	last, err := readTrxID()
+	if err != nil {
+		return 0, err
+	}
+	infos, err := ioutil.ReadDir(pathutil.TrxHistoryDir())
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	for _, info := range infos {
+		if id, err := strconv.Atoi(info.Name()); err == nil && info.IsDir() && id > last {
+			last = id
+		}
+	}
+
+	id := last + 1
+	// Write to temporary file and rename it not to leave broken file
+	tmp := pathutil.TrxID() + ".tmp"
+	if err = ioutil.WriteFile(tmp, []byte(strconv.Itoa(id)+"\n"), 0644); err != nil {
+		return 0, err
+	}
+	if err = os.Rename(tmp, pathutil.TrxID()); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// readTrxID returns the last ID in $VOLTPATH/trx.id.
+// If the file does not exist or is broken, it returns 0.
+func readTrxID() (int, error) {
+	content, err := ioutil.ReadFile(pathutil.TrxID())
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		logger.Warn(pathutil.TrxID() + " is broken, the IDs in the history are used instead: " + err.Error())
+		return 0, nil
+	}
+	return id, nil
+}
+
+// checkTrxLock returns an error if $VOLTPATH/trx.lock is not owned by
+// current process.
+func checkTrxLock() error {
+	content, err := ioutil.ReadFile(pathutil.TrxLock())
+	if err != nil {
+		return errors.New("transaction has not begun: " + err.Error())
+	}
+	pid := strings.SplitN(strings.TrimSpace(string(content)), "\n", 2)[0]
+	if pid != strconv.Itoa(os.Getpid()) {
+		return errors.New("trx.lock is owned by other process (PID " + pid + ")")
+	}
+	return nil
+}
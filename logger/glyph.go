@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Glyph is a symbol in the output. It is shown in ASCII unless Unicode
+// symbols are enabled by SetUnicode().
+type Glyph struct {
+	ascii   string
+	unicode string
+	emoji   string
+}
+
+var (
+	// GlyphArrow is shown between the values before and after a change.
+	GlyphArrow = Glyph{ascii: "->", unicode: "→", emoji: "→"}
+	// GlyphDone is shown before the summary of a command.
+	GlyphDone = Glyph{ascii: "", unicode: "", emoji: "✨ "}
+)
+
+var useUnicode, useEmoji bool
+
+// SetUnicode enables or disables Unicode symbols and emoji of the output.
+// emoji is used only if unicode is true. By default, only ASCII is used.
+func SetUnicode(unicode, emoji bool) {
+	useUnicode = unicode
+	useEmoji = unicode && emoji
+}
+
+// String returns the symbol which is enabled by SetUnicode().
+func (g Glyph) String() string {
+	switch {
+	case useEmoji:
+		return g.emoji
+	case useUnicode:
+		return g.unicode
+	default:
+		return g.ascii
+	}
+}
+
+// CanShowUnicode returns true if stdout is a terminal which can show Unicode
+// symbols: TERM is not "dumb", and the locale is UTF-8 (Windows Terminal on
+// Windows).
+func CanShowUnicode() bool {
+	if !isatty.IsTerminal(os.Stdout.Fd()) && !isatty.IsCygwinTerminal(os.Stdout.Fd()) {
+		return false
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return os.Getenv("WT_SESSION") != ""
+	}
+	// The first non-empty variable determines the encoding
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := strings.ToLower(os.Getenv(name)); locale != "" {
+			return strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package logger
+
+import "testing"
+
+func TestGlyph(t *testing.T) {
+	defer SetUnicode(false, false)
+	for _, tt := range []struct {
+		unicode, emoji bool
+		expected       string
+	}{
+		{false, false, "->"},
+		{false, true, "->"},
+		{true, false, "→"},
+	} {
+		SetUnicode(tt.unicode, tt.emoji)
+		if got := GlyphArrow.String(); got != tt.expected {
+			t.Errorf("SetUnicode(%v, %v): expected %q but got %q", tt.unicode, tt.emoji, tt.expected, got)
+		}
+	}
+	SetUnicode(true, true)
+	if GlyphDone.String() == "" {
+		t.Error("emoji is not used by SetUnicode(true, true)")
+	}
+}
@@ -36,6 +36,18 @@ var out *color.Color
 var m sync.Mutex
 
 func init() {
+	setLabels()
+	out = color.New()
+}
+
+// SetColor enables or disables the colors of the output. By default, the
+// output is colored only if it is a terminal.
+func SetColor(enabled bool) {
+	color.NoColor = !enabled
+	setLabels()
+}
+
+func setLabels() {
 	if !color.NoColor {
 		errorLabel = "[" + color.New(color.FgRed).Sprint("ERROR") + "]"
 		warnLabel = "[" + color.New(color.FgYellow).Sprint("WARN") + "]"
@@ -47,7 +59,6 @@ func init() {
 		infoLabel = "[INFO]"
 		debugLabel = "[DEBUG]"
 	}
-	out = color.New()
 }
 
 var logLevel = InfoLevel
@@ -161,3 +172,8 @@ func getDebugPrefix() string {
 func SetLevel(level LogLevel) {
 	logLevel = level
 }
+
+// Level returns current log level.
+func Level() LogLevel {
+	return logLevel
+}
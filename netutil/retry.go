@@ -0,0 +1,107 @@
+package netutil
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/logger"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+)
+
+// RetryPolicy determines how many times and how long to wait to retry a
+// network operation.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts (including the first one)
+	Attempts int
+	// Backoff is the wait before the first retry.
+	// It is doubled on each retry.
+	Backoff time.Duration
+	// MaxBackoff is the upper limit of Backoff (0 means no limit)
+	MaxBackoff time.Duration
+}
+
+// NewRetryPolicy returns RetryPolicy of "network.retry_attempts",
+// "network.retry_backoff", and "network.retry_max_backoff" in config.toml.
+func NewRetryPolicy(cfg *config.Config) *RetryPolicy {
+	// Durations are already validated by config.Read()
+	backoff, _ := time.ParseDuration(cfg.Network.RetryBackoff)
+	maxBackoff, _ := time.ParseDuration(cfg.Network.RetryMaxBackoff)
+	return &RetryPolicy{
+		Attempts:   cfg.Network.RetryAttempts,
+		Backoff:    backoff,
+		MaxBackoff: maxBackoff,
+	}
+}
+
+// Retry invokes f until it succeeds, it returns a permanent error (see
+// IsTransient), or the number of attempts reaches policy.Attempts.
+// what is the name of the operation shown in log messages.
+func (policy *RetryPolicy) Retry(what string, f func() error) error {
+	wait := policy.Backoff
+	for i := 1; ; i++ {
+		err := f()
+		if err == nil || i >= policy.Attempts || !IsTransient(err) {
+			return err
+		}
+		if policy.MaxBackoff > 0 && wait > policy.MaxBackoff {
+			wait = policy.MaxBackoff
+		}
+		// Add jitter of [-50%, +50%) to avoid retrying at the same time
+		d := wait/2 + time.Duration(rand.Int63n(int64(wait)+1))
+		logger.Warnf("%s failed (%d/%d), retrying in %s ...: %s", what, i, policy.Attempts, d, err.Error())
+		time.Sleep(d)
+		wait *= 2
+	}
+}
+
+type temporary interface {
+	Temporary() bool
+}
+
+// IsTransient returns true if err may not occur when the operation is
+// retried (e.g. connection reset, timeout, 5xx status).
+// Errors like 404 status, authentication failure, unknown host, or refused
+// connection are permanent.
+func IsTransient(err error) bool {
+	switch err {
+	case transport.ErrRepositoryNotFound,
+		transport.ErrEmptyRemoteRepository,
+		transport.ErrAuthenticationRequired,
+		transport.ErrAuthorizationFailed,
+		transport.ErrInvalidAuthMethod:
+		return false
+	case io.EOF, io.ErrUnexpectedEOF:
+		return true
+	}
+	switch e := err.(type) {
+	case *url.Error:
+		// e.g. "no Host in request URL" is not a network error
+		return IsTransient(e.Err)
+	case *net.DNSError:
+		// e.g. "no such host" is permanent
+		return e.IsTimeout || e.IsTemporary
+	case net.Error:
+		// e.g. "connection refused" is permanent, but "connection reset by
+		// peer" is not
+		return e.Timeout() || e.Temporary() ||
+			errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED)
+	case *githttp.Err:
+		return IsTransientStatus(e.Response.StatusCode)
+	case temporary:
+		return e.Temporary()
+	}
+	return false
+}
+
+// IsTransientStatus returns true if HTTP status code means the request may
+// succeed when it is retried.
+func IsTransientStatus(code int) bool {
+	return code >= 500 || code == 429 || code == 408
+}
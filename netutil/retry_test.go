@@ -0,0 +1,92 @@
+package netutil
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+func TestIsTransient(t *testing.T) {
+	opError := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", err)}
+	}
+	for _, tt := range []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"EOF", io.EOF, true},
+		{"connection reset", opError(syscall.ECONNRESET), true},
+		{"connection refused", opError(syscall.ECONNREFUSED), false},
+		{"timeout", &net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}, true},
+		{"temporary DNS error", &net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}, true},
+		{"no such host", &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}, false},
+		{"no such host in url.Error", &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}}}, false},
+		{"refused proxy in url.Error", &url.Error{Op: "proxyconnect", URL: "https://example.com", Err: opError(syscall.ECONNREFUSED)}, false},
+		{"non-network url.Error", &url.Error{Op: "Get", URL: "https://", Err: errors.New("no Host in request URL")}, false},
+		{"repository not found", transport.ErrRepositoryNotFound, false},
+	} {
+		if got := IsTransient(tt.err); got != tt.expected {
+			t.Errorf("%s: expected %v but got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestRetryPermanentError(t *testing.T) {
+	policy := &RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+	calls := 0
+	err := policy.Retry("test", func() error {
+		calls++
+		return &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}
+	})
+	if err == nil {
+		t.Error("expected an error but got nil")
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 attempt but got %d", calls)
+	}
+}
+
+func TestRetryTransientError(t *testing.T) {
+	policy := &RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+	calls := 0
+	err := policy.Retry("test", func() error {
+		calls++
+		if calls < 3 {
+			return io.EOF
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("expected nil but got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts but got %d", calls)
+	}
+}
+
+func TestRetryMaxBackoff(t *testing.T) {
+	policy := &RetryPolicy{Attempts: 3, Backoff: time.Hour, MaxBackoff: time.Millisecond}
+	calls := 0
+	start := time.Now()
+	err := policy.Retry("test", func() error {
+		calls++
+		return io.EOF
+	})
+	if err != io.EOF {
+		t.Errorf("expected %v but got %v", io.EOF, err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts but got %d", calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the wait is not limited by MaxBackoff: %s", elapsed)
+	}
+}
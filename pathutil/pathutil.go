@@ -2,6 +2,7 @@ package pathutil
 
 import (
 	"errors"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -114,6 +115,15 @@ func (path ReposPath) CloneURL() string {
 	return "https://" + filepath.ToSlash(path.String())
 }
 
+// Host returns the host of ReposPath (e.g. "github.com").
+func (path ReposPath) Host() string {
+	s := filepath.ToSlash(path.String())
+	if i := strings.Index(s, "/"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
 // Plugconf returns fullpath of plugconf.
 func (path ReposPath) Plugconf() string {
 	filenameList := strings.Split(filepath.ToSlash(path.String()+".vim"), "/")
@@ -124,6 +134,11 @@ func (path ReposPath) Plugconf() string {
 	return filepath.Join(paths...)
 }
 
+// LuaPlugconf returns fullpath of Lua plugconf (for Neovim).
+func (path ReposPath) LuaPlugconf() string {
+	return strings.TrimSuffix(path.Plugconf(), ".vim") + ".lua"
+}
+
 // ProfileVimrc is the basename of profile vimrc.
 const ProfileVimrc = "vimrc.vim"
 
@@ -164,21 +179,101 @@ func LockJSON() string {
 	return filepath.Join(VoltPath(), "lock.json")
 }
 
+// LockIndexJSON returns fullpath of "$HOME/volt/lock.index.json".
+func LockIndexJSON() string {
+	return filepath.Join(VoltPath(), "lock.index.json")
+}
+
 // ConfigTOML returns fullpath of "$HOME/volt/config.toml".
 func ConfigTOML() string {
 	return filepath.Join(VoltPath(), "config.toml")
 }
 
+// ConfigLocalTOML returns fullpath of "$HOME/volt/config.local.toml".
+func ConfigLocalTOML() string {
+	return filepath.Join(VoltPath(), "config.local.toml")
+}
+
+// Voltfile returns fullpath of "$HOME/volt/voltfile.toml".
+func Voltfile() string {
+	return filepath.Join(VoltPath(), "voltfile.toml")
+}
+
 // TrxLock returns fullpath of "$HOME/volt/trx.lock".
 func TrxLock() string {
 	return filepath.Join(VoltPath(), "trx.lock")
 }
 
+// TrxJournal returns fullpath of "$HOME/volt/trx.journal".
+func TrxJournal() string {
+	return filepath.Join(VoltPath(), "trx.journal")
+}
+
+// TrxBackup returns fullpath of "$HOME/volt/trx.backup".
+func TrxBackup() string {
+	return filepath.Join(VoltPath(), "trx.backup")
+}
+
+// TrxID returns fullpath of "$HOME/volt/trx.id".
+func TrxID() string {
+	return filepath.Join(VoltPath(), "trx.id")
+}
+
+// TrxHistoryDir returns fullpath of "$HOME/volt/trx".
+func TrxHistoryDir() string {
+	return filepath.Join(VoltPath(), "trx")
+}
+
+// TrxResume returns fullpath of "$HOME/volt/trx.resume".
+func TrxResume() string {
+	return filepath.Join(VoltPath(), "trx.resume")
+}
+
+// BuildHookDir returns fullpath of "$HOME/volt/build-hook".
+func BuildHookDir() string {
+	return filepath.Join(VoltPath(), "build-hook")
+}
+
+// BuildHookLog returns fullpath of "$HOME/volt/build-hook/{repos}.log".
+func BuildHookLog(reposPath ReposPath) string {
+	return filepath.Join(BuildHookDir(), filepath.FromSlash(reposPath.String())+".log")
+}
+
+// TrustedKeys returns fullpath of "$HOME/volt/trusted_keys.asc".
+func TrustedKeys() string {
+	return filepath.Join(VoltPath(), "trusted_keys.asc")
+}
+
+// MetadataCacheJSON returns fullpath of "$HOME/volt/cache/metadata.json".
+func MetadataCacheJSON() string {
+	return filepath.Join(CacheDir(), "metadata.json")
+}
+
+// HTTPCacheDir returns fullpath of "$HOME/volt/cache/http".
+func HTTPCacheDir() string {
+	return filepath.Join(CacheDir(), "http")
+}
+
+// ProjectsJSON returns fullpath of "$HOME/volt/projects.json".
+func ProjectsJSON() string {
+	return filepath.Join(VoltPath(), "projects.json")
+}
+
+// StartupProfileJSON returns fullpath of "$HOME/volt/startup-profile.json".
+func StartupProfileJSON() string {
+	return filepath.Join(VoltPath(), "startup-profile.json")
+}
+
 // TempDir returns fullpath of "$HOME/tmp".
 func TempDir() string {
 	return filepath.Join(VoltPath(), "tmp")
 }
 
+// CacheDir returns fullpath of "$VOLTPATH/cache".
+func CacheDir() string {
+	return filepath.Join(VoltPath(), "cache")
+}
+
 // VimExecutable detects vim executable path.
 // If VOLT_VIM environment variable is set, use it.
 // Otherwise look up "vim" binary from PATH.
@@ -194,6 +289,21 @@ func VimExecutable() (string, error) {
 	return exec.LookPath(exeName)
 }
 
+// NvimExecutable detects nvim executable path.
+// If VOLT_NVIM environment variable is set, use it.
+// Otherwise look up "nvim" binary from PATH.
+func NvimExecutable() (string, error) {
+	var nvim string
+	if nvim = os.Getenv("VOLT_NVIM"); nvim != "" {
+		return nvim, nil
+	}
+	exeName := "nvim"
+	if runtime.GOOS == "windows" {
+		exeName = "nvim.exe"
+	}
+	return exec.LookPath(exeName)
+}
+
 // VimDir returns the following fullpath:
 //   Windows: $HOME/vimfiles
 //   Other: $HOME/.vim
@@ -205,19 +315,76 @@ func VimDir() string {
 	return filepath.Join(HomeDir(), vimdir)
 }
 
-// VimVoltDir returns "(vim dir)/pack/volt".
+// vimVoltDir is returned by VimVoltDir() instead of "(vim dir)/pack/volt"
+// if it is not empty. See SetVimVoltDir().
+var vimVoltDir string
+
+// VimVoltDir returns "(vim dir)/pack/volt", or the directory set by
+// SetVimVoltDir().
 func VimVoltDir() string {
+	if vimVoltDir != "" {
+		return vimVoltDir
+	}
 	return filepath.Join(VimDir(), "pack", "volt")
 }
 
+// SetVimVoltDir changes the directory returned by VimVoltDir() and the paths
+// under it to dir. "volt build" writes the build output to
+// VimVoltStagingDir() with this. An empty dir restores the default.
+func SetVimVoltDir(dir string) {
+	vimVoltDir = dir
+}
+
+// RuntimePath returns the path in "(vim dir)/pack/volt" which corresponds to
+// path under the directory set by SetVimVoltDir(). The paths written in the
+// build output must be converted with this, because the output is moved to
+// "(vim dir)/pack/volt" after the build.
+func RuntimePath(path string) string {
+	if vimVoltDir == "" {
+		return path
+	}
+	rel, err := filepath.Rel(vimVoltDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.Join(VimDir(), "pack", "volt", rel)
+}
+
+// VimVoltStagingDir returns "(vim dir)/.volt-staging".
+// It is outside of "(vim dir)/pack", so Vim does not load the plugins in it.
+func VimVoltStagingDir() string {
+	return filepath.Join(VimDir(), ".volt-staging")
+}
+
+// VimVoltOldDir returns "(vim dir)/.volt-old".
+func VimVoltOldDir() string {
+	return filepath.Join(VimDir(), ".volt-old")
+}
+
+// VimVoltProfilesDir returns "(vim dir)/.volt-profiles".
+func VimVoltProfilesDir() string {
+	return filepath.Join(VimDir(), ".volt-profiles")
+}
+
+// VimVoltProfileDir returns "(vim dir)/.volt-profiles/{name}", where the
+// runtime built for profile name is kept. {name} is escaped profile name
+// which does not start with ".".
+func VimVoltProfileDir(name string) string {
+	name = url.PathEscape(name)
+	if strings.HasPrefix(name, ".") {
+		name = "%2E" + name[1:]
+	}
+	return filepath.Join(VimVoltProfilesDir(), name)
+}
+
 // VimVoltOptDir returns "(vim dir)/pack/volt/opt".
 func VimVoltOptDir() string {
-	return filepath.Join(VimDir(), "pack", "volt", "opt")
+	return filepath.Join(VimVoltDir(), "opt")
 }
 
 // VimVoltStartDir returns "(vim dir)/pack/volt/start".
 func VimVoltStartDir() string {
-	return filepath.Join(VimDir(), "pack", "volt", "start")
+	return filepath.Join(VimVoltDir(), "start")
 }
 
 // BuildInfoJSON returns "(vim dir)/pack/volt/build-info.json".
@@ -230,6 +397,32 @@ func BundledPlugConf() string {
 	return filepath.Join(VimVoltStartDir(), "system", "plugin", "bundled_plugconf.vim")
 }
 
+// BundledFtdetect returns "(vim dir)/pack/volt/start/system/ftdetect/bundled_ftdetect.vim".
+func BundledFtdetect() string {
+	return filepath.Join(VimVoltStartDir(), "system", "ftdetect", "bundled_ftdetect.vim")
+}
+
+// BundledLuaPlugconfDir returns "(vim dir)/pack/volt/start/system/plugconf".
+func BundledLuaPlugconfDir() string {
+	return filepath.Join(VimVoltStartDir(), "system", "plugconf")
+}
+
+// BundledLuaPlugconf returns "(vim dir)/pack/volt/start/system/plugconf/{name}.lua".
+// {name} is the same as the directory name of the repository in opt dir.
+func (path ReposPath) BundledLuaPlugconf() string {
+	return filepath.Join(BundledLuaPlugconfDir(), packer.Replace(path.String())+".lua")
+}
+
+// BundledProjectLoader returns "(vim dir)/pack/volt/start/system/plugin/project_plugconf.vim".
+func BundledProjectLoader() string {
+	return filepath.Join(VimVoltStartDir(), "system", "plugin", "project_plugconf.vim")
+}
+
+// BundledProjectPlugconfDir returns "(vim dir)/pack/volt/start/system/project".
+func BundledProjectPlugconfDir() string {
+	return filepath.Join(VimVoltStartDir(), "system", "project")
+}
+
 // LookUpVimrc looks up vimrc path from the following candidates:
 //   Windows  : $HOME/_vimrc
 //              (vim dir)/vimrc
@@ -0,0 +1,176 @@
+package plugconf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/haya14busa/go-vimlparser"
+	"github.com/haya14busa/go-vimlparser/ast"
+	"github.com/haya14busa/go-vimlparser/token"
+)
+
+// LintInfo is the information of a plugconf file which is checked by
+// "volt lint".
+type LintInfo struct {
+	// Empty is true if all functions in the plugconf are empty
+	Empty bool
+	// Depends are the repositories returned by s:depends()
+	Depends []LintValue
+	// Excmds are the Ex commands of "excmd=..." returned by s:loaded_on()
+	Excmds []LintValue
+	// PlugMappings are "<Plug>" mappings of "mapping=..." returned by
+	// s:loaded_on()
+	PlugMappings []LintValue
+	// Mappings are the global mappings defined in the plugconf
+	Mappings []LintMapping
+}
+
+// LintValue is a value written at Line of a plugconf file.
+type LintValue struct {
+	Value string
+	Line  int
+}
+
+// LintMapping is a mapping defined at Line of a plugconf file.
+// Modes are the characters of the modes where the mapping is defined
+// (e.g. "nxso" for ":map").
+type LintMapping struct {
+	Modes string
+	Lhs   string
+	Line  int
+}
+
+// rxMapCmd is a pattern which matches to the names of mapping commands.
+// $1 is a mode character.
+var rxMapCmd = regexp.MustCompile(`\A([nvxsoilct]?)(?:nore)?map\z`)
+
+// rxMapArg is a pattern which matches to special arguments of mapping
+// commands.
+var rxMapArg = regexp.MustCompile(`(?i)\A<(?:buffer|nowait|silent|special|script|expr|unique)>\z`)
+
+// ParseLintInfo parses the plugconf file of path and returns LintInfo.
+func ParseLintInfo(path string) (*LintInfo, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := vimlparser.ParseFile(bytes.NewReader(content), path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &LintInfo{Empty: true}
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Function:
+			if !isEmptyFunc(n) {
+				info.Empty = false
+			}
+			ident, ok := n.Name.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			switch ident.Name {
+			case "s:depends":
+				info.Depends = append(info.Depends, returnedValues(n)...)
+			case "s:loaded_on":
+				for _, v := range returnedValues(n) {
+					trigger, err := parseLoadTrigger(v.Value)
+					if err != nil {
+						continue
+					}
+					switch trigger.on {
+					case loadOnExcmd:
+						for _, excmd := range strings.Split(trigger.arg, ",") {
+							info.Excmds = append(info.Excmds, LintValue{Value: excmd, Line: v.Line})
+						}
+					case loadOnMapping:
+						for _, lhs := range strings.Split(trigger.arg, ",") {
+							if strings.HasPrefix(strings.ToLower(lhs), "<plug>") {
+								info.PlugMappings = append(info.PlugMappings, LintValue{Value: lhs, Line: v.Line})
+							}
+						}
+					}
+				}
+			}
+		case *ast.Excmd:
+			if m := parseMapping(n, content); m != nil {
+				info.Mappings = append(info.Mappings, *m)
+			}
+		}
+		return true
+	})
+	return info, nil
+}
+
+// returnedValues returns string literals (without quotes) in the rhs of
+// :return in fn, with their lines.
+func returnedValues(fn *ast.Function) []LintValue {
+	var values []LintValue
+	ast.Inspect(fn, func(node ast.Node) bool {
+		ret, ok := node.(*ast.Return)
+		if !ok {
+			return true
+		}
+		var exprs []ast.Expr
+		switch rhs := ret.Result.(type) {
+		case *ast.BasicLit:
+			exprs = []ast.Expr{rhs}
+		case *ast.List:
+			exprs = rhs.Values
+		}
+		for i := range exprs {
+			lit, ok := exprs[i].(*ast.BasicLit)
+			if ok && lit.Kind == token.STRING {
+				values = append(values, LintValue{
+					Value: lit.Value[1 : len(lit.Value)-1],
+					Line:  lit.ValuePos.Line,
+				})
+			}
+		}
+		return true
+	})
+	return values
+}
+
+// parseMapping returns a global mapping defined by excmd, or nil if excmd is
+// not a mapping command (or defines a buffer-local mapping).
+func parseMapping(excmd *ast.Excmd, src []byte) *LintMapping {
+	if excmd.ExArg.Cmd == nil || excmd.ExArg.Argpos == nil {
+		return nil
+	}
+	m := rxMapCmd.FindStringSubmatch(excmd.ExArg.Cmd.Name)
+	if m == nil {
+		return nil
+	}
+	args := src[excmd.ExArg.Argpos.Offset:]
+	if i := bytes.IndexByte(args, '\n'); i >= 0 {
+		args = args[:i]
+	}
+	fields := strings.Fields(string(args))
+	for len(fields) > 0 && rxMapArg.MatchString(fields[0]) {
+		if strings.EqualFold(fields[0], "<buffer>") {
+			return nil
+		}
+		fields = fields[1:]
+	}
+	// ":map {lhs}" lists mappings
+	if len(fields) < 2 {
+		return nil
+	}
+
+	var modes string
+	switch {
+	case m[1] == "" && excmd.ExArg.Forceit:
+		modes = "ic"
+	case m[1] == "":
+		modes = "nxso"
+	case m[1] == "v":
+		modes = "xs"
+	default:
+		modes = m[1]
+	}
+	return &LintMapping{Modes: modes, Lhs: fields[0], Line: excmd.Pos().Line}
+}
@@ -6,10 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"path"
+	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	multierror "github.com/hashicorp/go-multierror"
@@ -29,18 +30,66 @@ const (
 	loadOnStart    loadOnType = "(loadOnStart)"
 	loadOnFileType            = "FileType"
 	loadOnExcmd               = "(loadOnExcmd)"
+	loadOnMapping             = "(loadOnMapping)"
+	loadOnEvent               = "(loadOnEvent)"
 )
 
+// loadTrigger is a trigger to load a plugin, which is returned by
+// s:loaded_on() (e.g. "filetype=vim").
+type loadTrigger struct {
+	on  loadOnType
+	arg string
+}
+
+type conditionType string
+
+const (
+	conditionVim     conditionType = "vim"
+	conditionNvim                  = "nvim"
+	conditionGUI                   = "gui"
+	conditionOS                    = "os"
+	conditionHas                   = "has"
+	conditionProfile               = "profile"
+)
+
+// osFeatures is Vim expressions for "os=<os>" conditions.
+var osFeatures = map[string]string{
+	"windows": "has('win32')",
+	"mac":     "has('mac') || has('macunix')",
+	"unix":    "has('unix')",
+	"linux":   "has('linux')",
+	"wsl":     "has('wsl')",
+}
+
+// condition is an environment where a plugin is enabled, which is returned
+// by s:enabled_if() (e.g. "nvim", "!gui", "os=windows,mac").
+// "profile=<profiles>" is evaluated by "volt build", and the others are
+// evaluated by Vim on startup.
+type condition struct {
+	not  bool
+	on   conditionType
+	args []string
+}
+
 const (
 	// TODO: Check duplicate variable for excmdLoadPlugin
-	excmdLoadPlugin   = "s:__volt_excmd_load_plugin"
-	lazyLoadExcmdFunc = "s:__volt_lazy_load_excmd"
-	completeFunc      = "s:__volt_complete"
+	excmdLoadPlugin     = "s:__volt_excmd_load_plugin"
+	lazyLoadExcmdFunc   = "s:__volt_lazy_load_excmd"
+	completeFunc        = "s:__volt_complete"
+	lazyLoadPlugins     = "s:__volt_lazy_load_plugins"
+	lazyLoadFunc        = "s:__volt_lazy_load"
+	lazyLoadMappingFunc = "s:__volt_lazy_load_mapping"
+	packaddFunc         = "s:__volt_packadd"
+	loadAfterFunc       = "s:__volt_load_after"
 )
 
 func isProhibitedFuncName(name string) bool {
 	return name == lazyLoadExcmdFunc ||
-		name == completeFunc
+		name == completeFunc ||
+		name == lazyLoadFunc ||
+		name == lazyLoadMappingFunc ||
+		name == packaddFunc ||
+		name == loadAfterFunc
 }
 
 // ParsedInfo represents parsed info of plugconf.
@@ -51,10 +100,52 @@ type ParsedInfo struct {
 	onLoadPreFunc  string
 	onLoadPostFunc string
 	loadOnFunc     string
-	loadOn         loadOnType
-	loadOnArg      string
+	loadOn         []loadTrigger
 	dependsFunc    string
 	depends        pathutil.ReposPathList
+	buildFunc      string
+	buildCmd       string
+	enabledIfFunc  string
+	enabledIf      []condition
+	loadOrderFunc  string
+	loadOrder      int
+}
+
+// BuildCmd returns the command returned by s:build(), which is run in the
+// repository after it is installed or upgraded.
+// It returns an empty string if s:build() is not defined.
+func (pi *ParsedInfo) BuildCmd() string {
+	return pi.buildCmd
+}
+
+// LoadOn returns the triggers returned by s:loaded_on() in the same form as
+// the return value (e.g. "filetype=vim"). It returns ["start"] if
+// s:loaded_on() is not defined.
+func (pi *ParsedInfo) LoadOn() []string {
+	if len(pi.loadOn) == 0 {
+		return []string{"start"}
+	}
+	prefixes := map[loadOnType]string{
+		loadOnFileType: "filetype=",
+		loadOnExcmd:    "excmd=",
+		loadOnMapping:  "mapping=",
+		loadOnEvent:    "event=",
+	}
+	triggers := make([]string, 0, len(pi.loadOn))
+	for _, trigger := range pi.loadOn {
+		if trigger.on == loadOnStart {
+			triggers = append(triggers, "start")
+		} else {
+			triggers = append(triggers, prefixes[trigger.on]+trigger.arg)
+		}
+	}
+	return triggers
+}
+
+// HasOnLoadFuncs returns true if s:on_load_pre() or s:on_load_post() (which
+// are not empty) is defined.
+func (pi *ParsedInfo) HasOnLoadFuncs() bool {
+	return pi.onLoadPreFunc != "" || pi.onLoadPostFunc != ""
 }
 
 // ConvertConfigToOnLoadPreFunc converts s:config() function name to
@@ -110,6 +201,24 @@ func (pi *ParsedInfo) GeneratePlugconf() ([]byte, error) {
 		buf.WriteString(skeletonPlugconfDepends)
 	}
 
+	// s:build()
+	if pi.buildFunc != "" {
+		buf.WriteString("\n\n")
+		buf.WriteString(pi.buildFunc)
+	}
+
+	// s:enabled_if()
+	if pi.enabledIfFunc != "" {
+		buf.WriteString("\n\n")
+		buf.WriteString(pi.enabledIfFunc)
+	}
+
+	// s:load_order()
+	if pi.loadOrderFunc != "" {
+		buf.WriteString("\n\n")
+		buf.WriteString(pi.loadOrderFunc)
+	}
+
 	for _, f := range pi.functions {
 		buf.WriteString("\n\n")
 		buf.WriteString(f)
@@ -302,14 +411,19 @@ func ParsePlugconfFile(path string, reposID int, reposPath pathutil.ReposPath) (
 // ParsePlugconf always returns non-nil parseErr
 // (which may have empty errors / warns)
 func ParsePlugconf(file *ast.File, src []byte, path string) (*ParsedInfo, *ParseError) {
-	var loadOn = loadOnStart
-	var loadOnArg string
+	var loadOn = []loadTrigger{{on: loadOnStart}}
 	var loadOnFunc string
 	var onLoadPreFunc string
 	var onLoadPostFunc string
 	var functions []string
 	var dependsFunc string
 	var depends pathutil.ReposPathList
+	var buildFunc string
+	var buildCmd string
+	var enabledIfFunc string
+	var enabledIf []condition
+	var loadOrderFunc string
+	var loadOrder int
 
 	parseErr := newParseError(path)
 
@@ -337,7 +451,7 @@ func ParsePlugconf(file *ast.File, src []byte, path string) (*ParsedInfo, *Parse
 			if !isEmptyFunc(fn) {
 				loadOnFunc = string(extractBody(fn, src))
 				var err error
-				loadOn, loadOnArg, err = inspectReturnValue(fn)
+				loadOn, err = inspectReturnValue(fn)
 				if err != nil {
 					parseErr.merr = multierror.Append(parseErr.merr, err)
 				}
@@ -390,6 +504,48 @@ func ParsePlugconf(file *ast.File, src []byte, path string) (*ParsedInfo, *Parse
 					parseErr.merr = multierror.Append(parseErr.merr, err)
 				}
 			}
+		case ident.Name == "s:build":
+			if buildFunc != "" {
+				parseErr.merr = multierror.Append(parseErr.merr,
+					errors.New("duplicate s:build()"))
+				return true
+			}
+			if !isEmptyFunc(fn) {
+				buildFunc = string(extractBody(fn, src))
+				var err error
+				buildCmd, err = getBuildCmd(fn)
+				if err != nil {
+					parseErr.merr = multierror.Append(parseErr.merr, err)
+				}
+			}
+		case ident.Name == "s:enabled_if":
+			if enabledIfFunc != "" {
+				parseErr.merr = multierror.Append(parseErr.merr,
+					errors.New("duplicate s:enabled_if()"))
+				return true
+			}
+			if !isEmptyFunc(fn) {
+				enabledIfFunc = string(extractBody(fn, src))
+				var err error
+				enabledIf, err = getConditions(fn)
+				if err != nil {
+					parseErr.merr = multierror.Append(parseErr.merr, err)
+				}
+			}
+		case ident.Name == "s:load_order":
+			if loadOrderFunc != "" {
+				parseErr.merr = multierror.Append(parseErr.merr,
+					errors.New("duplicate s:load_order()"))
+				return true
+			}
+			if !isEmptyFunc(fn) {
+				loadOrderFunc = string(extractBody(fn, src))
+				var err error
+				loadOrder, err = getLoadOrder(fn)
+				if err != nil {
+					parseErr.merr = multierror.Append(parseErr.merr, err)
+				}
+			}
 		case isProhibitedFuncName(ident.Name):
 			parseErr.merr = multierror.Append(parseErr.merr,
 				fmt.Errorf(
@@ -411,17 +567,41 @@ func ParsePlugconf(file *ast.File, src []byte, path string) (*ParsedInfo, *Parse
 		onLoadPostFunc: onLoadPostFunc,
 		loadOnFunc:     loadOnFunc,
 		loadOn:         loadOn,
-		loadOnArg:      loadOnArg,
 		dependsFunc:    dependsFunc,
 		depends:        depends,
+		buildFunc:      buildFunc,
+		buildCmd:       buildCmd,
+		enabledIfFunc:  enabledIfFunc,
+		enabledIf:      enabledIf,
+		loadOrderFunc:  loadOrderFunc,
+		loadOrder:      loadOrder,
 	}, parseErr
 }
 
-// Inspect return value of s:loaded_on() function in plugconf
-func inspectReturnValue(fn *ast.Function) (loadOnType, string, error) {
-	var loadOn loadOnType
-	var loadOnArg string
+// Inspect return value of s:loaded_on() function in plugconf.
+// The rhs of :return is a string literal, or a list literal of string
+// literals (the plugin is loaded by the first one of the triggers).
+func inspectReturnValue(fn *ast.Function) ([]loadTrigger, error) {
+	var triggers []loadTrigger
 	var err error
+	for _, value := range returnedStrings(fn) {
+		trigger, e := parseLoadTrigger(value[1 : len(value)-1])
+		if e != nil {
+			err = errors.New("Invalid rhs of ':return': " + value)
+			continue
+		}
+		triggers = append(triggers, trigger)
+	}
+	if len(triggers) == 0 {
+		return nil, errors.New("can't detect return value of s:loaded_on()")
+	}
+	return triggers, err
+}
+
+// returnedStrings returns string literals (with quotes) in the rhs of :return
+// in fn. The rhs is a string literal, or a list literal of string literals.
+func returnedStrings(fn *ast.Function) []string {
+	var result []string
 	ast.Inspect(fn, func(node ast.Node) bool {
 		// Cast to return node (return if it's not a return node)
 		ret, ok := node.(*ast.Return)
@@ -430,28 +610,198 @@ func inspectReturnValue(fn *ast.Function) (loadOnType, string, error) {
 		}
 
 		// Parse the argument of :return
-		rhs, ok := ret.Result.(*ast.BasicLit)
-		if ok && rhs.Kind == token.STRING {
-			value := rhs.Value[1 : len(rhs.Value)-1]
-			if value == "start" {
-				loadOn = loadOnStart
-			} else if strings.HasPrefix(value, "filetype=") {
-				loadOn = loadOnFileType
-				loadOnArg = strings.TrimPrefix(value, "filetype=")
-			} else if strings.HasPrefix(value, "excmd=") {
-				loadOn = loadOnExcmd
-				loadOnArg = strings.TrimPrefix(value, "excmd=")
-			} else {
-				err = errors.New("Invalid rhs of ':return': " + rhs.Value)
+		var values []ast.Expr
+		switch rhs := ret.Result.(type) {
+		case *ast.BasicLit:
+			values = []ast.Expr{rhs}
+		case *ast.List:
+			values = rhs.Values
+		}
+		for i := range values {
+			lit, ok := values[i].(*ast.BasicLit)
+			if ok && lit.Kind == token.STRING {
+				result = append(result, lit.Value)
 			}
 		}
 
 		return true
 	})
-	if string(loadOn) == "" {
-		return "", "", errors.New("can't detect return value of s:loaded_on()")
+	return result
+}
+
+func parseLoadTrigger(value string) (loadTrigger, error) {
+	switch {
+	case value == "start":
+		return loadTrigger{on: loadOnStart}, nil
+	case strings.HasPrefix(value, "filetype="):
+		return loadTrigger{on: loadOnFileType, arg: strings.TrimPrefix(value, "filetype=")}, nil
+	case strings.HasPrefix(value, "excmd="):
+		return loadTrigger{on: loadOnExcmd, arg: strings.TrimPrefix(value, "excmd=")}, nil
+	case strings.HasPrefix(value, "mapping="):
+		return loadTrigger{on: loadOnMapping, arg: strings.TrimPrefix(value, "mapping=")}, nil
+	case strings.HasPrefix(value, "event="):
+		return loadTrigger{on: loadOnEvent, arg: strings.TrimPrefix(value, "event=")}, nil
 	}
-	return loadOn, loadOnArg, err
+	return loadTrigger{}, errors.New("invalid trigger: " + value)
+}
+
+// Inspect return value of s:enabled_if() function in plugconf.
+// The rhs of :return is a string literal, or a list literal of string
+// literals (the plugin is enabled when all of the conditions are satisfied).
+func getConditions(fn *ast.Function) ([]condition, error) {
+	var conds []condition
+	var err error
+	for _, value := range returnedStrings(fn) {
+		cond, e := parseCondition(value[1 : len(value)-1])
+		if e != nil {
+			err = fmt.Errorf("Invalid rhs of ':return': %s: %s", value, e.Error())
+			continue
+		}
+		conds = append(conds, cond)
+	}
+	if err == nil && len(conds) == 0 {
+		err = errors.New("can't detect return value of s:enabled_if()")
+	}
+	return conds, err
+}
+
+// Inspect return value of s:load_order() function in plugconf.
+// The rhs of :return is an integer literal (may be negative).
+func getLoadOrder(fn *ast.Function) (int, error) {
+	var order int
+	found := false
+	var err error
+	ast.Inspect(fn, func(node ast.Node) bool {
+		// Cast to return node (return if it's not a return node)
+		ret, ok := node.(*ast.Return)
+		if !ok {
+			return true
+		}
+
+		// Parse the argument of :return
+		rhs := ret.Result
+		sign := 1
+		if unary, ok := rhs.(*ast.UnaryExpr); ok && (unary.Op == token.MINUS || unary.Op == token.PLUS) {
+			if unary.Op == token.MINUS {
+				sign = -1
+			}
+			rhs = unary.X
+		}
+		lit, ok := rhs.(*ast.BasicLit)
+		if !ok || lit.Kind != token.NUMBER {
+			err = errors.New("s:load_order() must return integer literal")
+			return true
+		}
+		n, e := strconv.Atoi(lit.Value)
+		if e != nil {
+			err = errors.New("s:load_order() must return integer literal: " + lit.Value)
+			return true
+		}
+		order = sign * n
+		found = true
+		return true
+	})
+	if err == nil && !found {
+		err = errors.New("can't detect return value of s:load_order()")
+	}
+	return order, err
+}
+
+func parseCondition(value string) (condition, error) {
+	cond := condition{not: strings.HasPrefix(value, "!")}
+	value = strings.TrimPrefix(value, "!")
+	name := value
+	if i := strings.IndexByte(value, '='); i >= 0 {
+		name = value[:i]
+		cond.args = strings.Split(value[i+1:], ",")
+	}
+	cond.on = conditionType(name)
+	switch cond.on {
+	case conditionVim, conditionNvim, conditionGUI:
+		if cond.args != nil {
+			return condition{}, fmt.Errorf("'%s' does not take values", name)
+		}
+		return cond, nil
+	case conditionOS, conditionHas, conditionProfile:
+		for _, arg := range cond.args {
+			if arg == "" {
+				return condition{}, fmt.Errorf("'%s=' has an empty value", name)
+			}
+			if cond.on == conditionOS && osFeatures[arg] == "" {
+				return condition{}, fmt.Errorf("unknown OS '%s'", arg)
+			}
+		}
+		if cond.args != nil {
+			return cond, nil
+		}
+	}
+	return condition{}, errors.New("invalid condition: " + value)
+}
+
+// vimExpr returns Vim expression which is evaluated on startup.
+// It returns an empty string for the condition evaluated by "volt build".
+func (cond *condition) vimExpr() string {
+	var exprs []string
+	switch cond.on {
+	case conditionVim:
+		exprs = []string{"!has('nvim')"}
+	case conditionNvim:
+		exprs = []string{"has('nvim')"}
+	case conditionGUI:
+		exprs = []string{"has('gui_running')"}
+	case conditionOS:
+		for _, arg := range cond.args {
+			exprs = append(exprs, osFeatures[arg])
+		}
+	case conditionHas:
+		for _, arg := range cond.args {
+			exprs = append(exprs, "has('"+strings.Replace(arg, "'", "''", -1)+"')")
+		}
+	default:
+		return ""
+	}
+	expr := strings.Join(exprs, " || ")
+	if strings.Contains(expr, "||") {
+		expr = "(" + expr + ")"
+	}
+	if !cond.not {
+		return expr
+	}
+	if strings.HasPrefix(expr, "!") {
+		return expr[1:]
+	}
+	return "!" + expr
+}
+
+// enabledInProfile returns false if conds have "profile=<profiles>" which
+// profileName does not satisfy.
+func enabledInProfile(conds []condition, profileName string) bool {
+	for i := range conds {
+		if conds[i].on != conditionProfile {
+			continue
+		}
+		matched := false
+		for _, name := range conds[i].args {
+			if name == profileName {
+				matched = true
+				break
+			}
+		}
+		if matched == conds[i].not {
+			return false
+		}
+	}
+	return true
+}
+
+// isLoadedOnStart returns true if triggers have "start".
+func isLoadedOnStart(triggers []loadTrigger) bool {
+	for i := range triggers {
+		if triggers[i].on == loadOnStart {
+			return true
+		}
+	}
+	return false
 }
 
 // Returns true if fn.Body is empty or has only comment nodes
@@ -521,23 +871,41 @@ func getDependencies(fn *ast.Function) (pathutil.ReposPathList, error) {
 // $1 is a string before a function name.
 var rxFuncName = regexp.MustCompile(`\A(fu\w+!?\s+s:)(\w+)`)
 
-func convertToDecodableFunc(funcBody string, reposPath pathutil.ReposPath, reposID int) string {
-	// Change function name (e.g. s:loaded_on() -> s:loaded_on_1())
-	funcBody = rxFuncName.ReplaceAllString(funcBody, fmt.Sprintf("${1}${2}_%d", reposID))
-	// Add repos path as comment
-	funcBody = "\" " + reposPath.String() + "\n" + funcBody
-	return funcBody
+// Inspect return value of s:build() function in plugconf.
+func getBuildCmd(fn *ast.Function) (string, error) {
+	var cmd string
+	var err error
+	ast.Inspect(fn, func(node ast.Node) bool {
+		// Cast to return node (return if it's not a return node)
+		ret, ok := node.(*ast.Return)
+		if !ok {
+			return true
+		}
+
+		// Parse the argument of :return
+		rhs, ok := ret.Result.(*ast.BasicLit)
+		if !ok || rhs.Kind != token.STRING {
+			err = errors.New("s:build() must return string literal")
+			return true
+		}
+		cmd = rhs.Value[1 : len(rhs.Value)-1]
+		return true
+	})
+	if err == nil && cmd == "" {
+		err = errors.New("can't detect return value of s:build()")
+	}
+	return cmd, err
 }
 
-type reposDepTree struct {
-	// The nodes' dependTo are nil. These repos's ranks are always 0.
-	leaves []reposDepNode
+func convertToDecodableFunc(funcBody string, reposID int) string {
+	// Change function name (e.g. s:loaded_on() -> s:loaded_on_1())
+	return rxFuncName.ReplaceAllString(funcBody, fmt.Sprintf("${1}${2}_%d", reposID))
 }
 
-type reposDepNode struct {
-	repos      *lockjson.Repos
-	dependTo   []reposDepNode
-	dependedBy []reposDepNode
+// markSection surrounds content with the comments which show the beginning
+// and the end of name (source markers for debugging bundled plugconf).
+func markSection(name, content string) string {
+	return "\" >>> " + name + "\n" + content + "\n\" <<< " + name
 }
 
 // ParseMultiPlugconf parses plugconfs of given reposList.
@@ -546,7 +914,13 @@ func ParseMultiPlugconf(reposList []lockjson.Repos) (*MultiParsedInfo, MultiPars
 	if parseErr.HasErrs() {
 		return nil, parseErr
 	}
-	sortByDepends(reposList, plugconfMap)
+	sortByLoadOrder(reposList, plugconfMap)
+	if cycle := sortByDepends(reposList, plugconfMap); cycle != nil {
+		e := newParseError(cycle[0].Plugconf())
+		e.merr = multierror.Append(e.merr,
+			fmt.Errorf("dependency cycle detected: %s", strings.Join(cycle.Strings(), " -> ")))
+		return nil, append(parseErr, *e)
+	}
 	return &MultiParsedInfo{
 		plugconfMap: plugconfMap,
 		reposList:   reposList,
@@ -564,68 +938,223 @@ type MultiParsedInfo struct {
 
 // GenerateBundlePlugconf generates bundled plugconf content.
 // Generated content does not include s:loaded_on() function.
+// profileName is used to evaluate "profile=<profiles>" of s:enabled_if().
 // vimrcPath and gvimrcPath are fullpath of vimrc and gvimrc.
 // They become an empty string when each path does not exist.
-func (mp *MultiParsedInfo) GenerateBundlePlugconf(vimrcPath, gvimrcPath string) ([]byte, error) {
+// If mergeFtdetect is true, ftdetect scripts are not sourced when loading
+// plugins because they are sourced by bundled ftdetect
+// (see GenerateBundleFtdetect).
+func (mp *MultiParsedInfo) GenerateBundlePlugconf(profileName, vimrcPath, gvimrcPath string, mergeFtdetect bool) ([]byte, error) {
+	return mp.generatePlugconf("bundled", profileName, vimrcPath, gvimrcPath, mergeFtdetect)
+}
+
+// GenerateProjectPlugconf generates the plugconf content of the plugins of a
+// project (see "volt env"), which is sourced after bundled plugconf.
+// It is the same as bundled plugconf except the names of the guard variable
+// and the augroup.
+func (mp *MultiParsedInfo) GenerateProjectPlugconf(profileName string) ([]byte, error) {
+	return mp.generatePlugconf("project", profileName, "", "", false)
+}
+
+// generatePlugconf generates plugconf content. kind is the name of the
+// content used in the guard variable and the augroup.
+func (mp *MultiParsedInfo) generatePlugconf(kind, profileName, vimrcPath, gvimrcPath string, mergeFtdetect bool) ([]byte, error) {
 	functions := make([]string, 0, 64)
 	loadCmds := make([]string, 0, len(mp.reposList))
 	lazyExcmd := make(map[string]string, len(mp.reposList))
+	lazyPlugins := make(map[string]string, len(mp.reposList))
+	conds, disabled := mp.conditions(profileName)
+	loadedOnStart := mp.loadedOnStart(disabled)
+	usesPackaddFunc := false
+	usesLoadAfterFunc := false
 
 	for _, repos := range mp.reposList {
+		if disabled[repos.Path] {
+			continue
+		}
 		p, hasPlugconf := mp.plugconfMap[repos.Path]
 		// :packadd <repos>
 		optName := filepath.Base(repos.Path.EncodeToPlugDirName())
 		packadd := fmt.Sprintf("packadd %s", optName)
+		if mergeFtdetect && len(ftdetectFiles(repos.Path, "ftdetect")) > 0 {
+			packadd = fmt.Sprintf("call %s('%s')", packaddFunc, optName)
+			usesPackaddFunc = true
+		}
+		// :packadd does not source after/plugin (Vim sources it on startup
+		// only) and after/ftdetect scripts of the plugin
+		sourcePlugin := !loadedOnStart[repos.Path] && hasAfterPlugin(repos.Path)
+		sourceFtdetect := !mergeFtdetect && len(ftdetectFiles(repos.Path, "after/ftdetect")) > 0
+		if sourcePlugin || sourceFtdetect {
+			afterDir := strings.Replace(pathutil.RuntimePath(filepath.Join(repos.Path.EncodeToPlugDirName(), "after")), "'", "''", -1)
+			packadd += fmt.Sprintf(" | call %s('%s', %d, %d)", loadAfterFunc, afterDir, boolToInt(sourcePlugin), boolToInt(sourceFtdetect))
+			usesLoadAfterFunc = true
+		}
 
 		// s:on_load_pre(), invoked command, s:on_load_post()
 		var invokedCmd string
+		reposFuncs := make([]string, 0, 4)
 		if hasPlugconf {
 			cmds := make([]string, 0, 3)
 			if p.onLoadPreFunc != "" {
-				functions = append(functions, convertToDecodableFunc(p.onLoadPreFunc, p.reposPath, p.reposID))
+				reposFuncs = append(reposFuncs, convertToDecodableFunc(p.onLoadPreFunc, p.reposID))
 				cmds = append(cmds, fmt.Sprintf("call s:on_load_pre_%d()", p.reposID))
 			}
 			cmds = append(cmds, packadd)
 			if p.onLoadPostFunc != "" {
-				functions = append(functions, convertToDecodableFunc(p.onLoadPostFunc, p.reposPath, p.reposID))
+				reposFuncs = append(reposFuncs, convertToDecodableFunc(p.onLoadPostFunc, p.reposID))
 				cmds = append(cmds, fmt.Sprintf("call s:on_load_post_%d()", p.reposID))
 			}
 			invokedCmd = strings.Join(cmds, " | ")
 		} else {
 			invokedCmd = packadd
 		}
+		// Lua plugconf is executed after the plugin is loaded (Neovim only)
+		if pathutil.Exists(repos.Path.LuaPlugconf()) {
+			luaPath := strings.Replace(pathutil.RuntimePath(repos.Path.BundledLuaPlugconf()), "'", "''", -1)
+			invokedCmd += fmt.Sprintf(" | if has('nvim') | call luaeval('dofile(_A)', '%s') | endif", luaPath)
+		}
 
 		// Bootstrap statements
-		switch {
-		case !hasPlugconf || p.loadOn == loadOnStart:
-			loadCmds = append(loadCmds, "  "+invokedCmd)
-		case p.loadOn == loadOnFileType:
-			loadCmds = append(loadCmds,
-				fmt.Sprintf("  autocmd %s %s %s", string(p.loadOn), p.loadOnArg, invokedCmd))
-		case p.loadOn == loadOnExcmd:
-			// Define dummy Ex commands
-			for _, excmd := range strings.Split(p.loadOnArg, ",") {
-				lazyExcmd[excmd] = invokedCmd
-				loadCmds = append(loadCmds,
-					fmt.Sprintf("  command -complete=customlist,%[1]s -bang -bar -range -nargs=* %[3]s call %[2]s('%[3]s', <q-args>, expand('<bang>'), expand('<line1>'), expand('<line2>'))", completeFunc, lazyLoadExcmdFunc, excmd))
+		reposLoadCmds := make([]string, 0, 4)
+		if loadedOnStart[repos.Path] {
+			reposLoadCmds = append(reposLoadCmds, invokedCmd)
+		} else {
+			// The plugin is loaded once by the first trigger, after the
+			// lazy-loaded plugins which it depends on
+			cmds := make([]string, 0, len(p.depends)+1)
+			for _, dep := range p.depends {
+				if _, exists := lazyPlugins[dep.String()]; exists {
+					cmds = append(cmds, fmt.Sprintf("call %s('%s')", lazyLoadFunc, dep))
+				}
+			}
+			lazyPlugins[repos.Path.String()] = strings.Join(append(cmds, invokedCmd), " | ")
+			loadPlugin := fmt.Sprintf("call %s('%s')", lazyLoadFunc, repos.Path)
+			for _, trigger := range p.loadOn {
+				switch trigger.on {
+				case loadOnFileType:
+					reposLoadCmds = append(reposLoadCmds,
+						fmt.Sprintf("autocmd %s %s %s", loadOnFileType, trigger.arg, loadPlugin))
+				case loadOnEvent:
+					reposLoadCmds = append(reposLoadCmds,
+						fmt.Sprintf("autocmd %s * %s", trigger.arg, loadPlugin))
+				case loadOnExcmd:
+					// Define dummy Ex commands
+					for _, excmd := range strings.Split(trigger.arg, ",") {
+						lazyExcmd[excmd] = loadPlugin
+						reposLoadCmds = append(reposLoadCmds,
+							fmt.Sprintf("command -complete=customlist,%[1]s -bang -bar -range -nargs=* %[3]s call %[2]s('%[3]s', <q-args>, expand('<bang>'), expand('<line1>'), expand('<line2>'))", completeFunc, lazyLoadExcmdFunc, excmd))
+					}
+				case loadOnMapping:
+					// Define dummy mappings in Normal and Visual mode
+					for _, lhs := range strings.Split(trigger.arg, ",") {
+						arg := strings.NewReplacer("<", "<lt>", "|", "<Bar>", "'", "''").Replace(lhs)
+						for _, mode := range []string{"n", "x"} {
+							reposLoadCmds = append(reposLoadCmds,
+								fmt.Sprintf("%snoremap <silent> %s :<C-u>call <SID>%s('%s', '%s', '%s')<CR>", mode, lhs, strings.TrimPrefix(lazyLoadMappingFunc, "s:"), repos.Path, arg, mode))
+						}
+					}
+				}
+			}
+		}
+
+		// Load the plugin only in the environment where it is enabled
+		if expr := conds[repos.Path]; expr != "" {
+			loadCmds = append(loadCmds, "  if "+expr)
+			for _, cmd := range reposLoadCmds {
+				loadCmds = append(loadCmds, "    "+cmd)
+			}
+			loadCmds = append(loadCmds, "  endif")
+		} else {
+			for _, cmd := range reposLoadCmds {
+				loadCmds = append(loadCmds, "  "+cmd)
 			}
 		}
 
 		// User defined functions in plugconf
 		if hasPlugconf {
-			functions = append(functions, p.functions...)
+			reposFuncs = append(reposFuncs, p.functions...)
+		}
+		if len(reposFuncs) > 0 {
+			functions = append(functions,
+				markSection(repos.Path.Plugconf(), strings.Join(reposFuncs, "\n\n")))
 		}
 	}
 
 	var buf bytes.Buffer
-	buf.WriteString(`if exists('g:loaded_volt_system_bundled_plugconf')
+	buf.WriteString(`" This file is generated by "volt build" from plugconf files.
+" The code from each file is between ">>> {file}" and "<<< {file}" comments.
+
+if exists('g:loaded_volt_system_` + kind + `_plugconf')
   finish
 endif
-let g:loaded_volt_system_bundled_plugconf = 1`)
+let g:loaded_volt_system_` + kind + `_plugconf = 1`)
 	if len(functions) > 0 {
 		buf.WriteString("\n\n")
 		buf.WriteString(strings.Join(functions, "\n\n"))
 	}
+	if usesPackaddFunc {
+		// :packadd sources ftdetect scripts only if g:did_load_filetypes
+		// exists
+		buf.WriteString("\n\n" + markSection("volt: bundled ftdetect", `function `+packaddFunc+`(name) abort
+  if !exists('g:did_load_filetypes')
+    execute 'packadd' a:name
+    return
+  endif
+  let did_load_filetypes = g:did_load_filetypes
+  unlet g:did_load_filetypes
+  try
+    execute 'packadd' a:name
+  finally
+    let g:did_load_filetypes = did_load_filetypes
+  endtry
+endfunction`))
+	}
+	if usesLoadAfterFunc {
+		buf.WriteString("\n\n" + markSection("volt: after directory", `function `+loadAfterFunc+`(dir, plugin, ftdetect) abort
+  let exts = has('nvim') ? ['vim', 'lua'] : ['vim']
+  if a:plugin
+    for ext in exts
+      for file in glob(a:dir . '/plugin/**/*.' . ext, 1, 1)
+        execute 'source' fnameescape(file)
+      endfor
+    endfor
+  endif
+  if a:ftdetect && exists('g:did_load_filetypes')
+    augroup filetypedetect
+      for ext in exts
+        for file in glob(a:dir . '/ftdetect/*.' . ext, 1, 1)
+          execute 'source' fnameescape(file)
+        endfor
+      endfor
+    augroup END
+  endif
+endfunction`))
+	}
+	if len(lazyPlugins) > 0 {
+		lazyPluginsJSON, err := json.Marshal(lazyPlugins)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString("\n\n" + markSection("volt: lazy loading", `let `+lazyLoadPlugins+` = `+string(lazyPluginsJSON)+`
+
+function `+lazyLoadFunc+`(repos) abort
+  if has_key(`+lazyLoadPlugins+`, a:repos)
+    execute remove(`+lazyLoadPlugins+`, a:repos)
+  endif
+endfunction
+
+function `+lazyLoadMappingFunc+`(repos, lhs, mode) abort
+  for mode in ['n', 'x']
+    silent! execute mode . 'unmap' a:lhs
+  endfor
+  call `+lazyLoadFunc+`(a:repos)
+  if a:mode is# 'x'
+    call feedkeys('gv', 'n')
+  endif
+  let lhs = substitute(a:lhs, '\c<Leader>', escape(get(g:, 'mapleader', '\'), '\'), 'g')
+  call feedkeys(eval('"' . substitute(escape(lhs, '\"'), '<', '\\<', 'g') . '"'), 'm')
+endfunction`))
+	}
 	if len(lazyExcmd) > 0 {
 		lazyExcmdJSON, err := json.Marshal(lazyExcmd)
 		if err != nil {
@@ -635,15 +1164,13 @@ let g:loaded_volt_system_bundled_plugconf = 1`)
 		//   https://github.com/Shougo/dein.vim/blob/2adba7655b23f2fc1ddcd35e15d380c5069a3712/autoload/dein/autoload.vim#L157-L175
 		// * dein#autoload#_dummy_complete()
 		//   https://github.com/Shougo/dein.vim/blob/2adba7655b23f2fc1ddcd35e15d380c5069a3712/autoload/dein/autoload.vim#L216-L232
-		buf.WriteString(`
-
-let ` + excmdLoadPlugin + ` = ` + string(lazyExcmdJSON) + `
+		buf.WriteString("\n\n" + markSection("volt: lazy loading of Ex commands", `let `+excmdLoadPlugin+` = `+string(lazyExcmdJSON)+`
 
-function ` + lazyLoadExcmdFunc + `(command, args, bang, line1, line2) abort
+function `+lazyLoadExcmdFunc+`(command, args, bang, line1, line2) abort
   if exists(':' . a:command) is# 2
     execute 'delcommand' a:command
   endif
-  execute get(` + excmdLoadPlugin + `, a:command, '')
+  execute get(`+excmdLoadPlugin+`, a:command, '')
   if exists(':' . a:command) isnot# 2
     echohl ErrorMsg
     echomsg printf('[volt] Lazy loading of Ex command ''%s'' failed: ''%s'' is not found', a:command, a:command)
@@ -661,43 +1188,221 @@ function ` + lazyLoadExcmdFunc + `(command, args, bang, line1, line2) abort
   endtry
 endfunction
 
-function ` + completeFunc + `(arglead, cmdline, cursorpos) abort
+function `+completeFunc+`(arglead, cmdline, cursorpos) abort
   let command = matchstr(a:cmdline, '\h\w*')
   if exists(':' . command) is# 2
     execute 'delcommand' command
   endif
-  execute get(` + excmdLoadPlugin + `, command, '')
+  execute get(`+excmdLoadPlugin+`, command, '')
   if exists(':' . command) is# 2
     call feedkeys("\<C-d>", 'n')
   endif
   return [a:arglead]
-endfunction
-`)
+endfunction`))
 	}
 	if len(loadCmds) > 0 {
-		buf.WriteString("\n\n")
-		buf.WriteString(`augroup volt-bundled-plugconf
-  autocmd!
-`)
-		buf.WriteString(strings.Join(loadCmds, "\n"))
-		buf.WriteString("\naugroup END")
+		buf.WriteString("\n\n" + markSection("volt: loading plugins",
+			"augroup volt-"+kind+"-plugconf\n  autocmd!\n"+strings.Join(loadCmds, "\n")+"\naugroup END"))
 	}
 
 	if vimrcPath != "" || gvimrcPath != "" {
-		buf.WriteString("\n\n")
+		rcCmds := make([]string, 0, 2)
 		if vimrcPath != "" {
 			vimrcPath = strings.Replace(vimrcPath, "'", "''", -1)
-			buf.WriteString("let $MYVIMRC = '" + vimrcPath + "'")
+			rcCmds = append(rcCmds, "let $MYVIMRC = '"+vimrcPath+"'")
 		}
 		if gvimrcPath != "" {
 			gvimrcPath = strings.Replace(gvimrcPath, "'", "''", -1)
-			buf.WriteString("let $MYGVIMRC = '" + gvimrcPath + "'")
+			rcCmds = append(rcCmds, "let $MYGVIMRC = '"+gvimrcPath+"'")
+		}
+		buf.WriteString("\n\n" + markSection("volt: vimrc and gvimrc", strings.Join(rcCmds, "\n")))
+	}
+
+	return buf.Bytes(), nil
+}
+
+// rxNotMergeable is a pattern which matches to ftdetect scripts which cannot
+// be merged into bundled ftdetect (they depend on the script path, or finish
+// the script).
+var rxNotMergeable = regexp.MustCompile(`<sfile>|<script>|(?m)(^|\|)\s*fini(s|sh)?\s*($|\|)`)
+
+// GenerateBundleFtdetect generates bundled ftdetect content, which has
+// ftdetect scripts of all plugins enabled in profileName, to source them at
+// once on startup. It returns nil if no plugin has ftdetect scripts.
+// The scripts which cannot be merged are sourced from the files.
+func (mp *MultiParsedInfo) GenerateBundleFtdetect(profileName string) ([]byte, error) {
+	conds, disabled := mp.conditions(profileName)
+	sections := make([]string, 0, 16)
+	for _, repos := range mp.reposList {
+		if disabled[repos.Path] {
+			continue
 		}
+		scripts := make([]string, 0, 4)
+		files := append(ftdetectFiles(repos.Path, "ftdetect"), ftdetectFiles(repos.Path, "after/ftdetect")...)
+		for _, file := range files {
+			path := pathutil.RuntimePath(file)
+			quoted := strings.Replace(path, "'", "''", -1)
+			if strings.HasSuffix(file, ".lua") {
+				scripts = append(scripts, markSection(path,
+					fmt.Sprintf("if has('nvim')\n  call luaeval('dofile(_A)', '%s')\nendif", quoted)))
+				continue
+			}
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			if rxNotMergeable.Match(content) {
+				scripts = append(scripts, markSection(path, "execute 'source' fnameescape('"+quoted+"')"))
+				continue
+			}
+			scripts = append(scripts, markSection(path, strings.TrimRight(string(content), "\n")))
+		}
+		if len(scripts) == 0 {
+			continue
+		}
+		section := strings.Join(scripts, "\n\n")
+		if expr := conds[repos.Path]; expr != "" {
+			section = "if " + expr + "\n" + section + "\nendif"
+		}
+		sections = append(sections, section)
 	}
+	if len(sections) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`" This file is generated by "volt build" from ftdetect scripts of plugins.
+" The code from each file is between ">>> {file}" and "<<< {file}" comments.
 
+`)
+	buf.WriteString(strings.Join(sections, "\n\n"))
+	buf.WriteString("\n")
 	return buf.Bytes(), nil
 }
 
+// ftdetectFiles returns ftdetect scripts (*.vim and *.lua) in subdir
+// ("ftdetect" or "after/ftdetect") of reposPath installed in vim dir.
+func ftdetectFiles(reposPath pathutil.ReposPath, subdir string) []string {
+	dir := filepath.Join(reposPath.EncodeToPlugDirName(), filepath.FromSlash(subdir))
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	files := make([]string, 0, len(infos))
+	for _, fi := range infos {
+		ext := filepath.Ext(fi.Name())
+		if !fi.IsDir() && (ext == ".vim" || ext == ".lua") {
+			files = append(files, filepath.Join(dir, fi.Name()))
+		}
+	}
+	return files
+}
+
+// hasAfterPlugin returns true if reposPath installed in vim dir has plugin
+// scripts (*.vim and *.lua) in after/plugin.
+func hasAfterPlugin(reposPath pathutil.ReposPath) bool {
+	found := false
+	dir := filepath.Join(reposPath.EncodeToPlugDirName(), "after", "plugin")
+	filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || found {
+			return filepath.SkipDir
+		}
+		ext := filepath.Ext(path)
+		if !fi.IsDir() && (ext == ".vim" || ext == ".lua") {
+			found = true
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return found
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// conditions returns Vim expressions of s:enabled_if() evaluated on startup,
+// and the plugins disabled in profileName.
+// The conditions of a plugin include the conditions of the plugins which it
+// depends on, and the plugin is disabled if they are disabled.
+func (mp *MultiParsedInfo) conditions(profileName string) (map[pathutil.ReposPath]string, map[pathutil.ReposPath]bool) {
+	exprs := make(map[pathutil.ReposPath][]string, len(mp.reposList))
+	disabled := make(map[pathutil.ReposPath]bool, len(mp.reposList))
+	// Visit the plugins which are depended on first (reposList is sorted by
+	// sortByDepends)
+	for _, repos := range mp.reposList {
+		p, hasPlugconf := mp.plugconfMap[repos.Path]
+		if !hasPlugconf {
+			continue
+		}
+		if !enabledInProfile(p.enabledIf, profileName) {
+			disabled[repos.Path] = true
+		}
+		var reposExprs []string
+		for _, dep := range p.depends {
+			if disabled[dep] {
+				disabled[repos.Path] = true
+			}
+			reposExprs = appendUniq(reposExprs, exprs[dep]...)
+		}
+		for i := range p.enabledIf {
+			if expr := p.enabledIf[i].vimExpr(); expr != "" {
+				reposExprs = appendUniq(reposExprs, expr)
+			}
+		}
+		exprs[repos.Path] = reposExprs
+	}
+	result := make(map[pathutil.ReposPath]string, len(exprs))
+	for reposPath := range exprs {
+		result[reposPath] = strings.Join(exprs[reposPath], " && ")
+	}
+	return result, disabled
+}
+
+func appendUniq(list []string, values ...string) []string {
+	for _, value := range values {
+		exists := false
+		for i := range list {
+			if list[i] == value {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// loadedOnStart returns the plugins loaded on start: the plugins which do not
+// have lazy-loading triggers, and the plugins which they depend on.
+// The plugins in disabled are excluded.
+func (mp *MultiParsedInfo) loadedOnStart(disabled map[pathutil.ReposPath]bool) map[pathutil.ReposPath]bool {
+	result := make(map[pathutil.ReposPath]bool, len(mp.reposList))
+	// Visit the plugins which depend on others first (reposList is sorted
+	// by sortByDepends)
+	for i := len(mp.reposList) - 1; i >= 0; i-- {
+		reposPath := mp.reposList[i].Path
+		if disabled[reposPath] {
+			continue
+		}
+		p, hasPlugconf := mp.plugconfMap[reposPath]
+		if !hasPlugconf || isLoadedOnStart(p.loadOn) {
+			result[reposPath] = true
+		}
+		if result[reposPath] && hasPlugconf {
+			for _, dep := range p.depends {
+				result[dep] = true
+			}
+		}
+	}
+	return result
+}
+
 // Each iterates each repository by given func.
 func (mp *MultiParsedInfo) Each(f func(pathutil.ReposPath, *ParsedInfo)) {
 	for reposPath, info := range mp.plugconfMap {
@@ -743,20 +1448,65 @@ func parsePlugconfAsMap(reposList []lockjson.Repos) (map[pathutil.ReposPath]*Par
 }
 
 // Move the plugins which was depended to previous plugin which depends to them.
-// reposList is sorted in-place.
-func sortByDepends(reposList []lockjson.Repos, plugconfMap map[pathutil.ReposPath]*ParsedInfo) {
-	reposMap, depsMap, rdepsMap := getDepMaps(reposList, plugconfMap)
-	rank := make(map[pathutil.ReposPath]int, len(reposList))
-	for i := range reposList {
-		if _, exists := rank[reposList[i].Path]; !exists {
-			tree := makeDepTree(reposList[i].Path, reposMap, depsMap, rdepsMap)
-			for i := range tree.leaves {
-				makeRank(rank, &tree.leaves[i], 0)
+// The order of the other plugins is kept. reposList is sorted in-place.
+// If the dependencies have a cycle, reposList is not sorted and the cycle
+// (e.g. [a, b, a]) is returned.
+func sortByDepends(reposList []lockjson.Repos, plugconfMap map[pathutil.ReposPath]*ParsedInfo) pathutil.ReposPathList {
+	reposMap, depsMap, _ := getDepMaps(reposList, plugconfMap)
+	sorted := make([]lockjson.Repos, 0, len(reposList))
+	visited := make(map[pathutil.ReposPath]bool, len(reposList))
+	// visiting is the path of dependencies from a plugin to current plugin
+	visiting := make(pathutil.ReposPathList, 0, 8)
+
+	var visit func(reposPath pathutil.ReposPath) pathutil.ReposPathList
+	visit = func(reposPath pathutil.ReposPath) pathutil.ReposPathList {
+		if visited[reposPath] {
+			return nil
+		}
+		for i := range visiting {
+			if visiting[i] == reposPath {
+				cycle := append(pathutil.ReposPathList{}, visiting[i:]...)
+				return append(cycle, reposPath)
+			}
+		}
+		visiting = append(visiting, reposPath)
+		for _, dep := range depsMap[reposPath] {
+			// Skip the dependency which is not in reposList
+			if _, exists := reposMap[dep]; !exists {
+				continue
 			}
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		visiting = visiting[:len(visiting)-1]
+		visited[reposPath] = true
+		sorted = append(sorted, *reposMap[reposPath])
+		return nil
+	}
+
+	for i := range reposList {
+		if cycle := visit(reposList[i].Path); cycle != nil {
+			return cycle
 		}
 	}
-	sort.Slice(reposList, func(i, j int) bool {
-		return rank[reposList[i].Path] < rank[reposList[j].Path]
+	copy(reposList, sorted)
+	return nil
+}
+
+// sortByLoadOrder sorts reposList by s:load_order() (ascending order).
+// The order of the plugins which have the same value is not changed.
+// This must be done before sortByDepends, which moves the plugins which are
+// depended on before the plugins which depend on them.
+func sortByLoadOrder(reposList []lockjson.Repos, plugconfMap map[pathutil.ReposPath]*ParsedInfo) {
+	loadOrder := func(reposPath pathutil.ReposPath) int {
+		if p, exists := plugconfMap[reposPath]; exists {
+			return p.loadOrder
+		}
+		return 0
+	}
+	sort.SliceStable(reposList, func(i, j int) bool {
+		return loadOrder(reposList[i].Path) < loadOrder(reposList[j].Path)
 	})
 }
 
@@ -777,68 +1527,24 @@ func getDepMaps(reposList []lockjson.Repos, plugconfMap map[pathutil.ReposPath]*
 	return reposMap, depsMap, rdepsMap
 }
 
-func makeDepTree(reposPath pathutil.ReposPath, reposMap map[pathutil.ReposPath]*lockjson.Repos, depsMap map[pathutil.ReposPath]pathutil.ReposPathList, rdepsMap map[pathutil.ReposPath]pathutil.ReposPathList) *reposDepTree {
-	visitedNodes := make(map[pathutil.ReposPath]*reposDepNode, len(reposMap))
-	node := makeNodes(reposPath, reposMap, depsMap, rdepsMap, visitedNodes)
-	leaves := make([]reposDepNode, 0, 10)
-	visitedMarks := make(map[pathutil.ReposPath]bool, 10)
-	visitNode(node, func(n *reposDepNode) {
-		if len(n.dependTo) == 0 {
-			leaves = append(leaves, *n)
-		}
-	}, visitedMarks)
-	return &reposDepTree{leaves: leaves}
-}
-
-func makeNodes(reposPath pathutil.ReposPath, reposMap map[pathutil.ReposPath]*lockjson.Repos, depsMap map[pathutil.ReposPath]pathutil.ReposPathList, rdepsMap map[pathutil.ReposPath]pathutil.ReposPathList, visited map[pathutil.ReposPath]*reposDepNode) *reposDepNode {
-	if node, exists := visited[reposPath]; exists {
-		return node
-	}
-	node := &reposDepNode{repos: reposMap[reposPath]}
-	visited[reposPath] = node
-	for i := range depsMap[reposPath] {
-		dep := makeNodes(depsMap[reposPath][i], reposMap, depsMap, rdepsMap, visited)
-		node.dependTo = append(node.dependTo, *dep)
-	}
-	for i := range rdepsMap[reposPath] {
-		rdep := makeNodes(rdepsMap[reposPath][i], reposMap, depsMap, rdepsMap, visited)
-		node.dependedBy = append(node.dependedBy, *rdep)
-	}
-	return node
-}
-
-func visitNode(node *reposDepNode, callback func(*reposDepNode), visited map[pathutil.ReposPath]bool) {
-	if node == nil || node.repos == nil || visited[node.repos.Path] {
-		return
-	}
-	visited[node.repos.Path] = true
-	callback(node)
-	for i := range node.dependTo {
-		visitNode(&node.dependTo[i], callback, visited)
-	}
-	for i := range node.dependedBy {
-		visitNode(&node.dependedBy[i], callback, visited)
-	}
-}
-
-func makeRank(rank map[pathutil.ReposPath]int, node *reposDepNode, value int) {
-	rank[node.repos.Path] = value
-	for i := range node.dependedBy {
-		makeRank(rank, &node.dependedBy[i], value+1)
-	}
-}
-
 // Template is a content of plugconf template.
 type Template struct {
 	template []byte
 }
 
-// FetchPlugconfTemplate fetches reposPath's plugconf from vim-volt/plugconf-templates
-// repository.
-// Fetched URL: https://raw.githubusercontent.com/vim-volt/plugconf-templates/master/templates/{reposPath}.vim
-func FetchPlugconfTemplate(reposPath pathutil.ReposPath) (*Template, error) {
-	url := path.Join("https://raw.githubusercontent.com/vim-volt/plugconf-templates/master/templates", reposPath.String()+".vim")
-	content, err := httputil.GetContent(url)
+// FetchPlugconfTemplate fetches reposPath's plugconf template from
+// templateURL, which is http(s) URL or local directory of templates
+// (e.g. vim-volt/plugconf-templates repository).
+// Fetched URL: {templateURL}/{reposPath}.vim
+func FetchPlugconfTemplate(reposPath pathutil.ReposPath, templateURL string) (*Template, error) {
+	var content []byte
+	var err error
+	if strings.HasPrefix(templateURL, "http://") || strings.HasPrefix(templateURL, "https://") {
+		// Not retried because skeleton plugconf is created when failed
+		content, err = httputil.TryGetContent(strings.TrimSuffix(templateURL, "/") + "/" + reposPath.String() + ".vim")
+	} else {
+		content, err = ioutil.ReadFile(filepath.Join(templateURL, filepath.FromSlash(reposPath.String())+".vim"))
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -862,17 +1568,23 @@ const skeletonPlugconfLoadOn = `function! s:loaded_on()
   " * 'start' (a plugin will be loaded at VimEnter event)
   " * 'filetype=<filetypes>' (a plugin will be loaded at FileType event)
   " * 'excmd=<excmds>' (a plugin will be loaded at CmdUndefined event)
-  " <filetypes> and <excmds> can be multiple values separated by comma.
+  " * 'mapping=<lhs>' (a plugin will be loaded when <lhs> is typed in Normal
+  "   or Visual mode)
+  " * 'event=<events>' (a plugin will be loaded at <events> autocmd events)
+  " <filetypes>, <excmds>, <lhs>, and <events> can be multiple values
+  " separated by comma.
   "
-  " This function must contain 'return "<str>"' code.
-  " (the argument of :return must be string literal)
+  " This function must contain 'return "<str>"' code, or
+  " 'return ["<str>", ...]' code to load a plugin at the first one of them.
+  " (the argument of :return must be string literal, or list literal of
+  " string literals)
 
   return 'start'
 endfunction`
 
 const skeletonPlugconfDepends = `function! s:depends()
   " Dependencies of this plugin.
-  " The specified dependencies are loaded after this plugin is loaded.
+  " The specified dependencies are loaded before this plugin is loaded.
   "
   " This function must contain 'return [<repos>, ...]' code.
   " (the argument of :return must be list literal, and the elements are string)
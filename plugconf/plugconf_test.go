@@ -0,0 +1,227 @@
+package plugconf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/haya14busa/go-vimlparser"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func TestParseLoadedOn(t *testing.T) {
+	var tests = []struct {
+		ret      string
+		triggers []loadTrigger
+		err      bool
+	}{
+		{`'start'`, []loadTrigger{{on: loadOnStart}}, false},
+		{`'filetype=vim,go'`, []loadTrigger{{on: loadOnFileType, arg: "vim,go"}}, false},
+		{`'excmd=Foo'`, []loadTrigger{{on: loadOnExcmd, arg: "Foo"}}, false},
+		{`'mapping=<Plug>(foo)'`, []loadTrigger{{on: loadOnMapping, arg: "<Plug>(foo)"}}, false},
+		{`'event=InsertEnter'`, []loadTrigger{{on: loadOnEvent, arg: "InsertEnter"}}, false},
+		{`['filetype=vim', 'mapping=<Leader>f']`, []loadTrigger{{on: loadOnFileType, arg: "vim"}, {on: loadOnMapping, arg: "<Leader>f"}}, false},
+		{`'foo=bar'`, nil, true},
+		{`['start', 'foo']`, nil, true},
+	}
+	for _, tt := range tests {
+		src := "function! s:loaded_on()\n  return " + tt.ret + "\nendfunction\n"
+		file, err := vimlparser.ParseFile(strings.NewReader(src), "test.vim", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, parseErr := ParsePlugconf(file, []byte(src), "test.vim")
+		if tt.err {
+			if !parseErr.HasErrs() {
+				t.Errorf("ret:%s, expected error but got nil", tt.ret)
+			}
+			continue
+		}
+		if parseErr.HasErrs() {
+			t.Errorf("ret:%s, err:%s", tt.ret, parseErr.Errors())
+			continue
+		}
+		if !reflect.DeepEqual(result.loadOn, tt.triggers) {
+			t.Errorf("ret:%s, got:%v, expected:%v", tt.ret, result.loadOn, tt.triggers)
+		}
+		// LoadOn() returns the values which are parsed to the same triggers
+		for i, value := range result.LoadOn() {
+			if trigger, err := parseLoadTrigger(value); err != nil || trigger != tt.triggers[i] {
+				t.Errorf("ret:%s, LoadOn()[%d]:%s, expected:%v", tt.ret, i, value, tt.triggers[i])
+			}
+		}
+	}
+}
+
+func TestParseEnabledIf(t *testing.T) {
+	var tests = []struct {
+		ret     string
+		exprs   []string
+		profile string
+		enabled bool
+		err     bool
+	}{
+		{`'nvim'`, []string{"has('nvim')"}, "default", true, false},
+		{`'!gui'`, []string{"!has('gui_running')"}, "default", true, false},
+		{`'!vim'`, []string{"has('nvim')"}, "default", true, false},
+		{`'!os=mac'`, []string{"!(has('mac') || has('macunix'))"}, "default", true, false},
+		{`'os=mac,windows'`, []string{"(has('mac') || has('macunix') || has('win32'))"}, "default", true, false},
+		{`['vim', 'has=python3']`, []string{"!has('nvim')", "has('python3')"}, "default", true, false},
+		{`'profile=work,home'`, []string{""}, "home", true, false},
+		{`'profile=work'`, []string{""}, "default", false, false},
+		{`'!profile=work'`, []string{""}, "work", false, false},
+		{`'os=beos'`, nil, "", false, true},
+		{`'nvim=1'`, nil, "", false, true},
+		{`'has='`, nil, "", false, true},
+		{`'foo'`, nil, "", false, true},
+	}
+	for _, tt := range tests {
+		src := "function! s:enabled_if()\n  return " + tt.ret + "\nendfunction\n"
+		file, err := vimlparser.ParseFile(strings.NewReader(src), "test.vim", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, parseErr := ParsePlugconf(file, []byte(src), "test.vim")
+		if tt.err {
+			if !parseErr.HasErrs() {
+				t.Errorf("ret:%s, expected error but got nil", tt.ret)
+			}
+			continue
+		}
+		if parseErr.HasErrs() {
+			t.Errorf("ret:%s, err:%s", tt.ret, parseErr.Errors())
+			continue
+		}
+		exprs := make([]string, 0, len(result.enabledIf))
+		for i := range result.enabledIf {
+			exprs = append(exprs, result.enabledIf[i].vimExpr())
+		}
+		if !reflect.DeepEqual(exprs, tt.exprs) {
+			t.Errorf("ret:%s, got:%v, expected:%v", tt.ret, exprs, tt.exprs)
+		}
+		if enabled := enabledInProfile(result.enabledIf, tt.profile); enabled != tt.enabled {
+			t.Errorf("ret:%s, profile:%s, got enabled:%v, expected:%v", tt.ret, tt.profile, enabled, tt.enabled)
+		}
+	}
+}
+
+func TestParseLoadOrder(t *testing.T) {
+	var tests = []struct {
+		ret   string
+		order int
+		err   bool
+	}{
+		{`10`, 10, false},
+		{`-10`, -10, false},
+		{`+1`, 1, false},
+		{`'early'`, 0, true},
+		{`g:order`, 0, true},
+	}
+	for _, tt := range tests {
+		src := "function! s:load_order()\n  return " + tt.ret + "\nendfunction\n"
+		file, err := vimlparser.ParseFile(strings.NewReader(src), "test.vim", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, parseErr := ParsePlugconf(file, []byte(src), "test.vim")
+		if tt.err {
+			if !parseErr.HasErrs() {
+				t.Errorf("ret:%s, expected error but got nil", tt.ret)
+			}
+			continue
+		}
+		if parseErr.HasErrs() {
+			t.Errorf("ret:%s, err:%s", tt.ret, parseErr.Errors())
+			continue
+		}
+		if result.loadOrder != tt.order {
+			t.Errorf("ret:%s, got:%d, expected:%d", tt.ret, result.loadOrder, tt.order)
+		}
+	}
+}
+
+func TestSortByDepends(t *testing.T) {
+	var tests = []struct {
+		repos   []string
+		depends map[string][]string
+		order   map[string]int
+		sorted  []string
+		cycle   []string
+	}{
+		{
+			repos:  []string{"a", "b", "c"},
+			sorted: []string{"a", "b", "c"},
+		},
+		{
+			repos:   []string{"a", "b", "c"},
+			depends: map[string][]string{"a": {"c"}},
+			sorted:  []string{"c", "a", "b"},
+		},
+		{
+			repos:   []string{"a", "b", "c", "d"},
+			depends: map[string][]string{"a": {"b"}, "b": {"d"}, "c": {"missing"}},
+			sorted:  []string{"d", "b", "a", "c"},
+		},
+		{
+			repos:   []string{"a", "b", "c"},
+			depends: map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"b"}},
+			cycle:   []string{"b", "c", "b"},
+		},
+		{
+			repos:  []string{"a", "b", "c"},
+			order:  map[string]int{"a": 10, "c": -10},
+			sorted: []string{"c", "b", "a"},
+		},
+		{
+			// "c" is loaded later, but it is loaded before "b" which depends on it
+			repos:   []string{"a", "b", "c"},
+			depends: map[string][]string{"b": {"c"}},
+			order:   map[string]int{"c": 10},
+			sorted:  []string{"a", "c", "b"},
+		},
+	}
+	for _, tt := range tests {
+		reposList := make([]lockjson.Repos, 0, len(tt.repos))
+		for _, name := range tt.repos {
+			reposList = append(reposList, lockjson.Repos{Path: pathutil.ReposPath(name)})
+		}
+		plugconfMap := make(map[pathutil.ReposPath]*ParsedInfo, len(tt.depends))
+		getInfo := func(name string) *ParsedInfo {
+			if info, exists := plugconfMap[pathutil.ReposPath(name)]; exists {
+				return info
+			}
+			info := &ParsedInfo{reposPath: pathutil.ReposPath(name)}
+			plugconfMap[pathutil.ReposPath(name)] = info
+			return info
+		}
+		for name, depends := range tt.depends {
+			info := getInfo(name)
+			for _, dep := range depends {
+				info.depends = append(info.depends, pathutil.ReposPath(dep))
+			}
+		}
+		for name, order := range tt.order {
+			getInfo(name).loadOrder = order
+		}
+		sortByLoadOrder(reposList, plugconfMap)
+		cycle := sortByDepends(reposList, plugconfMap)
+		if tt.cycle != nil {
+			if !reflect.DeepEqual(cycle.Strings(), tt.cycle) {
+				t.Errorf("depends:%v, got cycle:%v, expected:%v", tt.depends, cycle, tt.cycle)
+			}
+			continue
+		}
+		if cycle != nil {
+			t.Errorf("depends:%v, unexpected cycle:%v", tt.depends, cycle)
+			continue
+		}
+		sorted := make([]string, 0, len(reposList))
+		for i := range reposList {
+			sorted = append(sorted, reposList[i].Path.String())
+		}
+		if !reflect.DeepEqual(sorted, tt.sorted) {
+			t.Errorf("depends:%v, got:%v, expected:%v", tt.depends, sorted, tt.sorted)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Package scheduler runs jobs in parallel with the limits of the number of
+// all jobs and the jobs per host.
+package scheduler
+
+import (
+	"sync"
+)
+
+// Scheduler runs jobs in parallel. At most jobs jobs run at the same time,
+// and at most jobsPerHost jobs of the same host run at the same time, so
+// many clones from one host (e.g. github.com) do not trip abuse detection or
+// saturate slow links.
+type Scheduler struct {
+	all         chan struct{}
+	jobsPerHost int
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+// New returns Scheduler. jobsPerHost <= 0 means no limit per host.
+func New(jobs, jobsPerHost int) *Scheduler {
+	if jobs < 1 {
+		jobs = 1
+	}
+	return &Scheduler{
+		all:         make(chan struct{}, jobs),
+		jobsPerHost: jobsPerHost,
+		hosts:       make(map[string]chan struct{}),
+	}
+}
+
+// Go runs job in a new goroutine when it can be run. host is the host which
+// job accesses, or "" if job does not access network.
+func (s *Scheduler) Go(host string, job func()) {
+	go func() {
+		// Wait for the host first so the waiting jobs do not block the jobs
+		// of other hosts
+		if sem := s.hostSem(host); sem != nil {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+		}
+		s.all <- struct{}{}
+		defer func() { <-s.all }()
+		job()
+	}()
+}
+
+func (s *Scheduler) hostSem(host string) chan struct{} {
+	if host == "" || s.jobsPerHost <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sem, exists := s.hosts[host]
+	if !exists {
+		sem = make(chan struct{}, s.jobsPerHost)
+		s.hosts[host] = sem
+	}
+	return sem
+}
@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerLimits(t *testing.T) {
+	s := New(4, 2)
+	var mu sync.Mutex
+	running := map[string]int{}
+	maxRunning := map[string]int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		host := "github.com"
+		if i%2 == 0 {
+			host = "gitlab.com"
+		}
+		if i%5 == 0 {
+			host = ""
+		}
+		wg.Add(1)
+		s.Go(host, func() {
+			defer wg.Done()
+			mu.Lock()
+			running[host]++
+			running["all"]++
+			for _, key := range []string{host, "all"} {
+				if running[key] > maxRunning[key] {
+					maxRunning[key] = running[key]
+				}
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			running[host]--
+			running["all"]--
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	if maxRunning["all"] > 4 {
+		t.Errorf("expected at most 4 jobs but %d jobs ran", maxRunning["all"])
+	}
+	for _, host := range []string{"github.com", "gitlab.com"} {
+		if maxRunning[host] > 2 {
+			t.Errorf("expected at most 2 jobs of %s but %d jobs ran", host, maxRunning[host])
+		}
+	}
+}
@@ -0,0 +1,521 @@
+package subcmd
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/subcmd/builder"
+	"github.com/vim-volt/volt/transaction"
+)
+
+func init() {
+	cmdMap["apply"] = &applyCmd{}
+}
+
+type applyCmd struct {
+	helped   bool
+	file     string
+	showPlan bool
+}
+
+func (cmd *applyCmd) ProhibitRootExecution(args []string) bool { return true }
+
+func (cmd *applyCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt apply [-help] [-f {voltfile}] [-plan]
+
+Quick example
+  $ volt apply -plan                        # will show what is changed
+  $ volt apply                              # will make lock.json match $VOLTPATH/voltfile.toml
+  $ volt apply -f ~/dotfiles/voltfile.toml  # will make lock.json match given voltfile
+  $ volt export -voltfile >$VOLTPATH/voltfile.toml  # will create voltfile of current lock.json
+
+Description
+  Make lock.json and the repositories match the voltfile ("$VOLTPATH/voltfile.toml"
+  by default), a human-editable manifest of the plugins:
+
+    # the profile to be used ("default" if omitted)
+    current_profile = "default"
+    # the profiles to be created even if they have no plugins (optional)
+    profiles = ["default", "work"]
+
+    [[plugins]]
+    repos = "tyru/caw.vim"
+    # a branch, a tag, or a commit to check out (optional)
+    ref = "v1.0.0"
+    # the profiles which have the plugin (["default"] if omitted, [] means
+    # that the plugin is installed but not used)
+    profiles = ["default", "work"]
+    # plugconf file copied to $VOLTPATH/plugconf (relative to voltfile, optional)
+    plugconf = "plugconf/caw.vim"
+
+  While the voltfile is edited by users, lock.json is the resolved state
+  (the installed commits) which is generated by volt. "volt apply" performs:
+
+  * Install the plugins which are not in lock.json, and check out "ref" of
+    the plugins whose versions differ
+  * Remove the plugins which are not in the voltfile from lock.json
+    (the repository directories are not removed)
+  * Copy plugconf files, and set the repositories of the profiles in the
+    voltfile (the other profiles are not changed except the removed plugins)
+  * Change current profile, and build ~/.vim/pack/volt
+
+  The changes are made in one transaction, so all of them are rolled back if
+  some plugins failed to be installed.
+
+  If -plan option was given, show the steps to be performed, and exit without
+  changing anything.` + "\n\n")
+		fmt.Println("Options")
+		fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	fs.StringVar(&cmd.file, "f", "", "voltfile (default: $VOLTPATH/voltfile.toml)")
+	fs.BoolVar(&cmd.showPlan, "plan", false, "show the steps to be performed without changing anything")
+	return fs
+}
+
+func (cmd *applyCmd) Run(args []string) *Error {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return nil
+	}
+	if len(fs.Args()) > 0 {
+		fs.Usage()
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: too many arguments"}
+	}
+	if cmd.file == "" {
+		cmd.file = pathutil.Voltfile()
+	}
+
+	vf, err := readVoltfile(cmd.file)
+	if err != nil {
+		return &Error{Code: ExitValidation, Msg: "Could not read " + cmd.file + ": " + err.Error()}
+	}
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return &Error{Code: ExitValidation, Msg: "Could not read lock.json: " + err.Error()}
+	}
+
+	err = cmd.doApply(vf, lockJSON)
+	if err == errShowedPlan {
+		return nil
+	}
+	if err != nil {
+		if e, ok := err.(*Error); ok {
+			return e
+		}
+		return &Error{Code: exitCodeOf(err, ExitGeneral), Msg: "Failed to apply: " + err.Error()}
+	}
+	return nil
+}
+
+// voltfile is the manifest of plugins edited by users.
+type voltfile struct {
+	CurrentProfile string           `toml:"current_profile"`
+	Profiles       []string         `toml:"profiles"`
+	Plugins        []voltfilePlugin `toml:"plugins"`
+	// dir is the directory of voltfile
+	dir string
+}
+
+type voltfilePlugin struct {
+	Repos    string   `toml:"repos"`
+	Ref      string   `toml:"ref"`
+	Profiles []string `toml:"profiles"`
+	Plugconf string   `toml:"plugconf"`
+	// reposPath is normalized Repos
+	reposPath pathutil.ReposPath
+	// repos is the repository in lock.json given by "volt sync". Its version
+	// is checked out at the state of HEAD (see gitutil.CheckoutVersion)
+	// instead of Ref.
+	repos *lockjson.Repos
+}
+
+// readVoltfile reads and validates voltfile of path.
+func readVoltfile(path string) (*voltfile, error) {
+	vf := &voltfile{dir: filepath.Dir(path)}
+	md, err := toml.DecodeFile(path, vf)
+	if err != nil {
+		return nil, err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("unknown key '%s'", undecoded[0])
+	}
+	if vf.CurrentProfile == "" {
+		vf.CurrentProfile = "default"
+	}
+	seen := make(map[pathutil.ReposPath]bool, len(vf.Plugins))
+	for i := range vf.Plugins {
+		p := &vf.Plugins[i]
+		if p.reposPath, err = pathutil.NormalizeRepos(p.Repos); err != nil {
+			return nil, err
+		}
+		if seen[p.reposPath] {
+			return nil, errors.New("duplicate plugin: " + p.reposPath.String())
+		}
+		seen[p.reposPath] = true
+		if p.Profiles == nil {
+			p.Profiles = []string{"default"}
+		}
+		if p.Plugconf != "" {
+			if !filepath.IsAbs(p.Plugconf) {
+				p.Plugconf = filepath.Join(vf.dir, p.Plugconf)
+			}
+			if !pathutil.Exists(p.Plugconf) {
+				return nil, fmt.Errorf("plugconf of %s does not exist: %s", p.reposPath, p.Plugconf)
+			}
+		}
+	}
+	return vf, nil
+}
+
+// profileNames returns the profiles in vf (sorted by name).
+func (vf *voltfile) profileNames() []string {
+	set := map[string]bool{vf.CurrentProfile: true}
+	for _, name := range vf.Profiles {
+		set[name] = true
+	}
+	for i := range vf.Plugins {
+		for _, name := range vf.Plugins[i].Profiles {
+			set[name] = true
+		}
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyPlan is the plan of "volt apply".
+type applyPlan struct {
+	plan
+	// get is the arguments of "volt get" ({repos}[@{ref}])
+	get []string
+	// versions is the repositories of get whose {ref} is checked out at the
+	// state of HEAD
+	versions map[pathutil.ReposPath]*lockjson.Repos
+	// rm is the repositories to be removed from lock.json
+	rm pathutil.ReposPathList
+	// plugconf is the plugconf files to be copied (destination -> source)
+	plugconf map[string]string
+	// profiles is the repositories of the profiles to be changed
+	profiles map[string]pathutil.ReposPathList
+	// currentProfile is the profile to be current profile ("" means no
+	// change)
+	currentProfile string
+}
+
+// planApply compares vf with lockJSON, and determines the steps.
+func (cmd *applyCmd) planApply(vf *voltfile, lockJSON *lockjson.LockJSON) (*applyPlan, error) {
+	p := &applyPlan{
+		versions: make(map[pathutil.ReposPath]*lockjson.Repos),
+		plugconf: make(map[string]string),
+		profiles: make(map[string]pathutil.ReposPathList),
+	}
+	inVoltfile := make(map[pathutil.ReposPath]bool, len(vf.Plugins))
+
+	for i := range vf.Plugins {
+		plugin := &vf.Plugins[i]
+		inVoltfile[plugin.reposPath] = true
+		if plugin.Plugconf != "" {
+			dst := plugin.reposPath.Plugconf()
+			src, err := ioutil.ReadFile(plugin.Plugconf)
+			if err != nil {
+				return nil, err
+			}
+			if current, err := ioutil.ReadFile(dst); err != nil || !bytes.Equal(src, current) {
+				p.plugconf[dst] = plugin.Plugconf
+				p.add("copy %s to %s", plugin.Plugconf, relVoltPath(dst))
+			}
+		}
+		if plugin.repos != nil {
+			p.versions[plugin.reposPath] = plugin.repos
+		}
+		repos, err := lockJSON.Repos.FindByPath(plugin.reposPath)
+		switch {
+		case err != nil:
+			arg := plugin.reposPath.String()
+			step := "install " + arg
+			if plugin.Ref != "" {
+				arg += "@" + plugin.Ref
+				step += " at " + plugin.Ref
+			}
+			p.get = append(p.get, arg)
+			p.add("%s", step)
+		case plugin.Ref != "" && repos.Type == lockjson.ReposGitType && !refMatches(repos, plugin.Ref):
+			p.get = append(p.get, plugin.reposPath.String()+"@"+plugin.Ref)
+			p.add("check out %s in %s", plugin.Ref, relVoltPath(plugin.reposPath.FullPath()))
+		}
+	}
+
+	for i := range lockJSON.Repos {
+		if reposPath := lockJSON.Repos[i].Path; !inVoltfile[reposPath] {
+			p.rm = append(p.rm, reposPath)
+			p.add("remove %s from lock.json", reposPath)
+		}
+	}
+
+	for _, name := range vf.profileNames() {
+		var want pathutil.ReposPathList
+		for i := range vf.Plugins {
+			for _, profileName := range vf.Plugins[i].Profiles {
+				if profileName == name {
+					want = append(want, vf.Plugins[i].reposPath)
+				}
+			}
+		}
+		profile, err := lockJSON.Profiles.FindByName(name)
+		switch {
+		case err != nil:
+			p.add("create profile '%s' with %d repositories", name, len(want))
+		case !sameReposPathSet(profile, want):
+			p.add("set %d repositories to profile '%s'", len(want), name)
+		default:
+			continue
+		}
+		p.profiles[name] = want
+	}
+	if lockJSON.CurrentProfileName != vf.CurrentProfile {
+		p.currentProfile = vf.CurrentProfile
+		p.add("change current profile to '%s'", vf.CurrentProfile)
+	}
+	if len(p.profiles) > 0 || p.currentProfile != "" {
+		changes := len(p.profiles)
+		if p.currentProfile != "" {
+			changes++
+		}
+		p.addWriteLockJSON(changes)
+	}
+	if len(p.steps) > 0 {
+		p.addBuild()
+	}
+	return p, nil
+}
+
+// refMatches returns true if the version of repos is ref.
+func refMatches(repos *lockjson.Repos, ref string) bool {
+	return repos.HeadRef == ref || len(ref) >= 7 && strings.HasPrefix(repos.Version, ref)
+}
+
+// sameReposPathSet returns true if profile has the same repositories as
+// reposPathList.
+func sameReposPathSet(profile *lockjson.Profile, reposPathList pathutil.ReposPathList) bool {
+	if len(profile.ReposPath) != len(reposPathList) {
+		return false
+	}
+	for _, reposPath := range reposPathList {
+		if !profile.ReposPath.Contains(reposPath) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cmd *applyCmd) doApply(vf *voltfile, lockJSON *lockjson.LockJSON) error {
+	p, err := cmd.planApply(vf, lockJSON)
+	if err != nil {
+		return err
+	}
+	if cmd.showPlan {
+		p.show()
+		return errShowedPlan
+	}
+	if len(p.steps) == 0 {
+		logger.Info("lock.json is up to date")
+		return nil
+	}
+
+	// Read config.toml
+	cfg, err := config.Read()
+	if err != nil {
+		return errors.New("could not read config.toml: " + err.Error())
+	}
+
+	// Begin transaction
+	err = transaction.Create()
+	if err != nil {
+		return err
+	}
+	defer transaction.Remove()
+
+	// Copy plugconf files before "volt get" creates the templates
+	for dst, src := range p.plugconf {
+		if err = cmd.copyPlugconf(src, dst); err != nil {
+			return err
+		}
+	}
+
+	if len(p.rm) > 0 {
+		rm := &rmCmd{}
+		rmPlan, err := rm.planRemove(p.rm, lockJSON)
+		if err != nil {
+			return err
+		}
+		if err = rm.applyRemove(rmPlan, p.rm, lockJSON); err != nil {
+			return err
+		}
+	}
+
+	get := &getCmd{}
+	var statusList []string
+	var succeeded []getParallelResult
+	var fullBuild bool
+	if len(p.get) > 0 {
+		get.versions = p.versions
+		r, err := cmd.getPlugins(get, p.get, lockJSON, cfg)
+		if err != nil {
+			return err
+		}
+		statusList = r.statusList
+		succeeded = r.succeeded
+		fullBuild = r.fullBuild
+	}
+
+	cmd.applyProfiles(p, lockJSON)
+
+	// Write to lock.json
+	err = lockJSON.Write()
+	if err != nil {
+		return errors.New("could not write to lock.json: " + err.Error())
+	}
+
+	// Build ~/.vim/pack/volt dir
+	err = builder.Build(fullBuild)
+	if err != nil {
+		return errors.New("could not build " + pathutil.VimVoltDir() + ": " + err.Error())
+	}
+
+	// Register remote plugins of Neovim in the built directories
+	rpluginStatusList := get.updateRemotePlugins(succeeded, lockJSON)
+	statusList = append(statusList, rpluginStatusList...)
+
+	if err = transaction.Commit(); err != nil {
+		return err
+	}
+
+	// Show results
+	sort.Strings(statusList)
+	for i := range statusList {
+		fmt.Println(statusList[i])
+	}
+	if len(statusList) > 1 {
+		fmt.Println(get.formatSummary(statusList))
+	}
+	for _, status := range statusList {
+		if strings.HasPrefix(status, statusPrefixFailed) {
+			return &partialFailureError{msg: "failed to run build hooks or to register remote plugins"}
+		}
+	}
+	return nil
+}
+
+// copyPlugconf copies plugconf file src to dst in current transaction.
+// If the transaction is rolled back, dst is restored.
+func (*applyCmd) copyPlugconf(src, dst string) error {
+	content, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if pathutil.Exists(dst) {
+		if err = transaction.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+	if err = transaction.Install(dst); err != nil {
+		return errors.New("failed to write transaction journal: " + err.Error())
+	}
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, content, 0644)
+}
+
+// getPlugins installs or checks out args ("{repository}@{ref}") by "volt get"
+// in current transaction, and runs the build hooks. If some plugins failed,
+// all changes of the transaction are rolled back.
+func (*applyCmd) getPlugins(get *getCmd, args []string, lockJSON *lockjson.LockJSON, cfg *config.Config) (*getResult, error) {
+	reposPathList, err := get.getReposPathList(args, lockJSON)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := lockJSON.Profiles.FindByName(lockJSON.CurrentProfileName)
+	if err != nil {
+		return nil, err
+	}
+	jobs := get.jobsOf(cfg)
+	r, err := get.getTargets(get.planGet(reposPathList, lockJSON, profile, cfg), lockJSON, profile, cfg, jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.failed {
+		if err = transaction.Rollback(); err != nil {
+			return nil, err
+		}
+		sort.Strings(r.rolledBackList)
+		for i := range r.rolledBackList {
+			fmt.Println(r.rolledBackList[i])
+		}
+		if transaction.Interrupted() {
+			return nil, transaction.ErrInterrupted
+		}
+		return nil, &partialFailureError{msg: "failed to install some plugins: all changes were rolled back"}
+	}
+	if transaction.Interrupted() {
+		return nil, transaction.ErrInterrupted
+	}
+
+	// Run build hooks of the installed / upgraded plugins
+	hookStatusList, hookRan := get.runBuildHooks(r.succeeded, jobs)
+	r.statusList = append(r.statusList, hookStatusList...)
+	if hookRan {
+		// The files in the worktree were changed by build hooks
+		r.fullBuild = true
+	}
+	return r, nil
+}
+
+// applyProfiles sets the profiles and current profile of p to lockJSON.
+func (*applyCmd) applyProfiles(p *applyPlan, lockJSON *lockjson.LockJSON) {
+	names := make([]string, 0, len(p.profiles))
+	for name := range p.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		reposPathList := append([]pathutil.ReposPath{}, p.profiles[name]...)
+		if profile, err := lockJSON.Profiles.FindByName(name); err == nil {
+			profile.ReposPath = reposPathList
+		} else {
+			lockJSON.Profiles = append(lockJSON.Profiles, lockjson.Profile{
+				Name:      name,
+				ReposPath: reposPathList,
+			})
+			logger.Info("Created new profile '" + name + "'")
+		}
+	}
+	if p.currentProfile != "" {
+		lockJSON.CurrentProfileName = p.currentProfile
+		logger.Info("Changed current profile: " + p.currentProfile)
+	}
+}
@@ -0,0 +1,203 @@
+package subcmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/internal/testutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/transaction"
+)
+
+func TestReadVoltfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "voltfile.toml")
+
+	for _, tt := range []struct {
+		src string
+		err string
+	}{
+		{src: "[[plugins]]\nrepos = 'a/b'\nfoo = 1\n", err: "unknown key 'plugins.foo'"},
+		{src: "[[plugins]]\nrepos = 'a/b'\n[[plugins]]\nrepos = 'github.com/a/b'\n", err: "duplicate plugin: github.com/a/b"},
+		{src: "[[plugins]]\nrepos = 'a/b'\nplugconf = 'none.vim'\n", err: "plugconf of github.com/a/b does not exist"},
+	} {
+		if err := ioutil.WriteFile(path, []byte(tt.src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readVoltfile(path); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("expected error %q but got %v: %s", tt.err, err, tt.src)
+		}
+	}
+
+	src := `
+profiles = ["work"]
+[[plugins]]
+repos = "tyru/caw.vim"
+ref = "v1.0.0"
+[[plugins]]
+repos = "localhost/local/a"
+profiles = []
+`
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	vf, err := readVoltfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vf.CurrentProfile != "default" {
+		t.Errorf("expected current profile 'default' but got %q", vf.CurrentProfile)
+	}
+	if vf.Plugins[0].reposPath != "github.com/tyru/caw.vim" || !reflect.DeepEqual(vf.Plugins[0].Profiles, []string{"default"}) {
+		t.Errorf("unexpected plugin: %+v", vf.Plugins[0])
+	}
+	if len(vf.Plugins[1].Profiles) != 0 {
+		t.Errorf("expected no profiles but got %+v", vf.Plugins[1].Profiles)
+	}
+	if names := vf.profileNames(); !reflect.DeepEqual(names, []string{"default", "work"}) {
+		t.Errorf("unexpected profiles: %+v", names)
+	}
+}
+
+func TestPlanApply(t *testing.T) {
+	lockJSON := &lockjson.LockJSON{
+		CurrentProfileName: "default",
+		Repos: lockjson.ReposList{
+			{Type: lockjson.ReposGitType, Path: "github.com/a/keep", Version: "0123456789abcdef", HeadRef: "master"},
+			{Type: lockjson.ReposGitType, Path: "github.com/a/pinned", Version: "fedcba9876543210", HeadRef: "v1.0.0"},
+			{Type: lockjson.ReposGitType, Path: "github.com/a/removed", Version: "0123456789abcdef"},
+		},
+		Profiles: lockjson.ProfileList{
+			{Name: "default", ReposPath: []pathutil.ReposPath{"github.com/a/keep", "github.com/a/pinned", "github.com/a/removed"}},
+		},
+	}
+	vf := &voltfile{
+		CurrentProfile: "work",
+		Plugins: []voltfilePlugin{
+			{reposPath: "github.com/a/keep", Ref: "0123456", Profiles: []string{"default", "work"}},
+			{reposPath: "github.com/a/pinned", Ref: "v2.0.0", Profiles: []string{"default"}},
+			{reposPath: "github.com/a/new", Profiles: []string{"work"}},
+		},
+	}
+	p, err := (&applyCmd{}).planApply(vf, lockJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"github.com/a/pinned@v2.0.0", "github.com/a/new"}; !reflect.DeepEqual(p.get, expected) {
+		t.Errorf("expected get %+v but got %+v", expected, p.get)
+	}
+	if expected := (pathutil.ReposPathList{"github.com/a/removed"}); !reflect.DeepEqual(p.rm, expected) {
+		t.Errorf("expected rm %+v but got %+v", expected, p.rm)
+	}
+	expected := map[string]pathutil.ReposPathList{
+		"default": {"github.com/a/keep", "github.com/a/pinned"},
+		"work":    {"github.com/a/keep", "github.com/a/new"},
+	}
+	if !reflect.DeepEqual(p.profiles, expected) {
+		t.Errorf("expected profiles %+v but got %+v", expected, p.profiles)
+	}
+	if p.currentProfile != "work" {
+		t.Errorf("expected current profile 'work' but got %q", p.currentProfile)
+	}
+
+	// Nothing is changed if lock.json already matches
+	vf = &voltfile{
+		CurrentProfile: "default",
+		Plugins: []voltfilePlugin{
+			{reposPath: "github.com/a/keep", Profiles: []string{"default"}},
+			{reposPath: "github.com/a/pinned", Ref: "v1.0.0", Profiles: []string{"default"}},
+			{reposPath: "github.com/a/removed", Profiles: []string{"default"}},
+		},
+	}
+	if p, err = (&applyCmd{}).planApply(vf, lockJSON); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.steps) != 0 {
+		t.Errorf("expected no steps but got %+v", p.steps)
+	}
+}
+
+// Checks:
+// (A) The removal from lock.json and the copied plugconf are rolled back if some plugins failed
+// (B) The changes are committed as one transaction
+func TestVoltApplyTransaction(t *testing.T) {
+	testutil.SetUpEnv(t)
+	kept := pathutil.ReposPath("example.com/vim-volt/kept.vim")
+	removed := pathutil.ReposPath("example.com/vim-volt/removed.vim")
+	missing := pathutil.ReposPath("example.com/vim-volt/missing.vim")
+	testutil.SetUpRemoteRepos(t, kept)
+	testutil.SetUpRemoteRepos(t, removed)
+	os.RemoveAll(testutil.SetUpRemoteRepos(t, missing))
+	out, err := testutil.RunVolt("get", kept.String(), removed.String())
+	testutil.SuccessExit(t, out, err)
+	oldPlugconf, err := ioutil.ReadFile(kept.Plugconf())
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := transaction.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(os.Getenv("HOME"), "dotfiles")
+	os.MkdirAll(dir, 0755)
+	newPlugconf := []byte("function! s:on_load_pre()\nendfunction\n")
+	if err = ioutil.WriteFile(filepath.Join(dir, "kept.vim"), newPlugconf, 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeVoltfile := func(reposPathList ...pathutil.ReposPath) string {
+		src := fmt.Sprintf("[[plugins]]\nrepos = %q\nplugconf = \"kept.vim\"\n", kept)
+		for _, reposPath := range reposPathList {
+			src += fmt.Sprintf("[[plugins]]\nrepos = %q\n", reposPath)
+		}
+		path := filepath.Join(dir, "voltfile.toml")
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// (A)
+	out, err = testutil.RunVolt("apply", "-f", writeVoltfile(missing))
+	testutil.FailExit(t, out, err)
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lockJSON.Repos.Contains(removed) || lockJSON.Repos.Contains(missing) {
+		t.Errorf("lock.json was not rolled back: %+v", lockJSON.Repos)
+	}
+	if content, _ := ioutil.ReadFile(kept.Plugconf()); string(content) != string(oldPlugconf) {
+		t.Errorf("plugconf was not rolled back: %s", string(content))
+	}
+
+	// (B)
+	out, err = testutil.RunVolt("apply", "-f", writeVoltfile())
+	testutil.SuccessExit(t, out, err)
+	if lockJSON, err = lockjson.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if lockJSON.Repos.Contains(removed) {
+		t.Errorf("%s was not removed from lock.json", removed)
+	}
+	if content, _ := ioutil.ReadFile(kept.Plugconf()); string(content) != string(newPlugconf) {
+		t.Errorf("plugconf was not copied: %s", string(content))
+	}
+	newHistory, err := transaction.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(newHistory) != len(history)+1 {
+		t.Errorf("expected 1 transaction but got %d", len(newHistory)-len(history))
+	}
+}
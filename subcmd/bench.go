@@ -0,0 +1,287 @@
+package subcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/subcmd/builder"
+	"github.com/vim-volt/volt/transaction"
+)
+
+func init() {
+	cmdMap["bench"] = &benchCmd{}
+}
+
+type benchCmd struct {
+	helped  bool
+	count   int
+	profile string
+}
+
+func (cmd *benchCmd) ProhibitRootExecution(args []string) bool { return true }
+
+func (cmd *benchCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt bench [-help] [-n {count}] [-profile {name}]
+
+Quick example
+  $ volt bench                   # measures build time, and the files under ~/.vim/pack/volt
+  $ volt bench -n 5              # measures 5 times each, and shows median, min, and max
+  $ volt bench -profile minimal  # also measures switching to profile "minimal" and back
+
+Description
+  Measure the performance of current setup, and show the numbers which can be
+  pasted to a performance bug report:
+    * cold build: "volt build -full" (all plugins are installed again)
+    * warm build: "volt build" when nothing was changed
+    * profile switch: "volt profile set {name}" to the profile of -profile option,
+      and switching back to current profile (each switch is measured)
+    * runtime files: the number and the total size of files under ~/.vim/pack/volt
+      (the files of symlinked repositories are also counted, except .git directories)
+
+  ~/.vim/pack/volt is built again, and current profile is the same after this
+  command finished.` + "\n\n")
+		fmt.Println("Options")
+		fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	fs.IntVar(&cmd.count, "n", 3, "measure {count} times each")
+	fs.StringVar(&cmd.profile, "profile", "", "measure switching to profile {name} and back")
+	return fs
+}
+
+func (cmd *benchCmd) Run(args []string) *Error {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return nil
+	}
+	if len(fs.Args()) > 0 {
+		fs.Usage()
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: too many arguments"}
+	}
+	if cmd.count < 1 {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: -n must be 1 or greater"}
+	}
+
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return &Error{Code: ExitValidation, Msg: "Could not read lock.json: " + err.Error()}
+	}
+	if cmd.profile != "" {
+		if cmd.profile == lockJSON.CurrentProfileName {
+			return &Error{Code: ExitValidation, Msg: fmt.Sprintf("'%s' is current profile", cmd.profile)}
+		}
+		if _, err = lockJSON.Profiles.FindByName(cmd.profile); err != nil {
+			return &Error{Code: ExitValidation, Msg: err.Error()}
+		}
+	}
+
+	if err = cmd.doBench(lockJSON); err != nil {
+		return &Error{Code: ExitGeneral, Msg: err.Error()}
+	}
+	return nil
+}
+
+// benchResult is the result of "volt bench". The durations are of each
+// measurement.
+type benchResult struct {
+	plugins       int
+	profile       string
+	strategy      string
+	coldBuild     []time.Duration
+	warmBuild     []time.Duration
+	profileSwitch []time.Duration
+	files         int
+	size          int64
+}
+
+func (cmd *benchCmd) doBench(lockJSON *lockjson.LockJSON) error {
+	current := lockJSON.CurrentProfileName
+	reposList, err := lockJSON.GetCurrentReposList()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Read()
+	if err != nil {
+		return errors.New("could not read config.toml: " + err.Error())
+	}
+
+	// Begin transaction
+	err = transaction.Create()
+	if err != nil {
+		return errors.New("failed to begin transaction: " + err.Error())
+	}
+	defer transaction.Remove()
+
+	// Do not show the progress of each build
+	if level := logger.Level(); level < logger.DebugLevel {
+		logger.SetLevel(logger.WarnLevel)
+		defer logger.SetLevel(level)
+	}
+
+	result := &benchResult{
+		plugins:  len(reposList),
+		profile:  current,
+		strategy: cfg.Build.Strategy,
+	}
+	result.coldBuild, err = cmd.measure(func() error { return builder.Build(true) })
+	if err != nil {
+		return errors.New("failed to build: " + err.Error())
+	}
+	result.warmBuild, err = cmd.measure(func() error { return builder.Build(false) })
+	if err != nil {
+		return errors.New("failed to build: " + err.Error())
+	}
+	if cmd.profile != "" {
+		result.profileSwitch, err = cmd.measureProfileSwitch(current)
+		if err != nil {
+			return errors.New("failed to switch profile: " + err.Error())
+		}
+	}
+	result.files, result.size, err = runtimeFiles(pathutil.VimVoltDir())
+	if err != nil {
+		return err
+	}
+
+	cmd.printResult(os.Stdout, result)
+	return nil
+}
+
+// measure calls f cmd.count times, and returns the time of each call.
+func (cmd *benchCmd) measure(f func() error) ([]time.Duration, error) {
+	results := make([]time.Duration, 0, cmd.count)
+	for i := 0; i < cmd.count; i++ {
+		start := time.Now()
+		if err := f(); err != nil {
+			return nil, err
+		}
+		results = append(results, time.Since(start))
+	}
+	return results, nil
+}
+
+// measureProfileSwitch measures switching current profile to cmd.profile and
+// back to current like "volt profile set". Current profile is restored on
+// error.
+func (cmd *benchCmd) measureProfileSwitch(current string) ([]time.Duration, error) {
+	results := make([]time.Duration, 0, cmd.count*2)
+	for i := 0; i < cmd.count; i++ {
+		for _, name := range []string{cmd.profile, current} {
+			start := time.Now()
+			if err := cmd.switchProfile(name); err != nil {
+				if name != current {
+					cmd.switchProfile(current)
+				}
+				return nil, err
+			}
+			results = append(results, time.Since(start))
+		}
+	}
+	return results, nil
+}
+
+func (*benchCmd) switchProfile(name string) error {
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return err
+	}
+	lockJSON.CurrentProfileName = name
+	if err = lockJSON.Write(); err != nil {
+		return err
+	}
+	return builder.Build(false)
+}
+
+// runtimeFiles returns the number and the total size of regular files under
+// dir. The symlinks to directories (the repositories installed by symlink
+// builder) are followed, and .git directories are skipped.
+func runtimeFiles(dir string) (int, int64, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	count, size := 0, int64(0)
+	for _, fi := range entries {
+		path := filepath.Join(dir, fi.Name())
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if fi, err = os.Stat(path); err != nil {
+				continue // broken symlink
+			}
+		}
+		if fi.IsDir() {
+			if fi.Name() == ".git" {
+				continue
+			}
+			n, s, err := runtimeFiles(path)
+			if err != nil {
+				return 0, 0, err
+			}
+			count += n
+			size += s
+		} else if fi.Mode().IsRegular() {
+			count++
+			size += fi.Size()
+		}
+	}
+	return count, size, nil
+}
+
+func (cmd *benchCmd) printResult(w io.Writer, result *benchResult) {
+	fmt.Fprintf(w, "volt %s (%s/%s), %d plugins in profile '%s', build.strategy = %q, %d runs\n",
+		voltVersion, runtime.GOOS, runtime.GOARCH, result.plugins, result.profile, result.strategy, cmd.count)
+	fmt.Fprintf(w, "  %-15s %s\n", "cold build:", formatDurations(result.coldBuild))
+	fmt.Fprintf(w, "  %-15s %s\n", "warm build:", formatDurations(result.warmBuild))
+	if cmd.profile != "" {
+		fmt.Fprintf(w, "  %-15s %s (to '%s' and back)\n", "profile switch:", formatDurations(result.profileSwitch), cmd.profile)
+	} else {
+		fmt.Fprintf(w, "  %-15s - (give -profile {name} to measure)\n", "profile switch:")
+	}
+	fmt.Fprintf(w, "  %-15s %d files, %s\n", "runtime files:", result.files, formatSize(result.size))
+}
+
+// formatDurations formats the median of results, and the minimum and the
+// maximum if there are two or more results.
+func formatDurations(results []time.Duration) string {
+	sorted := append([]time.Duration{}, results...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	median := roundDuration(sorted[len(sorted)/2])
+	if len(sorted) == 1 {
+		return median.String()
+	}
+	return fmt.Sprintf("%s (min %s, max %s)", median,
+		roundDuration(sorted[0]), roundDuration(sorted[len(sorted)-1]))
+}
+
+// roundDuration rounds d to about 3 significant digits (e.g. "1.234s",
+// "12.34ms").
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
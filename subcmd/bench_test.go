@@ -0,0 +1,61 @@
+package subcmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/internal/testutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+// (C) Shows cold build, warm build, profile switch, and runtime files
+// (D) Current profile is not changed
+
+// * Run `volt bench -n 1` (A, B, C, D)
+// * Run `volt bench -n 2 -profile empty` (A, B, C, D)
+func TestVoltBench(t *testing.T) {
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("localhost/local/hello")
+	teardown := testutil.SetUpRepos(t, "hello", lockjson.ReposStaticType, []pathutil.ReposPath{reposPath}, config.SymlinkBuilder)
+	defer teardown()
+	out, err := testutil.RunVolt("profile", "new", "empty")
+	testutil.SuccessExit(t, out, err)
+
+	for _, args := range [][]string{{"-n", "1"}, {"-n", "2", "-profile", "empty"}} {
+		out, err := testutil.RunVolt(append([]string{"bench"}, args...)...)
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+
+		// (C)
+		for _, expected := range []string{"1 plugins in profile 'default'", "cold build:", "warm build:", "profile switch:", "runtime files:"} {
+			if !strings.Contains(string(out), expected) {
+				t.Errorf("%v: expected %q in output: %s", args, expected, string(out))
+			}
+		}
+
+		// (D)
+		lockJSON, err := lockjson.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lockJSON.CurrentProfileName != "default" {
+			t.Errorf("%v: expected current profile 'default' but got %q", args, lockJSON.CurrentProfileName)
+		}
+	}
+}
+
+func TestFormatDurations(t *testing.T) {
+	if s := formatDurations([]time.Duration{1500 * time.Millisecond}); s != "1.5s" {
+		t.Errorf("expected %q but got %q", "1.5s", s)
+	}
+	s := formatDurations([]time.Duration{3 * time.Second, time.Second, 2 * time.Second})
+	if s != "2s (min 1s, max 3s)" {
+		t.Errorf("expected %q but got %q", "2s (min 1s, max 3s)", s)
+	}
+}
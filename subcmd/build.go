@@ -38,12 +38,25 @@ Description
     1. Copy repositories' files into ~/.vim/pack/volt/opt/
       * If the repository is git repository, extract files from locked revision of tree object and copy them into above vim directories
       * If the repository is static repository (imported non-git directory by "volt add" command), copy files into above vim directories
+      * Generate tags files of help files in doc/ directory like ":helptags" (vim executable is not needed)
     2. Remove directories from above vim directories, which exist in ~/.vim/pack/volt/build-info.json but not in $VOLTPATH/lock.json
+    3. Install Lua plugconf files ($VOLTPATH/plugconf/{repository}.lua) for Neovim into ~/.vim/pack/volt/start/system/plugconf/
+      * If "compile_lua" in [build] section of config.toml is true, compile them to bytecode by nvim
+    4. Merge ftdetect scripts of plugins into ~/.vim/pack/volt/start/system/ftdetect/bundled_ftdetect.vim
+      * If "merge_ftdetect" in [build] section of config.toml is false, ftdetect scripts are sourced when each plugin is loaded
+    5. Verify the build output, and show warnings for:
+      * broken symlinks
+      * plugin/ or autoload/ directories which exist in repositories but are not installed
+      * the same autoload files shipped by two or more plugins (only one of them is loaded)
 
-  ~/.vim/pack/volt/build-info.json is a file which holds the information that what vim plugins are installed in ~/.vim/pack/volt/ and its type (git repository, static repository, or system repository), its version. A user normally doesn't need to know the contents of build-info.json .
+  The build output is written into ~/.vim/.volt-staging/ (the files of the last build are linked there at first), flushed to the disk at once, and then swapped with ~/.vim/pack/volt/ by renaming the directories. So Vim never loads a half-written ~/.vim/pack/volt/ even if the build failed, and the next build restores ~/.vim/pack/volt/ if the previous build crashed while swapping them.
 
-  If -full option was given, remove all directories in ~/.vim/pack/volt/opt/ , and copy repositories' files into above vim directories.
-  Otherwise, it will perform smart build: copy / remove only changed repositories' files.` + "\n\n")
+  Repositories are processed in parallel. At most "jobs" in [build] section of config.toml repositories are processed at the same time.
+
+  ~/.vim/pack/volt/build-info.json is a file which holds the information that what vim plugins are installed in ~/.vim/pack/volt/ and its type (git repository, static repository, or system repository), its version, and the cache key of the build output. A user normally doesn't need to know the contents of build-info.json .
+
+  If -full option was given, build into the empty staging directory, and copy repositories' files into above vim directories.
+  Otherwise, it will perform smart build: copy / remove only changed repositories' files. The build output of each repository is keyed on its version, its plugconf files, and the version of volt's build output, and the bundled plugconf is regenerated only when one of these keys was changed.` + "\n\n")
 		fmt.Println("Options")
 		fs.PrintDefaults()
 		fmt.Println()
@@ -65,14 +78,14 @@ func (cmd *buildCmd) Run(args []string) *Error {
 	err := transaction.Create()
 	if err != nil {
 		logger.Error()
-		return &Error{Code: 11, Msg: "Failed to begin transaction: " + err.Error()}
+		return &Error{Code: ExitGeneral, Msg: "Failed to begin transaction: " + err.Error()}
 	}
 	defer transaction.Remove()
 
 	err = builder.Build(cmd.full)
 	if err != nil {
 		logger.Error()
-		return &Error{Code: 12, Msg: "Failed to build: " + err.Error()}
+		return &Error{Code: ExitGeneral, Msg: "Failed to build: " + err.Error()}
 	}
 
 	return nil
@@ -31,6 +31,13 @@ import (
 // (I) `~/.vim/gvimrc` has magic comment
 // (J) Installed bundled plugconf exists
 // (K) Installed bundled plugconf is syntax OK
+// (L) Lua plugconf is installed to `~/.vim/pack/volt/start/system/plugconf/` and executed by bundled plugconf
+// (M) Bundled plugconf is not rewritten if nothing was changed since the last build, and is rewritten if plugconf was changed
+// (N) Autoload files shipped by two plugins are reported with both plugins
+// (O) ftdetect scripts are merged into bundled ftdetect, and the scripts which cannot be merged are sourced
+// (P) after/plugin scripts of lazy-loaded plugins are sourced by bundled plugconf, and after/ftdetect scripts are merged into bundled ftdetect
+// (Q) Test suites, CI config, and images are not copied if `build.slim` is true (`doc/` is also not copied if `build.slim_doc` is true)
+// (R) The runtime moved aside by a crashed build is restored, the leftovers of the build are removed, and the output does not contain the path of the staging directory
 
 // About vimrc and gvimrc test cases (F, G, H, I)
 //
@@ -777,8 +784,490 @@ func voltBuildStaticVimDirNewer(t *testing.T, full bool, strategy string) {
 	checkSyntax(t, bundledPlugconf)
 }
 
+// * Run `volt build` (Lua plugconf: exists) (static repository) (A, B, J, K, L)
+func TestVoltBuildLuaPlugconf(t *testing.T) {
+	testBuildMatrix(t, voltBuildLuaPlugconf)
+}
+
+func voltBuildLuaPlugconf(t *testing.T, full bool, strategy string) {
+	// =============== setup =============== //
+
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("localhost/local/hello")
+	teardown := testutil.SetUpRepos(t, "hello", lockjson.ReposStaticType, []pathutil.ReposPath{reposPath}, strategy)
+	defer teardown()
+	testutil.InstallConfig(t, "strategy-"+strategy+".toml")
+	os.MkdirAll(filepath.Dir(reposPath.LuaPlugconf()), 0777)
+	if err := ioutil.WriteFile(reposPath.LuaPlugconf(), []byte("vim.g.hello = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// =============== run =============== //
+
+	args := []string{"build"}
+	if full {
+		args = append(args, "-full")
+	}
+	out, err := testutil.RunVolt(args...)
+	// (A, B)
+	testutil.SuccessExit(t, out, err)
+
+	// (J)
+	bundledPlugconf := pathutil.BundledPlugConf()
+	content, err := ioutil.ReadFile(bundledPlugconf)
+	if err != nil {
+		t.Fatalf("%s does not exist", bundledPlugconf)
+	}
+
+	// (K)
+	checkSyntax(t, bundledPlugconf)
+
+	// (L)
+	luaPlugconf := reposPath.BundledLuaPlugconf()
+	if !pathutil.Exists(luaPlugconf) {
+		t.Errorf("%s does not exist", luaPlugconf)
+	}
+	if !bytes.Contains(content, []byte(luaPlugconf)) {
+		t.Errorf("bundled plugconf does not execute %s:\n%s", luaPlugconf, string(content))
+	}
+}
+
+// * Run `volt build` (plugconf: not changed, changed) (static repository) (A, B, C, J, K, M)
+// * Run `volt build -full` (plugconf: not changed, changed) (static repository) (A, B, D, J, K, M)
+func TestVoltBuildCache(t *testing.T) {
+	testBuildMatrix(t, voltBuildCache)
+}
+
+func voltBuildCache(t *testing.T, full bool, strategy string) {
+	// =============== setup =============== //
+
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("localhost/local/hello")
+	teardown := testutil.SetUpRepos(t, "hello", lockjson.ReposStaticType, []pathutil.ReposPath{reposPath}, strategy)
+	defer teardown()
+	testutil.InstallConfig(t, "strategy-"+strategy+".toml")
+	writePlugconf := func(value int) {
+		content := fmt.Sprintf("function! s:on_load_pre()\n  let g:hello = %d\nendfunction\n", value)
+		os.MkdirAll(filepath.Dir(reposPath.Plugconf()), 0777)
+		if err := ioutil.WriteFile(reposPath.Plugconf(), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writePlugconf(1)
+	out, err := testutil.RunVolt("build")
+	testutil.SuccessExit(t, out, err)
+
+	// Make bundled plugconf older to detect if it is rewritten
+	bundledPlugconf := pathutil.BundledPlugConf()
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err = os.Chtimes(bundledPlugconf, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// =============== run =============== //
+
+	args := []string{"build"}
+	if full {
+		args = append(args, "-full")
+	}
+	out, err = testutil.RunVolt(args...)
+	// (A, B)
+	testutil.SuccessExit(t, out, err)
+
+	// (C) and (D)
+	checkBuildOutput(t, full, out, strategy)
+
+	// (J)
+	st, err := os.Stat(bundledPlugconf)
+	if err != nil {
+		t.Fatalf("%s does not exist", bundledPlugconf)
+	}
+
+	// (K)
+	checkSyntax(t, bundledPlugconf)
+
+	// (M)
+	if rewritten := !st.ModTime().Equal(old); rewritten != full {
+		t.Errorf("expected rewritten=%v but got %v: %s", full, rewritten, bundledPlugconf)
+	}
+
+	writePlugconf(2)
+	out, err = testutil.RunVolt("build")
+	// (A, B)
+	testutil.SuccessExit(t, out, err)
+
+	// (M)
+	content, err := ioutil.ReadFile(bundledPlugconf)
+	if err != nil {
+		t.Fatalf("%s does not exist", bundledPlugconf)
+	}
+	if !bytes.Contains(content, []byte("let g:hello = 2")) {
+		t.Errorf("bundled plugconf was not rewritten after plugconf was changed:\n%s", string(content))
+	}
+}
+
+// * Run `volt build` (two static repositories ship the same autoload file) (B, N)
+// * Run `volt build -full` (two static repositories ship the same autoload file) (B, N)
+func TestVoltBuildAutoloadCollision(t *testing.T) {
+	testBuildMatrix(t, voltBuildAutoloadCollision)
+}
+
+func voltBuildAutoloadCollision(t *testing.T, full bool, strategy string) {
+	// =============== setup =============== //
+
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("localhost/local/hello")
+	teardown := testutil.SetUpRepos(t, "hello", lockjson.ReposStaticType, []pathutil.ReposPath{reposPath}, strategy)
+	defer teardown()
+	testutil.InstallConfig(t, "strategy-"+strategy+".toml")
+	autoload := filepath.Join(reposPath.FullPath(), "autoload", "hello.vim")
+	os.MkdirAll(filepath.Dir(autoload), 0777)
+	if err := ioutil.WriteFile(autoload, []byte("function! hello#hello() abort\nendfunction\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reposPath2 := pathutil.ReposPath("localhost/local/hello2")
+	if err := fileutil.CopyDir(reposPath.FullPath(), reposPath2.FullPath(), make([]byte, 32*1024), 0777, 0); err != nil {
+		t.Fatal(err)
+	}
+	testutil.RunVolt("get", reposPath2.String())
+
+	// =============== run =============== //
+
+	args := []string{"build"}
+	if full {
+		args = append(args, "-full")
+	}
+	out, err := testutil.RunVolt(args...)
+	// (B)
+	if err != nil {
+		t.Errorf("expected success exit but exited with failure: status=%q, out=%s", err, string(out))
+	}
+
+	// (N)
+	expected := "[WARN] autoload/hello.vim is shipped by two or more plugins: " + reposPath.String() + " and " + reposPath2.String()
+	if !strings.Contains(string(out), expected) {
+		t.Errorf("expected %q but got: %s", expected, string(out))
+	}
+}
+
+// * Run `volt build` (ftdetect scripts: exist) (static repository) (A, B, J, K, O)
+// * Run `volt build -full` (ftdetect scripts: exist) (static repository) (A, B, J, K, O)
+func TestVoltBuildMergeFtdetect(t *testing.T) {
+	testBuildMatrix(t, voltBuildMergeFtdetect)
+}
+
+func voltBuildMergeFtdetect(t *testing.T, full bool, strategy string) {
+	// =============== setup =============== //
+
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("localhost/local/hello")
+	teardown := testutil.SetUpRepos(t, "hello", lockjson.ReposStaticType, []pathutil.ReposPath{reposPath}, strategy)
+	defer teardown()
+	testutil.InstallConfig(t, "strategy-"+strategy+".toml")
+	ftdetectDir := filepath.Join(reposPath.FullPath(), "ftdetect")
+	os.MkdirAll(ftdetectDir, 0777)
+	merged := "autocmd BufNewFile,BufRead *.hello setfiletype hello"
+	if err := ioutil.WriteFile(filepath.Join(ftdetectDir, "hello.vim"), []byte(merged+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	notMerged := "if exists('g:hello2')\n  finish\nendif\nautocmd BufNewFile,BufRead *.hello2 setfiletype hello2\n"
+	if err := ioutil.WriteFile(filepath.Join(ftdetectDir, "hello2.vim"), []byte(notMerged), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// =============== run =============== //
+
+	args := []string{"build"}
+	if full {
+		args = append(args, "-full")
+	}
+	out, err := testutil.RunVolt(args...)
+	// (A, B)
+	testutil.SuccessExit(t, out, err)
+
+	// (J)
+	bundledPlugconf := pathutil.BundledPlugConf()
+	content, err := ioutil.ReadFile(bundledPlugconf)
+	if err != nil {
+		t.Fatalf("%s does not exist", bundledPlugconf)
+	}
+	if !bytes.Contains(content, []byte("s:__volt_packadd(")) {
+		t.Errorf("bundled plugconf does not load the plugin by s:__volt_packadd():\n%s", string(content))
+	}
+
+	// (K)
+	checkSyntax(t, bundledPlugconf)
+
+	// (O)
+	bundledFtdetect := pathutil.BundledFtdetect()
+	content, err = ioutil.ReadFile(bundledFtdetect)
+	if err != nil {
+		t.Fatalf("%s does not exist", bundledFtdetect)
+	}
+	checkSyntax(t, bundledFtdetect)
+	for _, expected := range []string{
+		"\" >>> " + filepath.Join(reposPath.EncodeToPlugDirName(), "ftdetect", "hello.vim") + "\n" + merged + "\n",
+		"execute 'source' fnameescape('" + filepath.Join(reposPath.EncodeToPlugDirName(), "ftdetect", "hello2.vim") + "')",
+	} {
+		if !bytes.Contains(content, []byte(expected)) {
+			t.Errorf("bundled ftdetect does not contain %q:\n%s", expected, string(content))
+		}
+	}
+}
+
+// * Run `volt build` (after/plugin and after/ftdetect scripts: exist) (lazy-loaded static repository) (A, B, J, K, P)
+// * Run `volt build -full` (after/plugin and after/ftdetect scripts: exist) (lazy-loaded static repository) (A, B, J, K, P)
+func TestVoltBuildAfterDir(t *testing.T) {
+	testBuildMatrix(t, voltBuildAfterDir)
+}
+
+func voltBuildAfterDir(t *testing.T, full bool, strategy string) {
+	// =============== setup =============== //
+
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("localhost/local/hello")
+	teardown := testutil.SetUpRepos(t, "hello", lockjson.ReposStaticType, []pathutil.ReposPath{reposPath}, strategy)
+	defer teardown()
+	testutil.InstallConfig(t, "strategy-"+strategy+".toml")
+	for _, file := range []string{"after/plugin/hello.vim", "after/ftdetect/hello.vim"} {
+		path := filepath.Join(reposPath.FullPath(), filepath.FromSlash(file))
+		os.MkdirAll(filepath.Dir(path), 0777)
+		if err := ioutil.WriteFile(path, []byte("let g:hello_after = 1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.MkdirAll(filepath.Dir(reposPath.Plugconf()), 0777)
+	plugconf := "function! s:loaded_on()\n  return 'filetype=hello'\nendfunction\n"
+	if err := ioutil.WriteFile(reposPath.Plugconf(), []byte(plugconf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// =============== run =============== //
+
+	args := []string{"build"}
+	if full {
+		args = append(args, "-full")
+	}
+	out, err := testutil.RunVolt(args...)
+	// (A, B)
+	testutil.SuccessExit(t, out, err)
+
+	// (J)
+	bundledPlugconf := pathutil.BundledPlugConf()
+	content, err := ioutil.ReadFile(bundledPlugconf)
+	if err != nil {
+		t.Fatalf("%s does not exist", bundledPlugconf)
+	}
+
+	// (K)
+	checkSyntax(t, bundledPlugconf)
+
+	// (P)
+	afterDir := filepath.Join(reposPath.EncodeToPlugDirName(), "after")
+	expected := "call s:__volt_load_after('" + afterDir + "', 1, 0)"
+	if !bytes.Contains(content, []byte(expected)) {
+		t.Errorf("bundled plugconf does not contain %q:\n%s", expected, string(content))
+	}
+	bundledFtdetect := pathutil.BundledFtdetect()
+	content, err = ioutil.ReadFile(bundledFtdetect)
+	if err != nil {
+		t.Fatalf("%s does not exist", bundledFtdetect)
+	}
+	expected = "\" >>> " + filepath.Join(afterDir, "ftdetect", "hello.vim") + "\n"
+	if !bytes.Contains(content, []byte(expected)) {
+		t.Errorf("bundled ftdetect does not contain %q:\n%s", expected, string(content))
+	}
+}
+
+// * Run `volt build` (build.slim: true) (static repository) (A, B, Q)
+// * Run `volt build -full` (build.slim: true) (static repository) (A, B, Q)
+func TestVoltBuildSlim(t *testing.T) {
+	testBuildMatrix(t, voltBuildSlim)
+}
+
+func voltBuildSlim(t *testing.T, full bool, strategy string) {
+	// =============== setup =============== //
+
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("localhost/local/hello")
+	teardown := testutil.SetUpRepos(t, "hello", lockjson.ReposStaticType, []pathutil.ReposPath{reposPath}, strategy)
+	defer teardown()
+	testutil.InstallConfig(t, "strategy-"+strategy+".toml")
+	excluded := []string{"test/hello.vim", ".github/workflows/ci.yml", ".travis.yml", "screenshot.png", "doc/demo.gif"}
+	for _, file := range excluded {
+		path := filepath.Join(reposPath.FullPath(), filepath.FromSlash(file))
+		os.MkdirAll(filepath.Dir(path), 0777)
+		if err := ioutil.WriteFile(path, []byte("dummy\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, slimDoc := range []bool{false, true} {
+		for _, key := range []string{"build.slim", "build.slim_doc"} {
+			value := "true"
+			if key == "build.slim_doc" && !slimDoc {
+				value = "false"
+			}
+			out, err := testutil.RunVolt("config", "set", key, value)
+			testutil.SuccessExit(t, out, err)
+		}
+
+		// =============== run =============== //
+
+		args := []string{"build"}
+		if full {
+			args = append(args, "-full")
+		}
+		out, err := testutil.RunVolt(args...)
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+
+		// (Q)
+		dst := reposPath.EncodeToPlugDirName()
+		// Symlink builder links the whole repository
+		slim := strategy == config.CopyBuilder
+		for _, file := range excluded {
+			if exists := pathutil.Exists(filepath.Join(dst, filepath.FromSlash(file))); exists == slim {
+				t.Errorf("slim_doc=%v: %s exists=%v", slimDoc, file, exists)
+			}
+		}
+		if exists := pathutil.Exists(filepath.Join(dst, "plugin", "hello.vim")); !exists {
+			t.Errorf("slim_doc=%v: plugin/hello.vim does not exist", slimDoc)
+		}
+		if exists := pathutil.Exists(filepath.Join(dst, "doc")); exists == (slim && slimDoc) {
+			t.Errorf("slim_doc=%v: doc exists=%v", slimDoc, exists)
+		}
+	}
+}
+
+// * Run `volt build` (the previous build crashed while swapping the runtime) (A, B, J, R)
+// * Run `volt build -full` (the previous build crashed while swapping the runtime) (A, B, J, R)
+func TestVoltBuildRecoverRuntime(t *testing.T) {
+	testBuildMatrix(t, voltBuildRecoverRuntime)
+}
+
+func voltBuildRecoverRuntime(t *testing.T, full bool, strategy string) {
+	// =============== setup =============== //
+
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("localhost/local/hello")
+	teardown := testutil.SetUpRepos(t, "hello", lockjson.ReposStaticType, []pathutil.ReposPath{reposPath}, strategy)
+	defer teardown()
+	testutil.InstallConfig(t, "strategy-"+strategy+".toml")
+	ftdetectDir := filepath.Join(reposPath.FullPath(), "ftdetect")
+	os.MkdirAll(ftdetectDir, 0777)
+	if err := ioutil.WriteFile(filepath.Join(ftdetectDir, "hello.vim"), []byte("autocmd BufNewFile,BufRead *.hello setfiletype hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := testutil.RunVolt("build")
+	testutil.SuccessExit(t, out, err)
+
+	// Simulate the crash after "(vim dir)/pack/volt" was moved aside
+	if err = os.Rename(pathutil.VimVoltDir(), pathutil.VimVoltOldDir()); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(pathutil.VimVoltStagingDir(), "opt"), 0755)
+
+	// =============== run =============== //
+
+	args := []string{"build"}
+	if full {
+		args = append(args, "-full")
+	}
+	out, err = testutil.RunVolt(args...)
+	// (A, B)
+	testutil.SuccessExit(t, out, err)
+
+	// (J)
+	bundledPlugconf := pathutil.BundledPlugConf()
+	if !pathutil.Exists(bundledPlugconf) {
+		t.Fatalf("%s does not exist", bundledPlugconf)
+	}
+
+	// (R)
+	for _, dir := range []string{pathutil.VimVoltOldDir(), pathutil.VimVoltStagingDir()} {
+		if pathutil.Exists(dir) {
+			t.Errorf("%s exists after build", dir)
+		}
+	}
+	if !pathutil.Exists(reposPath.EncodeToPlugDirName()) {
+		t.Errorf("%s does not exist", reposPath.EncodeToPlugDirName())
+	}
+	for _, path := range []string{bundledPlugconf, pathutil.BundledFtdetect()} {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(content, []byte(pathutil.VimVoltStagingDir())) {
+			t.Errorf("%s contains the path of staging directory:\n%s", path, string(content))
+		}
+	}
+}
+
 // ============================================
 
+// * Run `volt build -full` (bare git repository, copy builder) (A, B, E)
+//   * Files in nested directories are copied with their content and mode
+//     from git objects
+func TestVoltBuildBareGitRepos(t *testing.T) {
+	// =============== setup =============== //
+
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("example.com/vim-volt/bare.vim")
+	remote := testutil.SetUpRemoteRepos(t, reposPath)
+	files := map[string]os.FileMode{
+		filepath.Join("autoload", "bare", "nested.vim"): 0644,
+		filepath.Join("bin", "tool"):                    0755,
+	}
+	for name, mode := range files {
+		path := filepath.Join(remote, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte("\" "+name+"\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testutil.Git(t, remote, "add", "-A")
+	testutil.Git(t, remote, "commit", "-q", "-m", "add files")
+	out, err := testutil.RunVolt("get", reposPath.String())
+	testutil.SuccessExit(t, out, err)
+	// Make the repository bare, and remove the worktree
+	fullpath := reposPath.FullPath()
+	testutil.Git(t, fullpath, "config", "core.bare", "true")
+	infos, err := ioutil.ReadDir(fullpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range infos {
+		if fi.Name() != ".git" {
+			os.RemoveAll(filepath.Join(fullpath, fi.Name()))
+		}
+	}
+	testutil.InstallConfig(t, "strategy-"+config.CopyBuilder+".toml")
+
+	// =============== run =============== //
+
+	out, err = testutil.RunVolt("build", "-full")
+	// (A, B)
+	testutil.SuccessExit(t, out, err)
+
+	// (E)
+	vimReposDir := reposPath.EncodeToPlugDirName()
+	for name, mode := range files {
+		path := filepath.Join(vimReposDir, name)
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Errorf("%s was not copied: %s", name, err.Error())
+			continue
+		}
+		if string(content) != "\" "+name+"\n" {
+			t.Errorf("unexpected content of %s: %q", name, string(content))
+		}
+		if fi, err := os.Stat(path); err == nil && fi.Mode().Perm() != mode {
+			t.Errorf("expected mode of %s is %v but got %v", name, mode, fi.Mode().Perm())
+		}
+	}
+}
+
 func testBuildMatrix(t *testing.T, f func(*testing.T, bool, string)) {
 	for _, strategy := range testutil.AvailableStrategies() {
 		for _, full := range []bool{false, true} {
@@ -811,9 +1300,6 @@ func touchFiles(t *testing.T, fullpath string) {
 
 func checkBuildOutput(t *testing.T, full bool, out []byte, strategy string) {
 	t.Helper()
-	if strategy == config.SymlinkBuilder {
-		full = true // symlink builder always perform full build
-	}
 	outstr := string(out)
 	contains := strings.Contains(outstr, "Full building")
 	if !full && contains {
@@ -1,24 +1,67 @@
 package builder
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
+	"sort"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/vim-volt/volt/config"
 	"github.com/vim-volt/volt/fileutil"
 	"github.com/vim-volt/volt/lockjson"
 	"github.com/vim-volt/volt/logger"
 	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/plugconf"
+	"github.com/vim-volt/volt/scheduler"
 	"github.com/vim-volt/volt/subcmd/buildinfo"
+	"github.com/vim-volt/volt/timing"
 )
 
 // BaseBuilder is a base struct which all builders must implement
-type BaseBuilder struct{}
+type BaseBuilder struct {
+	// sched limits the number of jobs which run at the same time
+	sched *scheduler.Scheduler
+	// deterministic is "build.deterministic" of config.toml. If it is true,
+	// the generated files do not depend on the time and line endings of
+	// their sources.
+	deterministic bool
+}
+
+func newBaseBuilder(jobs int, deterministic bool) BaseBuilder {
+	return BaseBuilder{sched: scheduler.New(jobs, 0), deterministic: deterministic}
+}
+
+// runJob runs job in a new goroutine. At most "build.jobs" of config.toml
+// jobs run at the same time.
+func (builder *BaseBuilder) runJob(job func()) {
+	builder.sched.Go("", job)
+}
+
+// receiveResults receives count results of the jobs run by runJob from done,
+// and returns them sorted by the paths of the repositories, so that the
+// results are merged in a deterministic order regardless of the order in
+// which the jobs finished.
+func receiveResults(done chan actionReposResult, count int) []actionReposResult {
+	results := make([]actionReposResult, 0, count)
+	for i := 0; i < count; i++ {
+		results = append(results, <-done)
+	}
+	path := func(result *actionReposResult) pathutil.ReposPath {
+		if result.repos == nil {
+			return ""
+		}
+		return result.repos.Path
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return path(&results[i]) < path(&results[j])
+	})
+	return results
+}
 
 func (builder *BaseBuilder) installVimrcAndGvimrc(profileName, vimrcPath, gvimrcPath string) error {
 	// Save old vimrc file as {vimrc}.bak
@@ -154,39 +197,115 @@ func (builder *BaseBuilder) copyFileWithMagicComment(src, dst string) (err error
 		return
 	}
 
+	if builder.deterministic {
+		var content []byte
+		if content, err = ioutil.ReadAll(r); err != nil {
+			return
+		}
+		_, err = w.Write(normalizeNewlines(content))
+		return
+	}
 	_, err = io.Copy(w, r)
 	return
 }
 
+// normalizeNewlines converts CRLF line endings of content to LF.
+func normalizeNewlines(content []byte) []byte {
+	return bytes.Replace(content, []byte("\r\n"), []byte("\n"), -1)
+}
+
 type actionReposResult struct {
 	err   error
 	repos *lockjson.Repos
 	files buildinfo.FileMap
 }
 
-func (builder *BaseBuilder) helptags(reposPath pathutil.ReposPath, vimExePath string) error {
+func (builder *BaseBuilder) helptags(reposPath pathutil.ReposPath) error {
 	// Do nothing if <reposPath>/doc directory doesn't exist
 	docdir := filepath.Join(reposPath.EncodeToPlugDirName(), "doc")
 	if !pathutil.Exists(docdir) {
 		return nil
 	}
-	// Execute ":helptags doc" in reposPath
-	vimArgs := builder.makeVimArgs(reposPath)
-	logger.Debugf("Executing '%s %s' ...", vimExePath, strings.Join(vimArgs, " "))
-	err := exec.Command(vimExePath, vimArgs...).Run()
-	if err != nil {
+	logger.Debugf("Generating tags files in '%s' ...", docdir)
+	defer timing.Start(timing.Helptags)()
+	if err := makeHelptags(reposPath.String(), docdir); err != nil {
 		return errors.New("failed to make tags file: " + err.Error())
 	}
 	return nil
 }
 
-func (*BaseBuilder) makeVimArgs(reposPath pathutil.ReposPath) []string {
-	path := reposPath.EncodeToPlugDirName()
-	return []string{
-		"-u", "NONE", "-i", "NONE", "-N",
-		"--cmd", "cd " + path,
-		"--cmd", "set rtp+=" + path,
-		"--cmd", "helptags doc",
-		"--cmd", "quit",
+// installBundledPlugconf writes bundled plugconf, bundled ftdetect, and Lua
+// plugconf files of reposList. reposKeys are the cache keys of reposList (see
+// reposCacheKey).
+// It does nothing if the inputs are not changed since the last build
+// (buildInfo.BundleKey), and returns true if the files were written.
+func (builder *BaseBuilder) installBundledPlugconf(buildInfo *buildinfo.BuildInfo, profileName string, reposList []lockjson.Repos, reposKeys []string) (bool, error) {
+	cfg, err := config.Read()
+	if err != nil {
+		return false, errors.New("could not read config.toml: " + err.Error())
+	}
+	rcDir := pathutil.RCDir(profileName)
+	vimrc := ""
+	if path := filepath.Join(rcDir, pathutil.ProfileVimrc); pathutil.Exists(path) {
+		vimrc = path
+	}
+	gvimrc := ""
+	if path := filepath.Join(rcDir, pathutil.ProfileGvimrc); pathutil.Exists(path) {
+		gvimrc = path
+	}
+	mergeFtdetect := *cfg.Build.MergeFtdetect
+	key := bundleCacheKey(reposKeys, profileName, vimrc, gvimrc, *cfg.Build.CompileLua, mergeFtdetect, builder.deterministic)
+	if key == buildInfo.BundleKey && pathutil.Exists(pathutil.BundledPlugConf()) {
+		logger.Debug("Bundled plugconf is not changed ... skip")
+		return false, nil
+	}
+
+	plugconfs, parseErr := plugconf.ParseMultiPlugconf(reposList)
+	if parseErr.HasErrs() {
+		// Vim script parse errors / other errors
+		return false, parseErr.Errors()
+	}
+	if parseErr.HasWarns() {
+		// Vim script parse warnings
+		merr := parseErr.Warns()
+		for _, err := range merr.Errors {
+			logger.Warn(err)
+		}
+	}
+	if err = builder.installLuaPlugconfs(reposList, *cfg.Build.CompileLua); err != nil {
+		return false, err
+	}
+	content, err := plugconfs.GenerateBundlePlugconf(profileName, vimrc, gvimrc, mergeFtdetect)
+	if err != nil {
+		return false, err
+	}
+	if builder.deterministic {
+		content = normalizeNewlines(content)
+	}
+	os.MkdirAll(filepath.Dir(pathutil.BundledPlugConf()), 0755)
+	if err = ioutil.WriteFile(pathutil.BundledPlugConf(), content, 0644); err != nil {
+		return false, err
+	}
+
+	// Write bundled ftdetect file
+	var ftdetect []byte
+	if mergeFtdetect {
+		if ftdetect, err = plugconfs.GenerateBundleFtdetect(profileName); err != nil {
+			return false, err
+		}
+	}
+	if ftdetect != nil && builder.deterministic {
+		ftdetect = normalizeNewlines(ftdetect)
+	}
+	if ftdetect == nil {
+		err = os.RemoveAll(pathutil.BundledFtdetect())
+	} else {
+		os.MkdirAll(filepath.Dir(pathutil.BundledFtdetect()), 0755)
+		err = ioutil.WriteFile(pathutil.BundledFtdetect(), ftdetect, 0644)
+	}
+	if err != nil {
+		return false, err
 	}
+	buildInfo.BundleKey = key
+	return true, nil
 }
@@ -5,9 +5,11 @@ import (
 	"os"
 
 	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/lockjson"
 	"github.com/vim-volt/volt/logger"
 	"github.com/vim-volt/volt/pathutil"
 	"github.com/vim-volt/volt/subcmd/buildinfo"
+	"github.com/vim-volt/volt/timing"
 )
 
 // Builder creates/updates ~/.vim/pack/volt directory
@@ -15,10 +17,15 @@ type Builder interface {
 	Build(buildInfo *buildinfo.BuildInfo, buildReposMap map[pathutil.ReposPath]*buildinfo.Repos) error
 }
 
-const currentBuildInfoVersion = 2
+// currentBuildInfoVersion is the version of build-info.json format and the
+// build output. It must be incremented when they are changed, so the cache
+// of the last build is not used.
+const currentBuildInfoVersion = 4
 
 // Build creates/updates ~/.vim/pack/volt directory
 func Build(full bool) error {
+	defer timing.Start(timing.Build)()
+
 	// Read config.toml
 	cfg, err := config.Read()
 	if err != nil {
@@ -26,13 +33,34 @@ func Build(full bool) error {
 	}
 
 	// Get builder
-	blder, err := getBuilder(cfg.Build.Strategy)
+	slim := newSlimFilter(*cfg.Build.Slim, *cfg.Build.SlimDoc)
+	blder, err := getBuilder(cfg.Build.Strategy, cfg.Build.Jobs, slim, *cfg.Build.Deterministic)
 	if err != nil {
 		return err
 	}
 
-	// Read ~/.vim/pack/volt/opt/build-info.json
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return errors.New("could not read lock.json: " + err.Error())
+	}
+
+	// Clean up the previous build if it crashed
+	if err = recoverRuntime(); err != nil {
+		return err
+	}
+
+	// Get the runtime directory of current profile, and the last build
+	// output which this build starts from
+	cachedProfiles := *cfg.Build.CachedProfiles
+	dir, base, err := runtimeDir(lockJSON.CurrentProfileName, cachedProfiles)
+	if err != nil {
+		return errors.New("could not set up " + pathutil.VimVoltDir() + ": " + err.Error())
+	}
+
+	// Read build-info.json of the last build output
+	pathutil.SetVimVoltDir(base)
 	buildInfo, err := buildinfo.Read()
+	pathutil.SetVimVoltDir("")
 	if err != nil {
 		return err
 	}
@@ -40,14 +68,15 @@ func Build(full bool) error {
 	// Do full build when:
 	// * build-info.json's version is different with current version
 	// * build-info.json's strategy is different with config
-	// * config strategy is symlink
+	// * build-info.json's slim is different with config
 	if buildInfo.Version != currentBuildInfoVersion ||
 		buildInfo.Strategy != cfg.Build.Strategy ||
-		cfg.Build.Strategy == config.SymlinkBuilder {
+		buildInfo.Slim != slim.String() {
 		full = true
 	}
 	buildInfo.Version = currentBuildInfoVersion
 	buildInfo.Strategy = cfg.Build.Strategy
+	buildInfo.Slim = slim.String()
 
 	// Put repos into map to be able to search with O(1).
 	// Use empty build-info.json map if the -full option was given
@@ -66,24 +95,48 @@ func Build(full bool) error {
 		logger.Info("Building " + optDir + " directory ...")
 	}
 
-	// Remove ~/.vim/pack/volt/ if -full option was given
-	if full {
-		vimVoltDir := pathutil.VimVoltDir()
-		os.RemoveAll(vimVoltDir)
-		if pathutil.Exists(vimVoltDir) {
-			return errors.New("failed to remove " + vimVoltDir)
+	// Build in the staging directory, which is empty if -full option was
+	// given, and swap it with the runtime directory
+	if err = stageRuntime(base, full); err != nil {
+		os.RemoveAll(pathutil.VimVoltStagingDir())
+		return errors.New("could not create staging directory: " + err.Error())
+	}
+	pathutil.SetVimVoltDir(pathutil.VimVoltStagingDir())
+	err = blder.Build(buildInfo, buildReposMap)
+	pathutil.SetVimVoltDir("")
+	if err == nil {
+		err = swapRuntime(dir)
+	}
+	if err != nil {
+		os.RemoveAll(pathutil.VimVoltStagingDir())
+		return err
+	}
+
+	// Point ~/.vim/pack/volt/ to the runtime of current profile
+	if cachedProfiles > 0 {
+		if err = linkRuntime(dir); err != nil {
+			return err
 		}
+		pruneRuntimes(lockJSON, dir, cachedProfiles)
 	}
 
-	return blder.Build(buildInfo, buildReposMap)
+	// Validate build output
+	reposList, err := lockJSON.GetCurrentReposList()
+	if err != nil {
+		return err
+	}
+	for _, problem := range validateOutput(reposList) {
+		logger.Warn(problem)
+	}
+	return nil
 }
 
-func getBuilder(strategy string) (Builder, error) {
+func getBuilder(strategy string, jobs int, slim *slimFilter, deterministic bool) (Builder, error) {
 	switch strategy {
 	case config.SymlinkBuilder:
-		return &symlinkBuilder{}, nil
+		return &symlinkBuilder{BaseBuilder: newBaseBuilder(jobs, deterministic), slim: slim}, nil
 	case config.CopyBuilder:
-		return &copyBuilder{}, nil
+		return &copyBuilder{BaseBuilder: newBaseBuilder(jobs, deterministic), slim: slim}, nil
 	default:
 		return nil, errors.New("unknown builder type: " + strategy)
 	}
@@ -0,0 +1,326 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/subcmd/buildinfo"
+)
+
+// setUpBuildEnv sets $HOME and $VOLTPATH to temporary directories until the
+// test finishes, and writes config.toml with strategy and jobs, and lock.json
+// with n static repositories which have help files. It returns the paths of
+// the repositories in the order of lock.json.
+func setUpBuildEnv(t *testing.T, strategy string, jobs, n int) []pathutil.ReposPath {
+	t.Helper()
+	tempDir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"HOME", "VOLTPATH"} {
+		old, exists := os.LookupEnv(name)
+		name := name
+		t.Cleanup(func() {
+			if exists {
+				os.Setenv(name, old)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+	os.Setenv("HOME", filepath.Join(tempDir, "home"))
+	os.Setenv("VOLTPATH", filepath.Join(tempDir, "volt"))
+
+	cfg := fmt.Sprintf("[build]\nstrategy = %q\njobs = %d\n", strategy, jobs)
+	if err = os.MkdirAll(pathutil.VoltPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(pathutil.ConfigTOML(), []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// lock.json does not exist yet, so this returns the initial one
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := lockJSON.Profiles.FindByName(lockJSON.CurrentProfileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Add in reverse order of the paths, so the order of lock.json is not the
+	// order of the paths
+	reposPathList := make([]pathutil.ReposPath, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		reposPath := pathutil.ReposPath(fmt.Sprintf("localhost/local/plugin%02d", i))
+		docDir := filepath.Join(reposPath.FullPath(), "doc")
+		if err = os.MkdirAll(docDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("plugin%02d.txt", i)
+		if err = ioutil.WriteFile(filepath.Join(docDir, name), []byte("*"+name+"*\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		lockJSON.Repos = append(lockJSON.Repos, lockjson.Repos{
+			Type:    lockjson.ReposStaticType,
+			Path:    reposPath,
+			Version: "2018-01-01T00:00:00Z",
+		})
+		profile.ReposPath = append(profile.ReposPath, reposPath)
+		reposPathList = append(reposPathList, reposPath)
+	}
+	if err = lockJSON.Write(); err != nil {
+		t.Fatal(err)
+	}
+	return reposPathList
+}
+
+// Run with -race to detect data races between jobs.
+// * Repositories are installed by jobs run at the same time, and tags files
+//   are generated for all of them
+// * build-info.json lists repositories in the order of lock.json regardless
+//   of the order in which the jobs finished
+// * Removed repositories are removed from vim dir
+func TestBuildParallel(t *testing.T) {
+	for _, strategy := range []string{"symlink", "copy"} {
+		t.Run(strategy, func(t *testing.T) {
+			reposPathList := setUpBuildEnv(t, strategy, 4, 16)
+
+			if err := Build(true); err != nil {
+				t.Fatal("Build() failed: " + err.Error())
+			}
+			for _, reposPath := range reposPathList {
+				tags := filepath.Join(reposPath.EncodeToPlugDirName(), "doc", "tags")
+				if !pathutil.Exists(tags) {
+					t.Errorf("tags file was not generated: %s", tags)
+				}
+			}
+			buildInfo, err := buildinfo.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual := make([]pathutil.ReposPath, 0, len(buildInfo.Repos))
+			for i := range buildInfo.Repos {
+				actual = append(actual, buildInfo.Repos[i].Path)
+			}
+			if !reflect.DeepEqual(actual, reposPathList) {
+				t.Errorf("expected repositories in build-info.json are %v but got %v", reposPathList, actual)
+			}
+
+			// Remove half of the repositories from lock.json
+			lockJSON, err := lockjson.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			removed := reposPathList[len(reposPathList)/2:]
+			for _, reposPath := range removed {
+				lockJSON.Repos.RemoveAllReposPath(reposPath)
+				lockJSON.Profiles.RemoveAllReposPath(reposPath)
+			}
+			if err = lockJSON.Write(); err != nil {
+				t.Fatal(err)
+			}
+			if err = Build(false); err != nil {
+				t.Fatal("Build() failed: " + err.Error())
+			}
+			for _, reposPath := range removed {
+				if pathutil.Exists(reposPath.EncodeToPlugDirName()) {
+					t.Errorf("removed repository was not removed from vim dir: %s", reposPath)
+				}
+			}
+			for _, reposPath := range reposPathList[:len(reposPathList)/2] {
+				if !pathutil.Exists(reposPath.EncodeToPlugDirName()) {
+					t.Errorf("repository was removed from vim dir: %s", reposPath)
+				}
+			}
+		})
+	}
+}
+
+// * Files are copied if symlinks cannot be created
+// * Copied static repositories are installed again even if they were not
+//   changed in lock.json
+func TestSymlinkFallbackToCopy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlink builder creates junctions on Windows")
+	}
+	reposPathList := setUpBuildEnv(t, "symlink", 2, 2)
+	oldSymlink := osSymlink
+	defer func() { osSymlink = oldSymlink }()
+	osSymlink = func(oldname, newname string) error {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: errors.New("not supported")}
+	}
+
+	if err := Build(true); err != nil {
+		t.Fatal("Build() failed: " + err.Error())
+	}
+	for _, reposPath := range reposPathList {
+		dir := reposPath.EncodeToPlugDirName()
+		fi, err := os.Lstat(dir)
+		if err != nil {
+			t.Errorf("repository was not installed: %s", dir)
+			continue
+		}
+		if !fi.IsDir() {
+			t.Errorf("expected %s is a directory but got mode %s", dir, fi.Mode())
+		}
+		if !pathutil.Exists(filepath.Join(dir, "doc", "tags")) {
+			t.Errorf("tags file was not generated in %s", dir)
+		}
+	}
+
+	// Change a file of the static repository, and build again
+	reposPath := reposPathList[0]
+	name := filepath.Join("doc", "changed.txt")
+	if err := ioutil.WriteFile(filepath.Join(reposPath.FullPath(), name), []byte("*changed.txt*\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Build(false); err != nil {
+		t.Fatal("Build() failed: " + err.Error())
+	}
+	if !pathutil.Exists(filepath.Join(reposPath.EncodeToPlugDirName(), name)) {
+		t.Errorf("changed file was not copied: %s", name)
+	}
+}
+
+// readTree returns the contents of the files under dir by the relative paths.
+// Symlinks are followed, because "(vim dir)/pack/volt" may be a symlink to
+// the runtime of current profile.
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	tree := make(map[string]string)
+	var walk func(dir, prefix string)
+	walk = func(dir, prefix string) {
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, info := range infos {
+			path := filepath.Join(dir, info.Name())
+			rel := filepath.Join(prefix, info.Name())
+			fi, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.IsDir() {
+				walk(path, rel)
+				continue
+			}
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tree[rel] = string(content)
+		}
+	}
+	walk(dir, "")
+	return tree
+}
+
+// * lock.json is sorted if "build.deterministic" is true
+// * CRLF line endings of profile vimrc are converted to LF
+// * The versions of static repositories in build-info.json are the latest
+//   mtime of the repositories, not the time of the build
+// * The build output is the same every time
+func TestBuildDeterministic(t *testing.T) {
+	reposPathList := setUpBuildEnv(t, "copy", 4, 4)
+	f, err := os.OpenFile(pathutil.ConfigTOML(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteString("deterministic = true\n")
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write lock.json again to sort it
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = lockJSON.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if lockJSON, err = lockjson.Read(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(lockJSON.Repos); i++ {
+		if lockJSON.Repos[i-1].Path > lockJSON.Repos[i].Path {
+			t.Errorf("repos in lock.json are not sorted: %s > %s", lockJSON.Repos[i-1].Path, lockJSON.Repos[i].Path)
+		}
+	}
+	profile, err := lockJSON.Profiles.FindByName(lockJSON.CurrentProfileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(profile.ReposPath); i++ {
+		if profile.ReposPath[i-1] > profile.ReposPath[i] {
+			t.Errorf("repos_path in lock.json are not sorted: %s > %s", profile.ReposPath[i-1], profile.ReposPath[i])
+		}
+	}
+
+	// Write profile vimrc with CRLF line endings
+	rcDir := pathutil.RCDir(lockJSON.CurrentProfileName)
+	if err = os.MkdirAll(rcDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(rcDir, pathutil.ProfileVimrc), []byte("echo 1\r\necho 2\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set mtime of all files of the repositories
+	mtime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, reposPath := range reposPathList {
+		err = filepath.Walk(reposPath.FullPath(), func(path string, _ os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			return os.Chtimes(path, mtime, mtime)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = Build(true); err != nil {
+		t.Fatal("Build() failed: " + err.Error())
+	}
+	content, err := ioutil.ReadFile(filepath.Join(pathutil.VimDir(), pathutil.Vimrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "\r") {
+		t.Errorf("CRLF line endings of vimrc were not converted: %q", string(content))
+	}
+	buildInfo, err := buildinfo.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range buildInfo.Repos {
+		if expected := mtime.Format(time.RFC3339Nano); buildInfo.Repos[i].Version != expected {
+			t.Errorf("expected version of %s is %q but got %q", buildInfo.Repos[i].Path, expected, buildInfo.Repos[i].Version)
+		}
+	}
+
+	first := readTree(t, pathutil.VimDir())
+	if err = Build(true); err != nil {
+		t.Fatal("Build() failed: " + err.Error())
+	}
+	if second := readTree(t, pathutil.VimDir()); !reflect.DeepEqual(first, second) {
+		t.Error("the build output was changed by building again")
+	}
+}
@@ -0,0 +1,77 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vim-volt/volt/lockjson"
+)
+
+// reposCacheKey returns the key of the build output of repos.
+// It is changed when the version of the repository, its plugconf files,
+// its help files, or the format of the build output (currentBuildInfoVersion)
+// is changed.
+func reposCacheKey(repos *lockjson.Repos) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s\x00", currentBuildInfoVersion,
+		repos.Type, repos.Version, strings.Join(repos.SparseCheckout, "\n"))
+	writeFileHash(h, repos.Path.Plugconf())
+	writeFileHash(h, repos.Path.LuaPlugconf())
+	// Help files can be changed without changing version (static
+	// repositories, or the files changed by build hooks)
+	writeHelpFilesStamp(h, filepath.Join(repos.Path.FullPath(), "doc"))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bundleCacheKey returns the key of bundled plugconf. It is changed when
+// one of the inputs of bundled plugconf is changed: the repositories of
+// current profile (and their order), their plugconf files, current profile
+// name, vimrc and gvimrc, and "build.compile_lua", "build.merge_ftdetect",
+// and "build.deterministic" of config.toml.
+func bundleCacheKey(reposKeys []string, profileName, vimrc, gvimrc string, compileLua, mergeFtdetect, deterministic bool) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s\x00%v\x00%v\x00%v\x00", currentBuildInfoVersion,
+		profileName, vimrc, gvimrc, compileLua, mergeFtdetect, deterministic)
+	for _, key := range reposKeys {
+		fmt.Fprintf(h, "%s\x00", key)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeFileHash(h hash.Hash, path string) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		h.Write([]byte("-\x00"))
+		return
+	}
+	sum := sha256.Sum256(content)
+	fmt.Fprintf(h, "%x\x00", sum)
+}
+
+// writeHelpFilesStamp writes the names, sizes, and modification times of help
+// files in docDir (tags files generated by volt are excluded).
+func writeHelpFilesStamp(h hash.Hash, docDir string) {
+	tagsFiles, err := helptagsFiles(docDir)
+	if err != nil {
+		return
+	}
+	names := make([]string, 0, 8)
+	for _, helpFiles := range tagsFiles {
+		names = append(names, helpFiles...)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fi, err := os.Stat(filepath.Join(docDir, name))
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", name, fi.Size(), fi.ModTime().UnixNano())
+	}
+}
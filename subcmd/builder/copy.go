@@ -3,8 +3,10 @@ package builder
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"time"
 
@@ -14,23 +16,30 @@ import (
 	"github.com/vim-volt/volt/lockjson"
 	"github.com/vim-volt/volt/logger"
 	"github.com/vim-volt/volt/pathutil"
-	"github.com/vim-volt/volt/plugconf"
+	"github.com/vim-volt/volt/subcmd/buildhook"
 	"github.com/vim-volt/volt/subcmd/buildinfo"
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
 type copyBuilder struct {
 	BaseBuilder
+	// slim decides the files which are not copied ("build.slim" of
+	// config.toml)
+	slim *slimFilter
+	// hooked is the repositories which build hooks were run in
+	hooked buildhook.State
 }
 
 func (builder *copyBuilder) Build(buildInfo *buildinfo.BuildInfo, buildReposMap map[pathutil.ReposPath]*buildinfo.Repos) error {
-	// Exit if vim executable was not found in PATH
-	vimExePath, err := pathutil.VimExecutable()
+	// Files made by build hooks are not in git objects
+	hooked, err := buildhook.ReadState()
 	if err != nil {
-		return err
+		return errors.New("could not read the state of build hooks: " + err.Error())
 	}
+	builder.hooked = hooked
 
 	// Read lock.json
 	lockJSON, err := lockjson.Read()
@@ -43,6 +52,9 @@ func (builder *copyBuilder) Build(buildInfo *buildinfo.BuildInfo, buildReposMap
 	if err != nil {
 		return err
 	}
+	// The plugins of projects are also installed to opt dir
+	projects, projectReposList := readProjects(lockJSON, reposList)
+	optReposList := append(append(lockjson.ReposList{}, reposList...), projectReposList...)
 
 	logger.Info("Installing vimrc and gvimrc ...")
 
@@ -69,10 +81,10 @@ func (builder *copyBuilder) Build(buildInfo *buildinfo.BuildInfo, buildReposMap
 	}
 
 	// Copy volt repos files to optDir
-	copyDone, copyCount := builder.copyReposList(buildReposMap, reposList, optDir, vimExePath)
+	copyDone, copyCount := builder.copyReposList(buildReposMap, optReposList, optDir)
 
 	// Remove vim repos not found in lock.json current repos list
-	removeDone, removeCount := builder.removeReposList(reposList, reposDirList)
+	removeDone, removeCount := builder.removeReposList(optReposList, reposDirList)
 
 	// Wait copy
 	var copyModified bool
@@ -96,38 +108,25 @@ func (builder *copyBuilder) Build(buildInfo *buildinfo.BuildInfo, buildReposMap
 	if copyErr != nil || removeErr != nil {
 		return multierror.Append(copyErr, removeErr).ErrorOrNil()
 	}
+	buildInfo.Repos.SortByLockJSON(optReposList)
 
 	// Write bundled plugconf file
-	rcDir := pathutil.RCDir(lockJSON.CurrentProfileName)
-	vimrc := ""
-	if path := filepath.Join(rcDir, pathutil.ProfileVimrc); pathutil.Exists(path) {
-		vimrc = path
-	}
-	gvimrc := ""
-	if path := filepath.Join(rcDir, pathutil.ProfileGvimrc); pathutil.Exists(path) {
-		gvimrc = path
-	}
-	plugconfs, parseErr := plugconf.ParseMultiPlugconf(reposList)
-	if parseErr.HasErrs() {
-		// Vim script parse errors / other errors
-		return parseErr.Errors()
-	}
-	if parseErr.HasWarns() {
-		// Vim script parse warnings
-		merr := parseErr.Warns()
-		for _, err := range merr.Errors {
-			logger.Warn(err)
-		}
-	}
-	content, err := plugconfs.GenerateBundlePlugconf(vimrc, gvimrc)
-	os.MkdirAll(filepath.Dir(pathutil.BundledPlugConf()), 0755)
-	err = ioutil.WriteFile(pathutil.BundledPlugConf(), content, 0644)
+	reposKeys := make([]string, 0, len(reposList))
+	for i := range reposList {
+		reposKeys = append(reposKeys, reposCacheKey(&reposList[i]))
+	}
+	bundleModified, err := builder.installBundledPlugconf(buildInfo, lockJSON.CurrentProfileName, reposList, reposKeys)
 	if err != nil {
 		return err
 	}
 
+	// Write the plugconf files of projects
+	if err = builder.installProjectPlugconfs(projects, lockJSON.CurrentProfileName, reposList, projectReposList); err != nil {
+		return err
+	}
+
 	// Write to build-info.json if buildInfo was modified
-	if copyModified || removeModified {
+	if copyModified || removeModified || bundleModified {
 		err = buildInfo.Write()
 		if err != nil {
 			return err
@@ -137,12 +136,12 @@ func (builder *copyBuilder) Build(buildInfo *buildinfo.BuildInfo, buildReposMap
 	return nil
 }
 
-func (builder *copyBuilder) copyReposList(buildReposMap map[pathutil.ReposPath]*buildinfo.Repos, reposList []lockjson.Repos, optDir, vimExePath string) (chan actionReposResult, int) {
+func (builder *copyBuilder) copyReposList(buildReposMap map[pathutil.ReposPath]*buildinfo.Repos, reposList []lockjson.Repos, optDir string) (chan actionReposResult, int) {
 	copyDone := make(chan actionReposResult, len(reposList))
 	copyCount := 0
 	for i := range reposList {
 		if reposList[i].Type == lockjson.ReposGitType {
-			n, err := builder.copyReposGit(&reposList[i], buildReposMap[reposList[i].Path], vimExePath, copyDone)
+			n, err := builder.copyReposGit(&reposList[i], buildReposMap[reposList[i].Path], copyDone)
 			if err != nil {
 				copyDone <- actionReposResult{
 					err:   errors.New("failed to copy " + string(reposList[i].Type) + " repos: " + err.Error()),
@@ -151,7 +150,7 @@ func (builder *copyBuilder) copyReposList(buildReposMap map[pathutil.ReposPath]*
 			}
 			copyCount += n
 		} else if reposList[i].Type == lockjson.ReposStaticType {
-			copyCount += builder.copyReposStatic(&reposList[i], buildReposMap[reposList[i].Path], optDir, vimExePath, copyDone)
+			copyCount += builder.copyReposStatic(&reposList[i], buildReposMap[reposList[i].Path], optDir, copyDone)
 		} else {
 			copyDone <- actionReposResult{
 				err:   errors.New("invalid repository type: " + string(reposList[i].Type)),
@@ -162,7 +161,7 @@ func (builder *copyBuilder) copyReposList(buildReposMap map[pathutil.ReposPath]*
 	return copyDone, copyCount
 }
 
-func (builder *copyBuilder) copyReposGit(repos *lockjson.Repos, buildRepos *buildinfo.Repos, vimExePath string, done chan actionReposResult) (int, error) {
+func (builder *copyBuilder) copyReposGit(repos *lockjson.Repos, buildRepos *buildinfo.Repos, done chan actionReposResult) (int, error) {
 	src := repos.Path.FullPath()
 
 	// Open ~/volt/repos/{repos}
@@ -198,17 +197,25 @@ func (builder *copyBuilder) copyReposGit(repos *lockjson.Repos, buildRepos *buil
 	if builder.hasChangedGitRepos(repos, buildRepos, !isClean) {
 		// Copy files from .git/objects/... when:
 		// * bare repository
-		// * or worktree is clean
-		copyFromGitObjects := cfg.Core.IsBare || isClean
-		go builder.updateGitRepos(repos, r, copyFromGitObjects, vimExePath, done)
+		// * or worktree is clean (and not sparse checkout, because
+		//   .git/objects/... has also excluded files)
+		// and not partial clone (.git/objects/... may not have files)
+		// and build hooks were not run
+		_, hooked := builder.hooked[repos.Path]
+		copyFromGitObjects := (cfg.Core.IsBare || (isClean && len(repos.SparseCheckout) == 0)) &&
+			repos.CloneFilter == "" && !hooked
+		// The files of git objects are linked from the worktree when it has
+		// the same files (see updateBareGitRepos)
+		linkWorktree := !cfg.Core.IsBare && isClean && head == repos.Version
+		builder.runJob(func() { builder.updateGitRepos(repos, r, copyFromGitObjects, linkWorktree, done) })
 		return 1, nil
 	}
 	return 0, nil
 }
 
-func (builder *copyBuilder) copyReposStatic(repos *lockjson.Repos, buildRepos *buildinfo.Repos, optDir, vimExePath string, done chan actionReposResult) int {
+func (builder *copyBuilder) copyReposStatic(repos *lockjson.Repos, buildRepos *buildinfo.Repos, optDir string, done chan actionReposResult) int {
 	if builder.hasChangedStaticRepos(repos, buildRepos, optDir) {
-		go builder.updateStaticRepos(repos, vimExePath, done)
+		builder.runJob(func() { builder.updateStaticRepos(repos, done) })
 		return 1
 	}
 	return 0
@@ -225,22 +232,24 @@ func (builder *copyBuilder) removeReposList(reposList lockjson.ReposList, reposD
 	}
 	removeDone := make(chan actionReposResult, len(removeList))
 	for i := range removeList {
-		go func(reposPath pathutil.ReposPath) {
+		reposPath := removeList[i]
+		builder.runJob(func() {
 			err := os.RemoveAll(reposPath.EncodeToPlugDirName())
 			logger.Info("Removing " + reposPath + " ... Done.")
 			removeDone <- actionReposResult{
 				err:   err,
 				repos: &lockjson.Repos{Path: reposPath},
 			}
-		}(removeList[i])
+		})
 	}
 	return removeDone, len(removeList)
 }
 
 func (*copyBuilder) waitCopyRepos(copyDone chan actionReposResult, copyCount int, callback func(*actionReposResult) error) *multierror.Error {
 	var merr *multierror.Error
-	for i := 0; i < copyCount; i++ {
-		result := <-copyDone
+	results := receiveResults(copyDone, copyCount)
+	for i := range results {
+		result := results[i]
 		if result.err != nil {
 			merr = multierror.Append(
 				merr,
@@ -257,7 +266,7 @@ func (*copyBuilder) waitCopyRepos(copyDone chan actionReposResult, copyCount int
 	return merr
 }
 
-func (*copyBuilder) constructBuildInfo(buildInfo *buildinfo.BuildInfo, result *actionReposResult) {
+func (builder *copyBuilder) constructBuildInfo(buildInfo *buildinfo.BuildInfo, result *actionReposResult) {
 	if result.repos.Type == lockjson.ReposGitType {
 		r := buildInfo.Repos.FindByReposPath(result.repos.Path)
 		if r != nil {
@@ -275,9 +284,10 @@ func (*copyBuilder) constructBuildInfo(buildInfo *buildinfo.BuildInfo, result *a
 			)
 		}
 	} else if result.repos.Type == lockjson.ReposStaticType {
+		version := builder.staticReposVersion(result.repos)
 		r := buildInfo.Repos.FindByReposPath(result.repos.Path)
 		if r != nil {
-			r.Version = time.Now().Format(time.RFC3339)
+			r.Version = version
 			r.Files = result.files
 		} else {
 			buildInfo.Repos = append(
@@ -285,7 +295,7 @@ func (*copyBuilder) constructBuildInfo(buildInfo *buildinfo.BuildInfo, result *a
 				buildinfo.Repos{
 					Type:    lockjson.ReposStaticType,
 					Path:    result.repos.Path,
-					Version: time.Now().Format(time.RFC3339),
+					Version: version,
 					Files:   result.files,
 				},
 			)
@@ -295,10 +305,24 @@ func (*copyBuilder) constructBuildInfo(buildInfo *buildinfo.BuildInfo, result *a
 	}
 }
 
+// staticReposVersion returns the version of static repository in
+// build-info.json, which is compared with the latest mtime of the repository
+// (see hasChangedStaticRepos). It is the time of the build, or the latest
+// mtime of the repository if "build.deterministic" is true.
+func (builder *copyBuilder) staticReposVersion(repos *lockjson.Repos) string {
+	if builder.deterministic {
+		if mtime, err := builder.getLatestModTime(repos.Path.FullPath()); err == nil {
+			return mtime.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return time.Now().Format(time.RFC3339)
+}
+
 func (*copyBuilder) waitRemoveRepos(removeDone chan actionReposResult, removeCount int, callback func(result *actionReposResult)) *multierror.Error {
 	var merr *multierror.Error
-	for i := 0; i < removeCount; i++ {
-		result := <-removeDone
+	results := receiveResults(removeDone, removeCount)
+	for i := range results {
+		result := results[i]
 		if result.err != nil {
 			target := "files"
 			if result.repos != nil {
@@ -347,7 +371,7 @@ func (*copyBuilder) hasChangedGitRepos(repos *lockjson.Repos, buildRepos *buildi
 }
 
 // Remove ~/.vim/volt/opt/{repos} and copy from ~/volt/repos/{repos}
-func (builder *copyBuilder) updateGitRepos(repos *lockjson.Repos, r *git.Repository, copyFromGitObjects bool, vimExePath string, done chan actionReposResult) {
+func (builder *copyBuilder) updateGitRepos(repos *lockjson.Repos, r *git.Repository, copyFromGitObjects, linkWorktree bool, done chan actionReposResult) {
 	src := repos.Path.FullPath()
 	dst := repos.Path.EncodeToPlugDirName()
 
@@ -364,14 +388,22 @@ func (builder *copyBuilder) updateGitRepos(repos *lockjson.Repos, r *git.Reposit
 
 	if copyFromGitObjects {
 		logger.Debug("Copy from git objects: " + repos.Path)
-		builder.updateBareGitRepos(r, src, dst, repos, vimExePath, done)
+		worktree := ""
+		if linkWorktree {
+			worktree = src
+		}
+		builder.updateBareGitRepos(r, worktree, dst, repos, done)
 	} else {
 		logger.Debug("Copy from filesystem: " + repos.Path)
-		builder.updateNonBareGitRepos(r, src, dst, repos, vimExePath, done)
+		builder.updateNonBareGitRepos(r, src, dst, repos, done)
 	}
 }
 
-func (builder *copyBuilder) updateBareGitRepos(r *git.Repository, src, dst string, repos *lockjson.Repos, vimExePath string, done chan actionReposResult) {
+// updateBareGitRepos writes the files of the locked commit to dst.
+// If worktree is not "", the files are linked (see fileutil.TryLinkFile) from
+// worktree, which must have the same files as the locked commit, instead of
+// writing the contents of git objects.
+func (builder *copyBuilder) updateBareGitRepos(r *git.Repository, worktree, dst string, repos *lockjson.Repos, done chan actionReposResult) {
 	// Get locked commit hash
 	commit := plumbing.NewHash(repos.Version)
 	commitObj, err := r.CommitObject(commit)
@@ -394,25 +426,52 @@ func (builder *copyBuilder) updateBareGitRepos(r *git.Repository, src, dst strin
 	}
 
 	// Copy files
+	// (directories are created only once, and one buffer is used for all files)
 	files := make(buildinfo.FileMap, 512)
-	err = tree.Files().ForEach(func(file *object.File) error {
-		osMode, err := file.Mode.ToOSFileMode()
-		if err != nil {
-			return errors.New("failed to convert file mode: " + err.Error())
-		}
-
-		contents, err := file.Contents()
-		if err != nil {
-			return errors.New("failed to get file contents: " + err.Error())
-		}
+	created := make(map[string]bool, 64)
+	buf := make([]byte, 32*1024)
+	// Walk the tree entries instead of tree.Files() not to read the blobs of
+	// the linked files
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	err = func() error {
+		for {
+			name, entry, err := walker.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if entry.Mode == filemode.Dir || entry.Mode == filemode.Submodule {
+				continue
+			}
+			osMode, err := entry.Mode.ToOSFileMode()
+			if err != nil {
+				return errors.New("failed to convert file mode: " + err.Error())
+			}
 
-		filename := filepath.Join(dst, file.Name)
-		os.MkdirAll(filepath.Dir(filename), 0755)
-		ioutil.WriteFile(filename, []byte(contents), osMode)
+			if builder.slim.excluded(name, false) {
+				continue
+			}
+			filename := filepath.Join(dst, name)
+			if dir := filepath.Dir(filename); !created[dir] {
+				if err = os.MkdirAll(dir, 0755); err != nil {
+					return err
+				}
+				created[dir] = true
+			}
+			// Symlinks are written as regular files of the link target
+			if worktree == "" || entry.Mode == filemode.Symlink ||
+				fileutil.TryLinkFile(filepath.Join(worktree, name), filename, buf, osMode) != nil {
+				if err = builder.writeBlob(r, entry.Hash, filename, osMode, buf); err != nil {
+					return errors.New("failed to write " + name + ": " + err.Error())
+				}
+			}
 
-		files[file.Name] = file.Hash.String() // blob hash
-		return nil
-	})
+			files[name] = entry.Hash.String() // blob hash
+		}
+	}()
 	if err != nil {
 		done <- actionReposResult{
 			err:   err,
@@ -422,7 +481,7 @@ func (builder *copyBuilder) updateBareGitRepos(r *git.Repository, src, dst strin
 	}
 
 	// Run ":helptags" to generate tags file
-	err = builder.helptags(repos.Path, vimExePath)
+	err = builder.helptags(repos.Path)
 	if err != nil {
 		done <- actionReposResult{
 			err:   err,
@@ -438,10 +497,32 @@ func (builder *copyBuilder) updateBareGitRepos(r *git.Repository, src, dst strin
 	}
 }
 
+// writeBlob writes the contents of the blob of hash to filename.
+func (*copyBuilder) writeBlob(repos *git.Repository, hash plumbing.Hash, filename string, perm os.FileMode, buf []byte) error {
+	blob, err := repos.BlobObject(hash)
+	if err != nil {
+		return err
+	}
+	r, err := blob.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyBuffer(w, r, buf)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
 // BuildModeInvalidType is invalid types of files which copy builder cannot handle.
 var BuildModeInvalidType = os.ModeSymlink | os.ModeNamedPipe | os.ModeSocket | os.ModeDevice
 
-func (builder *copyBuilder) updateNonBareGitRepos(r *git.Repository, src, dst string, repos *lockjson.Repos, vimExePath string, done chan actionReposResult) {
+func (builder *copyBuilder) updateNonBareGitRepos(r *git.Repository, src, dst string, repos *lockjson.Repos, done chan actionReposResult) {
 	files, err := ioutil.ReadDir(src)
 	if err != nil {
 		done <- actionReposResult{
@@ -462,6 +543,9 @@ func (builder *copyBuilder) updateNonBareGitRepos(r *git.Repository, src, dst st
 			// Currenly skip the invalid files...
 			continue
 		}
+		if builder.slim.excluded(file.Name(), file.IsDir()) {
+			continue
+		}
 		if !created[dst] {
 			os.MkdirAll(dst, 0755)
 			created[dst] = true
@@ -470,7 +554,7 @@ func (builder *copyBuilder) updateNonBareGitRepos(r *git.Repository, src, dst st
 		to := filepath.Join(dst, file.Name())
 		var err error
 		if file.IsDir() {
-			err = fileutil.TryLinkDir(from, to, buf, file.Mode(), BuildModeInvalidType)
+			err = builder.linkDir(from, to, file.Name(), buf, file.Mode())
 		} else {
 			err = fileutil.TryLinkFile(from, to, buf, file.Mode())
 		}
@@ -484,7 +568,7 @@ func (builder *copyBuilder) updateNonBareGitRepos(r *git.Repository, src, dst st
 	}
 
 	// Run ":helptags" to generate tags file
-	err = builder.helptags(repos.Path, vimExePath)
+	err = builder.helptags(repos.Path)
 	if err != nil {
 		done <- actionReposResult{
 			err:   err,
@@ -500,6 +584,40 @@ func (builder *copyBuilder) updateNonBareGitRepos(r *git.Repository, src, dst st
 	}
 }
 
+// linkDir copies (or hard-links) src directory to dst directory, except the
+// files excluded by "build.slim" of config.toml. name is the slash-separated
+// path of src relative to the repository ("" for the repository itself).
+func (builder *copyBuilder) linkDir(src, dst, name string, buf []byte, perm os.FileMode) error {
+	if builder.slim == nil {
+		return fileutil.TryLinkDir(src, dst, buf, perm, BuildModeInvalidType)
+	}
+	if err := os.MkdirAll(dst, perm); err != nil {
+		return err
+	}
+	entries, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		entryName := path.Join(name, entries[i].Name())
+		if entries[i].Mode()&BuildModeInvalidType != 0 ||
+			builder.slim.excluded(entryName, entries[i].IsDir()) {
+			continue
+		}
+		from := filepath.Join(src, entries[i].Name())
+		to := filepath.Join(dst, entries[i].Name())
+		if entries[i].IsDir() {
+			err = builder.linkDir(from, to, entryName, buf, entries[i].Mode())
+		} else {
+			err = fileutil.TryLinkFile(from, to, buf, entries[i].Mode())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (builder *copyBuilder) hasChangedStaticRepos(repos *lockjson.Repos, buildRepos *buildinfo.Repos, optDir string) bool {
 	if buildRepos == nil { // Full build
 		return true
@@ -531,7 +649,7 @@ func (builder *copyBuilder) hasChangedStaticRepos(repos *lockjson.Repos, buildRe
 }
 
 // Remove ~/.vim/volt/opt/{repos} and copy from ~/volt/repos/{repos}
-func (builder *copyBuilder) updateStaticRepos(repos *lockjson.Repos, vimExePath string, done chan actionReposResult) {
+func (builder *copyBuilder) updateStaticRepos(repos *lockjson.Repos, done chan actionReposResult) {
 	src := repos.Path.FullPath()
 	dst := repos.Path.EncodeToPlugDirName()
 
@@ -563,7 +681,7 @@ func (builder *copyBuilder) updateStaticRepos(repos *lockjson.Repos, vimExePath
 		}
 		return
 	}
-	err = fileutil.TryLinkDir(src, dst, buf, si.Mode(), BuildModeInvalidType)
+	err = builder.linkDir(src, dst, "", buf, si.Mode())
 	if err != nil {
 		done <- actionReposResult{
 			err:   errors.New("failed to copy static directory: " + err.Error()),
@@ -573,7 +691,7 @@ func (builder *copyBuilder) updateStaticRepos(repos *lockjson.Repos, vimExePath
 	}
 
 	// Run ":helptags" to generate tags file
-	err = builder.helptags(repos.Path, vimExePath)
+	err = builder.helptags(repos.Path)
 	if err != nil {
 		done <- actionReposResult{
 			err:   err,
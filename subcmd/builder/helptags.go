@@ -0,0 +1,258 @@
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/vim-volt/volt/diskcache"
+	"github.com/vim-volt/volt/logger"
+)
+
+// helptagsCacheNamespace is the namespace of diskcache which stores the tags
+// files of each repository (see makeHelptags).
+const helptagsCacheNamespace = "helptags"
+
+// helptagsCacheTTL is long because the cache is validated by the hash of
+// help files.
+const helptagsCacheTTL = 90 * 24 * time.Hour
+
+// cachedHelptags is the tags files generated from the help files of DocHash.
+type cachedHelptags struct {
+	DocHash string `json:"doc_hash"`
+	// Tags maps the name of tags files to their content
+	Tags map[string]string `json:"tags"`
+}
+
+// helptagsFiles returns help files in docDir grouped by tags file name like
+// ":helptags" of Vim: "tags" for "*.txt" files, and "tags-xx" for "*.xxx"
+// files (translated help files of language "xx").
+func helptagsFiles(docDir string) (map[string][]string, error) {
+	files, err := ioutil.ReadDir(docDir)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]string, 2)
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		name := file.Name()
+		ext := filepath.Ext(name)
+		switch {
+		case ext == ".txt":
+			result["tags"] = append(result["tags"], name)
+		case len(ext) == 4 && (ext[3] == 'x' || ext[3] == 'X'):
+			tagsName := "tags-" + strings.ToLower(ext[1:3])
+			result[tagsName] = append(result[tagsName], name)
+		}
+	}
+	return result, nil
+}
+
+// makeHelptags generates tags files of help files in docDir in the same
+// format as ":helptags" of Vim.
+// The tags files are generated again only if the contents of help files were
+// changed since the last time for cacheKey (the path of the repository).
+// Otherwise the cached tags files are written.
+// It does nothing if docDir has no help files.
+func makeHelptags(cacheKey, docDir string) error {
+	tagsFiles, err := helptagsFiles(docDir)
+	if err != nil {
+		return err
+	}
+	docHash, err := helpFilesHash(docDir, tagsFiles)
+	if err != nil {
+		return err
+	}
+	var cached cachedHelptags
+	if diskcache.Get(helptagsCacheNamespace, cacheKey, helptagsCacheTTL, &cached) &&
+		cached.DocHash == docHash {
+		logger.Debugf("Help files in '%s' are not changed ... skip", docDir)
+		return writeTagsFiles(docDir, cached.Tags)
+	}
+
+	tags := make(map[string]string, len(tagsFiles))
+	for tagsName, helpFiles := range tagsFiles {
+		content, err := makeTagsContent(docDir, helpFiles)
+		if err != nil {
+			return err
+		}
+		tags[tagsName] = string(content)
+	}
+	if err = writeTagsFiles(docDir, tags); err != nil {
+		return err
+	}
+	cached = cachedHelptags{DocHash: docHash, Tags: tags}
+	if err = diskcache.Put(helptagsCacheNamespace, cacheKey, &cached); err != nil {
+		logger.Debug("Could not cache tags files: " + err.Error())
+	}
+	return nil
+}
+
+// helpFilesHash returns the hash of the names and contents of tagsFiles (the
+// result of helptagsFiles) in docDir.
+func helpFilesHash(docDir string, tagsFiles map[string][]string) (string, error) {
+	names := make([]string, 0, 8)
+	for _, helpFiles := range tagsFiles {
+		names = append(names, helpFiles...)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		content, err := ioutil.ReadFile(filepath.Join(docDir, name))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%x\x00", name, sha256.Sum256(content))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeTagsFiles writes tags (see cachedHelptags) to docDir. The files which
+// have the same content are not written.
+func writeTagsFiles(docDir string, tags map[string]string) error {
+	for tagsName, content := range tags {
+		tagsPath := filepath.Join(docDir, tagsName)
+		if current, err := ioutil.ReadFile(tagsPath); err == nil && string(current) == content {
+			continue
+		}
+		// Remove it before writing because the tags file of the repository
+		// may be hard-linked (see fileutil.TryLinkFile)
+		if err := os.Remove(tagsPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := ioutil.WriteFile(tagsPath, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeHelptags removes tags files generated by makeHelptags in docDir.
+func removeHelptags(docDir string) error {
+	if _, err := os.Stat(docDir); os.IsNotExist(err) {
+		return nil
+	}
+	tagsFiles, err := helptagsFiles(docDir)
+	if err != nil {
+		return err
+	}
+	for tagsName := range tagsFiles {
+		err = os.Remove(filepath.Join(docDir, tagsName))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func makeTagsContent(docDir string, helpFiles []string) ([]byte, error) {
+	lines := make([]string, 0, 256)
+	// isUTF8 is nil until the first line of a help file is read
+	var isUTF8 *bool
+	for _, name := range helpFiles {
+		content, err := ioutil.ReadFile(filepath.Join(docDir, name))
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		scanner.Buffer(make([]byte, 0, 4096), len(content)+1)
+		firstLine := true
+		inExample := false
+		for scanner.Scan() {
+			line := scanner.Text()
+			if inExample {
+				// Skip over example; a non-white in the first column ends it
+				if line == "" || line[0] == ' ' || line[0] == '\t' {
+					continue
+				}
+				inExample = false
+			}
+			if firstLine {
+				// Detect utf-8 file by a non-ASCII char in the first line
+				thisUTF8 := hasNonASCII(line) && utf8.ValidString(line)
+				if isUTF8 == nil {
+					isUTF8 = &thisUTF8
+				} else if *isUTF8 != thisUTF8 {
+					return nil, errors.New("mix of help file encodings within a language: " + filepath.Join(docDir, name))
+				}
+				firstLine = false
+			}
+			for _, tag := range findTags(line) {
+				lines = append(lines, tag+"\t"+name)
+			}
+			inExample = line == ">" || strings.HasSuffix(line, " >")
+		}
+		if err = scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(lines)
+
+	var buf bytes.Buffer
+	if isUTF8 != nil && *isUTF8 {
+		buf.WriteString("!_TAG_FILE_ENCODING\tutf-8\t//\n")
+	}
+	for i, line := range lines {
+		tab := strings.IndexByte(line, '\t')
+		tag := line[:tab]
+		if i > 0 && strings.HasPrefix(lines[i-1], tag+"\t") {
+			logger.Warnf("duplicate tag %q in file %s", tag, filepath.Join(docDir, line[tab+1:]))
+		}
+		buf.WriteString(line)
+		buf.WriteString("\t/*")
+		buf.WriteString(strings.NewReplacer(`\`, `\\`, `/`, `\/`).Replace(tag))
+		buf.WriteString("*\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// findTags returns "*tag*" in line.
+// The tag is accepted only when it does not have white spaces and '|', there
+// is a white space before it, and it is followed by a white space or
+// end-of-line.
+func findTags(line string) []string {
+	var tags []string
+	p1 := strings.IndexByte(line, '*')
+	for p1 >= 0 {
+		p2 := strings.IndexByte(line[p1+1:], '*')
+		if p2 < 0 {
+			break
+		}
+		p2 += p1 + 1
+		if p2 > p1+1 &&
+			!strings.ContainsAny(line[p1+1:p2], " \t|") &&
+			(p1 == 0 || line[p1-1] == ' ' || line[p1-1] == '\t') &&
+			(p2+1 == len(line) || strings.IndexByte(" \t\r", line[p2+1]) >= 0) {
+			tags = append(tags, line[p1+1:p2])
+			// Find next '*' after the tag
+			next := strings.IndexByte(line[p2+1:], '*')
+			if next < 0 {
+				break
+			}
+			p2 += next + 1
+		}
+		p1 = p2
+	}
+	return tags
+}
+
+func hasNonASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vim-volt/volt/diskcache"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// setUpVoltPath sets $VOLTPATH to a temporary directory until the test
+// finishes, so that the tags files are not cached in the user's $VOLTPATH.
+func setUpVoltPath(t *testing.T) {
+	t.Helper()
+	tempDir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, exists := os.LookupEnv("VOLTPATH")
+	t.Cleanup(func() {
+		if exists {
+			os.Setenv("VOLTPATH", old)
+		} else {
+			os.Unsetenv("VOLTPATH")
+		}
+		os.RemoveAll(tempDir)
+	})
+	os.Setenv("VOLTPATH", tempDir)
+}
+
+func TestFindTags(t *testing.T) {
+	for _, tt := range []struct {
+		line     string
+		expected []string
+	}{
+		{"*foo.txt*\tFor Vim version 8.0", []string{"foo.txt"}},
+		{"Options\t\t\t\t*foo-options* *g:foo_enabled*", []string{"foo-options", "g:foo_enabled"}},
+		{"*foo* *bar*", []string{"foo", "bar"}},
+		{"*foo/bar\\baz*", []string{"foo/bar\\baz"}},
+		// Not tags
+		{"a*foo*", nil},
+		{"*foo*bar", nil},
+		{"*foo bar*", nil},
+		{"*foo|bar*", nil},
+		{"**", nil},
+		{"2 * 3 * 4", nil},
+	} {
+		if tags := findTags(tt.line); !reflect.DeepEqual(tags, tt.expected) {
+			t.Errorf("findTags(%q): expected %q but got %q", tt.line, tt.expected, tags)
+		}
+	}
+}
+
+// * "tags" is generated from "*.txt" files, and "tags-xx" is generated from
+//   "*.xxx" files like ":helptags"
+//   * Tags are sorted, '/' and '\' in patterns are escaped
+//   * Tags in examples (from the line ending with ">" to the line starting
+//     with a non-white character) are ignored
+//   * "!_TAG_FILE_ENCODING" line is written for utf-8 files
+// * removeHelptags removes the generated tags files
+func TestMakeHelptags(t *testing.T) {
+	setUpVoltPath(t)
+	docDir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(docDir)
+
+	files := map[string]string{
+		"foo.txt": "*foo.txt*\tFor Vim version 8.0\n" +
+			"\n" +
+			"Usage\t\t\t\t\t\t*foo-usage* *foo/bar*\n" +
+			"Example: >\n" +
+			"\t*not-a-tag*\n" +
+			"<\n" +
+			"\t\t\t\t\t\t*:Foo*\n",
+		"bar.txt": "*bar.txt*\tBar\n",
+		"foo.jax": "*foo.txt*\tフー\n" +
+			"\t\t\t\t\t\t*foo-usage*\n",
+		"README.md": "*not-a-tag*\n",
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(docDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = makeHelptags("github.com/tyru/foo.vim", docDir); err != nil {
+		t.Fatal("makeHelptags() failed: " + err.Error())
+	}
+	expected := map[string]string{
+		"tags": ":Foo\tfoo.txt\t/*:Foo*\n" +
+			"bar.txt\tbar.txt\t/*bar.txt*\n" +
+			"foo-usage\tfoo.txt\t/*foo-usage*\n" +
+			"foo.txt\tfoo.txt\t/*foo.txt*\n" +
+			"foo/bar\tfoo.txt\t/*foo\\/bar*\n",
+		"tags-ja": "!_TAG_FILE_ENCODING\tutf-8\t//\n" +
+			"foo-usage\tfoo.jax\t/*foo-usage*\n" +
+			"foo.txt\tfoo.jax\t/*foo.txt*\n",
+	}
+	for name, content := range expected {
+		actual, err := ioutil.ReadFile(filepath.Join(docDir, name))
+		if err != nil {
+			t.Errorf("%s was not generated: %s", name, err.Error())
+			continue
+		}
+		if string(actual) != content {
+			t.Errorf("expected %s is %q but got %q", name, content, string(actual))
+		}
+	}
+
+	if err = removeHelptags(docDir); err != nil {
+		t.Fatal("removeHelptags() failed: " + err.Error())
+	}
+	for name := range expected {
+		if pathutil.Exists(filepath.Join(docDir, name)) {
+			t.Errorf("%s was not removed", name)
+		}
+	}
+	for name := range files {
+		if !pathutil.Exists(filepath.Join(docDir, name)) {
+			t.Errorf("%s was removed", name)
+		}
+	}
+}
+
+// * The cached tags files are written if help files were not changed
+// * Tags files are generated again if help files were changed
+// * Tags files are generated again if help files were added
+func TestMakeHelptagsCache(t *testing.T) {
+	setUpVoltPath(t)
+	docDir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(docDir)
+	cacheKey := "github.com/tyru/foo.vim"
+	tagsPath := filepath.Join(docDir, "tags")
+
+	checkTags := func(expected string) {
+		t.Helper()
+		if err := makeHelptags(cacheKey, docDir); err != nil {
+			t.Fatal("makeHelptags() failed: " + err.Error())
+		}
+		actual, err := ioutil.ReadFile(tagsPath)
+		if err != nil {
+			t.Fatal("tags was not generated: " + err.Error())
+		}
+		if string(actual) != expected {
+			t.Errorf("expected tags is %q but got %q", expected, string(actual))
+		}
+	}
+	writeHelp := func(name, content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(docDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeHelp("foo.txt", "*foo.txt*\n")
+	checkTags("foo.txt\tfoo.txt\t/*foo.txt*\n")
+
+	// Replace the cached content to see whether it is used
+	var cached cachedHelptags
+	if !diskcache.Get(helptagsCacheNamespace, cacheKey, helptagsCacheTTL, &cached) {
+		t.Fatal("tags files were not cached")
+	}
+	cached.Tags["tags"] = "cached\tfoo.txt\t/*cached*\n"
+	if err = diskcache.Put(helptagsCacheNamespace, cacheKey, &cached); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Remove(tagsPath); err != nil {
+		t.Fatal(err)
+	}
+	checkTags("cached\tfoo.txt\t/*cached*\n")
+
+	writeHelp("foo.txt", "*foo.txt* *foo-usage*\n")
+	checkTags("foo-usage\tfoo.txt\t/*foo-usage*\n" +
+		"foo.txt\tfoo.txt\t/*foo.txt*\n")
+
+	writeHelp("bar.txt", "*bar.txt*\n")
+	checkTags("bar.txt\tbar.txt\t/*bar.txt*\n" +
+		"foo-usage\tfoo.txt\t/*foo-usage*\n" +
+		"foo.txt\tfoo.txt\t/*foo.txt*\n")
+}
@@ -0,0 +1,104 @@
+package builder
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/vim-volt/volt/fileutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// installLuaPlugconfs installs Lua plugconf files of reposList to
+// "(vim dir)/pack/volt/start/system/plugconf".
+// The files are compiled to bytecode if compile is true.
+func (*BaseBuilder) installLuaPlugconfs(reposList []lockjson.Repos, compile bool) error {
+	// Remove Lua plugconf files of removed repositories
+	dir := pathutil.BundledLuaPlugconfDir()
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	files := make([][2]string, 0, len(reposList))
+	for i := range reposList {
+		src := reposList[i].Path.LuaPlugconf()
+		if pathutil.Exists(src) {
+			files = append(files, [2]string{src, reposList[i].Path.BundledLuaPlugconf()})
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	if compile {
+		nvim, err := pathutil.NvimExecutable()
+		if err == nil {
+			return compileLua(nvim, files)
+		}
+		logger.Warn("Could not compile Lua plugconf files: nvim is not found")
+	}
+	for _, file := range files {
+		if err := fileutil.CopyFile(file[0], file[1], nil, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileLua compiles Lua files (pairs of source and destination) to
+// bytecode of LuaJIT by nvim.
+func compileLua(nvim string, files [][2]string) error {
+	var script bytes.Buffer
+	script.WriteString("local files = {\n")
+	for _, file := range files {
+		script.WriteString("  {" + luaLongString(file[0]) + ", " + luaLongString(file[1]) + "},\n")
+	}
+	script.WriteString(`}
+for _, file in ipairs(files) do
+  local chunk, err = loadfile(file[1])
+  if not chunk then
+    io.stderr:write(err .. "\n")
+    vim.cmd('cquit')
+  end
+  local out = assert(io.open(file[2], 'wb'))
+  out:write(string.dump(chunk))
+  out:close()
+end
+`)
+	tmp, err := ioutil.TempFile("", "volt-compile-lua-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(script.Bytes())
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("Compiling %d Lua plugconf files ...", len(files))
+	cmd := exec.Command(nvim, "--headless", "-u", "NONE", "-i", "NONE",
+		"-c", "luafile "+strings.Replace(tmp.Name(), " ", `\ `, -1),
+		"-c", "qall!")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.New("failed to compile Lua plugconf: " + strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// luaLongString returns Lua long string literal of s.
+func luaLongString(s string) string {
+	level := ""
+	for strings.Contains(s, "]"+level+"]") {
+		level += "="
+	}
+	return "[" + level + "[" + s + "]" + level + "]"
+}
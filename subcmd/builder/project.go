@@ -0,0 +1,127 @@
+package builder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vim-volt/volt/fileutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/plugconf"
+	"github.com/vim-volt/volt/subcmd/project"
+)
+
+// readProjects reads the projects registered by "volt env", and returns them
+// and the repositories of lock.json which are used by them but not in
+// reposList (current profile's repositories). Invalid projects are skipped
+// with warnings.
+func readProjects(lockJSON *lockjson.LockJSON, reposList lockjson.ReposList) ([]*project.Project, lockjson.ReposList) {
+	dirs, err := project.Registered()
+	if err != nil {
+		logger.Warn("Could not read projects: " + err.Error())
+		return nil, nil
+	}
+	var projects []*project.Project
+	var projectReposList lockjson.ReposList
+	for _, dir := range dirs {
+		p, err := project.Read(dir)
+		if err != nil {
+			logger.Debugf("Skipping project %s: %s", dir, err.Error())
+			continue
+		}
+		projects = append(projects, p)
+		for _, reposPath := range p.ReposPath {
+			if reposList.Contains(reposPath) || projectReposList.Contains(reposPath) {
+				continue
+			}
+			repos, err := lockJSON.Repos.FindByPath(reposPath)
+			if err != nil {
+				logger.Debugf("%s of project %s is not installed ... skip", reposPath, dir)
+				continue
+			}
+			projectReposList = append(projectReposList, *repos)
+		}
+	}
+	return projects, projectReposList
+}
+
+// installProjectPlugconfs writes the scripts which load the plugins of
+// projects (only the plugins not in reposList), and the loader script which
+// sources the script of $VOLT_PROJECT.
+func (builder *BaseBuilder) installProjectPlugconfs(projects []*project.Project, profileName string, reposList lockjson.ReposList, projectReposList lockjson.ReposList) error {
+	dir := pathutil.BundledProjectPlugconfDir()
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(pathutil.BundledProjectLoader()); err != nil {
+		return err
+	}
+	if len(projects) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, p := range projects {
+		var list []lockjson.Repos
+		for _, reposPath := range p.ReposPath {
+			if reposList.Contains(reposPath) {
+				continue
+			}
+			if repos, err := projectReposList.FindByPath(reposPath); err == nil {
+				list = append(list, *repos)
+			}
+		}
+		plugconfs, parseErr := plugconf.ParseMultiPlugconf(list)
+		if parseErr.HasErrs() {
+			return parseErr.Errors()
+		}
+		if parseErr.HasWarns() {
+			for _, err := range parseErr.Warns().Errors {
+				logger.Warn(err)
+			}
+		}
+		// Lua plugconf files are not compiled because they are copied on
+		// every build
+		if err := builder.copyLuaPlugconfs(list); err != nil {
+			return err
+		}
+		content, err := plugconfs.GenerateProjectPlugconf(profileName)
+		if err != nil {
+			return err
+		}
+		if vimrc := p.VimrcPath(); vimrc != "" {
+			content = append(content, fmt.Sprintf("\nexecute 'source' fnameescape('%s')\n", strings.Replace(vimrc, "'", "''", -1))...)
+		}
+		if builder.deterministic {
+			content = normalizeNewlines(content)
+		}
+		if err = ioutil.WriteFile(project.ScriptPath(p.Dir), content, 0644); err != nil {
+			return err
+		}
+	}
+
+	os.MkdirAll(filepath.Dir(pathutil.BundledProjectLoader()), 0755)
+	return ioutil.WriteFile(pathutil.BundledProjectLoader(), []byte(project.LoaderScript()), 0644)
+}
+
+// copyLuaPlugconfs copies Lua plugconf files of reposList to
+// "(vim dir)/pack/volt/start/system/plugconf" without removing other files.
+func (*BaseBuilder) copyLuaPlugconfs(reposList []lockjson.Repos) error {
+	for i := range reposList {
+		src := reposList[i].Path.LuaPlugconf()
+		if !pathutil.Exists(src) {
+			continue
+		}
+		os.MkdirAll(pathutil.BundledLuaPlugconfDir(), 0755)
+		if err := fileutil.CopyFile(src, reposList[i].Path.BundledLuaPlugconf(), nil, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package builder
+
+import (
+	"path"
+	"strings"
+)
+
+// slimExcludedDirs are the top-level directories of repositories which are
+// not needed at runtime (test suites and CI config).
+var slimExcludedDirs = map[string]bool{
+	"t":           true,
+	"test":        true,
+	"tests":       true,
+	"spec":        true,
+	"specs":       true,
+	".github":     true,
+	".circleci":   true,
+	".gitlab":     true,
+	"screenshot":  true,
+	"screenshots": true,
+}
+
+// slimExcludedFiles are the top-level files of repositories which are not
+// needed at runtime (CI and linter config).
+var slimExcludedFiles = map[string]bool{
+	".travis.yml":    true,
+	".appveyor.yml":  true,
+	"appveyor.yml":   true,
+	".gitlab-ci.yml": true,
+	".cirrus.yml":    true,
+	".codecov.yml":   true,
+	".themisrc":      true,
+	".vintrc":        true,
+	".vintrc.yml":    true,
+	".vintrc.yaml":   true,
+}
+
+// slimImageExts are the extensions of image files which are excluded from
+// the top-level and "doc" directory.
+var slimImageExts = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".webp": true,
+}
+
+// slimFilter decides the files which are not installed by "build.slim" of
+// config.toml. nil slimFilter excludes nothing.
+type slimFilter struct {
+	// doc is true if "doc" directory is also excluded ("build.slim_doc")
+	doc bool
+}
+
+// newSlimFilter returns slimFilter for "build.slim" and "build.slim_doc" of
+// config.toml, or nil if slim is false.
+func newSlimFilter(slim, doc bool) *slimFilter {
+	if !slim {
+		return nil
+	}
+	return &slimFilter{doc: doc}
+}
+
+// excluded returns true if the file (or directory) of name is not installed.
+// name is a slash-separated path relative to the repository.
+func (f *slimFilter) excluded(name string, isDir bool) bool {
+	if f == nil {
+		return false
+	}
+	top := name
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		top = name[:i]
+		isDir = true
+	}
+	if top == "doc" && f.doc {
+		return true
+	}
+	if isDir && slimExcludedDirs[top] {
+		return true
+	}
+	if top == name && slimExcludedFiles[name] {
+		return true
+	}
+	dir := path.Dir(name)
+	return (dir == "." || dir == "doc") && slimImageExts[strings.ToLower(path.Ext(name))]
+}
+
+// String returns the value recorded in build-info.json, which is changed
+// when the files excluded by f are changed.
+func (f *slimFilter) String() string {
+	switch {
+	case f == nil:
+		return ""
+	case f.doc:
+		return "slim,doc"
+	default:
+		return "slim"
+	}
+}
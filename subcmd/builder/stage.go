@@ -0,0 +1,239 @@
+package builder
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vim-volt/volt/fileutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// The build output is written to the staging directory
+// (pathutil.VimVoltStagingDir()), synced once, and then swapped with the
+// runtime directory by renaming the directories. So Vim never sees a
+// half-written runtime even if the build failed or crashed.
+//
+// The runtime directory is "(vim dir)/pack/volt" if "build.cached_profiles"
+// of config.toml is 0. Otherwise it is pathutil.VimVoltProfileDir() of
+// current profile, and "(vim dir)/pack/volt" is a symlink to it. The
+// runtimes of recently used profiles are kept, so switching to them only
+// updates the changed files and the symlink.
+
+// recoverRuntime restores the runtime directory if the previous build
+// crashed while swapping the directories, and removes the leftovers of the
+// build.
+func recoverRuntime() error {
+	live := pathutil.VimVoltDir()
+	old := pathutil.VimVoltOldDir()
+	if _, err := os.Stat(live); os.IsNotExist(err) && pathutil.Exists(old) {
+		dir := live
+		if target, err := os.Readlink(live); err == nil {
+			dir = resolveLink(live, target)
+		}
+		logger.Info("Restoring " + dir + " which was moved by the previous build ...")
+		if err := os.Rename(old, dir); err != nil {
+			return err
+		}
+	}
+	for _, dir := range []string{old, pathutil.VimVoltStagingDir()} {
+		os.RemoveAll(dir)
+		if pathutil.Exists(dir) {
+			return errors.New("failed to remove " + dir)
+		}
+	}
+	return nil
+}
+
+// runtimeDir returns the runtime directory of profileName, and the
+// directory of the last build output which the build starts from.
+// It also converts "(vim dir)/pack/volt" to a directory or a symlink
+// according to cachedProfiles ("build.cached_profiles" of config.toml).
+func runtimeDir(profileName string, cachedProfiles int) (string, string, error) {
+	live := pathutil.VimVoltDir()
+	fi, err := os.Lstat(live)
+	exists := err == nil
+	isLink := exists && fi.Mode()&os.ModeSymlink != 0
+
+	if cachedProfiles == 0 {
+		if isLink {
+			// Move the runtime of current profile to "(vim dir)/pack/volt"
+			target, evalErr := filepath.EvalSymlinks(live)
+			if err := os.Remove(live); err != nil {
+				return "", "", err
+			}
+			if evalErr == nil {
+				if err := os.Rename(target, live); err != nil {
+					return "", "", err
+				}
+			}
+			os.RemoveAll(pathutil.VimVoltProfilesDir())
+		}
+		return live, live, nil
+	}
+
+	dir := pathutil.VimVoltProfileDir(profileName)
+	if exists && !isLink {
+		// Move the runtime built without the cache of profiles
+		if err := os.MkdirAll(pathutil.VimVoltProfilesDir(), 0755); err != nil {
+			return "", "", err
+		}
+		os.RemoveAll(dir)
+		if err := os.Rename(live, dir); err != nil {
+			return "", "", err
+		}
+		if err := linkRuntime(dir); err != nil {
+			return "", "", err
+		}
+		return dir, dir, nil
+	}
+	if pathutil.Exists(dir) {
+		return dir, dir, nil
+	}
+	// Start from the runtime of the last profile, because the profiles
+	// usually share most of the plugins
+	if isLink {
+		if target, err := filepath.EvalSymlinks(live); err == nil {
+			return dir, target, nil
+		}
+	}
+	return dir, dir, nil
+}
+
+// stageRuntime populates the staging directory with the files in base, so
+// the builders update only the changed repositories.
+// The files under opt dir are linked because the builders remove them before
+// writing, and the other files are copied because they are rewritten.
+// The empty staging directory is created if full is true.
+func stageRuntime(base string, full bool) error {
+	staging := pathutil.VimVoltStagingDir()
+	if full || !pathutil.Exists(base) {
+		return os.MkdirAll(staging, 0755)
+	}
+	optDir := filepath.Join(base, "opt") + string(filepath.Separator)
+	buf := make([]byte, 32*1024)
+	return filepath.Walk(base, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(staging, rel)
+		switch {
+		case fi.IsDir():
+			return os.MkdirAll(dst, fi.Mode().Perm())
+		case fi.Mode()&os.ModeSymlink != 0:
+			// The symlink is created again by the builder if this failed
+			if src, err := os.Readlink(path); err == nil {
+				os.Symlink(src, dst)
+			}
+			return nil
+		case strings.HasPrefix(path, optDir):
+			return fileutil.TryLinkFile(path, dst, buf, fi.Mode())
+		default:
+			// Keep the modification time to skip writing unchanged files
+			if err := fileutil.CopyFile(path, dst, buf, fi.Mode()); err != nil {
+				return err
+			}
+			return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+		}
+	})
+}
+
+// swapRuntime syncs the staging directory and replaces the runtime
+// directory dir with it.
+func swapRuntime(dir string) error {
+	old := pathutil.VimVoltOldDir()
+	staging := pathutil.VimVoltStagingDir()
+	if err := fileutil.SyncDir(staging); err != nil {
+		return err
+	}
+	if pathutil.Exists(dir) {
+		if err := os.Rename(dir, old); err != nil {
+			return err
+		}
+	} else if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		os.Rename(old, dir)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+// linkRuntime replaces "(vim dir)/pack/volt" with the symlink to dir
+// atomically. The modification time of dir is updated to remember the
+// recently used profiles.
+func linkRuntime(dir string) error {
+	live := pathutil.VimVoltDir()
+	target, err := filepath.Rel(filepath.Dir(live), dir)
+	if err != nil {
+		target = dir
+	}
+	if current, err := os.Readlink(live); err != nil || current != target {
+		// The symlink is created outside of "(vim dir)/pack" so Vim does
+		// not load it, and then renamed
+		tmp := filepath.Join(pathutil.VimDir(), ".volt-link")
+		os.Remove(tmp)
+		if err := os.MkdirAll(filepath.Dir(live), 0755); err != nil {
+			return err
+		}
+		if err := os.Symlink(target, tmp); err != nil {
+			return errors.New("could not create symlink (set build.cached_profiles to 0 to disable it): " + err.Error())
+		}
+		if err := os.Rename(tmp, live); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	now := time.Now()
+	return os.Chtimes(dir, now, now)
+}
+
+// pruneRuntimes removes the runtimes of the profiles which were removed from
+// lock.json, and the runtimes of the least recently used profiles except
+// current profile dir, so at most cachedProfiles runtimes are kept.
+func pruneRuntimes(lockJSON *lockjson.LockJSON, dir string, cachedProfiles int) {
+	profilesDir := pathutil.VimVoltProfilesDir()
+	entries, err := ioutil.ReadDir(profilesDir)
+	if err != nil {
+		return
+	}
+	valid := make(map[string]bool, len(lockJSON.Profiles))
+	for i := range lockJSON.Profiles {
+		valid[pathutil.VimVoltProfileDir(lockJSON.Profiles[i].Name)] = true
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().After(entries[j].ModTime())
+	})
+	kept := 1
+	for _, fi := range entries {
+		path := filepath.Join(profilesDir, fi.Name())
+		if path == dir {
+			continue
+		}
+		if valid[path] && kept < cachedProfiles {
+			kept++
+			continue
+		}
+		logger.Debug("Removing the runtime " + path + " ...")
+		os.RemoveAll(path)
+	}
+}
+
+// resolveLink returns the path which the symlink link points to.
+func resolveLink(link, target string) string {
+	if filepath.IsAbs(target) {
+		return target
+	}
+	return filepath.Join(filepath.Dir(link), target)
+}
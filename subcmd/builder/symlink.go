@@ -15,21 +15,18 @@ import (
 	"github.com/vim-volt/volt/lockjson"
 	"github.com/vim-volt/volt/logger"
 	"github.com/vim-volt/volt/pathutil"
-	"github.com/vim-volt/volt/plugconf"
 	"github.com/vim-volt/volt/subcmd/buildinfo"
 )
 
 type symlinkBuilder struct {
 	BaseBuilder
+	// slim decides the files which are not copied from bare repositories
+	// ("build.slim" of config.toml)
+	slim *slimFilter
 }
 
 // TODO: rollback when return err (!= nil)
 func (builder *symlinkBuilder) Build(buildInfo *buildinfo.BuildInfo, buildReposMap map[pathutil.ReposPath]*buildinfo.Repos) error {
-	// Exit if vim executable was not found in PATH
-	if _, err := pathutil.VimExecutable(); err != nil {
-		return err
-	}
-
 	// Get current profile's repos list
 	lockJSON, err := lockjson.Read()
 	if err != nil {
@@ -39,6 +36,9 @@ func (builder *symlinkBuilder) Build(buildInfo *buildinfo.BuildInfo, buildReposM
 	if err != nil {
 		return err
 	}
+	// The plugins of projects are also installed to opt dir
+	projects, projectReposList := readProjects(lockJSON, reposList)
+	optReposList := append(append(lockjson.ReposList{}, reposList...), projectReposList...)
 
 	logger.Info("Installing vimrc and gvimrc ...")
 
@@ -59,58 +59,91 @@ func (builder *symlinkBuilder) Build(buildInfo *buildinfo.BuildInfo, buildReposM
 		return errors.New("could not create " + optDir)
 	}
 
-	vimExePath, err := pathutil.VimExecutable()
+	// Remove tags files generated in the repositories which were removed
+	// from vim dir
+	reposDirList, err := ioutil.ReadDir(optDir)
 	if err != nil {
 		return err
 	}
+	removeDone := make(chan actionReposResult, len(buildInfo.Repos)+len(reposDirList))
+	removeCount := 0
+	for i := range buildInfo.Repos {
+		reposPath := buildInfo.Repos[i].Path
+		if optReposList.Contains(reposPath) {
+			continue
+		}
+		builder.runJob(func() {
+			if err := removeHelptags(filepath.Join(reposPath.FullPath(), "doc")); err != nil {
+				logger.Warnf("could not remove tags files of %s: %s", reposPath, err.Error())
+			}
+			removeDone <- actionReposResult{repos: &lockjson.Repos{Path: reposPath}}
+		})
+		removeCount++
+	}
 
-	buildInfo.Repos = make([]buildinfo.Repos, 0, len(reposList))
-	done := make(chan actionReposResult, len(reposList))
-	for i := range reposList {
-		go builder.installRepos(&reposList[i], vimExePath, done)
+	// Remove the directories of the repositories which were removed from
+	// current profile
+	for i := range reposDirList {
+		reposPath := pathutil.DecodeReposPath(reposDirList[i].Name())
+		if optReposList.Contains(reposPath) {
+			continue
+		}
+		dir := filepath.Join(optDir, reposDirList[i].Name())
+		builder.runJob(func() {
+			err := os.RemoveAll(dir)
+			if err == nil {
+				logger.Info("Removing " + reposPath + " ... Done.")
+			}
+			removeDone <- actionReposResult{err: err, repos: &lockjson.Repos{Path: reposPath}}
+		})
+		removeCount++
+	}
+	for _, result := range receiveResults(removeDone, removeCount) {
+		if result.err != nil {
+			return result.err
+		}
+	}
+
+	// Install only the repositories which were changed since the last build
+	buildInfo.Repos = make([]buildinfo.Repos, 0, len(optReposList))
+	reposKeys := make([]string, 0, len(optReposList))
+	done := make(chan actionReposResult, len(optReposList))
+	installCount := 0
+	for i := range optReposList {
+		key := reposCacheKey(&optReposList[i])
+		reposKeys = append(reposKeys, key)
 		// Make build-info.json data
 		buildInfo.Repos = append(buildInfo.Repos, buildinfo.Repos{
-			Type:    reposList[i].Type,
-			Path:    reposList[i].Path,
-			Version: reposList[i].Version,
+			Type:     optReposList[i].Type,
+			Path:     optReposList[i].Path,
+			Version:  optReposList[i].Version,
+			CacheKey: key,
 		})
+		if old, exists := buildReposMap[optReposList[i].Path]; exists && old.CacheKey == key &&
+			builder.isInstalled(&optReposList[i]) {
+			logger.Debug("Repository " + optReposList[i].Path.String() + " is not changed ... skip")
+			continue
+		}
+		repos := &optReposList[i]
+		builder.runJob(func() { builder.installRepos(repos, done) })
+		installCount++
 	}
-	for i := 0; i < len(reposList); i++ {
-		result := <-done
+	// Wait for all jobs, and return the first error in the order of the
+	// repository paths
+	for _, result := range receiveResults(done, installCount) {
 		if result.err != nil {
-			return err
-		}
-		if result.repos != nil {
-			logger.Debug("Installing " + string(result.repos.Type) + " repository " + result.repos.Path.String() + " ... Done.")
+			return result.err
 		}
+		logger.Debug("Installing " + string(result.repos.Type) + " repository " + result.repos.Path.String() + " ... Done.")
 	}
 
 	// Write bundled plugconf file
-	rcDir := pathutil.RCDir(lockJSON.CurrentProfileName)
-	vimrc := ""
-	if path := filepath.Join(rcDir, pathutil.ProfileVimrc); pathutil.Exists(path) {
-		vimrc = path
-	}
-	gvimrc := ""
-	if path := filepath.Join(rcDir, pathutil.ProfileGvimrc); pathutil.Exists(path) {
-		gvimrc = path
-	}
-	plugconfs, parseErr := plugconf.ParseMultiPlugconf(reposList)
-	if parseErr.HasErrs() {
-		// Vim script parse errors / other errors
-		return parseErr.Errors()
-	}
-	if parseErr.HasWarns() {
-		// Vim script parse warnings
-		merr := parseErr.Warns()
-		for _, err := range merr.Errors {
-			logger.Warn(err)
-		}
+	if _, err = builder.installBundledPlugconf(buildInfo, lockJSON.CurrentProfileName, reposList, reposKeys[:len(reposList)]); err != nil {
+		return err
 	}
-	content, err := plugconfs.GenerateBundlePlugconf(vimrc, gvimrc)
-	os.MkdirAll(filepath.Dir(pathutil.BundledPlugConf()), 0755)
-	err = ioutil.WriteFile(pathutil.BundledPlugConf(), content, 0644)
-	if err != nil {
+
+	// Write the plugconf files of projects
+	if err = builder.installProjectPlugconfs(projects, lockJSON.CurrentProfileName, reposList, projectReposList); err != nil {
 		return err
 	}
 
@@ -118,17 +151,24 @@ func (builder *symlinkBuilder) Build(buildInfo *buildinfo.BuildInfo, buildReposM
 	return buildInfo.Write()
 }
 
-func (builder *symlinkBuilder) installRepos(repos *lockjson.Repos, vimExePath string, done chan actionReposResult) {
+func (builder *symlinkBuilder) installRepos(repos *lockjson.Repos, done chan actionReposResult) {
 	src := repos.Path.FullPath()
 	dst := repos.Path.EncodeToPlugDirName()
 
+	// Remove the symlink (or copied directory) of the previous build
+	if err := os.RemoveAll(dst); err != nil {
+		done <- actionReposResult{err: err, repos: repos}
+		return
+	}
+
 	copied := false
 	if repos.Type == lockjson.ReposGitType {
 		// Open a repository to determine it is bare repository or not
 		r, err := git.PlainOpen(src)
 		if err != nil {
 			done <- actionReposResult{
-				err: fmt.Errorf("repository %q: %s", src, err.Error()),
+				repos: repos,
+				err:   fmt.Errorf("repository %q: %s", src, err.Error()),
 			}
 			return
 		}
@@ -137,7 +177,8 @@ func (builder *symlinkBuilder) installRepos(repos *lockjson.Repos, vimExePath st
 		head, err := gitutil.GetHEADRepository(r)
 		if err != nil {
 			done <- actionReposResult{
-				err: fmt.Errorf("failed to get HEAD revision of %q: %s", src, err.Error()),
+				repos: repos,
+				err:   fmt.Errorf("failed to get HEAD revision of %q: %s", src, err.Error()),
 			}
 			return
 		}
@@ -151,18 +192,19 @@ func (builder *symlinkBuilder) installRepos(repos *lockjson.Repos, vimExePath st
 		cfg, err := r.Config()
 		if err != nil {
 			done <- actionReposResult{
-				err: fmt.Errorf("failed to get repository config of %q: %s", src, err.Error()),
+				repos: repos,
+				err:   fmt.Errorf("failed to get repository config of %q: %s", src, err.Error()),
 			}
 			return
 		}
 		if cfg.Core.IsBare {
 			// * Copy files from git objects under vim dir
 			// * Run ":helptags" to generate tags file
-			updateDone := make(chan actionReposResult)
-			(&copyBuilder{}).updateBareGitRepos(r, src, dst, repos, vimExePath, updateDone)
+			updateDone := make(chan actionReposResult, 1)
+			(&copyBuilder{slim: builder.slim}).updateBareGitRepos(r, "", dst, repos, updateDone)
 			result := <-updateDone
 			if result.err != nil {
-				done <- actionReposResult{err: result.err}
+				done <- actionReposResult{err: result.err, repos: repos}
 				return
 			}
 			copied = true
@@ -172,21 +214,57 @@ func (builder *symlinkBuilder) installRepos(repos *lockjson.Repos, vimExePath st
 	if !copied {
 		// Make symlinks under vim dir
 		if err := builder.symlink(src, dst); err != nil {
-			done <- actionReposResult{err: err}
-			return
-		}
-		// Run ":helptags" to generate tags file
-		if err := builder.helptags(repos.Path, vimExePath); err != nil {
-			done <- actionReposResult{err: err}
+			// Copy files on the filesystems which do not support symlinks
+			logger.Warnf("could not create symlink %s (%s): copying files instead", dst, err.Error())
+			os.RemoveAll(dst)
+			if err := builder.copyRepos(repos); err != nil {
+				done <- actionReposResult{err: err, repos: repos}
+				return
+			}
+		} else if err := builder.helptags(repos.Path); err != nil {
+			// Run ":helptags" to generate tags file
+			done <- actionReposResult{err: err, repos: repos}
 			return
 		}
 	}
 	done <- actionReposResult{repos: repos}
 }
 
+// isInstalled returns true if repos is installed to vim dir. The files of
+// static repositories which were copied (not symlinked) are always installed
+// again, because they may be changed.
+func (*symlinkBuilder) isInstalled(repos *lockjson.Repos) bool {
+	fi, err := os.Lstat(repos.Path.EncodeToPlugDirName())
+	if err != nil {
+		return false
+	}
+	return repos.Type == lockjson.ReposGitType || fi.Mode()&os.ModeSymlink != 0
+}
+
+// copyRepos copies the files of repos to vim dir like copy builder, and runs
+// ":helptags".
+func (builder *symlinkBuilder) copyRepos(repos *lockjson.Repos) error {
+	copier := &copyBuilder{slim: builder.slim}
+	done := make(chan actionReposResult, 1)
+	if repos.Type == lockjson.ReposGitType {
+		r, err := git.PlainOpen(repos.Path.FullPath())
+		if err != nil {
+			return fmt.Errorf("repository %q: %s", repos.Path.FullPath(), err.Error())
+		}
+		copier.updateNonBareGitRepos(r, repos.Path.FullPath(), repos.Path.EncodeToPlugDirName(), repos, done)
+	} else {
+		copier.updateStaticRepos(repos, done)
+	}
+	return (<-done).err
+}
+
+// osSymlink is os.Symlink. Tests replace it to simulate the filesystems which
+// do not support symlinks.
+var osSymlink = os.Symlink
+
 func (*symlinkBuilder) symlink(src, dst string) error {
 	if runtime.GOOS == "windows" {
 		return exec.Command("cmd", "/c", "mklink", "/J", dst, src).Run()
 	}
-	return os.Symlink(src, dst)
+	return osSymlink(src, dst)
 }
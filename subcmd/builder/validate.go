@@ -0,0 +1,90 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// requiredDirs are the directories which must be installed if the source
+// repository has them.
+var requiredDirs = []string{"plugin", "autoload"}
+
+// validateOutput verifies the build output of reposList, and returns the
+// problems found:
+// * broken symlinks
+// * "plugin" or "autoload" directories which exist in the source repository
+//   but not in the build output
+// * autoload files shipped by two or more plugins (only one of them is
+//   loaded)
+func validateOutput(reposList []lockjson.Repos) []string {
+	problems := make([]string, 0, 8)
+	// autoload file path -> the repositories which ship it
+	owners := make(map[string][]pathutil.ReposPath, 64)
+	for i := range reposList {
+		reposPath := reposList[i].Path
+		dst := reposPath.EncodeToPlugDirName()
+		if _, err := os.Lstat(dst); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s is not installed", reposPath, dst))
+			continue
+		}
+		if _, err := os.Stat(dst); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: broken symlink %s", reposPath, dst))
+			continue
+		}
+
+		for _, name := range requiredDirs {
+			if isDir(filepath.Join(reposPath.FullPath(), name)) && !isDir(filepath.Join(dst, name)) {
+				problems = append(problems, fmt.Sprintf("%s: %s/ exists in the repository but is not installed", reposPath, name))
+			}
+		}
+
+		autoloadDir := filepath.Join(dst, "autoload")
+		filepath.Walk(autoloadDir, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			rel, err := filepath.Rel(dst, path)
+			if err != nil {
+				return nil
+			}
+			if fi.Mode()&os.ModeSymlink != 0 {
+				st, err := os.Stat(path)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("%s: broken symlink %s", reposPath, filepath.ToSlash(rel)))
+					return nil
+				}
+				fi = st
+			}
+			if !fi.IsDir() {
+				rel = filepath.ToSlash(rel)
+				owners[rel] = append(owners[rel], reposPath)
+			}
+			return nil
+		})
+	}
+
+	collisions := make([]string, 0, len(owners))
+	for rel, reposPathList := range owners {
+		if len(reposPathList) < 2 {
+			continue
+		}
+		names := make([]string, 0, len(reposPathList))
+		for _, reposPath := range reposPathList {
+			names = append(names, reposPath.String())
+		}
+		collisions = append(collisions, fmt.Sprintf("%s is shipped by two or more plugins: %s", rel, strings.Join(names, " and ")))
+	}
+	sort.Strings(collisions)
+	return append(problems, collisions...)
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,100 @@
+package buildhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// Result is the result of a build hook which succeeded.
+type Result struct {
+	Command string `json:"command"`
+	Version string `json:"version"`
+}
+
+// State is the results of build hooks which succeeded.
+// It is saved to $VOLTPATH/build-hook/state.json not to run the hooks again
+// for the same command and version.
+type State map[pathutil.ReposPath]Result
+
+func stateFile() string {
+	return filepath.Join(pathutil.BuildHookDir(), "state.json")
+}
+
+// ReadState reads $VOLTPATH/build-hook/state.json.
+// It returns empty State if the file does not exist.
+func ReadState() (State, error) {
+	content, err := ioutil.ReadFile(stateFile())
+	if os.IsNotExist(err) {
+		return State{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state State
+	if err = json.Unmarshal(content, &state); err != nil {
+		return nil, err
+	}
+	if state == nil {
+		state = State{}
+	}
+	return state, nil
+}
+
+// Write writes state to $VOLTPATH/build-hook/state.json.
+func (state State) Write() error {
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(pathutil.BuildHookDir(), 0755); err != nil {
+		return err
+	}
+	// Write to temporary file and rename it, not to leave half-written
+	// state.json
+	tmp := stateFile() + ".tmp"
+	if err = ioutil.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, stateFile())
+}
+
+// Done returns true if command of reposPath succeeded at version.
+func (state State) Done(reposPath pathutil.ReposPath, command, version string) bool {
+	result, exists := state[reposPath]
+	return exists && result.Command == command && result.Version == version
+}
+
+// Run runs command by shell in the directory of reposPath.
+// The output is written to $VOLTPATH/build-hook/{repos}.log .
+func Run(reposPath pathutil.ReposPath, command string) error {
+	logFile := pathutil.BuildHookLog(reposPath)
+	if err := os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(logFile)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/c", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Dir = reposPath.FullPath()
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("'%s' failed: %s (see %s)", command, err.Error(), logFile)
+	}
+	return nil
+}
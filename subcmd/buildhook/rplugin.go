@@ -0,0 +1,67 @@
+package buildhook
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// RemotePluginLog returns fullpath of "$VOLTPATH/build-hook/rplugin.log".
+func RemotePluginLog() string {
+	return filepath.Join(pathutil.BuildHookDir(), "rplugin.log")
+}
+
+// HasRemotePlugin returns true if dir has "rplugin" directory (remote plugins
+// of Neovim).
+func HasRemotePlugin(dir string) bool {
+	fi, err := os.Stat(filepath.Join(dir, "rplugin"))
+	return err == nil && fi.IsDir()
+}
+
+// UpdateRemotePlugins runs ":UpdateRemotePlugins" by headless Neovim nvim
+// with rtpDirs in 'runtimepath'.
+// Neovim regenerates the manifest from all remote plugins in 'runtimepath',
+// so rtpDirs must be all directories which have remote plugins.
+// The output is written to $VOLTPATH/build-hook/rplugin.log .
+func UpdateRemotePlugins(nvim string, rtpDirs []string) error {
+	logFile := RemotePluginLog()
+	if err := os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
+		return err
+	}
+
+	rtp := make([]string, 0, len(rtpDirs))
+	for _, dir := range rtpDirs {
+		rtp = append(rtp, strings.Replace(dir, ",", `\,`, -1))
+	}
+	addRtp := "let &rtp .= ',' . '" + strings.Replace(strings.Join(rtp, ","), "'", "''", -1) + "'"
+	redir := "redir! > " + strings.Replace(logFile, " ", `\ `, -1)
+	// Remote plugin hosts report errors of loading plugins by messages, so
+	// the messages are checked in addition to exceptions
+	cmd := exec.Command(nvim, "--headless", "-i", "NONE",
+		"--cmd", addRtp,
+		"-c", redir,
+		"-c", "try | execute 'UpdateRemotePlugins' | catch | echomsg v:exception | cquit | endtry",
+		"-c", `if execute('messages') =~? 'error\|exception' | cquit | endif`,
+		"-c", "redir END",
+		"-c", "qall!")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if len(out) > 0 {
+			appendLog(logFile, out)
+		}
+		return fmt.Errorf(":UpdateRemotePlugins failed: %s (see %s)", err.Error(), logFile)
+	}
+	return nil
+}
+
+func appendLog(logFile string, content []byte) {
+	f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(content)
+}
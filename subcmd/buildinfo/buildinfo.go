@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"os"
+	"sort"
 
 	"github.com/vim-volt/volt/lockjson"
 	"github.com/vim-volt/volt/pathutil"
@@ -13,6 +15,11 @@ type BuildInfo struct {
 	Repos    ReposList `json:"repos"`
 	Version  int64     `json:"version"`
 	Strategy string    `json:"strategy"`
+	// Slim is "build.slim" and "build.slim_doc" of config.toml which the
+	// files were installed by
+	Slim string `json:"slim,omitempty"`
+	// BundleKey is the cache key of bundled plugconf
+	BundleKey string `json:"bundle_key,omitempty"`
 }
 
 type ReposList []Repos
@@ -23,6 +30,8 @@ type Repos struct {
 	Version       string             `json:"version"`
 	Files         FileMap            `json:"files,omitempty"`
 	DirtyWorktree bool               `json:"dirty_worktree,omitempty"`
+	// CacheKey is the cache key of the build output of the repository
+	CacheKey string `json:"cache_key,omitempty"`
 }
 
 // key: filepath, value: version
@@ -63,12 +72,17 @@ func (buildInfo *BuildInfo) Write() error {
 		return errors.New("validation failed: build-info.json: " + err.Error())
 	}
 
-	// Write to build-info.json
+	// Write to temporary file and rename it, not to leave half-written
+	// build-info.json
 	bytes, err := json.MarshalIndent(buildInfo, "", "  ")
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(pathutil.BuildInfoJSON(), bytes, 0644)
+	tmp := pathutil.BuildInfoJSON() + ".tmp"
+	if err = ioutil.WriteFile(tmp, bytes, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, pathutil.BuildInfoJSON())
 }
 
 func (buildInfo *BuildInfo) validate() error {
@@ -103,3 +117,23 @@ func (reposList *ReposList) RemoveByReposPath(reposPath pathutil.ReposPath) {
 		}
 	}
 }
+
+// SortByLockJSON sorts reposList in the order of lockReposList.
+// Repositories which are not in lockReposList are placed at the end.
+// Repositories are processed in parallel and the results are appended in
+// the order of completion, so this makes build-info.json deterministic.
+func (reposList *ReposList) SortByLockJSON(lockReposList []lockjson.Repos) {
+	order := make(map[pathutil.ReposPath]int, len(lockReposList))
+	for i := range lockReposList {
+		order[lockReposList[i].Path] = i
+	}
+	index := func(reposPath pathutil.ReposPath) int {
+		if i, exists := order[reposPath]; exists {
+			return i
+		}
+		return len(lockReposList)
+	}
+	sort.SliceStable(*reposList, func(i, j int) bool {
+		return index((*reposList)[i].Path) < index((*reposList)[j].Path)
+	})
+}
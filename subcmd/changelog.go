@@ -0,0 +1,176 @@
+package subcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/gitutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/timing"
+	"github.com/vim-volt/volt/transaction"
+)
+
+func init() {
+	cmdMap["changelog"] = &changelogCmd{}
+}
+
+type changelogCmd struct {
+	helped bool
+}
+
+func (cmd *changelogCmd) ProhibitRootExecution(args []string) bool { return true }
+
+func (cmd *changelogCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt changelog [-help] {repository} [{from}..{to}]
+
+Quick example
+  $ volt changelog tyru/caw.vim                  # will show commits which "volt get -u" installs
+  $ volt changelog tyru/caw.vim v1.0.0..v1.1.0   # will show commits between tags
+  $ volt changelog tyru/caw.vim v1.0.0..         # will show commits from tag to upstream
+
+Description
+  Show the commits (hash, subject, author, and date) of {repository} which
+  are reachable from {to} but not from {from}, in reverse chronological order.
+
+  {from} and {to} are revisions (e.g. a commit hash, a tag, or a branch).
+  If {from} is omitted, the version of {repository} in lock.json is used.
+  If {to} is omitted, the upstream commit is fetched and used. Then
+  "volt get -u" within 10 minutes upgrades {repository} to the fetched commit
+  without accessing the remote again (see "volt get -help").
+  If {from} or {to} is not in the local repository, all history, branches,
+  and tags are fetched.` + "\n\n")
+		fmt.Println("Options")
+		fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	return fs
+}
+
+func (cmd *changelogCmd) Run(args []string) *Error {
+	reposPath, from, to, err := cmd.parseArgs(args)
+	if err == ErrShowedHelp {
+		return nil
+	}
+	if err != nil {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: " + err.Error()}
+	}
+
+	err = cmd.doChangelog(reposPath, from, to)
+	if err != nil {
+		return &Error{Code: exitCodeOf(err, ExitGeneral), Msg: err.Error()}
+	}
+	return nil
+}
+
+func (cmd *changelogCmd) parseArgs(args []string) (pathutil.ReposPath, string, string, error) {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return "", "", "", ErrShowedHelp
+	}
+	if len(fs.Args()) == 0 || len(fs.Args()) > 2 {
+		fs.Usage()
+		return "", "", "", errors.New("{repository} and optional {from}..{to} must be given")
+	}
+	reposPath, err := pathutil.NormalizeRepos(fs.Arg(0))
+	if err != nil {
+		return "", "", "", err
+	}
+	var from, to string
+	if len(fs.Args()) == 2 {
+		revs := strings.SplitN(fs.Arg(1), "..", 2)
+		if len(revs) != 2 {
+			return "", "", "", errors.New("range must be {from}..{to}: " + fs.Arg(1))
+		}
+		from, to = revs[0], revs[1]
+	}
+	return reposPath, from, to, nil
+}
+
+func (cmd *changelogCmd) doChangelog(reposPath pathutil.ReposPath, from, to string) error {
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return errors.New("could not read lock.json: " + err.Error())
+	}
+	repos, err := lockJSON.Repos.FindByPath(reposPath)
+	if err != nil {
+		return err
+	}
+	if repos.Type != lockjson.ReposGitType {
+		return errors.New("static repository does not have commits: " + reposPath.String())
+	}
+	if !pathutil.Exists(reposPath.FullPath()) {
+		return errors.New("repository is not installed: " + reposPath.String())
+	}
+
+	cfg, err := config.Read()
+	if err != nil {
+		return errors.New("could not read config.toml: " + err.Error())
+	}
+
+	// Begin transaction
+	err = transaction.Create()
+	if err != nil {
+		return err
+	}
+	defer transaction.Remove()
+
+	if from == "" {
+		from = repos.Version
+	}
+	if to == "" {
+		logger.Debug("Fetching " + reposPath + " ...")
+		stop := timing.Start(timing.Network)
+		fetched, err := gitutil.Fetch(reposPath, cfg)
+		stop()
+		if err != nil {
+			return &networkError{err: errors.New("could not fetch upstream: " + err.Error())}
+		}
+		to = fetched.Remote
+	}
+
+	commits, err := gitutil.Changelog(reposPath, from, to)
+	if err != nil {
+		logger.Debugf("%s, fetching all refs of %s ...", err.Error(), reposPath)
+		stop := timing.Start(timing.Network)
+		err = gitutil.FetchAllRefs(reposPath, cfg)
+		stop()
+		if err != nil {
+			return &networkError{err: errors.New("could not fetch refs: " + err.Error())}
+		}
+		if err = gitutil.UpdateSubmodules(reposPath, repos.Submodules, cfg); err != nil {
+			return errors.New("could not update submodules: " + err.Error())
+		}
+		commits, err = gitutil.Changelog(reposPath, from, to)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%s (%s..%s): %d commits\n", reposPath, cmd.shortRev(from), cmd.shortRev(to), len(commits))
+	for _, c := range commits {
+		fmt.Printf("  %s %s (%s, %s)\n", c.Hash[:7], c.Subject, c.Author, c.Date.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// shortRev abbreviates a commit hash to 7 characters.
+// Other revisions (e.g. a tag) are returned as is.
+func (*changelogCmd) shortRev(rev string) string {
+	if len(rev) == 40 && strings.Trim(rev, "0123456789abcdef") == "" {
+		return rev[:7]
+	}
+	return rev
+}
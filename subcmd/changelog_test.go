@@ -0,0 +1,35 @@
+package subcmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/internal/testutil"
+)
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+
+// * Run `volt changelog {repository} HEAD..HEAD` (A, B)
+//   * Shows no commits
+// * Run `volt changelog {repository}` for not installed repository (!A, !B)
+func TestVoltChangelog(t *testing.T) {
+	t.Run("Run `volt changelog {repository} HEAD..HEAD`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		out, err := testutil.RunVolt("get", "tyru/dummy")
+		testutil.SuccessExit(t, out, err)
+
+		out, err = testutil.RunVolt("changelog", "tyru/dummy", "HEAD..HEAD")
+		testutil.SuccessExit(t, out, err)
+		if !strings.Contains(string(out), "github.com/tyru/dummy (HEAD..HEAD): 0 commits") {
+			t.Errorf("unexpected output: %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt changelog {repository}` for not installed repository", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		out, err := testutil.RunVolt("changelog", "tyru/dummy")
+		testutil.FailExit(t, out, err)
+	})
+}
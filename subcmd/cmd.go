@@ -1,14 +1,22 @@
 package subcmd
 
 import (
+	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"os/user"
 	"runtime"
+	"sort"
+	"strings"
 
 	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/gitutil"
+	"github.com/vim-volt/volt/httputil"
 	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/timing"
+	"github.com/vim-volt/volt/transaction"
 )
 
 var cmdMap = make(map[string]Cmd)
@@ -25,17 +33,116 @@ type Cmd interface {
 // On unit testing, a mock function was given.
 type RunnerFunc func(c Cmd, args []string) *Error
 
+// Exit codes of volt command.
+// Scripts can distinguish the kinds of failures by these codes.
+const (
+	// ExitGeneral is returned on errors which do not match other kinds.
+	ExitGeneral = 1
+	// ExitUsage is returned when arguments or options are invalid.
+	ExitUsage = 2
+	// ExitValidation is returned when lock.json, config.toml, or given
+	// values are invalid.
+	ExitValidation = 3
+	// ExitNetwork is returned when communicating with remote failed.
+	ExitNetwork = 4
+	// ExitPartialFailure is returned when some of the targets failed
+	// (e.g. 'volt get' failed to install some plugins).
+	ExitPartialFailure = 5
+	// ExitPermission is returned when a command was run with root priviledge.
+	ExitPermission = 6
+)
+
+var exitKinds = map[int]string{
+	ExitGeneral:        "general",
+	ExitUsage:          "usage",
+	ExitValidation:     "validation",
+	ExitNetwork:        "network",
+	ExitPartialFailure: "partial_failure",
+	ExitPermission:     "permission",
+}
+
 // Error is a command error.
 // It also has a exit code.
 type Error struct {
 	Code int
 	Msg  string
+
+	// json is true when -json option was given to volt command
+	json bool
 }
 
 func (e *Error) Error() string {
 	return e.Msg
 }
 
+// Print prints the error to stderr.
+// If -json option was given, it prints the error as JSON.
+func (e *Error) Print() {
+	if !e.json {
+		logger.Error(e.Msg)
+		return
+	}
+	kind, exists := exitKinds[e.Code]
+	if !exists {
+		kind = exitKinds[ExitGeneral]
+	}
+	envelope := map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    e.Code,
+			"kind":    kind,
+			"message": e.Msg,
+		},
+	}
+	b, err := json.Marshal(envelope)
+	if err != nil {
+		logger.Error(e.Msg)
+		return
+	}
+	fmt.Fprintln(os.Stderr, string(b))
+}
+
+// networkError is an error which occurred while communicating with remote.
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string {
+	return e.err.Error()
+}
+
+// partialFailureError is an error which means some of the targets failed.
+type partialFailureError struct {
+	msg string
+}
+
+func (e *partialFailureError) Error() string {
+	return e.msg
+}
+
+// usageError is an error which means the arguments of a command are invalid.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// exitCodeOf returns the exit code for err.
+// If err is not a specific kind of error, returns defaultCode.
+func exitCodeOf(err error, defaultCode int) int {
+	switch err.(type) {
+	case *networkError:
+		return ExitNetwork
+	case *partialFailureError:
+		return ExitPartialFailure
+	case *usageError:
+		return ExitUsage
+	default:
+		return defaultCode
+	}
+}
+
 // DefaultRunner simply runs command with args
 func DefaultRunner(c Cmd, args []string) *Error {
 	return c.Run(args)
@@ -43,6 +150,38 @@ func DefaultRunner(c Cmd, args []string) *Error {
 
 // Run is invoked by main(), each argument means 'volt {subcmd} {args}'.
 func Run(args []string, cont RunnerFunc) *Error {
+	// Parse global options
+	jsonError := false
+	for len(args) > 1 && isGlobalOption(args[1]) {
+		switch args[1] {
+		case "-json", "--json":
+			jsonError = true
+		case "-timings", "--timings":
+			timing.Enable()
+		}
+		args = append([]string{args[0]}, args[2:]...)
+	}
+	err := run(args, cont)
+	if timing.Enabled() {
+		timing.Report(os.Stderr)
+	}
+	if err != nil {
+		err.json = jsonError
+	}
+	return err
+}
+
+// isGlobalOption returns true if arg is an option of volt command, which is
+// given before subcommand name.
+func isGlobalOption(arg string) bool {
+	switch arg {
+	case "-json", "--json", "-timings", "--timings":
+		return true
+	}
+	return false
+}
+
+func run(args []string, cont RunnerFunc) *Error {
 	if os.Getenv("VOLT_DEBUG") != "" {
 		logger.SetLevel(logger.DebugLevel)
 	}
@@ -53,38 +192,139 @@ func Run(args []string, cont RunnerFunc) *Error {
 	subCmd := args[1]
 	args = args[2:]
 
-	// Expand subcommand alias
-	subCmd, args, err := expandAlias(subCmd, args)
-	if err != nil {
-		return &Error{Code: 1, Msg: err.Error()}
+	// Expand subcommand alias. 'volt config' is not an alias, and must work
+	// with broken config.toml to fix it
+	if subCmd != "config" {
+		var err error
+		subCmd, args, err = expandAlias(subCmd, args)
+		if err != nil {
+			return &Error{Code: ExitValidation, Msg: err.Error()}
+		}
+	}
+
+	// Set up proxy of all network operations (including spawned git processes),
+	// HTTP client, git command, and the colors and symbols of the output
+	if cfg, err := config.Read(); err == nil {
+		httputil.SetUpProxy(cfg)
+		httputil.SetUpClient(cfg)
+		gitutil.SetUpCommand(cfg)
+		switch {
+		case cfg.UI.Color == config.ColorAlways:
+			logger.SetColor(true)
+		case cfg.UI.Color == config.ColorNever || os.Getenv("NO_COLOR") != "":
+			logger.SetColor(false)
+		}
+		switch cfg.UI.Unicode {
+		case config.UnicodeAlways:
+			logger.SetUnicode(true, *cfg.UI.Emoji)
+		case config.UnicodeAuto:
+			logger.SetUnicode(logger.CanShowUnicode(), *cfg.UI.Emoji)
+		}
+		// The report is not mixed into the output of completion
+		if *cfg.Debug.Timings && subCmd != "__complete" {
+			timing.Enable()
+		}
 	}
 
 	c, exists := cmdMap[subCmd]
 	if !exists {
-		return &Error{Code: 3, Msg: "Unknown command '" + subCmd + "'"}
+		return &Error{Code: ExitUsage, Msg: "Unknown command '" + subCmd + "'"}
+	}
+
+	// Give the default flags in config.toml before args, so the flags of
+	// command line override them
+	if subCmd != "__complete" && subCmd != "config" {
+		flags, err := defaultFlags(c, subCmd)
+		if err != nil {
+			return &Error{Code: ExitValidation, Msg: err.Error()}
+		}
+		args = append(flags, args...)
 	}
 
 	// Disallow executing the commands which may modify files in root priviledge
 	if c.ProhibitRootExecution(args) {
 		err := detectPriviledgedUser()
 		if err != nil {
-			return &Error{Code: 4, Msg: err.Error()}
+			return &Error{Code: ExitPermission, Msg: err.Error()}
+		}
+	}
+
+	// Finish the transaction interrupted by crashed volt process, not to run
+	// the command on top of half-applied state
+	if subCmd != "help" && subCmd != "version" && subCmd != "__complete" && detectPriviledgedUser() == nil {
+		if err := transaction.Recover(); err != nil {
+			return &Error{Code: ExitGeneral, Msg: err.Error()}
 		}
 	}
 
 	return cont(c, args)
 }
 
+// expandAlias expands subCmd if it is an alias in [alias] section of
+// config.toml. An alias can refer to another alias, but subcommands cannot
+// be overridden by aliases.
 func expandAlias(subCmd string, args []string) (string, []string, error) {
 	cfg, err := config.Read()
 	if err != nil {
 		return "", nil, errors.New("could not read config.toml: " + err.Error())
 	}
-	if newArgs, exists := cfg.Alias[subCmd]; exists && len(newArgs) > 0 {
-		subCmd = newArgs[0]
-		args = append(newArgs[1:], args...)
+	var expanded []string
+	for {
+		if _, exists := cmdMap[subCmd]; exists {
+			return subCmd, args, nil
+		}
+		alias, exists := cfg.Alias[subCmd]
+		if !exists {
+			return subCmd, args, nil
+		}
+		for _, name := range expanded {
+			if name == subCmd {
+				return "", nil, fmt.Errorf("alias loop: %s -> %s", strings.Join(expanded, " -> "), subCmd)
+			}
+		}
+		expanded = append(expanded, subCmd)
+		newArgs := make([]string, 0, len(alias)-1+len(args))
+		newArgs = append(newArgs, alias[1:]...)
+		subCmd, args = alias[0], append(newArgs, args...)
+	}
+}
+
+// defaultFlags returns the flags in [{subCmd}] section of config.toml as
+// arguments (e.g. "check = true" of [get] is "-check=true").
+// An array gives the flag repeatedly. Unknown flags are warned and ignored.
+func defaultFlags(c Cmd, subCmd string) ([]string, error) {
+	values, err := config.CommandFlags(subCmd)
+	if err != nil {
+		return nil, errors.New("could not read config.toml: " + err.Error())
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fs := c.FlagSet()
+	flags := make([]string, 0, len(names))
+	for _, name := range names {
+		if fs.Lookup(name) == nil {
+			logger.Warnf("[%s] of config.toml: 'volt %s' does not have '-%s' option", subCmd, subCmd, name)
+			continue
+		}
+		switch v := values[name].(type) {
+		case []interface{}:
+			for i := range v {
+				flags = append(flags, fmt.Sprintf("-%s=%v", name, v[i]))
+			}
+		case map[string]interface{}:
+			logger.Warnf("[%s] of config.toml: the value of '%s' must not be a table", subCmd, name)
+		default:
+			flags = append(flags, fmt.Sprintf("-%s=%v", name, v))
+		}
 	}
-	return subCmd, args, nil
+	return flags, nil
 }
 
 // On Windows, this function always returns nil.
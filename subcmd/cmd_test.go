@@ -0,0 +1,126 @@
+package subcmd
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/internal/testutil"
+)
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+
+// Checks:
+// (a) Exit with the status of the kind of the error
+// (b) With -json option, the error is output as JSON
+//
+// * Run `volt {unknown command}` (!A, !B, a)
+// * Run `volt -json {unknown command}` (!B, a, b)
+// * Run `volt -json config set get.jobs 0` (!B, a, b)
+// * Run `volt -json profile set` and `volt -json config set get.jobs` (!B, a, b)
+// * Run `volt -json version` (A, B)
+func TestVoltExitCode(t *testing.T) {
+	exitCode := func(t *testing.T, err error) int {
+		t.Helper()
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			t.Fatalf("expected exit error but got %v", err)
+		}
+		return exitErr.ExitCode()
+	}
+	checkJSON := func(t *testing.T, out []byte, code int, kind string) {
+		t.Helper()
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		var envelope struct {
+			Error struct {
+				Code    int    `json:"code"`
+				Kind    string `json:"kind"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &envelope); err != nil {
+			t.Fatalf("error is not JSON: %s: %s", err.Error(), string(out))
+		}
+		if envelope.Error.Code != code || envelope.Error.Kind != kind || envelope.Error.Message == "" {
+			t.Errorf("expected code %d and kind %q but got %+v", code, kind, envelope.Error)
+		}
+	}
+
+	t.Run("Run `volt {unknown command}`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		out, err := testutil.RunVolt("no-such-command")
+		// (!A, !B)
+		testutil.FailExit(t, out, err)
+		// (a)
+		if code := exitCode(t, err); code != ExitUsage {
+			t.Errorf("expected %d but got %d", ExitUsage, code)
+		}
+	})
+
+	t.Run("Run `volt -json {unknown command}`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		out, err := testutil.RunVolt("-json", "no-such-command")
+		// (a)
+		if code := exitCode(t, err); code != ExitUsage {
+			t.Errorf("expected %d but got %d", ExitUsage, code)
+		}
+		// (b)
+		checkJSON(t, out, ExitUsage, "usage")
+	})
+
+	t.Run("Run `volt -json config set get.jobs 0`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		out, err := testutil.RunVolt("-json", "config", "set", "get.jobs", "0")
+		// (a)
+		if code := exitCode(t, err); code != ExitValidation {
+			t.Errorf("expected %d but got %d", ExitValidation, code)
+		}
+		// (b)
+		checkJSON(t, out, ExitValidation, "validation")
+	})
+
+	t.Run("Run `volt -json profile set`, `volt -json profile import`, and `volt -json config set get.jobs`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		for _, args := range [][]string{
+			{"-json", "profile", "set"},
+			{"-json", "profile", "import"},
+			{"-json", "profile", "export", "default", "foo"},
+			{"-json", "config", "set", "get.jobs"},
+		} {
+			out, err := testutil.RunVolt(args...)
+			// (a)
+			if code := exitCode(t, err); code != ExitUsage {
+				t.Errorf("%v: expected %d but got %d", args, ExitUsage, code)
+			}
+			// (b)
+			checkJSON(t, out, ExitUsage, "usage")
+		}
+	})
+
+	t.Run("Run `volt -json profile import {unreachable URL}`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		out, err := testutil.RunVolt("-json", "profile", "import", "http://127.0.0.1:1/lock.json")
+		// (a)
+		if code := exitCode(t, err); code != ExitNetwork {
+			t.Errorf("expected %d but got %d", ExitNetwork, code)
+		}
+		// (b)
+		checkJSON(t, out, ExitNetwork, "network")
+	})
+
+	t.Run("Run `volt -json version`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		out, err := testutil.RunVolt("-json", "version")
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+	})
+}
@@ -0,0 +1,279 @@
+package subcmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/haya14busa/go-vimlparser"
+	"github.com/haya14busa/go-vimlparser/ast"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// otherManager is the plugins installed by other plugin manager.
+type otherManager struct {
+	// name is the name of the plugin manager
+	name string
+	// source is the vimrc or the directory where the plugins were found
+	source  string
+	plugins []pathutil.ReposPath
+	// adopt is the command which installs the plugins by volt ("" means
+	// "volt get" of the plugins)
+	adopt string
+}
+
+// adoptCommand returns the command which installs unmanaged plugins by volt.
+func (m *otherManager) adoptCommand(unmanaged []pathutil.ReposPath) string {
+	if m.adopt != "" {
+		return m.adopt
+	}
+	return "volt get " + strings.Join(pathutil.ReposPathList(unmanaged).Strings(), " ")
+}
+
+var (
+	// rxDeinAddCall matches to "call dein#add(...)".
+	rxDeinAddCall = regexp.MustCompile(`^call\s+dein#add\s*\((.+)\)$`)
+	// rxDeinLoadTOMLCall matches to "call dein#load_toml(...)".
+	rxDeinLoadTOMLCall = regexp.MustCompile(`^call\s+dein#load_toml\s*\((.+)\)$`)
+	// rxMinpacAddCall matches to "call minpac#add(...)".
+	rxMinpacAddCall = regexp.MustCompile(`^call\s+minpac#add\s*\((.+)\)$`)
+)
+
+// detectOtherManagers finds the plugins of other plugin managers (vim-plug,
+// Vundle, dein.vim, minpac, packer.nvim, and pathogen) in vimrc and their
+// plugin directories.
+func detectOtherManagers() []otherManager {
+	var managers []otherManager
+	vimrcs := append(pathutil.LookUpVimrc(), filepath.Join(pathutil.HomeDir(), ".config", "nvim", "init.vim"))
+	for _, vimrc := range vimrcs {
+		if pathutil.Exists(vimrc) {
+			managers = append(managers, detectOtherManagersInVimrc(vimrc)...)
+		}
+	}
+
+	type pluginDir struct {
+		name string
+		dir  string
+	}
+	dirs := []pluginDir{
+		{name: "vim-plug", dir: filepath.Join(pathutil.VimDir(), "plugged")},
+		{name: "pathogen", dir: filepath.Join(pathutil.VimDir(), "bundle")},
+	}
+	for _, packDir := range []string{
+		filepath.Join(pathutil.VimDir(), "pack"),
+		filepath.Join(pathutil.HomeDir(), ".local", "share", "nvim", "site", "pack"),
+	} {
+		matches, _ := filepath.Glob(filepath.Join(packDir, "*", "*"))
+		for _, dir := range matches {
+			pack := filepath.Base(filepath.Dir(dir))
+			if pack == "volt" || filepath.Base(dir) != "start" && filepath.Base(dir) != "opt" {
+				continue
+			}
+			name := map[string]string{"minpac": "minpac", "packer": "packer.nvim"}[pack]
+			if name == "" {
+				name = "Vim packages"
+			}
+			dirs = append(dirs, pluginDir{name: name, dir: dir})
+		}
+	}
+	for _, d := range dirs {
+		if !pathutil.Exists(d.dir) {
+			continue
+		}
+		plugins, _, err := parseBundle([]string{d.dir})
+		if err != nil || len(plugins) == 0 {
+			continue
+		}
+		managers = append(managers, otherManager{
+			name:    d.name,
+			source:  d.dir,
+			plugins: importedReposPaths(plugins),
+			adopt:   "volt migrate from-bundle " + d.dir,
+		})
+	}
+	return managers
+}
+
+// detectOtherManagersInVimrc finds the plugins configured for other plugin
+// managers in vimrc.
+func detectOtherManagersInVimrc(vimrc string) []otherManager {
+	var managers []otherManager
+	for _, m := range []struct {
+		name  string
+		parse func([]string) ([]importedPlugin, []string, error)
+		adopt string
+	}{
+		{name: "vim-plug", parse: parseVimPlug, adopt: "volt migrate from-vimplug " + vimrc},
+		{name: "Vundle", parse: parseVundle, adopt: "volt migrate from-vundle " + vimrc},
+		{name: "dein.vim", parse: func(paths []string) ([]importedPlugin, []string, error) {
+			return parseVimCommands(paths, rxDeinAddCall, parseAddCallArgs)
+		}},
+		{name: "minpac", parse: func(paths []string) ([]importedPlugin, []string, error) {
+			return parseVimCommands(paths, rxMinpacAddCall, parseAddCallArgs)
+		}},
+	} {
+		plugins, _, err := m.parse([]string{vimrc})
+		if err != nil || len(plugins) == 0 {
+			continue
+		}
+		managers = append(managers, otherManager{
+			name:    m.name,
+			source:  vimrc,
+			plugins: importedReposPaths(plugins),
+			adopt:   m.adopt,
+		})
+	}
+
+	tomls := deinTOMLFiles(vimrc)
+	if len(tomls) > 0 {
+		if plugins, _, err := parseDein(tomls); err == nil && len(plugins) > 0 {
+			managers = append(managers, otherManager{
+				name:    "dein.vim",
+				source:  vimrc,
+				plugins: importedReposPaths(plugins),
+				adopt:   "volt migrate from-dein " + strings.Join(tomls, " "),
+			})
+		}
+	}
+	return managers
+}
+
+// parseAddCallArgs parses the arguments of "call {manager}#add({repository}
+// [, {options}])". The options are ignored.
+func parseAddCallArgs(args string) (*importedPlugin, []string, error) {
+	expr, err := vimlparser.ParseExpr(strings.NewReader("[" + args + "]"))
+	if err != nil {
+		return nil, nil, err
+	}
+	list, ok := expr.(*ast.List)
+	if !ok || len(list.Values) == 0 {
+		return nil, nil, fmt.Errorf("could not parse '%s'", args)
+	}
+	repos, err := vimLiteralString(list.Values[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if isLocalDir(repos) {
+		return nil, nil, fmt.Errorf("%s: local directory is not installed", repos)
+	}
+	reposPath, err := normalizeRemoteURL(repos)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &importedPlugin{reposPath: reposPath}, nil, nil
+}
+
+// deinTOMLFiles returns the existing TOML files of "call dein#load_toml()" in
+// vimrc.
+func deinTOMLFiles(vimrc string) []string {
+	content, err := ioutil.ReadFile(vimrc)
+	if err != nil {
+		return nil
+	}
+	var tomls []string
+	lines, _ := joinVimLines(content)
+	for _, line := range lines {
+		for _, cmd := range splitVimBar(stripVimComment(strings.TrimSpace(line))) {
+			m := rxDeinLoadTOMLCall.FindStringSubmatch(cmd)
+			if m == nil {
+				continue
+			}
+			expr, err := vimlparser.ParseExpr(strings.NewReader("[" + m[1] + "]"))
+			if err != nil {
+				continue
+			}
+			list, ok := expr.(*ast.List)
+			if !ok || len(list.Values) == 0 {
+				continue
+			}
+			file, err := vimLiteralString(list.Values[0])
+			if err != nil {
+				continue
+			}
+			if strings.HasPrefix(file, "~") {
+				file = pathutil.HomeDir() + file[1:]
+			}
+			file = os.ExpandEnv(file)
+			if pathutil.Exists(file) {
+				tomls = append(tomls, file)
+			}
+		}
+	}
+	return tomls
+}
+
+// pluginManagerNames is the names of the repositories of plugin managers.
+// They are not installed by volt.
+var pluginManagerNames = map[string]bool{
+	"vim-plug":     true,
+	"Vundle.vim":   true,
+	"vundle":       true,
+	"dein.vim":     true,
+	"minpac":       true,
+	"packer.nvim":  true,
+	"vim-pathogen": true,
+}
+
+// importedReposPaths returns the repository paths of plugins.
+func importedReposPaths(plugins []importedPlugin) []pathutil.ReposPath {
+	reposPathList := make([]pathutil.ReposPath, 0, len(plugins))
+	for i := range plugins {
+		reposPathList = append(reposPathList, plugins[i].reposPath)
+	}
+	return reposPathList
+}
+
+// samePluginIn returns the plugin in reposPathList which is the same as
+// reposPath. The plugins are compared by the repository path. Only the plugin
+// directories without git remote ("localhost/local/{name}", e.g. found in
+// the bundle directory) are compared by the name (the last element of the
+// path), because their URLs are unknown.
+func samePluginIn(reposPath pathutil.ReposPath, reposPathList []pathutil.ReposPath) (pathutil.ReposPath, bool) {
+	for _, rp := range reposPathList {
+		if rp == reposPath {
+			return rp, true
+		}
+	}
+	name := path.Base(reposPath.String())
+	for _, rp := range reposPathList {
+		if (isLocalReposPath(reposPath) || isLocalReposPath(rp)) && path.Base(rp.String()) == name {
+			return rp, true
+		}
+	}
+	return "", false
+}
+
+// isLocalReposPath returns true if reposPath is "localhost/local/{name}".
+func isLocalReposPath(reposPath pathutil.ReposPath) bool {
+	return path.Dir(reposPath.String()) == "localhost/local"
+}
+
+// warnOtherManagers warns about the plugins of reposPathList which are also
+// installed by other plugin managers.
+func warnOtherManagers(reposPathList []pathutil.ReposPath) {
+	for _, m := range detectOtherManagers() {
+		var both []string
+		for _, reposPath := range reposPathList {
+			if _, found := samePluginIn(reposPath, m.plugins); found {
+				both = append(both, reposPath.String())
+			}
+		}
+		if len(both) > 0 {
+			logger.Warnf("%s also installed by %s (%s) and may be loaded twice: %s",
+				pluralPlugins(len(both)), m.name, m.source, strings.Join(both, ", "))
+		}
+	}
+}
+
+// pluralPlugins returns "1 plugin is" or "{n} plugins are".
+func pluralPlugins(n int) string {
+	if n == 1 {
+		return "1 plugin is"
+	}
+	return fmt.Sprintf("%d plugins are", n)
+}
@@ -0,0 +1,67 @@
+package subcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func TestDetectOtherManagersInVimrc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	toml := filepath.Join(dir, "dein.toml")
+	if err := ioutil.WriteFile(toml, []byte("[[plugins]]\nrepo = 'Shougo/ddc.vim'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	vimrc := filepath.Join(dir, "vimrc")
+	src := `
+call plug#begin()
+Plug 'junegunn/fzf.vim'
+call plug#end()
+call minpac#add('k-takata/minpac', {'type': 'opt'}) | call minpac#add('tyru/caw.vim')
+call dein#add('Shougo/dein.vim')
+call dein#load_toml('` + toml + `', {'lazy': 0})
+`
+	if err := ioutil.WriteFile(vimrc, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []otherManager{
+		{name: "vim-plug", source: vimrc, plugins: []pathutil.ReposPath{"github.com/junegunn/fzf.vim"}, adopt: "volt migrate from-vimplug " + vimrc},
+		{name: "dein.vim", source: vimrc, plugins: []pathutil.ReposPath{"github.com/Shougo/dein.vim"}},
+		{name: "minpac", source: vimrc, plugins: []pathutil.ReposPath{"github.com/k-takata/minpac", "github.com/tyru/caw.vim"}},
+		{name: "dein.vim", source: vimrc, plugins: []pathutil.ReposPath{"github.com/Shougo/ddc.vim"}, adopt: "volt migrate from-dein " + toml},
+	}
+	managers := detectOtherManagersInVimrc(vimrc)
+	if !reflect.DeepEqual(managers, expected) {
+		t.Errorf("expected %+v but got %+v", expected, managers)
+	}
+	if cmd := managers[2].adoptCommand(managers[2].plugins[1:]); cmd != "volt get github.com/tyru/caw.vim" {
+		t.Errorf("unexpected adopt command: %s", cmd)
+	}
+}
+
+func TestSamePluginIn(t *testing.T) {
+	reposPathList := []pathutil.ReposPath{"github.com/a/vim-colors", "github.com/tyru/caw.vim"}
+	for _, tt := range []struct {
+		reposPath pathutil.ReposPath
+		expected  pathutil.ReposPath
+	}{
+		{reposPath: "github.com/tyru/caw.vim", expected: "github.com/tyru/caw.vim"},
+		{reposPath: "localhost/local/caw.vim", expected: "github.com/tyru/caw.vim"},
+		// Different plugins which have the same name
+		{reposPath: "github.com/b/vim-colors", expected: ""},
+	} {
+		rp, found := samePluginIn(tt.reposPath, reposPathList)
+		if rp != tt.expected || found != (tt.expected != "") {
+			t.Errorf("samePluginIn(%q): expected %q but got %q (found=%v)", tt.reposPath, tt.expected, rp, found)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package subcmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/subcmd/migrate"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func init() {
+	cmdMap["__complete"] = &completeCmd{}
+}
+
+// completeCmd is a hidden command which is invoked by shell completion
+// scripts. It is not listed in 'volt help'.
+type completeCmd struct{}
+
+func (cmd *completeCmd) ProhibitRootExecution(args []string) bool { return false }
+
+func (cmd *completeCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt __complete {arg} [{arg2} ...] {current}
+
+Description
+  Show candidates of {current} word for 'volt {arg} [{arg2} ...] {current}'.
+  Candidates are shown one per line.
+  If {current} is an empty string, all candidates are shown.
+  This command is used by shell completion scripts in _contrib/completion/.` + "\n\n")
+	}
+	return fs
+}
+
+// Run does not parse args as flags, because args are the words of the
+// command line being completed.
+func (cmd *completeCmd) Run(args []string) *Error {
+	if len(args) == 1 && args[0] == "-help" {
+		cmd.FlagSet().Usage()
+		return nil
+	}
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	current := args[len(args)-1]
+	for _, c := range cmd.candidates(args[:len(args)-1], current) {
+		if strings.HasPrefix(c, current) {
+			fmt.Println(c)
+		}
+	}
+	return nil
+}
+
+var profileSubCmdNames = []string{"set", "show", "list", "new", "destroy", "rename", "add", "rm", "export", "import"}
+
+// candidates returns candidates of current word.
+// words are the words before current word (not including "volt").
+// If an error occurred (e.g. lock.json is broken), no candidates are returned.
+func (cmd *completeCmd) candidates(words []string, current string) []string {
+	for len(words) > 0 && isGlobalOption(words[0]) {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return cmd.subCmdNames(true)
+	}
+	subCmd, words, err := expandAlias(words[0], words[1:])
+	if err != nil {
+		return nil
+	}
+	prev := subCmd
+	if len(words) > 0 {
+		prev = words[len(words)-1]
+	}
+
+	switch subCmd {
+	case "help":
+		if len(words) == 0 {
+			return cmd.subCmdNames(false)
+		}
+	case "get":
+		if strings.HasPrefix(current, "-") {
+			return []string{"-l", "-u", "-j", "-reinstall", "-from-starred"}
+		}
+		if i := strings.LastIndex(current, "@"); i > 0 {
+			return cmd.tagList(current[:i])
+		}
+		switch {
+		case prev == "-j" || cmd.contains(words, "-l"):
+			return nil
+		case cmd.contains(words, "-u") || cmd.contains(words, "-reinstall"):
+			return cmd.allReposList()
+		default:
+			return cmd.reposList("", false)
+		}
+	case "changelog":
+		if len(words) == 0 {
+			return cmd.allReposList()
+		}
+	case "rm":
+		if strings.HasPrefix(current, "-") {
+			return []string{"-r", "-p", "-plan"}
+		}
+		return cmd.reposList("", true)
+	case "enable":
+		return cmd.reposList("", false)
+	case "disable":
+		return cmd.reposList("", true)
+	case "list":
+		if len(words) == 0 {
+			return []string{"-f"}
+		}
+	case "profile":
+		return cmd.profileCandidates(words)
+	case "build":
+		if len(words) == 0 {
+			return []string{"-full"}
+		}
+	case "log":
+		if prev == "-format" {
+			return []string{"text", "json"}
+		}
+		if len(words) == 0 {
+			return []string{"-prune", "-format"}
+		}
+	case "undo":
+		if strings.HasPrefix(current, "-") {
+			return []string{"-m"}
+		}
+	case "migrate":
+		if len(words) == 0 {
+			migraters := migrate.ListMigraters()
+			names := make([]string, 0, len(migraters))
+			for i := range migraters {
+				names = append(names, migraters[i].Name())
+			}
+			return names
+		}
+	case "config":
+		switch {
+		case len(words) == 0:
+			return []string{"get", "set", "list", "validate"}
+		case len(words) == 1 && (words[0] == "get" || words[0] == "set"):
+			return config.Keys()
+		}
+	case "import":
+		if prev != "-profile" && strings.HasPrefix(current, "-") {
+			return []string{"-profile", "-plan"}
+		}
+	case "export":
+		if prev == "-shell" {
+			return []string{"sh", "powershell"}
+		}
+		return []string{"-bootstrap", "-shell", "-vimplug", "-voltfile", "-sbom"}
+	case "apply":
+		if prev != "-f" && strings.HasPrefix(current, "-") {
+			return []string{"-f", "-plan"}
+		}
+	case "sync":
+		if prev != "-from" && strings.HasPrefix(current, "-") {
+			return []string{"-from", "-plan"}
+		}
+	case "env":
+		if prev == "-shell" {
+			return []string{"sh", "powershell"}
+		}
+		if strings.HasPrefix(current, "-") {
+			return []string{"-shell", "-install"}
+		}
+	case "bench":
+		if prev == "-profile" {
+			return cmd.profileNames()
+		}
+		if prev != "-n" && strings.HasPrefix(current, "-") {
+			return []string{"-n", "-profile"}
+		}
+	case "prefetch":
+		if prev != "-interval" && prev != "-j" && strings.HasPrefix(current, "-") {
+			return []string{"-daemon", "-interval", "-j"}
+		}
+	case "profile-startup":
+		if prev != "-n" && strings.HasPrefix(current, "-") {
+			return []string{"-nvim", "-n"}
+		}
+	case "self-upgrade":
+		if len(words) == 0 {
+			return []string{"-check"}
+		}
+	}
+	return nil
+}
+
+func (cmd *completeCmd) profileCandidates(words []string) []string {
+	if len(words) == 0 {
+		return profileSubCmdNames
+	}
+	switch words[0] {
+	case "set", "destroy", "rename", "export":
+		if len(words) == 1 {
+			return cmd.profileNames()
+		}
+	case "show":
+		if len(words) == 1 {
+			return append([]string{"-current"}, cmd.profileNames()...)
+		}
+	case "add", "rm":
+		if len(words) == 1 {
+			return append([]string{"-current"}, cmd.profileNames()...)
+		}
+		profileName := words[1]
+		if profileName == "-current" {
+			profileName = ""
+		}
+		return cmd.reposList(profileName, words[0] == "rm")
+	}
+	return nil
+}
+
+// subCmdNames returns all subcommand names except hidden commands.
+// If withAlias is true, alias names in config.toml are also returned.
+func (*completeCmd) subCmdNames(withAlias bool) []string {
+	names := make([]string, 0, len(cmdMap))
+	for name := range cmdMap {
+		if !strings.HasPrefix(name, "__") {
+			names = append(names, name)
+		}
+	}
+	if withAlias {
+		if cfg, err := config.Read(); err == nil {
+			for name := range cfg.Alias {
+				if _, exists := cmdMap[name]; !exists {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (*completeCmd) profileNames() []string {
+	index, err := lockjson.ReadIndex()
+	if err != nil {
+		return nil
+	}
+	return index.Profiles
+}
+
+// reposList returns repositories in lock.json.
+// If inProfile is true, returns repositories which are in profile
+// profileName, otherwise returns ones which are not in the profile.
+// If profileName is empty, current profile is used.
+// "github.com/" prefix is omitted because 'volt' commands complement it.
+func (*completeCmd) reposList(profileName string, inProfile bool) []string {
+	index, err := lockjson.ReadIndex()
+	if err != nil {
+		return nil
+	}
+	if profileName == "" {
+		profileName = index.CurrentProfileName
+	}
+	if !index.HasProfile(profileName) {
+		return nil
+	}
+	list := make([]string, 0, len(index.Repos))
+	for i := range index.Repos {
+		if index.Repos[i].InProfile(profileName) != inProfile {
+			continue
+		}
+		list = append(list, strings.TrimPrefix(index.Repos[i].Path.String(), "github.com/"))
+	}
+	sort.Strings(list)
+	return list
+}
+
+// allReposList returns all repositories in lock.json.
+func (*completeCmd) allReposList() []string {
+	index, err := lockjson.ReadIndex()
+	if err != nil {
+		return nil
+	}
+	list := make([]string, 0, len(index.Repos))
+	for i := range index.Repos {
+		list = append(list, strings.TrimPrefix(index.Repos[i].Path.String(), "github.com/"))
+	}
+	sort.Strings(list)
+	return list
+}
+
+// tagList returns "{arg}@{tag}" list of tags in the local repository of arg.
+func (*completeCmd) tagList(arg string) []string {
+	reposPath, err := pathutil.NormalizeRepos(arg)
+	if err != nil {
+		return nil
+	}
+	r, err := git.PlainOpen(reposPath.FullPath())
+	if err != nil {
+		return nil
+	}
+	tags, err := r.Tags()
+	if err != nil {
+		return nil
+	}
+	var list []string
+	tags.ForEach(func(ref *plumbing.Reference) error {
+		list = append(list, arg+"@"+ref.Name().Short())
+		return nil
+	})
+	sort.Strings(list)
+	return list
+}
+
+func (*completeCmd) contains(words []string, word string) bool {
+	for i := range words {
+		if words[i] == word {
+			return true
+		}
+	}
+	return false
+}
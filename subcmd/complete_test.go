@@ -0,0 +1,65 @@
+package subcmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/internal/testutil"
+)
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+
+// * Run `volt __complete ""` (A, B)
+//   * Shows subcommands, but does not show hidden commands
+// * Run `volt __complete pro` (A, B)
+//   * Shows only subcommands which start with "pro"
+// * Run `volt __complete profile set ""` (A, B)
+//   * Shows profile names
+func TestVoltComplete(t *testing.T) {
+	t.Run("Run `volt __complete \"\"`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		out, err := testutil.RunVolt("__complete", "")
+		testutil.SuccessExit(t, out, err)
+		candidates := strings.Split(strings.TrimSpace(string(out)), "\n")
+		if !containsString(candidates, "get") || !containsString(candidates, "profile") {
+			t.Error("subcommands were not shown: " + string(out))
+		}
+		if containsString(candidates, "__complete") {
+			t.Error("hidden command was shown: " + string(out))
+		}
+	})
+
+	t.Run("Run `volt __complete pro`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		out, err := testutil.RunVolt("__complete", "pro")
+		testutil.SuccessExit(t, out, err)
+		if string(out) != "profile\nprofile-startup\n" {
+			t.Error("expected only 'profile' and 'profile-startup' but got: " + string(out))
+		}
+	})
+
+	t.Run("Run `volt __complete profile set \"\"`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		out, err := testutil.RunVolt("profile", "new", "foo")
+		testutil.SuccessExit(t, out, err)
+
+		out, err = testutil.RunVolt("__complete", "profile", "set", "")
+		testutil.SuccessExit(t, out, err)
+		if string(out) != "default\nfoo\n" {
+			t.Error("expected profile names but got: " + string(out))
+		}
+	})
+}
+
+func containsString(list []string, s string) bool {
+	for i := range list {
+		if list[i] == s {
+			return true
+		}
+	}
+	return false
+}
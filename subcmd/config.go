@@ -0,0 +1,240 @@
+package subcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func init() {
+	cmdMap["config"] = &configCmd{}
+}
+
+type configCmd struct {
+	helped bool
+}
+
+func (cmd *configCmd) ProhibitRootExecution(args []string) bool {
+	return len(args) > 0 && args[0] == "set"
+}
+
+func (cmd *configCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  config [-help] {command}
+
+Command
+  config get {key}
+    Show the value of {key}.
+
+  config set [-local] {key} {value}
+    Validate {value} and write it to {key} of $VOLTPATH/config.toml.
+    If -local was given, write it to $VOLTPATH/config.local.toml instead.
+    Other keys and comments in the file are kept.
+
+  config list
+    Show all keys and values (including default values).
+
+  config validate
+    Check $VOLTPATH/config.toml, $VOLTPATH/config.local.toml, and the
+    environment variables, and show the problems: syntax errors, the values of
+    wrong types or out of range, and unknown keys (e.g. typos). If there is no
+    error, the effective configuration (default values + config.toml +
+    config.local.toml + environment variables) is shown in TOML. Exits with
+    non-zero status if there is an error.
+
+Machine-local config
+  $VOLTPATH/config.local.toml has the same format as config.toml, and its
+  values override the values of config.toml. Put machine-specific settings
+  (e.g. proxy, concurrency) in it, and share config.toml in your dotfiles.
+
+Environment variables
+  Each key can be overridden by the environment variable "VOLT_{KEY}", where
+  {KEY} is the key in upper case and "." is replaced with "_" (e.g.
+  $VOLT_GET_JOBS for "get.jobs"). Empty variables are ignored.
+  The precedence is: environment variables > config.local.toml >
+  config.toml > default values.
+  "volt config get" and "volt config list" show the overridden values.
+
+Default flags
+  The other keys in the section of a subcommand name (e.g. [get], [list])
+  are the default values of the flags of the subcommand. They are given
+  before the arguments of command line, so the flags of command line
+  override them. An array gives the flag repeatedly.
+    [get]
+    check = true            # "volt get" is "volt get -check"
+    [list]
+    f = "{{ range .Repos }}{{ println .Path }}{{ end }}"
+
+Quick example
+  $ volt config list
+  $ volt config get get.jobs
+  8
+  $ volt config set get.jobs 16
+  $ volt config set -local get.jobs 4  # only on this machine
+  $ VOLT_GET_JOBS=4 volt get -u  # use 4 jobs only this time
+
+Keys
+`)
+		for _, key := range config.Keys() {
+			fmt.Printf("  %s\n    %s\n", key, config.KeyDescription(key))
+		}
+		fmt.Println()
+		cmd.helped = true
+	}
+	return fs
+}
+
+func (cmd *configCmd) Run(args []string) *Error {
+	// Parse args
+	args, err := cmd.parseArgs(args)
+	if err == ErrShowedHelp {
+		return nil
+	}
+	if err != nil {
+		return &Error{Code: ExitUsage, Msg: err.Error()}
+	}
+
+	subCmd := args[0]
+	switch subCmd {
+	case "get":
+		err = cmd.doGet(args[1:])
+	case "set":
+		err = cmd.doSet(args[1:])
+	case "list":
+		err = cmd.doList(args[1:])
+	case "validate":
+		err = cmd.doValidate(args[1:])
+	default:
+		return &Error{Code: ExitUsage, Msg: "Unknown subcommand: " + subCmd}
+	}
+
+	if err != nil {
+		return &Error{Code: exitCodeOf(err, ExitValidation), Msg: err.Error()}
+	}
+
+	return nil
+}
+
+func (cmd *configCmd) parseArgs(args []string) ([]string, error) {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return nil, ErrShowedHelp
+	}
+	if len(fs.Args()) == 0 {
+		fs.Usage()
+		return nil, errors.New("must specify subcommand")
+	}
+	return fs.Args(), nil
+}
+
+func (cmd *configCmd) doGet(args []string) error {
+	if len(args) != 1 {
+		cmd.FlagSet().Usage()
+		return &usageError{msg: "'volt config get' receives one key"}
+	}
+
+	cfg, err := config.Read()
+	if err != nil {
+		return errors.New("could not read config.toml: " + err.Error())
+	}
+	value, err := config.GetValue(cfg, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+	return nil
+}
+
+func (cmd *configCmd) doSet(args []string) error {
+	local := len(args) > 0 && args[0] == "-local"
+	if local {
+		args = args[1:]
+	}
+	if len(args) != 2 {
+		cmd.FlagSet().Usage()
+		return &usageError{msg: "'volt config set' receives key and value"}
+	}
+
+	file := pathutil.ConfigTOML()
+	if local {
+		file = pathutil.ConfigLocalTOML()
+	}
+	err := config.Set(args[0], args[1], local)
+	if err != nil {
+		return errors.New("could not set " + args[0] + " in " + file + ": " + err.Error())
+	}
+	logger.Infof("Set %s = %s in %s", args[0], args[1], filepath.Base(file))
+	if name := config.EnvName(args[0]); os.Getenv(name) != "" {
+		logger.Warnf("$%s is set, which overrides the value of %s", name, filepath.Base(file))
+	} else if !local && config.IsSetLocally(args[0]) {
+		logger.Warnf("%s is also set in config.local.toml, which overrides the value of config.toml", args[0])
+	}
+	return nil
+}
+
+func (cmd *configCmd) doList(args []string) error {
+	cfg, err := config.Read()
+	if err != nil {
+		return errors.New("could not read config.toml: " + err.Error())
+	}
+	for _, key := range config.Keys() {
+		value, err := config.GetValue(cfg, key)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s = %s\n", key, value)
+	}
+	return nil
+}
+
+func (cmd *configCmd) doValidate(args []string) error {
+	if len(args) != 0 {
+		cmd.FlagSet().Usage()
+		return &usageError{msg: "'volt config validate' receives no arguments"}
+	}
+
+	// The keys in [{subcommand}] section are the default flags (see
+	// defaultFlags())
+	isFlag := func(section, name string) bool {
+		c, exists := cmdMap[section]
+		return exists && c.FlagSet().Lookup(name) != nil
+	}
+	problems, err := config.Check(isFlag)
+	if err != nil {
+		return err
+	}
+	errCount := 0
+	for i := range problems {
+		if problems[i].Warning {
+			logger.Warn(problems[i].String())
+		} else {
+			logger.Error(problems[i].String())
+			errCount++
+		}
+	}
+	if errCount > 0 {
+		return fmt.Errorf("found %d error(s)", errCount)
+	}
+
+	cfg, err := config.Read()
+	if err != nil {
+		return err
+	}
+	effective, err := config.Effective(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Print(effective)
+	return nil
+}
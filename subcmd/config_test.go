@@ -0,0 +1,476 @@
+package subcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/internal/testutil"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+
+// * Run `volt config set {key} {value}` without config.toml (A, B)
+//   * `volt config get {key}` shows {value}
+// * Run `volt config set {key} {value}` with config.toml (A, B)
+//   * Only the line of {key} is rewritten, and comments and other keys are kept
+// * Run `volt config set {key} {value}` with config.toml which does not have {key} (A, B)
+//   * {key} is added to the section
+// * Run `volt config set {key} {value}` with invalid value (!A, !B)
+//   * config.toml is not changed
+// * Run `volt config set {key} {value}` with unknown key (!A, !B)
+// * Run `volt config`, `volt config get`, or `volt config set {key}` (!A, !B)
+// * Run `volt config set git.protocol {value}` (A, B)
+//   * "https" and "ssh" are accepted, and other values are rejected (!A, !B)
+// * Run `volt config set ui.color {value}` (A, B)
+//   * "always" colors the output even if it is not a terminal
+//   * "never" does not color the output
+//   * Other values are rejected (!A, !B)
+func TestVoltConfigSet(t *testing.T) {
+	writeConfig := func(t *testing.T, content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(pathutil.ConfigTOML(), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	readConfig := func(t *testing.T) string {
+		t.Helper()
+		b, err := ioutil.ReadFile(pathutil.ConfigTOML())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	t.Run("Run `volt config set {key} {value}` without config.toml", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		out, err := testutil.RunVolt("config", "set", "build.strategy", config.CopyBuilder)
+		testutil.SuccessExit(t, out, err)
+		out, err = testutil.RunVolt("config", "get", "build.strategy")
+		testutil.SuccessExit(t, out, err)
+		if strings.TrimSpace(string(out)) != config.CopyBuilder {
+			t.Errorf("expected %q but got %q", config.CopyBuilder, string(out))
+		}
+	})
+
+	t.Run("Run `volt config set {key} {value}` with config.toml", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		writeConfig(t, "# my config\n[build]\n# strategy\nstrategy = \"symlink\"  # comment\n\n[get]\n# jobs\njobs = 4\n")
+
+		out, err := testutil.RunVolt("config", "set", "get.jobs", "16")
+		testutil.SuccessExit(t, out, err)
+		expected := "# my config\n[build]\n# strategy\nstrategy = \"symlink\"  # comment\n\n[get]\n# jobs\njobs = 16\n"
+		if got := readConfig(t); got != expected {
+			t.Errorf("expected %q but got %q", expected, got)
+		}
+	})
+
+	t.Run("Run `volt config set {key} {value}` with config.toml which does not have {key}", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		writeConfig(t, "[get]\n# jobs\njobs = 4\n\n# build\n[build]\nstrategy = \"copy\"\n")
+
+		out, err := testutil.RunVolt("config", "set", "get.fallback_git_cmd", "true")
+		testutil.SuccessExit(t, out, err)
+		expected := "[get]\n# jobs\njobs = 4\nfallback_git_cmd = true\n\n# build\n[build]\nstrategy = \"copy\"\n"
+		if got := readConfig(t); got != expected {
+			t.Errorf("expected %q but got %q", expected, got)
+		}
+	})
+
+	t.Run("Run `volt config set {key} {value}` with invalid value", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		content := "[get]\njobs = 4\n"
+		writeConfig(t, content)
+
+		out, err := testutil.RunVolt("config", "set", "get.jobs", "0")
+		testutil.FailExit(t, out, err)
+		if got := readConfig(t); got != content {
+			t.Errorf("config.toml was changed: %q", got)
+		}
+	})
+
+	t.Run("Run `volt config set {key} {value}` with unknown key", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		out, err := testutil.RunVolt("config", "set", "get.unknown", "1")
+		testutil.FailExit(t, out, err)
+	})
+
+	t.Run("Run `volt config`, `volt config get`, or `volt config set {key}`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		for _, args := range [][]string{
+			{"config"},
+			{"config", "get"},
+			{"config", "set", "get.jobs"},
+		} {
+			out, err := testutil.RunVolt(args...)
+			testutil.FailExit(t, out, err)
+		}
+	})
+
+	t.Run("Run `volt config set git.protocol {value}`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		for _, value := range []string{config.SSHProtocol, config.HTTPSProtocol} {
+			out, err := testutil.RunVolt("config", "set", "git.protocol", value)
+			testutil.SuccessExit(t, out, err)
+			out, err = testutil.RunVolt("config", "get", "git.protocol")
+			testutil.SuccessExit(t, out, err)
+			if strings.TrimSpace(string(out)) != value {
+				t.Errorf("expected %q but got %q", value, string(out))
+			}
+		}
+		out, err := testutil.RunVolt("config", "set", "git.protocol", "git")
+		testutil.FailExit(t, out, err)
+	})
+
+	t.Run("Run `volt config set ui.color {value}`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		for _, tt := range []struct {
+			value   string
+			colored bool
+		}{
+			{config.ColorAlways, true},
+			{config.ColorNever, false},
+		} {
+			out, err := testutil.RunVolt("config", "set", "ui.color", tt.value)
+			testutil.SuccessExit(t, out, err)
+			// Show an error message
+			out, _ = testutil.RunVolt("config", "get", "get.unknown")
+			if got := strings.Contains(string(out), "\x1b["); got != tt.colored {
+				t.Errorf("ui.color=%s: expected colored = %v but got %v: %q", tt.value, tt.colored, got, string(out))
+			}
+		}
+		out, err := testutil.RunVolt("config", "set", "ui.color", "yes")
+		testutil.FailExit(t, out, err)
+	})
+}
+
+// * Run `volt config get get.jobs` with $VOLT_GET_JOBS (A, B)
+//   * Shows the value of the environment variable instead of config.toml
+// * Run `volt config get get.jobs` with invalid $VOLT_GET_JOBS (!A, !B)
+func TestVoltConfigEnv(t *testing.T) {
+	t.Run("Run `volt config get get.jobs` with $VOLT_GET_JOBS", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		out, err := testutil.RunVolt("config", "set", "get.jobs", "16")
+		testutil.SuccessExit(t, out, err)
+
+		os.Setenv("VOLT_GET_JOBS", "4")
+		defer os.Unsetenv("VOLT_GET_JOBS")
+		out, err = testutil.RunVolt("config", "get", "get.jobs")
+		testutil.SuccessExit(t, out, err)
+		if strings.TrimSpace(string(out)) != "4" {
+			t.Errorf("expected 4 but got %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt config get get.jobs` with invalid $VOLT_GET_JOBS", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		os.Setenv("VOLT_GET_JOBS", "0")
+		defer os.Unsetenv("VOLT_GET_JOBS")
+		out, err := testutil.RunVolt("config", "get", "get.jobs")
+		testutil.FailExit(t, out, err)
+		if !strings.Contains(string(out), "VOLT_GET_JOBS") {
+			t.Errorf("the variable is not shown in the error: %s", string(out))
+		}
+	})
+}
+
+// * Run `volt list` with [list] section in config.toml (A, B)
+//   * The value of "f" is used as -f option
+//   * The keys of config.toml (e.g. "jobs" of [get]) are not flags
+// * Run `volt list -f` with [list] section in config.toml (A, B)
+//   * The flag of command line overrides config.toml
+// * Run `volt list` with unknown flag in [list] section (!A, B)
+func TestVoltConfigDefaultFlags(t *testing.T) {
+	writeConfig := func(t *testing.T, content string) {
+		if err := ioutil.WriteFile(pathutil.ConfigTOML(), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Run `volt list` with [list] section in config.toml", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		writeConfig(t, "[get]\njobs = 4\n[list]\nf = \"{{ .CurrentProfileName }}\"\n")
+
+		out, err := testutil.RunVolt("list")
+		testutil.SuccessExit(t, out, err)
+		if string(out) != "default" {
+			t.Errorf("expected %q but got %q", "default", string(out))
+		}
+	})
+
+	t.Run("Run `volt list -f` with [list] section in config.toml", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		writeConfig(t, "[list]\nf = \"{{ .CurrentProfileName }}\"\n")
+
+		out, err := testutil.RunVolt("list", "-f", "{{ json .CurrentProfileName }}")
+		testutil.SuccessExit(t, out, err)
+		if string(out) != "\"default\"" {
+			t.Errorf("expected %q but got %q", "\"default\"", string(out))
+		}
+	})
+
+	t.Run("Run `volt list` with unknown flag in [list] section", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		writeConfig(t, "[list]\nformat = \"json\"\n")
+
+		out, err := testutil.RunVolt("list")
+		if err != nil {
+			t.Errorf("expected success exit but failed: %s", string(out))
+		}
+		if !strings.Contains(string(out), "-format") {
+			t.Errorf("the unknown flag is not warned: %s", string(out))
+		}
+	})
+}
+
+// * Run the aliases in [alias] section of config.toml (A, B)
+//   * A string alias is split into words, and quoted words are kept
+//   * An alias can refer to another alias
+//   * Subcommands are not overridden by aliases
+//   * `volt help {alias}` shows the expansion
+// * Run an alias which refers to itself (!A, !B)
+func TestVoltConfigAlias(t *testing.T) {
+	t.Run("Run the aliases in [alias] section of config.toml", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		content := `[alias]
+cur = "list -f '{{ .CurrentProfileName }}'"
+cur2 = ["cur"]
+list = ["version"]
+`
+		if err := ioutil.WriteFile(pathutil.ConfigTOML(), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range []string{"cur", "cur2"} {
+			out, err := testutil.RunVolt(name)
+			testutil.SuccessExit(t, out, err)
+			if string(out) != "default" {
+				t.Errorf("'volt %s': expected %q but got %q", name, "default", string(out))
+			}
+		}
+
+		out, err := testutil.RunVolt("list", "-f", "{{ .CurrentProfileName }}")
+		testutil.SuccessExit(t, out, err)
+		if string(out) != "default" {
+			t.Errorf("'volt list' was overridden by the alias: %q", string(out))
+		}
+
+		out, err = testutil.RunVolt("help", "cur")
+		testutil.SuccessExit(t, out, err)
+		expected := "'cur' is an alias for 'list -f '{{ .CurrentProfileName }}''\n"
+		if string(out) != expected {
+			t.Errorf("expected %q but got %q", expected, string(out))
+		}
+	})
+
+	t.Run("Run an alias which refers to itself", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		content := "[alias]\na = \"b -u\"\nb = [\"a\"]\n"
+		if err := ioutil.WriteFile(pathutil.ConfigTOML(), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := testutil.RunVolt("a")
+		testutil.FailExit(t, out, err)
+		if !strings.Contains(string(out), "alias loop: a -> b -> a") {
+			t.Errorf("the loop is not shown in the error: %s", string(out))
+		}
+	})
+}
+
+// * Run `volt config validate` with valid config.toml (A, B)
+//   * Shows the effective configuration
+// * Run `volt config validate` with unknown keys (!A, B)
+//   * Shows the most similar key
+//   * The default flags of subcommands are not unknown keys
+// * Run `volt config validate` with a value of wrong type (!A, !B)
+// * Run `volt config validate {argument}` (!A, !B)
+//   * Exits with the usage status
+func TestVoltConfigValidate(t *testing.T) {
+	writeConfig := func(t *testing.T, content string) {
+		if err := ioutil.WriteFile(pathutil.ConfigTOML(), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Run `volt config validate` with valid config.toml", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		writeConfig(t, "[build]\njobs = 4\n")
+
+		out, err := testutil.RunVolt("config", "validate")
+		testutil.SuccessExit(t, out, err)
+		if !strings.Contains(string(out), "jobs = 4") || !strings.Contains(string(out), "[network]") {
+			t.Errorf("the effective configuration is not shown: %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt config validate` with unknown keys", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		writeConfig(t, "[get]\njbos = 4\ncheck = true\n")
+
+		out, err := testutil.RunVolt("config", "validate")
+		if err != nil {
+			t.Errorf("expected success exit but failed: %s", string(out))
+		}
+		if !strings.Contains(string(out), "[WARN] get.jbos: unknown key (ignored): did you mean 'get.jobs'?") {
+			t.Errorf("the unknown key is not warned: %s", string(out))
+		}
+		if strings.Contains(string(out), "get.check") {
+			t.Errorf("the flag of 'volt get' is warned: %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt config validate` with a value of wrong type", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		writeConfig(t, "[build]\njobs = \"4\"\n")
+
+		out, err := testutil.RunVolt("config", "validate")
+		testutil.FailExit(t, out, err)
+		if !strings.Contains(string(out), "build.jobs: must be an integer") {
+			t.Errorf("the type of the value is not shown: %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt config validate {argument}`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		out, err := testutil.RunVolt("config", "validate", "build.jobs")
+		testutil.FailExit(t, out, err)
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != ExitUsage {
+			t.Errorf("expected exit status %d but got %v", ExitUsage, err)
+		}
+	})
+}
+
+// * Run `volt config get` with config.toml of old version (!A, B)
+//   * The renamed key is migrated in memory
+// * Run `volt migrate config` (A, B)
+//   * The migrated config.toml is written
+// * Run `volt config set` with config.toml of old version (!A, B)
+//   * The new key is written, and overrides the old key
+// * Run `volt config get` with config.toml of unknown version (!A, !B)
+func TestVoltConfigMigrate(t *testing.T) {
+	t.Run("Run `volt config get` with config.toml of old version", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		if err := ioutil.WriteFile(pathutil.ConfigTOML(), []byte("[network]\ntimeout = \"10s\"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := testutil.RunVolt("config", "get", "network.connect_timeout")
+		if err != nil {
+			t.Errorf("expected success exit but failed: %s", string(out))
+		}
+		if !strings.Contains(string(out), "auto-migration of config.toml: v1 -> v2") {
+			t.Errorf("the migration is not warned: %s", string(out))
+		}
+		if !strings.HasSuffix(string(out), "\n10s\n") {
+			t.Errorf("network.timeout was not migrated: %s", string(out))
+		}
+
+		out, err = testutil.RunVolt("migrate", "config")
+		if err != nil {
+			t.Errorf("expected success exit but failed: %s", string(out))
+		}
+		content, err := ioutil.ReadFile(pathutil.ConfigTOML())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(content), "version = 2") || !strings.Contains(string(content), `connect_timeout = "10s"`) {
+			t.Errorf("config.toml was not migrated: %s", string(content))
+		}
+
+		out, err = testutil.RunVolt("config", "get", "network.connect_timeout")
+		testutil.SuccessExit(t, out, err)
+		if string(out) != "10s\n" {
+			t.Errorf("expected %q but got %q", "10s\n", string(out))
+		}
+	})
+
+	t.Run("Run `volt config set` with config.toml of old version", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		if err := ioutil.WriteFile(pathutil.ConfigTOML(), []byte("[network]\ntimeout = \"10s\"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := testutil.RunVolt("config", "set", "network.connect_timeout", "20s")
+		if err != nil {
+			t.Errorf("expected success exit but failed: %s", string(out))
+		}
+		out, err = testutil.RunVolt("config", "get", "network.connect_timeout")
+		if err != nil {
+			t.Errorf("expected success exit but failed: %s", string(out))
+		}
+		if !strings.HasSuffix(string(out), "\n20s\n") {
+			t.Errorf("network.connect_timeout was not written: %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt config get` with config.toml of unknown version", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		if err := ioutil.WriteFile(pathutil.ConfigTOML(), []byte("version = 99\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := testutil.RunVolt("config", "get", "network.connect_timeout")
+		testutil.FailExit(t, out, err)
+		if !strings.Contains(string(out), "please upgrade volt") {
+			t.Errorf("the version is not shown in the error: %s", string(out))
+		}
+	})
+}
+
+// Checks:
+// (A) Does not show `[WARN]` and `[ERROR]` (unless it is expected)
+// (B) Exit with zero status (unless it is expected)
+//
+// * Run `volt config set -local` (A, B)
+//   * The value is written to config.local.toml, not config.toml
+//   * The value of config.local.toml overrides the value of config.toml
+// * Run `volt config set` for a key in config.local.toml (!A, B)
+//   * It warns that config.local.toml overrides the value
+func TestVoltConfigLocal(t *testing.T) {
+	testutil.SetUpEnv(t)
+
+	out, err := testutil.RunVolt("config", "set", "get.jobs", "16")
+	testutil.SuccessExit(t, out, err)
+	out, err = testutil.RunVolt("config", "set", "-local", "get.jobs", "4")
+	testutil.SuccessExit(t, out, err)
+	content, err := ioutil.ReadFile(pathutil.ConfigLocalTOML())
+	if err != nil {
+		t.Fatal("config.local.toml was not written: " + err.Error())
+	}
+	if !strings.Contains(string(content), "jobs = 4") {
+		t.Errorf("get.jobs was not written to config.local.toml: %s", string(content))
+	}
+
+	out, err = testutil.RunVolt("config", "get", "get.jobs")
+	testutil.SuccessExit(t, out, err)
+	if string(out) != "4\n" {
+		t.Errorf("expected %q but got %q", "4\n", string(out))
+	}
+
+	out, err = testutil.RunVolt("config", "set", "get.jobs", "8")
+	if err != nil {
+		t.Errorf("expected success exit but failed: %s", string(out))
+	}
+	if !strings.Contains(string(out), "also set in config.local.toml") {
+		t.Errorf("the override is not warned: %s", string(out))
+	}
+	out, err = testutil.RunVolt("config", "get", "get.jobs")
+	testutil.SuccessExit(t, out, err)
+	if string(out) != "4\n" {
+		t.Errorf("expected %q but got %q", "4\n", string(out))
+	}
+}
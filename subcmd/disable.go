@@ -7,6 +7,7 @@ import (
 	"os"
 
 	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/transaction"
 )
 
 func init() {
@@ -14,7 +15,8 @@ func init() {
 }
 
 type disableCmd struct {
-	helped bool
+	helped  bool
+	message string
 }
 
 func (cmd *disableCmd) ProhibitRootExecution(args []string) bool { return true }
@@ -25,19 +27,23 @@ func (cmd *disableCmd) FlagSet() *flag.FlagSet {
 	fs.Usage = func() {
 		fmt.Print(`
 Usage
-  volt disable [-help] {repository} [{repository2} ...]
+  volt disable [-help] [-m {message}] {repository} [{repository2} ...]
 
 Quick example
   $ volt disable tyru/caw.vim # will disable tyru/caw.vim plugin in current profile
 
 Description
   This is shortcut of:
-  volt profile rm {current profile} {repository} [{repository2} ...]` + "\n\n")
+  volt profile rm {current profile} {repository} [{repository2} ...]
+
+  If -m option was given, {message} is recorded as the reason of the change
+  (see "volt log").` + "\n\n")
 		//fmt.Println("Options")
 		//fs.PrintDefaults()
 		fmt.Println()
 		cmd.helped = true
 	}
+	fs.StringVar(&cmd.message, "m", "", messageFlagUsage)
 	return fs
 }
 
@@ -47,8 +53,9 @@ func (cmd *disableCmd) Run(args []string) *Error {
 		return nil
 	}
 	if err != nil {
-		return &Error{Code: 10, Msg: "Failed to parse args: " + err.Error()}
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: " + err.Error()}
 	}
+	transaction.SetMessage(cmd.message)
 
 	profCmd := profileCmd{}
 	err = profCmd.doRm(append(
@@ -56,7 +63,7 @@ func (cmd *disableCmd) Run(args []string) *Error {
 		reposPathList.Strings()...,
 	))
 	if err != nil {
-		return &Error{Code: 11, Msg: err.Error()}
+		return &Error{Code: ExitGeneral, Msg: err.Error()}
 	}
 
 	return nil
@@ -0,0 +1,200 @@
+package subcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/subcmd/builder"
+	"github.com/vim-volt/volt/subcmd/project"
+	"github.com/vim-volt/volt/transaction"
+)
+
+func init() {
+	cmdMap["env"] = &envCmd{}
+}
+
+type envCmd struct {
+	helped  bool
+	shell   string
+	install bool
+}
+
+func (cmd *envCmd) ProhibitRootExecution(args []string) bool { return true }
+
+func (cmd *envCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt env [-help] [-shell {shell}] [-install] [{dir}]
+
+Quick example
+  $ volt env -install     # will install the plugins of .volt.json in current directory
+  $ eval "$(volt env)"    # will load the plugins of .volt.json when Vim starts
+  $ volt env -shell powershell | Invoke-Expression   # same as above (PowerShell)
+
+Description
+  Print the shell commands which set $VOLT_PROJECT to the project of {dir}
+  (default: current directory). Vim started with $VOLT_PROJECT loads the
+  plugins of the project in addition to current profile's plugins.
+
+  A project is the directory which has .volt.json (looked up from {dir} to
+  its parents):
+
+    {
+      "plugins": ["fatih/vim-go", "tyru/caw.vim"],
+      "vimrc": ".vim/project.vim"
+    }
+
+  "plugins" are the repositories used in the project. They are not added to
+  any profile. "vimrc" is Vim script (relative to the project) sourced after
+  the plugins are loaded.
+
+  If .volt.json is not found, "volt env" prints the commands which unset
+  $VOLT_PROJECT. The project is registered in $VOLTPATH/projects.json and
+  ~/.vim/pack/volt is built when .volt.json was added or changed.
+
+  If -install option was given, the plugins which are not installed are
+  installed (without printing the shell commands). Otherwise they are
+  skipped with warnings.
+
+  Run "volt env" in the hook of your shell to switch projects automatically:
+
+    # bash (~/.bashrc)
+    PROMPT_COMMAND='eval "$(volt env)"'
+
+    # zsh (~/.zshrc)
+    autoload -Uz add-zsh-hook
+    _volt_env() { eval "$(volt env)" }
+    add-zsh-hook chpwd _volt_env
+    _volt_env` + "\n\n")
+		fmt.Println("Options")
+		fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	defaultShell := "sh"
+	if runtime.GOOS == "windows" {
+		defaultShell = "powershell"
+	}
+	fs.StringVar(&cmd.shell, "shell", defaultShell, "the syntax of the commands to be printed (\"sh\" or \"powershell\")")
+	fs.BoolVar(&cmd.install, "install", false, "install the plugins of the project which are not installed")
+	return fs
+}
+
+func (cmd *envCmd) Run(args []string) *Error {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return nil
+	}
+	if cmd.shell != "sh" && cmd.shell != "powershell" {
+		fs.Usage()
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: unknown shell: " + cmd.shell}
+	}
+	dir := "."
+	switch len(fs.Args()) {
+	case 0:
+	case 1:
+		dir = fs.Args()[0]
+	default:
+		fs.Usage()
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: too many arguments"}
+	}
+
+	projectDir, found := project.Find(dir)
+	if !found {
+		if cmd.install {
+			return &Error{Code: ExitValidation, Msg: project.FileName + " was not found in " + dir + " or its parents"}
+		}
+		fmt.Println(cmd.unsetCommand())
+		return nil
+	}
+	p, err := project.Read(projectDir)
+	if err != nil {
+		return &Error{Code: ExitValidation, Msg: "Could not read " + project.FileName + ": " + err.Error()}
+	}
+	if err = cmd.prepare(p); err != nil {
+		return &Error{Code: exitCodeOf(err, ExitGeneral), Msg: "Failed to prepare the project: " + err.Error()}
+	}
+	if !cmd.install {
+		fmt.Println(cmd.setCommand(p.Dir))
+	}
+	return nil
+}
+
+// prepare registers the project, installs its plugins (-install), and builds
+// ~/.vim/pack/volt if needed.
+func (cmd *envCmd) prepare(p *project.Project) error {
+	registered, err := project.Register(p.Dir)
+	if err != nil {
+		return errors.New("could not register the project: " + err.Error())
+	}
+
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return errors.New("could not read lock.json: " + err.Error())
+	}
+	var missing []pathutil.ReposPath
+	for _, reposPath := range p.ReposPath {
+		if !lockJSON.Repos.Contains(reposPath) {
+			missing = append(missing, reposPath)
+		}
+	}
+	if len(missing) > 0 {
+		if cmd.install {
+			// doGet builds ~/.vim/pack/volt
+			return (&getCmd{noProfile: true}).doGet(missing, lockJSON)
+		}
+		for _, reposPath := range missing {
+			logger.Warnf("%s is not installed (run \"volt env -install\" to install)", reposPath)
+		}
+	}
+
+	if !registered && !cmd.changed(p) {
+		return nil
+	}
+	err = transaction.Create()
+	if err != nil {
+		return err
+	}
+	defer transaction.Remove()
+	if err = builder.Build(false); err != nil {
+		return errors.New("could not build " + pathutil.VimVoltDir() + ": " + err.Error())
+	}
+	return nil
+}
+
+// changed returns true if .volt.json of p is newer than the script generated
+// by the last build.
+func (*envCmd) changed(p *project.Project) bool {
+	script, err := os.Stat(project.ScriptPath(p.Dir))
+	if err != nil {
+		return true
+	}
+	fi, err := os.Stat(filepath.Join(p.Dir, project.FileName))
+	return err != nil || fi.ModTime().After(script.ModTime())
+}
+
+func (cmd *envCmd) setCommand(dir string) string {
+	if cmd.shell == "powershell" {
+		return "$env:" + project.EnvName + " = '" + strings.Replace(dir, "'", "''", -1) + "'"
+	}
+	return "export " + project.EnvName + "='" + strings.Replace(dir, "'", `'\''`, -1) + "'"
+}
+
+func (cmd *envCmd) unsetCommand() string {
+	if cmd.shell == "powershell" {
+		return "Remove-Item Env:" + project.EnvName + " -ErrorAction SilentlyContinue"
+	}
+	return "unset " + project.EnvName
+}
@@ -0,0 +1,69 @@
+package subcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vim-volt/volt/subcmd/project"
+)
+
+func TestEnvShellCommands(t *testing.T) {
+	sh := &envCmd{shell: "sh"}
+	if got := sh.setCommand("/tmp/it's"); got != `export VOLT_PROJECT='/tmp/it'\''s'` {
+		t.Errorf("unexpected command: %s", got)
+	}
+	if got := sh.unsetCommand(); got != "unset VOLT_PROJECT" {
+		t.Errorf("unexpected command: %s", got)
+	}
+	ps := &envCmd{shell: "powershell"}
+	if got := ps.setCommand(`C:\it's`); got != `$env:VOLT_PROJECT = 'C:\it''s'` {
+		t.Errorf("unexpected command: %s", got)
+	}
+}
+
+func TestProjectRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+	sub := filepath.Join(dir, "src", "pkg")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := project.Find(sub); found {
+		t.Fatal("expected .volt.json is not found")
+	}
+
+	content := `{"plugins": ["tyru/caw.vim", "https://github.com/fatih/vim-go"]}`
+	if err := ioutil.WriteFile(filepath.Join(dir, project.FileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	projectDir, found := project.Find(sub)
+	if !found || projectDir != dir {
+		t.Fatalf("expected %s but got %s (%v)", dir, projectDir, found)
+	}
+	p, err := project.Read(projectDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.ReposPath) != 2 || p.ReposPath[0] != "github.com/tyru/caw.vim" || p.ReposPath[1] != "github.com/fatih/vim-go" {
+		t.Errorf("unexpected plugins: %v", p.ReposPath)
+	}
+
+	for _, content := range []string{
+		`{"plugins": ["tyru/caw.vim", "github.com/tyru/caw.vim"]}`,
+		`{"plugins": [], "vimrc": "none.vim"}`,
+		`{"plugin": []}`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, project.FileName), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := project.Read(dir); err == nil {
+			t.Errorf("expected an error for %s", content)
+		}
+	}
+}
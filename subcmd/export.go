@@ -0,0 +1,556 @@
+package subcmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/plugconf"
+)
+
+func init() {
+	cmdMap["export"] = &exportCmd{}
+}
+
+type exportCmd struct {
+	helped    bool
+	bootstrap bool
+	shell     string
+	vimplug   bool
+	voltfile  bool
+	sbom      bool
+}
+
+func (cmd *exportCmd) ProhibitRootExecution(args []string) bool { return false }
+
+func (cmd *exportCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt export [-help] [-bootstrap [-shell {sh|powershell}] | -vimplug | -voltfile | -sbom]
+
+Quick example
+  $ volt export >lock.json               # will output lock.json
+  $ volt export -bootstrap >bootstrap.sh # will output a shell script to set up a new machine
+  $ volt export -bootstrap -shell powershell >bootstrap.ps1
+  $ volt export -vimplug >plugins.vim   # will output "Plug" commands of vim-plug
+  $ volt export -voltfile >$VOLTPATH/voltfile.toml  # will output voltfile for "volt apply"
+  $ volt export -sbom >volt.cdx.json     # will output the list of plugins in CycloneDX format
+
+  On a new machine:
+  $ curl -fsSL https://example.com/dotfiles/bootstrap.sh | sh
+
+Description
+  Export current volt setup to stdout.
+
+  If -bootstrap option was not given, this command outputs the content of lock.json.
+
+  If -bootstrap option was given, this command outputs a self-contained script which:
+    1. Installs volt ` + voltVersion + ` into $HOME/bin (or $VOLT_INSTALL_DIR) if "volt" is not found in PATH
+    2. Restores lock.json, plugconf files, and rc files under $VOLTPATH
+       (existing files are backed up as "{file}.bak")
+    3. Runs "volt get -l" to install plugins of current profile, and "volt build"
+
+  Static repositories cannot be restored by the script because they exist only on this machine.
+  The script shows the list of such repositories, so please copy them manually.
+
+  If -vimplug option was given, this command outputs a vim-plug section of the
+  repositories of current profile, to share the plugins with vim-plug users
+  (or to stop using volt):
+    * The versions of lock.json are pinned by "commit" (or "tag" if a tag was
+      checked out) option
+    * s:build() of plugconf is converted to "do" option
+    * s:loaded_on() of plugconf is converted to "on" and "for" options
+  Static repositories and the other plugconf functions are shown as comments.
+
+  If -voltfile option was given, this command outputs the voltfile (see
+  "volt apply -help") of current lock.json. The tags and the detached commits
+  which were checked out are written as "ref".
+
+  If -sbom option was given, this command outputs the SBOM (software bill of
+  materials) of all repositories in lock.json in CycloneDX 1.4 JSON format, to
+  inventory third-party code. Each component has:
+    * "version" and "purl": the commit of lock.json
+    * "externalReferences": the URL of the repository
+    * "licenses": SPDX license ID detected from the license file (e.g.
+      "LICENSE"), or "Vim" if help files say that the plugin is distributed
+      under the Vim license. If the license file is not well-known license,
+      the first line of it is written as the name of the license
+  Static repositories have neither version nor URL.` + "\n\n")
+		fmt.Println("Options")
+		fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	fs.BoolVar(&cmd.bootstrap, "bootstrap", false, "output bootstrap script instead of lock.json")
+	fs.StringVar(&cmd.shell, "shell", "sh", "script type of -bootstrap (\"sh\" or \"powershell\")")
+	fs.BoolVar(&cmd.vimplug, "vimplug", false, "output vim-plug section instead of lock.json")
+	fs.BoolVar(&cmd.voltfile, "voltfile", false, "output voltfile instead of lock.json")
+	fs.BoolVar(&cmd.sbom, "sbom", false, "output SBOM in CycloneDX format instead of lock.json")
+	return fs
+}
+
+func (cmd *exportCmd) Run(args []string) *Error {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return nil
+	}
+	if cmd.shell != "sh" && cmd.shell != "powershell" {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: -shell must be \"sh\" or \"powershell\": " + cmd.shell}
+	}
+	if boolToCount(cmd.bootstrap)+boolToCount(cmd.vimplug)+boolToCount(cmd.voltfile)+boolToCount(cmd.sbom) > 1 {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: only one of -bootstrap, -vimplug, -voltfile, and -sbom can be specified"}
+	}
+
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return &Error{Code: ExitValidation, Msg: "Could not read lock.json: " + err.Error()}
+	}
+
+	var content []byte
+	switch {
+	case cmd.bootstrap:
+		content, err = cmd.generateBootstrap(lockJSON)
+	case cmd.vimplug:
+		content, err = cmd.generateVimPlug(lockJSON)
+	case cmd.voltfile:
+		content = cmd.generateVoltfile(lockJSON)
+	case cmd.sbom:
+		content, err = cmd.generateSBOM(lockJSON)
+	default:
+		content, err = cmd.marshalLockJSON(lockJSON)
+	}
+	if err != nil {
+		return &Error{Code: ExitGeneral, Msg: "Failed to export: " + err.Error()}
+	}
+	os.Stdout.Write(content)
+	return nil
+}
+
+func (*exportCmd) marshalLockJSON(lockJSON *lockjson.LockJSON) ([]byte, error) {
+	b, err := json.MarshalIndent(lockJSON, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// exportFile is a file to be restored by bootstrap script.
+type exportFile struct {
+	// relPath is a slash-separated path relative to $VOLTPATH
+	relPath string
+	content []byte
+}
+
+// collectFiles collects lock.json, plugconf files (.vim and .lua) of all repositories,
+// and all files under $VOLTPATH/rc/.
+func (cmd *exportCmd) collectFiles(lockJSON *lockjson.LockJSON) ([]exportFile, error) {
+	lockContent, err := cmd.marshalLockJSON(lockJSON)
+	if err != nil {
+		return nil, err
+	}
+	files := []exportFile{{relPath: "lock.json", content: lockContent}}
+
+	voltpath := pathutil.VoltPath()
+	for i := range lockJSON.Repos {
+		reposPath := lockJSON.Repos[i].Path
+		for _, path := range []string{reposPath.Plugconf(), reposPath.LuaPlugconf()} {
+			if !pathutil.Exists(path) {
+				continue
+			}
+			file, err := cmd.readFile(voltpath, path)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, *file)
+		}
+	}
+
+	rcDir := filepath.Join(voltpath, "rc")
+	if pathutil.Exists(rcDir) {
+		err = filepath.Walk(rcDir, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+			file, err := cmd.readFile(voltpath, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, *file)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(files[1:], func(i, j int) bool {
+		return files[i+1].relPath < files[j+1].relPath
+	})
+	return files, nil
+}
+
+func (*exportCmd) readFile(voltpath, path string) (*exportFile, error) {
+	rel, err := filepath.Rel(voltpath, path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+	return &exportFile{relPath: filepath.ToSlash(rel), content: content}, nil
+}
+
+func (cmd *exportCmd) generateBootstrap(lockJSON *lockjson.LockJSON) ([]byte, error) {
+	files, err := cmd.collectFiles(lockJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	// Static repositories can't be fetched from anywhere
+	var staticRepos []string
+	for i := range lockJSON.Repos {
+		if lockJSON.Repos[i].Type == lockjson.ReposStaticType {
+			staticRepos = append(staticRepos, lockJSON.Repos[i].Path.String())
+		}
+	}
+
+	// "volt get -l" fails when current profile has no repositories
+	reposList, err := lockJSON.GetCurrentReposList()
+	if err != nil {
+		return nil, err
+	}
+	doGet := false
+	for i := range reposList {
+		if reposList[i].Type == lockjson.ReposGitType {
+			doGet = true
+			break
+		}
+	}
+
+	if cmd.shell == "powershell" {
+		return cmd.generatePowerShell(files, staticRepos, doGet)
+	}
+	return cmd.generateShell(files, staticRepos, doGet)
+}
+
+const bootstrapReleaseURL = "https://github.com/vim-volt/volt/releases/download"
+
+func (*exportCmd) generateShell(files []exportFile, staticRepos []string, doGet bool) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`#!/bin/sh
+# This script was generated by "volt export -bootstrap".
+# It installs volt, restores $VOLTPATH files, and installs plugins.
+set -e
+
+VOLTPATH="${VOLTPATH:-$HOME/volt}"
+export VOLTPATH
+`)
+	fmt.Fprintf(&buf, "VOLT_VERSION='%s'\n", voltVersion)
+	fmt.Fprintf(&buf, "VOLT_RELEASE_URL='%s'\n", bootstrapReleaseURL)
+	buf.WriteString(`
+# Install volt if not found
+if command -v volt >/dev/null 2>&1; then
+  VOLT=volt
+else
+  case "$(uname -s)" in
+    Linux) os=linux ;;
+    Darwin) os=darwin ;;
+    *) echo "[ERROR] unsupported OS: $(uname -s)" >&2; exit 1 ;;
+  esac
+  case "$(uname -m)" in
+    x86_64|amd64) arch=amd64 ;;
+    i386|i686) arch=386 ;;
+    *) echo "[ERROR] unsupported architecture: $(uname -m)" >&2; exit 1 ;;
+  esac
+  installdir="${VOLT_INSTALL_DIR:-$HOME/bin}"
+  mkdir -p "$installdir"
+  VOLT="$installdir/volt"
+  url="$VOLT_RELEASE_URL/$VOLT_VERSION/volt-$VOLT_VERSION-$os-$arch"
+  echo "[INFO] Downloading $url ..."
+  if command -v curl >/dev/null 2>&1; then
+    curl -fsSL -o "$VOLT" "$url"
+  else
+    wget -q -O "$VOLT" "$url"
+  fi
+  chmod +x "$VOLT"
+fi
+
+volt_write() {
+  mkdir -p "$(dirname "$1")"
+  if [ -f "$1" ]; then
+    cp "$1" "$1.bak"
+  fi
+  cat >"$1"
+}
+
+# Restore files
+`)
+	for _, file := range files {
+		delim := heredocDelimiter(file.content)
+		fmt.Fprintf(&buf, "volt_write \"$VOLTPATH\"/%s <<'%s'\n", shellQuote(file.relPath), delim)
+		buf.Write(file.content)
+		buf.WriteString(delim + "\n")
+	}
+	if len(staticRepos) > 0 {
+		buf.WriteString("\n# Static repositories\n")
+		buf.WriteString("echo \"[WARN] Please copy the following static repositories to $VOLTPATH/repos/ manually:\" >&2\n")
+		for _, path := range staticRepos {
+			fmt.Fprintf(&buf, "echo %s >&2\n", shellQuote("[WARN]   "+path))
+		}
+	}
+	buf.WriteString("\n# Install plugins and build\n")
+	if doGet {
+		buf.WriteString("\"$VOLT\" get -l\n")
+	} else {
+		buf.WriteString("\"$VOLT\" build\n")
+	}
+	return buf.Bytes(), nil
+}
+
+func (*exportCmd) generatePowerShell(files []exportFile, staticRepos []string, doGet bool) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`# This script was generated by "volt export -bootstrap -shell powershell".
+# It installs volt, restores $VOLTPATH files, and installs plugins.
+$ErrorActionPreference = 'Stop'
+
+if (-not $env:VOLTPATH) { $env:VOLTPATH = Join-Path $env:USERPROFILE 'volt' }
+`)
+	fmt.Fprintf(&buf, "$VoltVersion = '%s'\n", voltVersion)
+	fmt.Fprintf(&buf, "$VoltReleaseURL = '%s'\n", bootstrapReleaseURL)
+	buf.WriteString(`
+# Install volt if not found
+if (Get-Command volt -ErrorAction SilentlyContinue) {
+  $Volt = 'volt'
+} else {
+  $arch = if ([Environment]::Is64BitOperatingSystem) { 'amd64' } else { '386' }
+  $installDir = if ($env:VOLT_INSTALL_DIR) { $env:VOLT_INSTALL_DIR } else { Join-Path $env:USERPROFILE 'bin' }
+  New-Item -ItemType Directory -Force -Path $installDir | Out-Null
+  $Volt = Join-Path $installDir 'volt.exe'
+  $url = "$VoltReleaseURL/$VoltVersion/volt-$VoltVersion-windows-$arch.exe"
+  Write-Host "[INFO] Downloading $url ..."
+  Invoke-WebRequest -UseBasicParsing -Uri $url -OutFile $Volt
+}
+
+function Write-VoltFile($RelPath, $Content) {
+  $path = Join-Path $env:VOLTPATH $RelPath
+  New-Item -ItemType Directory -Force -Path (Split-Path -Parent $path) | Out-Null
+  if (Test-Path $path) { Copy-Item -Force $path "$path.bak" }
+  [IO.File]::WriteAllText($path, $Content)
+}
+
+# Restore files
+`)
+	for _, file := range files {
+		if bytes.Contains(file.content, []byte("\n'@")) || bytes.HasPrefix(file.content, []byte("'@")) {
+			return nil, errors.New("cannot embed " + file.relPath + ": it contains a line beginning with \"'@\"")
+		}
+		// PowerShell here-string strips the last newline
+		fmt.Fprintf(&buf, "Write-VoltFile %s @'\n", powerShellQuote(strings.Replace(file.relPath, "/", "\\", -1)))
+		buf.Write(file.content)
+		buf.WriteString("\n'@\n")
+	}
+	if len(staticRepos) > 0 {
+		buf.WriteString("\n# Static repositories\n")
+		buf.WriteString("Write-Warning 'Please copy the following static repositories to $env:VOLTPATH\\repos\\ manually:'\n")
+		for _, path := range staticRepos {
+			fmt.Fprintf(&buf, "Write-Warning %s\n", powerShellQuote("  "+path))
+		}
+	}
+	buf.WriteString("\n# Install plugins and build\n")
+	if doGet {
+		buf.WriteString("& $Volt get -l\n")
+	} else {
+		buf.WriteString("& $Volt build\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// shellQuote quotes s as a word of sh, in which no characters are expanded.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// powerShellQuote quotes s as a verbatim string of PowerShell.
+func powerShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// heredocDelimiter returns a delimiter of here document which does not appear
+// as a line in content.
+func heredocDelimiter(content []byte) string {
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(string(content), "\n") {
+		lines[line] = true
+	}
+	delim := "__VOLT_EOF__"
+	for i := 1; lines[delim]; i++ {
+		delim = fmt.Sprintf("__VOLT_EOF_%d__", i)
+	}
+	return delim
+}
+
+// generateVimPlug generates "Plug" commands of vim-plug for the repositories
+// of current profile.
+func (*exportCmd) generateVimPlug(lockJSON *lockjson.LockJSON) ([]byte, error) {
+	reposList, err := lockJSON.GetCurrentReposList()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "\" Generated by \"volt export -vimplug\" (profile: %s)\n", lockJSON.CurrentProfileName)
+	buf.WriteString("call plug#begin()\n")
+	for i := range reposList {
+		repos := &reposList[i]
+		if repos.Type == lockjson.ReposStaticType {
+			fmt.Fprintf(&buf, "\" %s: static repository is not exported\n", repos.Path)
+			continue
+		}
+
+		var opts, comments []string
+		switch {
+		case repos.Head == lockjson.HeadTag && repos.HeadRef != "":
+			opts = append(opts, "'tag': "+vimPlugString(repos.HeadRef))
+		case repos.Version != "":
+			opts = append(opts, "'commit': "+vimPlugString(repos.Version))
+		}
+		if pathutil.Exists(repos.Path.Plugconf()) {
+			info, parseErr := plugconf.ParsePlugconfFile(repos.Path.Plugconf(), 0, repos.Path)
+			if parseErr.HasErrs() {
+				return nil, parseErr.Errors()
+			}
+			opts, comments = vimPlugOptions(info, opts)
+		}
+
+		name := repos.Path.String()
+		if strings.HasPrefix(name, "github.com/") {
+			name = strings.TrimPrefix(name, "github.com/")
+		} else {
+			name = repos.Path.CloneURL()
+		}
+		for _, c := range comments {
+			fmt.Fprintf(&buf, "\" %s: %s\n", repos.Path, c)
+		}
+		buf.WriteString("Plug " + vimPlugString(name))
+		if len(opts) > 0 {
+			buf.WriteString(", { " + strings.Join(opts, ", ") + " }")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("call plug#end()\n")
+	return buf.Bytes(), nil
+}
+
+// vimPlugOptions appends the options of vim-plug converted from plugconf to
+// opts. It also returns the plugconf settings which cannot be converted.
+func vimPlugOptions(info *plugconf.ParsedInfo, opts []string) ([]string, []string) {
+	var comments []string
+	if cmd := info.BuildCmd(); cmd != "" {
+		opts = append(opts, "'do': "+vimPlugString(cmd))
+	}
+	var on, fts []string
+	for _, trigger := range info.LoadOn() {
+		switch {
+		case trigger == "start":
+		case strings.HasPrefix(trigger, "filetype="):
+			fts = append(fts, strings.Split(strings.TrimPrefix(trigger, "filetype="), ",")...)
+		case strings.HasPrefix(trigger, "excmd="):
+			on = append(on, strings.Split(strings.TrimPrefix(trigger, "excmd="), ",")...)
+		case strings.HasPrefix(trigger, "mapping="):
+			on = append(on, strings.Split(strings.TrimPrefix(trigger, "mapping="), ",")...)
+		default:
+			comments = append(comments, "s:loaded_on() \""+trigger+"\" is not exported")
+		}
+	}
+	if len(on) > 0 {
+		opts = append(opts, "'on': "+vimPlugList(on))
+	}
+	if len(fts) > 0 {
+		opts = append(opts, "'for': "+vimPlugList(fts))
+	}
+	if info.HasOnLoadFuncs() {
+		comments = append(comments, "s:on_load_pre() and s:on_load_post() of plugconf are not exported")
+	}
+	return opts, comments
+}
+
+// vimPlugString returns Vim script string literal of s.
+func vimPlugString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// vimPlugList returns Vim script list literal of values, or a string literal
+// if values has one value.
+func vimPlugList(values []string) string {
+	if len(values) == 1 {
+		return vimPlugString(values[0])
+	}
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, vimPlugString(v))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// generateVoltfile generates voltfile of lockJSON.
+func (*exportCmd) generateVoltfile(lockJSON *lockjson.LockJSON) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by \"volt export -voltfile\". Run \"volt apply\" after editing.\n")
+	fmt.Fprintf(&buf, "current_profile = %s\n", strconv.Quote(lockJSON.CurrentProfileName))
+	names := make([]string, 0, len(lockJSON.Profiles))
+	for i := range lockJSON.Profiles {
+		names = append(names, strconv.Quote(lockJSON.Profiles[i].Name))
+	}
+	fmt.Fprintf(&buf, "profiles = [%s]\n", strings.Join(names, ", "))
+
+	for i := range lockJSON.Repos {
+		repos := &lockJSON.Repos[i]
+		buf.WriteString("\n[[plugins]]\n")
+		fmt.Fprintf(&buf, "repos = %s\n", strconv.Quote(repos.Path.String()))
+		switch {
+		case repos.Type != lockjson.ReposGitType:
+		case repos.Head == lockjson.HeadTag && repos.HeadRef != "":
+			fmt.Fprintf(&buf, "ref = %s\n", strconv.Quote(repos.HeadRef))
+		case repos.Head == lockjson.HeadDetached && repos.Version != "":
+			fmt.Fprintf(&buf, "ref = %s\n", strconv.Quote(repos.Version))
+		}
+		var profiles []string
+		for j := range lockJSON.Profiles {
+			if lockJSON.Profiles[j].ReposPath.Contains(repos.Path) {
+				profiles = append(profiles, strconv.Quote(lockJSON.Profiles[j].Name))
+			}
+		}
+		if len(profiles) != 1 || profiles[0] != `"default"` {
+			fmt.Fprintf(&buf, "profiles = [%s]\n", strings.Join(profiles, ", "))
+		}
+	}
+	return buf.Bytes()
+}
+
+func boolToCount(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
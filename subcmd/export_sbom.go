@@ -0,0 +1,200 @@
+package subcmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// cycloneDXBOM is a CycloneDX 1.4 BOM (JSON format).
+// See https://cyclonedx.org/docs/1.4/json/ for the specification.
+type cycloneDXBOM struct {
+	BOMFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Metadata    cycloneDXMetadata    `json:"metadata"`
+	Components  []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Tools []cycloneDXTool `json:"tools"`
+}
+
+type cycloneDXTool struct {
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type cycloneDXComponent struct {
+	Type               string                 `json:"type"`
+	BOMRef             string                 `json:"bom-ref"`
+	Name               string                 `json:"name"`
+	Group              string                 `json:"group,omitempty"`
+	Version            string                 `json:"version,omitempty"`
+	Purl               string                 `json:"purl,omitempty"`
+	Licenses           []cycloneDXLicenseItem `json:"licenses,omitempty"`
+	ExternalReferences []cycloneDXReference   `json:"externalReferences,omitempty"`
+}
+
+type cycloneDXLicenseItem struct {
+	License cycloneDXLicense `json:"license"`
+}
+
+// cycloneDXLicense is SPDX license ID, or the name of the license which is
+// not detected.
+type cycloneDXLicense struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type cycloneDXReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// generateSBOM returns CycloneDX BOM of all repositories in lock.json. It has
+// no timestamp and serial number, so the same lock.json and repositories
+// produce the same output.
+func (*exportCmd) generateSBOM(lockJSON *lockjson.LockJSON) ([]byte, error) {
+	bom := cycloneDXBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: cycloneDXMetadata{
+			Tools: []cycloneDXTool{{Vendor: "vim-volt", Name: "volt", Version: voltVersion}},
+		},
+		Components: make([]cycloneDXComponent, 0, len(lockJSON.Repos)),
+	}
+	for i := range lockJSON.Repos {
+		bom.Components = append(bom.Components, sbomComponent(&lockJSON.Repos[i]))
+	}
+	sort.Slice(bom.Components, func(i, j int) bool {
+		return bom.Components[i].BOMRef < bom.Components[j].BOMRef
+	})
+	b, err := json.MarshalIndent(&bom, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// sbomComponent returns the component of repos.
+func sbomComponent(repos *lockjson.Repos) cycloneDXComponent {
+	p := repos.Path.String()
+	c := cycloneDXComponent{
+		Type:   "library",
+		BOMRef: p,
+		Name:   path.Base(p),
+	}
+	if dir := path.Dir(p); dir != "." {
+		c.Group = dir
+	}
+	if repos.Type == lockjson.ReposGitType {
+		c.Version = repos.Version
+		c.Purl = sbomPurl(repos)
+		c.ExternalReferences = []cycloneDXReference{{Type: "vcs", URL: repos.Path.CloneURL()}}
+	}
+	if license := detectLicense(repos.Path); license != nil {
+		c.Licenses = []cycloneDXLicenseItem{{License: *license}}
+	}
+	return c
+}
+
+// sbomPurl returns the package URL of git repository.
+// See https://github.com/package-url/purl-spec for the specification.
+func sbomPurl(repos *lockjson.Repos) string {
+	elems := strings.Split(repos.Path.String(), "/")
+	if len(elems) == 3 && elems[0] == "github.com" {
+		return "pkg:github/" + strings.ToLower(elems[1]) + "/" + strings.ToLower(elems[2]) + "@" + repos.Version
+	}
+	return "pkg:generic/" + url.PathEscape(elems[len(elems)-1]) + "@" + repos.Version +
+		"?vcs_url=" + url.QueryEscape("git+"+repos.Path.CloneURL()+"@"+repos.Version)
+}
+
+var (
+	// rxLicenseFile matches to the names of license files.
+	rxLicenseFile = regexp.MustCompile(`(?i)\A(un)?licen[cs]e|\Acopying`)
+	// rxVimLicense matches to the license in help files of the plugins which
+	// are distributed under the Vim license.
+	rxVimLicense = regexp.MustCompile(`(?i)(same terms as|under the) vim(\s+itself|\s+license)`)
+)
+
+// spdxLicenses is the SPDX license IDs and the phrases of the licenses (all
+// phrases must be in the license file). The phrases are lower-cased and
+// the spaces are normalized.
+var spdxLicenses = []struct {
+	id      string
+	phrases []string
+}{
+	{id: "MIT", phrases: []string{"permission is hereby granted, free of charge"}},
+	{id: "Apache-2.0", phrases: []string{"apache license", "version 2.0"}},
+	{id: "BSD-3-Clause", phrases: []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{id: "BSD-2-Clause", phrases: []string{"redistribution and use in source and binary forms"}},
+	{id: "ISC", phrases: []string{"permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{id: "MPL-2.0", phrases: []string{"mozilla public license", "version 2.0"}},
+	{id: "Unlicense", phrases: []string{"this is free and unencumbered software released into the public domain"}},
+	{id: "WTFPL", phrases: []string{"do what the fuck you want to public license"}},
+	{id: "LGPL-3.0-only", phrases: []string{"gnu lesser general public license", "version 3"}},
+	{id: "LGPL-2.1-only", phrases: []string{"gnu lesser general public license", "version 2.1"}},
+	{id: "GPL-3.0-only", phrases: []string{"gnu general public license", "version 3"}},
+	{id: "GPL-2.0-only", phrases: []string{"gnu general public license", "version 2"}},
+}
+
+// detectLicense returns the license of reposPath, or nil if no license was
+// found. The license is detected from the license file (e.g. "LICENSE"), or
+// the statement of the Vim license in help files. If the license file is not
+// one of well-known licenses, its first line is returned as the name.
+func detectLicense(reposPath pathutil.ReposPath) *cycloneDXLicense {
+	dir := reposPath.FullPath()
+	files, _ := ioutil.ReadDir(dir)
+	for _, fi := range files {
+		if fi.IsDir() || !rxLicenseFile.MatchString(fi.Name()) {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			continue
+		}
+		text := strings.ToLower(strings.Join(strings.Fields(string(content)), " "))
+		for _, l := range spdxLicenses {
+			if containsAll(text, l.phrases) {
+				return &cycloneDXLicense{ID: l.id}
+			}
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				return &cycloneDXLicense{Name: line}
+			}
+		}
+	}
+
+	docs, _ := filepath.Glob(filepath.Join(dir, "doc", "*.txt"))
+	for _, doc := range docs {
+		if content, err := ioutil.ReadFile(doc); err == nil && rxVimLicense.Match(content) {
+			return &cycloneDXLicense{ID: "Vim"}
+		}
+	}
+	return nil
+}
+
+// containsAll returns true if s contains all of substrs.
+func containsAll(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if !strings.Contains(s, substr) {
+			return false
+		}
+	}
+	return true
+}
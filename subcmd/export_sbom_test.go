@@ -0,0 +1,61 @@
+package subcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func TestDetectLicense(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("VOLTPATH", os.Getenv("VOLTPATH"))
+	os.Setenv("VOLTPATH", dir)
+
+	for _, tt := range []struct {
+		file     string
+		content  string
+		expected *cycloneDXLicense
+	}{
+		{file: "LICENSE", content: "MIT License\n\nPermission is hereby granted, free of\ncharge, ...", expected: &cycloneDXLicense{ID: "MIT"}},
+		{file: "COPYING", content: "    GNU GENERAL PUBLIC LICENSE\n    Version 3, 29 June 2007\n", expected: &cycloneDXLicense{ID: "GPL-3.0-only"}},
+		{file: "LICENSE.txt", content: "\nMy own license\nYou can do anything.\n", expected: &cycloneDXLicense{Name: "My own license"}},
+		{file: "doc/foo.txt", content: "License: Same terms as Vim itself (see |license|)\n", expected: &cycloneDXLicense{ID: "Vim"}},
+		{file: "README.md", content: "Permission is hereby granted, free of charge", expected: nil},
+	} {
+		reposPath := pathutil.ReposPath("localhost/local/" + filepath.Base(tt.file))
+		path := filepath.Join(reposPath.FullPath(), filepath.FromSlash(tt.file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if license := detectLicense(reposPath); !reflect.DeepEqual(license, tt.expected) {
+			t.Errorf("%s: expected %+v but got %+v", tt.file, tt.expected, license)
+		}
+	}
+}
+
+func TestSBOMPurl(t *testing.T) {
+	for _, tt := range []struct {
+		reposPath pathutil.ReposPath
+		expected  string
+	}{
+		{reposPath: "github.com/tyru/Caw.vim", expected: "pkg:github/tyru/caw.vim@0123abc"},
+		{reposPath: "gitlab.com/foo/bar", expected: "pkg:generic/bar@0123abc?vcs_url=git%2Bhttps%3A%2F%2Fgitlab.com%2Ffoo%2Fbar%400123abc"},
+	} {
+		repos := &lockjson.Repos{Type: lockjson.ReposGitType, Path: tt.reposPath, Version: "0123abc"}
+		if purl := sbomPurl(repos); purl != tt.expected {
+			t.Errorf("expected %s but got %s", tt.expected, purl)
+		}
+	}
+}
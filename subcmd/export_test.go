@@ -0,0 +1,130 @@
+package subcmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/internal/testutil"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+
+// * Run `volt export` (A, B)
+//   * Outputs lock.json
+// * Run `volt export -bootstrap`, and run the script with new $VOLTPATH (B)
+//   * The files are restored even if their names have quotes, and their
+//     content has the delimiter of here document
+// * Run `volt export -bootstrap -shell powershell` (A, B)
+//   * The names of files are quoted
+// * Run `volt export -bootstrap -shell powershell` with a line beginning with "'@" (!A, !B)
+// * Run `volt export -bootstrap -shell fish` (!A, !B)
+func TestVoltExport(t *testing.T) {
+	writeRC := func(t *testing.T, name, content string) string {
+		t.Helper()
+		path := filepath.Join(pathutil.VoltPath(), "rc", "default", name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("Run `volt export`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		out, err := testutil.RunVolt("profile", "new", "foo")
+		testutil.SuccessExit(t, out, err)
+
+		out, err = testutil.RunVolt("export")
+		testutil.SuccessExit(t, out, err)
+		var lockJSON map[string]interface{}
+		if err := json.Unmarshal(out, &lockJSON); err != nil {
+			t.Fatalf("output is not JSON: %s: %s", err.Error(), string(out))
+		}
+		if !strings.Contains(string(out), `"foo"`) {
+			t.Errorf("profile is not exported: %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt export -bootstrap`, and run the script with new $VOLTPATH", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("sh is not available")
+		}
+		testutil.SetUpEnv(t)
+		files := map[string]string{
+			"vimrc.vim":          "set nocompatible\n__VOLT_EOF__\n",
+			"it's $HOME `x`.vim": "\" $HOME is not expanded\n",
+		}
+		for name, content := range files {
+			writeRC(t, name, content)
+		}
+
+		out, err := testutil.RunVolt("export", "-bootstrap")
+		// The script has "[WARN]" and "[ERROR]" messages
+		if err != nil {
+			t.Fatalf("expected success exit but failed: %s", string(out))
+		}
+		script := filepath.Join(os.Getenv("HOME"), "bootstrap.sh")
+		if err := ioutil.WriteFile(script, out, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		// Run the script with dummy volt command, which is not downloaded
+		binDir := filepath.Join(os.Getenv("HOME"), "bin")
+		os.MkdirAll(binDir, 0755)
+		if err := ioutil.WriteFile(filepath.Join(binDir, "volt"), []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		newVoltPath := filepath.Join(os.Getenv("HOME"), "newvolt")
+		cmd := exec.Command("sh", script)
+		cmd.Env = append(os.Environ(), "VOLTPATH="+newVoltPath, "PATH="+binDir+string(filepath.ListSeparator)+os.Getenv("PATH"))
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("bootstrap script failed: %s: %s", err.Error(), string(out))
+		}
+		for name, content := range files {
+			b, err := ioutil.ReadFile(filepath.Join(newVoltPath, "rc", "default", name))
+			if err != nil {
+				t.Errorf("%s was not restored: %s", name, err.Error())
+			} else if string(b) != content {
+				t.Errorf("%s: expected %q but got %q", name, content, string(b))
+			}
+		}
+		if !pathutil.Exists(filepath.Join(newVoltPath, "lock.json")) {
+			t.Error("lock.json was not restored")
+		}
+	})
+
+	t.Run("Run `volt export -bootstrap -shell powershell`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		writeRC(t, "it's.vim", "set nocompatible\n")
+
+		out, err := testutil.RunVolt("export", "-bootstrap", "-shell", "powershell")
+		testutil.SuccessExit(t, out, err)
+		if !strings.Contains(string(out), "Write-VoltFile 'rc\\default\\it''s.vim' @'\nset nocompatible\n\n'@\n") {
+			t.Errorf("the file is not quoted: %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt export -bootstrap -shell powershell` with a line beginning with \"'@\"", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		writeRC(t, "vimrc.vim", "'@\n")
+
+		out, err := testutil.RunVolt("export", "-bootstrap", "-shell", "powershell")
+		testutil.FailExit(t, out, err)
+	})
+
+	t.Run("Run `volt export -bootstrap -shell fish`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		out, err := testutil.RunVolt("export", "-bootstrap", "-shell", "fish")
+		testutil.FailExit(t, out, err)
+	})
+}
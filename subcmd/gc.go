@@ -0,0 +1,224 @@
+package subcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/fileutil"
+	"github.com/vim-volt/volt/gitutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/scheduler"
+	"github.com/vim-volt/volt/transaction"
+)
+
+func init() {
+	cmdMap["gc"] = &gcCmd{}
+}
+
+type gcCmd struct {
+	helped bool
+	jobs   int
+}
+
+func (cmd *gcCmd) ProhibitRootExecution(args []string) bool { return true }
+
+func (cmd *gcCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt gc [-help] [-j {jobs}]
+
+Quick example
+  $ volt gc       # will clean up all git repositories
+  $ volt gc -j 2  # will clean up 2 repositories at the same time
+
+Description
+  Clean up all git repositories in lock.json, and show the reclaimed disk space.
+  This executes "git gc --prune=now" for each repository, which packs loose
+  objects and removes unreachable objects (git command is required).
+  Static repositories are ignored.
+
+  Temporary clones which were left by interrupted operations ("$VOLTPATH/tmp")
+  and the git objects of removed repositories ("$VOLTPATH/cache", see
+  "volt rm -help") are also removed.
+
+  The number of repositories processed at the same time is determined by -j
+  option, or "jobs" in [get] section of config.toml (default: 8).` + "\n\n")
+		fmt.Println("Options")
+		fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	fs.IntVar(&cmd.jobs, "j", 0, "the number of parallel jobs (default: get.jobs in config.toml)")
+	return fs
+}
+
+func (cmd *gcCmd) Run(args []string) *Error {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return nil
+	}
+	if cmd.jobs < 0 {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: -j must be 1 or greater"}
+	}
+	if !gitutil.HasGitCmd() {
+		return &Error{Code: ExitGeneral, Msg: "git command is not found"}
+	}
+
+	err := cmd.doGC()
+	if err != nil {
+		return &Error{Code: exitCodeOf(err, ExitGeneral), Msg: err.Error()}
+	}
+	return nil
+}
+
+type gcResult struct {
+	reposPath pathutil.ReposPath
+	before    int64
+	after     int64
+	err       error
+}
+
+func (cmd *gcCmd) doGC() error {
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return errors.New("could not read lock.json: " + err.Error())
+	}
+
+	cfg, err := config.Read()
+	if err != nil {
+		return errors.New("could not read config.toml: " + err.Error())
+	}
+
+	// Begin transaction
+	err = transaction.Create()
+	if err != nil {
+		return err
+	}
+	defer transaction.Remove()
+
+	var reclaimed int64
+
+	// Remove temporary clones and the clone cache
+	tmpReclaimed, err := cmd.removeDir(pathutil.TempDir())
+	if err != nil {
+		return errors.New("could not remove temporary clones: " + err.Error())
+	}
+	reclaimed += tmpReclaimed
+	cacheReclaimed, err := cmd.removeDir(pathutil.CacheDir())
+	if err != nil {
+		return errors.New("could not remove the clone cache: " + err.Error())
+	}
+	reclaimed += cacheReclaimed
+
+	jobs := cmd.jobs
+	if jobs == 0 {
+		jobs = cfg.Get.Jobs
+	}
+
+	done := make(chan gcResult, len(lockJSON.Repos))
+	sched := scheduler.New(jobs, 0)
+	gcCount := 0
+	for i := range lockJSON.Repos {
+		repos := &lockJSON.Repos[i]
+		if repos.Type != lockjson.ReposGitType || !pathutil.Exists(repos.Path.FullPath()) {
+			continue
+		}
+		reposPath := repos.Path
+		sched.Go("", func() { done <- cmd.gcRepos(reposPath) })
+		gcCount++
+	}
+
+	failed := 0
+	statusList := make([]string, 0, gcCount)
+	for i := 0; i < gcCount; i++ {
+		r := <-done
+		if r.err != nil {
+			failed++
+			statusList = append(statusList,
+				fmt.Sprintf("! %s > gc failed\n  * %s", r.reposPath, r.err.Error()))
+			continue
+		}
+		if r.before <= r.after {
+			statusList = append(statusList, fmt.Sprintf("# %s > no change", r.reposPath))
+			continue
+		}
+		reclaimed += r.before - r.after
+		statusList = append(statusList, fmt.Sprintf("* %s > %s %s %s",
+			r.reposPath, formatSize(r.before), logger.GlyphArrow, formatSize(r.after)))
+	}
+	sort.Strings(statusList)
+
+	for i := range statusList {
+		fmt.Println(statusList[i])
+	}
+	if tmpReclaimed > 0 {
+		fmt.Printf("* removed temporary clones (%s)\n", formatSize(tmpReclaimed))
+	}
+	if cacheReclaimed > 0 {
+		fmt.Printf("* removed the clone cache (%s)\n", formatSize(cacheReclaimed))
+	}
+	fmt.Printf("%sDone: %s reclaimed, %d failed\n", logger.GlyphDone, formatSize(reclaimed), failed)
+
+	if failed > 0 {
+		return &partialFailureError{msg: "failed to clean up some repositories"}
+	}
+	return nil
+}
+
+func (*gcCmd) gcRepos(reposPath pathutil.ReposPath) gcResult {
+	fullpath := reposPath.FullPath()
+	before, err := fileutil.DirSize(fullpath)
+	if err != nil {
+		return gcResult{reposPath: reposPath, err: err}
+	}
+	logger.Debugf("Running gc on %s ...", reposPath)
+	if err = gitutil.GC(reposPath); err != nil {
+		return gcResult{reposPath: reposPath, err: err}
+	}
+	after, err := fileutil.DirSize(fullpath)
+	if err != nil {
+		return gcResult{reposPath: reposPath, err: err}
+	}
+	return gcResult{reposPath: reposPath, before: before, after: after}
+}
+
+// removeDir removes dir (e.g. "$VOLTPATH/tmp" where temporary clones are
+// created), and returns the size of removed files.
+func (*gcCmd) removeDir(dir string) (int64, error) {
+	if !pathutil.Exists(dir) {
+		return 0, nil
+	}
+	size, err := fileutil.DirSize(dir)
+	if err != nil {
+		return 0, err
+	}
+	logger.Debugf("Removing %s ...", dir)
+	if err = os.RemoveAll(dir); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// formatSize formats n bytes in human readable form (e.g. "1.5MiB").
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,64 @@
+package subcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/internal/testutil"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+
+// * Run `volt gc` (A, B)
+//   * Removes temporary clones ("$VOLTPATH/tmp")
+//   * Removes the clone cache ("$VOLTPATH/cache")
+//   * Does not remove repositories whose names look like temporary clones
+//   * Shows the summary
+func TestVoltGC(t *testing.T) {
+	t.Run("Run `volt gc`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		reposPath := pathutil.ReposPath("localhost/local/hello.vim.unshallow")
+		plugin := filepath.Join(reposPath.FullPath(), "plugin", "hello.vim")
+		os.MkdirAll(filepath.Dir(plugin), 0777)
+		if err := ioutil.WriteFile(plugin, []byte("\" hello"), 0666); err != nil {
+			t.Fatal("failed to create " + plugin)
+		}
+		out, err := testutil.RunVolt("get", reposPath.String())
+		testutil.SuccessExit(t, out, err)
+
+		tmpClone := filepath.Join(pathutil.TempDir(), "unshallow", "repos", "github.com", "tyru", "caw.vim")
+		tmpFile := filepath.Join(pathutil.TempDir(), "file")
+		cache := filepath.Join(pathutil.CacheDir(), "repos", "github.com", "tyru", "caw.vim")
+		for _, file := range []string{filepath.Join(tmpClone, "file"), tmpFile, filepath.Join(cache, "file")} {
+			os.MkdirAll(filepath.Dir(file), 0777)
+			if err := ioutil.WriteFile(file, []byte("hello"), 0666); err != nil {
+				t.Fatal("failed to create " + file)
+			}
+		}
+
+		out, err = testutil.RunVolt("gc")
+		testutil.SuccessExit(t, out, err)
+		if !pathutil.Exists(plugin) {
+			t.Error("repository was removed: " + reposPath.FullPath())
+		}
+		if pathutil.Exists(tmpClone) {
+			t.Error("temporary clone was not removed: " + tmpClone)
+		}
+		if pathutil.Exists(tmpFile) {
+			t.Error("temporary file was not removed: " + tmpFile)
+		}
+		if pathutil.Exists(cache) {
+			t.Error("clone cache was not removed: " + cache)
+		}
+		if !strings.Contains(string(out), "Done: 15B reclaimed, 0 failed") {
+			t.Error("summary was not shown: " + string(out))
+		}
+	})
+}
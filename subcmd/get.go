@@ -1,30 +1,35 @@
 package subcmd
 
 import (
+	"bufio"
 	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
 	"sort"
 	"strings"
+	"sync"
 
 	"gopkg.in/src-d/go-git.v4"
 
 	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/diskcache"
 	"github.com/vim-volt/volt/fileutil"
 	"github.com/vim-volt/volt/gitutil"
 	"github.com/vim-volt/volt/lockjson"
 	"github.com/vim-volt/volt/logger"
 	"github.com/vim-volt/volt/pathutil"
 	"github.com/vim-volt/volt/plugconf"
+	"github.com/vim-volt/volt/scheduler"
 	"github.com/vim-volt/volt/subcmd/builder"
+	"github.com/vim-volt/volt/subcmd/buildhook"
+	"github.com/vim-volt/volt/timing"
 	"github.com/vim-volt/volt/transaction"
 
 	multierror "github.com/hashicorp/go-multierror"
+	"github.com/mattn/go-isatty"
 )
 
 func init() {
@@ -35,6 +40,40 @@ type getCmd struct {
 	helped   bool
 	lockJSON bool
 	upgrade  bool
+	check    bool
+	// reinstall is the value of -reinstall option
+	reinstall bool
+	partial   bool
+	showPlan  bool
+	jobs      int
+	// message is the value of -m option
+	message string
+	// submodules is the value of -submodules option
+	submodules string
+	// cloneFilter is the value of -filter option
+	cloneFilter string
+	// include and exclude are the values of -include and -exclude options
+	include pathListFlag
+	exclude pathListFlag
+	// fromStarred is the value of -from-starred option
+	fromStarred string
+	// noProfile does not add the plugins to current profile (used by
+	// "volt env -install")
+	noProfile bool
+	// refs is the map of {repository} and {ref} of "{repository}@{ref}" arguments
+	refs map[pathutil.ReposPath]string
+	// versions is the map of {repository} and the repository in lock.json
+	// whose version is checked out at the state of HEAD instead of {ref}
+	// (see gitutil.CheckoutVersion). It is given by "volt sync".
+	versions map[pathutil.ReposPath]*lockjson.Repos
+	// args is the command line saved to resume failed transaction
+	args []string
+	// resume is the state of the failed transaction resumed by "volt resume"
+	resume *transaction.ResumeState
+
+	// promptMutex serializes questions to user (see askRewrittenHistory)
+	promptMutex sync.Mutex
+	stdin       *bufio.Reader
 }
 
 func (cmd *getCmd) ProhibitRootExecution(args []string) bool { return true }
@@ -45,12 +84,23 @@ func (cmd *getCmd) FlagSet() *flag.FlagSet {
 	fs.Usage = func() {
 		fmt.Println(`
 Usage
-  volt get [-help] [-l] [-u] [{repository} ...]
+  volt get [-help] [-l] [-u] [-check] [-reinstall] [-partial] [-plan] [-j {jobs}] [-submodules {none|shallow|recursive}]
+           [-include {path}] [-exclude {path}] [-filter {filter}] [-m {message}] [{repository}[@{ref}] ...]
+  volt get [-help] [-plan] [-from-starred {user}]
 
 Quick example
   $ volt get tyru/caw.vim     # will install tyru/caw.vim plugin
   $ volt get -u tyru/caw.vim  # will upgrade tyru/caw.vim plugin
   $ volt get -l -u            # will upgrade all plugins in current profile
+  $ volt get -l -u -j 16      # will upgrade 16 plugins at the same time
+  $ volt get -l -check        # will show plugins which can be upgraded
+  $ volt get -plan tyru/caw.vim  # will show what "volt get tyru/caw.vim" does
+  $ volt get -m "v2 breaks my mappings" tyru/caw.vim@v1.0.0  # will record the reason (see "volt log")
+  $ volt get tyru/caw.vim@v1.0.0  # will install tyru/caw.vim and check out tag "v1.0.0"
+  $ volt get -exclude test tyru/caw.vim  # will install tyru/caw.vim without "test" directory
+  $ volt get -filter blob:none tyru/caw.vim  # will install tyru/caw.vim by partial clone
+  $ volt get -reinstall tyru/caw.vim  # will clone tyru/caw.vim again at the locked version
+  $ volt get -from-starred tyru  # will install Vim plugins selected from GitHub stars of tyru
   $ VOLT_DEBUG=1 volt get tyru/caw.vim  # will output more verbosely
 
   $ mkdir -p ~/volt/repos/localhost/local/hello/plugin
@@ -61,15 +111,21 @@ Quick example
 Description
   Install or upgrade given {repository} list, or add local {repository} list as plugins.
 
-  And fetch skeleton plugconf from:
+  And fetch plugconf template of {repository} from:
     https://github.com/vim-volt/plugconf-templates
   and install it to:
     $VOLTPATH/plugconf/{repository}.vim
+  The template repository can be changed by "get.plugconf_template_url" in
+  config.toml (e.g. to your own repository or local directory).
+  If the template does not exist or cannot be fetched (e.g. offline),
+  skeleton plugconf is installed instead.
 
 Repository List
   {repository} list (=target to perform installing, upgrading, and so on) is determined as followings:
   * If -l option is specified, all plugins in current profile are used
   * If one or more {repository} arguments are specified, the arguments are used
+  * If -from-starred option is specified, the plugins selected from the
+    repositories starred by {user} in GitHub are used (see "GitHub stars")
 
 Action
   The action (install, upgrade, or add only) is determined as follows:
@@ -80,6 +136,155 @@ Action
       * Fetch {repository} list from remotes
       * Add {repository} list to lock.json (if not found)
 
+  Existing repositories are never cloned again: upgrading, checking out
+  "{repository}@{ref}", and fetching all history reuse the existing clone
+  (fetch and checkout of the new commit), even if the remote URL or the
+  checked out branch / tag was changed.
+  If -reinstall option is specified, git repositories in {repository} list
+  are removed and cloned again (e.g. when the clone is broken, or to apply
+  -filter), and the version in lock.json is checked out (the upstream commit
+  if -u was also specified). The existing clone is kept until the new clone
+  succeeds, and restored if the version in lock.json cannot be checked out
+  or the changes are rolled back.
+
+Plan
+  If -plan option is specified, the steps which will be performed (cloning
+  repositories, checking out refs, writing lock.json, and so on) are shown in
+  order, and nothing is changed. The steps are determined by the local state,
+  so whether upgrading actually changes a repository is not known until the
+  upstream commits are fetched (see "Upgrade check").
+
+Upgrade check
+  If -check option is specified, the upstream commits of git repositories in
+  {repository} list are fetched, and the repositories which can be upgraded
+  are shown. The worktrees and lock.json are not changed.
+  "volt get -u" within 10 minutes after that upgrades the repositories to the
+  fetched commits without accessing the remotes again. The fetched commits are
+  cached in $VOLTPATH/cache, and "volt get -check" within 10 minutes shows
+  them without fetching again.
+
+Parallelism
+  Repositories are installed or upgraded in parallel.
+  The number of repositories processed at the same time is determined by -j
+  option, or "jobs" in [get] section of config.toml (default: 8).
+  At most "jobs_per_host" in [network] section (default: 4) repositories of
+  the same host (e.g. github.com) are processed at the same time.
+  lock.json is written only once after all repositories are processed, and
+  the summary of all results is shown at the end.
+
+Batch updates
+  If some of the repositories failed, all changes are rolled back: installed
+  repositories are removed, upgraded repositories are checked out to the
+  previous commits, and lock.json and ~/.vim/pack/volt are not changed.
+  If -partial option is specified, the repositories which were installed or
+  upgraded successfully are kept (best-effort).
+  If volt is interrupted (Ctrl-C or SIGTERM), no more repositories are
+  processed, and all changes are rolled back after running operations finish.
+  Note that Ctrl-C also interrupts running git processes (they are in the same
+  process group as volt), so the repositories being processed fail.
+  By the second signal, volt exits immediately, and the changes are rolled
+  back when volt runs next time.
+
+GitHub stars
+  If -from-starred option is specified, the repositories starred by {user} are
+  fetched by GitHub API, and the plausible Vim plugins (by the language,
+  the topics, or the name like "vim-*" and "*.nvim") which are not installed
+  yet are shown with their descriptions. Then the plugins to be installed are
+  asked: the numbers or ranges of numbers (e.g. "1 3 5-7"), "a" for all, or
+  empty to cancel. The answer can also be given by stdin
+  (e.g. "echo a | volt get -from-starred {user}").
+
+Rewritten upstream history
+  If upstream history was rewritten (e.g. force-pushed), the current commit is
+  no longer reachable from the upstream branch and cannot be upgraded simply.
+  "volt get -u" detects it, and performs one of the followings according to
+  "on_force_push" in [get] section of config.toml:
+    * "ask" (default): Ask which of "keep" or "reset" is performed.
+                       If stdin is not a terminal, "keep" is performed.
+    * "keep":  Keep the current commit, and report it.
+    * "reset": Reset to the new upstream commit (local changes are discarded).
+  If the upstream branch was updated without rewriting its history, but the
+  current branch has local commits, it is not regarded as rewritten history.
+  "volt get -u" fails without changing the repository, then merge or rebase
+  the local commits manually.
+
+Submodules
+  Submodules of each repository are initialized and updated after installing
+  or upgrading it. How they are processed is determined by -submodules option,
+  or "submodules" of the repository in lock.json (default: "recursive"):
+    * "none":      Submodules are not initialized.
+    * "shallow":   Only top-level submodules are initialized with depth 1.
+    * "recursive": All nested submodules are initialized.
+  -submodules option is recorded to lock.json, so "volt get -u" keeps using it.
+  The checked out commits of submodules are also recorded to
+  "submodule_versions" of the repository in lock.json.
+
+Git LFS
+  If a repository stores files by Git LFS, they are downloaded by
+  "git lfs pull" after installing or upgrading it.
+  If git-lfs is not installed, the files are left as placeholders (pointer
+  files), and a warning shows which files are placeholders.
+
+Sparse checkout
+  -include and -exclude options (they can be given multiple times) restrict
+  the files checked out in the worktree of the repositories, e.g. to skip
+  large screenshots or test fixtures. {path} is relative to the repository.
+  If -include is not given, all files except -exclude paths are checked out.
+  The patterns are recorded to "sparse_checkout" of the repository in lock.json
+  (the syntax is same as .gitignore), and applied again after upgrading.
+  "-include /" checks out all files, and removes the patterns from lock.json.
+  git command is required for sparse checkout.
+
+Private repositories
+  If a repository requires authentication, the credential is obtained from
+  git credential helpers ("git credential fill", which also asks it on the
+  terminal or by GIT_ASKPASS). If git command is not installed, GIT_ASKPASS or
+  SSH_ASKPASS program is executed to ask it.
+  The credential is cached in memory only while volt is running, and volt
+  never writes it to files. It is stored by the credential helper after
+  successful authentication (if configured, e.g. "git config --global
+  credential.helper cache"), so it is not asked again.
+  SSH URLs use ssh-agent.
+
+Partial clone
+  -filter option clones the repositories partially when installing them
+  (e.g. "blob:none" fetches only commits and trees, and file contents are
+  fetched when they are checked out). This reduces the time and disk space of
+  cloning large plugins. If -filter is not given, "clone_filter" in [git]
+  section of config.toml is used. "-filter none" clones all objects.
+  The filter is recorded to "clone_filter" of the repository in lock.json, and
+  "volt import" clones the repository in the same way.
+  git command is required, and it is always used for partially cloned
+  repositories even if "backend" in [git] section of config.toml is "go-git".
+
+Signature verification
+  If "verify_signatures" in [git] section of config.toml is true, the commit
+  of installed or upgraded repository (or an annotated tag pointing to the
+  commit) must be signed by a key in $VOLTPATH/trusted_keys.asc
+  (ASCII-armored public keys, e.g. exported by "gpg --armor --export {key}").
+  Otherwise the repository is removed (install) or rolled back (upgrade),
+  and reported as failed.
+  The versions restored by rolling back a failed or interrupted volt command
+  are not verified, because they were installed before the command.
+
+Build hooks
+  If plugconf of a repository has s:build() function which returns a shell
+  command (e.g. "make"), the command is run in $VOLTPATH/repos/{repository}
+  after installing or upgrading it. The output is written to
+  $VOLTPATH/build-hook/{repository}.log . If the command fails, it is shown in
+  the results (the repository is not rolled back), and run again by next
+  "volt get". The command is not run again for the same commit after it
+  succeeded.
+
+Remote plugins
+  If an installed or upgraded repository has rplugin/ directory (remote
+  plugins of Neovim, e.g. Python or Node.js plugins), ":UpdateRemotePlugins"
+  is run by headless Neovim after building ~/.vim/pack/volt, so the remote
+  plugins can be used without running it manually. All repositories in the
+  current profile which have rplugin/ directory are registered. The output is
+  written to $VOLTPATH/build-hook/rplugin.log . If Neovim is not installed, it
+  is skipped. $VOLT_NVIM environment variable can specify nvim executable.
+
 Static repository
     Volt can manage a local directory as a repository. It's called "static repository".
     When you have unpublished plugins, or you want to manage ~/.vim/* files as one repository
@@ -105,6 +310,23 @@ Repository path
   3. https://{site}/{user}/{name}
   4. http://{site}/{user}/{name}
 
+  "@{ref}" can be appended to {repository} to check out {ref}, which is a tag,
+  a branch, or a commit hash (tags are searched first).
+  If {ref} is not found in the local repository, all history, branches, and
+  tags are fetched. The checked out commit is recorded to lock.json.
+  If {ref} is a branch, "volt get -u" follows the branch after that.
+  If {ref} is a tag or a commit hash, the version is pinned, and "volt get -u"
+  does not upgrade it. Run "volt get -u {repository}@{branch}" to unpin it.
+
+  Whether a branch, a tag, or a detached commit is checked out is recorded to
+  "head" and "head_ref" of the repository in lock.json, and restored when the
+  repository is checked out by "volt import" or rolled back.
+
+  If the installed plugins are also installed by other plugin managers
+  (e.g. "Plug" commands in vimrc, or ~/.vim/bundle), a warning is shown because
+  they may be loaded twice. "volt lint" shows all of them, and the command
+  which installs the others by volt.
+
 Options`)
 		fs.PrintDefaults()
 		fmt.Println()
@@ -112,36 +334,63 @@ Options`)
 	}
 	fs.BoolVar(&cmd.lockJSON, "l", false, "use all plugins in current profile as targets")
 	fs.BoolVar(&cmd.upgrade, "u", false, "upgrade plugins")
+	fs.BoolVar(&cmd.check, "check", false, "show plugins which can be upgraded (worktrees and lock.json are not changed)")
+	fs.BoolVar(&cmd.reinstall, "reinstall", false, "remove and clone the repositories again instead of reusing the existing clones")
+	fs.BoolVar(&cmd.partial, "partial", false, "keep successfully installed / upgraded plugins even if some plugins failed")
+	fs.BoolVar(&cmd.showPlan, "plan", false, "show the steps to be performed without changing anything")
+	fs.IntVar(&cmd.jobs, "j", 0, "the number of parallel jobs (default: get.jobs in config.toml)")
+	fs.Var(&cmd.include, "include", "check out only {path} (can be given multiple times)")
+	fs.Var(&cmd.exclude, "exclude", "do not check out {path} (can be given multiple times)")
+	fs.StringVar(&cmd.cloneFilter, "filter", "", "the filter of partial clone: \"blob:none\", \"blob:limit={size}\", \"tree:{depth}\", or \"none\" (default: git.clone_filter in config.toml)")
+	fs.StringVar(&cmd.message, "m", "", messageFlagUsage)
+	fs.StringVar(&cmd.fromStarred, "from-starred", "", "select plugins to be installed from the repositories starred by {user} in GitHub")
+	fs.StringVar(&cmd.submodules, "submodules", "", "how submodules are updated: \"none\", \"shallow\", or \"recursive\" (default: the value in lock.json, or \"recursive\")")
 	return fs
 }
 
 func (cmd *getCmd) Run(args []string) *Error {
+	cmd.args = append([]string{"get"}, args...)
+
 	// Parse args
 	args, err := cmd.parseArgs(args)
 	if err == ErrShowedHelp {
 		return nil
 	}
 	if err != nil {
-		return &Error{Code: 10, Msg: "Failed to parse args: " + err.Error()}
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: " + err.Error()}
 	}
+	transaction.SetMessage(cmd.message)
 
 	// Read lock.json
 	lockJSON, err := lockjson.Read()
 	if err != nil {
-		return &Error{Code: 11, Msg: "Could not read lock.json: " + err.Error()}
+		return &Error{Code: ExitValidation, Msg: "Could not read lock.json: " + err.Error()}
 	}
 
 	reposPathList, err := cmd.getReposPathList(args, lockJSON)
 	if err != nil {
-		return &Error{Code: 12, Msg: "Could not get repos list: " + err.Error()}
+		return &Error{Code: ExitValidation, Msg: "Could not get repos list: " + err.Error()}
 	}
 	if len(reposPathList) == 0 {
-		return &Error{Code: 13, Msg: "No repositories are specified"}
+		if cmd.fromStarred != "" {
+			return nil
+		}
+		return &Error{Code: ExitUsage, Msg: "No repositories are specified"}
+	}
+	if cmd.check && len(cmd.refs) > 0 {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: -check cannot be used with \"{repository}@{ref}\""}
 	}
 
-	err = cmd.doGet(reposPathList, lockJSON)
+	if cmd.check {
+		err = cmd.doCheck(reposPathList, lockJSON)
+	} else {
+		err = cmd.doGet(reposPathList, lockJSON)
+	}
 	if err != nil {
-		return &Error{Code: 20, Msg: err.Error()}
+		return &Error{Code: exitCodeOf(err, ExitGeneral), Msg: err.Error()}
+	}
+	if !cmd.check && !cmd.showPlan && !cmd.lockJSON {
+		warnOtherManagers(reposPathList)
 	}
 
 	return nil
@@ -154,17 +403,43 @@ func (cmd *getCmd) parseArgs(args []string) ([]string, error) {
 		return nil, ErrShowedHelp
 	}
 
-	if !cmd.lockJSON && len(fs.Args()) == 0 {
+	if cmd.fromStarred != "" {
+		if cmd.lockJSON || cmd.upgrade || cmd.check || len(fs.Args()) > 0 {
+			return nil, errors.New("-from-starred cannot be used with -l, -u, -check, and {repository}")
+		}
+	} else if !cmd.lockJSON && len(fs.Args()) == 0 {
 		fs.Usage()
 		return nil, errors.New("repository was not given")
 	}
 
+	if cmd.jobs < 0 {
+		return nil, errors.New("-j must be 1 or greater")
+	}
+
+	if cmd.check && (cmd.upgrade || cmd.reinstall || cmd.partial || cmd.showPlan || cmd.include != nil || cmd.exclude != nil || cmd.submodules != "") {
+		return nil, errors.New("-check cannot be used with -u, -reinstall, -partial, -plan, -include, -exclude, and -submodules")
+	}
+
+	if cmd.cloneFilter != "none" {
+		if err := config.ValidateCloneFilter(cmd.cloneFilter); err != nil {
+			return nil, errors.New("-filter " + err.Error())
+		}
+	}
+
+	switch cmd.submodules {
+	case "", lockjson.SubmodulesNone, lockjson.SubmodulesShallow, lockjson.SubmodulesRecursive:
+	default:
+		return nil, fmt.Errorf("-submodules must be %q, %q, or %q", lockjson.SubmodulesNone, lockjson.SubmodulesShallow, lockjson.SubmodulesRecursive)
+	}
+
 	return fs.Args(), nil
 }
 
 func (cmd *getCmd) getReposPathList(args []string, lockJSON *lockjson.LockJSON) ([]pathutil.ReposPath, error) {
 	var reposPathList []pathutil.ReposPath
-	if cmd.lockJSON {
+	if cmd.fromStarred != "" {
+		return cmd.selectStarredPlugins(cmd.fromStarred, lockJSON)
+	} else if cmd.lockJSON {
 		reposList, err := lockJSON.GetCurrentReposList()
 		if err != nil {
 			return nil, err
@@ -175,17 +450,39 @@ func (cmd *getCmd) getReposPathList(args []string, lockJSON *lockjson.LockJSON)
 		}
 	} else {
 		reposPathList = make([]pathutil.ReposPath, 0, len(args))
+		cmd.refs = make(map[pathutil.ReposPath]string, len(args))
 		for _, arg := range args {
-			reposPath, err := pathutil.NormalizeRepos(arg)
+			reposPath, ref, err := cmd.parseReposArg(arg)
 			if err != nil {
 				return nil, err
 			}
+			if ref != "" {
+				cmd.refs[reposPath] = ref
+			}
 			reposPathList = append(reposPathList, reposPath)
 		}
 	}
 	return reposPathList, nil
 }
 
+// parseReposArg splits "{repository}@{ref}" into {repository} and {ref}.
+// If arg does not have "@{ref}", returned ref is empty.
+func (*getCmd) parseReposArg(arg string) (pathutil.ReposPath, string, error) {
+	// "@" may be a part of URL (e.g. "https://user@github.com/...").
+	// Split only when the left side is a valid repository.
+	if i := strings.LastIndex(arg, "@"); i > 0 {
+		if reposPath, err := pathutil.NormalizeRepos(arg[:i]); err == nil {
+			ref := arg[i+1:]
+			if ref == "" {
+				return "", "", errors.New("empty ref is given: " + arg)
+			}
+			return reposPath, ref, nil
+		}
+	}
+	reposPath, err := pathutil.NormalizeRepos(arg)
+	return reposPath, "", err
+}
+
 func (cmd *getCmd) doGet(reposPathList []pathutil.ReposPath, lockJSON *lockjson.LockJSON) error {
 	// Find matching profile
 	profile, err := lockJSON.Profiles.FindByName(lockJSON.CurrentProfileName)
@@ -195,6 +492,18 @@ func (cmd *getCmd) doGet(reposPathList []pathutil.ReposPath, lockJSON *lockjson.
 		return err
 	}
 
+	// Read config.toml
+	cfg, err := config.Read()
+	if err != nil {
+		return errors.New("could not read config.toml: " + err.Error())
+	}
+
+	p := cmd.planGet(reposPathList, lockJSON, profile, cfg)
+	if cmd.showPlan {
+		p.show()
+		return nil
+	}
+
 	// Begin transaction
 	err = transaction.Create()
 	if err != nil {
@@ -202,30 +511,161 @@ func (cmd *getCmd) doGet(reposPathList []pathutil.ReposPath, lockJSON *lockjson.
 	}
 	defer transaction.Remove()
 
-	// Read config.toml
-	cfg, err := config.Read()
+	jobs := cmd.jobsOf(cfg)
+	r, err := cmd.getTargets(p, lockJSON, profile, cfg, jobs)
 	if err != nil {
-		return errors.New("could not read config.toml: " + err.Error())
+		return err
+	}
+	statusList := r.statusList
+	fullBuild := r.fullBuild
+
+	// Roll back all changes unless -partial was specified
+	if r.failed && !cmd.partial {
+		statusList = r.rolledBackList
+		if err = transaction.SaveResume(cmd.args, r.resumeSteps, true); err != nil {
+			logger.Warn("Could not save the state to resume: " + err.Error())
+		}
+		if err = transaction.Rollback(); err != nil {
+			return err
+		}
+		sort.Strings(statusList)
+		for i := range statusList {
+			fmt.Println(statusList[i])
+		}
+		if len(statusList) > 1 {
+			fmt.Println(cmd.formatSummary(statusList))
+		}
+		if transaction.Interrupted() {
+			return transaction.ErrInterrupted
+		}
+		return &partialFailureError{msg: "failed to install some plugins: all changes were rolled back (-partial keeps successful ones)"}
 	}
 
-	done := make(chan getParallelResult, len(reposPathList))
-	getCount := 0
-	// Invoke installing / upgrading tasks
-	for _, reposPath := range reposPathList {
-		repos, err := lockJSON.Repos.FindByPath(reposPath)
+	// Do not build with the changes to be rolled back
+	if transaction.Interrupted() {
+		return transaction.ErrInterrupted
+	}
+
+	// Run build hooks of the installed / upgraded plugins
+	hookStatusList, hookRan := cmd.runBuildHooks(r.succeeded, jobs)
+	statusList = append(statusList, hookStatusList...)
+	if hookRan {
+		// The files in the worktree were changed by build hooks
+		fullBuild = true
+	}
+
+	if r.updatedLockJSON {
+		// Write to lock.json
+		err = lockJSON.Write()
 		if err != nil {
-			repos = nil
+			return errors.New("could not write to lock.json: " + err.Error())
 		}
-		if repos == nil || repos.Type == lockjson.ReposGitType {
-			go cmd.getParallel(reposPath, repos, cfg, done)
-			getCount++
+	}
+
+	// Build ~/.vim/pack/volt dir
+	err = builder.Build(fullBuild)
+	if err != nil {
+		return errors.New("could not build " + pathutil.VimVoltDir() + ": " + err.Error())
+	}
+
+	// Register remote plugins of Neovim in the built directories
+	rpluginStatusList := cmd.updateRemotePlugins(r.succeeded, lockJSON)
+	statusList = append(statusList, rpluginStatusList...)
+
+	// Sort by status
+	sort.Strings(statusList)
+
+	// Failed plugins were already rolled back, keep installed / upgraded ones
+	// (-partial)
+	if r.failed {
+		if err = transaction.SaveResume(cmd.args, r.resumeSteps, false); err != nil {
+			logger.Warn("Could not save the state to resume: " + err.Error())
+		}
+	}
+	if err = transaction.Commit(); err != nil {
+		return err
+	}
+	if !r.failed && cmd.resume != nil {
+		if err = transaction.RemoveResume(); err != nil {
+			logger.Warn("Could not remove the state to resume: " + err.Error())
 		}
 	}
 
+	// Show results
+	for i := range statusList {
+		fmt.Println(statusList[i])
+	}
+	if len(statusList) > 1 {
+		fmt.Println(cmd.formatSummary(statusList))
+	}
+	if r.failed {
+		return &partialFailureError{msg: "failed to install some plugins"}
+	}
+	if len(hookStatusList) > 0 {
+		return &partialFailureError{msg: "failed to run build hooks of some plugins"}
+	}
+	if len(rpluginStatusList) > 0 {
+		return &partialFailureError{msg: "failed to register remote plugins"}
+	}
+	return nil
+}
+
+// getResult is the result of getTargets.
+type getResult struct {
+	statusList []string
+	// rolledBackList is statusList when all changes are rolled back
+	rolledBackList []string
+	// resumeSteps are saved to resume the transaction if some plugins failed
+	resumeSteps []transaction.ResumeStep
+	// succeeded are the results of installed / upgraded plugins
+	succeeded       []getParallelResult
+	failed          bool
+	updatedLockJSON bool
+	// fullBuild is true if the files in the worktrees were changed without
+	// changing the versions
+	fullBuild bool
+}
+
+// getTargets installs or upgrades the targets of p in current transaction
+// (at most jobs targets at once), and updates lockJSON. lock.json is not
+// written, and the changes are not rolled back even if some targets failed.
+func (cmd *getCmd) getTargets(p *getPlan, lockJSON *lockjson.LockJSON, profile *lockjson.Profile, cfg *config.Config, jobs int) (*getResult, error) {
+	err := gitutil.CheckBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan getParallelResult, len(p.targets))
+	sched := scheduler.New(jobs, cfg.Network.JobsPerHost)
+	getCount := len(p.targets)
+	// Invoke installing / upgrading tasks (at most 'jobs' tasks run at once,
+	// and at most "network.jobs_per_host" tasks per host)
+	for i := range p.targets {
+		t := &p.targets[i]
+		sched.Go(t.reposPath.Host(), func() {
+			if transaction.Interrupted() {
+				done <- getParallelResult{
+					reposPath: t.reposPath,
+					status:    fmt.Sprintf(fmtInstallFailed, t.reposPath),
+					err:       transaction.ErrInterrupted,
+				}
+				return
+			}
+			cmd.getParallel(t.reposPath, t.repos, cfg, done)
+		})
+	}
+
 	// Wait results
 	failed := false
 	statusList := make([]string, 0, getCount)
+	// statusList when all changes are rolled back
+	rolledBackList := make([]string, 0, getCount)
+	// resumeSteps are saved to resume the transaction if some plugins failed
+	resumeSteps := make([]transaction.ResumeStep, 0, getCount)
+	// succeeded are the results of installed / upgraded plugins
+	succeeded := make([]getParallelResult, 0, getCount)
 	var updatedLockJSON bool
+	var fullBuild bool
 	for i := 0; i < getCount; i++ {
 		r := <-done
 		status := cmd.formatStatus(&r)
@@ -233,42 +673,256 @@ func (cmd *getCmd) doGet(reposPathList []pathutil.ReposPath, lockJSON *lockjson.
 		if strings.HasPrefix(status, statusPrefixFailed) {
 			failed = true
 		} else {
-			added := cmd.updateReposVersion(lockJSON, r.reposPath, r.reposType, r.hash, profile)
+			resumeSteps = append(resumeSteps, transaction.ResumeStep{
+				ReposPath: r.reposPath,
+				Version:   r.hash,
+				Head:      r.head,
+				HeadRef:   r.headRef,
+				Installed: r.installed,
+			})
+			if repos, err := lockJSON.Repos.FindByPath(r.reposPath); err == nil &&
+				strings.Join(repos.SparseCheckout, "\n") != strings.Join(r.sparseCheckout, "\n") {
+				// The files in the worktree were changed without changing version
+				fullBuild = true
+			}
+			added := cmd.updateReposVersion(lockJSON, &r, profile)
 			if added && strings.Contains(status, "already exists") {
-				status = fmt.Sprintf(fmtAddedRepos, r.reposPath)
+				if cmd.noProfile {
+					status = fmt.Sprintf(fmtAddedLockJSON, r.reposPath)
+				} else {
+					status = fmt.Sprintf(fmtAddedRepos, r.reposPath)
+				}
+				r.status = status
 			}
+			succeeded = append(succeeded, r)
 			updatedLockJSON = true
+			// The upstream commit cached by "volt get -check" may be older
+			if err := diskcache.Remove(fetchedCacheNamespace, r.reposPath.String()); err != nil {
+				logger.Debug("Could not remove cached upstream commit: " + err.Error())
+			}
 		}
 		statusList = append(statusList, status)
+		if strings.HasPrefix(status, statusPrefixFailed) || strings.HasPrefix(status, statusPrefixNoChange) {
+			rolledBackList = append(rolledBackList, status)
+		} else {
+			rolledBackList = append(rolledBackList, fmt.Sprintf(fmtRolledBack, r.reposPath))
+		}
 	}
 
-	// Sort by status
-	sort.Strings(statusList)
+	return &getResult{
+		statusList:      statusList,
+		rolledBackList:  rolledBackList,
+		resumeSteps:     resumeSteps,
+		succeeded:       succeeded,
+		failed:          failed,
+		updatedLockJSON: updatedLockJSON,
+		fullBuild:       fullBuild,
+	}, nil
+}
 
-	if updatedLockJSON {
-		// Write to lock.json
-		err = lockJSON.Write()
+// jobsOf returns the number of parallel jobs given by -j option, or get.jobs
+// in config.toml.
+func (cmd *getCmd) jobsOf(cfg *config.Config) int {
+	if cmd.jobs == 0 {
+		return cfg.Get.Jobs
+	}
+	return cmd.jobs
+}
+
+// getPlan is the plan of "volt get".
+type getPlan struct {
+	plan
+	// targets are the repositories to be installed or upgraded
+	targets []getTarget
+}
+
+type getTarget struct {
+	reposPath pathutil.ReposPath
+	// repos is nil if reposPath is not in lock.json
+	repos *lockjson.Repos
+}
+
+// planGet determines the repositories to be installed or upgraded, and the
+// steps for them. Static repositories in lock.json are not processed.
+func (cmd *getCmd) planGet(reposPathList []pathutil.ReposPath, lockJSON *lockjson.LockJSON, profile *lockjson.Profile, cfg *config.Config) *getPlan {
+	p := &getPlan{}
+	changes := 0
+	for _, reposPath := range reposPathList {
+		repos, err := lockJSON.Repos.FindByPath(reposPath)
 		if err != nil {
-			return errors.New("could not write to lock.json: " + err.Error())
+			repos = nil
+		}
+		if repos != nil && repos.Type != lockjson.ReposGitType {
+			continue
 		}
+		p.targets = append(p.targets, getTarget{reposPath: reposPath, repos: repos})
+
+		fullReposPath := relVoltPath(reposPath.FullPath())
+		ref := cmd.refs[reposPath]
+		switch {
+		case !pathutil.Exists(reposPath.FullPath()):
+			at := "default branch"
+			if ref != "" {
+				at = ref
+			}
+			step := fmt.Sprintf("clone %s at %s into %s", reposPath.CloneURL(), at, fullReposPath)
+			if filter := cmd.cloneFilterOf(repos, cfg); filter != "" {
+				step += " (filter: " + filter + ")"
+			}
+			p.add("%s", step)
+		case cmd.reinstall && cmd.isGitRepos(reposPath):
+			step := fmt.Sprintf("clone %s again into %s", reposPath.CloneURL(), fullReposPath)
+			if filter := cmd.cloneFilterOf(repos, cfg); filter != "" {
+				step += " (filter: " + filter + ")"
+			}
+			p.add("%s", step)
+			switch {
+			case ref != "":
+				p.add("check out %s in %s", ref, fullReposPath)
+			case repos != nil && !cmd.upgrade:
+				p.add("check out %s in %s", repos.Version, fullReposPath)
+			}
+		case cmd.upgrade:
+			p.add("upgrade %s to the upstream commit", fullReposPath)
+			if ref != "" {
+				p.add("check out %s in %s", ref, fullReposPath)
+			}
+		case ref != "":
+			p.add("check out %s in %s", ref, fullReposPath)
+		}
+		if cmd.include != nil || cmd.exclude != nil {
+			p.add("apply sparse checkout to %s", fullReposPath)
+		}
+		if *cfg.Get.CreateSkeletonPlugconf && !pathutil.Exists(reposPath.Plugconf()) {
+			p.add("create plugconf %s", relVoltPath(reposPath.Plugconf()))
+		}
+
+		// The entry of the repository is added or updated
+		changes++
+		if !profile.ReposPath.Contains(reposPath) {
+			changes++
+		}
+	}
+	if len(p.targets) > 0 {
+		p.addWriteLockJSON(changes)
+		p.addBuild()
 	}
+	return p
+}
 
-	// Build ~/.vim/pack/volt dir
-	err = builder.Build(false)
+// doCheck fetches the upstream commits of reposPathList, and shows the
+// repositories which can be upgraded.
+func (cmd *getCmd) doCheck(reposPathList []pathutil.ReposPath, lockJSON *lockjson.LockJSON) error {
+	// Begin transaction
+	err := transaction.Create()
 	if err != nil {
-		return errors.New("could not build " + pathutil.VimVoltDir() + ": " + err.Error())
+		return err
 	}
+	defer transaction.Remove()
+
+	// Read config.toml
+	cfg, err := config.Read()
+	if err != nil {
+		return errors.New("could not read config.toml: " + err.Error())
+	}
+
+	err = gitutil.CheckBackend(cfg)
+	if err != nil {
+		return err
+	}
+
+	jobs := cmd.jobs
+	if jobs == 0 {
+		jobs = cfg.Get.Jobs
+	}
+
+	done := make(chan getParallelResult, len(reposPathList))
+	sched := scheduler.New(jobs, cfg.Network.JobsPerHost)
+	checkCount := 0
+	for _, reposPath := range reposPathList {
+		repos, err := lockJSON.Repos.FindByPath(reposPath)
+		if err == nil && repos.Type != lockjson.ReposGitType {
+			continue
+		}
+		reposPath, installed := reposPath, err == nil
+		sched.Go(reposPath.Host(), func() {
+			done <- cmd.checkUpgrade(reposPath, installed, cfg)
+		})
+		checkCount++
+	}
+
+	var upgradable, unchanged, failed int
+	statusList := make([]string, 0, checkCount)
+	for i := 0; i < checkCount; i++ {
+		r := <-done
+		status := cmd.formatStatus(&r)
+		switch {
+		case strings.HasPrefix(status, statusPrefixFailed):
+			failed++
+		case strings.HasPrefix(status, statusPrefixNoChange):
+			unchanged++
+		default:
+			upgradable++
+		}
+		statusList = append(statusList, status)
+	}
+	sort.Strings(statusList)
 
-	// Show results
 	for i := range statusList {
 		fmt.Println(statusList[i])
 	}
-	if failed {
-		return errors.New("failed to install some plugins")
+	if len(statusList) > 1 {
+		fmt.Printf("%sDone: %d can be upgraded, %d unchanged, %d failed\n", logger.GlyphDone, upgradable, unchanged, failed)
+	}
+	if failed > 0 {
+		return &partialFailureError{msg: "failed to check some plugins"}
 	}
 	return nil
 }
 
+// fetchedCacheNamespace is the namespace of diskcache which has the upstream
+// commits fetched by "volt get -check".
+const fetchedCacheNamespace = "fetched"
+
+func (*getCmd) checkUpgrade(reposPath pathutil.ReposPath, installed bool, cfg *config.Config) getParallelResult {
+	if !installed || !pathutil.Exists(reposPath.FullPath()) {
+		return getParallelResult{
+			reposPath: reposPath,
+			status:    fmt.Sprintf(fmtCheckFailed, reposPath),
+			err:       errors.New("repository is not installed"),
+		}
+	}
+	// The upstream commit fetched within gitutil.FetchHeadTTL is used without
+	// accessing the remote
+	var fetched gitutil.FetchResult
+	if diskcache.Get(fetchedCacheNamespace, reposPath.String(), gitutil.FetchHeadTTL, &fetched.Remote) {
+		logger.Debug("Using fetched upstream commit of " + reposPath)
+		fetched.Local, _ = gitutil.GetHEAD(reposPath)
+	}
+	if fetched.Local == "" {
+		logger.Debug("Fetching " + reposPath + " ...")
+		stop := timing.Start(timing.Network)
+		result, err := gitutil.Fetch(reposPath, cfg)
+		stop()
+		if err != nil {
+			return getParallelResult{
+				reposPath: reposPath,
+				status:    fmt.Sprintf(fmtCheckFailed, reposPath),
+				err:       errors.New("failed to fetch: " + err.Error()),
+			}
+		}
+		fetched = *result
+		if err = diskcache.Put(fetchedCacheNamespace, reposPath.String(), fetched.Remote); err != nil {
+			logger.Debug("Could not cache fetched upstream commit: " + err.Error())
+		}
+	}
+	status := fmt.Sprintf(fmtNoChange, reposPath)
+	if fetched.Local != fetched.Remote {
+		status = fmt.Sprintf(fmtUpgradable, reposPath, fetched.Local, fetched.Remote)
+	}
+	return getParallelResult{reposPath: reposPath, status: status}
+}
+
 func (*getCmd) formatStatus(r *getParallelResult) string {
 	if r.err == nil {
 		return r.status
@@ -288,29 +942,65 @@ func (*getCmd) formatStatus(r *getParallelResult) string {
 	return string(buf)
 }
 
+// formatSummary returns the numbers of each kind of results.
+func (*getCmd) formatSummary(statusList []string) string {
+	var changed, unchanged, failed int
+	for _, status := range statusList {
+		switch {
+		case strings.HasPrefix(status, statusPrefixFailed):
+			failed++
+		case strings.HasPrefix(status, statusPrefixNoChange):
+			unchanged++
+		default:
+			changed++
+		}
+	}
+	return fmt.Sprintf("%sDone: %d changed, %d unchanged, %d failed", logger.GlyphDone, changed, unchanged, failed)
+}
+
 type getParallelResult struct {
-	reposPath pathutil.ReposPath
-	status    string
-	hash      string
-	reposType lockjson.ReposType
+	reposPath         pathutil.ReposPath
+	status            string
+	hash              string
+	head              string
+	headRef           string
+	reposType         lockjson.ReposType
+	submodules        string
+	submoduleVersions map[string]string
+	sparseCheckout    []string
+	cloneFilter       string
+	// installed is true if the repository was installed
+	installed bool
 	err       error
 }
 
 const (
-	statusPrefixFailed = "!"
+	statusPrefixFailed   = "!"
+	statusPrefixNoChange = "#"
 	// Failed
 	fmtInstallFailed = "! %s > install failed"
 	fmtUpgradeFailed = "! %s > upgrade failed"
+	fmtCheckFailed   = "! %s > check failed"
+	fmtHookFailed    = "! %s > build hook failed"
+	fmtRpluginFailed = "! %s > remote plugin registration failed"
 	// No change
 	fmtNoChange      = "# %s > no change"
 	fmtAlreadyExists = "# %s > already exists"
+	fmtPinned        = "# %s > pinned to %s (not upgraded)"
+	fmtKeptLocal     = "# %s > kept current commit (upstream history was rewritten to %s)"
+	fmtRolledBack    = "# %s > rolled back (other plugins failed)"
 	// Installed
-	fmtAddedRepos = "+ %s > added repository to current profile"
-	fmtInstalled  = "+ %s > installed"
+	fmtAddedRepos    = "+ %s > added repository to current profile"
+	fmtAddedLockJSON = "+ %s > added repository to lock.json"
+	fmtInstalled     = "+ %s > installed"
+	fmtReinstalled   = "+ %s > reinstalled"
+	fmtReinstalledAt = "+ %s > reinstalled (%s..%s)"
 	// Upgraded
-	fmtRevUpdate = "* %s > updated lock.json revision (%s..%s)"
-	fmtUpgraded  = "* %s > upgraded (%s..%s)"
-	fmtFetched   = "* %s > fetched objects (worktree is not updated)"
+	fmtRevUpdate     = "* %s > updated lock.json revision (%s..%s)"
+	fmtUpgraded      = "* %s > upgraded (%s..%s)"
+	fmtForceUpgraded = "* %s > reset to rewritten upstream history (%s..%s)"
+	fmtFetched       = "* %s > fetched objects (worktree is not updated)"
+	fmtUpgradable    = "* %s > can be upgraded (%s..%s)"
 )
 
 // This function is executed in goroutine of each plugin.
@@ -325,15 +1015,17 @@ func (cmd *getCmd) getParallel(reposPath pathutil.ReposPath, repos *lockjson.Rep
 		return
 	}
 	plugconfDone := make(chan getParallelResult)
-	go cmd.installPlugconf(reposPath, &pluginResult, plugconfDone)
+	go cmd.installPlugconf(reposPath, *cfg.Get.PlugconfTemplateURL, &pluginResult, plugconfDone)
 	done <- (<-plugconfDone)
 }
 
 func (cmd *getCmd) installPlugin(reposPath pathutil.ReposPath, repos *lockjson.Repos, cfg *config.Config, done chan<- getParallelResult) {
 	// true:upgrade, false:install
 	fullReposPath := reposPath.FullPath()
-	doUpgrade := cmd.upgrade && pathutil.Exists(fullReposPath)
-	doInstall := !pathutil.Exists(fullReposPath)
+	// Static repositories are not removed by -reinstall
+	doReinstall := cmd.reinstall && cmd.isGitRepos(reposPath)
+	doUpgrade := cmd.upgrade && pathutil.Exists(fullReposPath) && !doReinstall
+	doInstall := !pathutil.Exists(fullReposPath) || doReinstall
 
 	var fromHash string
 	var err error
@@ -353,7 +1045,21 @@ func (cmd *getCmd) installPlugin(reposPath pathutil.ReposPath, repos *lockjson.R
 
 	var status string
 	var upgraded bool
+	var forceUpgraded bool
 	var checkRevision bool
+	var cloneFilter string
+	if repos != nil {
+		cloneFilter = repos.CloneFilter
+	}
+
+	// The state of HEAD before upgrading or checking out {ref} (used for rollback)
+	var fromHead, fromHeadRef string
+	if !doInstall {
+		fromHead, fromHeadRef, _ = gitutil.GetHEADState(reposPath)
+	}
+	if doReinstall {
+		fromHash, _ = gitutil.GetHEAD(reposPath)
+	}
 
 	if doUpgrade {
 		// when cmd.upgrade is true, repos must not be nil.
@@ -366,8 +1072,37 @@ func (cmd *getCmd) installPlugin(reposPath pathutil.ReposPath, repos *lockjson.R
 			return
 		}
 		// Upgrade plugin
-		logger.Debug("Upgrading " + reposPath + " ...")
-		err := cmd.upgradePlugin(reposPath, cfg)
+		// The repository whose version is pinned by "{repository}@{ref}"
+		// (detached HEAD) is not upgraded unless {ref} is given again.
+		var err error
+		if pinned, _ := gitutil.IsDetachedHEAD(reposPath); pinned {
+			logger.Debug("Skip upgrading pinned " + reposPath)
+			err = git.NoErrAlreadyUpToDate
+		} else {
+			logger.Debug("Upgrading " + reposPath + " ...")
+			if err := transaction.Upgrade(reposPath, fromHash, fromHead, fromHeadRef); err != nil {
+				done <- getParallelResult{
+					reposPath: reposPath,
+					status:    fmt.Sprintf(fmtUpgradeFailed, reposPath),
+					err:       errors.New("failed to write transaction journal: " + err.Error()),
+				}
+				return
+			}
+			err = cmd.upgradeOrResume(reposPath, fromHash, cfg)
+		}
+		if rewritten, ok := err.(*gitutil.HistoryRewrittenError); ok {
+			logger.Debug(reposPath.String() + ": " + rewritten.Error())
+			reset, resetErr := cmd.handleRewrittenHistory(reposPath, rewritten, cfg)
+			if resetErr != nil {
+				err = errors.New(rewritten.Error() + ": could not reset to upstream commit: " + resetErr.Error())
+			} else if reset {
+				err = nil
+				forceUpgraded = true
+			} else {
+				err = nil
+				status = fmt.Sprintf(fmtKeptLocal, reposPath, rewritten.Remote)
+			}
+		}
 		if err != git.NoErrAlreadyUpToDate && err != nil {
 			result := errors.New("failed to upgrade plugin: " + err.Error())
 			done <- getParallelResult{
@@ -377,15 +1112,36 @@ func (cmd *getCmd) installPlugin(reposPath pathutil.ReposPath, repos *lockjson.R
 			}
 			return
 		}
-		if err == git.NoErrAlreadyUpToDate {
+		if pinned, _ := gitutil.IsDetachedHEAD(reposPath); pinned && cmd.refs[reposPath] == "" {
+			status = fmt.Sprintf(fmtPinned, reposPath, fromHash)
+		} else if err == git.NoErrAlreadyUpToDate {
 			status = fmt.Sprintf(fmtNoChange, reposPath)
-		} else {
+		} else if status == "" {
 			upgraded = true
 		}
 	} else if doInstall {
 		// Install plugin
 		logger.Debug("Installing " + reposPath + " ...")
-		err := cmd.clonePlugin(reposPath, cfg)
+		cloneFilter = cmd.cloneFilterOf(repos, cfg)
+		var err error
+		if doReinstall {
+			err = cmd.reinstallPlugin(reposPath, cloneFilter, cfg)
+			if err != nil {
+				// The existing clone is kept, or restored by rollback
+				done <- getParallelResult{
+					reposPath: reposPath,
+					status:    fmt.Sprintf(fmtInstallFailed, reposPath),
+					err:       errors.New("failed to reinstall plugin: " + err.Error()),
+				}
+				return
+			}
+		} else if step := cmd.resume.Step(reposPath); step != nil && step.Stash != "" {
+			// Restore the clone of the failed transaction instead of cloning
+			logger.Debug("Restoring " + reposPath + " installed by the failed transaction ...")
+			err = step.Restore()
+		} else {
+			err = cmd.clonePlugin(reposPath, cloneFilter, cfg)
+		}
 		if err != nil {
 			result := errors.New("failed to install plugin: " + err.Error())
 			logger.Debug("Rollbacking " + fullReposPath + " ...")
@@ -401,6 +1157,9 @@ func (cmd *getCmd) installPlugin(reposPath pathutil.ReposPath, repos *lockjson.R
 			return
 		}
 		status = fmt.Sprintf(fmtInstalled, reposPath)
+		if doReinstall {
+			status = fmt.Sprintf(fmtReinstalled, reposPath)
+		}
 	} else {
 		status = fmt.Sprintf(fmtAlreadyExists, reposPath)
 		checkRevision = true
@@ -413,7 +1172,12 @@ func (cmd *getCmd) installPlugin(reposPath pathutil.ReposPath, repos *lockjson.R
 		toHash, err = gitutil.GetHEAD(reposPath)
 		if err != nil {
 			result := errors.New("failed to get HEAD commit hash: " + err.Error())
-			if doInstall {
+			if doReinstall {
+				logger.Debug("Restoring the existing clone of " + reposPath + " ...")
+				if err = transaction.Revert(fullReposPath); err != nil {
+					result = multierror.Append(result, err)
+				}
+			} else if doInstall {
 				logger.Debug("Rollbacking " + fullReposPath + " ...")
 				err = cmd.removeDir(fullReposPath)
 				if err != nil {
@@ -429,8 +1193,237 @@ func (cmd *getCmd) installPlugin(reposPath pathutil.ReposPath, repos *lockjson.R
 		}
 	}
 
+	// Check out the locked version of the reinstalled repository
+	if doReinstall && !cmd.upgrade && cmd.refs[reposPath] == "" && repos != nil && repos.Version != toHash {
+		logger.Debug("Checking out " + reposPath.String() + " to " + repos.Version + " ...")
+		stop := timing.Start(timing.Checkout)
+		err := gitutil.CheckoutVersion(reposPath, repos.Version, repos.Head, repos.HeadRef, cfg)
+		stop()
+		if err == nil {
+			toHash, err = gitutil.GetHEAD(reposPath)
+		}
+		if err != nil {
+			// Restore the existing clone instead of removing the plugin
+			var result error = errors.New("failed to check out " + repos.Version + ": " + err.Error())
+			logger.Debug("Restoring the existing clone of " + reposPath + " ...")
+			if err = transaction.Revert(fullReposPath); err != nil {
+				result = multierror.Append(result, err)
+			}
+			done <- getParallelResult{
+				reposPath: reposPath,
+				status:    fmt.Sprintf(fmtInstallFailed, reposPath),
+				err:       result,
+			}
+			return
+		}
+	}
+	if doReinstall && fromHash != "" && fromHash != toHash {
+		status = fmt.Sprintf(fmtReinstalledAt, reposPath, fromHash, toHash)
+	}
+
+	// Check out {ref} of "{repository}@{ref}"
+	checkedOut := false
+	if ref := cmd.refs[reposPath]; ref != "" {
+		if !doInstall && fromHash == "" && reposType == lockjson.ReposGitType {
+			fromHash = toHash
+		}
+		var err error
+		if reposType != lockjson.ReposGitType {
+			err = errors.New("static repository does not have refs")
+		} else if !doInstall && !doUpgrade {
+			err = transaction.Upgrade(reposPath, fromHash, fromHead, fromHeadRef)
+		}
+		if err == nil {
+			stop := timing.Start(timing.Checkout)
+			if v := cmd.versions[reposPath]; v != nil {
+				err = gitutil.CheckoutVersion(reposPath, v.Version, v.Head, v.HeadRef, cfg)
+			} else {
+				err = gitutil.Checkout(reposPath, ref, cfg)
+			}
+			stop()
+		}
+		if err == nil {
+			toHash, err = gitutil.GetHEAD(reposPath)
+		}
+		if err != nil {
+			var result error = errors.New("failed to check out " + ref + ": " + err.Error())
+			failedStatus := fmt.Sprintf(fmtUpgradeFailed, reposPath)
+			if doReinstall {
+				failedStatus = fmt.Sprintf(fmtInstallFailed, reposPath)
+				logger.Debug("Restoring the existing clone of " + reposPath + " ...")
+				if err = transaction.Revert(fullReposPath); err != nil {
+					result = multierror.Append(result, err)
+				}
+			} else if doInstall {
+				failedStatus = fmt.Sprintf(fmtInstallFailed, reposPath)
+				logger.Debug("Rollbacking " + fullReposPath + " ...")
+				if err = cmd.removeDir(fullReposPath); err != nil {
+					result = multierror.Append(result, err)
+				}
+			}
+			done <- getParallelResult{
+				reposPath: reposPath,
+				status:    failedStatus,
+				err:       result,
+			}
+			return
+		}
+		checkedOut = true
+		if !doInstall && fromHash != toHash {
+			status = fmt.Sprintf(fmtUpgraded, reposPath, fromHash, toHash)
+			checkRevision = false
+		}
+	}
+
+	submodules := cmd.submodules
+	if submodules == "" && repos != nil {
+		submodules = repos.Submodules
+	}
+
+	// Verify signature of installed / upgraded commit
+	if reposType == lockjson.ReposGitType && *cfg.Git.VerifySignatures && (doInstall || upgraded || checkedOut) {
+		if err := gitutil.VerifyHEAD(reposPath); err != nil {
+			var result error = errors.New("signature verification failed: " + err.Error())
+			failedStatus := fmt.Sprintf(fmtInstallFailed, reposPath)
+			if doReinstall {
+				logger.Debug("Restoring the existing clone of " + reposPath + " ...")
+				if err = transaction.Revert(fullReposPath); err != nil {
+					result = multierror.Append(result, err)
+				}
+			} else if doInstall {
+				logger.Debug("Rollbacking " + fullReposPath + " ...")
+				if err = cmd.removeDir(fullReposPath); err != nil {
+					result = multierror.Append(result, err)
+				}
+			} else {
+				failedStatus = fmt.Sprintf(fmtUpgradeFailed, reposPath)
+				logger.Debug("Rollbacking " + fullReposPath + " to " + fromHash + " ...")
+				stop := timing.Start(timing.Checkout)
+				err = gitutil.CheckoutVersion(reposPath, fromHash, fromHead, fromHeadRef, cfg)
+				if err == nil {
+					err = gitutil.UpdateSubmodules(reposPath, submodules, cfg)
+				}
+				stop()
+				if err != nil {
+					result = multierror.Append(result, err)
+				}
+			}
+			done <- getParallelResult{
+				reposPath: reposPath,
+				status:    failedStatus,
+				err:       result,
+			}
+			return
+		}
+	}
+
+	// Apply sparse checkout patterns
+	var sparseCheckout []string
+	if repos != nil {
+		sparseCheckout = repos.SparseCheckout
+	}
+	if cmd.include != nil || cmd.exclude != nil {
+		sparseCheckout = cmd.sparseCheckoutPatterns()
+	}
+	if reposType == lockjson.ReposGitType {
+		if err := gitutil.SetSparseCheckout(reposPath, sparseCheckout); err != nil {
+			var result error = errors.New("failed to apply sparse checkout: " + err.Error())
+			failedStatus := fmt.Sprintf(fmtUpgradeFailed, reposPath)
+			if doReinstall {
+				failedStatus = fmt.Sprintf(fmtInstallFailed, reposPath)
+				logger.Debug("Restoring the existing clone of " + reposPath + " ...")
+				if err = transaction.Revert(fullReposPath); err != nil {
+					result = multierror.Append(result, err)
+				}
+			} else if doInstall {
+				failedStatus = fmt.Sprintf(fmtInstallFailed, reposPath)
+				logger.Debug("Rollbacking " + fullReposPath + " ...")
+				if err = cmd.removeDir(fullReposPath); err != nil {
+					result = multierror.Append(result, err)
+				}
+			}
+			done <- getParallelResult{
+				reposPath: reposPath,
+				status:    failedStatus,
+				err:       result,
+			}
+			return
+		}
+	}
+
+	// Update submodules
+	var submoduleVersions map[string]string
+	if reposType == lockjson.ReposGitType {
+		stop := timing.Start(timing.Network)
+		err := gitutil.UpdateSubmodules(reposPath, submodules, cfg)
+		stop()
+		if err == nil {
+			submoduleVersions, err = gitutil.SubmoduleVersions(reposPath, submodules, cfg)
+		}
+		if err != nil {
+			var result error = errors.New("failed to update submodules: " + err.Error())
+			failedStatus := fmt.Sprintf(fmtUpgradeFailed, reposPath)
+			if doReinstall {
+				failedStatus = fmt.Sprintf(fmtInstallFailed, reposPath)
+				logger.Debug("Restoring the existing clone of " + reposPath + " ...")
+				if err = transaction.Revert(fullReposPath); err != nil {
+					result = multierror.Append(result, err)
+				}
+			} else if doInstall {
+				failedStatus = fmt.Sprintf(fmtInstallFailed, reposPath)
+				logger.Debug("Rollbacking " + fullReposPath + " ...")
+				if err = cmd.removeDir(fullReposPath); err != nil {
+					result = multierror.Append(result, err)
+				}
+			}
+			done <- getParallelResult{
+				reposPath: reposPath,
+				status:    failedStatus,
+				err:       result,
+			}
+			return
+		}
+	}
+
+	// Download files stored by Git LFS
+	if reposType == lockjson.ReposGitType {
+		stop := timing.Start(timing.Network)
+		placeholders, err := gitutil.PullLFS(reposPath, cfg)
+		stop()
+		if err != nil {
+			var result error = errors.New("failed to pull Git LFS files: " + err.Error())
+			failedStatus := fmt.Sprintf(fmtUpgradeFailed, reposPath)
+			if doReinstall {
+				failedStatus = fmt.Sprintf(fmtInstallFailed, reposPath)
+				logger.Debug("Restoring the existing clone of " + reposPath + " ...")
+				if err = transaction.Revert(fullReposPath); err != nil {
+					result = multierror.Append(result, err)
+				}
+			} else if doInstall {
+				failedStatus = fmt.Sprintf(fmtInstallFailed, reposPath)
+				logger.Debug("Rollbacking " + fullReposPath + " ...")
+				if err = cmd.removeDir(fullReposPath); err != nil {
+					result = multierror.Append(result, err)
+				}
+			}
+			done <- getParallelResult{
+				reposPath: reposPath,
+				status:    failedStatus,
+				err:       result,
+			}
+			return
+		}
+		if len(placeholders) > 0 {
+			logger.Warnf("%s uses Git LFS, but git-lfs is not installed. "+
+				"The following files are placeholders (install git-lfs and run \"volt get -u %s\"):\n  %s",
+				reposPath, reposPath, strings.Join(placeholders, "\n  "))
+		}
+	}
+
 	if upgraded {
-		if fromHash != toHash {
+		if forceUpgraded {
+			status = fmt.Sprintf(fmtForceUpgraded, reposPath, fromHash, toHash)
+		} else if fromHash != toHash {
 			status = fmt.Sprintf(fmtUpgraded, reposPath, fromHash, toHash)
 		} else {
 			status = fmt.Sprintf(fmtFetched, reposPath)
@@ -441,18 +1434,75 @@ func (cmd *getCmd) installPlugin(reposPath pathutil.ReposPath, repos *lockjson.R
 		status = fmt.Sprintf(fmtRevUpdate, reposPath, repos.Version, toHash)
 	}
 
+	// Record the state of HEAD
+	var head, headRef string
+	if reposType == lockjson.ReposGitType {
+		head, headRef, err = gitutil.GetHEADState(reposPath)
+		if err != nil {
+			logger.Debug("could not get the state of HEAD of " + reposPath.String() + ": " + err.Error())
+		}
+	}
+
+	if doInstall {
+		transaction.RepoInstalled(reposPath)
+	}
 	done <- getParallelResult{
-		reposPath: reposPath,
-		status:    status,
-		reposType: reposType,
-		hash:      toHash,
+		reposPath:         reposPath,
+		status:            status,
+		reposType:         reposType,
+		hash:              toHash,
+		head:              head,
+		headRef:           headRef,
+		submodules:        submodules,
+		submoduleVersions: submoduleVersions,
+		sparseCheckout:    sparseCheckout,
+		cloneFilter:       cloneFilter,
+		installed:         doInstall,
 	}
 }
 
-func (cmd *getCmd) installPlugconf(reposPath pathutil.ReposPath, pluginResult *getParallelResult, done chan<- getParallelResult) {
+// sparseCheckoutPatterns converts -include and -exclude paths to sparse
+// checkout patterns. If all files are checked out, it returns nil.
+func (cmd *getCmd) sparseCheckoutPatterns() []string {
+	toPattern := func(path string) string {
+		return "/" + strings.Trim(filepath.ToSlash(path), "/")
+	}
+	patterns := make([]string, 0, len(cmd.include)+len(cmd.exclude)+1)
+	if len(cmd.include) == 0 {
+		patterns = append(patterns, "/*")
+	}
+	for _, path := range cmd.include {
+		if p := toPattern(path); p == "/" {
+			patterns = append(patterns, "/*")
+		} else {
+			patterns = append(patterns, p)
+		}
+	}
+	for _, path := range cmd.exclude {
+		patterns = append(patterns, "!"+toPattern(path))
+	}
+	if len(patterns) == 1 && patterns[0] == "/*" {
+		return nil
+	}
+	return patterns
+}
+
+// pathListFlag is a flag which can be given multiple times.
+type pathListFlag []string
+
+func (f *pathListFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *pathListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func (cmd *getCmd) installPlugconf(reposPath pathutil.ReposPath, templateURL string, pluginResult *getParallelResult, done chan<- getParallelResult) {
 	// Install plugconf
 	logger.Debug("Installing plugconf " + reposPath + " ...")
-	err := cmd.downloadPlugconf(reposPath)
+	err := cmd.downloadPlugconf(reposPath, templateURL)
 	if err != nil {
 		result := errors.New("failed to install plugconf: " + err.Error())
 		// TODO: Call cmd.removeDir() only when the repos *did not* exist previously
@@ -496,66 +1546,193 @@ func (*getCmd) removeDir(fullReposPath string) error {
 }
 
 func (cmd *getCmd) upgradePlugin(reposPath pathutil.ReposPath, cfg *config.Config) error {
-	fullpath := reposPath.FullPath()
+	defer timing.Start(timing.Network)()
+	return gitutil.Update(reposPath, cfg)
+}
 
-	repos, err := git.PlainOpen(fullpath)
+// upgradeOrResume checks out the version upgraded by the failed transaction
+// without fetching (see "volt resume"), or upgrades the repository.
+// fromHash is the current version of the repository.
+func (cmd *getCmd) upgradeOrResume(reposPath pathutil.ReposPath, fromHash string, cfg *config.Config) error {
+	step := cmd.resume.Step(reposPath)
+	if step == nil || step.Version == "" {
+		return cmd.upgradePlugin(reposPath, cfg)
+	}
+	if step.Version == fromHash {
+		return git.NoErrAlreadyUpToDate
+	}
+	logger.Debug("Checking out " + reposPath.String() + " to " + step.Version + " upgraded by the failed transaction ...")
+	stop := timing.Start(timing.Checkout)
+	err := gitutil.CheckoutVersion(reposPath, step.Version, step.Head, step.HeadRef, cfg)
+	stop()
 	if err != nil {
-		return err
+		logger.Debug("Could not check out: " + err.Error())
+		return cmd.upgradePlugin(reposPath, cfg)
 	}
+	return nil
+}
 
-	reposCfg, err := repos.Config()
-	if err != nil {
-		return err
+// handleRewrittenHistory keeps the current commit, or resets to the upstream
+// commit according to "on_force_push" in [get] section of config.toml.
+// It returns true if the repository was reset.
+func (cmd *getCmd) handleRewrittenHistory(reposPath pathutil.ReposPath, rewritten *gitutil.HistoryRewrittenError, cfg *config.Config) (bool, error) {
+	action := cfg.Get.OnForcePush
+	if action == config.ForcePushAsk {
+		action = cmd.askRewrittenHistory(reposPath, rewritten)
+	}
+	if action != config.ForcePushReset {
+		return false, nil
 	}
+	logger.Debug("Resetting " + reposPath.String() + " to " + rewritten.Remote + " ...")
+	defer timing.Start(timing.Checkout)()
+	return true, gitutil.ResetToVersion(reposPath, rewritten.Remote, cfg)
+}
 
-	remote, err := gitutil.GetUpstreamRemote(repos)
-	if err != nil {
-		return err
+// askRewrittenHistory asks whether the current commit is kept or not,
+// and returns config.ForcePushKeep or config.ForcePushReset.
+// If stdin is not a terminal, config.ForcePushKeep is returned.
+func (cmd *getCmd) askRewrittenHistory(reposPath pathutil.ReposPath, rewritten *gitutil.HistoryRewrittenError) string {
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return config.ForcePushKeep
 	}
 
-	if reposCfg.Core.IsBare {
-		return cmd.gitFetch(repos, fullpath, remote, cfg)
+	// Repositories are processed in parallel, but ask one by one
+	cmd.promptMutex.Lock()
+	defer cmd.promptMutex.Unlock()
+	if cmd.stdin == nil {
+		cmd.stdin = bufio.NewReader(os.Stdin)
+	}
+
+	fmt.Printf("%s: upstream history was rewritten (e.g. force-pushed).\n", reposPath)
+	fmt.Printf("  current commit:  %s\n", rewritten.Local)
+	fmt.Printf("  upstream commit: %s\n", rewritten.Remote)
+	for {
+		fmt.Print("[k]eep current commit, or [r]eset to upstream commit (local changes are discarded)? [k/r]: ")
+		line, err := cmd.stdin.ReadString('\n')
+		switch strings.TrimSpace(line) {
+		case "k":
+			return config.ForcePushKeep
+		case "r":
+			return config.ForcePushReset
+		}
+		if err != nil {
+			fmt.Println()
+			return config.ForcePushKeep
+		}
 	}
-	return cmd.gitPull(repos, fullpath, remote, cfg)
 }
 
 var errRepoExists = errors.New("repository exists")
 
-func (cmd *getCmd) clonePlugin(reposPath pathutil.ReposPath, cfg *config.Config) error {
+// cloneFilterOf returns the filter of partial clone by -filter option,
+// lock.json, or config.toml. Empty string means full clone.
+func (cmd *getCmd) cloneFilterOf(repos *lockjson.Repos, cfg *config.Config) string {
+	switch cmd.cloneFilter {
+	case "":
+		if repos != nil && repos.CloneFilter != "" {
+			return repos.CloneFilter
+		}
+		return cfg.Git.CloneFilter
+	case "none":
+		return ""
+	default:
+		return cmd.cloneFilter
+	}
+}
+
+func (cmd *getCmd) clonePlugin(reposPath pathutil.ReposPath, cloneFilter string, cfg *config.Config) error {
 	fullpath := reposPath.FullPath()
 	if pathutil.Exists(fullpath) {
 		return errRepoExists
 	}
 
-	err := os.MkdirAll(filepath.Dir(fullpath), 0755)
+	err := transaction.Install(fullpath)
+	if err != nil {
+		return errors.New("failed to write transaction journal: " + err.Error())
+	}
+
+	err = os.MkdirAll(filepath.Dir(fullpath), 0755)
 	if err != nil {
 		return err
 	}
 
 	// Clone repository to $VOLTPATH/repos/{site}/{user}/{name}
-	return cmd.gitClone(reposPath.CloneURL(), fullpath, cfg)
+	defer timing.Start(timing.Network)()
+	return gitutil.Clone(reposPath.CloneURL(), fullpath, cloneFilter, cfg)
+}
+
+// isGitRepos returns true if reposPath exists and is a git repository.
+func (cmd *getCmd) isGitRepos(reposPath pathutil.ReposPath) bool {
+	if !pathutil.Exists(reposPath.FullPath()) {
+		return false
+	}
+	reposType, err := cmd.detectReposType(reposPath.FullPath())
+	return err == nil && reposType == lockjson.ReposGitType
+}
+
+// reinstallPlugin clones reposPath again, and replaces the existing clone
+// with it. The existing clone is not changed until the clone succeeded, and
+// it is restored if the transaction is rolled back (or reverted by
+// transaction.Revert).
+func (cmd *getCmd) reinstallPlugin(reposPath pathutil.ReposPath, cloneFilter string, cfg *config.Config) error {
+	fullpath := reposPath.FullPath()
+	if err := os.MkdirAll(pathutil.TempDir(), 0755); err != nil {
+		return err
+	}
+	tmpDir, err := ioutil.TempDir(pathutil.TempDir(), "reinstall-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	logger.Debug("Cloning " + reposPath + " again ...")
+	newDir := filepath.Join(tmpDir, "repos")
+	stop := timing.Start(timing.Network)
+	err = gitutil.Clone(reposPath.CloneURL(), newDir, cloneFilter, cfg)
+	stop()
+	if err != nil {
+		return err
+	}
+
+	if err = transaction.RemoveAll(fullpath); err != nil {
+		return errors.New("failed to remove the existing clone: " + err.Error())
+	}
+	if err = transaction.Install(fullpath); err != nil {
+		return errors.New("failed to write transaction journal: " + err.Error())
+	}
+	if err = os.MkdirAll(filepath.Dir(fullpath), 0755); err != nil {
+		return err
+	}
+	return os.Rename(newDir, fullpath)
 }
 
-func (cmd *getCmd) downloadPlugconf(reposPath pathutil.ReposPath) error {
+func (cmd *getCmd) downloadPlugconf(reposPath pathutil.ReposPath, templateURL string) error {
 	path := reposPath.Plugconf()
 	if pathutil.Exists(path) {
 		logger.Debugf("plugconf '%s' exists... skip", path)
 		return nil
 	}
 
-	// If non-nil error returned from FetchPlugconfTemplate(),
-	// create skeleton plugconf file
-	tmpl, err := plugconf.FetchPlugconfTemplate(reposPath)
-	if err != nil {
-		logger.Debug(err.Error())
-		// empty tmpl is returned when err != nil
+	// If template URL is empty or non-nil error returned from
+	// FetchPlugconfTemplate(), create skeleton plugconf file
+	var tmpl *plugconf.Template
+	if templateURL != "" {
+		var err error
+		tmpl, err = plugconf.FetchPlugconfTemplate(reposPath, templateURL)
+		if err != nil {
+			logger.Debug("plugconf template was not fetched: " + err.Error())
+			// nil tmpl is returned when err != nil
+		}
 	}
 	content, merr := tmpl.Generate(path)
 	if merr.ErrorOrNil() != nil {
 		return fmt.Errorf("parse error in fetched plugconf %s: %s", reposPath, merr.Error())
 	}
+	if err := transaction.Install(path); err != nil {
+		return errors.New("failed to write transaction journal: " + err.Error())
+	}
 	os.MkdirAll(filepath.Dir(path), 0755)
-	err = ioutil.WriteFile(path, content, 0644)
+	err := ioutil.WriteFile(path, content, 0644)
 	if err != nil {
 		return err
 	}
@@ -564,8 +1741,8 @@ func (cmd *getCmd) downloadPlugconf(reposPath pathutil.ReposPath) error {
 
 // * Add repos to 'repos' if not found
 // * Add repos to 'profiles[]/repos_path' if not found
-func (*getCmd) updateReposVersion(lockJSON *lockjson.LockJSON, reposPath pathutil.ReposPath, reposType lockjson.ReposType, version string, profile *lockjson.Profile) bool {
-	repos, err := lockJSON.Repos.FindByPath(reposPath)
+func (cmd *getCmd) updateReposVersion(lockJSON *lockjson.LockJSON, r *getParallelResult, profile *lockjson.Profile) bool {
+	repos, err := lockJSON.Repos.FindByPath(r.reposPath)
 	if err != nil {
 		repos = nil
 	}
@@ -575,135 +1752,158 @@ func (*getCmd) updateReposVersion(lockJSON *lockjson.LockJSON, reposPath pathuti
 	if repos == nil {
 		// repos is not found in lock.json
 		// -> previous operation is install
-		repos = &lockjson.Repos{
-			Type:    reposType,
-			Path:    reposPath,
-			Version: version,
-		}
-		// Add repos to 'repos'
-		lockJSON.Repos = append(lockJSON.Repos, *repos)
+		lockJSON.Repos = append(lockJSON.Repos, lockjson.Repos{
+			Type:    r.reposType,
+			Path:    r.reposPath,
+			Version: r.hash,
+		})
+		repos = &lockJSON.Repos[len(lockJSON.Repos)-1]
 		added = true
 	} else {
 		// repos is found in lock.json
 		// -> previous operation is upgrade
-		repos.Version = version
+		repos.Version = r.hash
+	}
+	if r.reposType == lockjson.ReposGitType {
+		repos.Submodules = r.submodules
+		repos.SubmoduleVersions = r.submoduleVersions
+		repos.SparseCheckout = r.sparseCheckout
+		repos.CloneFilter = r.cloneFilter
+		repos.Head = r.head
+		repos.HeadRef = r.headRef
 	}
 
-	if !profile.ReposPath.Contains(reposPath) {
+	if !cmd.noProfile && !profile.ReposPath.Contains(r.reposPath) {
 		// Add repos to 'profiles[]/repos_path'
-		profile.ReposPath = append(profile.ReposPath, reposPath)
+		profile.ReposPath = append(profile.ReposPath, r.reposPath)
 		added = true
 	}
 	return added
 }
 
-func (cmd *getCmd) gitFetch(r *git.Repository, workDir string, remote string, cfg *config.Config) error {
-	err := r.Fetch(&git.FetchOptions{
-		RemoteName: remote,
-	})
-	if err == nil || err == git.NoErrAlreadyUpToDate {
-		return err
-	}
-
-	// When fallback_git_cmd is true and git command is installed,
-	// try to invoke git-fetch command
-	if !*cfg.Get.FallbackGitCmd || !cmd.hasGitCmd() {
-		return err
-	}
-	logger.Warnf("failed to fetch, try to execute \"git fetch %s\" instead...: %s", remote, err.Error())
-
-	before, err := gitutil.GetHEADRepository(r)
-	fetch := exec.Command("git", "fetch", remote)
-	fetch.Dir = workDir
-	err = fetch.Run()
+// runBuildHooks runs build hooks (s:build() in plugconf) of the plugins of
+// results in the repositories (at most jobs hooks run at once), and returns
+// the status of failed hooks, and true if some hooks were run.
+// The hooks which succeeded at the same version are not run again unless the
+// plugins were installed.
+func (cmd *getCmd) runBuildHooks(results []getParallelResult, jobs int) ([]string, bool) {
+	state, err := buildhook.ReadState()
 	if err != nil {
-		return err
-	}
-	if changed, err := cmd.getWorktreeChanges(r, before); err != nil {
-		return err
-	} else if !changed {
-		return git.NoErrAlreadyUpToDate
+		logger.Warn("Could not read the state of build hooks: " + err.Error())
+		state = buildhook.State{}
 	}
-	return nil
-}
 
-func (cmd *getCmd) gitPull(r *git.Repository, workDir string, remote string, cfg *config.Config) error {
-	wt, err := r.Worktree()
-	if err != nil {
-		return err
+	type hookResult struct {
+		index     int
+		reposPath pathutil.ReposPath
+		command   string
+		version   string
+		err       error
 	}
-	err = wt.Pull(&git.PullOptions{
-		RemoteName: remote,
-		// TODO: Temporarily recursive clone is disabled, because go-git does
-		// not support relative submodule url in .gitmodules and it causes an
-		// error
-		RecurseSubmodules: 0,
-	})
-	if err == nil || err == git.NoErrAlreadyUpToDate {
-		return err
+	done := make(chan hookResult, len(results))
+	sched := scheduler.New(jobs, 0)
+	hookCount := 0
+	for i := range results {
+		reposPath := results[i].reposPath
+		version := results[i].hash
+		path := reposPath.Plugconf()
+		if !pathutil.Exists(path) {
+			continue
+		}
+		// Parse errors are reported by builder
+		info, parseErr := plugconf.ParsePlugconfFile(path, 0, reposPath)
+		if parseErr.HasErrs() {
+			continue
+		}
+		command := info.BuildCmd()
+		if command == "" || (!results[i].installed && state.Done(reposPath, command, version)) {
+			continue
+		}
+		index := hookCount
+		hookCount++
+		sched.Go("", func() {
+			logger.Infof("Running build hook of %s ...", reposPath)
+			err := buildhook.Run(reposPath, command)
+			done <- hookResult{index: index, reposPath: reposPath, command: command, version: version, err: err}
+		})
 	}
 
-	// When fallback_git_cmd is true and git command is installed,
-	// try to invoke git-pull command
-	if !*cfg.Get.FallbackGitCmd || !cmd.hasGitCmd() {
-		return err
+	// Merge the results in the order of results, not in the order in which
+	// the hooks finished
+	hookResults := make([]hookResult, hookCount)
+	for i := 0; i < hookCount; i++ {
+		r := <-done
+		hookResults[r.index] = r
 	}
-	logger.Warnf("failed to pull, try to execute \"git pull\" instead...: %s", err.Error())
-
-	before, err := gitutil.GetHEADRepository(r)
-	pull := exec.Command("git", "pull")
-	pull.Dir = workDir
-	err = pull.Run()
-	if err != nil {
-		return err
+	statusList := make([]string, 0, hookCount)
+	for _, r := range hookResults {
+		if r.err != nil {
+			delete(state, r.reposPath)
+			statusList = append(statusList, cmd.formatStatus(&getParallelResult{
+				reposPath: r.reposPath,
+				status:    fmt.Sprintf(fmtHookFailed, r.reposPath),
+				err:       r.err,
+			}))
+			continue
+		}
+		state[r.reposPath] = buildhook.Result{Command: r.command, Version: r.version}
 	}
-	if changed, err := cmd.getWorktreeChanges(r, before); err != nil {
-		return err
-	} else if !changed {
-		return git.NoErrAlreadyUpToDate
+	if hookCount > 0 {
+		if err = state.Write(); err != nil {
+			logger.Warn("Could not write the state of build hooks: " + err.Error())
+		}
 	}
-	return nil
+	return statusList, hookCount > 0
 }
 
-func (cmd *getCmd) getWorktreeChanges(r *git.Repository, before string) (bool, error) {
-	after, err := gitutil.GetHEADRepository(r)
+// updateRemotePlugins runs ":UpdateRemotePlugins" by Neovim if the plugins of
+// results were changed and have remote plugins. It returns failed status
+// list.
+func (cmd *getCmd) updateRemotePlugins(results []getParallelResult, lockJSON *lockjson.LockJSON) []string {
+	changed := make([]pathutil.ReposPath, 0, len(results))
+	for i := range results {
+		if strings.HasPrefix(results[i].status, statusPrefixNoChange) {
+			continue
+		}
+		if buildhook.HasRemotePlugin(results[i].reposPath.FullPath()) {
+			changed = append(changed, results[i].reposPath)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	nvim, err := pathutil.NvimExecutable()
 	if err != nil {
-		return false, err
+		logger.Debug("Skip registering remote plugins: nvim is not found")
+		return nil
 	}
-	return before != after, nil
-}
 
-func (cmd *getCmd) gitClone(cloneURL, dstDir string, cfg *config.Config) error {
-	isBare := false
-	r, err := git.PlainClone(dstDir, isBare, &git.CloneOptions{
-		URL:               cloneURL,
-		RecurseSubmodules: 10,
-	})
+	// All remote plugins in the current profile are registered, because
+	// Neovim regenerates the manifest
+	reposList, err := lockJSON.GetCurrentReposList()
 	if err != nil {
-		// When fallback_git_cmd is true and git command is installed,
-		// try to invoke git-clone command
-		if !*cfg.Get.FallbackGitCmd || !cmd.hasGitCmd() {
-			return err
-		}
-		logger.Warnf("failed to clone, try to execute \"git clone --recursive %s %s\" instead...: %s", cloneURL, dstDir, err.Error())
-		err = os.RemoveAll(dstDir)
-		if err != nil {
-			return err
-		}
-		out, err := exec.Command("git", "clone", "--recursive", cloneURL, dstDir).CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("\"git clone --recursive %s %s\" failed, out=%s: %s", cloneURL, dstDir, string(out), err.Error())
+		logger.Warn("Could not register remote plugins: " + err.Error())
+		return nil
+	}
+	rtpDirs := make([]string, 0, len(reposList))
+	for i := range reposList {
+		dir := reposList[i].Path.EncodeToPlugDirName()
+		if buildhook.HasRemotePlugin(dir) {
+			rtpDirs = append(rtpDirs, dir)
 		}
 	}
 
-	return gitutil.SetUpstreamRemote(r, "origin")
-}
-
-func (cmd *getCmd) hasGitCmd() bool {
-	exeName := "git"
-	if runtime.GOOS == "windows" {
-		exeName = "git.exe"
+	logger.Info("Registering remote plugins ...")
+	if err = buildhook.UpdateRemotePlugins(nvim, rtpDirs); err != nil {
+		statusList := make([]string, 0, len(changed))
+		for _, reposPath := range changed {
+			statusList = append(statusList, cmd.formatStatus(&getParallelResult{
+				reposPath: reposPath,
+				status:    fmt.Sprintf(fmtRpluginFailed, reposPath),
+				err:       err,
+			}))
+		}
+		return statusList
 	}
-	_, err := exec.LookPath(exeName)
-	return err == nil
+	return nil
 }
@@ -0,0 +1,198 @@
+package subcmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vim-volt/volt/httputil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// githubAPIURL is the endpoint of GitHub REST API.
+var githubAPIURL = "https://api.github.com"
+
+// starredPerPage is the number of starred repositories per page of GitHub API
+// (the maximum value).
+const starredPerPage = 100
+
+// starredRepos is a repository in the response of GitHub API
+// "GET /users/{user}/starred".
+type starredRepos struct {
+	FullName    string   `json:"full_name"`
+	Description string   `json:"description"`
+	Language    string   `json:"language"`
+	Topics      []string `json:"topics"`
+	Archived    bool     `json:"archived"`
+}
+
+// fetchStarredRepos returns all repositories starred by user.
+func fetchStarredRepos(user string) ([]starredRepos, error) {
+	var starred []starredRepos
+	for page := 1; ; page++ {
+		u := fmt.Sprintf("%s/users/%s/starred?per_page=%d&page=%d",
+			githubAPIURL, url.PathEscape(user), starredPerPage, page)
+		content, err := httputil.GetContent(u)
+		if err != nil {
+			return nil, err
+		}
+		var list []starredRepos
+		if err = json.Unmarshal(content, &list); err != nil {
+			return nil, errors.New("could not parse the response of " + u + ": " + err.Error())
+		}
+		starred = append(starred, list...)
+		if len(list) < starredPerPage {
+			return starred, nil
+		}
+	}
+}
+
+var (
+	// vimPluginLanguages is the languages (lower-cased) of Vim plugins.
+	vimPluginLanguages = map[string]bool{
+		"vim script":  true,
+		"viml":        true,
+		"vim snippet": true,
+	}
+	// vimPluginTopics is the topics of Vim plugins.
+	vimPluginTopics = map[string]bool{
+		"vim":           true,
+		"vim-plugin":    true,
+		"vim-plugins":   true,
+		"neovim":        true,
+		"neovim-plugin": true,
+		"nvim":          true,
+		"nvim-plugin":   true,
+		"colorscheme":   true,
+	}
+	// rxVimPluginName matches to the names of Vim plugins (e.g. "vim-surround",
+	// "caw.vim", "telescope.nvim").
+	rxVimPluginName = regexp.MustCompile(`(?i)\A(n?vim|neovim)-|[.-](n?vim|neovim)\z`)
+)
+
+// isVimPlugin returns true if repos looks like a Vim plugin by its language,
+// topics, or name.
+func isVimPlugin(repos *starredRepos) bool {
+	if vimPluginLanguages[strings.ToLower(repos.Language)] {
+		return true
+	}
+	for _, topic := range repos.Topics {
+		if vimPluginTopics[topic] {
+			return true
+		}
+	}
+	name := repos.FullName[strings.LastIndex(repos.FullName, "/")+1:]
+	return rxVimPluginName.MatchString(name)
+}
+
+// starredPluginCandidates returns the Vim plugins in starred which are not in
+// lockJSON. The order of starred (recently starred first) is kept.
+func starredPluginCandidates(starred []starredRepos, lockJSON *lockjson.LockJSON) ([]pathutil.ReposPath, []*starredRepos) {
+	var reposPathList []pathutil.ReposPath
+	var candidates []*starredRepos
+	for i := range starred {
+		if !isVimPlugin(&starred[i]) {
+			continue
+		}
+		reposPath, err := pathutil.NormalizeRepos(starred[i].FullName)
+		if err != nil || lockJSON.Repos.Contains(reposPath) {
+			continue
+		}
+		reposPathList = append(reposPathList, reposPath)
+		candidates = append(candidates, &starred[i])
+	}
+	return reposPathList, candidates
+}
+
+// selectStarredPlugins shows the Vim plugins starred by user, and returns the
+// plugins selected by user.
+func (cmd *getCmd) selectStarredPlugins(user string, lockJSON *lockjson.LockJSON) ([]pathutil.ReposPath, error) {
+	starred, err := fetchStarredRepos(user)
+	if err != nil {
+		return nil, errors.New("could not get the repositories starred by " + user + ": " + err.Error())
+	}
+	reposPathList, candidates := starredPluginCandidates(starred, lockJSON)
+	if len(candidates) == 0 {
+		fmt.Printf("No Vim plugins which are not installed were found in %d repositories starred by %s\n", len(starred), user)
+		return nil, nil
+	}
+
+	fmt.Printf("Vim plugins starred by %s (%d of %d repositories):\n", user, len(candidates), len(starred))
+	for i, repos := range candidates {
+		line := fmt.Sprintf("%3d) %s", i+1, reposPathList[i])
+		if repos.Archived {
+			line += " (archived)"
+		}
+		if repos.Description != "" {
+			line += " - " + repos.Description
+		}
+		fmt.Println(line)
+	}
+
+	cmd.promptMutex.Lock()
+	defer cmd.promptMutex.Unlock()
+	if cmd.stdin == nil {
+		cmd.stdin = bufio.NewReader(os.Stdin)
+	}
+	for {
+		fmt.Print("Select plugins to install (e.g. \"1 3 5-7\", \"a\" for all, empty to cancel): ")
+		line, readErr := cmd.stdin.ReadString('\n')
+		if readErr != nil {
+			fmt.Println()
+		}
+		indices, err := parseSelection(line, len(candidates))
+		if err == nil {
+			selected := make([]pathutil.ReposPath, 0, len(indices))
+			for _, i := range indices {
+				selected = append(selected, reposPathList[i])
+			}
+			return selected, nil
+		}
+		if readErr != nil {
+			return nil, err
+		}
+		fmt.Println(err.Error())
+	}
+}
+
+// parseSelection parses the numbers (1-origin) and the ranges of numbers
+// separated by spaces or commas (e.g. "1 3 5-7"), or "a" (all), and returns
+// 0-origin indices of n items in ascending order.
+func parseSelection(input string, n int) ([]int, error) {
+	input = strings.TrimSpace(input)
+	selected := make([]bool, n)
+	if input == "a" || input == "all" {
+		for i := range selected {
+			selected[i] = true
+		}
+		input = ""
+	}
+	for _, field := range strings.FieldsFunc(input, func(r rune) bool { return r == ' ' || r == ',' }) {
+		first, last := field, field
+		if i := strings.Index(field, "-"); i > 0 {
+			first, last = field[:i], field[i+1:]
+		}
+		from, err1 := strconv.Atoi(first)
+		to, err2 := strconv.Atoi(last)
+		if err1 != nil || err2 != nil || from < 1 || to > n || from > to {
+			return nil, fmt.Errorf("invalid selection '%s' (must be between 1 and %d)", field, n)
+		}
+		for i := from; i <= to; i++ {
+			selected[i-1] = true
+		}
+	}
+	var indices []int
+	for i := range selected {
+		if selected[i] {
+			indices = append(indices, i)
+		}
+	}
+	return indices, nil
+}
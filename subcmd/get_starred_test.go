@@ -0,0 +1,65 @@
+package subcmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func TestStarredPluginCandidates(t *testing.T) {
+	starred := []starredRepos{
+		{FullName: "tyru/caw.vim", Language: "Vim Script"},
+		{FullName: "golang/go", Language: "Go"},
+		{FullName: "nvim-telescope/telescope.nvim", Language: "Lua"},
+		{FullName: "tpope/vim-surround", Language: ""},
+		{FullName: "a/colors", Language: "", Topics: []string{"colorscheme"}},
+		{FullName: "vim-volt/volt", Language: "Go"},
+		{FullName: "a/installed.vim", Language: "Vim Script"},
+	}
+	lockJSON := &lockjson.LockJSON{
+		Repos: lockjson.ReposList{{Type: lockjson.ReposGitType, Path: "github.com/a/installed.vim"}},
+	}
+	reposPathList, candidates := starredPluginCandidates(starred, lockJSON)
+	expected := []pathutil.ReposPath{
+		"github.com/tyru/caw.vim",
+		"github.com/nvim-telescope/telescope.nvim",
+		"github.com/tpope/vim-surround",
+		"github.com/a/colors",
+	}
+	if !reflect.DeepEqual(reposPathList, expected) {
+		t.Errorf("expected %+v but got %+v", expected, reposPathList)
+	}
+	if len(candidates) != len(expected) || candidates[1] != &starred[2] {
+		t.Errorf("unexpected candidates: %+v", candidates)
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	for _, tt := range []struct {
+		input    string
+		expected []int
+		err      bool
+	}{
+		{input: "\n", expected: nil},
+		{input: "a\n", expected: []int{0, 1, 2, 3, 4}},
+		{input: "3 1,2\n", expected: []int{0, 1, 2}},
+		{input: "2-4 4", expected: []int{1, 2, 3}},
+		{input: "0", err: true},
+		{input: "6", err: true},
+		{input: "4-2", err: true},
+		{input: "x", err: true},
+	} {
+		indices, err := parseSelection(tt.input, 5)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: expected error but got %+v", tt.input, indices)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(indices, tt.expected) {
+			t.Errorf("%q: expected %+v but got %+v (%v)", tt.input, tt.expected, indices, err)
+		}
+	}
+}
@@ -2,14 +2,17 @@ package subcmd
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/vim-volt/volt/gitutil"
 	"github.com/vim-volt/volt/internal/testutil"
 	"github.com/vim-volt/volt/lockjson"
 	"github.com/vim-volt/volt/pathutil"
@@ -35,6 +38,9 @@ import (
 // (N) Output contains "* {repos} > updated lock.json revision ({from}..{to})"
 // (O) Output contains "* {repos} > upgraded ({from}..{to})"
 // (P) Output contains "{repos}: HEAD and locked revision are different ..."
+// (Q) Build hook (s:build() in plugconf) is run in `$VOLTPATH/repos/<repos>/`
+// (R) Plugconf is created from template of `get.plugconf_template_url`, or skeleton if it cannot be fetched
+// (S) ":UpdateRemotePlugins" is run by nvim with `~/.vim/pack/volt/opt/<repos>` in 'runtimepath'
 
 // TODO: Add test cases
 // * Specify plugins which have dependency plugins without help (A, B, C, D, E, F, !G) / with help (A, B, C, D, E, F, G)
@@ -403,6 +409,252 @@ func TestVoltGetLoptMustNotAddDisabledPlugins(t *testing.T) {
 	testReposPathWereDisabled(t, pathutil.ReposPath("github.com/tyru/dummy2"))
 }
 
+// Check upgrade of one plugin (A, B, J), and lock.json is not changed
+func TestVoltGetCheck(t *testing.T) {
+	// =============== setup =============== //
+
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("github.com/tyru/dummy")
+	out, err := testutil.RunVolt("get", reposPath.String())
+	testutil.SuccessExit(t, out, err)
+	before, err := ioutil.ReadFile(pathutil.LockJSON())
+	if err != nil {
+		t.Fatal("could not read lock.json: " + err.Error())
+	}
+
+	// =============== run =============== //
+
+	out, err = testutil.RunVolt("get", "-check", reposPath.String())
+	// (A, B)
+	testutil.SuccessExit(t, out, err)
+
+	// (J)
+	msg := fmt.Sprintf(fmtNoChange, reposPath)
+	if !bytes.Contains(out, []byte(msg)) {
+		t.Errorf("Output does not contain %q\n%s", msg, string(out))
+	}
+
+	after, err := ioutil.ReadFile(pathutil.LockJSON())
+	if err != nil {
+		t.Fatal("could not read lock.json: " + err.Error())
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("lock.json was changed:\n%s\n%s", string(before), string(after))
+	}
+}
+
+// Specify one plugin which has build hook (A, B, Q), and specify it again (A, B, !Q)
+// Specify one plugin which has failing build hook (!A, !B)
+func TestVoltGetBuildHook(t *testing.T) {
+	t.Run("Run build hook once", func(t *testing.T) {
+		// =============== setup =============== //
+
+		testutil.SetUpEnv(t)
+		reposPath := pathutil.ReposPath("localhost/local/hook")
+		setUpBuildHook(t, reposPath, "echo built >>hook.out")
+
+		// =============== run =============== //
+
+		for i := 0; i < 2; i++ {
+			out, err := testutil.RunVolt("get", reposPath.String())
+			// (A, B)
+			testutil.SuccessExit(t, out, err)
+		}
+
+		// (Q, !Q)
+		content, err := ioutil.ReadFile(filepath.Join(reposPath.FullPath(), "hook.out"))
+		if err != nil {
+			t.Fatal("build hook was not run: " + err.Error())
+		}
+		if string(content) != "built\n" {
+			t.Errorf("build hook was not run once: %q", string(content))
+		}
+	})
+
+	t.Run("Run failing build hook", func(t *testing.T) {
+		// =============== setup =============== //
+
+		testutil.SetUpEnv(t)
+		reposPath := pathutil.ReposPath("localhost/local/hook")
+		setUpBuildHook(t, reposPath, "exit 3")
+
+		// =============== run =============== //
+
+		out, err := testutil.RunVolt("get", reposPath.String())
+		// (!A, !B)
+		testutil.FailExit(t, out, err)
+		msg := fmt.Sprintf(fmtHookFailed, reposPath)
+		if !bytes.Contains(out, []byte(msg)) {
+			t.Errorf("Output does not contain %q\n%s", msg, string(out))
+		}
+		if !pathutil.Exists(pathutil.BuildHookLog(reposPath)) {
+			t.Error("log file of build hook was not created: " + pathutil.BuildHookLog(reposPath))
+		}
+	})
+}
+
+// setUpBuildHook creates static repository reposPath and its plugconf which
+// has s:build() returning command.
+func setUpBuildHook(t *testing.T, reposPath pathutil.ReposPath, command string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(reposPath.FullPath(), "plugin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(reposPath.Plugconf()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "function! s:build()\n  return '" + command + "'\nendfunction\n"
+	if err := ioutil.WriteFile(reposPath.Plugconf(), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVoltGetPlugconfTemplate(t *testing.T) {
+	t.Run("Install plugconf template in local directory", func(t *testing.T) {
+		// =============== setup =============== //
+
+		testutil.SetUpEnv(t)
+		reposPath := pathutil.ReposPath("localhost/local/tmpl")
+		templateDir := setUpPlugconfTemplate(t, reposPath, "function! s:loaded_on()\n  return 'filetype=go'\nendfunction\n")
+
+		// =============== run =============== //
+
+		out, err := testutil.RunVolt("config", "set", "get.plugconf_template_url", templateDir)
+		testutil.SuccessExit(t, out, err)
+		out, err = testutil.RunVolt("get", reposPath.String())
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+
+		// (D, R)
+		content, err := ioutil.ReadFile(reposPath.Plugconf())
+		if err != nil {
+			t.Fatal("plugconf was not created: " + err.Error())
+		}
+		if !bytes.Contains(content, []byte("return 'filetype=go'")) {
+			t.Errorf("plugconf was not created from template:\n%s", string(content))
+		}
+	})
+
+	t.Run("Install skeleton plugconf when template cannot be fetched", func(t *testing.T) {
+		// =============== setup =============== //
+
+		testutil.SetUpEnv(t)
+		reposPath := pathutil.ReposPath("localhost/local/tmpl")
+		setUpPlugconfTemplate(t, reposPath, "")
+
+		// =============== run =============== //
+
+		out, err := testutil.RunVolt("config", "set", "get.plugconf_template_url", "http://localhost:1/templates")
+		testutil.SuccessExit(t, out, err)
+		out, err = testutil.RunVolt("get", reposPath.String())
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+
+		// (D, R)
+		content, err := ioutil.ReadFile(reposPath.Plugconf())
+		if err != nil {
+			t.Fatal("plugconf was not created: " + err.Error())
+		}
+		if !bytes.Contains(content, []byte("return 'start'")) {
+			t.Errorf("skeleton plugconf was not created:\n%s", string(content))
+		}
+	})
+}
+
+// setUpPlugconfTemplate creates static repository reposPath, and the template
+// directory which has template of reposPath if template is not empty.
+// It returns the template directory.
+func setUpPlugconfTemplate(t *testing.T, reposPath pathutil.ReposPath, template string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(reposPath.FullPath(), "plugin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	templateDir := filepath.Join(os.Getenv("HOME"), "templates")
+	if template == "" {
+		return templateDir
+	}
+	templateFile := filepath.Join(templateDir, filepath.FromSlash(reposPath.String())+".vim")
+	if err := os.MkdirAll(filepath.Dir(templateFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(templateFile, []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return templateDir
+}
+
+func TestVoltGetRemotePlugin(t *testing.T) {
+	t.Run("Register remote plugins", func(t *testing.T) {
+		// =============== setup =============== //
+
+		testutil.SetUpEnv(t)
+		reposPath := pathutil.ReposPath("localhost/local/rplugin")
+		argsFile := setUpRemotePlugin(t, reposPath, 0)
+		defer os.Unsetenv("VOLT_NVIM")
+
+		// =============== run =============== //
+
+		out, err := testutil.RunVolt("get", reposPath.String())
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+
+		// (S)
+		args, err := ioutil.ReadFile(argsFile)
+		if err != nil {
+			t.Fatal("nvim was not run: " + err.Error())
+		}
+		if !bytes.Contains(args, []byte("UpdateRemotePlugins")) || !bytes.Contains(args, []byte(reposPath.EncodeToPlugDirName())) {
+			t.Errorf("nvim was run with unexpected arguments: %s", string(args))
+		}
+
+		// Not run again when no plugins are changed
+		os.Remove(argsFile)
+		out, err = testutil.RunVolt("get", reposPath.String())
+		testutil.SuccessExit(t, out, err)
+		if pathutil.Exists(argsFile) {
+			t.Error("nvim was run though no plugins were changed")
+		}
+	})
+
+	t.Run("Report failure of registering remote plugins", func(t *testing.T) {
+		// =============== setup =============== //
+
+		testutil.SetUpEnv(t)
+		reposPath := pathutil.ReposPath("localhost/local/rplugin")
+		setUpRemotePlugin(t, reposPath, 1)
+		defer os.Unsetenv("VOLT_NVIM")
+
+		// =============== run =============== //
+
+		out, err := testutil.RunVolt("get", reposPath.String())
+		// (!A, !B)
+		testutil.FailExit(t, out, err)
+		msg := fmt.Sprintf(fmtRpluginFailed, reposPath)
+		if !bytes.Contains(out, []byte(msg)) {
+			t.Errorf("Output does not contain %q\n%s", msg, string(out))
+		}
+	})
+}
+
+// setUpRemotePlugin creates static repository reposPath which has rplugin/
+// directory, and fake nvim executable which exits with exitCode.
+// It returns the file which the fake nvim writes arguments to.
+func setUpRemotePlugin(t *testing.T, reposPath pathutil.ReposPath, exitCode int) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(reposPath.FullPath(), "rplugin", "python3"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	home := os.Getenv("HOME")
+	argsFile := filepath.Join(home, "nvim-args")
+	nvim := filepath.Join(home, "nvim")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" >%s\nexit %d\n", argsFile, exitCode)
+	if err := ioutil.WriteFile(nvim, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("VOLT_NVIM", nvim)
+	return argsFile
+}
+
 // [error] Specify invalid argument (!A, !B, !C, !D, !E, !F, !G)
 func TestErrVoltGetInvalidArgs(t *testing.T) {
 	// =============== setup =============== //
@@ -448,6 +700,50 @@ func TestErrVoltGetInvalidArgs(t *testing.T) {
 	}
 }
 
+// [error] Specify invalid -filter (!A, !B, !C)
+func TestErrVoltGetInvalidFilter(t *testing.T) {
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("github.com/tyru/caw.vim")
+
+	out, err := testutil.RunVolt("get", "-filter", "blob:all", reposPath.String())
+	// (!A, !B)
+	testutil.FailExit(t, out, err)
+	// (!C)
+	if pathutil.Exists(reposPath.FullPath()) {
+		t.Error("repos exists: " + reposPath.FullPath())
+	}
+}
+
+// [error] Specify plugins which include not existing one (!A, !B, !C, !F)
+// * with -partial option (!A, !B, C, F)
+func TestErrVoltGetBatchRollback(t *testing.T) {
+	for _, partial := range []bool{false, true} {
+		t.Run(fmt.Sprintf("partial=%v", partial), func(t *testing.T) {
+			testutil.SetUpEnv(t)
+			reposPath := pathutil.ReposPath("github.com/tyru/caw.vim")
+			args := []string{"get", "tyru/caw.vim", "vim-volt/not_found"}
+			if partial {
+				args = []string{"get", "-partial", "tyru/caw.vim", "vim-volt/not_found"}
+			}
+
+			out, err := testutil.RunVolt(args...)
+			// (!A, !B)
+			testutil.FailExit(t, out, err)
+
+			// (C)
+			if pathutil.Exists(reposPath.FullPath()) != partial {
+				t.Errorf("repos exists: %v (-partial=%v)", pathutil.Exists(reposPath.FullPath()), partial)
+			}
+			// (F)
+			if partial {
+				testReposPathWereAdded(t, reposPath)
+			} else {
+				testReposPathWereNotAdded(t, reposPath)
+			}
+		})
+	}
+}
+
 // [error] Specify plugin which does not exist (!A, !B, !C, !D, !E, !F, !G, H)
 func TestErrVoltGetNotFound(t *testing.T) {
 	// =============== setup =============== //
@@ -495,6 +791,422 @@ func TestErrVoltGetNotFound(t *testing.T) {
 	}
 }
 
+// Checks:
+// (a) HEAD is detached at the commit of {ref}
+// (b) lock.json has the commit of {ref}
+//
+// * Run `volt get <repos>@<tag>` (A, B, a, b)
+// * Run `volt get -u <repos>` after `volt get <repos>@<tag>` (A, B, a, b)
+//   * Output contains "# {repos} > pinned to {hash} (not upgraded)"
+// * Run `volt get -u <repos>@<branch>` after `volt get <repos>@<tag>` (A, B, O)
+//   * The version is unpinned
+func TestVoltGetPinned(t *testing.T) {
+	reposPath := pathutil.ReposPath("example.com/vim-volt/pinned.vim")
+	setUp := func(t *testing.T) (remote, tagged string) {
+		t.Helper()
+		testutil.SetUpEnv(t)
+		remote = testutil.SetUpRemoteRepos(t, reposPath)
+		testutil.Git(t, remote, "tag", "-a", "-m", "v1.0.0", "v1.0.0")
+		tagged = testutil.Git(t, remote, "rev-parse", "HEAD")
+		testutil.Git(t, remote, "commit", "-q", "--allow-empty", "-m", "second")
+		return
+	}
+	checkPinned := func(t *testing.T, tagged string) {
+		t.Helper()
+		// (a)
+		if pinned, err := gitutil.IsDetachedHEAD(reposPath); err != nil || !pinned {
+			t.Errorf("HEAD is not detached: %v", err)
+		}
+		if head, err := gitutil.GetHEAD(reposPath); err != nil || head != tagged {
+			t.Errorf("expected HEAD is %s but got %s (%v)", tagged, head, err)
+		}
+		// (b)
+		lockJSON, err := lockjson.Read()
+		if err != nil {
+			t.Fatal("lockjson.Read() returned non-nil error: " + err.Error())
+		}
+		repos, err := lockJSON.Repos.FindByPath(reposPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if repos.Version != tagged {
+			t.Errorf("expected version is %s but got %s", tagged, repos.Version)
+		}
+	}
+
+	t.Run("Run `volt get <repos>@<tag>`", func(t *testing.T) {
+		_, tagged := setUp(t)
+
+		out, err := testutil.RunVolt("get", reposPath.String()+"@v1.0.0")
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (a, b)
+		checkPinned(t, tagged)
+	})
+
+	t.Run("Run `volt get -u <repos>` after `volt get <repos>@<tag>`", func(t *testing.T) {
+		_, tagged := setUp(t)
+		out, err := testutil.RunVolt("get", reposPath.String()+"@v1.0.0")
+		testutil.SuccessExit(t, out, err)
+
+		out, err = testutil.RunVolt("get", "-u", reposPath.String())
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (a, b)
+		checkPinned(t, tagged)
+		msg := fmt.Sprintf(fmtPinned, reposPath, tagged)
+		if !bytes.Contains(out, []byte(msg)) {
+			t.Errorf("Output does not contain %q\n%s", msg, string(out))
+		}
+	})
+
+	t.Run("Run `volt get -u <repos>@<branch>` after `volt get <repos>@<tag>`", func(t *testing.T) {
+		remote, tagged := setUp(t)
+		out, err := testutil.RunVolt("get", reposPath.String()+"@v1.0.0")
+		testutil.SuccessExit(t, out, err)
+		branch := testutil.Git(t, remote, "rev-parse", "--abbrev-ref", "HEAD")
+		latest := testutil.Git(t, remote, "rev-parse", "HEAD")
+
+		out, err = testutil.RunVolt("get", "-u", reposPath.String()+"@"+branch)
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (O)
+		msg := fmt.Sprintf(fmtUpgraded, reposPath, tagged, latest)
+		if !bytes.Contains(out, []byte(msg)) {
+			t.Errorf("Output does not contain %q\n%s", msg, string(out))
+		}
+		if pinned, err := gitutil.IsDetachedHEAD(reposPath); err != nil || pinned {
+			t.Errorf("HEAD is still detached: %v", err)
+		}
+	})
+}
+
+// Checks:
+// (a) HEAD is the commit of rewritten upstream history
+// (b) HEAD is not changed
+//
+// * Run `volt get -u <repos>` after upstream history was rewritten with "on_force_push = reset" (A, B, a)
+// * Run `volt get -u <repos>` after upstream history was rewritten with "on_force_push = keep" (A, B, b)
+//   * Output contains "# {repos} > kept current commit ..."
+// * Run `volt get -u <repos>` after upstream and local branch were diverged with "on_force_push = reset" (!A, !B, b, I)
+//   * Local commits are not regarded as rewritten history, and are kept
+func TestVoltGetRewrittenHistory(t *testing.T) {
+	reposPath := pathutil.ReposPath("example.com/vim-volt/rewritten.vim")
+	setUp := func(t *testing.T, onForcePush string) string {
+		t.Helper()
+		testutil.SetUpEnv(t)
+		remote := testutil.SetUpRemoteRepos(t, reposPath)
+		out, err := testutil.RunVolt("config", "set", "get.on_force_push", onForcePush)
+		testutil.SuccessExit(t, out, err)
+		out, err = testutil.RunVolt("get", reposPath.String())
+		testutil.SuccessExit(t, out, err)
+		return remote
+	}
+
+	t.Run("Run `volt get -u <repos>` after upstream history was rewritten with \"on_force_push = reset\"", func(t *testing.T) {
+		remote := setUp(t, "reset")
+		testutil.Git(t, remote, "commit", "-q", "--amend", "-m", "rewritten")
+		rewritten := testutil.Git(t, remote, "rev-parse", "HEAD")
+
+		out, err := testutil.RunVolt("get", "-u", reposPath.String())
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (a)
+		if head, err := gitutil.GetHEAD(reposPath); err != nil || head != rewritten {
+			t.Errorf("expected HEAD is %s but got %s (%v)", rewritten, head, err)
+		}
+	})
+
+	t.Run("Run `volt get -u <repos>` after upstream history was rewritten with \"on_force_push = keep\"", func(t *testing.T) {
+		remote := setUp(t, "keep")
+		before := testutil.Git(t, remote, "rev-parse", "HEAD")
+		testutil.Git(t, remote, "commit", "-q", "--amend", "-m", "rewritten")
+		rewritten := testutil.Git(t, remote, "rev-parse", "HEAD")
+
+		out, err := testutil.RunVolt("get", "-u", reposPath.String())
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (b)
+		if head, err := gitutil.GetHEAD(reposPath); err != nil || head != before {
+			t.Errorf("expected HEAD is %s but got %s (%v)", before, head, err)
+		}
+		msg := fmt.Sprintf(fmtKeptLocal, reposPath, rewritten)
+		if !bytes.Contains(out, []byte(msg)) {
+			t.Errorf("Output does not contain %q\n%s", msg, string(out))
+		}
+	})
+
+	t.Run("Run `volt get -u <repos>` after upstream and local branch were diverged with \"on_force_push = reset\"", func(t *testing.T) {
+		remote := setUp(t, "reset")
+		testutil.Git(t, remote, "commit", "-q", "--allow-empty", "-m", "upstream")
+		testutil.Git(t, reposPath.FullPath(), "commit", "-q", "--allow-empty", "-m", "local")
+		local := testutil.Git(t, reposPath.FullPath(), "rev-parse", "HEAD")
+
+		out, err := testutil.RunVolt("get", "-u", reposPath.String())
+		// (!A, !B)
+		testutil.FailExit(t, out, err)
+		// (b)
+		if head, err := gitutil.GetHEAD(reposPath); err != nil || head != local {
+			t.Errorf("expected HEAD is %s but got %s (%v)", local, head, err)
+		}
+		// (I)
+		msg := fmt.Sprintf(fmtUpgradeFailed, reposPath)
+		if !bytes.Contains(out, []byte(msg)) || !bytes.Contains(out, []byte("has local commits")) {
+			t.Errorf("Output does not contain %q\n%s", msg, string(out))
+		}
+	})
+}
+
+// Checks:
+// (a) Submodule is checked out at the commit recorded in HEAD commit
+// (b) lock.json has the commit of submodule
+// (c) Submodule is not initialized
+//
+// * Run `volt get <repos>` (A, B, a, b)
+// * Run `volt get <repos>@<tag>` (A, B, a, b)
+//   * Submodule is checked out at the commit recorded in the tagged commit
+// * Run `volt get -u <repos>` after upstream history was rewritten with "on_force_push = reset" (A, B, a, b)
+//   * Submodule is checked out at the commit recorded in the rewritten commit
+// * Run `volt get -submodules none <repos>` (A, B, c)
+// * Run `volt profile new <profile>` after `volt get -u <repos>` crashed (!A, B, a, b)
+//   * Submodule is checked out at the commit recorded in the rolled back commit
+func TestVoltGetSubmodules(t *testing.T) {
+	reposPath := pathutil.ReposPath("example.com/vim-volt/super.vim")
+	subReposPath := pathutil.ReposPath("example.com/vim-volt/sub.vim")
+	// setUp creates the repository which has the submodule "sub".
+	// The tag v1.0.0 records the first commit of the submodule, and HEAD
+	// records the second commit.
+	setUp := func(t *testing.T) (remote, first, second string) {
+		t.Helper()
+		testutil.SetUpEnv(t)
+		sub := testutil.SetUpRemoteRepos(t, subReposPath)
+		first = testutil.Git(t, sub, "rev-parse", "HEAD")
+		testutil.Git(t, sub, "commit", "-q", "--allow-empty", "-m", "second")
+		second = testutil.Git(t, sub, "rev-parse", "HEAD")
+		testutil.Git(t, sub, "checkout", "-q", first)
+
+		remote = testutil.SetUpRemoteRepos(t, reposPath)
+		testutil.Git(t, remote, "submodule", "add", "-q", "https://"+subReposPath.String(), "sub")
+		testutil.Git(t, filepath.Join(remote, "sub"), "checkout", "-q", first)
+		testutil.Git(t, remote, "commit", "-q", "-am", "add submodule")
+		testutil.Git(t, remote, "tag", "-a", "-m", "v1.0.0", "v1.0.0")
+		testutil.Git(t, filepath.Join(remote, "sub"), "checkout", "-q", second)
+		testutil.Git(t, remote, "commit", "-q", "-am", "update submodule")
+		return
+	}
+	checkSubmodule := func(t *testing.T, expected string) {
+		t.Helper()
+		// (a)
+		sub := filepath.Join(reposPath.FullPath(), "sub")
+		if head := testutil.Git(t, sub, "rev-parse", "HEAD"); head != expected {
+			t.Errorf("expected submodule HEAD is %s but got %s", expected, head)
+		}
+		// (b)
+		lockJSON, err := lockjson.Read()
+		if err != nil {
+			t.Fatal("lockjson.Read() returned non-nil error: " + err.Error())
+		}
+		repos, err := lockJSON.Repos.FindByPath(reposPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if repos.SubmoduleVersions["sub"] != expected {
+			t.Errorf("expected submodule version is %s but got %v", expected, repos.SubmoduleVersions)
+		}
+	}
+
+	t.Run("Run `volt get <repos>`", func(t *testing.T) {
+		_, _, second := setUp(t)
+
+		out, err := testutil.RunVolt("get", reposPath.String())
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (a, b)
+		checkSubmodule(t, second)
+	})
+
+	t.Run("Run `volt get <repos>@<tag>`", func(t *testing.T) {
+		_, first, _ := setUp(t)
+
+		out, err := testutil.RunVolt("get", reposPath.String()+"@v1.0.0")
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (a, b)
+		checkSubmodule(t, first)
+	})
+
+	t.Run("Run `volt get -u <repos>` after upstream history was rewritten with \"on_force_push = reset\"", func(t *testing.T) {
+		remote, first, _ := setUp(t)
+		out, err := testutil.RunVolt("config", "set", "get.on_force_push", "reset")
+		testutil.SuccessExit(t, out, err)
+		out, err = testutil.RunVolt("get", reposPath.String())
+		testutil.SuccessExit(t, out, err)
+		testutil.Git(t, remote, "reset", "-q", "--hard", "v1.0.0")
+		testutil.Git(t, filepath.Join(remote, "sub"), "checkout", "-q", first)
+		testutil.Git(t, remote, "commit", "-q", "--amend", "-m", "rewritten")
+
+		out, err = testutil.RunVolt("get", "-u", reposPath.String())
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (a, b)
+		checkSubmodule(t, first)
+	})
+
+	t.Run("Run `volt get -submodules none <repos>`", func(t *testing.T) {
+		setUp(t)
+
+		out, err := testutil.RunVolt("get", "-submodules", "none", reposPath.String())
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (c)
+		if pathutil.Exists(filepath.Join(reposPath.FullPath(), "sub", ".git")) {
+			t.Error("submodule was initialized")
+		}
+	})
+
+	t.Run("Run `volt profile new <profile>` after `volt get -u <repos>` crashed", func(t *testing.T) {
+		_, first, _ := setUp(t)
+		out, err := testutil.RunVolt("get", reposPath.String()+"@v1.0.0")
+		testutil.SuccessExit(t, out, err)
+		lockJSON, err := ioutil.ReadFile(pathutil.LockJSON())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Upgrade the repository and the submodule, and crash
+		fullpath := reposPath.FullPath()
+		version := testutil.Git(t, fullpath, "rev-parse", "HEAD")
+		testutil.Git(t, fullpath, "checkout", "-q", "master")
+		testutil.Git(t, fullpath, "submodule", "update", "-q")
+		content, err := json.Marshal(map[string]interface{}{
+			"pid":        1,
+			"started_at": "2018-01-01T00:00:00Z",
+			"args":       []string{"get", "-u", reposPath.String()},
+			"lock_json":  string(lockJSON),
+			"ops": []map[string]string{{
+				"type":       "upgrade",
+				"repos_path": reposPath.String(),
+				"version":    version,
+				"head":       lockjson.HeadTag,
+				"head_ref":   "v1.0.0",
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(pathutil.TrxJournal(), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err = testutil.RunVolt("profile", "new", "foo")
+		// (!A)
+		if !strings.Contains(string(out), "[WARN] Rolling back") {
+			t.Errorf("rollback message is not shown: %s", string(out))
+		}
+		// (B)
+		if err != nil {
+			t.Errorf("expected success exit but exited with failure: status=%q, out=%s", err, string(out))
+		}
+		// (a, b)
+		checkSubmodule(t, first)
+	})
+}
+
+// Checks:
+// (a) Only the files which match the patterns are in the worktree
+// (b) lock.json has the patterns
+//
+// * Run `volt get -exclude doc <repos>` (A, B, a, b)
+// * Run `volt get -include plugin <repos>` (A, B, a, b)
+// * Run `volt get -u <repos>` after `volt get -exclude doc <repos>` (A, B, a, b)
+//   * The patterns are applied again to the upgraded files
+// * Run `volt get -include / <repos>` after `volt get -exclude doc <repos>` (A, B, a, b)
+//   * All files are checked out, and the patterns are removed from lock.json
+func TestVoltGetSparseCheckout(t *testing.T) {
+	reposPath := pathutil.ReposPath("example.com/vim-volt/sparse.vim")
+	setUp := func(t *testing.T) string {
+		t.Helper()
+		testutil.SetUpEnv(t)
+		remote := testutil.SetUpRemoteRepos(t, reposPath)
+		os.MkdirAll(filepath.Join(remote, "doc"), 0755)
+		if err := ioutil.WriteFile(filepath.Join(remote, "doc", "sparse.txt"), []byte("*sparse.txt*"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		testutil.Git(t, remote, "add", "-A")
+		testutil.Git(t, remote, "commit", "-q", "-m", "add doc")
+		return remote
+	}
+	check := func(t *testing.T, files map[string]bool, patterns []string) {
+		t.Helper()
+		// (a)
+		for file, exists := range files {
+			if pathutil.Exists(filepath.Join(reposPath.FullPath(), file)) != exists {
+				t.Errorf("expected %s exists = %v", file, exists)
+			}
+		}
+		// (b)
+		lockJSON, err := lockjson.Read()
+		if err != nil {
+			t.Fatal("lockjson.Read() returned non-nil error: " + err.Error())
+		}
+		repos, err := lockJSON.Repos.FindByPath(reposPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(repos.SparseCheckout, " ") != strings.Join(patterns, " ") {
+			t.Errorf("expected patterns %v but got %v", patterns, repos.SparseCheckout)
+		}
+	}
+
+	t.Run("Run `volt get -exclude doc <repos>`", func(t *testing.T) {
+		setUp(t)
+
+		out, err := testutil.RunVolt("get", "-exclude", "doc", reposPath.String())
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (a, b)
+		check(t, map[string]bool{"plugin/sparse.vim": true, "doc/sparse.txt": false}, []string{"/*", "!/doc"})
+	})
+
+	t.Run("Run `volt get -include plugin <repos>`", func(t *testing.T) {
+		setUp(t)
+
+		out, err := testutil.RunVolt("get", "-include", "plugin", reposPath.String())
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (a, b)
+		check(t, map[string]bool{"plugin/sparse.vim": true, "doc/sparse.txt": false}, []string{"/plugin"})
+	})
+
+	t.Run("Run `volt get -u <repos>` after `volt get -exclude doc <repos>`", func(t *testing.T) {
+		remote := setUp(t)
+		out, err := testutil.RunVolt("get", "-exclude", "doc", reposPath.String())
+		testutil.SuccessExit(t, out, err)
+		if err := ioutil.WriteFile(filepath.Join(remote, "doc", "tags"), []byte("sparse.txt"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		testutil.Git(t, remote, "add", "-A")
+		testutil.Git(t, remote, "commit", "-q", "-m", "add tags")
+
+		out, err = testutil.RunVolt("get", "-u", reposPath.String())
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (a, b)
+		check(t, map[string]bool{"plugin/sparse.vim": true, "doc/sparse.txt": false, "doc/tags": false}, []string{"/*", "!/doc"})
+	})
+
+	t.Run("Run `volt get -include / <repos>` after `volt get -exclude doc <repos>`", func(t *testing.T) {
+		setUp(t)
+		out, err := testutil.RunVolt("get", "-exclude", "doc", reposPath.String())
+		testutil.SuccessExit(t, out, err)
+
+		out, err = testutil.RunVolt("get", "-include", "/", reposPath.String())
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (a, b)
+		check(t, map[string]bool{"plugin/sparse.vim": true, "doc/sparse.txt": true}, nil)
+	})
+}
+
 func testReposPathWereAdded(t *testing.T, reposPath pathutil.ReposPath) {
 	t.Helper()
 	lockJSON, err := lockjson.Read()
@@ -635,3 +1347,127 @@ func gitResetHard(reposPath pathutil.ReposPath, ref string) (current plumbing.Ha
 	})
 	return
 }
+
+// Specify -reinstall with static repository (A, B)
+// * The static repository is not removed
+func TestVoltGetReinstallStaticRepos(t *testing.T) {
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("localhost/local/hello")
+	file := filepath.Join(reposPath.FullPath(), "plugin", "hello.vim")
+	os.MkdirAll(filepath.Dir(file), 0755)
+	if err := ioutil.WriteFile(file, []byte(`command! Hello echom "hello"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := testutil.RunVolt("get", "-reinstall", reposPath.String())
+	// (A, B)
+	testutil.SuccessExit(t, out, err)
+	// (C)
+	if !pathutil.Exists(file) {
+		t.Error("static repository was removed: " + file)
+	}
+}
+
+// Specify -reinstall when the version in lock.json cannot be checked out
+// (!A, !B)
+// * The existing clone is restored (a)
+// * The existing clone is restored with -partial (a)
+// * The transaction is finished (b)
+// * The transaction is not rolled back with -partial (c)
+func TestVoltGetReinstallCheckoutFailed(t *testing.T) {
+	reposPath := pathutil.ReposPath("github.com/tyru/reinstall.vim")
+	missing := "0123456789012345678901234567890123456789"
+
+	for _, partial := range []bool{false, true} {
+		t.Run(fmt.Sprintf("partial=%v", partial), func(t *testing.T) {
+			testutil.SetUpEnv(t)
+			testutil.SetUpRemoteRepos(t, reposPath)
+			out, err := testutil.RunVolt("get", reposPath.String())
+			testutil.SuccessExit(t, out, err)
+			head, err := gitutil.GetHEAD(reposPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The file which is not in the new clone
+			marker := filepath.Join(reposPath.FullPath(), "marker")
+			if err = ioutil.WriteFile(marker, []byte("marker"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			// The version which is not in the remote repository
+			lockJSON, err := lockjson.Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			repos, err := lockJSON.Repos.FindByPath(reposPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			repos.Version = missing
+			if err = lockJSON.Write(); err != nil {
+				t.Fatal(err)
+			}
+
+			args := []string{"get", "-reinstall"}
+			if partial {
+				args = append(args, "-partial")
+			}
+			out, err = testutil.RunVolt(append(args, reposPath.String())...)
+			// (!A, !B)
+			testutil.FailExit(t, out, err)
+			// (a)
+			if !pathutil.Exists(marker) {
+				t.Error("the existing clone was not restored: " + marker + " does not exist")
+			}
+			if actual, err := gitutil.GetHEAD(reposPath); err != nil || actual != head {
+				t.Errorf("expected HEAD is %s but got %s (%v)", head, actual, err)
+			}
+			// (b)
+			for _, path := range []string{pathutil.TrxJournal(), pathutil.TrxBackup()} {
+				if pathutil.Exists(path) {
+					t.Error("transaction was not finished: " + path + " exists")
+				}
+			}
+			// (c)
+			if partial && strings.Contains(string(out), "Rolling back") {
+				t.Error("transaction was rolled back: " + string(out))
+			}
+		})
+	}
+}
+
+// Specify -reinstall -partial when the signature of the new clone cannot be
+// verified (!A, !B)
+// * The existing clone is restored (a)
+// * The transaction is finished (b)
+func TestVoltGetReinstallVerifyFailed(t *testing.T) {
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("github.com/tyru/reinstall.vim")
+	testutil.SetUpRemoteRepos(t, reposPath)
+	out, err := testutil.RunVolt("get", reposPath.String())
+	testutil.SuccessExit(t, out, err)
+	// The file which is not in the new clone
+	marker := filepath.Join(reposPath.FullPath(), "marker")
+	if err = ioutil.WriteFile(marker, []byte("marker"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The commit of the remote repository is not signed
+	out, err = testutil.RunVolt("config", "set", "git.verify_signatures", "true")
+	testutil.SuccessExit(t, out, err)
+
+	out, err = testutil.RunVolt("get", "-reinstall", "-partial", reposPath.String())
+	// (!A, !B)
+	testutil.FailExit(t, out, err)
+	if !strings.Contains(string(out), "signature verification failed") {
+		t.Errorf("signature was not verified: %s", string(out))
+	}
+	// (a)
+	if !pathutil.Exists(marker) {
+		t.Error("the existing clone was not restored: " + marker + " does not exist")
+	}
+	// (b)
+	for _, path := range []string{pathutil.TrxJournal(), pathutil.TrxBackup()} {
+		if pathutil.Exists(path) {
+			t.Error("transaction was not finished: " + path + " exists")
+		}
+	}
+}
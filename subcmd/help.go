@@ -5,6 +5,8 @@ import (
 	"flag"
 	"fmt"
 	"os"
+
+	"github.com/vim-volt/volt/config"
 )
 
 // ErrShowedHelp is used in parsing argument function of subcommand when the
@@ -37,12 +39,38 @@ func (cmd *helpCmd) FlagSet() *flag.FlagSet {
 				" '----------------'  '----------------'  '----------------'  '----------------'\n" +
 				`
 Usage
-  volt COMMAND ARGS
+  volt [-json] [-timings] COMMAND ARGS
+
+  COMMAND can also be an alias in [alias] section of config.toml.
+  'volt help {alias}' shows what the alias is expanded to.
+
+Options
+  -json
+    Output an error as JSON to stderr when the command failed:
+    {"error":{"code":{exit status},"kind":{kind},"message":{message}}}
+
+  -timings
+    Output the time spent in each phase (network, checkout, build, helptags,
+    lock write) to stderr after the command. The time of a phase is summed
+    over the plugins processed in parallel.
+    "debug.timings" of config.toml enables this by default.
+
+Exit status
+  0 success
+  1 general:         other errors
+  2 usage:           invalid arguments or options
+  3 validation:      lock.json, config.toml, or given values are invalid
+  4 network:         failed to communicate with remote
+  5 partial_failure: some of the targets failed (e.g. some plugins could not be installed)
+  6 permission:      the command cannot be run with root priviledge
 
 Command
-  get [-l] [-u] [{repository} ...]
+  get [-l] [-u] [-j {jobs}] [{repository}[@{ref}] ...]
     Install or upgrade given {repository} list, or add local {repository} list as plugins
 
+  get -from-starred {user}
+    Install Vim plugins selected from the repositories starred by {user} in GitHub
+
   rm [-r] [-p] {repository} [{repository2} ...]
     Remove vim plugin from ~/.vim/pack/volt/opt/ directory
 
@@ -50,6 +78,12 @@ Command
     Vim plugin information extractor.
     Unless -f flag was given, this command shows vim plugins of **current profile** (not all installed plugins) by default.
 
+  search {query}
+    Search plugins, and show their descriptions, categories, and popularity
+
+  info {repository} [{repository2} ...]
+    Show the description, category, and popularity of the plugin, and whether it is installed
+
   enable {repository} [{repository2} ...]
     This is shortcut of:
     volt profile add -current {repository} [{repository2} ...]
@@ -82,13 +116,74 @@ Command
   profile rm {name} {repository} [{repository2} ...]
     Remove one or more repositories to profile
 
+  profile export [{name}]
+    Output lock.json of profile to share it
+
+  profile import {lock.json} [{name}]
+    Install the plugins of shared profile (a file or a URL like gist) as profile
+
   build [-full]
     Build ~/.vim/pack/volt/ directory
 
-  migrate {migration operation}
+  log [-prune] [-format {text|json}]
+    Show the history of transactions
+
+  undo [{trx_id}]
+    Revert the most recent transaction, or the transaction {trx_id}
+
+  resume
+    Run the command of the last failed transaction again
+
+  changelog {repository} [{from}..{to}]
+    Show commits between the locked version and upstream, or between two revisions
+
+  gc [-j {jobs}]
+    Clean up git repositories, and show the reclaimed disk space
+
+  prefetch [-daemon] [-interval {duration}] [-j {jobs}]
+    Fetch all repositories without changing them, so that 'volt get -u' does not wait for network
+
+  lint
+    Check plugconf files and lock.json, and show the problems
+
+  profile-startup [-nvim] [-n {count}]
+    Show startup time of vim (or neovim) per plugin, and the difference from the previous result
+
+  bench [-n {count}] [-profile {name}]
+    Measure build time, profile switch time, and the files under ~/.vim/pack/volt for a performance bug report
+
+  config get {key}
+    Show the value of {key} in config.toml
+
+  config set [-local] {key} {value}
+    Validate {value} and write it to {key} in config.toml (or config.local.toml)
+
+  config list
+    Show all keys and values in config.toml
+
+  config validate
+    Show the problems of config.toml and config.local.toml (e.g. unknown keys), and the effective configuration
+
+  migrate {migration operation} [{args}]
     Perform miscellaneous migration operations.
     See 'volt migrate -help' for all available operations
 
+  import [-profile {name}] {lock.json}
+    Merge other lock.json (a file or a URL) into current lock.json
+
+  export [-bootstrap [-shell {sh|powershell}] | -vimplug | -voltfile | -sbom]
+    Output lock.json, a bootstrap script which restores current setup on a new machine,
+    "Plug" commands of vim-plug, a voltfile, or SBOM in CycloneDX format
+
+  apply [-f {voltfile}] [-plan]
+    Install, remove, and assign plugins to profiles as declared in voltfile
+
+  sync -from {lock.json} [-plan]
+    Make current lock.json and repositories the same as other lock.json (a file or a URL)
+
+  env [-shell {sh|powershell}] [-install] [{dir}]
+    Print shell commands which load the plugins of the project (.volt.json) of {dir}
+
   self-upgrade [-check]
     Upgrade to the latest volt command, or if -check was given, it only checks the newer version is available
 
@@ -110,7 +205,13 @@ func (cmd *helpCmd) Run(args []string) *Error {
 
 	fs, exists := cmdMap[args[0]]
 	if !exists {
-		return &Error{Code: 1, Msg: fmt.Sprintf("Unknown command '%s'", args[0])}
+		if cfg, err := config.Read(); err == nil {
+			if alias, exists := cfg.Alias[args[0]]; exists {
+				fmt.Printf("'%s' is an alias for '%s'\n", args[0], alias)
+				return nil
+			}
+		}
+		return &Error{Code: ExitUsage, Msg: fmt.Sprintf("Unknown command '%s'", args[0])}
 	}
 	args = append([]string{"-help"}, args[1:]...)
 	fs.Run(args)
@@ -0,0 +1,452 @@
+package subcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/gitutil"
+	"github.com/vim-volt/volt/httputil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/scheduler"
+	"github.com/vim-volt/volt/timing"
+	"github.com/vim-volt/volt/transaction"
+)
+
+func init() {
+	cmdMap["import"] = &importCmd{}
+}
+
+type importCmd struct {
+	helped   bool
+	profile  string
+	showPlan bool
+	message  string
+}
+
+func (cmd *importCmd) ProhibitRootExecution(args []string) bool { return true }
+
+func (cmd *importCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt import [-help] [-profile {name}] [-plan] [-m {message}] {lock.json}
+
+Quick example
+  $ volt import colleague-lock.json           # will install repositories which are not installed yet
+  $ volt import -profile work work-lock.json  # will also create profile "work"
+  $ volt export | ssh other-machine volt import -  # "-" reads lock.json from stdin
+  $ volt import -plan colleague-lock.json     # will show what is installed
+  $ volt import https://github.com/tyru/dotfiles/blob/master/lock.json  # will read lock.json of the URL
+
+Description
+  Merge {lock.json} (e.g. exported by "volt export" on other machine) into $VOLTPATH/lock.json.
+  {lock.json} can be a file, "-" (stdin), or a URL. GitHub gist URLs and
+  GitHub file URLs ("https://github.com/{user}/{repo}/blob/{ref}/{path}") are
+  read as the raw content.
+
+  * Git repositories which do not exist in local lock.json are installed, and
+    checked out to the version of {lock.json}. The branch, the tag, or the
+    detached commit recorded to "head" of {lock.json} is checked out.
+  * Static repositories which do not exist in local lock.json are added only if
+    the directories exist under $VOLTPATH/repos/
+  * Repositories which exist in both lock.json are not changed.
+    If their versions differ, they are reported as conflicts
+  * Current profile and existing profiles are not changed
+
+  If -profile option was given, create profile {name} which has the same
+  repositories as current profile of {lock.json}.
+
+  If -plan option was given, show the steps to be performed (the repositories
+  to be installed or added, and so on), and exit without changing anything.` + "\n\n")
+		fmt.Println("Options")
+		fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	fs.StringVar(&cmd.profile, "profile", "", "create new profile from current profile of given lock.json")
+	fs.StringVar(&cmd.message, "m", "", messageFlagUsage)
+	fs.BoolVar(&cmd.showPlan, "plan", false, "show the steps to be performed without changing anything")
+	return fs
+}
+
+func (cmd *importCmd) Run(args []string) *Error {
+	file, err := cmd.parseArgs(args)
+	if err == ErrShowedHelp {
+		return nil
+	}
+	if err != nil {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: " + err.Error()}
+	}
+	transaction.SetMessage(cmd.message)
+
+	imported, err := cmd.readImportedLockJSON(file)
+	if err != nil {
+		return &Error{Code: exitCodeOf(err, ExitValidation), Msg: "Could not read " + file + ": " + err.Error()}
+	}
+
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return &Error{Code: ExitValidation, Msg: "Could not read lock.json: " + err.Error()}
+	}
+
+	err = cmd.doImport(lockJSON, imported)
+	if err == errShowedPlan {
+		return nil
+	}
+	if err != nil {
+		return &Error{Code: exitCodeOf(err, ExitGeneral), Msg: "Failed to import: " + err.Error()}
+	}
+	return nil
+}
+
+func (cmd *importCmd) parseArgs(args []string) (string, error) {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return "", ErrShowedHelp
+	}
+	if len(fs.Args()) != 1 {
+		fs.Usage()
+		return "", errors.New("one lock.json must be given")
+	}
+	return fs.Arg(0), nil
+}
+
+func (*importCmd) readImportedLockJSON(file string) (*lockjson.LockJSON, error) {
+	var content []byte
+	var err error
+	switch {
+	case file == "-":
+		content, err = ioutil.ReadAll(os.Stdin)
+	case strings.HasPrefix(file, "https://") || strings.HasPrefix(file, "http://"):
+		content, err = httputil.GetContent(rawContentURL(file))
+		if err != nil {
+			return nil, &networkError{err: err}
+		}
+	default:
+		content, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lockjson.Parse(content)
+}
+
+var (
+	// rxGistURL matches to the URL of gist page ("https://gist.github.com/{user}/{id}").
+	rxGistURL = regexp.MustCompile(`\Ahttps://gist\.github\.com/([^/]+/)?[0-9a-f]+/?\z`)
+	// rxGitHubBlobURL matches to the URL of file page in GitHub
+	// ("https://github.com/{user}/{repo}/blob/{ref}/{path}").
+	rxGitHubBlobURL = regexp.MustCompile(`\Ahttps://github\.com/([^/]+/[^/]+)/blob/(.+)\z`)
+)
+
+// rawContentURL returns the URL of the raw content of url if url is the page
+// of gist or the file in GitHub. Otherwise url is returned.
+func rawContentURL(url string) string {
+	if rxGistURL.MatchString(url) {
+		return strings.TrimSuffix(url, "/") + "/raw"
+	}
+	if m := rxGitHubBlobURL.FindStringSubmatch(url); m != nil {
+		return "https://raw.githubusercontent.com/" + m[1] + "/" + m[2]
+	}
+	return url
+}
+
+const (
+	fmtImportAdded     = "+ %s > added"
+	fmtImportInstalled = "+ %s > installed"
+	fmtImportExists    = "# %s > already exists"
+	fmtImportConflict  = "! %s > conflict: %s"
+	fmtImportFailed    = "! %s > install failed"
+	fmtImportSkipped   = "! %s > skipped: %s"
+)
+
+type importResult struct {
+	repos  *lockjson.Repos
+	status string
+	err    error
+}
+
+// importPlan is the plan of "volt import".
+type importPlan struct {
+	plan
+	// install is the git repositories to be installed
+	install []*lockjson.Repos
+	// static is the static repositories to be added
+	static []*lockjson.Repos
+	// statusList is the status of the repositories which are not changed
+	statusList []string
+}
+
+// planImport determines the repositories to be installed or added.
+func (cmd *importCmd) planImport(lockJSON, imported *lockjson.LockJSON) (*importPlan, error) {
+	if cmd.profile != "" && lockJSON.Profiles.FindIndexByName(cmd.profile) >= 0 {
+		return nil, errors.New("profile '" + cmd.profile + "' already exists")
+	}
+
+	p := &importPlan{statusList: make([]string, 0, len(imported.Repos))}
+	for i := range imported.Repos {
+		repos := &imported.Repos[i]
+		if local, err := lockJSON.Repos.FindByPath(repos.Path); err == nil {
+			p.statusList = append(p.statusList, cmd.compareRepos(local, repos))
+			continue
+		}
+		switch repos.Type {
+		case lockjson.ReposGitType:
+			p.install = append(p.install, repos)
+			step := fmt.Sprintf("clone %s at %s into %s", repos.Path.CloneURL(), repos.Version, relVoltPath(repos.Path.FullPath()))
+			if repos.CloneFilter != "" {
+				step += " (filter: " + repos.CloneFilter + ")"
+			}
+			p.add("%s", step)
+		case lockjson.ReposStaticType:
+			if !pathutil.Exists(repos.Path.FullPath()) {
+				p.statusList = append(p.statusList, fmt.Sprintf(fmtImportSkipped, repos.Path, "static repository does not exist in "+repos.Path.FullPath()))
+				continue
+			}
+			p.static = append(p.static, repos)
+			p.add("add static repository %s", relVoltPath(repos.Path.FullPath()))
+		}
+	}
+	changes := len(p.install) + len(p.static)
+	if cmd.profile != "" {
+		p.add("create profile '%s'", cmd.profile)
+		changes++
+	}
+	p.addWriteLockJSON(changes)
+	return p, nil
+}
+
+func (cmd *importCmd) doImport(lockJSON, imported *lockjson.LockJSON) error {
+	// Validate before modifying anything
+	p, err := cmd.planImport(lockJSON, imported)
+	if err != nil {
+		return err
+	}
+	if cmd.showPlan {
+		p.show()
+		return errShowedPlan
+	}
+
+	// Begin transaction
+	err = transaction.Create()
+	if err != nil {
+		return err
+	}
+	defer transaction.Remove()
+
+	// Read config.toml
+	cfg, err := config.Read()
+	if err != nil {
+		return errors.New("could not read config.toml: " + err.Error())
+	}
+	err = gitutil.CheckBackend(cfg)
+	if err != nil {
+		return err
+	}
+
+	statusList := p.statusList
+	done := make(chan importResult, len(p.install))
+	sched := scheduler.New(cfg.Get.Jobs, cfg.Network.JobsPerHost)
+	for _, repos := range p.install {
+		repos := repos
+		sched.Go(repos.Path.Host(), func() { cmd.installRepos(repos, cfg, done) })
+	}
+	for _, repos := range p.static {
+		lockJSON.Repos = append(lockJSON.Repos, *repos)
+		statusList = append(statusList, fmt.Sprintf(fmtImportAdded, repos.Path))
+	}
+
+	failed := false
+	for i := 0; i < len(p.install); i++ {
+		r := <-done
+		if r.err != nil {
+			failed = true
+			statusList = append(statusList, r.status+"\n  * "+r.err.Error())
+			continue
+		}
+		lockJSON.Repos = append(lockJSON.Repos, *r.repos)
+		statusList = append(statusList, r.status)
+	}
+
+	// Create new profile from current profile of imported lock.json
+	if cmd.profile != "" {
+		profile, err := imported.Profiles.FindByName(imported.CurrentProfileName)
+		if err != nil {
+			return err
+		}
+		reposPathList := make([]pathutil.ReposPath, 0, len(profile.ReposPath))
+		for _, reposPath := range profile.ReposPath {
+			if !lockJSON.Repos.Contains(reposPath) {
+				logger.Warnf("'%s' is not added to profile '%s' because it could not be imported", reposPath, cmd.profile)
+				continue
+			}
+			reposPathList = append(reposPathList, reposPath)
+		}
+		lockJSON.Profiles = append(lockJSON.Profiles, lockjson.Profile{
+			Name:      cmd.profile,
+			ReposPath: reposPathList,
+		})
+		logger.Info("Created new profile '" + cmd.profile + "'")
+	}
+
+	// Write to lock.json
+	err = lockJSON.Write()
+	if err != nil {
+		return errors.New("could not write to lock.json: " + err.Error())
+	}
+
+	// Failed repositories were already rolled back, keep installed ones
+	if err = transaction.Commit(); err != nil {
+		return err
+	}
+
+	// Show results
+	sort.Strings(statusList)
+	for i := range statusList {
+		fmt.Println(statusList[i])
+	}
+	if failed {
+		return &partialFailureError{msg: "failed to install some plugins"}
+	}
+	return nil
+}
+
+// compareRepos returns status string of repos which exists in both lock.json.
+func (*importCmd) compareRepos(local, imported *lockjson.Repos) string {
+	switch {
+	case local.Type != imported.Type:
+		return fmt.Sprintf(fmtImportConflict, local.Path,
+			fmt.Sprintf("type is %q in local, but %q in imported lock.json (kept local)", local.Type, imported.Type))
+	case local.Type == lockjson.ReposGitType && local.Version != imported.Version:
+		return fmt.Sprintf(fmtImportConflict, local.Path,
+			fmt.Sprintf("version is %s in local, but %s in imported lock.json (kept local)", local.Version, imported.Version))
+	default:
+		return fmt.Sprintf(fmtImportExists, local.Path)
+	}
+}
+
+// installRepos clones repos, and checks out the version of imported lock.json.
+func (*importCmd) installRepos(repos *lockjson.Repos, cfg *config.Config, done chan<- importResult) {
+	get := &getCmd{}
+	reposPath := repos.Path
+	fullReposPath := reposPath.FullPath()
+
+	if transaction.Interrupted() {
+		done <- importResult{
+			status: fmt.Sprintf(fmtImportFailed, reposPath),
+			err:    transaction.ErrInterrupted,
+		}
+		return
+	}
+	logger.Debug("Installing " + reposPath + " ...")
+	if err := get.clonePlugin(reposPath, repos.CloneFilter, cfg); err != nil {
+		if err != errRepoExists {
+			get.removeDir(fullReposPath)
+		}
+		done <- importResult{
+			status: fmt.Sprintf(fmtImportFailed, reposPath),
+			err:    errors.New("failed to install plugin: " + err.Error()),
+		}
+		return
+	}
+
+	status := fmt.Sprintf(fmtImportInstalled, reposPath)
+	stop := timing.Start(timing.Checkout)
+	err := gitutil.CheckoutVersion(reposPath, repos.Version, repos.Head, repos.HeadRef, cfg)
+	stop()
+	if err != nil {
+		status = fmt.Sprintf(fmtImportConflict, reposPath,
+			fmt.Sprintf("could not check out %s (kept HEAD): %s", repos.Version, strings.TrimSpace(err.Error())))
+	}
+
+	if *cfg.Git.VerifySignatures {
+		if err := gitutil.VerifyHEAD(reposPath); err != nil {
+			get.removeDir(fullReposPath)
+			done <- importResult{
+				status: fmt.Sprintf(fmtImportFailed, reposPath),
+				err:    errors.New("signature verification failed: " + err.Error()),
+			}
+			return
+		}
+	}
+
+	head, err := gitutil.GetHEAD(reposPath)
+	if err != nil {
+		get.removeDir(fullReposPath)
+		done <- importResult{
+			status: fmt.Sprintf(fmtImportFailed, reposPath),
+			err:    errors.New("failed to get HEAD commit hash: " + err.Error()),
+		}
+		return
+	}
+
+	headKind, headRef, err := gitutil.GetHEADState(reposPath)
+	if err != nil {
+		get.removeDir(fullReposPath)
+		done <- importResult{
+			status: fmt.Sprintf(fmtImportFailed, reposPath),
+			err:    errors.New("failed to get the state of HEAD: " + err.Error()),
+		}
+		return
+	}
+
+	stop = timing.Start(timing.Network)
+	err = gitutil.UpdateSubmodules(reposPath, repos.Submodules, cfg)
+	stop()
+	var submoduleVersions map[string]string
+	if err == nil {
+		submoduleVersions, err = gitutil.SubmoduleVersions(reposPath, repos.Submodules, cfg)
+	}
+	if err != nil {
+		get.removeDir(fullReposPath)
+		done <- importResult{
+			status: fmt.Sprintf(fmtImportFailed, reposPath),
+			err:    errors.New("failed to update submodules: " + err.Error()),
+		}
+		return
+	}
+
+	stop = timing.Start(timing.Network)
+	placeholders, err := gitutil.PullLFS(reposPath, cfg)
+	stop()
+	if err != nil {
+		get.removeDir(fullReposPath)
+		done <- importResult{
+			status: fmt.Sprintf(fmtImportFailed, reposPath),
+			err:    errors.New("failed to pull Git LFS files: " + err.Error()),
+		}
+		return
+	}
+	if len(placeholders) > 0 {
+		logger.Warnf("%s uses Git LFS, but git-lfs is not installed. "+
+			"The following files are placeholders:\n  %s",
+			reposPath, strings.Join(placeholders, "\n  "))
+	}
+
+	transaction.RepoInstalled(reposPath)
+	done <- importResult{
+		repos: &lockjson.Repos{
+			Type:              lockjson.ReposGitType,
+			Path:              reposPath,
+			Version:           head,
+			Submodules:        repos.Submodules,
+			SubmoduleVersions: submoduleVersions,
+			CloneFilter:       repos.CloneFilter,
+			Head:              headKind,
+			HeadRef:           headRef,
+		},
+		status: status,
+	}
+}
@@ -0,0 +1,164 @@
+package subcmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/internal/testutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+
+// Checks:
+// (a) Static repositories which exist under $VOLTPATH/repos/ are added
+// (b) Static repositories which do not exist are skipped
+// (c) Repositories which exist in both lock.json are not changed
+// (d) Profile is created from current profile of imported lock.json
+//
+// * Run `volt import {lock.json}` (A, B, a, b, c)
+// * Run `volt import -profile {name} {lock.json}` (A, B, a, d)
+// * Run `volt import -profile {name} {lock.json}` ({name} already exists) (!A, !B)
+func TestVoltImport(t *testing.T) {
+	setUpLocal := func(t *testing.T) {
+		t.Helper()
+		testutil.SetUpEnv(t)
+		local := &lockjson.LockJSON{
+			Version:            2,
+			CurrentProfileName: "default",
+			Repos: lockjson.ReposList{
+				{Type: lockjson.ReposGitType, Path: "github.com/vim-volt/local.vim", Version: "1111111111111111111111111111111111111111"},
+			},
+			Profiles: lockjson.ProfileList{
+				{Name: "default", ReposPath: []pathutil.ReposPath{"github.com/vim-volt/local.vim"}},
+			},
+		}
+		if err := local.Write(); err != nil {
+			t.Fatal(err)
+		}
+		os.MkdirAll(pathutil.ReposPath("localhost/local/exists").FullPath(), 0755)
+	}
+	writeImported := func(t *testing.T) string {
+		t.Helper()
+		imported := &lockjson.LockJSON{
+			Version:            2,
+			CurrentProfileName: "other",
+			Repos: lockjson.ReposList{
+				{Type: lockjson.ReposGitType, Path: "github.com/vim-volt/local.vim", Version: "2222222222222222222222222222222222222222"},
+				{Type: lockjson.ReposStaticType, Path: "localhost/local/exists"},
+				{Type: lockjson.ReposStaticType, Path: "localhost/local/missing"},
+			},
+			Profiles: lockjson.ProfileList{
+				{Name: "default", ReposPath: []pathutil.ReposPath{"github.com/vim-volt/local.vim"}},
+				{Name: "other", ReposPath: []pathutil.ReposPath{"github.com/vim-volt/local.vim", "localhost/local/exists"}},
+			},
+		}
+		b, err := json.Marshal(imported)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join(os.Getenv("HOME"), "imported.json")
+		if err := ioutil.WriteFile(file, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	t.Run("Run `volt import {lock.json}`", func(t *testing.T) {
+		setUpLocal(t)
+		file := writeImported(t)
+
+		out, err := testutil.RunVolt("import", file)
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+
+		lockJSON, err := lockjson.Read()
+		if err != nil {
+			t.Fatal("lockjson.Read() returned non-nil error: " + err.Error())
+		}
+		// (a)
+		if !lockJSON.Repos.Contains("localhost/local/exists") {
+			t.Error("existing static repository was not added")
+		}
+		// (b)
+		if lockJSON.Repos.Contains("localhost/local/missing") {
+			t.Error("missing static repository was added")
+		}
+		if !strings.Contains(string(out), "! localhost/local/missing > skipped") {
+			t.Errorf("missing static repository was not reported: %s", string(out))
+		}
+		// (c)
+		repos, err := lockJSON.Repos.FindByPath("github.com/vim-volt/local.vim")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if repos.Version != "1111111111111111111111111111111111111111" {
+			t.Errorf("version of local repository was changed: %s", repos.Version)
+		}
+		if !strings.Contains(string(out), "! github.com/vim-volt/local.vim > conflict") {
+			t.Errorf("conflict was not reported: %s", string(out))
+		}
+		if lockJSON.CurrentProfileName != "default" || len(lockJSON.Profiles) != 1 {
+			t.Errorf("profiles were changed: %+v", lockJSON.Profiles)
+		}
+	})
+
+	t.Run("Run `volt import -profile {name} {lock.json}`", func(t *testing.T) {
+		setUpLocal(t)
+		file := writeImported(t)
+
+		out, err := testutil.RunVolt("import", "-profile", "work", file)
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+
+		lockJSON, err := lockjson.Read()
+		if err != nil {
+			t.Fatal("lockjson.Read() returned non-nil error: " + err.Error())
+		}
+		// (a)
+		if !lockJSON.Repos.Contains("localhost/local/exists") {
+			t.Error("existing static repository was not added")
+		}
+		// (d)
+		profile, err := lockJSON.Profiles.FindByName("work")
+		if err != nil {
+			t.Fatal("profile 'work' was not created")
+		}
+		expected := []pathutil.ReposPath{"github.com/vim-volt/local.vim", "localhost/local/exists"}
+		if len(profile.ReposPath) != len(expected) {
+			t.Fatalf("expected %v but got %v", expected, profile.ReposPath)
+		}
+		for i := range expected {
+			if profile.ReposPath[i] != expected[i] {
+				t.Errorf("expected %v but got %v", expected, profile.ReposPath)
+			}
+		}
+		if lockJSON.CurrentProfileName != "default" {
+			t.Errorf("current profile was changed: %s", lockJSON.CurrentProfileName)
+		}
+	})
+
+	t.Run("Run `volt import -profile {name} {lock.json}` ({name} already exists)", func(t *testing.T) {
+		setUpLocal(t)
+		file := writeImported(t)
+
+		out, err := testutil.RunVolt("import", "-profile", "default", file)
+		// (!A, !B)
+		testutil.FailExit(t, out, err)
+
+		lockJSON, err := lockjson.Read()
+		if err != nil {
+			t.Fatal("lockjson.Read() returned non-nil error: " + err.Error())
+		}
+		if lockJSON.Repos.Contains("localhost/local/exists") {
+			t.Error("lock.json was changed")
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package subcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func init() {
+	cmdMap["info"] = &infoCmd{}
+}
+
+type infoCmd struct {
+	helped bool
+}
+
+func (cmd *infoCmd) ProhibitRootExecution(args []string) bool { return true }
+
+func (cmd *infoCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt info [-help] {repository} [{repository2} ...]
+
+Quick example
+  $ volt info tyru/caw.vim  # will show the description of tyru/caw.vim
+
+Description
+  Show the description, the category, the tags, and the popularity (GitHub
+  stars) of {repository}, and whether it is installed (and the version, the
+  profiles which have it, and the disk usage). {repository} does not need to
+  be installed.
+
+  The metadata are fetched from the source of "metadata.source" in
+  config.toml (see "volt search -help"), and cached for 7 days in
+  $VOLTPATH/cache/metadata.json. If the source cannot be accessed (e.g.
+  offline), the cached metadata are shown even if they are older.` + "\n\n")
+		//fmt.Println("Options")
+		//fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	return fs
+}
+
+func (cmd *infoCmd) Run(args []string) *Error {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return nil
+	}
+	if len(fs.Args()) == 0 {
+		fs.Usage()
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: {repository} was not given"}
+	}
+	reposPathList := make([]pathutil.ReposPath, 0, len(fs.Args()))
+	for _, arg := range fs.Args() {
+		reposPath, err := pathutil.NormalizeRepos(arg)
+		if err != nil {
+			return &Error{Code: ExitUsage, Msg: "Failed to parse args: " + err.Error()}
+		}
+		reposPathList = append(reposPathList, reposPath)
+	}
+
+	if err := cmd.showInfo(reposPathList); err != nil {
+		return &Error{Code: exitCodeOf(err, ExitGeneral), Msg: "Failed to show info: " + err.Error()}
+	}
+	return nil
+}
+
+func (cmd *infoCmd) showInfo(reposPathList []pathutil.ReposPath) error {
+	index, err := lockjson.ReadIndex()
+	if err != nil {
+		return errors.New("could not read lock.json: " + err.Error())
+	}
+	store, err := openMetadataStore()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := store.save(); err != nil {
+			logger.Warn("Could not write metadata cache: " + err.Error())
+		}
+	}()
+
+	for i, reposPath := range reposPathList {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(reposPath)
+		metadata, err := store.lookup(reposPath)
+		switch {
+		case err != nil:
+			fmt.Println("  (could not get metadata: " + err.Error() + ")")
+		case metadata == nil:
+			fmt.Println("  (metadata was not found)")
+		default:
+			cmd.printField("description", metadata.Description)
+			cmd.printField("category", metadata.Category)
+			cmd.printField("tags", strings.Join(metadata.Tags, ", "))
+			cmd.printField("stars", strconv.Itoa(metadata.Stars))
+			cmd.printField("url", metadata.URL)
+		}
+		cmd.printField("installed", cmd.installedState(index, reposPath))
+	}
+	return nil
+}
+
+// installedState returns "no", or "yes" and the version, the profiles, and
+// the disk usage of reposPath.
+func (*infoCmd) installedState(index *lockjson.Index, reposPath pathutil.ReposPath) string {
+	entry := index.FindByPath(reposPath)
+	if entry == nil {
+		return "no"
+	}
+	var attrs []string
+	if entry.Type == lockjson.ReposGitType && len(entry.Version) >= 7 {
+		attrs = append(attrs, "version "+entry.Version[:7])
+	} else if entry.Type == lockjson.ReposStaticType {
+		attrs = append(attrs, "static repository")
+	}
+	if len(entry.Profiles) > 0 {
+		attrs = append(attrs, "profiles: "+strings.Join(entry.Profiles, ", "))
+	}
+	if entry.Size >= 0 {
+		attrs = append(attrs, "size: "+formatSize(entry.Size))
+	}
+	if len(attrs) == 0 {
+		return "yes"
+	}
+	return "yes (" + strings.Join(attrs, ", ") + ")"
+}
+
+func (*infoCmd) printField(name, value string) {
+	if value != "" {
+		fmt.Printf("  %-12s %s\n", name+":", value)
+	}
+}
@@ -0,0 +1,375 @@
+package subcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/plugconf"
+)
+
+func init() {
+	cmdMap["lint"] = &lintCmd{}
+}
+
+type lintCmd struct {
+	helped bool
+}
+
+func (cmd *lintCmd) ProhibitRootExecution(args []string) bool { return false }
+
+func (cmd *lintCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt lint [-help]
+
+Quick example
+  $ volt lint   # will check plugconf files and lock.json
+
+Description
+  Check plugconf files and lock.json, and show the problems with file names
+  and line numbers:
+    * plugconf files of the plugins which are not installed
+    * s:depends() which returns the plugins which are not installed
+    * the plugins which are not installed in $VOLTPATH/repos
+    * the plugins which have neither configuration in plugconf nor help files
+    * the same mappings defined by two plugconf files (of the plugins in the same profile)
+    * "excmd=..." and "mapping=<Plug>..." of s:loaded_on() which are not
+      defined by the plugin (lazy-loading triggers which never load it)
+    * the plugins installed by other plugin managers (vim-plug, Vundle,
+      dein.vim, minpac, packer.nvim, and pathogen) in vimrc and their
+      directories: the plugins which may be loaded twice because volt also
+      installed them, and the command which installs the others by volt
+
+  This command exits with non-zero status if some problems were found.` + "\n\n")
+		fmt.Println("Options")
+		fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	return fs
+}
+
+func (cmd *lintCmd) Run(args []string) *Error {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return nil
+	}
+	if len(fs.Args()) > 0 {
+		fs.Usage()
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: too many arguments"}
+	}
+
+	problems, err := cmd.doLint()
+	if err != nil {
+		return &Error{Code: ExitGeneral, Msg: err.Error()}
+	}
+	for i := range problems {
+		fmt.Println(problems[i].String())
+	}
+	if len(problems) == 1 {
+		return &Error{Code: ExitValidation, Msg: "Found 1 problem"}
+	} else if len(problems) > 1 {
+		return &Error{Code: ExitValidation, Msg: fmt.Sprintf("Found %d problems", len(problems))}
+	}
+	return nil
+}
+
+// lintProblem is a problem found at line of file.
+// line is 0 if the problem is about the whole file.
+type lintProblem struct {
+	file string
+	line int
+	msg  string
+}
+
+func (p *lintProblem) String() string {
+	if p.line == 0 {
+		return fmt.Sprintf("%s: %s", p.file, p.msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", p.file, p.line, p.msg)
+}
+
+// lintMappingOwner is a plugconf file which defines a mapping.
+type lintMappingOwner struct {
+	reposPath pathutil.ReposPath
+	line      int
+}
+
+func (cmd *lintCmd) doLint() ([]lintProblem, error) {
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return nil, errors.New("could not read lock.json: " + err.Error())
+	}
+	lockJSONContent, err := ioutil.ReadFile(pathutil.LockJSON())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	problems := make([]lintProblem, 0, 16)
+	orphans, err := cmd.lintOrphanPlugconfs(lockJSON)
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, orphans...)
+
+	// mode and lhs -> the plugconf files which define the mapping
+	mappings := make(map[string][]lintMappingOwner, 64)
+	for i := range lockJSON.Repos {
+		reposPath := lockJSON.Repos[i].Path
+		line := lockJSONLine(lockJSONContent, reposPath)
+		installed := pathutil.Exists(reposPath.FullPath())
+		if !installed {
+			problems = append(problems, lintProblem{
+				file: pathutil.LockJSON(), line: line,
+				msg: reposPath.String() + " is not installed (run \"volt get -l\" to install it)",
+			})
+		}
+
+		var info *plugconf.LintInfo
+		path := reposPath.Plugconf()
+		if pathutil.Exists(path) {
+			info, err = plugconf.ParseLintInfo(path)
+			if err != nil {
+				problems = append(problems, lintProblem{file: path, msg: err.Error()})
+				continue
+			}
+		}
+		if installed && (info == nil || info.Empty) && !hasHelpFiles(reposPath) {
+			problems = append(problems, lintProblem{
+				file: pathutil.LockJSON(), line: line,
+				msg: reposPath.String() + " has neither configuration in plugconf nor help files",
+			})
+		}
+		if info == nil {
+			continue
+		}
+
+		for _, dep := range info.Depends {
+			depPath, err := pathutil.NormalizeRepos(dep.Value)
+			if err == nil && !lockJSON.Repos.Contains(depPath) {
+				problems = append(problems, lintProblem{
+					file: path, line: dep.Line,
+					msg: fmt.Sprintf("%s of s:depends() is not installed", dep.Value),
+				})
+			}
+		}
+		if installed {
+			problems = append(problems, cmd.lintTriggers(reposPath, path, info)...)
+		}
+		for _, m := range info.Mappings {
+			for _, mode := range m.Modes {
+				key := string(mode) + " " + m.Lhs
+				mappings[key] = append(mappings[key], lintMappingOwner{reposPath: reposPath, line: m.Line})
+			}
+		}
+	}
+	problems = append(problems, cmd.lintDuplicateMappings(lockJSON, mappings)...)
+	problems = append(problems, cmd.lintOtherManagers(lockJSON)...)
+
+	sort.Slice(problems, func(i, j int) bool {
+		if problems[i].file != problems[j].file {
+			return problems[i].file < problems[j].file
+		}
+		if problems[i].line != problems[j].line {
+			return problems[i].line < problems[j].line
+		}
+		return problems[i].msg < problems[j].msg
+	})
+	return problems, nil
+}
+
+// lintOrphanPlugconfs returns the problems of plugconf files of the plugins
+// which are not installed.
+func (*lintCmd) lintOrphanPlugconfs(lockJSON *lockjson.LockJSON) ([]lintProblem, error) {
+	var problems []lintProblem
+	plugconfDir := filepath.Join(pathutil.VoltPath(), "plugconf")
+	err := filepath.Walk(plugconfDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.IsDir() || filepath.Ext(path) != ".vim" {
+			return nil
+		}
+		rel, err := filepath.Rel(plugconfDir, path)
+		if err != nil {
+			return err
+		}
+		reposPath := pathutil.ReposPath(filepath.ToSlash(strings.TrimSuffix(rel, ".vim")))
+		if !lockJSON.Repos.Contains(reposPath) {
+			problems = append(problems, lintProblem{
+				file: path,
+				msg:  "plugconf of " + reposPath.String() + " which is not installed",
+			})
+		}
+		return nil
+	})
+	return problems, err
+}
+
+// lintTriggers returns the problems of lazy-loading triggers in info which
+// are not defined by the plugin.
+func (*lintCmd) lintTriggers(reposPath pathutil.ReposPath, path string, info *plugconf.LintInfo) []lintProblem {
+	if len(info.Excmds) == 0 && len(info.PlugMappings) == 0 {
+		return nil
+	}
+	var sources []byte
+	filepath.Walk(reposPath.FullPath(), func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if fi.IsDir() {
+			if fi.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(file); ext == ".vim" || ext == ".lua" {
+			if content, err := ioutil.ReadFile(file); err == nil {
+				sources = append(append(sources, content...), '\n')
+			}
+		}
+		return nil
+	})
+
+	var problems []lintProblem
+	for _, excmd := range info.Excmds {
+		rx := regexp.MustCompile(`(?m)(^|\|)\s*com(m(a(n(d)?)?)?)?!?\s+(-\S+\s+)*` + regexp.QuoteMeta(excmd.Value) + `\b` +
+			`|create_user_command\(\s*['"]` + regexp.QuoteMeta(excmd.Value) + `['"]`)
+		if !rx.Match(sources) {
+			problems = append(problems, lintProblem{
+				file: path, line: excmd.Line,
+				msg: fmt.Sprintf("excmd=%s of s:loaded_on() is not defined by %s", excmd.Value, reposPath),
+			})
+		}
+	}
+	for _, lhs := range info.PlugMappings {
+		rx := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(lhs.Value))
+		if !rx.Match(sources) {
+			problems = append(problems, lintProblem{
+				file: path, line: lhs.Line,
+				msg: fmt.Sprintf("mapping=%s of s:loaded_on() is not defined by %s", lhs.Value, reposPath),
+			})
+		}
+	}
+	return problems
+}
+
+// lintDuplicateMappings returns the problems of the mappings which are
+// defined by two or more plugconf files of the plugins in the same profile.
+func (*lintCmd) lintDuplicateMappings(lockJSON *lockjson.LockJSON, mappings map[string][]lintMappingOwner) []lintProblem {
+	var problems []lintProblem
+	reported := make(map[string]bool, len(mappings))
+	for key, owners := range mappings {
+		for i := range owners {
+			for j := i + 1; j < len(owners); j++ {
+				a, b := owners[i], owners[j]
+				if a.reposPath == b.reposPath || !inSameProfile(lockJSON, a.reposPath, b.reposPath) {
+					continue
+				}
+				// Report once for the mapping in all modes
+				lhs := strings.SplitN(key, " ", 2)[1]
+				id := fmt.Sprintf("%s:%d:%s", a.reposPath, a.line, b.reposPath)
+				if reported[id] {
+					continue
+				}
+				reported[id] = true
+				problems = append(problems, lintProblem{
+					file: a.reposPath.Plugconf(), line: a.line,
+					msg: fmt.Sprintf("mapping %s is also defined at %s:%d", lhs, b.reposPath.Plugconf(), b.line),
+				})
+			}
+		}
+	}
+	return problems
+}
+
+// lintOtherManagers returns the problems of the plugins installed by other
+// plugin managers.
+func (*lintCmd) lintOtherManagers(lockJSON *lockjson.LockJSON) []lintProblem {
+	var profileReposPath []pathutil.ReposPath
+	if profile, err := lockJSON.Profiles.FindByName(lockJSON.CurrentProfileName); err == nil {
+		profileReposPath = profile.ReposPath
+	}
+	allReposPath := make([]pathutil.ReposPath, 0, len(lockJSON.Repos))
+	for i := range lockJSON.Repos {
+		allReposPath = append(allReposPath, lockJSON.Repos[i].Path)
+	}
+	var problems []lintProblem
+	for _, m := range detectOtherManagers() {
+		var unmanaged []pathutil.ReposPath
+		for _, reposPath := range m.plugins {
+			if rp, found := samePluginIn(reposPath, profileReposPath); found {
+				problems = append(problems, lintProblem{
+					file: m.source,
+					msg:  fmt.Sprintf("%s is installed by both %s and volt (%s), so it may be loaded twice", reposPath, m.name, rp),
+				})
+			} else if _, found := samePluginIn(reposPath, allReposPath); !found && !pluginManagerNames[path.Base(reposPath.String())] {
+				unmanaged = append(unmanaged, reposPath)
+			}
+		}
+		if len(unmanaged) > 0 {
+			problems = append(problems, lintProblem{
+				file: m.source,
+				msg:  fmt.Sprintf("%s installed by %s but not by volt (run \"%s\" to install by volt)", pluralPlugins(len(unmanaged)), m.name, m.adoptCommand(unmanaged)),
+			})
+		}
+	}
+	return problems
+}
+
+func inSameProfile(lockJSON *lockjson.LockJSON, a, b pathutil.ReposPath) bool {
+	for i := range lockJSON.Profiles {
+		reposPathList := lockJSON.Profiles[i].ReposPath
+		if reposPathList.Contains(a) && reposPathList.Contains(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// rxHelpFile is a pattern which matches to the names of help files
+// ("*.txt" or "*.{lang}x").
+var rxHelpFile = regexp.MustCompile(`\.(txt|[a-z][a-z]x)\z`)
+
+// hasHelpFiles returns true if reposPath has help files in doc directory.
+func hasHelpFiles(reposPath pathutil.ReposPath) bool {
+	files, err := ioutil.ReadDir(filepath.Join(reposPath.FullPath(), "doc"))
+	if err != nil {
+		return false
+	}
+	for _, fi := range files {
+		if !fi.IsDir() && rxHelpFile.MatchString(fi.Name()) {
+			return true
+		}
+	}
+	return false
+}
+
+// lockJSONLine returns the line number of reposPath in content of lock.json,
+// or 0 if it is not found.
+func lockJSONLine(content []byte, reposPath pathutil.ReposPath) int {
+	needle := `"path": "` + reposPath.String() + `"`
+	for i, line := range strings.Split(string(content), "\n") {
+		if strings.Contains(line, needle) {
+			return i + 1
+		}
+	}
+	return 0
+}
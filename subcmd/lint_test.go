@@ -0,0 +1,98 @@
+package subcmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/fileutil"
+	"github.com/vim-volt/volt/internal/testutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+// (C) Shows the problems with file names and line numbers
+
+// * Run `volt lint` (no problems) (A, B)
+// * Run `volt lint` (problems) (!A, !B, C)
+func TestVoltLint(t *testing.T) {
+	t.Run("Run `volt lint` (no problems)", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		reposPath := pathutil.ReposPath("localhost/local/hello")
+		teardown := testutil.SetUpRepos(t, "hello", lockjson.ReposStaticType, []pathutil.ReposPath{reposPath}, config.SymlinkBuilder)
+		defer teardown()
+		writeLintPlugconf(t, reposPath, "function! s:on_load_pre()\n  let g:hello = 1\nendfunction\n")
+
+		out, err := testutil.RunVolt("lint")
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		if len(out) > 0 {
+			t.Errorf("expected no output but got: %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt lint` (problems)", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		reposPath := pathutil.ReposPath("localhost/local/hello")
+		teardown := testutil.SetUpRepos(t, "hello", lockjson.ReposStaticType, []pathutil.ReposPath{reposPath}, config.SymlinkBuilder)
+		defer teardown()
+		reposPath2 := pathutil.ReposPath("localhost/local/hello2")
+		if err := fileutil.CopyDir(reposPath.FullPath(), reposPath2.FullPath(), make([]byte, 32*1024), 0777, 0); err != nil {
+			t.Fatal(err)
+		}
+		out, err := testutil.RunVolt("get", reposPath2.String())
+		testutil.SuccessExit(t, out, err)
+
+		writeLintPlugconf(t, reposPath, `function! s:on_load_pre()
+  nnoremap <C-h> :<C-u>echo 'hello'<CR>
+endfunction
+
+function! s:loaded_on()
+  return 'excmd=HelloNotDefined'
+endfunction
+
+function! s:depends()
+  return ['localhost/local/missing']
+endfunction
+`)
+		writeLintPlugconf(t, reposPath2, `function! s:on_load_pre()
+  let g:hello2 = 1
+  noremap <C-h> :<C-u>echo 'hello2'<CR>
+endfunction
+`)
+		orphanPath := pathutil.ReposPath("localhost/local/orphan")
+		writeLintPlugconf(t, orphanPath, "")
+
+		out, err = testutil.RunVolt("lint")
+		// (!A, !B)
+		testutil.FailExit(t, out, err)
+
+		// (C)
+		for _, expected := range []string{
+			fmt.Sprintf("%s:2: mapping <C-h> is also defined at %s:3", reposPath.Plugconf(), reposPath2.Plugconf()),
+			fmt.Sprintf("%s:6: excmd=HelloNotDefined of s:loaded_on() is not defined by %s", reposPath.Plugconf(), reposPath),
+			fmt.Sprintf("%s:10: localhost/local/missing of s:depends() is not installed", reposPath.Plugconf()),
+			fmt.Sprintf("%s: plugconf of %s which is not installed", orphanPath.Plugconf(), orphanPath),
+		} {
+			if !strings.Contains(string(out), expected) {
+				t.Errorf("expected %q but got: %s", expected, string(out))
+			}
+		}
+	})
+}
+
+func writeLintPlugconf(t *testing.T, reposPath pathutil.ReposPath, content string) {
+	t.Helper()
+	path := reposPath.Plugconf()
+	os.MkdirAll(filepath.Dir(path), 0777)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"text/template"
 
 	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
 )
 
 func init() {
@@ -18,6 +20,8 @@ func init() {
 type listCmd struct {
 	helped bool
 	format string
+	// metadata is opened when "metadata" template function is called
+	metadata *metadataStore
 }
 
 func (cmd *listCmd) ProhibitRootExecution(args []string) bool { return false }
@@ -45,6 +49,10 @@ Quick example
 
   $ volt list -f '{{ range currentProfile.ReposPath }}{{ println . }}{{ end }}'
 
+  Show repositories used by current profile with their descriptions:
+
+  $ volt list -f '{{ range currentProfile.ReposPath }}{{ . }}: {{ println (metadata .).Description }}{{ end }}'
+
 Template functions
 
   json value [prefix [indent]] (string)
@@ -60,6 +68,11 @@ Template functions
   version (string)
     Returns volt version string. format is "v{major}.{minor}.{patch}" (e.g. "v0.3.0")
 
+  metadata path (Metadata)
+    Returns the metadata of given repository path (see "volt search -help").
+    Metadata has the properties "Description", "Category", "Tags",
+    "Stars", and "URL". They are empty if the metadata was not found.
+
   versionMajor (number)
     Returns volt major version
 
@@ -132,14 +145,14 @@ func (cmd *listCmd) Run(args []string) *Error {
 		return nil
 	}
 	if err := cmd.list(cmd.format); err != nil {
-		return &Error{Code: 10, Msg: "Failed to render template: " + err.Error()}
+		return &Error{Code: ExitUsage, Msg: "Failed to render template: " + err.Error()}
 	}
 	return nil
 }
 
 func (cmd *listCmd) list(format string) error {
 	// Read lock.json
-	lockJSON, err := lockjson.Read()
+	lockJSON, err := lockjson.ReadOnly()
 	if err != nil {
 		return errors.New("failed to read lock.json: " + err.Error())
 	}
@@ -149,10 +162,18 @@ func (cmd *listCmd) list(format string) error {
 		return err
 	}
 	// Output templated information
-	return t.Execute(os.Stdout, lockJSON)
+	if err = t.Execute(os.Stdout, lockJSON); err != nil {
+		return err
+	}
+	if cmd.metadata != nil {
+		if err = cmd.metadata.save(); err != nil {
+			logger.Warn("Could not write metadata cache: " + err.Error())
+		}
+	}
+	return nil
 }
 
-func (*listCmd) funcMap(lockJSON *lockjson.LockJSON) template.FuncMap {
+func (cmd *listCmd) funcMap(lockJSON *lockjson.LockJSON) template.FuncMap {
 	profileOf := func(name string) *lockjson.Profile {
 		profile, err := lockJSON.Profiles.FindByName(name)
 		if err != nil {
@@ -178,6 +199,23 @@ func (*listCmd) funcMap(lockJSON *lockjson.LockJSON) template.FuncMap {
 			return profileOf(lockJSON.CurrentProfileName)
 		},
 		"profile": profileOf,
+		"metadata": func(reposPath pathutil.ReposPath) (*pluginMetadata, error) {
+			if cmd.metadata == nil {
+				store, err := openMetadataStore()
+				if err != nil {
+					return nil, err
+				}
+				cmd.metadata = store
+			}
+			metadata, err := cmd.metadata.lookup(reposPath)
+			if err != nil {
+				logger.Debugf("Could not get metadata of %s: %s", reposPath, err.Error())
+			}
+			if metadata == nil {
+				return &pluginMetadata{Repos: reposPath}, nil
+			}
+			return metadata, nil
+		},
 		"version": func() string {
 			return voltVersion
 		},
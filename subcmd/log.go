@@ -0,0 +1,178 @@
+package subcmd
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/transaction"
+)
+
+func init() {
+	cmdMap["log"] = &logCmd{}
+}
+
+type logCmd struct {
+	helped bool
+	prune  bool
+	format string
+}
+
+// messageFlagUsage is the usage of -m option of the commands which begin
+// transactions.
+const messageFlagUsage = "the reason of the change, which is shown by \"volt log\""
+
+func (cmd *logCmd) ProhibitRootExecution(args []string) bool { return true }
+
+func (cmd *logCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt log [-help] [-prune] [-format {text|json}]
+
+Quick example
+  $ volt log               # will show the history of transactions
+  $ volt log -format json  # will show the history as JSON for auditing
+  $ volt log -prune        # will remove old transactions from the history
+
+Description
+  Show the committed transactions (the commands which changed repositories or
+  lock.json) from the newest one, with the operations of each transaction.
+  The reason given by -m option of the commands (e.g. "volt get -m {message}")
+  is shown under each transaction.
+
+  If -format json is given, the transactions are shown as an array of JSON
+  objects from the newest one. Each object has the following keys:
+    * "id", "pid", "args", "message": the transaction and the command
+    * "started_at", "committed_at": the time in RFC 3339 format
+    * "ops": the operations ("install", "upgrade", and "remove")
+    * "repos": the changes of lock.json, which have "change" ("add",
+      "update", or "remove"), "path", "from" (the previous version),
+      and "to" (the new version)
+
+  The history is kept in $VOLTPATH/trx/ with the repositories and files
+  removed by the transactions. Old transactions are pruned when a transaction
+  is committed, or by -prune option, according to [log] section of
+  config.toml:
+    * "max_count":    the maximum number of transactions (default: 50)
+    * "max_age_days": the maximum age of transactions in days (default: 90)
+    * "max_size_mb":  the maximum total size in MB (default: 1024)
+  0 means unlimited.` + "\n\n")
+		fmt.Println("Options")
+		fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	fs.BoolVar(&cmd.prune, "prune", false, "remove old transactions according to config.toml")
+	fs.StringVar(&cmd.format, "format", "text", "output format (text or json)")
+	return fs
+}
+
+func (cmd *logCmd) Run(args []string) *Error {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return nil
+	}
+	if len(fs.Args()) > 0 {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: volt log does not receive arguments"}
+	}
+	if cmd.format != "text" && cmd.format != "json" {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: -format must be text or json: " + cmd.format}
+	}
+
+	var err error
+	if cmd.prune {
+		err = cmd.doPrune()
+	} else if cmd.format == "json" {
+		err = cmd.doLogJSON()
+	} else {
+		err = cmd.doLog()
+	}
+	if err != nil {
+		return &Error{Code: ExitGeneral, Msg: err.Error()}
+	}
+	return nil
+}
+
+func (cmd *logCmd) doLog() error {
+	journals, err := transaction.History()
+	if err != nil {
+		return errors.New("could not read the history: " + err.Error())
+	}
+	for i := range journals {
+		j := &journals[i]
+		date := j.StartedAt
+		if j.CommittedAt != nil {
+			date = *j.CommittedAt
+		}
+		fmt.Printf("#%d %s volt %s\n", j.ID, date.Format("2006-01-02 15:04:05"), strings.Join(j.Args, " "))
+		if j.Message != "" {
+			fmt.Println("    " + j.Message)
+		}
+		for _, op := range j.Ops {
+			fmt.Println("  " + cmd.formatOp(&op))
+		}
+	}
+	return nil
+}
+
+func (cmd *logCmd) doLogJSON() error {
+	journals, err := transaction.History()
+	if err != nil {
+		return errors.New("could not read the history: " + err.Error())
+	}
+	records := make([]*transaction.AuditRecord, 0, len(journals))
+	for i := range journals {
+		records = append(records, journals[i].Audit())
+	}
+	content, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(content))
+	return nil
+}
+
+// formatOp returns the description of op.
+// Paths are shown relative to $VOLTPATH.
+func (*logCmd) formatOp(op *transaction.Op) string {
+	path := relVoltPath(op.Path)
+	switch op.Type {
+	case transaction.OpUpgrade:
+		version := op.Version
+		if len(version) > 7 {
+			version = version[:7]
+		}
+		return fmt.Sprintf("upgrade %s (from %s)", op.ReposPath, version)
+	default:
+		return op.Type + " " + path
+	}
+}
+
+func (cmd *logCmd) doPrune() error {
+	cfg, err := config.Read()
+	if err != nil {
+		return errors.New("could not read config.toml: " + err.Error())
+	}
+
+	// Begin transaction
+	err = transaction.Create()
+	if err != nil {
+		return err
+	}
+	defer transaction.Remove()
+
+	count, size, err := transaction.Prune(cfg)
+	if err != nil {
+		return errors.New("could not prune the history: " + err.Error())
+	}
+	fmt.Printf("Pruned %d transactions (%s)\n", count, formatSize(size))
+	return transaction.Commit()
+}
@@ -0,0 +1,129 @@
+package subcmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/internal/testutil"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/transaction"
+)
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+
+// * Run `volt log` after `volt profile new` (A, B)
+//   * Shows the transaction of `volt profile new`
+// * Run `volt log` with `log.max_count = 1` (A, B)
+//   * Shows only the newest transaction
+// * Run `volt log -prune` (A, B)
+// * Run `volt log` after `volt profile -m <message> new` (A, B)
+//   * Shows the message
+// * Run `volt log` after the history was removed (A, B)
+//   * The ID of removed transaction is not reused
+// * Run `volt log -format json` after `volt get -m <message> <repos>` (A, B)
+//   * Shows the transaction and the change of lock.json as JSON
+func TestVoltLog(t *testing.T) {
+	t.Run("Run `volt log` after `volt profile new`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		out, err := testutil.RunVolt("profile", "new", "foo")
+		testutil.SuccessExit(t, out, err)
+
+		out, err = testutil.RunVolt("log")
+		testutil.SuccessExit(t, out, err)
+		if !strings.HasPrefix(string(out), "#1 ") || !strings.Contains(string(out), "volt profile new foo") {
+			t.Errorf("unexpected output: %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt log` with `log.max_count = 1`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		out, err := testutil.RunVolt("config", "set", "log.max_count", "1")
+		testutil.SuccessExit(t, out, err)
+		for _, name := range []string{"foo", "bar"} {
+			out, err = testutil.RunVolt("profile", "new", name)
+			testutil.SuccessExit(t, out, err)
+		}
+
+		out, err = testutil.RunVolt("log")
+		testutil.SuccessExit(t, out, err)
+		if !strings.Contains(string(out), "volt profile new bar") || strings.Contains(string(out), "volt profile new foo") {
+			t.Errorf("unexpected output: %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt log -prune`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		out, err := testutil.RunVolt("profile", "new", "foo")
+		testutil.SuccessExit(t, out, err)
+
+		out, err = testutil.RunVolt("log", "-prune")
+		testutil.SuccessExit(t, out, err)
+		if !strings.Contains(string(out), "Pruned 0 transactions") {
+			t.Errorf("unexpected output: %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt log` after `volt profile -m <message> new`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		out, err := testutil.RunVolt("profile", "-m", "for work", "new", "foo")
+		testutil.SuccessExit(t, out, err)
+
+		out, err = testutil.RunVolt("log")
+		testutil.SuccessExit(t, out, err)
+		if !strings.Contains(string(out), "\n    for work\n") {
+			t.Errorf("message is not shown: %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt log` after the history was removed", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		out, err := testutil.RunVolt("profile", "new", "foo")
+		testutil.SuccessExit(t, out, err)
+		if err = os.RemoveAll(pathutil.TrxHistoryDir()); err != nil {
+			t.Fatal(err)
+		}
+		out, err = testutil.RunVolt("profile", "new", "bar")
+		testutil.SuccessExit(t, out, err)
+
+		out, err = testutil.RunVolt("log")
+		testutil.SuccessExit(t, out, err)
+		if !strings.HasPrefix(string(out), "#2 ") {
+			t.Errorf("unexpected output: %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt log -format json` after `volt get -m <message> <repos>`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		reposPath := pathutil.ReposPath("localhost/local/hello")
+		if err := os.MkdirAll(filepath.Join(reposPath.FullPath(), "plugin"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		out, err := testutil.RunVolt("get", "-m", "for audit", "localhost/local/hello")
+		testutil.SuccessExit(t, out, err)
+
+		out, err = testutil.RunVolt("log", "-format", "json")
+		testutil.SuccessExit(t, out, err)
+		var records []transaction.AuditRecord
+		if err = json.Unmarshal(out, &records); err != nil {
+			t.Fatalf("could not parse output: %s: %s", err, string(out))
+		}
+		if len(records) != 1 {
+			t.Fatalf("expected 1 transaction but got %d: %s", len(records), string(out))
+		}
+		r := records[0]
+		if r.ID != 1 || r.CommittedAt == nil || r.Message != "for audit" ||
+			!reflect.DeepEqual(r.Args, []string{"get", "-m", "for audit", "localhost/local/hello"}) {
+			t.Errorf("unexpected transaction: %s", string(out))
+		}
+		expected := []transaction.ReposChange{{Change: transaction.ReposAdded, Path: reposPath}}
+		if !reflect.DeepEqual(r.Repos, expected) {
+			t.Errorf("expected repos %+v but got %+v", expected, r.Repos)
+		}
+	})
+}
@@ -0,0 +1,320 @@
+package subcmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/httputil"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// pluginMetadata is the description, the category, and the popularity of a
+// plugin. It is also the format of the entries of JSON index
+// ("metadata.source" of config.toml).
+type pluginMetadata struct {
+	Repos       pathutil.ReposPath `json:"repos"`
+	Description string             `json:"description"`
+	Category    string             `json:"category,omitempty"`
+	Tags        []string           `json:"tags,omitempty"`
+	Stars       int                `json:"stars"`
+	URL         string             `json:"url,omitempty"`
+}
+
+// metadataProvider fetches the metadata of plugins.
+type metadataProvider interface {
+	// lookup returns the metadata of reposPath, or nil if it is not found.
+	lookup(reposPath pathutil.ReposPath) (*pluginMetadata, error)
+	// search returns the metadata of plugins which match query.
+	search(query string) ([]pluginMetadata, error)
+}
+
+// errMetadataOffline is returned by the provider of "metadata.source = none".
+var errMetadataOffline = errors.New("fetching metadata is disabled by metadata.source in config.toml")
+
+// newMetadataProvider returns the provider of source ("metadata.source" of
+// config.toml).
+func newMetadataProvider(source string) metadataProvider {
+	switch source {
+	case config.MetadataVimAwesome:
+		return &vimAwesomeProvider{apiURL: vimAwesomeAPIURL}
+	case config.MetadataNone:
+		return offlineProvider{}
+	default:
+		return &indexProvider{source: source}
+	}
+}
+
+// vimAwesomeAPIURL is the endpoint of VimAwesome API.
+var vimAwesomeAPIURL = "https://vimawesome.com/api/plugins"
+
+// vimAwesomeProvider fetches the metadata from VimAwesome API.
+type vimAwesomeProvider struct {
+	apiURL string
+}
+
+// vimAwesomePlugin is a plugin in the response of VimAwesome API.
+type vimAwesomePlugin struct {
+	Slug           string   `json:"slug"`
+	ShortDesc      string   `json:"short_desc"`
+	Category       string   `json:"category"`
+	Tags           []string `json:"tags"`
+	GithubOwner    string   `json:"github_owner"`
+	GithubRepoName string   `json:"github_repo_name"`
+	GithubStars    int      `json:"github_stars"`
+}
+
+func (p *vimAwesomeProvider) search(query string) ([]pluginMetadata, error) {
+	content, err := httputil.GetContent(p.apiURL + "?query=" + url.QueryEscape(query) + "&page=1")
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Plugins []vimAwesomePlugin `json:"plugins"`
+	}
+	if err = json.Unmarshal(content, &res); err != nil {
+		return nil, errors.New("could not parse the response of VimAwesome: " + err.Error())
+	}
+	result := make([]pluginMetadata, 0, len(res.Plugins))
+	for _, plugin := range res.Plugins {
+		if plugin.GithubOwner == "" || plugin.GithubRepoName == "" {
+			continue
+		}
+		reposPath, err := pathutil.NormalizeRepos(plugin.GithubOwner + "/" + plugin.GithubRepoName)
+		if err != nil {
+			continue
+		}
+		result = append(result, pluginMetadata{
+			Repos:       reposPath,
+			Description: plugin.ShortDesc,
+			Category:    plugin.Category,
+			Tags:        plugin.Tags,
+			Stars:       plugin.GithubStars,
+			URL:         "https://vimawesome.com/plugin/" + plugin.Slug,
+		})
+	}
+	return result, nil
+}
+
+// lookup searches the name of reposPath, and returns the plugin of the same
+// repository. Only GitHub repositories are found.
+func (p *vimAwesomeProvider) lookup(reposPath pathutil.ReposPath) (*pluginMetadata, error) {
+	if !strings.HasPrefix(reposPath.String(), "github.com/") {
+		return nil, nil
+	}
+	result, err := p.search(reposPath.String()[strings.LastIndex(reposPath.String(), "/")+1:])
+	if err != nil {
+		return nil, err
+	}
+	for i := range result {
+		if strings.EqualFold(result[i].Repos.String(), reposPath.String()) {
+			return &result[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// indexProvider reads the metadata from JSON index (an array of
+// pluginMetadata) of URL or local file.
+type indexProvider struct {
+	source string
+	index  []pluginMetadata
+}
+
+func (p *indexProvider) load() error {
+	if p.index != nil {
+		return nil
+	}
+	var content []byte
+	var err error
+	if strings.HasPrefix(p.source, "https://") || strings.HasPrefix(p.source, "http://") {
+		content, err = httputil.GetContent(p.source)
+	} else {
+		content, err = ioutil.ReadFile(p.source)
+	}
+	if err != nil {
+		return err
+	}
+	var index []pluginMetadata
+	if err = json.Unmarshal(content, &index); err != nil {
+		return errors.New("could not parse " + p.source + ": " + err.Error())
+	}
+	p.index = make([]pluginMetadata, 0, len(index))
+	for i := range index {
+		reposPath, err := pathutil.NormalizeRepos(index[i].Repos.String())
+		if err != nil {
+			logger.Debugf("Skipping invalid repository in %s: %s", p.source, err.Error())
+			continue
+		}
+		index[i].Repos = reposPath
+		p.index = append(p.index, index[i])
+	}
+	return nil
+}
+
+func (p *indexProvider) lookup(reposPath pathutil.ReposPath) (*pluginMetadata, error) {
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	for i := range p.index {
+		if p.index[i].Repos == reposPath {
+			return &p.index[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *indexProvider) search(query string) ([]pluginMetadata, error) {
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return searchMetadata(p.index, query), nil
+}
+
+// offlineProvider does not fetch anything.
+type offlineProvider struct{}
+
+func (offlineProvider) lookup(pathutil.ReposPath) (*pluginMetadata, error) {
+	return nil, errMetadataOffline
+}
+
+func (offlineProvider) search(string) ([]pluginMetadata, error) {
+	return nil, errMetadataOffline
+}
+
+// searchMetadata returns the plugins in list whose repository path,
+// description, category, or tags contain query (case-insensitive).
+// The result is sorted by stars.
+func searchMetadata(list []pluginMetadata, query string) []pluginMetadata {
+	query = strings.ToLower(query)
+	var result []pluginMetadata
+	for i := range list {
+		text := strings.ToLower(strings.Join(append([]string{
+			list[i].Repos.String(), list[i].Description, list[i].Category,
+		}, list[i].Tags...), "\n"))
+		if strings.Contains(text, query) {
+			result = append(result, list[i])
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Stars > result[j].Stars
+	})
+	return result
+}
+
+// metadataCacheTTL is the duration while the cached metadata is used without
+// fetching it again.
+const metadataCacheTTL = 7 * 24 * time.Hour
+
+// metadataCache is the content of $VOLTPATH/cache/metadata.json.
+type metadataCache struct {
+	// Source is "metadata.source" of config.toml when the metadata were
+	// fetched
+	Source  string                                     `json:"source"`
+	Plugins map[pathutil.ReposPath]*metadataCacheEntry `json:"plugins"`
+}
+
+type metadataCacheEntry struct {
+	// Metadata is nil if the plugin was not found
+	Metadata  *pluginMetadata `json:"metadata"`
+	FetchedAt time.Time       `json:"fetched_at"`
+}
+
+// metadataStore returns the metadata of plugins from the cache or the
+// provider. The cache is used when the provider fails (e.g. offline).
+type metadataStore struct {
+	provider metadataProvider
+	cache    *metadataCache
+	changed  bool
+	now      time.Time
+}
+
+// openMetadataStore reads config.toml and the cache.
+func openMetadataStore() (*metadataStore, error) {
+	cfg, err := config.Read()
+	if err != nil {
+		return nil, errors.New("could not read config.toml: " + err.Error())
+	}
+	store := &metadataStore{
+		provider: newMetadataProvider(cfg.Metadata.Source),
+		cache:    &metadataCache{Source: cfg.Metadata.Source},
+		now:      time.Now(),
+	}
+	var cache metadataCache
+	if content, err := ioutil.ReadFile(pathutil.MetadataCacheJSON()); err == nil {
+		if json.Unmarshal(content, &cache) == nil && (cache.Source == cfg.Metadata.Source || cfg.Metadata.Source == config.MetadataNone) {
+			store.cache.Plugins = cache.Plugins
+		}
+	}
+	if store.cache.Plugins == nil {
+		store.cache.Plugins = make(map[pathutil.ReposPath]*metadataCacheEntry)
+	}
+	return store, nil
+}
+
+// lookup returns the metadata of reposPath, or nil if it is not found.
+// If the provider fails, the cached metadata is returned even if it is
+// older than metadataCacheTTL.
+func (s *metadataStore) lookup(reposPath pathutil.ReposPath) (*pluginMetadata, error) {
+	entry := s.cache.Plugins[reposPath]
+	if entry != nil && s.now.Sub(entry.FetchedAt) < metadataCacheTTL {
+		return entry.Metadata, nil
+	}
+	metadata, err := s.provider.lookup(reposPath)
+	if err != nil {
+		if entry != nil {
+			logger.Debugf("Using cached metadata of %s: %s", reposPath, err.Error())
+			return entry.Metadata, nil
+		}
+		return nil, err
+	}
+	s.put(reposPath, metadata)
+	return metadata, nil
+}
+
+// search returns the plugins which match query. If the provider fails, the
+// cached metadata are searched.
+func (s *metadataStore) search(query string) ([]pluginMetadata, error) {
+	result, err := s.provider.search(query)
+	if err != nil {
+		cached := make([]pluginMetadata, 0, len(s.cache.Plugins))
+		for _, entry := range s.cache.Plugins {
+			if entry.Metadata != nil {
+				cached = append(cached, *entry.Metadata)
+			}
+		}
+		sort.Slice(cached, func(i, j int) bool { return cached[i].Repos < cached[j].Repos })
+		logger.Warn("Searching cached metadata: " + err.Error())
+		return searchMetadata(cached, query), nil
+	}
+	for i := range result {
+		s.put(result[i].Repos, &result[i])
+	}
+	return result, nil
+}
+
+func (s *metadataStore) put(reposPath pathutil.ReposPath, metadata *pluginMetadata) {
+	s.cache.Plugins[reposPath] = &metadataCacheEntry{Metadata: metadata, FetchedAt: s.now}
+	s.changed = true
+}
+
+// save writes the cache if it was changed.
+func (s *metadataStore) save() error {
+	if !s.changed {
+		return nil
+	}
+	content, err := json.MarshalIndent(s.cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(pathutil.MetadataCacheJSON()), 0755)
+	return ioutil.WriteFile(pathutil.MetadataCacheJSON(), content, 0644)
+}
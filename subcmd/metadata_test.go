@@ -0,0 +1,76 @@
+package subcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func TestIndexProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	index := filepath.Join(dir, "index.json")
+	content := `[
+  {"repos": "tyru/caw.vim", "description": "Vim comment plugin", "category": "other", "tags": ["comment"], "stars": 300},
+  {"repos": "tpope/vim-commentary", "description": "comment stuff out", "stars": 5000},
+  {"repos": "tyru/open-browser.vim", "description": "Open URI with your favorite browser", "stars": 700}
+]`
+	if err := ioutil.WriteFile(index, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newMetadataProvider(index)
+	metadata, err := p.lookup("github.com/tyru/caw.vim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata == nil || metadata.Description != "Vim comment plugin" || metadata.Stars != 300 {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+	if metadata, err = p.lookup("github.com/a/none"); err != nil || metadata != nil {
+		t.Errorf("expected nil but got %+v (%v)", metadata, err)
+	}
+
+	result, err := p.search("COMMENT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 || result[0].Repos != "github.com/tpope/vim-commentary" || result[1].Repos != "github.com/tyru/caw.vim" {
+		t.Errorf("expected the plugins sorted by stars but got %+v", result)
+	}
+}
+
+func TestMetadataStoreOffline(t *testing.T) {
+	now := time.Now()
+	cached := &pluginMetadata{Repos: "github.com/tyru/caw.vim", Description: "Vim comment plugin"}
+	store := &metadataStore{
+		provider: offlineProvider{},
+		cache: &metadataCache{Plugins: map[pathutil.ReposPath]*metadataCacheEntry{
+			"github.com/tyru/caw.vim": {Metadata: cached, FetchedAt: now.Add(-30 * 24 * time.Hour)},
+		}},
+		now: now,
+	}
+
+	// Stale cache is used if the provider fails
+	metadata, err := store.lookup("github.com/tyru/caw.vim")
+	if err != nil || metadata != cached {
+		t.Errorf("expected cached metadata but got %+v (%v)", metadata, err)
+	}
+	if _, err = store.lookup("github.com/a/none"); err != errMetadataOffline {
+		t.Errorf("expected errMetadataOffline but got %v", err)
+	}
+	result, err := store.search("comment")
+	if err != nil || len(result) != 1 || result[0].Repos != cached.Repos {
+		t.Errorf("expected cached metadata but got %+v (%v)", result, err)
+	}
+	if store.changed {
+		t.Error("expected the cache is not changed")
+	}
+}
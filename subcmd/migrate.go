@@ -37,7 +37,7 @@ func (cmd *migrateCmd) FlagSet() *flag.FlagSet {
 		}
 
 		fmt.Println(`Usage
-  volt migrate [-help] {migration operation}
+  volt migrate [-help] {migration operation} [{args}]
 
 Description
   Perform miscellaneous migration operations.
@@ -56,33 +56,37 @@ Available operations`)
 }
 
 func (cmd *migrateCmd) Run(args []string) *Error {
-	op, err := cmd.parseArgs(args)
+	op, opArgs, err := cmd.parseArgs(args)
 	if err == ErrShowedHelp {
 		return nil
 	}
 	if err != nil {
-		return &Error{Code: 10, Msg: "Failed to parse args: " + err.Error()}
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: " + err.Error()}
 	}
 
-	if err := op.Migrate(); err != nil {
-		return &Error{Code: 11, Msg: "Failed to migrate: " + err.Error()}
+	if err := op.Migrate(opArgs); err != nil {
+		if e, ok := err.(*Error); ok {
+			return e
+		}
+		return &Error{Code: exitCodeOf(err, ExitGeneral), Msg: "Failed to migrate: " + err.Error()}
 	}
 
 	logger.Infof("'%s' was successfully migrated!", op.Name())
 	return nil
 }
 
-func (cmd *migrateCmd) parseArgs(args []string) (migrate.Migrater, error) {
+func (cmd *migrateCmd) parseArgs(args []string) (migrate.Migrater, []string, error) {
 	fs := cmd.FlagSet()
 	fs.Parse(args)
 	if cmd.helped {
-		return nil, ErrShowedHelp
+		return nil, nil, ErrShowedHelp
 	}
 	args = fs.Args()
 	if len(args) == 0 {
-		return nil, errors.New("please specify migration operation")
+		return nil, nil, errors.New("please specify migration operation")
 	}
-	return migrate.GetMigrater(args[0])
+	m, err := migrate.GetMigrater(args[0])
+	return m, args[1:], err
 }
 
 func (cmd *migrateCmd) showAvailableOps(write func(string)) {
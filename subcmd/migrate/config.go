@@ -0,0 +1,39 @@
+package migrate
+
+import (
+	"errors"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/logger"
+)
+
+func init() {
+	m := &configMigrater{}
+	migrateOps[m.Name()] = m
+}
+
+type configMigrater struct{}
+
+func (*configMigrater) Name() string {
+	return "config"
+}
+
+func (m *configMigrater) Description(brief bool) string {
+	if brief {
+		return "converts old config.toml (and config.local.toml) format to the latest format"
+	}
+	return `Usage
+  volt migrate [-help] ` + m.Name() + `
+
+Description
+  Perform migration of $VOLTPATH/config.toml, which means volt converts old version config.toml structure (e.g. renamed keys) into the latest version, and writes "version" of the latest version. $VOLTPATH/config.local.toml is also migrated if it exists. This is always done automatically in memory when reading config.toml, and the warning is shown until config.toml is migrated by this command.
+  NOTE: Comments in config.toml and config.local.toml are not kept.`
+}
+
+func (*configMigrater) Migrate(args []string) error {
+	if err := config.Migrate(); err != nil {
+		return errors.New("could not migrate config.toml: " + err.Error())
+	}
+	logger.Info("Migrated config.toml to the latest version")
+	return nil
+}
@@ -31,7 +31,7 @@ Description
   To suppress this, running this command simply reads and writes migrated structure to lock.json.`
 }
 
-func (*lockjsonMigrater) Migrate() error {
+func (*lockjsonMigrater) Migrate(args []string) error {
 	// Read lock.json
 	lockJSON, err := lockjson.ReadNoMigrationMsg()
 	if err != nil {
@@ -50,5 +50,5 @@ func (*lockjsonMigrater) Migrate() error {
 	if err != nil {
 		return errors.New("could not write to lock.json: " + err.Error())
 	}
-	return nil
+	return transaction.Commit()
 }
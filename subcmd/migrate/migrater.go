@@ -6,14 +6,21 @@ import (
 )
 
 // Migrater migrates many kinds of data.
+// args are the arguments after the name of migration operation.
 type Migrater interface {
-	Migrate() error
+	Migrate(args []string) error
 	Name() string
 	Description(brief bool) string
 }
 
 var migrateOps = make(map[string]Migrater)
 
+// Register registers m as a migration operation. This is used by the
+// packages which this package cannot import (e.g. subcmd package).
+func Register(m Migrater) {
+	migrateOps[m.Name()] = m
+}
+
 // GetMigrater gets Migrater of specified name.
 func GetMigrater(name string) (Migrater, error) {
 	m, exists := migrateOps[name]
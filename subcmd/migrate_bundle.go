@@ -0,0 +1,114 @@
+package subcmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/vim-volt/volt/gitutil"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/subcmd/migrate"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+func init() {
+	migrate.Register(&pluginsMigrater{
+		name:  "from-bundle",
+		brief: "adopts the plugins in pathogen's bundle directory or Vim packages",
+		usage: `Usage
+  volt migrate [-help] from-bundle [{dir} ...]
+
+Quick example
+  $ volt migrate from-bundle                    # will adopt ~/.vim/bundle/* and ~/.vim/pack/*/start/*
+  $ volt migrate from-bundle ~/dotfiles/bundle  # will adopt ~/dotfiles/bundle/*
+
+Description
+  Move the plugins in {dir} (~/.vim/bundle and ~/.vim/pack/*/start by default)
+  to $VOLTPATH/repos, and add them to lock.json by "volt get". The plugins are
+  not downloaded again:
+
+  * Git repositories are moved to $VOLTPATH/repos/{host}/{user}/{name} of
+    their remote URL, and the current commit is recorded to lock.json
+  * The other directories are moved to $VOLTPATH/repos/localhost/local/{name}
+    as static repositories
+
+  ~/.vim/pack/volt is not adopted. Git submodules (e.g. the plugins in
+  dotfiles repository), and the directories which already exist in
+  $VOLTPATH/repos are not moved, and they are shown after the plugins were
+  installed.`,
+		parse:       parseBundle,
+		defaultArgs: bundleDirs,
+	})
+}
+
+// bundleDirs returns ~/.vim/bundle and ~/.vim/pack/*/start directories except
+// ~/.vim/pack/volt/start.
+func bundleDirs() []string {
+	var dirs []string
+	if dir := filepath.Join(pathutil.VimDir(), "bundle"); pathutil.Exists(dir) {
+		dirs = append(dirs, dir)
+	}
+	starts, _ := filepath.Glob(filepath.Join(pathutil.VimDir(), "pack", "*", "start"))
+	for _, dir := range starts {
+		if dir != pathutil.VimVoltStartDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// parseBundle returns the plugins in the directories of paths.
+func parseBundle(paths []string) ([]importedPlugin, []string, error) {
+	var plugins []importedPlugin
+	var untranslated []string
+	for _, dir := range paths {
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, fi := range infos {
+			if !fi.IsDir() {
+				continue
+			}
+			src := filepath.Join(dir, fi.Name())
+			reposPath, err := bundleReposPath(src)
+			if err != nil {
+				untranslated = append(untranslated, src+": "+err.Error())
+				continue
+			}
+			plugins = append(plugins, importedPlugin{reposPath: reposPath, srcDir: src})
+		}
+	}
+	return plugins, untranslated, nil
+}
+
+// bundleReposPath returns the repository path of the plugin directory src: the
+// remote URL of git repository, or "localhost/local/{name}".
+func bundleReposPath(src string) (pathutil.ReposPath, error) {
+	fi, err := os.Stat(filepath.Join(src, ".git"))
+	if err != nil {
+		return pathutil.NormalizeLocalRepos(filepath.Base(src))
+	}
+	if !fi.IsDir() {
+		return "", errors.New("git submodule is not moved")
+	}
+	r, err := git.PlainOpen(src)
+	if err != nil {
+		return "", err
+	}
+	remote, err := gitutil.GetUpstreamRemote(r)
+	if err != nil {
+		remote = "origin"
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return "", err
+	}
+	remoteCfg, exists := cfg.Remotes[remote]
+	if !exists || len(remoteCfg.URLs) == 0 {
+		return "", fmt.Errorf("remote '%s' is not found", remote)
+	}
+	return normalizeRemoteURL(remoteCfg.URLs[0])
+}
@@ -0,0 +1,63 @@
+package subcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/pathutil"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+)
+
+func TestParseBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a-static", "b-git", "c-submodule", "d-noremote"} {
+		if err := os.MkdirAll(filepath.Join(dir, name, "plugin"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := git.PlainInit(filepath.Join(dir, "b-git"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"git@github.com:user/b-git.git"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = git.PlainInit(filepath.Join(dir, "d-noremote"), false); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "c-submodule", ".git"), []byte("gitdir: ../.git/modules/c\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte(""), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins, untranslated, err := parseBundle([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []importedPlugin{
+		{reposPath: pathutil.ReposPath("localhost/local/a-static"), srcDir: filepath.Join(dir, "a-static")},
+		{reposPath: pathutil.ReposPath("github.com/user/b-git"), srcDir: filepath.Join(dir, "b-git")},
+	}
+	if !reflect.DeepEqual(plugins, expected) {
+		t.Errorf("expected %+v but got %+v", expected, plugins)
+	}
+	if len(untranslated) != 2 {
+		t.Fatalf("expected 2 messages but got %+v", untranslated)
+	}
+	for i, s := range []string{"c-submodule: git submodule", "d-noremote: remote 'origin'"} {
+		if !strings.Contains(untranslated[i], s) {
+			t.Errorf("expected %q in %q", s, untranslated[i])
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package subcmd
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/subcmd/migrate"
+)
+
+func init() {
+	migrate.Register(&pluginsMigrater{
+		name:  "from-dein",
+		brief: "installs the plugins of dein.vim (TOML files)",
+		usage: `Usage
+  volt migrate [-help] from-dein {toml} [{toml2} ...]
+
+Quick example
+  $ volt migrate from-dein ~/.vim/dein/plugins.toml ~/.vim/dein/lazy.toml
+
+Description
+  Install the plugins of [[plugins]] tables in dein.vim TOML files by
+  "volt get", and create plugconf files of them. The keys of the tables are
+  converted as follows:
+
+  * "rev": the version to be checked out
+  * "build": s:build()
+  * "on_ft", "on_cmd", "on_map", "on_event": s:loaded_on() ("filetype=...",
+    "excmd=...", "mapping=...", "event=...")
+  * "depends": s:depends() (the plugins in the given TOML files)
+  * "hook_add", "hook_source": s:on_load_pre()
+  * "hook_post_source": s:on_load_post()
+
+  Existing plugconf files are not changed. The keys which cannot be converted
+  are shown after the plugins were installed. TOML files are not changed, so
+  remove them from the configuration of dein.vim after migration.`,
+		parse: parseDein,
+	})
+}
+
+// parseDein parses [[plugins]] tables in dein.vim TOML files of paths.
+func parseDein(paths []string) ([]importedPlugin, []string, error) {
+	var plugins []importedPlugin
+	var untranslated []string
+	// names is the plugin names (dein's "name" key, or the basename of
+	// repository) referred by "depends"
+	names := make(map[string]pathutil.ReposPath, 32)
+	depends := make(map[int][]string, 8)
+	for _, file := range paths {
+		var conf struct {
+			Plugins []map[string]interface{} `toml:"plugins"`
+		}
+		if _, err := toml.DecodeFile(file, &conf); err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", file, err.Error())
+		}
+		for i, table := range conf.Plugins {
+			pos := fmt.Sprintf("%s: plugins[%d]", file, i)
+			p, name, dep, msgs, err := parseDeinPlugin(table)
+			if err != nil {
+				untranslated = append(untranslated, pos+": "+err.Error())
+				continue
+			}
+			for _, msg := range msgs {
+				untranslated = append(untranslated, pos+": "+p.reposPath.String()+": "+msg)
+			}
+			names[name] = p.reposPath
+			if len(dep) > 0 {
+				depends[len(plugins)] = dep
+			}
+			plugins = append(plugins, *p)
+		}
+	}
+
+	indices := make([]int, 0, len(depends))
+	for i := range depends {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	for _, i := range indices {
+		for _, name := range depends[i] {
+			reposPath, exists := names[name]
+			if !exists {
+				untranslated = append(untranslated, fmt.Sprintf("%s: depends: plugin '%s' is not found", plugins[i].reposPath, name))
+				continue
+			}
+			plugins[i].depends = append(plugins[i].depends, reposPath.String())
+		}
+	}
+	return plugins, untranslated, nil
+}
+
+// parseDeinPlugin parses a table of [[plugins]]. It returns the plugin, the
+// name of the plugin, the names of "depends", and the messages about the keys
+// which could not be converted.
+func parseDeinPlugin(table map[string]interface{}) (*importedPlugin, string, []string, []string, error) {
+	repos, ok := table["repo"].(string)
+	if !ok {
+		return nil, "", nil, nil, fmt.Errorf("'repo' is not a string")
+	}
+	if isLocalDir(repos) {
+		return nil, "", nil, nil, fmt.Errorf("%s: local directory is not installed", repos)
+	}
+	reposPath, err := normalizeRemoteURL(repos)
+	if err != nil {
+		return nil, "", nil, nil, err
+	}
+
+	p := &importedPlugin{reposPath: reposPath}
+	name := path.Base(reposPath.String())
+	var depends, msgs []string
+	var lazy bool
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := table[key]
+		var err error
+		switch key {
+		case "repo":
+		case "name":
+			name, err = deinString(value)
+		case "rev":
+			p.ref, err = deinString(value)
+		case "build":
+			p.build, err = deinString(value)
+		case "on_ft", "on_cmd", "on_event", "on_map":
+			var values []string
+			values, err = deinStrings(value)
+			if key == "on_map" {
+				if modes, ok := value.(map[string]interface{}); ok {
+					values, err = deinMappings(modes)
+				}
+			}
+			prefix := map[string]string{
+				"on_ft":    "filetype=",
+				"on_cmd":   "excmd=",
+				"on_event": "event=",
+				"on_map":   "mapping=",
+			}[key]
+			for _, v := range values {
+				p.loadOn = append(p.loadOn, prefix+v)
+			}
+		case "depends":
+			depends, err = deinStrings(value)
+		case "hook_add", "hook_source":
+			var script string
+			if script, err = deinString(value); err == nil && strings.TrimSpace(script) != "" {
+				p.onLoadPre += strings.TrimRight(strings.TrimLeft(script, "\n"), "\n ") + "\n"
+			}
+		case "hook_post_source":
+			var script string
+			if script, err = deinString(value); err == nil {
+				p.onLoadPost = script
+			}
+		case "lazy":
+			lazy, _ = value.(bool)
+		default:
+			msgs = append(msgs, fmt.Sprintf("'%s' is not supported", key))
+		}
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("'%s' is not converted (%s)", key, err.Error()))
+		}
+	}
+	if lazy && len(p.loadOn) == 0 {
+		msgs = append(msgs, "'lazy' without 'on_*' keys is not converted (the plugin is loaded on startup)")
+	}
+	return p, name, depends, msgs, nil
+}
+
+// deinString returns value if it is a string.
+func deinString(value interface{}) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("not a string")
+	}
+	return s, nil
+}
+
+// deinStrings returns the values of a string or an array of strings.
+func deinStrings(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for i := range v {
+			s, err := deinString(v[i])
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("not a string or an array of strings")
+}
+
+// deinMappings returns the mappings of "on_map" which is a table of
+// {mode} = {mappings}. volt loads the plugin by the mappings in Normal and
+// Visual mode.
+func deinMappings(modes map[string]interface{}) ([]string, error) {
+	keys := make([]string, 0, len(modes))
+	for mode := range modes {
+		keys = append(keys, mode)
+	}
+	sort.Strings(keys)
+	var values []string
+	seen := make(map[string]bool, len(keys))
+	for _, mode := range keys {
+		mappings, err := deinStrings(modes[mode])
+		if err != nil {
+			return nil, err
+		}
+		for _, lhs := range mappings {
+			if !seen[lhs] {
+				values = append(values, lhs)
+				seen[lhs] = true
+			}
+		}
+	}
+	return values, nil
+}
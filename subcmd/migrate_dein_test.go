@@ -0,0 +1,75 @@
+package subcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func TestParseDein(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	plugins := filepath.Join(dir, "plugins.toml")
+	lazy := filepath.Join(dir, "lazy.toml")
+	src := `
+[[plugins]]
+repo = 'Shougo/dein.vim'
+
+[[plugins]]
+repo = 'kana/vim-textobj-user'
+rev = 'v0.7.6'
+hook_add = '''
+let g:textobj_user = 1
+'''
+`
+	if err := ioutil.WriteFile(plugins, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src = `
+[[plugins]]
+repo = 'kana/vim-textobj-line'
+depends = ['vim-textobj-user', 'unknown']
+on_map = { ox = '<Plug>(textobj-line', n = ['<Plug>(textobj-line', '<Plug>(x)'] }
+
+[[plugins]]
+repo = 'fatih/vim-go'
+build = 'make'
+on_ft = 'go'
+on_cmd = ['GoRun', 'GoBuild']
+hook_post_source = 'let g:go_loaded = 1'
+merged = 0
+`
+	if err := ioutil.WriteFile(lazy, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, untranslated, err := parseDein([]string{plugins, lazy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []importedPlugin{
+		{reposPath: pathutil.ReposPath("github.com/Shougo/dein.vim")},
+		{reposPath: pathutil.ReposPath("github.com/kana/vim-textobj-user"), ref: "v0.7.6", onLoadPre: "let g:textobj_user = 1\n"},
+		{reposPath: pathutil.ReposPath("github.com/kana/vim-textobj-line"), loadOn: []string{"mapping=<Plug>(textobj-line", "mapping=<Plug>(x)"}, depends: []string{"github.com/kana/vim-textobj-user"}},
+		{reposPath: pathutil.ReposPath("github.com/fatih/vim-go"), build: "make", loadOn: []string{"excmd=GoRun", "excmd=GoBuild", "filetype=go"}, onLoadPost: "let g:go_loaded = 1"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v but got %+v", expected, result)
+	}
+	if len(untranslated) != 2 {
+		t.Fatalf("expected 2 messages but got %+v", untranslated)
+	}
+	for i, s := range []string{"github.com/fatih/vim-go: 'merged' is not supported", "github.com/kana/vim-textobj-line: depends: plugin 'unknown'"} {
+		if !strings.Contains(untranslated[i], s) {
+			t.Errorf("expected %q in %q", s, untranslated[i])
+		}
+	}
+}
@@ -0,0 +1,669 @@
+package subcmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/vim-volt/volt/subcmd/migrate"
+)
+
+func init() {
+	migrate.Register(&pluginsMigrater{
+		name:  "from-lazynvim",
+		brief: "installs the plugins of lazy.nvim (Lua plugin specs)",
+		usage: `Usage
+  volt migrate [-help] from-lazynvim {spec.lua} [{spec2.lua} ...] [{lazy-lock.json}]
+
+Quick example
+  $ volt migrate from-lazynvim ~/.config/nvim/lua/plugins/*.lua ~/.config/nvim/lazy-lock.json
+  $ volt migrate from-lazynvim ~/.config/nvim/init.lua
+
+Description
+  Install the plugins of lazy.nvim specs by "volt get", and create plugconf
+  files of them. The specs are read from the table returned by the Lua files
+  (e.g. lua/plugins/*.lua), or the table given to require("lazy").setup().
+  The fields of the specs are converted as follows:
+
+  * "commit", "tag", "branch": the version to be checked out
+  * "build": s:build() (only if the value is a shell command)
+  * "event", "ft", "cmd", "keys": s:loaded_on() ("event=...", "filetype=...",
+    "excmd=...", "mapping=...")
+  * "dependencies": s:depends() (the dependencies are also installed)
+
+  If lazy-lock.json was given, the plugins are checked out to the commits of
+  it (the versions of the specs are ignored).
+
+  Existing plugconf files are not changed. Lua code in the specs (e.g.
+  "config", "init", "opts") cannot be converted, so these fields, and the
+  plugins of local directories are shown after the plugins were installed.
+  The specs are not changed, so remove them after migration.`,
+		parse: parseLazyNvim,
+	})
+}
+
+// parseLazyNvim parses lazy.nvim specs in the Lua files of paths, and
+// lazy-lock.json if it is in paths.
+func parseLazyNvim(paths []string) ([]importedPlugin, []string, error) {
+	var plugins []importedPlugin
+	var untranslated []string
+	var lock map[string]struct {
+		Commit string `json:"commit"`
+	}
+	names := make(map[string]int, 32)
+	for _, file := range paths {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		if strings.HasSuffix(file, ".json") {
+			if err := json.Unmarshal(content, &lock); err != nil {
+				return nil, nil, fmt.Errorf("%s: %s", file, err.Error())
+			}
+			continue
+		}
+		spec, err := findLazySpec(string(content))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", file, err.Error())
+		}
+		c := &lazyConverter{file: file, names: names, plugins: plugins}
+		c.convertSpecs(spec)
+		plugins = c.plugins
+		untranslated = append(untranslated, c.msgs...)
+	}
+	if len(lock) > 0 {
+		for name, i := range names {
+			if entry, exists := lock[name]; exists && entry.Commit != "" {
+				plugins[i].ref = entry.Commit
+			}
+		}
+	}
+	return plugins, untranslated, nil
+}
+
+// findLazySpec returns the spec table given to setup() (its "spec" field if
+// it exists), or returned by the Lua script src.
+func findLazySpec(src string) (interface{}, error) {
+	p := &luaParser{src: src}
+	var returned interface{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			break
+		}
+		name := p.readName()
+		switch {
+		case name == "setup":
+			p.skipSpace()
+			if p.consume("(") {
+				value, err := p.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				if table, ok := value.(*luaTable); ok && table.fields["spec"] != nil {
+					return table.fields["spec"], nil
+				}
+				return value, nil
+			}
+		case name == "return" && returned == nil:
+			p.skipSpace()
+			if p.peek() == '{' {
+				value, err := p.parseValue()
+				if err != nil {
+					return nil, err
+				}
+				returned = value
+			}
+		case name != "":
+		default:
+			p.skipToken()
+		}
+	}
+	if returned == nil {
+		return nil, errors.New("lazy.nvim specs are not found")
+	}
+	return returned, nil
+}
+
+// lazyConverter converts lazy.nvim specs to importedPlugin.
+type lazyConverter struct {
+	file    string
+	plugins []importedPlugin
+	// names is the plugin names (the "name" field, or the basename of
+	// repository) to the index of plugins
+	names map[string]int
+	msgs  []string
+}
+
+// convertSpecs converts a spec or a list of specs, and returns the indices of
+// the plugins in c.plugins. A table which has two or more positional values
+// (or has no fields) is a list of specs like lazy.nvim.
+func (c *lazyConverter) convertSpecs(value interface{}) []int {
+	switch v := value.(type) {
+	case string:
+		if i, exists := c.names[v]; exists && !strings.Contains(v, "/") {
+			// a plugin name (e.g. dependencies = "plenary.nvim")
+			return []int{i}
+		}
+		if i := c.convertSpec(&luaTable{array: []interface{}{v}}); i >= 0 {
+			return []int{i}
+		}
+		return nil
+	case *luaTable:
+		if len(v.array) > 1 || len(v.fields) == 0 {
+			var indices []int
+			for _, spec := range v.array {
+				indices = append(indices, c.convertSpecs(spec)...)
+			}
+			return indices
+		}
+		if v.fields["import"] != nil {
+			c.msgs = append(c.msgs, c.file+": 'import' is not supported (give the imported files instead)")
+			return nil
+		}
+		if i := c.convertSpec(v); i >= 0 {
+			return []int{i}
+		}
+		return nil
+	}
+	c.msgs = append(c.msgs, c.file+": spec which is not a string or a table is ignored")
+	return nil
+}
+
+// convertSpec converts a spec, and returns the index of the plugin in
+// c.plugins (-1 if it was not converted).
+func (c *lazyConverter) convertSpec(spec *luaTable) int {
+	var repos string
+	if len(spec.array) > 0 {
+		repos, _ = spec.array[0].(string)
+	}
+	if url, ok := spec.fields["url"].(string); ok {
+		repos = url
+	}
+	if isLocalDir(repos) || spec.fields["dir"] != nil {
+		c.msgs = append(c.msgs, fmt.Sprintf("%s: %s: local directory is not installed", c.file, repos))
+		return -1
+	}
+	reposPath, err := normalizeRemoteURL(repos)
+	if err != nil {
+		c.msgs = append(c.msgs, c.file+": "+err.Error())
+		return -1
+	}
+	name := path.Base(reposPath.String())
+	if s, ok := spec.fields["name"].(string); ok {
+		name = s
+	}
+	if i, exists := c.names[name]; exists {
+		// Specs of the same plugin are merged by lazy.nvim
+		c.convertFields(i, spec)
+		return i
+	}
+	c.names[name] = len(c.plugins)
+	c.plugins = append(c.plugins, importedPlugin{reposPath: reposPath})
+	i := len(c.plugins) - 1
+	c.convertFields(i, spec)
+	return i
+}
+
+func (c *lazyConverter) convertFields(i int, spec *luaTable) {
+	report := func(format string, a ...interface{}) {
+		c.msgs = append(c.msgs, fmt.Sprintf("%s: %s: ", c.file, c.plugins[i].reposPath)+fmt.Sprintf(format, a...))
+	}
+	var lazy bool
+	var branch, tag, commit string
+	for _, key := range spec.keys {
+		value := spec.fields[key]
+		switch key {
+		case "url", "name", "dir":
+		case "commit", "tag", "branch":
+			s, ok := value.(string)
+			if !ok {
+				report("'%s' is not converted (not a string)", key)
+				continue
+			}
+			switch key {
+			case "commit":
+				commit = s
+			case "tag":
+				tag = s
+			default:
+				branch = s
+			}
+		case "build":
+			s, ok := value.(string)
+			if !ok || strings.HasPrefix(s, ":") {
+				report("'build' is not converted (only shell command is supported)")
+				continue
+			}
+			c.plugins[i].build = s
+		case "event", "ft", "cmd":
+			values, ok := luaStrings(value)
+			if !ok {
+				report("'%s' is not converted (not a string or a list of strings)", key)
+				continue
+			}
+			for _, v := range values {
+				switch {
+				case key == "ft":
+					c.plugins[i].loadOn = append(c.plugins[i].loadOn, "filetype="+v)
+				case key == "cmd":
+					c.plugins[i].loadOn = append(c.plugins[i].loadOn, "excmd="+v)
+				case strings.ContainsAny(v, " ") || v == "VeryLazy" || v == "LazyFile":
+					report("event '%s' is not converted", v)
+				default:
+					c.plugins[i].loadOn = append(c.plugins[i].loadOn, "event="+v)
+				}
+			}
+		case "keys":
+			c.convertKeys(i, value, report)
+		case "dependencies":
+			c.convertDependencies(i, value)
+		case "lazy":
+			lazy, _ = value.(bool)
+		default:
+			report("'%s' is not converted", key)
+		}
+	}
+	switch {
+	case commit != "":
+		c.plugins[i].ref = commit
+	case tag != "":
+		c.plugins[i].ref = tag
+	case branch != "":
+		c.plugins[i].ref = branch
+	}
+	if lazy && len(c.plugins[i].loadOn) == 0 {
+		report("'lazy' without triggers is not converted (the plugin is loaded on startup)")
+	}
+}
+
+// convertKeys converts "keys" field: a string, or a list of strings or
+// tables whose first value is lhs.
+func (c *lazyConverter) convertKeys(i int, value interface{}, report func(string, ...interface{})) {
+	keys := []interface{}{value}
+	if table, ok := value.(*luaTable); ok {
+		keys = table.array
+	}
+	for _, key := range keys {
+		if table, ok := key.(*luaTable); ok && len(table.array) > 0 {
+			key = table.array[0]
+		}
+		lhs, ok := key.(string)
+		if !ok {
+			report("'keys' is not converted (lhs is not a string)")
+			continue
+		}
+		c.plugins[i].loadOn = append(c.plugins[i].loadOn, "mapping="+lhs)
+	}
+}
+
+// convertDependencies converts "dependencies" field. The dependencies are
+// also converted as plugins.
+func (c *lazyConverter) convertDependencies(i int, value interface{}) {
+	for _, j := range c.convertSpecs(value) {
+		if j != i {
+			c.plugins[i].depends = append(c.plugins[i].depends, c.plugins[j].reposPath.String())
+		}
+	}
+}
+
+// luaStrings returns the values of a string or a list of strings.
+func luaStrings(value interface{}) ([]string, bool) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, true
+	case *luaTable:
+		values := make([]string, 0, len(v.array))
+		for _, e := range v.array {
+			s, ok := e.(string)
+			if !ok {
+				return nil, false
+			}
+			values = append(values, s)
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+// luaTable is a table constructor of Lua.
+type luaTable struct {
+	// array is the positional values
+	array []interface{}
+	// fields is the values of string keys, and keys is the keys in the
+	// order of appearance
+	fields map[string]interface{}
+	keys   []string
+}
+
+// luaOpaque is a Lua value which is not parsed (e.g. function, variable,
+// and the other expressions).
+type luaOpaque string
+
+// luaParser parses the literals of Lua (strings, booleans, and tables).
+// The other expressions are skipped as luaOpaque.
+type luaParser struct {
+	src string
+	pos int
+}
+
+func (p *luaParser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *luaParser) consume(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// skipSpace skips white spaces and comments.
+func (p *luaParser) skipSpace() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "--"):
+			p.pos += 2
+			if level := p.longBracketLevel(); level >= 0 {
+				p.readLongString(level)
+				continue
+			}
+			if i := strings.IndexByte(p.src[p.pos:], '\n'); i >= 0 {
+				p.pos += i + 1
+			} else {
+				p.pos = len(p.src)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// longBracketLevel returns the level of long bracket ("[[" is 0, "[=[" is 1,
+// ...) at current position, or -1 if it is not a long bracket.
+func (p *luaParser) longBracketLevel() int {
+	if p.peek() != '[' {
+		return -1
+	}
+	i := p.pos + 1
+	for i < len(p.src) && p.src[i] == '=' {
+		i++
+	}
+	if i < len(p.src) && p.src[i] == '[' {
+		return i - p.pos - 1
+	}
+	return -1
+}
+
+// readLongString reads a long string (or a long comment) of level.
+func (p *luaParser) readLongString(level int) (string, error) {
+	p.pos += level + 2
+	closing := "]" + strings.Repeat("=", level) + "]"
+	i := strings.Index(p.src[p.pos:], closing)
+	if i < 0 {
+		p.pos = len(p.src)
+		return "", errors.New("unfinished long string")
+	}
+	s := strings.TrimPrefix(p.src[p.pos:p.pos+i], "\n")
+	p.pos += i + len(closing)
+	return s, nil
+}
+
+// readQuotedString reads a string literal which begins with ' or ".
+func (p *luaParser) readQuotedString() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var buf strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return buf.String(), nil
+		case c == '\n':
+			return "", errors.New("unfinished string")
+		case c == '\\' && p.pos < len(p.src):
+			e := p.src[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				buf.WriteByte('\n')
+			case 't':
+				buf.WriteByte('\t')
+			default:
+				buf.WriteByte(e)
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return "", errors.New("unfinished string")
+}
+
+func isLuaNameChar(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
+
+// readName reads a name (an identifier or a keyword), or returns "".
+func (p *luaParser) readName() string {
+	begin := p.pos
+	if p.pos < len(p.src) && isLuaNameChar(p.src[p.pos], true) {
+		for p.pos < len(p.src) && isLuaNameChar(p.src[p.pos], false) {
+			p.pos++
+		}
+	}
+	return p.src[begin:p.pos]
+}
+
+// skipToken skips a token which is not a name.
+func (p *luaParser) skipToken() {
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		p.readQuotedString()
+	case p.longBracketLevel() >= 0:
+		p.readLongString(p.longBracketLevel())
+	case '0' <= c && c <= '9':
+		for p.pos < len(p.src) && (isLuaNameChar(p.src[p.pos], false) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+	default:
+		p.pos++
+	}
+}
+
+// skipFunction skips the rest of function after "function" keyword.
+func (p *luaParser) skipFunction() error {
+	depth := 1
+	for depth > 0 {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return errors.New("unfinished function")
+		}
+		switch p.readName() {
+		case "function", "do", "if", "repeat":
+			depth++
+		case "end", "until":
+			depth--
+		case "":
+			p.skipToken()
+		}
+	}
+	return nil
+}
+
+// skipExpr skips the rest of expression until "," or ";" or "}" or ")" of
+// current table (or arguments).
+func (p *luaParser) skipExpr() error {
+	depth := 0
+	for {
+		p.skipSpace()
+		c := p.peek()
+		switch {
+		case p.pos >= len(p.src):
+			return errors.New("unfinished expression")
+		case depth == 0 && (c == ',' || c == ';' || c == '}' || c == ')'):
+			return nil
+		case c == '(' || c == '{' || c == '[' && p.longBracketLevel() < 0:
+			depth++
+			p.pos++
+		case c == ')' || c == '}' || c == ']':
+			depth--
+			p.pos++
+		default:
+			if name := p.readName(); name == "function" {
+				if err := p.skipFunction(); err != nil {
+					return err
+				}
+			} else if name == "" {
+				p.skipToken()
+			}
+		}
+	}
+}
+
+// parseValue parses an expression. It returns string, bool, nil, *luaTable,
+// or luaOpaque.
+func (p *luaParser) parseValue() (interface{}, error) {
+	p.skipSpace()
+	var value interface{}
+	var err error
+	switch c := p.peek(); {
+	case c == '{':
+		value, err = p.parseTable()
+	case c == '"' || c == '\'':
+		value, err = p.readQuotedString()
+	case p.longBracketLevel() >= 0:
+		value, err = p.readLongString(p.longBracketLevel())
+	default:
+		switch name := p.readName(); name {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "nil":
+			value = nil
+		case "function":
+			if err := p.skipFunction(); err != nil {
+				return nil, err
+			}
+			value = luaOpaque("function")
+		default:
+			if err := p.skipExpr(); err != nil {
+				return nil, err
+			}
+			return luaOpaque("expression"), nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The literal is a part of an expression (e.g. "a" .. b)
+	p.skipSpace()
+	if c := p.peek(); c != ',' && c != ';' && c != '}' && c != ')' && p.pos < len(p.src) {
+		if err := p.skipExpr(); err != nil {
+			return nil, err
+		}
+		return luaOpaque("expression"), nil
+	}
+	return value, nil
+}
+
+// parseTable parses a table constructor.
+func (p *luaParser) parseTable() (*luaTable, error) {
+	table := &luaTable{fields: make(map[string]interface{})}
+	p.pos++ // "{"
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return nil, errors.New("unfinished table")
+		}
+		if p.consume("}") {
+			return table, nil
+		}
+
+		var key interface{}
+		begin := p.pos
+		if p.peek() == '[' && p.longBracketLevel() < 0 {
+			// [key] = value
+			p.pos++
+			k, err := p.parseValueUntil(']')
+			if err != nil {
+				return nil, err
+			}
+			key = k
+			p.skipSpace()
+			if !p.consume("=") {
+				return nil, fmt.Errorf("'=' is expected at offset %d", p.pos)
+			}
+		} else if name := p.readName(); name != "" {
+			p.skipSpace()
+			if p.peek() == '=' && !strings.HasPrefix(p.src[p.pos:], "==") {
+				p.pos++
+				key = name
+			} else {
+				p.pos = begin
+			}
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		switch k := key.(type) {
+		case nil:
+			table.array = append(table.array, value)
+		case string:
+			if _, exists := table.fields[k]; !exists {
+				table.keys = append(table.keys, k)
+			}
+			table.fields[k] = value
+		}
+
+		p.skipSpace()
+		if !p.consume(",") && !p.consume(";") && p.peek() != '}' {
+			return nil, fmt.Errorf("',' or '}' is expected at offset %d", p.pos)
+		}
+	}
+}
+
+// parseValueUntil parses the key of "[key] = value", and consumes closing.
+func (p *luaParser) parseValueUntil(closing byte) (interface{}, error) {
+	p.skipSpace()
+	var value interface{} = luaOpaque("expression")
+	if c := p.peek(); c == '"' || c == '\'' {
+		s, err := p.readQuotedString()
+		if err != nil {
+			return nil, err
+		}
+		value = s
+	}
+	depth := 0
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return nil, errors.New("unfinished key")
+		}
+		c := p.peek()
+		if c == closing && depth == 0 {
+			p.pos++
+			return value, nil
+		}
+		switch c {
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+		}
+		value = luaOpaque("expression")
+		p.skipToken()
+	}
+}
@@ -0,0 +1,101 @@
+package subcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func TestParseLazyNvim(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	initLua := filepath.Join(dir, "init.lua")
+	src := `
+-- bootstrap lazy.nvim
+local lazypath = vim.fn.stdpath("data") .. "/lazy/lazy.nvim"
+vim.opt.rtp:prepend(lazypath)
+
+require("lazy").setup({
+  spec = {
+    "folke/which-key.nvim", -- comment
+    { "nvim-telescope/telescope.nvim", tag = '0.1.5',
+      dependencies = { "nvim-lua/plenary.nvim" },
+      cmd = "Telescope",
+      keys = { { "<leader>ff", "<cmd>Telescope find_files<cr>", desc = "Find" }, "<leader>fg" },
+      config = function()
+        if true then require("telescope").setup({}) end
+      end,
+    },
+    {
+      "fatih/vim-go", ft = { "go", "gomod" }, build = ":GoInstallBinaries",
+      event = { "BufReadPre", "VeryLazy" },
+      opts = { [ "key" ] = [[long
+string]] },
+    },
+    { dir = "~/projects/my.nvim" },
+  },
+  defaults = { lazy = false },
+})
+`
+	if err := ioutil.WriteFile(initLua, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	specLua := filepath.Join(dir, "spec.lua")
+	src = `return {
+  { "nvim-lua/plenary.nvim", lazy = true },
+  { "https://gitlab.com/user/name.nvim", branch = "dev", build = "make" },
+}`
+	if err := ioutil.WriteFile(specLua, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lockJSON := filepath.Join(dir, "lazy-lock.json")
+	src = `{
+  "which-key.nvim": { "branch": "main", "commit": "4433e5ec9a507e5097571ed55c02ea9658fb268a" }
+}`
+	if err := ioutil.WriteFile(lockJSON, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins, untranslated, err := parseLazyNvim([]string{initLua, specLua, lockJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []importedPlugin{
+		{reposPath: pathutil.ReposPath("github.com/folke/which-key.nvim"), ref: "4433e5ec9a507e5097571ed55c02ea9658fb268a"},
+		{
+			reposPath: pathutil.ReposPath("github.com/nvim-telescope/telescope.nvim"),
+			ref:       "0.1.5",
+			loadOn:    []string{"excmd=Telescope", "mapping=<leader>ff", "mapping=<leader>fg"},
+			depends:   []string{"github.com/nvim-lua/plenary.nvim"},
+		},
+		{reposPath: pathutil.ReposPath("github.com/nvim-lua/plenary.nvim")},
+		{reposPath: pathutil.ReposPath("github.com/fatih/vim-go"), loadOn: []string{"filetype=go", "filetype=gomod", "event=BufReadPre"}},
+		{reposPath: pathutil.ReposPath("gitlab.com/user/name.nvim"), ref: "dev", build: "make"},
+	}
+	if !reflect.DeepEqual(plugins, expected) {
+		t.Errorf("expected %+v but got %+v", expected, plugins)
+	}
+	for i, s := range []string{
+		"github.com/nvim-telescope/telescope.nvim: 'config' is not converted",
+		"github.com/fatih/vim-go: 'build' is not converted",
+		"github.com/fatih/vim-go: event 'VeryLazy' is not converted",
+		"github.com/fatih/vim-go: 'opts' is not converted",
+		"local directory is not installed",
+		"github.com/nvim-lua/plenary.nvim: 'lazy' without triggers",
+	} {
+		if i >= len(untranslated) || !strings.Contains(untranslated[i], s) {
+			t.Errorf("expected %q in the message %d of %+v", s, i, untranslated)
+		}
+	}
+	if len(untranslated) != 6 {
+		t.Errorf("expected 6 messages but got %+v", untranslated)
+	}
+}
@@ -0,0 +1,413 @@
+package subcmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/haya14busa/go-vimlparser"
+	"github.com/haya14busa/go-vimlparser/ast"
+	"github.com/haya14busa/go-vimlparser/token"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/plugconf"
+)
+
+// importedPlugin is a plugin converted from the configuration of other plugin
+// manager.
+type importedPlugin struct {
+	reposPath pathutil.ReposPath
+	// ref is a branch, a tag, or a commit to check out ("" means default
+	// branch)
+	ref string
+	// build is the shell command of s:build()
+	build string
+	// loadOn is the return values of s:loaded_on() (nil means "start")
+	loadOn []string
+	// depends is the return values of s:depends()
+	depends []string
+	// onLoadPre and onLoadPost are the bodies of s:on_load_pre() and
+	// s:on_load_post()
+	onLoadPre  string
+	onLoadPost string
+	// srcDir is the directory of the plugin which was installed by other
+	// plugin manager. It is moved to $VOLTPATH/repos/{repos path} instead
+	// of downloading the plugin
+	srcDir string
+}
+
+// pluginsMigrater is a migration operation which installs the plugins
+// configured for other plugin manager.
+type pluginsMigrater struct {
+	name  string
+	brief string
+	// usage is the help shown by "volt migrate -help {name}"
+	usage string
+	// parse returns the plugins in the configuration files of paths, and the
+	// messages about the things which could not be converted
+	parse func(paths []string) ([]importedPlugin, []string, error)
+	// defaultArgs returns the files parsed when no files were given (nil
+	// means that files must be given)
+	defaultArgs func() []string
+}
+
+func (m *pluginsMigrater) Name() string {
+	return m.name
+}
+
+func (m *pluginsMigrater) Description(brief bool) string {
+	if brief {
+		return m.brief
+	}
+	return m.usage
+}
+
+// Migrate creates plugconf files of the plugins in the configuration files of
+// args, and installs the plugins by "volt get".
+// Existing plugconf files are not changed.
+func (m *pluginsMigrater) Migrate(args []string) error {
+	if len(args) == 0 && m.defaultArgs != nil {
+		args = m.defaultArgs()
+	}
+	if len(args) == 0 {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: no files were given (see 'volt migrate -help " + m.name + "')"}
+	}
+	plugins, untranslated, err := m.parse(args)
+	if err != nil {
+		return &Error{Code: ExitValidation, Msg: "Could not read configuration: " + err.Error()}
+	}
+	if len(plugins) == 0 {
+		return &Error{Code: ExitValidation, Msg: "No plugins were found in " + strings.Join(args, ", ")}
+	}
+
+	getArgs := make([]string, 0, len(plugins))
+	for i := range plugins {
+		p := &plugins[i]
+		if p.srcDir != "" {
+			if err := moveImportedPlugin(p); err != nil {
+				untranslated = append(untranslated, fmt.Sprintf("%s: %s was not moved: %s", p.reposPath, p.srcDir, err.Error()))
+				continue
+			}
+		}
+		if err := writeImportedPlugconf(p); err != nil {
+			untranslated = append(untranslated, fmt.Sprintf("%s: plugconf was not written: %s", p.reposPath, err.Error()))
+		}
+		arg := p.reposPath.String()
+		if p.ref != "" {
+			arg += "@" + p.ref
+		}
+		getArgs = append(getArgs, arg)
+	}
+
+	var getErr *Error
+	if len(getArgs) > 0 {
+		getErr = (&getCmd{}).Run(getArgs)
+	}
+
+	if len(untranslated) > 0 {
+		logger.Warn("The following settings could not be converted:")
+		for _, msg := range untranslated {
+			logger.Warn("  " + msg)
+		}
+	}
+	if getErr != nil {
+		return getErr
+	}
+	return nil
+}
+
+// moveImportedPlugin moves p.srcDir to the repository directory of p.
+func moveImportedPlugin(p *importedPlugin) error {
+	dst := p.reposPath.FullPath()
+	if pathutil.Exists(dst) {
+		return errors.New(dst + " already exists")
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	logger.Debugf("Moving %s to %s ...", p.srcDir, dst)
+	return os.Rename(p.srcDir, dst)
+}
+
+// writeImportedPlugconf writes the plugconf file of p. It does nothing if the
+// plugconf file exists, or p has nothing to be written.
+func writeImportedPlugconf(p *importedPlugin) error {
+	path := p.reposPath.Plugconf()
+	if pathutil.Exists(path) {
+		logger.Debugf("plugconf '%s' exists... skip", path)
+		return nil
+	}
+	content, err := p.plugconf()
+	if err != nil || content == nil {
+		return err
+	}
+	file, err := vimlparser.ParseFile(bytes.NewReader(content), path, nil)
+	if err != nil {
+		return err
+	}
+	if _, parseErr := plugconf.ParsePlugconf(file, content, path); parseErr.HasErrs() {
+		return parseErr.Errors()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+// plugconf returns the content of the plugconf file of p, or nil if p has
+// nothing to be written (then "volt get" creates the plugconf file by the
+// template).
+func (p *importedPlugin) plugconf() ([]byte, error) {
+	var loadOn, depends, build string
+	var err error
+	if loadOn, err = vimStringList(p.loadOn); err != nil {
+		return nil, err
+	}
+	if depends, err = vimStringList(p.depends); err != nil {
+		return nil, err
+	}
+	if build, err = vimString(p.build); err != nil {
+		return nil, err
+	}
+
+	funcs := make([]string, 0, 5)
+	if p.onLoadPre != "" {
+		funcs = append(funcs, "function! s:on_load_pre()\n"+indentVimScript(p.onLoadPre)+"endfunction")
+	}
+	if p.onLoadPost != "" {
+		funcs = append(funcs, "function! s:on_load_post()\n"+indentVimScript(p.onLoadPost)+"endfunction")
+	}
+	if len(p.loadOn) > 0 {
+		funcs = append(funcs, "function! s:loaded_on()\n  return "+loadOn+"\nendfunction")
+	}
+	if len(p.depends) > 0 {
+		funcs = append(funcs, "function! s:depends()\n  return "+depends+"\nendfunction")
+	}
+	if p.build != "" {
+		funcs = append(funcs, "function! s:build()\n  return "+build+"\nendfunction")
+	}
+	if len(funcs) == 0 {
+		return nil, nil
+	}
+	return []byte(strings.Join(funcs, "\n\n") + "\n"), nil
+}
+
+// indentVimScript indents each line of script by two spaces.
+func indentVimScript(script string) string {
+	var buf bytes.Buffer
+	for _, line := range strings.Split(strings.TrimRight(script, "\n"), "\n") {
+		if strings.TrimSpace(line) != "" {
+			buf.WriteString("  " + line)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// vimString returns Vim script string literal of s. plugconf parser reads
+// string literals without unescaping, so s must be written as it is.
+func vimString(s string) (string, error) {
+	switch {
+	case !strings.Contains(s, "'"):
+		return "'" + s + "'", nil
+	case !strings.ContainsAny(s, "\"\\"):
+		return "\"" + s + "\"", nil
+	}
+	return "", errors.New("cannot write string literal of " + s)
+}
+
+// vimStringList returns Vim script list literal of values, or a string literal
+// if values has one value.
+func vimStringList(values []string) (string, error) {
+	if len(values) == 1 {
+		return vimString(values[0])
+	}
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		q, err := vimString(v)
+		if err != nil {
+			return "", err
+		}
+		quoted = append(quoted, q)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]", nil
+}
+
+// vimLiteralString returns the value of Vim script string literal expr.
+func vimLiteralString(expr ast.Expr) (string, error) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING || len(lit.Value) < 2 {
+		return "", errNotVimLiteral
+	}
+	value := lit.Value[1 : len(lit.Value)-1]
+	if lit.Value[0] == '\'' {
+		return strings.Replace(value, "''", "'", -1), nil
+	}
+	if strings.Contains(value, "\\<") {
+		return "", errNotVimLiteral
+	}
+	return strconv.Unquote("\"" + value + "\"")
+}
+
+// vimLiteralStrings returns the values of Vim script string literal, or list
+// literal of string literals.
+func vimLiteralStrings(expr ast.Expr) ([]string, error) {
+	list, ok := expr.(*ast.List)
+	if !ok {
+		value, err := vimLiteralString(expr)
+		if err != nil {
+			return nil, err
+		}
+		return []string{value}, nil
+	}
+	values := make([]string, 0, len(list.Values))
+	for _, v := range list.Values {
+		value, err := vimLiteralString(v)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+var errNotVimLiteral = errors.New("not a string literal")
+
+// isLocalDir returns true if repos of other plugin managers is a local
+// directory.
+func isLocalDir(repos string) bool {
+	return strings.HasPrefix(repos, "~") || strings.HasPrefix(repos, "/") ||
+		strings.HasPrefix(repos, ".") || strings.HasPrefix(repos, "file://")
+}
+
+// normalizeRemoteURL normalizes the repository or the git URL (including
+// "git@{host}:{user}/{name}" form) of other plugin managers into ReposPath.
+func normalizeRemoteURL(repos string) (pathutil.ReposPath, error) {
+	if strings.HasPrefix(repos, "git@") {
+		// "git@host:user/name.git" is cloned by https
+		repos = "https://" + strings.Replace(strings.TrimPrefix(repos, "git@"), ":", "/", 1)
+	}
+	if i := strings.Index(repos, "://"); i >= 0 && strings.Count(strings.TrimSuffix(repos[i+3:], "/"), "/") != 2 {
+		return "", fmt.Errorf("%s: URL which is not {host}/{user}/{name} form is not supported", repos)
+	}
+	return pathutil.NormalizeRepos(repos)
+}
+
+// parseVimCommands parses the Ex commands of rx (the first submatch is the
+// arguments) in the Vim script files of paths by parseArgs.
+func parseVimCommands(paths []string, rx *regexp.Regexp, parseArgs func(string) (*importedPlugin, []string, error)) ([]importedPlugin, []string, error) {
+	var plugins []importedPlugin
+	var untranslated []string
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		lines, linenums := joinVimLines(content)
+		for i, line := range lines {
+			for _, cmd := range splitVimBar(stripVimComment(strings.TrimSpace(line))) {
+				m := rx.FindStringSubmatch(cmd)
+				if m == nil {
+					continue
+				}
+				pos := fmt.Sprintf("%s:%d", path, linenums[i])
+				p, msgs, err := parseArgs(m[1])
+				if err != nil {
+					untranslated = append(untranslated, pos+": "+err.Error())
+					continue
+				}
+				for _, msg := range msgs {
+					untranslated = append(untranslated, pos+": "+p.reposPath.String()+": "+msg)
+				}
+				plugins = append(plugins, *p)
+			}
+		}
+	}
+	return plugins, untranslated, nil
+}
+
+// joinVimLines joins continuation lines (which begin with "\") of Vim script
+// to the previous lines. It returns the joined lines and their line numbers.
+func joinVimLines(content []byte) ([]string, []int) {
+	lines := make([]string, 0, 128)
+	linenums := make([]int, 0, 128)
+	for i, line := range strings.Split(strings.Replace(string(content), "\r\n", "\n", -1), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(trimmed, "\\") && len(lines) > 0 {
+			lines[len(lines)-1] += trimmed[1:]
+			continue
+		}
+		lines = append(lines, line)
+		linenums = append(linenums, i+1)
+	}
+	return lines, linenums
+}
+
+// stripVimComment removes the trailing comment from the arguments of Ex
+// command. A double quote which begins a string literal (after ",", "[", "{",
+// ":", or "(") is not a comment.
+func stripVimComment(args string) string {
+	var quote byte
+	prev := byte(0)
+	for i := 0; i < len(args); i++ {
+		c := args[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				if i+1 < len(args) && args[i+1] == '\'' {
+					i++
+				} else {
+					quote = 0
+				}
+			}
+		case quote == '"':
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				quote = 0
+			}
+		case c == '\'':
+			quote = c
+		case c == '"':
+			if prev != 0 && !strings.ContainsRune(",[{:(", rune(prev)) {
+				return strings.TrimSpace(args[:i])
+			}
+			quote = c
+		}
+		if c != ' ' && c != '\t' {
+			prev = c
+		}
+	}
+	return strings.TrimSpace(args)
+}
+
+// splitVimBar splits the line (which has no comment) by "|" which separates
+// Ex commands.
+func splitVimBar(line string) []string {
+	var cmds []string
+	var quote byte
+	begin := 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '|':
+			cmds = append(cmds, strings.TrimSpace(line[begin:i]))
+			begin = i + 1
+		}
+	}
+	return append(cmds, strings.TrimSpace(line[begin:]))
+}
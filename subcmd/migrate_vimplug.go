@@ -0,0 +1,141 @@
+package subcmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/haya14busa/go-vimlparser"
+	"github.com/haya14busa/go-vimlparser/ast"
+	"github.com/vim-volt/volt/subcmd/migrate"
+)
+
+func init() {
+	migrate.Register(&pluginsMigrater{
+		name:  "from-vimplug",
+		brief: "installs the plugins of vim-plug (Plug commands in vimrc)",
+		usage: `Usage
+  volt migrate [-help] from-vimplug {vimrc} [{vimrc2} ...]
+
+Quick example
+  $ volt migrate from-vimplug ~/.vimrc
+
+Description
+  Install the plugins of "Plug" commands in {vimrc} by "volt get", and create
+  plugconf files of them. The options of "Plug" are converted as follows:
+
+  * "branch", "tag", "commit": the version to be checked out
+  * "do": s:build() (only if the value is a shell command)
+  * "on": s:loaded_on() ("mapping=..." for <Plug> mappings, "excmd=..." for
+    commands)
+  * "for": s:loaded_on() ("filetype=...")
+
+  Existing plugconf files are not changed. The plugins of local directories,
+  and the options which cannot be converted ("do" of Vim command or function,
+  "rtp", "dir", "as", "frozen") are shown after the plugins were installed.
+  vimrc is not changed, so remove "Plug" commands from vimrc after migration.`,
+		parse: parseVimPlug,
+	})
+}
+
+// rxPlugCommand matches to "Plug" command of vim-plug.
+var rxPlugCommand = regexp.MustCompile(`^Plug!?\s+(.+)$`)
+
+// parseVimPlug parses "Plug" commands in the vimrc files of paths.
+func parseVimPlug(paths []string) ([]importedPlugin, []string, error) {
+	return parseVimCommands(paths, rxPlugCommand, parsePlugArgs)
+}
+
+// parsePlugArgs parses the arguments of "Plug" command:
+// "{repository} [, {options}]".
+func parsePlugArgs(args string) (*importedPlugin, []string, error) {
+	expr, err := vimlparser.ParseExpr(strings.NewReader("[" + args + "]"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse 'Plug %s': %s", args, err.Error())
+	}
+	list, ok := expr.(*ast.List)
+	if !ok || len(list.Values) == 0 || len(list.Values) > 2 {
+		return nil, nil, fmt.Errorf("could not parse 'Plug %s'", args)
+	}
+	repos, err := vimLiteralString(list.Values[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse 'Plug %s': repository is %s", args, err.Error())
+	}
+	if isLocalDir(repos) {
+		return nil, nil, fmt.Errorf("%s: local directory is not installed", repos)
+	}
+	reposPath, err := normalizeRemoteURL(repos)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := &importedPlugin{reposPath: reposPath}
+	if len(list.Values) == 1 {
+		return p, nil, nil
+	}
+	dict, ok := list.Values[1].(*ast.Dict)
+	if !ok {
+		return p, []string{"options are not a dictionary literal"}, nil
+	}
+	var msgs []string
+	var branch, tag, commit string
+	for _, entry := range dict.Entries {
+		key, err := vimLiteralString(entry.Key)
+		if err != nil {
+			msgs = append(msgs, "option key is not a string literal")
+			continue
+		}
+		values, err := vimLiteralStrings(entry.Value)
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("option '%s' is not converted (the value is not a string literal)", key))
+			continue
+		}
+		switch key {
+		case "branch", "tag", "commit":
+			if len(values) != 1 {
+				msgs = append(msgs, fmt.Sprintf("option '%s' is not a string", key))
+				continue
+			}
+			switch key {
+			case "branch":
+				branch = values[0]
+			case "tag":
+				tag = values[0]
+			default:
+				commit = values[0]
+			}
+		case "do":
+			if len(values) != 1 || strings.HasPrefix(values[0], ":") {
+				msgs = append(msgs, "option 'do' is not converted (only shell command is supported)")
+				continue
+			}
+			p.build = values[0]
+		case "on":
+			if len(values) == 0 {
+				msgs = append(msgs, "option 'on' is not converted (the plugin is loaded on startup)")
+			}
+			for _, v := range values {
+				if strings.HasPrefix(v, "<") {
+					p.loadOn = append(p.loadOn, "mapping="+v)
+				} else {
+					p.loadOn = append(p.loadOn, "excmd="+v)
+				}
+			}
+		case "for":
+			for _, v := range values {
+				p.loadOn = append(p.loadOn, "filetype="+v)
+			}
+		default:
+			msgs = append(msgs, fmt.Sprintf("option '%s' is not supported", key))
+		}
+	}
+	switch {
+	case commit != "":
+		p.ref = commit
+	case tag != "":
+		p.ref = tag
+	default:
+		p.ref = branch
+	}
+	return p, msgs, nil
+}
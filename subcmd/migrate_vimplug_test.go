@@ -0,0 +1,90 @@
+package subcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func TestParseVimPlug(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	vimrc := filepath.Join(dir, "vimrc")
+	src := `
+call plug#begin('~/.vim/plugged')
+Plug 'tyru/caw.vim'  " comment
+Plug 'junegunn/fzf', { 'do': './install --all', 'tag': 'v0.1' }
+Plug 'scrooloose/nerdtree', { 'on': ['NERDTreeToggle', '<Plug>NERDTree'] }
+Plug 'fatih/vim-go', {
+      \ 'for': 'go',
+      \ 'branch': "master",
+      \ 'do': ':GoInstallBinaries' }
+Plug 'a/b' | Plug 'https://github.com/c/d.git'
+Plug '~/my-plugin'
+Plug 'e/f', { 'rtp': 'vim' }
+call plug#end()
+`
+	if err := ioutil.WriteFile(vimrc, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins, untranslated, err := parseVimPlug([]string{vimrc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []importedPlugin{
+		{reposPath: pathutil.ReposPath("github.com/tyru/caw.vim")},
+		{reposPath: pathutil.ReposPath("github.com/junegunn/fzf"), ref: "v0.1", build: "./install --all"},
+		{reposPath: pathutil.ReposPath("github.com/scrooloose/nerdtree"), loadOn: []string{"excmd=NERDTreeToggle", "mapping=<Plug>NERDTree"}},
+		{reposPath: pathutil.ReposPath("github.com/fatih/vim-go"), ref: "master", loadOn: []string{"filetype=go"}},
+		{reposPath: pathutil.ReposPath("github.com/a/b")},
+		{reposPath: pathutil.ReposPath("github.com/c/d")},
+		{reposPath: pathutil.ReposPath("github.com/e/f")},
+	}
+	if !reflect.DeepEqual(plugins, expected) {
+		t.Errorf("expected %+v but got %+v", expected, plugins)
+	}
+	if len(untranslated) != 3 {
+		t.Fatalf("expected 3 messages but got %+v", untranslated)
+	}
+	for i, s := range []string{":6: github.com/fatih/vim-go: option 'do'", ":11: ~/my-plugin", ":12: github.com/e/f: option 'rtp'"} {
+		if !strings.Contains(untranslated[i], vimrc+s) {
+			t.Errorf("expected %q in %q", vimrc+s, untranslated[i])
+		}
+	}
+}
+
+func TestImportedPluginPlugconf(t *testing.T) {
+	p := &importedPlugin{
+		build:  "make",
+		loadOn: []string{"filetype=go", "excmd=Foo"},
+	}
+	content, err := p.plugconf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `function! s:loaded_on()
+  return ['filetype=go', 'excmd=Foo']
+endfunction
+
+function! s:build()
+  return 'make'
+endfunction
+`
+	if string(content) != expected {
+		t.Errorf("expected %q but got %q", expected, string(content))
+	}
+
+	p = &importedPlugin{build: `echo 'a' "b"`}
+	if _, err := p.plugconf(); err == nil {
+		t.Error("expected error but got nil")
+	}
+}
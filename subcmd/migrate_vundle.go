@@ -0,0 +1,94 @@
+package subcmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/haya14busa/go-vimlparser"
+	"github.com/haya14busa/go-vimlparser/ast"
+	"github.com/vim-volt/volt/subcmd/migrate"
+)
+
+func init() {
+	migrate.Register(&pluginsMigrater{
+		name:  "from-vundle",
+		brief: "installs the plugins of Vundle (Plugin commands in vimrc)",
+		usage: `Usage
+  volt migrate [-help] from-vundle {vimrc} [{vimrc2} ...]
+
+Quick example
+  $ volt migrate from-vundle ~/.vimrc
+
+Description
+  Install the plugins of "Plugin" (or "Bundle") commands in {vimrc} by
+  "volt get". The repositories are converted as follows:
+
+  * "user/repo": https://github.com/user/repo
+  * "repo" (vim-scripts shorthand): https://github.com/vim-scripts/repo
+  * git URL: the repository of the URL
+
+  volt cannot install plugins from vim.org, so vim-scripts shorthand is
+  installed from the mirror of GitHub, and it is shown after the plugins were
+  installed to check the version. The plugins of local directories, git URLs
+  which do not have "{host}/{user}/{name}" form, and the options ("rtp",
+  "name", "pinned") are also shown because they cannot be converted.
+  vimrc is not changed, so remove "Plugin" commands from vimrc after migration.`,
+		parse: parseVundle,
+	})
+}
+
+// rxVundleCommand matches to "Plugin" (or old "Bundle") command of Vundle.
+var rxVundleCommand = regexp.MustCompile(`^(?:Plugin|Bundle)!?\s+(.+)$`)
+
+// parseVundle parses "Plugin" commands in the vimrc files of paths.
+func parseVundle(paths []string) ([]importedPlugin, []string, error) {
+	return parseVimCommands(paths, rxVundleCommand, parseVundleArgs)
+}
+
+// parseVundleArgs parses the arguments of "Plugin" command:
+// "{repository} [, {options}]".
+func parseVundleArgs(args string) (*importedPlugin, []string, error) {
+	expr, err := vimlparser.ParseExpr(strings.NewReader("[" + args + "]"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse 'Plugin %s': %s", args, err.Error())
+	}
+	list, ok := expr.(*ast.List)
+	if !ok || len(list.Values) == 0 || len(list.Values) > 2 {
+		return nil, nil, fmt.Errorf("could not parse 'Plugin %s'", args)
+	}
+	repos, err := vimLiteralString(list.Values[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse 'Plugin %s': repository is %s", args, err.Error())
+	}
+
+	var msgs []string
+	switch {
+	case isLocalDir(repos):
+		return nil, nil, fmt.Errorf("%s: local directory is not installed", repos)
+	case !strings.Contains(repos, "/"):
+		repos = "vim-scripts/" + repos
+		msgs = append(msgs, "vim-scripts shorthand is installed from the mirror of GitHub (not from vim.org)")
+	}
+	reposPath, err := normalizeRemoteURL(repos)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := &importedPlugin{reposPath: reposPath}
+	if len(list.Values) == 2 {
+		dict, ok := list.Values[1].(*ast.Dict)
+		if !ok {
+			return p, append(msgs, "options are not a dictionary literal"), nil
+		}
+		for _, entry := range dict.Entries {
+			key, err := vimLiteralString(entry.Key)
+			if err != nil {
+				msgs = append(msgs, "option key is not a string literal")
+				continue
+			}
+			msgs = append(msgs, fmt.Sprintf("option '%s' is not supported", key))
+		}
+	}
+	return p, msgs, nil
+}
@@ -0,0 +1,65 @@
+package subcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func TestParseVundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	vimrc := filepath.Join(dir, "vimrc")
+	src := `
+call vundle#begin()
+Plugin 'VundleVim/Vundle.vim'
+Plugin 'tpope/vim-fugitive'  " comment
+Plugin 'L9'
+Plugin 'git://git.wincent.com/wincent/command-t.git'
+Plugin 'git@github.com:a/b.git'
+Plugin 'git://git.wincent.com/command-t.git'
+Plugin 'file:///home/gmarik/path/to/plugin'
+Bundle 'rstacruz/sparkup', {'rtp': 'vim/'}
+call vundle#end()
+`
+	if err := ioutil.WriteFile(vimrc, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins, untranslated, err := parseVundle([]string{vimrc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []importedPlugin{
+		{reposPath: pathutil.ReposPath("github.com/VundleVim/Vundle.vim")},
+		{reposPath: pathutil.ReposPath("github.com/tpope/vim-fugitive")},
+		{reposPath: pathutil.ReposPath("github.com/vim-scripts/L9")},
+		{reposPath: pathutil.ReposPath("git.wincent.com/wincent/command-t")},
+		{reposPath: pathutil.ReposPath("github.com/a/b")},
+		{reposPath: pathutil.ReposPath("github.com/rstacruz/sparkup")},
+	}
+	if !reflect.DeepEqual(plugins, expected) {
+		t.Errorf("expected %+v but got %+v", expected, plugins)
+	}
+	if len(untranslated) != 4 {
+		t.Fatalf("expected 4 messages but got %+v", untranslated)
+	}
+	for i, s := range []string{
+		":5: github.com/vim-scripts/L9: vim-scripts",
+		":8: git://git.wincent.com/command-t.git: URL",
+		":9: file:///home/gmarik/path/to/plugin: local directory",
+		":10: github.com/rstacruz/sparkup: option 'rtp'",
+	} {
+		if !strings.Contains(untranslated[i], vimrc+s) {
+			t.Errorf("expected %q in %q", vimrc+s, untranslated[i])
+		}
+	}
+}
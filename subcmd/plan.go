@@ -0,0 +1,61 @@
+package subcmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// errShowedPlan is returned when -plan option was given and the plan was
+// shown.
+var errShowedPlan = errors.New("already showed plan")
+
+// plan is the list of steps performed by a command which changes
+// repositories or lock.json.
+// Such commands compute the plan without changing anything first (plan
+// phase), and then perform the steps (apply phase). -plan option shows the
+// plan and exits before the apply phase.
+type plan struct {
+	steps []string
+}
+
+func (p *plan) add(format string, a ...interface{}) {
+	p.steps = append(p.steps, fmt.Sprintf(format, a...))
+}
+
+// show prints the steps in order.
+func (p *plan) show() {
+	if len(p.steps) == 0 {
+		fmt.Println("Nothing to do")
+		return
+	}
+	for i := range p.steps {
+		fmt.Printf("%d. %s\n", i+1, p.steps[i])
+	}
+}
+
+// addWriteLockJSON adds the step to write lock.json with changes.
+func (p *plan) addWriteLockJSON(changes int) {
+	if changes == 1 {
+		p.add("write lock.json with 1 change")
+	} else {
+		p.add("write lock.json with %d changes", changes)
+	}
+}
+
+// addBuild adds the step to build ~/.vim/pack/volt.
+func (p *plan) addBuild() {
+	p.add("build %s", pathutil.VimVoltDir())
+}
+
+// relVoltPath returns path relative to $VOLTPATH if path is under $VOLTPATH.
+// Otherwise it returns path as it is.
+func relVoltPath(path string) string {
+	if rel, err := filepath.Rel(pathutil.VoltPath(), path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
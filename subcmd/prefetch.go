@@ -0,0 +1,217 @@
+package subcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/diskcache"
+	"github.com/vim-volt/volt/gitutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/scheduler"
+	"github.com/vim-volt/volt/timing"
+	"github.com/vim-volt/volt/transaction"
+	git "gopkg.in/src-d/go-git.v4"
+)
+
+func init() {
+	cmdMap["prefetch"] = &prefetchCmd{}
+}
+
+type prefetchCmd struct {
+	helped   bool
+	daemon   bool
+	interval time.Duration
+	jobs     int
+}
+
+func (cmd *prefetchCmd) ProhibitRootExecution(args []string) bool { return true }
+
+func (cmd *prefetchCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt prefetch [-help] [-daemon] [-interval {duration}] [-j {jobs}]
+
+Quick example
+  $ volt prefetch                        # fetches all repositories once (e.g. from cron)
+  $ volt prefetch -daemon                # fetches all repositories every hour
+  $ volt prefetch -daemon -interval 30m  # fetches all repositories every 30 minutes
+
+Description
+  Fetch the upstream branches of all git repositories in lock.json (of all
+  profiles) like "git fetch". The worktrees, the current branches, and
+  lock.json are not changed.
+
+  "volt get -u" updates the repositories to the prefetched commits without
+  accessing the remote if they were fetched within 10 minutes. Otherwise it
+  fetches the new objects only, and if the remote cannot be accessed (e.g.
+  offline), the repositories are updated to the prefetched commits.
+  "volt get -check" also shows the commits prefetched within 10 minutes.
+
+  If -daemon was given, all repositories are fetched at {duration} intervals
+  (default: 1h) until volt is killed. The errors are shown, and fetching is
+  tried again at next time. While fetching, other volt commands wait for it
+  or fail like when other volt process is running (see "wait" in [lock]
+  section of config.toml), and fetching is skipped while other volt process
+  is running.
+
+  The number of repositories processed at the same time is determined by -j
+  option, or "jobs" in [get] section of config.toml (default: 8).` + "\n\n")
+		fmt.Println("Options")
+		fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	fs.BoolVar(&cmd.daemon, "daemon", false, "fetch repeatedly at -interval until volt is killed")
+	fs.DurationVar(&cmd.interval, "interval", time.Hour, "the interval of fetching with -daemon")
+	fs.IntVar(&cmd.jobs, "j", 0, "the number of parallel jobs (default: get.jobs in config.toml)")
+	return fs
+}
+
+func (cmd *prefetchCmd) Run(args []string) *Error {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return nil
+	}
+	if len(fs.Args()) > 0 {
+		fs.Usage()
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: too many arguments"}
+	}
+	if cmd.jobs < 0 {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: -j must be 1 or greater"}
+	}
+	if cmd.interval <= 0 {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: -interval must be greater than 0"}
+	}
+
+	if !cmd.daemon {
+		if err := cmd.doPrefetch(); err != nil {
+			return &Error{Code: exitCodeOf(err, ExitGeneral), Msg: err.Error()}
+		}
+		return nil
+	}
+	for {
+		if err := cmd.doPrefetch(); err != nil {
+			logger.Error(err.Error())
+		}
+		logger.Infof("Next prefetch: %s", time.Now().Add(cmd.interval).Format(time.RFC3339))
+		time.Sleep(cmd.interval)
+	}
+}
+
+type prefetchResult struct {
+	reposPath pathutil.ReposPath
+	status    string
+	err       error
+}
+
+func (cmd *prefetchCmd) doPrefetch() error {
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return errors.New("could not read lock.json: " + err.Error())
+	}
+
+	cfg, err := config.Read()
+	if err != nil {
+		return errors.New("could not read config.toml: " + err.Error())
+	}
+
+	// Begin transaction
+	err = transaction.Create()
+	if err != nil {
+		return err
+	}
+	defer transaction.Remove()
+
+	err = gitutil.CheckBackend(cfg)
+	if err != nil {
+		return err
+	}
+
+	jobs := cmd.jobs
+	if jobs == 0 {
+		jobs = cfg.Get.Jobs
+	}
+
+	done := make(chan prefetchResult, len(lockJSON.Repos))
+	sched := scheduler.New(jobs, cfg.Network.JobsPerHost)
+	fetchCount := 0
+	for i := range lockJSON.Repos {
+		repos := &lockJSON.Repos[i]
+		if repos.Type != lockjson.ReposGitType || !pathutil.Exists(repos.Path.FullPath()) {
+			continue
+		}
+		reposPath := repos.Path
+		sched.Go(reposPath.Host(), func() { done <- cmd.prefetchRepos(reposPath, cfg) })
+		fetchCount++
+	}
+
+	failed := 0
+	statusList := make([]string, 0, fetchCount)
+	for i := 0; i < fetchCount; i++ {
+		r := <-done
+		if r.err != nil {
+			failed++
+			statusList = append(statusList,
+				fmt.Sprintf("! %s > prefetch failed\n  * %s", r.reposPath, r.err.Error()))
+			continue
+		}
+		statusList = append(statusList, r.status)
+	}
+	sort.Strings(statusList)
+
+	for i := range statusList {
+		fmt.Println(statusList[i])
+	}
+	fmt.Printf("%sDone: %d fetched, %d failed\n", logger.GlyphDone, fetchCount-failed, failed)
+	if failed > 0 {
+		return &partialFailureError{msg: "failed to prefetch some repositories"}
+	}
+	return nil
+}
+
+// prefetchRepos fetches reposPath, and caches the fetched commit for "volt
+// get -check" like getCmd.checkUpgrade. Bare repositories are skipped because
+// they cannot be fetched without updating them.
+func (*prefetchCmd) prefetchRepos(reposPath pathutil.ReposPath, cfg *config.Config) prefetchResult {
+	r, err := git.PlainOpen(reposPath.FullPath())
+	if err != nil {
+		return prefetchResult{reposPath: reposPath, err: err}
+	}
+	reposCfg, err := r.Config()
+	if err != nil {
+		return prefetchResult{reposPath: reposPath, err: err}
+	}
+	if reposCfg.Core.IsBare {
+		return prefetchResult{
+			reposPath: reposPath,
+			status:    fmt.Sprintf("# %s > skipped (bare repository)", reposPath),
+		}
+	}
+
+	logger.Debug("Fetching " + reposPath + " ...")
+	stop := timing.Start(timing.Network)
+	fetched, err := gitutil.Fetch(reposPath, cfg)
+	stop()
+	if err != nil {
+		return prefetchResult{reposPath: reposPath, err: errors.New("failed to fetch: " + err.Error())}
+	}
+	if err = diskcache.Put(fetchedCacheNamespace, reposPath.String(), fetched.Remote); err != nil {
+		logger.Debug("Could not cache fetched upstream commit: " + err.Error())
+	}
+	status := fmt.Sprintf("# %s > no change", reposPath)
+	if fetched.Local != fetched.Remote {
+		status = fmt.Sprintf("* %s > fetched %s..%s", reposPath, fetched.Local, fetched.Remote)
+	}
+	return prefetchResult{reposPath: reposPath, status: status}
+}
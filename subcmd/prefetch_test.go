@@ -0,0 +1,34 @@
+package subcmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/internal/testutil"
+)
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+
+// * Run `volt prefetch` without repositories (A, B)
+//   * Shows the summary
+// * Run `volt prefetch -interval 0` (!A, !B)
+func TestVoltPrefetch(t *testing.T) {
+	t.Run("Run `volt prefetch` without repositories", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		out, err := testutil.RunVolt("prefetch")
+		testutil.SuccessExit(t, out, err)
+		if !strings.Contains(string(out), "Done: 0 fetched, 0 failed") {
+			t.Error("summary was not shown: " + string(out))
+		}
+	})
+
+	t.Run("Run `volt prefetch -interval 0`", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+
+		out, err := testutil.RunVolt("prefetch", "-daemon", "-interval", "0")
+		testutil.FailExit(t, out, err)
+	})
+}
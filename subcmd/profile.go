@@ -15,7 +15,8 @@ import (
 )
 
 type profileCmd struct {
-	helped bool
+	helped  bool
+	message string
 }
 
 var profileSubCmd = make(map[string]func([]string) error)
@@ -34,6 +35,8 @@ func (cmd *profileCmd) ProhibitRootExecution(args []string) bool {
 		return false
 	case "list":
 		return false
+	case "export":
+		return false
 	default:
 		return true
 	}
@@ -45,11 +48,12 @@ func (cmd *profileCmd) FlagSet() *flag.FlagSet {
 	fs.Usage = func() {
 		fmt.Print(`
 Usage
-  profile [-help] {command}
+  profile [-help] [-m {message}] {command}
 
 Command
   profile set [-n] {name}
     Set profile name to {name}.
+    The runtimes of recently used profiles are kept (see "cached_profiles" in [build] section of config.toml), so switching to them updates only the changed files.
 
   profile show [-current | {name}]
     Show profile info of {name}.
@@ -73,6 +77,18 @@ Command
   profile rm [-current | {name}] {repository} [{repository2} ...]
     Remove one or more repositories from profile {name}.
 
+  profile export [{name}]
+    Output lock.json which has only profile {name} (current profile by
+    default) and its repositories, to share the plugins of the profile.
+
+  profile import {lock.json} [{name}]
+    Install the repositories of current profile of {lock.json}, and create
+    profile {name} (the profile name in {lock.json} by default) which has them.
+    {lock.json} can be a file, "-" (stdin), or a URL. GitHub gist URLs
+    ("https://gist.github.com/{user}/{id}") and GitHub file URLs
+    ("https://github.com/{user}/{repo}/blob/{ref}/{path}") are read as the
+    raw content.
+
 Quick example
   $ volt profile list   # default profile is "default"
   * default
@@ -93,9 +109,17 @@ Quick example
   $ volt disable tyru/caw.vim   # disable loading tyru/caw.vim on current profile
   $ volt profile rm foo tyru/caw.vim    # disable loading tyru/caw.vim on "foo" profile
 
-  $ volt profile destroy foo   # will delete profile "foo"` + "\n\n")
+  $ volt profile destroy foo   # will delete profile "foo"
+
+  $ volt profile export foo | gh gist create -f lock.json -   # will share profile "foo" by gist
+  $ volt profile import https://gist.github.com/tyru/0123abcd   # will install the plugins of the gist as profile "foo"
+
+Description
+  If -m option was given, {message} is recorded as the reason of the change
+  (see "volt log").` + "\n\n")
 		cmd.helped = true
 	}
+	fs.StringVar(&cmd.message, "m", "", messageFlagUsage)
 	return fs
 }
 
@@ -106,8 +130,9 @@ func (cmd *profileCmd) Run(args []string) *Error {
 		return nil
 	}
 	if err != nil {
-		return &Error{Code: 10, Msg: err.Error()}
+		return &Error{Code: ExitUsage, Msg: err.Error()}
 	}
+	transaction.SetMessage(cmd.message)
 
 	subCmd := args[0]
 	switch subCmd {
@@ -127,12 +152,16 @@ func (cmd *profileCmd) Run(args []string) *Error {
 		err = cmd.doAdd(args[1:])
 	case "rm":
 		err = cmd.doRm(args[1:])
+	case "export":
+		err = cmd.doExport(args[1:])
+	case "import":
+		err = cmd.doImport(args[1:])
 	default:
-		return &Error{Code: 11, Msg: "Unknown subcommand: " + subCmd}
+		return &Error{Code: ExitUsage, Msg: "Unknown subcommand: " + subCmd}
 	}
 
 	if err != nil {
-		return &Error{Code: 20, Msg: err.Error()}
+		return &Error{Code: exitCodeOf(err, ExitGeneral), Msg: err.Error()}
 	}
 
 	return nil
@@ -146,8 +175,7 @@ func (cmd *profileCmd) parseArgs(args []string) ([]string, error) {
 	}
 	if len(fs.Args()) == 0 {
 		fs.Usage()
-		logger.Error("must specify subcommand")
-		return nil, ErrShowedHelp
+		return nil, errors.New("must specify subcommand")
 	}
 	return fs.Args(), nil
 }
@@ -169,8 +197,7 @@ func (cmd *profileCmd) doSet(args []string) error {
 	}
 	if len(args) == 0 {
 		cmd.FlagSet().Usage()
-		logger.Error("'volt profile set' receives profile name.")
-		return nil
+		return &usageError{msg: "'volt profile set' receives profile name"}
 	}
 	profileName := args[0]
 
@@ -185,22 +212,11 @@ func (cmd *profileCmd) doSet(args []string) error {
 		return fmt.Errorf("'%s' is current profile", profileName)
 	}
 
-	// Create given profile unless the profile exists
-	if _, err = lockJSON.Profiles.FindByName(profileName); err != nil {
-		if !createProfile {
-			return err
-		}
-		if err = cmd.doNew([]string{profileName}); err != nil {
-			return err
-		}
-		// Read lock.json again
-		lockJSON, err = lockjson.Read()
-		if err != nil {
-			return errors.New("failed to read lock.json: " + err.Error())
-		}
-		if _, err = lockJSON.Profiles.FindByName(profileName); err != nil {
-			return err
-		}
+	// Return error if the profile does not exist and -n was not given
+	_, err = lockJSON.Profiles.FindByName(profileName)
+	exists := err == nil
+	if !exists && !createProfile {
+		return err
 	}
 
 	// Begin transaction
@@ -210,6 +226,16 @@ func (cmd *profileCmd) doSet(args []string) error {
 	}
 	defer transaction.Remove()
 
+	// Create given profile unless the profile exists
+	// (lock.json is written only once with the change of current profile)
+	if !exists {
+		lockJSON.Profiles = append(lockJSON.Profiles, lockjson.Profile{
+			Name:      profileName,
+			ReposPath: make([]pathutil.ReposPath, 0),
+		})
+		logger.Info("Created new profile '" + profileName + "'")
+	}
+
 	// Set profile name
 	lockJSON.CurrentProfileName = profileName
 
@@ -227,14 +253,13 @@ func (cmd *profileCmd) doSet(args []string) error {
 		return errors.New("could not build " + pathutil.VimVoltDir() + ": " + err.Error())
 	}
 
-	return nil
+	return transaction.Commit()
 }
 
 func (cmd *profileCmd) doShow(args []string) error {
 	if len(args) == 0 {
 		cmd.FlagSet().Usage()
-		logger.Error("'volt profile show' receives profile name.")
-		return nil
+		return &usageError{msg: "'volt profile show' receives profile name"}
 	}
 
 	// Read lock.json
@@ -274,8 +299,7 @@ func (cmd *profileCmd) doList(args []string) error {
 func (cmd *profileCmd) doNew(args []string) error {
 	if len(args) == 0 {
 		cmd.FlagSet().Usage()
-		logger.Error("'volt profile new' receives profile name.")
-		return nil
+		return &usageError{msg: "'volt profile new' receives profile name"}
 	}
 	profileName := args[0]
 
@@ -312,14 +336,13 @@ func (cmd *profileCmd) doNew(args []string) error {
 
 	logger.Info("Created new profile '" + profileName + "'")
 
-	return nil
+	return transaction.Commit()
 }
 
 func (cmd *profileCmd) doDestroy(args []string) error {
 	if len(args) == 0 {
 		cmd.FlagSet().Usage()
-		logger.Error("'volt profile destroy' receives profile name.")
-		return nil
+		return &usageError{msg: "'volt profile destroy' receives profile name"}
 	}
 
 	// Read lock.json
@@ -356,9 +379,10 @@ func (cmd *profileCmd) doDestroy(args []string) error {
 
 		// Remove $VOLTPATH/rc/{profile} dir
 		rcDir := pathutil.RCDir(profileName)
-		os.RemoveAll(rcDir)
 		if pathutil.Exists(rcDir) {
-			return errors.New("failed to remove " + rcDir)
+			if err = transaction.RemoveAll(rcDir); err != nil {
+				return errors.New("failed to remove " + rcDir + ": " + err.Error())
+			}
 		}
 
 		logger.Info("Deleted profile '" + profileName + "'")
@@ -369,6 +393,9 @@ func (cmd *profileCmd) doDestroy(args []string) error {
 	if err != nil {
 		return err
 	}
+	if err = transaction.Commit(); err != nil {
+		return err
+	}
 
 	return merr.ErrorOrNil()
 }
@@ -376,8 +403,7 @@ func (cmd *profileCmd) doDestroy(args []string) error {
 func (cmd *profileCmd) doRename(args []string) error {
 	if len(args) != 2 {
 		cmd.FlagSet().Usage()
-		logger.Error("'volt profile rename' receives profile name.")
-		return nil
+		return &usageError{msg: "'volt profile rename' receives old and new profile names"}
 	}
 	oldName := args[0]
 	newName := args[1]
@@ -429,7 +455,7 @@ func (cmd *profileCmd) doRename(args []string) error {
 
 	logger.Infof("Renamed profile '%s' to '%s'", oldName, newName)
 
-	return nil
+	return transaction.Commit()
 }
 
 func (cmd *profileCmd) doAdd(args []string) error {
@@ -442,6 +468,9 @@ func (cmd *profileCmd) doAdd(args []string) error {
 	// Parse args
 	profileName, reposPathList, err := cmd.parseAddArgs(lockJSON, "add", args)
 	if err != nil {
+		if _, ok := err.(*usageError); ok {
+			return err
+		}
 		return errors.New("failed to parse args: " + err.Error())
 	}
 
@@ -484,6 +513,9 @@ func (cmd *profileCmd) doRm(args []string) error {
 	// Parse args
 	profileName, reposPathList, err := cmd.parseAddArgs(lockJSON, "rm", args)
 	if err != nil {
+		if _, ok := err.(*usageError); ok {
+			return err
+		}
 		return errors.New("failed to parse args: " + err.Error())
 	}
 
@@ -518,11 +550,91 @@ func (cmd *profileCmd) doRm(args []string) error {
 	return nil
 }
 
+func (cmd *profileCmd) doExport(args []string) error {
+	if len(args) > 1 {
+		cmd.FlagSet().Usage()
+		return &usageError{msg: "'volt profile export' receives one profile name"}
+	}
+
+	// Read lock.json
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return errors.New("failed to read lock.json: " + err.Error())
+	}
+
+	profileName := lockJSON.CurrentProfileName
+	if len(args) == 1 {
+		profileName = args[0]
+	}
+	profile, err := lockJSON.Profiles.FindByName(profileName)
+	if err != nil {
+		return err
+	}
+
+	// Leave only the profile and its repositories
+	exported := *lockJSON
+	exported.CurrentProfileName = profileName
+	exported.Profiles = lockjson.ProfileList{*profile}
+	exported.Repos = make(lockjson.ReposList, 0, len(profile.ReposPath))
+	for i := range lockJSON.Repos {
+		if profile.ReposPath.Contains(lockJSON.Repos[i].Path) {
+			exported.Repos = append(exported.Repos, lockJSON.Repos[i])
+		}
+	}
+	content, err := (&exportCmd{}).marshalLockJSON(&exported)
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(content)
+	return nil
+}
+
+func (cmd *profileCmd) doImport(args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		cmd.FlagSet().Usage()
+		return &usageError{msg: "'volt profile import' receives lock.json and profile name"}
+	}
+
+	importer := &importCmd{}
+	imported, err := importer.readImportedLockJSON(args[0])
+	if err != nil {
+		msg := "could not read " + args[0] + ": " + err.Error()
+		if _, ok := err.(*networkError); ok {
+			return &networkError{err: errors.New(msg)}
+		}
+		return errors.New(msg)
+	}
+	importer.profile = imported.CurrentProfileName
+	if len(args) == 2 {
+		importer.profile = args[1]
+	}
+
+	// Read lock.json
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return errors.New("failed to read lock.json: " + err.Error())
+	}
+
+	// Import only the repositories of the profile
+	profile, err := imported.Profiles.FindByName(imported.CurrentProfileName)
+	if err != nil {
+		return err
+	}
+	reposList := make(lockjson.ReposList, 0, len(profile.ReposPath))
+	for i := range imported.Repos {
+		if profile.ReposPath.Contains(imported.Repos[i].Path) {
+			reposList = append(reposList, imported.Repos[i])
+		}
+	}
+	imported.Repos = reposList
+
+	return importer.doImport(lockJSON, imported)
+}
+
 func (cmd *profileCmd) parseAddArgs(lockJSON *lockjson.LockJSON, subCmd string, args []string) (string, []pathutil.ReposPath, error) {
 	if len(args) == 0 {
 		cmd.FlagSet().Usage()
-		logger.Errorf("'volt profile %s' receives profile name and one or more repositories.", subCmd)
-		return "", nil, nil
+		return "", nil, &usageError{msg: fmt.Sprintf("'volt profile %s' receives profile name and one or more repositories", subCmd)}
 	}
 
 	profileName := args[0]
@@ -568,5 +680,5 @@ func (*profileCmd) transactProfile(lockJSON *lockjson.LockJSON, profileName stri
 	if err != nil {
 		return nil, err
 	}
-	return lockJSON, nil
+	return lockJSON, transaction.Commit()
 }
@@ -0,0 +1,306 @@
+package subcmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func init() {
+	cmdMap["profile-startup"] = &profileStartupCmd{}
+}
+
+type profileStartupCmd struct {
+	helped bool
+	nvim   bool
+	count  int
+}
+
+func (cmd *profileStartupCmd) ProhibitRootExecution(args []string) bool { return true }
+
+func (cmd *profileStartupCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt profile-startup [-help] [-nvim] [-n {count}]
+
+Quick example
+  $ volt profile-startup        # will show startup time of vim per plugin
+  $ volt profile-startup -nvim  # will show startup time of neovim per plugin
+  $ volt profile-startup -n 5   # will start vim 5 times, and show average time
+
+Description
+  Start vim (or neovim if -nvim was given) with --startuptime option, and
+  show the time spent for sourcing the scripts of each plugin, sorted by the
+  time (the slowest plugin first). Vim quits immediately after startup.
+
+  The time of the scripts which are not of plugins is shown as
+  "(bundled plugconf)" (the configuration in plugconf files) and "(others)"
+  (vimrc, $VIMRUNTIME, ...).
+
+  The result is saved to "$VOLTPATH/startup-profile.json", and the difference
+  from the previous result (of the same editor) is shown in "diff" column.
+
+  vim (or nvim) executable is looked up from PATH, or VOLT_VIM (or VOLT_NVIM)
+  environment variable.` + "\n\n")
+		fmt.Println("Options")
+		fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	fs.BoolVar(&cmd.nvim, "nvim", false, "profile neovim instead of vim")
+	fs.IntVar(&cmd.count, "n", 1, "start vim {count} times, and show average time")
+	return fs
+}
+
+func (cmd *profileStartupCmd) Run(args []string) *Error {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return nil
+	}
+	if len(fs.Args()) > 0 {
+		fs.Usage()
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: too many arguments"}
+	}
+	if cmd.count < 1 {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: -n must be 1 or greater"}
+	}
+
+	err := cmd.doProfileStartup()
+	if err != nil {
+		return &Error{Code: ExitGeneral, Msg: err.Error()}
+	}
+	return nil
+}
+
+// startupProfile is the result of "volt profile-startup".
+type startupProfile struct {
+	// Total is the startup time (msec)
+	Total float64 `json:"total"`
+	// Plugins is the time (msec) spent for sourcing the scripts of each
+	// plugin, bundled plugconf (startupBundledPlugconf), and the other
+	// scripts (startupOthers)
+	Plugins map[string]float64 `json:"plugins"`
+}
+
+const (
+	startupBundledPlugconf = "(bundled plugconf)"
+	startupOthers          = "(others)"
+)
+
+func (cmd *profileStartupCmd) doProfileStartup() error {
+	editor := "vim"
+	exe, err := pathutil.VimExecutable()
+	if cmd.nvim {
+		editor = "nvim"
+		exe, err = pathutil.NvimExecutable()
+	}
+	if err != nil {
+		return fmt.Errorf("%s executable is not found: %s", editor, err.Error())
+	}
+
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return errors.New("could not read lock.json: " + err.Error())
+	}
+
+	profile := &startupProfile{Plugins: make(map[string]float64, len(lockJSON.Repos))}
+	for i := 0; i < cmd.count; i++ {
+		p, err := cmd.startEditor(exe, lockJSON.Repos)
+		if err != nil {
+			return err
+		}
+		profile.Total += p.Total / float64(cmd.count)
+		for name, msec := range p.Plugins {
+			profile.Plugins[name] += msec / float64(cmd.count)
+		}
+	}
+
+	// Read the previous results, and save current result
+	results := make(map[string]*startupProfile, 2)
+	if content, err := ioutil.ReadFile(pathutil.StartupProfileJSON()); err == nil {
+		json.Unmarshal(content, &results)
+	}
+	prev := results[editor]
+	results[editor] = profile
+	content, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(pathutil.StartupProfileJSON(), content, 0644); err != nil {
+		return err
+	}
+
+	cmd.printProfile(os.Stdout, editor, profile, prev)
+	return nil
+}
+
+// startEditor starts exe with --startuptime option, and returns the parsed
+// result.
+func (cmd *profileStartupCmd) startEditor(exe string, reposList lockjson.ReposList) (*startupProfile, error) {
+	tmp, err := ioutil.TempFile("", "volt-startuptime-")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	var args []string
+	if cmd.nvim {
+		args = []string{"--headless", "--startuptime", tmp.Name(), "-c", "qall!"}
+	} else {
+		args = []string{"--not-a-term", "--startuptime", tmp.Name(), "-c", "qall!"}
+	}
+	c := exec.Command(exe, args...)
+	c.Stdin = os.Stdin
+	if out, err := c.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %s: %s", exe, err.Error(), strings.TrimSpace(string(out)))
+	}
+
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseStartupTime(f, startupDirs(reposList))
+}
+
+// startupDirs returns the directories of the scripts of each plugin: the
+// directories installed by "volt build", and the repositories (the scripts
+// may be shown with resolved symlinks, including "(vim dir)/pack/volt" which
+// is a symlink to the runtime of current profile).
+func startupDirs(reposList lockjson.ReposList) map[string]string {
+	dirs := make(map[string]string, len(reposList)*3+2)
+	add := func(dir, name string) {
+		dirs[dir] = name
+		if parent, err := filepath.EvalSymlinks(filepath.Dir(dir)); err == nil {
+			dirs[filepath.Join(parent, filepath.Base(dir))] = name
+		}
+	}
+	for i := range reposList {
+		name := reposList[i].Path.String()
+		add(reposList[i].Path.EncodeToPlugDirName(), name)
+		dirs[reposList[i].Path.FullPath()] = name
+	}
+	add(filepath.Dir(pathutil.BundledPlugConf()), startupBundledPlugconf)
+	return dirs
+}
+
+// rxStartupSourcing matches to the lines of sourced scripts in the output of
+// --startuptime: "{clock}  {self+sourced}  {self}: sourcing {script}".
+var rxStartupSourcing = regexp.MustCompile(`^([0-9.]+)\s+[0-9.]+\s+([0-9.]+): sourcing (.+)$`)
+
+// rxStartupClock matches to the clock at the beginning of the lines in the
+// output of --startuptime.
+var rxStartupClock = regexp.MustCompile(`^([0-9]+\.[0-9]+)\s`)
+
+// parseStartupTime parses the output of --startuptime. The time of a script
+// is added to the plugin of dirs (directory -> plugin name) which has the
+// script, or startupOthers.
+// If vim was started two or more times in the output, only the last one is
+// parsed.
+func parseStartupTime(r io.Reader, dirs map[string]string) (*startupProfile, error) {
+	var profile *startupProfile
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, "--- VIM STARTING ---") || strings.Contains(line, "--- NVIM STARTING ---") {
+			profile = &startupProfile{Plugins: make(map[string]float64, len(dirs))}
+		}
+		if profile == nil {
+			continue
+		}
+		if m := rxStartupClock.FindStringSubmatch(line); m != nil {
+			if clock, err := strconv.ParseFloat(m[1], 64); err == nil && clock > profile.Total {
+				profile.Total = clock
+			}
+		}
+		m := rxStartupSourcing.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		self, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			continue
+		}
+		profile.Plugins[startupOwner(filepath.Clean(m[3]), dirs)] += self
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		return nil, errors.New("could not parse the output of --startuptime")
+	}
+	return profile, nil
+}
+
+// startupOwner returns the plugin name of dirs which has script.
+func startupOwner(script string, dirs map[string]string) string {
+	for dir := script; ; {
+		if name, exists := dirs[dir]; exists {
+			return name
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return startupOthers
+		}
+		dir = parent
+	}
+}
+
+func (cmd *profileStartupCmd) printProfile(w io.Writer, editor string, profile, prev *startupProfile) {
+	names := make([]string, 0, len(profile.Plugins))
+	for name := range profile.Plugins {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := profile.Plugins[names[i]], profile.Plugins[names[j]]
+		if a != b {
+			return a > b
+		}
+		return names[i] < names[j]
+	})
+
+	diff := func(name string, msec float64) string {
+		if prev == nil {
+			return ""
+		}
+		prevMsec := prev.Total
+		if name != "" {
+			var exists bool
+			if prevMsec, exists = prev.Plugins[name]; !exists {
+				return "new"
+			}
+		}
+		return fmt.Sprintf("%+.3f", msec-prevMsec)
+	}
+
+	fmt.Fprintf(w, "Startup time of %s: %.3f msec", editor, profile.Total)
+	if prev != nil {
+		fmt.Fprintf(w, " (%s msec)", diff("", profile.Total))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%10s  %10s  %s\n", "msec", "diff", "plugin")
+	for _, name := range names {
+		msec := profile.Plugins[name]
+		fmt.Fprintf(w, "%10.3f  %10s  %s\n", msec, diff(name, msec), name)
+	}
+}
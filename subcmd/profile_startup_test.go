@@ -0,0 +1,106 @@
+package subcmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/internal/testutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+// (C) Shows the time of the plugins
+// (D) Saves the result to `$VOLTPATH/startup-profile.json`
+// (E) Shows the difference from the previous result
+
+// * Run `volt build` and `volt profile-startup` (A, B, C, D, !E)
+// * Run `volt profile-startup` again (A, B, C, D, E)
+func TestVoltProfileStartup(t *testing.T) {
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("localhost/local/hello")
+	teardown := testutil.SetUpRepos(t, "hello", lockjson.ReposStaticType, []pathutil.ReposPath{reposPath}, config.SymlinkBuilder)
+	defer teardown()
+	if _, err := pathutil.VimExecutable(); err != nil {
+		t.Skip("vim is not found")
+	}
+	out, err := testutil.RunVolt("build")
+	testutil.SuccessExit(t, out, err)
+
+	for i, diff := range []bool{false, true} {
+		out, err := testutil.RunVolt("profile-startup")
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+
+		// (C)
+		lines := strings.Split(string(out), "\n")
+		if !strings.HasPrefix(lines[0], "Startup time of vim: ") {
+			t.Errorf("[%d] unexpected output: %s", i, string(out))
+		}
+		found := false
+		for _, line := range lines {
+			if fields := strings.Fields(line); len(fields) > 0 && fields[len(fields)-1] == reposPath.String() {
+				found = true
+				// (E)
+				if hasDiff := len(fields) == 3; hasDiff != diff {
+					t.Errorf("[%d] expected diff=%v but got: %s", i, diff, line)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("[%d] %s is not shown: %s", i, reposPath, string(out))
+		}
+
+		// (D)
+		var results map[string]*startupProfile
+		content, err := ioutil.ReadFile(pathutil.StartupProfileJSON())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(content, &results); err != nil {
+			t.Fatal(err)
+		}
+		if results["vim"] == nil || results["vim"].Total <= 0 {
+			t.Errorf("[%d] unexpected startup-profile.json: %s", i, string(content))
+		}
+	}
+}
+
+func TestParseStartupTime(t *testing.T) {
+	optDir := filepath.Join(string(os.PathSeparator)+"home", ".vim", "pack", "volt", "opt")
+	dirs := map[string]string{
+		filepath.Join(optDir, "github.com_a_b"): "github.com/a/b",
+	}
+	src := `
+
+times in msec
+ clock   self+sourced   self:  sourced script
+ clock   elapsed:              other lines
+
+000.007  000.007: --- VIM STARTING ---
+001.405  000.500  000.300: sourcing /home/.vimrc
+002.000  000.400  000.250: sourcing ` + filepath.Join(optDir, "github.com_a_b", "plugin", "b.vim") + `
+002.500  000.300  000.100: sourcing ` + filepath.Join(optDir, "github.com_a_b", "autoload", "b.vim") + `
+010.250  000.020: --- VIM STARTED ---
+`
+	profile, err := parseStartupTime(strings.NewReader(src), dirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Total != 10.25 {
+		t.Errorf("expected total 10.25 but got %v", profile.Total)
+	}
+	if msec := profile.Plugins["github.com/a/b"]; msec < 0.349 || msec > 0.351 {
+		t.Errorf("expected 0.35 msec for github.com/a/b but got %v", msec)
+	}
+	if msec := profile.Plugins[startupOthers]; msec != 0.3 {
+		t.Errorf("expected 0.3 msec for others but got %v", msec)
+	}
+}
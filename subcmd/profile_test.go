@@ -3,8 +3,12 @@ package subcmd
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/vim-volt/volt/config"
 	"github.com/vim-volt/volt/internal/testutil"
@@ -19,10 +23,12 @@ import (
 // Checks:
 // (a) Changes current profile
 // (b) Plugins of specified profile are installed under vim dir
+// (c) The profile is created in one transaction
+// (d) No temporary files of lock.json and build-info.json are left
 //
 // * Run `volt profile set <profile>` (`<profile>` is not current profile) (A, B, a, b)
 // * Run `volt profile set <profile>` (`<profile>` is current profile) (!A, !B, !a)
-// * Run `volt profile set -n <profile>` (`<profile>` is not current profile and non-existing profile) (A, B, a)
+// * Run `volt profile set -n <profile>` (`<profile>` is not current profile and non-existing profile) (A, B, a, c, d)
 func TestVoltProfileSet(t *testing.T) {
 	t.Run("Run `volt profile set <profile>` (`<profile>` is not current profile)", func(t *testing.T) {
 		testProfileMatrix(t, func(t *testing.T, strategy string) {
@@ -115,10 +121,111 @@ func TestVoltProfileSet(t *testing.T) {
 			if lockJSON.CurrentProfileName != profileName {
 				t.Errorf("expected: %s, got: %s", profileName, lockJSON.CurrentProfileName)
 			}
+
+			// (c)
+			if _, err = lockJSON.Profiles.FindByName(profileName); err != nil {
+				t.Error(err)
+			}
+			out, err = testutil.RunVolt("log")
+			testutil.SuccessExit(t, out, err)
+			if !strings.Contains(string(out), "#1 ") || strings.Contains(string(out), "#2 ") {
+				t.Errorf("expected only one transaction but got: %s", string(out))
+			}
+
+			// (d)
+			for _, file := range []string{pathutil.LockJSON(), pathutil.BuildInfoJSON()} {
+				if pathutil.Exists(file + ".tmp") {
+					t.Error("temporary file was left: " + file + ".tmp")
+				}
+			}
 		})
 	})
 }
 
+// Checks:
+// (a) `~/.vim/pack/volt` is a symlink to the runtime of current profile
+// (b) The runtime of the previous profile is kept, and is not rebuilt when switching back to it
+// (c) `~/.vim/pack/volt` is a directory and the runtimes of profiles are removed if `build.cached_profiles` is 0
+//
+// * Run `volt profile set <profile>` twice (`build.cached_profiles` is 3) (A, B, a, b)
+// * Run `volt build` (`build.cached_profiles` is 0) (A, B, c)
+func TestVoltProfileSetCachedRuntime(t *testing.T) {
+	testProfileMatrix(t, func(t *testing.T, strategy string) {
+		// =============== setup =============== //
+
+		testutil.SetUpEnv(t)
+		reposPath := pathutil.ReposPath("localhost/local/hello")
+		teardown := testutil.SetUpRepos(t, "hello", lockjson.ReposStaticType, []pathutil.ReposPath{reposPath}, strategy)
+		defer teardown()
+		testutil.InstallConfig(t, "strategy-"+strategy+".toml")
+		out, err := testutil.RunVolt("build")
+		testutil.SuccessExit(t, out, err)
+		out, err = testutil.RunVolt("profile", "new", "foo")
+		testutil.SuccessExit(t, out, err)
+
+		// =============== run =============== //
+
+		out, err = testutil.RunVolt("profile", "set", "foo")
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+
+		// (a)
+		checkRuntimeLink(t, "foo")
+		if pathutil.Exists(reposPath.EncodeToPlugDirName()) {
+			t.Errorf("%s exists in profile foo", reposPath.EncodeToPlugDirName())
+		}
+
+		// (b)
+		bundledPlugconf := filepath.Join(pathutil.VimVoltProfileDir("default"), "start", "system", "plugin", "bundled_plugconf.vim")
+		old := time.Now().Add(-time.Hour).Truncate(time.Second)
+		if err = os.Chtimes(bundledPlugconf, old, old); err != nil {
+			t.Fatal(err)
+		}
+		out, err = testutil.RunVolt("profile", "set", "default")
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		checkRuntimeLink(t, "default")
+		if !pathutil.Exists(reposPath.EncodeToPlugDirName()) {
+			t.Errorf("%s does not exist in profile default", reposPath.EncodeToPlugDirName())
+		}
+		if st, err := os.Stat(pathutil.BundledPlugConf()); err != nil || !st.ModTime().Equal(old) {
+			t.Errorf("bundled plugconf of profile default was rebuilt")
+		}
+
+		out, err = testutil.RunVolt("config", "set", "build.cached_profiles", "0")
+		testutil.SuccessExit(t, out, err)
+		out, err = testutil.RunVolt("build")
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+
+		// (c)
+		if fi, err := os.Lstat(pathutil.VimVoltDir()); err != nil || !fi.IsDir() {
+			t.Errorf("%s is not a directory", pathutil.VimVoltDir())
+		}
+		if pathutil.Exists(pathutil.VimVoltProfilesDir()) {
+			t.Errorf("%s exists", pathutil.VimVoltProfilesDir())
+		}
+		if !pathutil.Exists(reposPath.EncodeToPlugDirName()) {
+			t.Errorf("%s does not exist", reposPath.EncodeToPlugDirName())
+		}
+	})
+}
+
+func checkRuntimeLink(t *testing.T, profileName string) {
+	t.Helper()
+	target, err := filepath.EvalSymlinks(pathutil.VimVoltDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := filepath.EvalSymlinks(pathutil.VimVoltProfileDir(profileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != expected {
+		t.Errorf("expected %s is a symlink to %s but got %s", pathutil.VimVoltDir(), expected, target)
+	}
+}
+
 // Checks:
 // (a) Output has profile name
 // (b) Output has "repos path"
@@ -1192,6 +1299,70 @@ func testNotChangedProfileExcept(t *testing.T, oldLockJSON *lockjson.LockJSON, n
 	}
 }
 
+// Checks:
+// (a) The output of `volt profile export` has only the profile and its repositories
+// (b) `volt profile import` creates the profile which has the repositories
+//
+// * Run `volt profile export <profile>` (A, B, a)
+// * Run `volt profile import <lock.json> <profile>` (A, B, b)
+// * Run `volt profile import <lock.json> <profile>` (`<profile>` exists) (!B)
+func TestVoltProfileExportImport(t *testing.T) {
+	testProfileMatrix(t, func(t *testing.T, strategy string) {
+		// =============== setup =============== //
+
+		testutil.SetUpEnv(t)
+		reposPathList := []pathutil.ReposPath{"localhost/local/hello"}
+		teardown := testutil.SetUpRepos(t, "hello", lockjson.ReposStaticType, reposPathList, strategy)
+		defer teardown()
+		testutil.InstallConfig(t, "strategy-"+strategy+".toml")
+
+		out, err := testutil.RunVolt("profile", "new", "foo")
+		testutil.SuccessExit(t, out, err)
+		out, err = testutil.RunVolt("profile", "add", "foo", "localhost/local/hello")
+		testutil.SuccessExit(t, out, err)
+		out, err = testutil.RunVolt("profile", "rm", "default", "localhost/local/hello")
+		testutil.SuccessExit(t, out, err)
+
+		// =============== run =============== //
+
+		out, err = testutil.RunVolt("profile", "export", "foo")
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (a)
+		exported, err := lockjson.Parse(out)
+		if err != nil {
+			t.Fatal("lockjson.Parse() returned non-nil error: " + err.Error())
+		}
+		if exported.CurrentProfileName != "foo" || len(exported.Profiles) != 1 || len(exported.Repos) != 1 {
+			t.Errorf("expected only profile 'foo' and its repository but got: %s", string(out))
+		}
+
+		file := filepath.Join(os.Getenv("HOME"), "foo.json")
+		if err := ioutil.WriteFile(file, out, 0644); err != nil {
+			t.Fatal(err)
+		}
+		out, err = testutil.RunVolt("profile", "import", file, "bar")
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (b)
+		lockJSON, err := lockjson.Read()
+		if err != nil {
+			t.Fatal("lockjson.Read() returned non-nil error: " + err.Error())
+		}
+		profile, err := lockJSON.Profiles.FindByName("bar")
+		if err != nil {
+			t.Fatal("profile 'bar' was not created: " + err.Error())
+		}
+		if len(profile.ReposPath) != 1 || profile.ReposPath[0] != reposPathList[0] {
+			t.Errorf("expected profile 'bar' has %v but got %v", reposPathList, profile.ReposPath)
+		}
+
+		out, err = testutil.RunVolt("profile", "import", file, "bar")
+		// (!B)
+		testutil.FailExit(t, out, err)
+	})
+}
+
 func testProfileMatrix(t *testing.T, f func(*testing.T, string)) {
 	for _, strategy := range testutil.AvailableStrategies() {
 		t.Run(fmt.Sprintf("strategy=%v", strategy), func(t *testing.T) {
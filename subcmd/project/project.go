@@ -0,0 +1,168 @@
+// Package project reads the plugin sets of projects (.volt.json), and the
+// projects registered by "volt env".
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// FileName is the name of the file which declares the plugins of a project.
+const FileName = ".volt.json"
+
+// EnvName is the name of the environment variable which has the directory
+// of current project. It is set by "volt env".
+const EnvName = "VOLT_PROJECT"
+
+// Project is the plugin set of a project.
+type Project struct {
+	// Plugins is the repositories used in the project
+	Plugins []string `json:"plugins"`
+	// Vimrc is Vim script sourced after the plugins are loaded (relative to
+	// Dir)
+	Vimrc string `json:"vimrc,omitempty"`
+
+	// Dir is the directory which has .volt.json
+	Dir string `json:"-"`
+	// ReposPath is normalized Plugins
+	ReposPath pathutil.ReposPathList `json:"-"`
+}
+
+// Find returns the directory which has .volt.json in dir or its parents.
+func Find(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		if pathutil.Exists(filepath.Join(dir, FileName)) {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// Read reads and validates .volt.json in dir.
+func Read(dir string) (*Project, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return nil, err
+	}
+	p := &Project{Dir: dir}
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("%s: %s", filepath.Join(dir, FileName), err.Error())
+	}
+	p.ReposPath = make(pathutil.ReposPathList, 0, len(p.Plugins))
+	seen := make(map[pathutil.ReposPath]bool, len(p.Plugins))
+	for _, plugin := range p.Plugins {
+		reposPath, err := pathutil.NormalizeRepos(plugin)
+		if err != nil {
+			return nil, err
+		}
+		if seen[reposPath] {
+			return nil, errors.New("duplicate plugin: " + reposPath.String())
+		}
+		seen[reposPath] = true
+		p.ReposPath = append(p.ReposPath, reposPath)
+	}
+	if p.Vimrc != "" && !pathutil.Exists(p.VimrcPath()) {
+		return nil, errors.New("vimrc does not exist: " + p.VimrcPath())
+	}
+	return p, nil
+}
+
+// VimrcPath returns the full path of the vimrc of the project, or "" if it
+// is not specified.
+func (p *Project) VimrcPath() string {
+	if p.Vimrc == "" || filepath.IsAbs(p.Vimrc) {
+		return p.Vimrc
+	}
+	return filepath.Join(p.Dir, p.Vimrc)
+}
+
+// rxUnsafeChars matches to the characters which are replaced in the file
+// name of project script. The same replacement is done in Vim script (see
+// LoaderScript).
+var rxUnsafeChars = regexp.MustCompile(`[^0-9A-Za-z._-]`)
+
+// ScriptPath returns the path of the script which loads the plugins of the
+// project in dir (generated by "volt build").
+func ScriptPath(dir string) string {
+	return filepath.Join(pathutil.BundledProjectPlugconfDir(), rxUnsafeChars.ReplaceAllString(dir, "_")+".vim")
+}
+
+// LoaderScript returns the content of pathutil.BundledProjectLoader(), which
+// sources the script of current project on startup.
+func LoaderScript() string {
+	dir := strings.Replace(pathutil.RuntimePath(pathutil.BundledProjectPlugconfDir()), "'", "''", -1)
+	return `" This file is generated by "volt build".
+" It loads the plugins of the project in $` + EnvName + ` (set by "volt env").
+if $` + EnvName + ` !=# ''
+  let s:script = '` + dir + `/' . substitute($` + EnvName + `, '[^0-9A-Za-z._-]', '_', 'g') . '.vim'
+  if filereadable(s:script)
+    execute 'source' fnameescape(s:script)
+  endif
+  unlet s:script
+endif
+`
+}
+
+// registry is the content of projects.json.
+type registry struct {
+	Projects []string `json:"projects"`
+}
+
+// Registered returns the directories of the projects registered by
+// "volt env".
+func Registered() ([]string, error) {
+	content, err := ioutil.ReadFile(pathutil.ProjectsJSON())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r registry
+	if err = json.Unmarshal(content, &r); err != nil {
+		return nil, errors.New("could not parse " + pathutil.ProjectsJSON() + ": " + err.Error())
+	}
+	return r.Projects, nil
+}
+
+// Register adds dir to the registered projects, and returns true if it was
+// not registered.
+func Register(dir string) (bool, error) {
+	dirs, err := Registered()
+	if err != nil {
+		return false, err
+	}
+	for i := range dirs {
+		if dirs[i] == dir {
+			return false, nil
+		}
+	}
+	dirs = append(dirs, dir)
+	sort.Strings(dirs)
+	content, err := json.MarshalIndent(&registry{Projects: dirs}, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	os.MkdirAll(filepath.Dir(pathutil.ProjectsJSON()), 0755)
+	return true, ioutil.WriteFile(pathutil.ProjectsJSON(), content, 0644)
+}
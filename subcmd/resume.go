@@ -0,0 +1,78 @@
+package subcmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vim-volt/volt/transaction"
+)
+
+func init() {
+	cmdMap["resume"] = &resumeCmd{}
+}
+
+type resumeCmd struct {
+	helped bool
+}
+
+func (cmd *resumeCmd) ProhibitRootExecution(args []string) bool { return true }
+
+func (cmd *resumeCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt resume [-help]
+
+Quick example
+  $ volt get -l -u   # failed to upgrade some plugins (e.g. network error)
+  $ volt resume      # will run "volt get -l -u" again
+
+Description
+  Run the command of the last failed transaction again.
+  The repositories which were installed or upgraded successfully by the
+  failed transaction are not fetched again:
+    * The installed repositories are kept in $VOLTPATH/trx.resume/ after
+      rollback, and moved back to $VOLTPATH/repos/
+    * The upgraded repositories are checked out to the upgraded versions
+  and the remaining repositories are installed or upgraded. lock.json is
+  updated with all repositories as the command succeeded at once.
+
+  Only "volt get" can be resumed now. If it fails again, "volt resume" can be
+  run again.` + "\n\n")
+		fmt.Println("Options")
+		fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	return fs
+}
+
+func (cmd *resumeCmd) Run(args []string) *Error {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return nil
+	}
+	if len(fs.Args()) > 0 {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: volt resume does not receive arguments"}
+	}
+
+	state, err := transaction.ReadResume()
+	if err != nil {
+		return &Error{Code: ExitGeneral, Msg: "Could not read the state to resume: " + err.Error()}
+	}
+	if state == nil {
+		return &Error{Code: ExitGeneral, Msg: "Failed to resume: no failed transaction to resume"}
+	}
+	if len(state.Args) == 0 || state.Args[0] != "get" {
+		return &Error{Code: ExitGeneral, Msg: "Failed to resume: cannot resume \"volt " + strings.Join(state.Args, " ") + "\""}
+	}
+
+	fmt.Printf("Resuming \"volt %s\" failed at %s\n", strings.Join(state.Args, " "), state.FailedAt.Format("2006-01-02 15:04:05"))
+	get := &getCmd{resume: state}
+	return get.Run(state.Args[1:])
+}
@@ -0,0 +1,62 @@
+package subcmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vim-volt/volt/internal/testutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+// (C) lock.json has all repositories of the failed command
+
+// * Run `volt resume` after `volt get` failed (A, B, C)
+//   * The state to resume is removed
+// * Run `volt resume` without failed transaction (!A, !B)
+func TestVoltResume(t *testing.T) {
+	t.Run("Run `volt resume` after `volt get` failed", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		hello := pathutil.ReposPath("localhost/local/hello")
+		missing := pathutil.ReposPath("localhost/local/missing")
+		if err := os.MkdirAll(filepath.Join(hello.FullPath(), "plugin"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		out, err := testutil.RunVolt("get", "localhost/local/hello", "localhost/local/missing")
+		testutil.FailExit(t, out, err)
+		if !pathutil.Exists(pathutil.TrxResume()) {
+			t.Fatal("the state to resume was not saved: " + pathutil.TrxResume())
+		}
+		if err = os.MkdirAll(filepath.Join(missing.FullPath(), "plugin"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err = testutil.RunVolt("resume")
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (C)
+		lockJSON, err := lockjson.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, reposPath := range []pathutil.ReposPath{hello, missing} {
+			if !lockJSON.Repos.Contains(reposPath) {
+				t.Errorf("%s is not in lock.json", reposPath)
+			}
+		}
+		if pathutil.Exists(pathutil.TrxResume()) {
+			t.Error("the state to resume was not removed: " + pathutil.TrxResume())
+		}
+	})
+
+	t.Run("Run `volt resume` without failed transaction", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		out, err := testutil.RunVolt("resume")
+		// (!A, !B)
+		testutil.FailExit(t, out, err)
+	})
+}
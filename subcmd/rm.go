@@ -5,10 +5,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
-	"github.com/vim-volt/volt/fileutil"
+	"github.com/vim-volt/volt/gitutil"
 	"github.com/vim-volt/volt/lockjson"
 	"github.com/vim-volt/volt/logger"
 	"github.com/vim-volt/volt/pathutil"
@@ -25,6 +24,8 @@ type rmCmd struct {
 	helped     bool
 	rmRepos    bool
 	rmPlugconf bool
+	showPlan   bool
+	message    string
 }
 
 func (cmd *rmCmd) ProhibitRootExecution(args []string) bool { return true }
@@ -35,13 +36,14 @@ func (cmd *rmCmd) FlagSet() *flag.FlagSet {
 	fs.Usage = func() {
 		fmt.Print(`
 Usage
-  volt rm [-help] [-r] [-p] {repository} [{repository2} ...]
+  volt rm [-help] [-r] [-p] [-plan] [-m {message}] {repository} [{repository2} ...]
 
 Quick example
   $ volt rm tyru/caw.vim    # Remove tyru/caw.vim plugin from lock.json
   $ volt rm -r tyru/caw.vim # Remove tyru/caw.vim plugin from lock.json, and remove repository directory
   $ volt rm -p tyru/caw.vim # Remove tyru/caw.vim plugin from lock.json, and remove plugconf
   $ volt rm -r -p tyru/caw.vim # Remove tyru/caw.vim plugin from lock.json, and remove repository directory, plugconf
+  $ volt rm -plan -r tyru/caw.vim # Show what "volt rm -r tyru/caw.vim" does
 
 Description
   Uninstall one or more {repository} from every profile.
@@ -49,7 +51,15 @@ Description
   If {repository} is depended by other repositories, this command exits with an error.
 
   If -r option was given, remove also repository directories of specified repositories.
+  The git objects of them are kept in "$VOLTPATH/cache" (except shallow
+  repositories), and they are reused when the repositories are installed
+  again by "volt get", so only new objects are downloaded.
+  "volt gc" removes the cache.
   If -p option was given, remove also plugconf files of specified repositories.
+  If -plan option was given, show the steps to be performed, and exit without
+  removing anything.
+  If -m option was given, {message} is recorded as the reason of removing
+  (see "volt log").
 
   {repository} is treated as same format as "volt get" (see "volt get -help").` + "\n\n")
 		//fmt.Println("Options")
@@ -59,6 +69,8 @@ Description
 	}
 	fs.BoolVar(&cmd.rmRepos, "r", false, "remove also repository directories")
 	fs.BoolVar(&cmd.rmPlugconf, "p", false, "remove also plugconf files")
+	fs.StringVar(&cmd.message, "m", "", messageFlagUsage)
+	fs.BoolVar(&cmd.showPlan, "plan", false, "show the steps to be performed without changing anything")
 	return fs
 }
 
@@ -68,18 +80,22 @@ func (cmd *rmCmd) Run(args []string) *Error {
 		return nil
 	}
 	if err != nil {
-		return &Error{Code: 10, Msg: err.Error()}
+		return &Error{Code: ExitUsage, Msg: err.Error()}
 	}
+	transaction.SetMessage(cmd.message)
 
 	err = cmd.doRemove(reposPathList)
+	if err == errShowedPlan {
+		return nil
+	}
 	if err != nil {
-		return &Error{Code: 11, Msg: "Failed to remove repository: " + err.Error()}
+		return &Error{Code: ExitGeneral, Msg: "Failed to remove repository: " + err.Error()}
 	}
 
 	// Build opt dir
 	err = builder.Build(false)
 	if err != nil {
-		return &Error{Code: 12, Msg: "Could not build " + pathutil.VimVoltDir() + ": " + err.Error()}
+		return &Error{Code: ExitGeneral, Msg: "Could not build " + pathutil.VimVoltDir() + ": " + err.Error()}
 	}
 
 	return nil
@@ -108,42 +124,46 @@ func (cmd *rmCmd) parseArgs(args []string) ([]pathutil.ReposPath, error) {
 	return reposPathList, nil
 }
 
-func (cmd *rmCmd) doRemove(reposPathList []pathutil.ReposPath) error {
-	// Read lock.json
-	lockJSON, err := lockjson.Read()
-	if err != nil {
-		return err
-	}
+// rmPlan is the plan of "volt rm".
+type rmPlan struct {
+	plan
+	// removals are the repository directories and plugconf files to be
+	// removed
+	removals []rmRemoval
+}
 
-	// Begin transaction
-	err = transaction.Create()
-	if err != nil {
-		return err
-	}
-	defer transaction.Remove()
+type rmRemoval struct {
+	path     string
+	plugconf bool
+	// reposPath is the repository of the directory (empty if plugconf is
+	// true), whose git objects are kept in the clone cache
+	reposPath pathutil.ReposPath
+}
 
+// planRemove checks if reposPathList can be removed, and determines the
+// steps to remove them.
+func (cmd *rmCmd) planRemove(reposPathList []pathutil.ReposPath, lockJSON *lockjson.LockJSON) (*rmPlan, error) {
 	// Check if specified plugins are depended by some plugins
 	for _, reposPath := range reposPathList {
 		rdeps, err := plugconf.RdepsOf(reposPath, lockJSON.Repos)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if len(rdeps) > 0 {
-			return fmt.Errorf("cannot remove '%s' because it's depended by '%s'",
+			return nil, fmt.Errorf("cannot remove '%s' because it's depended by '%s'",
 				reposPath, strings.Join(rdeps.Strings(), "', '"))
 		}
 	}
 
-	removeCount := 0
+	p := &rmPlan{}
+	changes := 0
 	for _, reposPath := range reposPathList {
 		// Remove repository directory
 		if cmd.rmRepos {
 			fullReposPath := reposPath.FullPath()
 			if pathutil.Exists(fullReposPath) {
-				if err = cmd.removeRepos(fullReposPath); err != nil {
-					return err
-				}
-				removeCount++
+				p.removals = append(p.removals, rmRemoval{path: fullReposPath, reposPath: reposPath})
+				p.add("remove directory %s", relVoltPath(fullReposPath))
 			} else {
 				logger.Debugf("No repository was installed for '%s' ... skip.", reposPath)
 			}
@@ -153,50 +173,101 @@ func (cmd *rmCmd) doRemove(reposPathList []pathutil.ReposPath) error {
 		if cmd.rmPlugconf {
 			plugconfPath := reposPath.Plugconf()
 			if pathutil.Exists(plugconfPath) {
-				if err = cmd.removePlugconf(plugconfPath); err != nil {
-					return err
-				}
-				removeCount++
+				p.removals = append(p.removals, rmRemoval{path: plugconfPath, plugconf: true})
+				p.add("remove plugconf %s", relVoltPath(plugconfPath))
 			} else {
 				logger.Debugf("No plugconf was installed for '%s' ... skip.", reposPath)
 			}
+			if luaPath := reposPath.LuaPlugconf(); pathutil.Exists(luaPath) {
+				p.removals = append(p.removals, rmRemoval{path: luaPath, plugconf: true})
+				p.add("remove plugconf %s", relVoltPath(luaPath))
+			}
 		}
 
 		// Remove repository from lock.json
-		err = lockJSON.Repos.RemoveAllReposPath(reposPath)
-		err2 := lockJSON.Profiles.RemoveAllReposPath(reposPath)
-		if err == nil || err2 == nil {
-			removeCount++
+		if lockJSON.Repos.Contains(reposPath) {
+			changes++
 		}
+		for i := range lockJSON.Profiles {
+			if lockJSON.Profiles[i].ReposPath.Contains(reposPath) {
+				changes++
+			}
+		}
+	}
+	if len(p.removals) == 0 && changes == 0 {
+		return nil, errors.New("no plugins are removed")
+	}
+	p.addWriteLockJSON(changes)
+	p.addBuild()
+	return p, nil
+}
+
+func (cmd *rmCmd) doRemove(reposPathList []pathutil.ReposPath) error {
+	// Read lock.json
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return err
 	}
-	if removeCount == 0 {
-		return errors.New("no plugins are removed")
+
+	p, err := cmd.planRemove(reposPathList, lockJSON)
+	if err != nil {
+		return err
+	}
+	if cmd.showPlan {
+		p.show()
+		return errShowedPlan
+	}
+
+	// Begin transaction
+	err = transaction.Create()
+	if err != nil {
+		return err
+	}
+	defer transaction.Remove()
+
+	if err = cmd.applyRemove(p, reposPathList, lockJSON); err != nil {
+		return err
 	}
 
 	// Write to lock.json
 	if err = lockJSON.Write(); err != nil {
 		return err
 	}
+	return transaction.Commit()
+}
+
+// applyRemove removes the files of p in current transaction, and removes
+// reposPathList from lockJSON (lock.json is not written).
+func (cmd *rmCmd) applyRemove(p *rmPlan, reposPathList []pathutil.ReposPath, lockJSON *lockjson.LockJSON) error {
+	for _, r := range p.removals {
+		var err error
+		if r.plugconf {
+			err = cmd.removePlugconf(r.path)
+		} else {
+			if err = gitutil.MoveToCache(r.reposPath, transaction.Rename); err != nil {
+				logger.Warnf("could not keep git objects of '%s' in the cache: %s", r.reposPath, err.Error())
+			}
+			err = cmd.removeRepos(r.path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, reposPath := range reposPathList {
+		lockJSON.Repos.RemoveAllReposPath(reposPath)
+		lockJSON.Profiles.RemoveAllReposPath(reposPath)
+	}
 	return nil
 }
 
 // Remove repository directory
 func (cmd *rmCmd) removeRepos(fullReposPath string) error {
 	logger.Info("Removing " + fullReposPath + " ...")
-	if err := os.RemoveAll(fullReposPath); err != nil {
-		return err
-	}
-	fileutil.RemoveDirs(filepath.Dir(fullReposPath))
-	return nil
+	return transaction.RemoveAll(fullReposPath)
 }
 
 // Remove plugconf file
 func (*rmCmd) removePlugconf(plugconfPath string) error {
 	logger.Info("Removing plugconf files ...")
-	if err := os.Remove(plugconfPath); err != nil {
-		return err
-	}
-	// Remove parent directories of plugconf
-	fileutil.RemoveDirs(filepath.Dir(plugconfPath))
-	return nil
+	return transaction.RemoveAll(plugconfPath)
 }
@@ -3,6 +3,8 @@ package subcmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/vim-volt/volt/internal/testutil"
@@ -480,6 +482,79 @@ func TestErrVoltRmNotFound(t *testing.T) {
 	testutil.FailExit(t, out, err)
 }
 
+// Run `volt rm -r <plugin>` and `volt get <plugin>` (A, B, C)
+// * git objects are kept in "$VOLTPATH/cache/repos/<repos>", and `volt get`
+//   reuses and removes them
+func TestVoltRmRoptKeepsCache(t *testing.T) {
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("example.com/vim-volt/cached.vim")
+	remote := testutil.SetUpRemoteRepos(t, reposPath)
+	out, err := testutil.RunVolt("config", "set", "git.clone_depth", "0")
+	testutil.SuccessExit(t, out, err)
+	out, err = testutil.RunVolt("get", reposPath.String())
+	testutil.SuccessExit(t, out, err)
+
+	out, err = testutil.RunVolt("rm", "-r", reposPath.String())
+	// (A, B)
+	testutil.SuccessExit(t, out, err)
+	// (C)
+	if pathutil.Exists(reposPath.FullPath()) {
+		t.Error("repos was not removed: " + reposPath.FullPath())
+	}
+	cache := filepath.Join(pathutil.CacheDir(), "repos", filepath.FromSlash(reposPath.String()))
+	if !pathutil.Exists(filepath.Join(cache, "objects")) {
+		t.Error("git objects were not kept: " + cache)
+	}
+
+	testutil.Git(t, remote, "commit", "-q", "--allow-empty", "-m", "second")
+	out, err = testutil.RunVolt("get", reposPath.String())
+	testutil.SuccessExit(t, out, err)
+	expected := testutil.Git(t, remote, "rev-parse", "HEAD")
+	if head := testutil.Git(t, reposPath.FullPath(), "rev-parse", "HEAD"); head != expected {
+		t.Errorf("expected HEAD is %s but got %s", expected, head)
+	}
+	if pathutil.Exists(cache) {
+		t.Error("the cache was not removed: " + cache)
+	}
+}
+
+// Run `volt rm -plan -r <plugin>` (A, B, !C, !F)
+// * Shows the steps to remove the plugin
+func TestVoltRmPlan(t *testing.T) {
+	// =============== setup =============== //
+
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("localhost/local/hello")
+	if err := os.MkdirAll(filepath.Join(reposPath.FullPath(), "plugin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	out, err := testutil.RunVolt("get", "localhost/local/hello")
+	testutil.SuccessExit(t, out, err)
+
+	// =============== run =============== //
+
+	out, err = testutil.RunVolt("rm", "-plan", "-r", "localhost/local/hello")
+	// (A, B)
+	testutil.SuccessExit(t, out, err)
+	for _, step := range []string{
+		"1. remove directory repos/localhost/local/hello",
+		"2. write lock.json with 2 changes",
+		"3. build ",
+	} {
+		if !strings.Contains(string(out), step) {
+			t.Errorf("step %q is not shown: %s", step, string(out))
+		}
+	}
+
+	// (!C)
+	if !pathutil.Exists(reposPath.FullPath()) {
+		t.Error("repos was removed: " + reposPath.FullPath())
+	}
+
+	// (!F)
+	testReposPathWereAdded(t, reposPath)
+}
+
 func testReposPathWereRemoved(t *testing.T, reposPath pathutil.ReposPath) {
 	t.Helper()
 	lockJSON, err := lockjson.Read()
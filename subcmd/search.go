@@ -0,0 +1,117 @@
+package subcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+)
+
+func init() {
+	cmdMap["search"] = &searchCmd{}
+}
+
+type searchCmd struct {
+	helped bool
+}
+
+func (cmd *searchCmd) ProhibitRootExecution(args []string) bool { return true }
+
+func (cmd *searchCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt search [-help] {query}
+
+Quick example
+  $ volt search comment  # will show plugins about "comment"
+
+Description
+  Search plugins by {query}, and show their descriptions, categories, and
+  popularity (GitHub stars). The installed plugins are marked as "installed".
+
+  The plugins are searched in VimAwesome (https://vimawesome.com/) by default.
+  "metadata.source" in config.toml can change it to JSON index of your own:
+
+    [
+      {
+        "repos": "tyru/caw.vim",
+        "description": "Vim comment plugin",
+        "category": "other",
+        "tags": ["comment"],
+        "stars": 300
+      }
+    ]
+
+  The metadata of the found plugins are cached in $VOLTPATH/cache/metadata.json
+  (also used by "volt info" and "volt list"). If the source cannot be accessed
+  (e.g. offline, or "metadata.source" is "none"), the cache is searched
+  instead.` + "\n\n")
+		//fmt.Println("Options")
+		//fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	return fs
+}
+
+func (cmd *searchCmd) Run(args []string) *Error {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return nil
+	}
+	if len(fs.Args()) == 0 {
+		fs.Usage()
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: {query} was not given"}
+	}
+
+	if err := cmd.doSearch(strings.Join(fs.Args(), " ")); err != nil {
+		return &Error{Code: exitCodeOf(err, ExitGeneral), Msg: "Failed to search: " + err.Error()}
+	}
+	return nil
+}
+
+func (cmd *searchCmd) doSearch(query string) error {
+	lockJSON, err := lockjson.ReadOnly()
+	if err != nil {
+		return errors.New("could not read lock.json: " + err.Error())
+	}
+	store, err := openMetadataStore()
+	if err != nil {
+		return err
+	}
+	result, err := store.search(query)
+	if err != nil {
+		return err
+	}
+	if err = store.save(); err != nil {
+		logger.Warn("Could not write metadata cache: " + err.Error())
+	}
+
+	if len(result) == 0 {
+		fmt.Printf("No plugins were found for %q\n", query)
+		return nil
+	}
+	for i := range result {
+		var attrs []string
+		attrs = append(attrs, fmt.Sprintf("%d stars", result[i].Stars))
+		if result[i].Category != "" {
+			attrs = append(attrs, result[i].Category)
+		}
+		if lockJSON.Repos.Contains(result[i].Repos) {
+			attrs = append(attrs, "installed")
+		}
+		fmt.Printf("%s (%s)\n", result[i].Repos, strings.Join(attrs, ", "))
+		if result[i].Description != "" {
+			fmt.Println("  " + result[i].Description)
+		}
+	}
+	return nil
+}
@@ -15,6 +15,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/vim-volt/volt/diskcache"
 	"github.com/vim-volt/volt/httputil"
 	"github.com/vim-volt/volt/logger"
 )
@@ -55,17 +56,17 @@ func (cmd *selfUpgradeCmd) Run(args []string) *Error {
 		return nil
 	}
 	if err != nil {
-		return &Error{Code: 10, Msg: "Failed to parse args: " + err.Error()}
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: " + err.Error()}
 	}
 
 	if ppidStr := os.Getenv("VOLT_SELF_UPGRADE_PPID"); ppidStr != "" {
 		if err = cmd.doCleanUp(ppidStr); err != nil {
-			return &Error{Code: 11, Msg: "Failed to clean up old binary: " + err.Error()}
+			return &Error{Code: ExitGeneral, Msg: "Failed to clean up old binary: " + err.Error()}
 		}
 	} else {
 		latestURL := "https://api.github.com/repos/vim-volt/volt/releases/latest"
 		if err = cmd.doSelfUpgrade(latestURL); err != nil {
-			return &Error{Code: 12, Msg: "Failed to self-upgrade: " + err.Error()}
+			return &Error{Code: exitCodeOf(err, ExitGeneral), Msg: "Failed to self-upgrade: " + err.Error()}
 		}
 	}
 
@@ -146,7 +147,7 @@ func (cmd *selfUpgradeCmd) doSelfUpgrade(latestURL string) error {
 		logger.Info("No updates were found.")
 		return nil
 	}
-	logger.Infof("Found update: %s -> %s", voltVersion, release.TagName)
+	logger.Infof("Found update: %s %s %s", voltVersion, logger.GlyphArrow, release.TagName)
 
 	// Show release note
 	fmt.Println("---")
@@ -199,15 +200,30 @@ func (*selfUpgradeCmd) getExecutablePath() (string, error) {
 	return filepath.EvalSymlinks(exe)
 }
 
+// releaseCacheNamespace is the namespace of diskcache which has the release
+// data, and releaseCacheTTL is the duration while it is used without
+// accessing the network again.
+const (
+	releaseCacheNamespace = "releases"
+	releaseCacheTTL       = time.Hour
+)
+
 func (*selfUpgradeCmd) checkLatest(url string) (*latestRelease, error) {
+	var release latestRelease
+	if diskcache.Get(releaseCacheNamespace, url, releaseCacheTTL, &release) {
+		logger.Debug("Using cached release data of " + url)
+		return &release, nil
+	}
 	content, err := httputil.GetContent(url)
 	if err != nil {
-		return nil, err
+		return nil, &networkError{err: err}
 	}
-	var release latestRelease
 	if err = json.Unmarshal(content, &release); err != nil {
 		return nil, err
 	}
+	if err = diskcache.Put(releaseCacheNamespace, url, &release); err != nil {
+		logger.Debug("Could not cache release data: " + err.Error())
+	}
 	return &release, nil
 }
 
@@ -218,11 +234,11 @@ func (*selfUpgradeCmd) download(w io.Writer, release *latestRelease) error {
 		if strings.HasSuffix(release.Assets[i].Name, suffix) {
 			r, err := httputil.GetContentReader(release.Assets[i].BrowserDownloadURL)
 			if err != nil {
-				return err
+				return &networkError{err: err}
 			}
 			defer r.Close()
 			if _, err = io.Copy(w, r); err != nil {
-				return err
+				return &networkError{err: err}
 			}
 			break
 		}
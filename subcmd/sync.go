@@ -0,0 +1,136 @@
+package subcmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/vim-volt/volt/lockjson"
+)
+
+func init() {
+	cmdMap["sync"] = &syncCmd{}
+}
+
+type syncCmd struct {
+	helped   bool
+	from     string
+	showPlan bool
+}
+
+func (cmd *syncCmd) ProhibitRootExecution(args []string) bool { return true }
+
+func (cmd *syncCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt sync [-help] [-plan] -from {lock.json}
+
+Quick example
+  $ volt sync -from https://raw.githubusercontent.com/tyru/dotfiles/master/lock.json  # will make local lock.json the same as the URL
+  $ volt sync -from ~/dotfiles/lock.json  # will make local lock.json the same as the file
+  $ volt sync -plan -from https://github.com/tyru/dotfiles/blob/master/lock.json  # will show what is changed
+
+Description
+  Download {lock.json}, validate it, and make $VOLTPATH/lock.json and the
+  repositories the same as it in one step. It does not ask anything, so it
+  can be used to provision containers and new machines.
+  {lock.json} can be a file, "-" (stdin), or a URL (see "volt import -help").
+
+  * Install the git repositories which are not in local lock.json, and check
+    out the versions of {lock.json} (also in the repositories whose versions
+    differ). The branches in {lock.json} are moved to the versions, so
+    "volt get -u" can upgrade them
+  * Add the static repositories which exist under $VOLTPATH/repos/
+  * Remove the repositories which are not in {lock.json} from lock.json
+    (the repository directories are not removed)
+  * Set the repositories of the profiles and current profile of {lock.json},
+    and build ~/.vim/pack/volt
+
+  Unlike "volt import", which merges {lock.json} into local lock.json, the
+  local changes are overwritten. Plugconf files are not changed.
+
+  If -plan option was given, show the steps to be performed, and exit without
+  changing anything.` + "\n\n")
+		fmt.Println("Options")
+		fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	fs.StringVar(&cmd.from, "from", "", "lock.json to be synchronized with (a file, \"-\", or a URL)")
+	fs.BoolVar(&cmd.showPlan, "plan", false, "show the steps to be performed without changing anything")
+	return fs
+}
+
+func (cmd *syncCmd) Run(args []string) *Error {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return nil
+	}
+	if len(fs.Args()) > 0 {
+		fs.Usage()
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: too many arguments"}
+	}
+	if cmd.from == "" {
+		fs.Usage()
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: -from {lock.json} must be given"}
+	}
+
+	imported, err := (&importCmd{}).readImportedLockJSON(cmd.from)
+	if err != nil {
+		return &Error{Code: ExitValidation, Msg: "Could not read " + cmd.from + ": " + err.Error()}
+	}
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		return &Error{Code: ExitValidation, Msg: "Could not read lock.json: " + err.Error()}
+	}
+
+	err = (&applyCmd{showPlan: cmd.showPlan}).doApply(voltfileOfLockJSON(imported), lockJSON)
+	if err == errShowedPlan {
+		return nil
+	}
+	if err != nil {
+		if e, ok := err.(*Error); ok {
+			return e
+		}
+		return &Error{Code: exitCodeOf(err, ExitGeneral), Msg: "Failed to sync: " + err.Error()}
+	}
+	return nil
+}
+
+// voltfileOfLockJSON returns the voltfile which has the same repositories and
+// profiles as lockJSON. The versions of the git repositories are checked out
+// at the state of HEAD in lockJSON (e.g. the branch is moved to the version),
+// so they are not pinned unless HEAD was detached in lockJSON.
+func voltfileOfLockJSON(lockJSON *lockjson.LockJSON) *voltfile {
+	vf := &voltfile{
+		CurrentProfile: lockJSON.CurrentProfileName,
+		Profiles:       make([]string, 0, len(lockJSON.Profiles)),
+		Plugins:        make([]voltfilePlugin, 0, len(lockJSON.Repos)),
+	}
+	for i := range lockJSON.Profiles {
+		vf.Profiles = append(vf.Profiles, lockJSON.Profiles[i].Name)
+	}
+	for i := range lockJSON.Repos {
+		repos := &lockJSON.Repos[i]
+		plugin := voltfilePlugin{
+			Repos:     repos.Path.String(),
+			Profiles:  []string{},
+			reposPath: repos.Path,
+		}
+		if repos.Type == lockjson.ReposGitType {
+			plugin.Ref = repos.Version
+			plugin.repos = repos
+		}
+		for j := range lockJSON.Profiles {
+			if lockJSON.Profiles[j].ReposPath.Contains(repos.Path) {
+				plugin.Profiles = append(plugin.Profiles, lockJSON.Profiles[j].Name)
+			}
+		}
+		vf.Plugins = append(vf.Plugins, plugin)
+	}
+	return vf
+}
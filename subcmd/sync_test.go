@@ -0,0 +1,99 @@
+package subcmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vim-volt/volt/gitutil"
+	"github.com/vim-volt/volt/internal/testutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func TestVoltfileOfLockJSON(t *testing.T) {
+	lockJSON := &lockjson.LockJSON{
+		CurrentProfileName: "work",
+		Repos: lockjson.ReposList{
+			{Type: lockjson.ReposGitType, Path: "github.com/a/git", Version: "0123456789abcdef", HeadRef: "master"},
+			{Type: lockjson.ReposStaticType, Path: "localhost/local/static"},
+			{Type: lockjson.ReposGitType, Path: "github.com/a/unused", Version: "fedcba9876543210"},
+		},
+		Profiles: lockjson.ProfileList{
+			{Name: "default", ReposPath: []pathutil.ReposPath{"github.com/a/git"}},
+			{Name: "work", ReposPath: []pathutil.ReposPath{"github.com/a/git", "localhost/local/static"}},
+			{Name: "empty", ReposPath: []pathutil.ReposPath{}},
+		},
+	}
+	vf := voltfileOfLockJSON(lockJSON)
+	if vf.CurrentProfile != "work" {
+		t.Errorf("expected current profile 'work' but got %q", vf.CurrentProfile)
+	}
+	if names := vf.profileNames(); !reflect.DeepEqual(names, []string{"default", "empty", "work"}) {
+		t.Errorf("unexpected profiles: %+v", names)
+	}
+	expected := []voltfilePlugin{
+		{Repos: "github.com/a/git", Ref: "0123456789abcdef", Profiles: []string{"default", "work"}, reposPath: "github.com/a/git", repos: &lockJSON.Repos[0]},
+		{Repos: "localhost/local/static", Profiles: []string{"work"}, reposPath: "localhost/local/static"},
+		{Repos: "github.com/a/unused", Ref: "fedcba9876543210", Profiles: []string{}, reposPath: "github.com/a/unused", repos: &lockJSON.Repos[2]},
+	}
+	if !reflect.DeepEqual(vf.Plugins, expected) {
+		t.Errorf("expected plugins %+v but got %+v", expected, vf.Plugins)
+	}
+
+	// Nothing is changed if local lock.json is the same
+	p, err := (&applyCmd{}).planApply(vf, lockJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.steps) != 0 {
+		t.Errorf("expected no steps but got %+v", p.steps)
+	}
+}
+
+// Checks:
+// (A) The branch of synchronized lock.json is checked out at the version
+// (B) `volt get -u` upgrades the repository after `volt sync`
+func TestVoltSyncUpgrade(t *testing.T) {
+	testutil.SetUpEnv(t)
+	reposPath := pathutil.ReposPath("example.com/vim-volt/synced.vim")
+	remote := testutil.SetUpRemoteRepos(t, reposPath)
+	out, err := testutil.RunVolt("get", reposPath.String())
+	testutil.SuccessExit(t, out, err)
+	content, err := ioutil.ReadFile(pathutil.LockJSON())
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := filepath.Join(os.Getenv("HOME"), "lock.json")
+	if err = ioutil.WriteFile(from, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	testutil.Git(t, remote, "commit", "-q", "--allow-empty", "-m", "second")
+	latest := testutil.Git(t, remote, "rev-parse", "HEAD")
+	out, err = testutil.RunVolt("rm", "-r", reposPath.String())
+	testutil.SuccessExit(t, out, err)
+
+	// (A)
+	out, err = testutil.RunVolt("sync", "-from", from)
+	testutil.SuccessExit(t, out, err)
+	if detached, err := gitutil.IsDetachedHEAD(reposPath); err != nil || detached {
+		t.Errorf("expected HEAD is not detached but got %v (err=%v)", detached, err)
+	}
+
+	// (B)
+	out, err = testutil.RunVolt("get", "-u", reposPath.String())
+	testutil.SuccessExit(t, out, err)
+	lockJSON, err := lockjson.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos, err := lockJSON.Repos.FindByPath(reposPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repos.Version != latest {
+		t.Errorf("expected version %s but got %s", latest, repos.Version)
+	}
+}
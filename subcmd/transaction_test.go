@@ -0,0 +1,277 @@
+package subcmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vim-volt/volt/internal/testutil"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// Checks:
+// (A) Shows `[WARN]` message about rollback
+// (B) Exit with zero status
+// (C) Directory installed by interrupted transaction is removed
+// (D) Journal is removed
+// (E) lock.json is restored before running the command
+
+// * Run `volt profile new <profile>` after volt crashed (A, B, C, D)
+// * Run `volt profile new <profile>` after volt crashed with changing lock.json (A, B, D, E)
+// * Run `volt profile new <profile>` after volt crashed while committing (B, D)
+//   * The transaction is saved to the history
+func TestVoltTransactionRecovery(t *testing.T) {
+	writeJournal := func(t *testing.T, lockJSON string, installed string) {
+		t.Helper()
+		content, err := json.Marshal(map[string]interface{}{
+			"pid":        1,
+			"started_at": "2018-01-01T00:00:00Z",
+			"args":       []string{"get", "tyru/caw.vim"},
+			"lock_json":  lockJSON,
+			"ops":        []map[string]string{{"type": "install", "path": installed}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(pathutil.TrxJournal(), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Run `volt profile new <profile>` after volt crashed", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		out, err := testutil.RunVolt("profile", "new", "foo")
+		testutil.SuccessExit(t, out, err)
+		lockJSON, err := ioutil.ReadFile(pathutil.LockJSON())
+		if err != nil {
+			t.Fatal(err)
+		}
+		installed := pathutil.ReposPath("github.com/tyru/caw.vim").FullPath()
+		if err = os.MkdirAll(installed, 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeJournal(t, string(lockJSON), installed)
+
+		out, err = testutil.RunVolt("profile", "new", "bar")
+		// (A)
+		if !strings.Contains(string(out), "[WARN] Rolling back \"volt get tyru/caw.vim\"") {
+			t.Errorf("rollback message is not shown: %s", string(out))
+		}
+		// (B)
+		if err != nil {
+			t.Errorf("expected success exit but exited with failure: status=%q, out=%s", err, string(out))
+		}
+		// (C)
+		if pathutil.Exists(installed) {
+			t.Error("installed directory was not removed: " + installed)
+		}
+		// (D)
+		if pathutil.Exists(pathutil.TrxJournal()) {
+			t.Error("journal was not removed: " + pathutil.TrxJournal())
+		}
+	})
+
+	t.Run("Run `volt profile new <profile>` after volt crashed with changing lock.json", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		out, err := testutil.RunVolt("profile", "new", "foo")
+		testutil.SuccessExit(t, out, err)
+		lockJSON, err := ioutil.ReadFile(pathutil.LockJSON())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// lock.json before "volt profile new foo"
+		before := strings.Replace(string(lockJSON), `"foo"`, `"baz"`, -1)
+		writeJournal(t, before, pathutil.ReposPath("github.com/tyru/caw.vim").FullPath())
+
+		out, err = testutil.RunVolt("profile", "new", "bar")
+		// (A)
+		if !strings.Contains(string(out), "[WARN] Rolling back \"volt get tyru/caw.vim\"") {
+			t.Errorf("rollback message is not shown: %s", string(out))
+		}
+		// (B)
+		if err != nil {
+			t.Errorf("expected success exit but exited with failure: status=%q, out=%s", err, string(out))
+		}
+		// (E)
+		content, _ := ioutil.ReadFile(pathutil.LockJSON())
+		if strings.Contains(string(content), `"foo"`) || !strings.Contains(string(content), `"baz"`) || !strings.Contains(string(content), `"bar"`) {
+			t.Errorf("lock.json was not restored: %s", string(content))
+		}
+		// (D)
+		if pathutil.Exists(pathutil.TrxJournal()) {
+			t.Error("journal was not removed: " + pathutil.TrxJournal())
+		}
+	})
+}
+
+func TestVoltTransactionResumeCommit(t *testing.T) {
+	testutil.SetUpEnv(t)
+	out, err := testutil.RunVolt("profile", "new", "foo")
+	testutil.SuccessExit(t, out, err)
+	content, err := json.Marshal(map[string]interface{}{
+		"id":           5,
+		"pid":          1,
+		"started_at":   "2018-01-01T00:00:00Z",
+		"committed_at": "2018-01-01T00:00:01Z",
+		"args":         []string{"profile", "new", "foo"},
+		"lock_json":    nil,
+		"ops":          []map[string]string{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(pathutil.TrxJournal(), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = testutil.RunVolt("log")
+	// (B)
+	if err != nil {
+		t.Errorf("expected success exit but exited with failure: status=%q, out=%s", err, string(out))
+	}
+	if !strings.Contains(string(out), "#5 ") {
+		t.Errorf("the transaction is not saved to the history: %s", string(out))
+	}
+	// (D)
+	if pathutil.Exists(pathutil.TrxJournal()) {
+		t.Error("journal was not removed: " + pathutil.TrxJournal())
+	}
+}
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+// (C) Shows the PID of other volt process
+
+// * Run `volt profile new <profile>` while other volt process is running (!A, !B, C)
+// * Run `volt profile new <profile>` with `lock.wait` while other volt process is running (A, B)
+func TestVoltTransactionLock(t *testing.T) {
+	t.Run("Run `volt profile new <profile>` while other volt process is running", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		if err := ioutil.WriteFile(pathutil.TrxLock(), []byte("12345\n2018-01-01T00:00:00Z\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := testutil.RunVolt("profile", "new", "foo")
+		// (!A, !B)
+		testutil.FailExit(t, out, err)
+		// (C)
+		if !strings.Contains(string(out), "operation in progress by PID 12345 started at 2018-01-01T00:00:00Z") {
+			t.Errorf("PID is not shown: %s", string(out))
+		}
+	})
+
+	t.Run("Run `volt profile new <profile>` with `lock.wait` while other volt process is running", func(t *testing.T) {
+		testutil.SetUpEnv(t)
+		out, err := testutil.RunVolt("config", "set", "lock.wait", "10s")
+		testutil.SuccessExit(t, out, err)
+		if err := ioutil.WriteFile(pathutil.TrxLock(), []byte("12345\n2018-01-01T00:00:00Z\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			time.Sleep(500 * time.Millisecond)
+			os.Remove(pathutil.TrxLock())
+		}()
+
+		out, err = testutil.RunVolt("profile", "new", "foo")
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+	})
+}
+
+// Checks:
+// (A) Shows `[WARN]` message about interruption
+// (B) Exit with non-zero status
+// (C) Running git process finishes, and the repository installed by it is
+//     removed
+// (D) No more repositories are installed
+// (E) lock.json is not changed
+// (F) Journal and trx.lock are removed
+
+// * Send SIGINT to `volt get <repos1> <repos2>` while cloning <repos1> (A, B, C, D, E, F)
+func TestVoltTransactionInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sending SIGINT is not supported on Windows")
+	}
+	testutil.SetUpEnv(t)
+	reposPath1 := pathutil.ReposPath("example.com/vim-volt/first.vim")
+	reposPath2 := pathutil.ReposPath("example.com/vim-volt/second.vim")
+	testutil.SetUpRemoteRepos(t, reposPath1)
+	testutil.SetUpRemoteRepos(t, reposPath2)
+	out, err := testutil.RunVolt("profile", "new", "foo")
+	testutil.SuccessExit(t, out, err)
+	lockJSON, err := ioutil.ReadFile(pathutil.LockJSON())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// git command which waits for the signal before cloning
+	home := os.Getenv("HOME")
+	cloning := filepath.Join(home, "cloning")
+	cloned := filepath.Join(home, "cloned")
+	gitScript := filepath.Join(home, "git.sh")
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = clone ]; then touch '" + cloning + "'; sleep 1; git \"$@\" && touch '" + cloned + "'; exit; fi\n" +
+		"exec git \"$@\"\n"
+	if err = ioutil.WriteFile(gitScript, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	out, err = testutil.RunVolt("config", "set", "git.command", gitScript)
+	testutil.SuccessExit(t, out, err)
+
+	var buf bytes.Buffer
+	cmd := testutil.VoltCommand("get", "-j", "1", reposPath1.String(), reposPath2.String())
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	if err = cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; !pathutil.Exists(cloning); i++ {
+		if i >= 100 {
+			cmd.Process.Kill()
+			t.Fatal("git clone was not started: " + buf.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	// Send SIGINT only to volt
+	if err = cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	err = cmd.Wait()
+	// (A)
+	if !strings.Contains(buf.String(), "[WARN] Interrupted") {
+		t.Errorf("interruption message is not shown: %s", buf.String())
+	}
+	// (B)
+	if err == nil {
+		t.Errorf("expected failure exit but exited with success: out=%s", buf.String())
+	}
+	// (C)
+	if !pathutil.Exists(cloned) {
+		t.Error("git clone did not finish")
+	}
+	if pathutil.Exists(reposPath1.FullPath()) {
+		t.Error("repos was not removed: " + reposPath1.FullPath())
+	}
+	// (D)
+	if pathutil.Exists(reposPath2.FullPath()) {
+		t.Error("repos was installed: " + reposPath2.FullPath())
+	}
+	// (E)
+	if content, _ := ioutil.ReadFile(pathutil.LockJSON()); string(content) != string(lockJSON) {
+		t.Errorf("lock.json was changed: %s", string(content))
+	}
+	// (F)
+	if pathutil.Exists(pathutil.TrxJournal()) {
+		t.Error("journal was not removed: " + pathutil.TrxJournal())
+	}
+	if pathutil.Exists(pathutil.TrxLock()) {
+		t.Error("trx.lock was not removed: " + pathutil.TrxLock())
+	}
+}
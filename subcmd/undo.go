@@ -0,0 +1,152 @@
+package subcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/pathutil"
+	"github.com/vim-volt/volt/subcmd/builder"
+	"github.com/vim-volt/volt/transaction"
+)
+
+func init() {
+	cmdMap["undo"] = &undoCmd{}
+}
+
+type undoCmd struct {
+	helped  bool
+	message string
+}
+
+func (cmd *undoCmd) ProhibitRootExecution(args []string) bool { return true }
+
+func (cmd *undoCmd) FlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	fs.Usage = func() {
+		fmt.Print(`
+Usage
+  volt undo [-help] [-m {message}] [{trx_id}]
+
+Quick example
+  $ volt undo     # will revert the most recent transaction
+  $ volt undo 3   # will revert the transaction #3 (see "volt log")
+
+Description
+  Revert the transaction {trx_id} in the history (see "volt log"), or the most
+  recent transaction if {trx_id} is not given:
+    * The installed repositories and plugconf files are removed
+    * The removed repositories and plugconf files are restored from the history
+    * The upgraded repositories are checked out to the previous commits
+    * lock.json is restored to the state before the transaction
+  and then ~/.vim/pack/volt is rebuilt.
+  Submodules of the checked out repositories are updated, and if
+  "verify_signatures" in [git] section of config.toml is true, the checked out
+  commits must be signed by a key in $VOLTPATH/trusted_keys.asc (see
+  "volt get -help"). Otherwise nothing is reverted.
+
+  If lock.json was changed after the transaction {trx_id} (e.g. by later
+  transactions), undo them first.
+
+  "volt undo" itself is recorded as a transaction, so running "volt undo"
+  again redoes the reverted transaction.` + "\n\n")
+		fmt.Println("Options")
+		fs.PrintDefaults()
+		fmt.Println()
+		cmd.helped = true
+	}
+	fs.StringVar(&cmd.message, "m", "", messageFlagUsage)
+	return fs
+}
+
+func (cmd *undoCmd) Run(args []string) *Error {
+	id, err := cmd.parseArgs(args)
+	if err == ErrShowedHelp {
+		return nil
+	}
+	if err != nil {
+		return &Error{Code: ExitUsage, Msg: "Failed to parse args: " + err.Error()}
+	}
+	transaction.SetMessage(cmd.message)
+
+	err = cmd.doUndo(id)
+	if err != nil {
+		return &Error{Code: ExitGeneral, Msg: "Failed to undo: " + err.Error()}
+	}
+	return nil
+}
+
+// parseArgs returns {trx_id}. If it is not given, 0 is returned.
+func (cmd *undoCmd) parseArgs(args []string) (int, error) {
+	fs := cmd.FlagSet()
+	fs.Parse(args)
+	if cmd.helped {
+		return 0, ErrShowedHelp
+	}
+	switch len(fs.Args()) {
+	case 0:
+		return 0, nil
+	case 1:
+		id, err := strconv.Atoi(strings.TrimPrefix(fs.Arg(0), "#"))
+		if err != nil || id <= 0 {
+			return 0, errors.New("invalid transaction ID: " + fs.Arg(0))
+		}
+		return id, nil
+	default:
+		fs.Usage()
+		return 0, errors.New("too many arguments")
+	}
+}
+
+func (cmd *undoCmd) doUndo(id int) error {
+	cfg, err := config.Read()
+	if err != nil {
+		return errors.New("could not read config.toml: " + err.Error())
+	}
+
+	// Begin transaction
+	err = transaction.Create()
+	if err != nil {
+		return err
+	}
+	defer transaction.Remove()
+
+	journals, err := transaction.History()
+	if err != nil {
+		return errors.New("could not read the history: " + err.Error())
+	}
+	if len(journals) == 0 {
+		return errors.New("no transactions in the history")
+	}
+	j := &journals[0]
+	for i := range journals {
+		if journals[i].ID == id {
+			j = &journals[i]
+			break
+		}
+	}
+	if id != 0 && j.ID != id {
+		return fmt.Errorf("transaction #%d is not found in the history", id)
+	}
+
+	if err = transaction.Undo(j.ID, cfg); err != nil {
+		return err
+	}
+
+	// Build ~/.vim/pack/volt dir
+	err = builder.Build(true)
+	if err != nil {
+		return errors.New("could not build " + pathutil.VimVoltDir() + ": " + err.Error())
+	}
+
+	if err = transaction.Commit(); err != nil {
+		return err
+	}
+	fmt.Printf("Undid #%d volt %s\n", j.ID, strings.Join(j.Args, " "))
+	return nil
+}
@@ -0,0 +1,132 @@
+package subcmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vim-volt/volt/internal/testutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// Checks:
+// (A) Does not show `[ERROR]`, `[WARN]` messages
+// (B) Exit with zero status
+// (C) The change of the transaction is reverted
+
+// * Run `volt undo` after `volt get <static repository>` (A, B, C)
+// * Run `volt undo` twice after `volt get <static repository>` (A, B)
+//   * The reverted transaction is redone
+// * Run `volt undo <trx_id>` for old transaction (!A, !B, !C)
+// * Run `volt undo` after `volt rm -r <repos>` (A, B, C)
+//   * .git directory is restored from the clone cache
+// * Run `volt undo` after `volt get -u <repos>` with `git.verify_signatures = true` (!A, !B, !C)
+//   * The previous commit is not signed
+func TestVoltUndo(t *testing.T) {
+	reposPath := pathutil.ReposPath("localhost/local/hello")
+	setUp := func(t *testing.T) {
+		t.Helper()
+		testutil.SetUpEnv(t)
+		if err := os.MkdirAll(filepath.Join(reposPath.FullPath(), "plugin"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		out, err := testutil.RunVolt("get", "localhost/local/hello")
+		testutil.SuccessExit(t, out, err)
+	}
+
+	t.Run("Run `volt undo` after `volt get <static repository>`", func(t *testing.T) {
+		setUp(t)
+
+		out, err := testutil.RunVolt("undo")
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		if !strings.Contains(string(out), "Undid #1 volt get localhost/local/hello") {
+			t.Errorf("unexpected output: %s", string(out))
+		}
+		// (C)
+		testReposPathWereNotAdded(t, reposPath)
+		if pathutil.Exists(reposPath.Plugconf()) {
+			t.Error("plugconf was not removed: " + reposPath.Plugconf())
+		}
+	})
+
+	t.Run("Run `volt undo` twice after `volt get <static repository>`", func(t *testing.T) {
+		setUp(t)
+
+		for i := 0; i < 2; i++ {
+			out, err := testutil.RunVolt("undo")
+			// (A, B)
+			testutil.SuccessExit(t, out, err)
+		}
+		testReposPathWereAdded(t, reposPath)
+		if !pathutil.Exists(reposPath.Plugconf()) {
+			t.Error("plugconf was not restored: " + reposPath.Plugconf())
+		}
+	})
+
+	t.Run("Run `volt undo <trx_id>` for old transaction", func(t *testing.T) {
+		setUp(t)
+		out, err := testutil.RunVolt("profile", "new", "foo")
+		testutil.SuccessExit(t, out, err)
+
+		out, err = testutil.RunVolt("undo", "1")
+		// (!A, !B)
+		testutil.FailExit(t, out, err)
+		// (!C)
+		lockJSON, err := lockjson.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !lockJSON.Repos.Contains(reposPath) || lockJSON.Profiles.FindIndexByName("foo") < 0 {
+			t.Error("lock.json was changed")
+		}
+	})
+
+	gitReposPath := pathutil.ReposPath("example.com/vim-volt/undo.vim")
+	setUpGit := func(t *testing.T) string {
+		t.Helper()
+		testutil.SetUpEnv(t)
+		remote := testutil.SetUpRemoteRepos(t, gitReposPath)
+		out, err := testutil.RunVolt("config", "set", "git.clone_depth", "0")
+		testutil.SuccessExit(t, out, err)
+		out, err = testutil.RunVolt("get", gitReposPath.String())
+		testutil.SuccessExit(t, out, err)
+		return remote
+	}
+
+	t.Run("Run `volt undo` after `volt rm -r <repos>`", func(t *testing.T) {
+		remote := setUpGit(t)
+		out, err := testutil.RunVolt("rm", "-r", gitReposPath.String())
+		testutil.SuccessExit(t, out, err)
+
+		out, err = testutil.RunVolt("undo")
+		// (A, B)
+		testutil.SuccessExit(t, out, err)
+		// (C)
+		testReposPathWereAdded(t, gitReposPath)
+		expected := testutil.Git(t, remote, "rev-parse", "HEAD")
+		if head := testutil.Git(t, gitReposPath.FullPath(), "rev-parse", "HEAD"); head != expected {
+			t.Errorf("expected HEAD is %s but got %s", expected, head)
+		}
+	})
+
+	t.Run("Run `volt undo` after `volt get -u <repos>` with `git.verify_signatures = true`", func(t *testing.T) {
+		remote := setUpGit(t)
+		testutil.Git(t, remote, "commit", "-q", "--allow-empty", "-m", "second")
+		out, err := testutil.RunVolt("get", "-u", gitReposPath.String())
+		testutil.SuccessExit(t, out, err)
+		out, err = testutil.RunVolt("config", "set", "git.verify_signatures", "true")
+		testutil.SuccessExit(t, out, err)
+
+		out, err = testutil.RunVolt("undo")
+		// (!A, !B)
+		testutil.FailExit(t, out, err)
+		// (!C)
+		expected := testutil.Git(t, remote, "rev-parse", "HEAD")
+		if head := testutil.Git(t, gitReposPath.FullPath(), "rev-parse", "HEAD"); head != expected {
+			t.Errorf("expected HEAD is %s but got %s", expected, head)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+// Package timing measures the time spent in each phase of a command (e.g.
+// network operations, checkout, build), which is reported by "-timings"
+// option of volt command.
+package timing
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Phases of a command.
+const (
+	// Network is cloning, fetching, and HTTP requests.
+	Network = "network"
+	// Checkout is checking out a version of repositories.
+	Checkout = "checkout"
+	// Build is "volt build" (including Helptags).
+	Build = "build"
+	// Helptags is generating tags files of help files.
+	Helptags = "helptags"
+	// LockWrite is writing lock.json.
+	LockWrite = "lock write"
+)
+
+// phases is the order of phases in Report.
+var phases = []string{Network, Checkout, Build, Helptags, LockWrite}
+
+var (
+	enabled int32
+	started time.Time
+
+	mu     sync.Mutex
+	spent  = make(map[string]time.Duration)
+	counts = make(map[string]int)
+)
+
+// Enable starts measuring. Start does nothing until this is called.
+func Enable() {
+	if atomic.CompareAndSwapInt32(&enabled, 0, 1) {
+		started = time.Now()
+	}
+}
+
+// Enabled returns true if Enable was called.
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) != 0
+}
+
+// Start starts measuring phase, and returns the function which stops it:
+//
+//	defer timing.Start(timing.Network)()
+//
+// It can be called from several goroutines at the same time.
+func Start(phase string) func() {
+	if !Enabled() {
+		return func() {}
+	}
+	t := time.Now()
+	return func() {
+		d := time.Since(t)
+		mu.Lock()
+		spent[phase] += d
+		counts[phase]++
+		mu.Unlock()
+	}
+}
+
+// Report writes the time spent in each phase to w. The time of a phase is
+// summed over the jobs which ran in parallel, so it can be longer than the
+// total.
+func Report(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	fmt.Fprintf(w, "Timings (total %s, summed over parallel jobs):\n", round(time.Since(started)))
+	for _, phase := range phases {
+		if counts[phase] == 0 {
+			fmt.Fprintf(w, "  %-10s  -\n", phase)
+			continue
+		}
+		fmt.Fprintf(w, "  %-10s  %-8s x%d\n", phase, round(spent[phase]), counts[phase])
+	}
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
@@ -0,0 +1,29 @@
+package timing
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	Start(Network)()
+	if counts[Network] != 0 {
+		t.Errorf("expected nothing is measured before Enable")
+	}
+
+	Enable()
+	for i := 0; i < 2; i++ {
+		Start(Network)()
+	}
+	Start(LockWrite)()
+
+	var buf bytes.Buffer
+	Report(&buf)
+	out := buf.String()
+	for _, expected := range []string{"Timings (total ", "network ", " x2\n", "lock write ", " x1\n", "checkout    -"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in the report:\n%s", expected, out)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package transaction
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// AuditRecord is the structured record of a committed transaction for
+// auditing, which is shown by "volt log -format json".
+type AuditRecord struct {
+	ID          int        `json:"id"`
+	PID         int        `json:"pid"`
+	StartedAt   time.Time  `json:"started_at"`
+	CommittedAt *time.Time `json:"committed_at,omitempty"`
+	Args        []string   `json:"args"`
+	Message     string     `json:"message,omitempty"`
+	Ops         []Op       `json:"ops"`
+	// Repos is the changes of the versions of repositories in lock.json
+	Repos []ReposChange `json:"repos"`
+}
+
+// ReposChange is the change of the version of a repository in lock.json.
+type ReposChange struct {
+	// Change is ReposAdded, ReposUpdated, or ReposRemoved
+	Change string             `json:"change"`
+	Path   pathutil.ReposPath `json:"path"`
+	// From is the version before the transaction (empty if added or static)
+	From string `json:"from,omitempty"`
+	// To is the version after the transaction (empty if removed or static)
+	To string `json:"to,omitempty"`
+}
+
+// Types of ReposChange
+const (
+	ReposAdded   = "add"
+	ReposUpdated = "update"
+	ReposRemoved = "remove"
+)
+
+// Audit returns the record of committed transaction j.
+// If lock.json in j could not be parsed, Repos is empty.
+func (j *Journal) Audit() *AuditRecord {
+	record := &AuditRecord{
+		ID:          j.ID,
+		PID:         j.PID,
+		StartedAt:   j.StartedAt,
+		CommittedAt: j.CommittedAt,
+		Args:        j.Args,
+		Message:     j.Message,
+		Ops:         j.Ops,
+		Repos:       []ReposChange{},
+	}
+	if record.Ops == nil {
+		record.Ops = []Op{}
+	}
+	if j.CommittedLockJSON == nil {
+		return record
+	}
+	from, ok := reposVersions(j.LockJSON)
+	if !ok {
+		return record
+	}
+	to, ok := reposVersions(j.CommittedLockJSON)
+	if !ok {
+		return record
+	}
+	for path, version := range to {
+		old, exists := from[path]
+		if !exists {
+			record.Repos = append(record.Repos, ReposChange{Change: ReposAdded, Path: path, To: version})
+		} else if old != version {
+			record.Repos = append(record.Repos, ReposChange{Change: ReposUpdated, Path: path, From: old, To: version})
+		}
+	}
+	for path, version := range from {
+		if _, exists := to[path]; !exists {
+			record.Repos = append(record.Repos, ReposChange{Change: ReposRemoved, Path: path, From: version})
+		}
+	}
+	sort.Slice(record.Repos, func(i, k int) bool {
+		return record.Repos[i].Path < record.Repos[k].Path
+	})
+	return record
+}
+
+// reposVersions returns the map of repository path and its version in
+// content of lock.json. nil content means lock.json did not exist.
+func reposVersions(content *string) (map[pathutil.ReposPath]string, bool) {
+	versions := make(map[pathutil.ReposPath]string)
+	if content == nil {
+		return versions, true
+	}
+	lockJSON, err := lockjson.Parse([]byte(*content))
+	if err != nil {
+		return nil, false
+	}
+	for i := range lockJSON.Repos {
+		versions[lockJSON.Repos[i].Path] = lockJSON.Repos[i].Version
+	}
+	return versions, true
+}
@@ -0,0 +1,297 @@
+package transaction
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/fileutil"
+	"github.com/vim-volt/volt/gitutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+func init() {
+	RegisterHook(pruneHook{})
+}
+
+// historyJournalFile is the journal file in $VOLTPATH/trx/{id}/.
+const historyJournalFile = "journal.json"
+
+// historyBackupDir is the directory of the files removed by RemoveAll in
+// $VOLTPATH/trx/{id}/.
+const historyBackupDir = "backup"
+
+// archive moves the journal and the backup of committed transaction j to
+// $VOLTPATH/trx/{id}/. It does nothing if j was already archived.
+func (j *Journal) archive() error {
+	dir := filepath.Join(pathutil.TrxHistoryDir(), strconv.Itoa(j.ID))
+	if pathutil.Exists(filepath.Join(dir, historyJournalFile)) {
+		return nil
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	backupDir := filepath.Join(dir, historyBackupDir)
+	if pathutil.Exists(pathutil.TrxBackup()) {
+		if err = os.Rename(pathutil.TrxBackup(), backupDir); err != nil {
+			return err
+		}
+	}
+	for i := range j.Ops {
+		if j.Ops[i].Type == OpRemove {
+			j.Ops[i].Backup = filepath.Join(backupDir, filepath.Base(j.Ops[i].Backup))
+		}
+	}
+	content, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, historyJournalFile), content, 0644)
+}
+
+// historyIDs returns the IDs of transactions in the history in ascending
+// order.
+func historyIDs() ([]int, error) {
+	infos, err := ioutil.ReadDir(pathutil.TrxHistoryDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(infos))
+	for _, info := range infos {
+		if id, err := strconv.Atoi(info.Name()); err == nil && info.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// History returns the committed transactions in $VOLTPATH/trx/, from the
+// newest one.
+func History() ([]Journal, error) {
+	ids, err := historyIDs()
+	if err != nil {
+		return nil, err
+	}
+	journals := make([]Journal, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		j, err := readHistory(ids[i])
+		if err != nil {
+			return nil, err
+		}
+		journals = append(journals, *j)
+	}
+	return journals, nil
+}
+
+func readHistory(id int) (*Journal, error) {
+	path := filepath.Join(pathutil.TrxHistoryDir(), strconv.Itoa(id), historyJournalFile)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var j Journal
+	if err = json.Unmarshal(content, &j); err != nil {
+		return nil, err
+	}
+	j.ID = id
+	return &j, nil
+}
+
+// Undo reverts the transaction of id in the history as a part of current
+// transaction: the installed files are removed, the removed files are
+// restored from the history, the upgraded repositories are checked out to the
+// previous versions, and lock.json is restored.
+// If "verify_signatures" in [git] section of config.toml is true, the
+// signatures of the checked out versions are verified (see
+// gitutil.VerifyHEAD). The restored files are not verified because they are
+// copied from the history as is.
+// If lock.json was changed after the transaction (e.g. by later
+// transactions), it returns an error not to lose the changes.
+func Undo(id int, cfg *config.Config) error {
+	j, err := readHistory(id)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("transaction #%d is not found in the history", id)
+		}
+		return err
+	}
+	if j.CommittedLockJSON != nil && !sameLockJSON(j.CommittedLockJSON) {
+		return fmt.Errorf("lock.json was changed after transaction #%d: undo the later transactions first", id)
+	}
+
+	for i := len(j.Ops) - 1; i >= 0; i-- {
+		op := &j.Ops[i]
+		switch op.Type {
+		case OpInstall:
+			if !pathutil.Exists(op.Path) {
+				continue
+			}
+			logger.Info("Removing " + op.Path + " ...")
+			if err = RemoveAll(op.Path); err != nil {
+				return err
+			}
+		case OpUpgrade:
+			version, err := gitutil.GetHEAD(op.ReposPath)
+			if err != nil {
+				return fmt.Errorf("could not get HEAD commit hash of %s: %s", op.ReposPath, err.Error())
+			}
+			head, headRef, _ := gitutil.GetHEADState(op.ReposPath)
+			if err = Upgrade(op.ReposPath, version, head, headRef); err != nil {
+				return err
+			}
+			logger.Info("Checking out " + op.ReposPath.String() + " to " + op.Version + " ...")
+			if err = gitutil.CheckoutVersion(op.ReposPath, op.Version, op.Head, op.HeadRef, cfg); err != nil {
+				return err
+			}
+			if err = gitutil.UpdateSubmodules(op.ReposPath, j.submodulesOf(op.ReposPath), cfg); err != nil {
+				return fmt.Errorf("could not update submodules of %s: %s", op.ReposPath, err.Error())
+			}
+			if *cfg.Git.VerifySignatures {
+				if err = gitutil.VerifyHEAD(op.ReposPath); err != nil {
+					return fmt.Errorf("could not verify %s: %s", op.ReposPath, err.Error())
+				}
+			}
+		case OpRemove:
+			if pathutil.Exists(op.Path) {
+				return errors.New("could not restore " + op.Path + ": the file exists")
+			}
+			if !pathutil.Exists(op.Backup) {
+				return errors.New("could not restore " + op.Path + ": the backup was not found in the history")
+			}
+			logger.Info("Restoring " + op.Path + " ...")
+			if err = Install(op.Path); err != nil {
+				return err
+			}
+			if err = restoreBackup(op.Backup, op.Path); err != nil {
+				return err
+			}
+		case OpRename:
+			if pathutil.Exists(op.Path) {
+				return errors.New("could not restore " + op.Path + ": the file exists")
+			}
+			if !pathutil.Exists(op.Backup) {
+				return errors.New("could not restore " + op.Path + ": " + op.Backup + " was removed")
+			}
+			logger.Info("Restoring " + op.Path + " ...")
+			if err = Rename(op.Backup, op.Path); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Restore lock.json
+	if j.LockJSON == nil {
+		err = os.Remove(pathutil.LockJSON())
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return err
+	}
+	return lockjson.WriteContent([]byte(*j.LockJSON))
+}
+
+// sameLockJSON returns true if the content of lock.json is content.
+func sameLockJSON(content *string) bool {
+	b, err := ioutil.ReadFile(pathutil.LockJSON())
+	if err != nil {
+		return content == nil
+	}
+	return content != nil && *content == string(b)
+}
+
+// restoreBackup copies backup in the history to path.
+// The backup is kept to undo the transaction again.
+func restoreBackup(backup, path string) error {
+	info, err := os.Stat(backup)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fileutil.CopyDir(backup, path, nil, info.Mode().Perm(), 0)
+	}
+	return fileutil.CopyFile(backup, path, nil, info.Mode().Perm())
+}
+
+// Prune removes old transactions from the history, which exceed "max_count",
+// "max_age_days", or "max_size_mb" in [log] section of config.toml.
+// It returns the number and the total size of removed transactions.
+func Prune(cfg *config.Config) (int, int64, error) {
+	ids, err := historyIDs()
+	if err != nil {
+		return 0, 0, err
+	}
+	sizes := make(map[int]int64, len(ids))
+	var total int64
+	for _, id := range ids {
+		size, err := fileutil.DirSize(filepath.Join(pathutil.TrxHistoryDir(), strconv.Itoa(id)))
+		if err != nil {
+			return 0, 0, err
+		}
+		sizes[id] = size
+		total += size
+	}
+
+	maxCount := *cfg.Log.MaxCount
+	maxAge := time.Duration(*cfg.Log.MaxAgeDays) * 24 * time.Hour
+	maxSize := int64(*cfg.Log.MaxSizeMB) * 1024 * 1024
+	var count int
+	var reclaimed int64
+	// Remove from the oldest one
+	for i, id := range ids {
+		remaining := len(ids) - i
+		expired := maxAge > 0 && isOlderThan(id, maxAge)
+		if !(maxCount > 0 && remaining > maxCount) && !expired && !(maxSize > 0 && total > maxSize) {
+			break
+		}
+		if err := os.RemoveAll(filepath.Join(pathutil.TrxHistoryDir(), strconv.Itoa(id))); err != nil {
+			return count, reclaimed, err
+		}
+		count++
+		reclaimed += sizes[id]
+		total -= sizes[id]
+	}
+	return count, reclaimed, nil
+}
+
+// pruneHook prunes the history when a transaction was saved to the history.
+type pruneHook struct{}
+
+func (pruneHook) OnTrxCommit(j *Journal) {
+	if j.ID == 0 {
+		return
+	}
+	cfg, err := config.Read()
+	if err != nil {
+		return
+	}
+	if _, _, err = Prune(cfg); err != nil {
+		logger.Warn("could not prune the history of transactions: " + err.Error())
+	}
+}
+
+// isOlderThan returns true if the transaction of id was committed before age.
+// Broken transactions are also regarded as old.
+func isOlderThan(id int, age time.Duration) bool {
+	j, err := readHistory(id)
+	if err != nil || j.CommittedAt == nil {
+		return true
+	}
+	return time.Since(*j.CommittedAt) > age
+}
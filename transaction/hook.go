@@ -0,0 +1,98 @@
+package transaction
+
+import (
+	"sync"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// BeginHook is notified when a transaction began.
+type BeginHook interface {
+	OnTrxBegin(j *Journal)
+}
+
+// RepoInstalledHook is notified when a repository was installed in a
+// transaction. The repository is removed if the transaction is rolled back.
+// It may be notified concurrently because repositories are installed in
+// parallel.
+type RepoInstalledHook interface {
+	OnRepoInstalled(j *Journal, reposPath pathutil.ReposPath)
+}
+
+// CommitHook is notified when a transaction was committed.
+// j.ID is 0 if nothing was changed (the transaction is not in the history).
+type CommitHook interface {
+	OnTrxCommit(j *Journal)
+}
+
+// RollbackHook is notified when a transaction was rolled back, including
+// the transaction interrupted by crashed volt process.
+type RollbackHook interface {
+	OnTrxRollback(j *Journal)
+}
+
+var (
+	hooks   []interface{}
+	hooksMu sync.Mutex
+)
+
+// RegisterHook registers hook, which implements one or more of BeginHook,
+// RepoInstalledHook, CommitHook, and RollbackHook.
+// Hooks are notified in registration order, and must not begin or finish
+// transactions.
+func RegisterHook(hook interface{}) {
+	switch hook.(type) {
+	case BeginHook, RepoInstalledHook, CommitHook, RollbackHook:
+	default:
+		panic("transaction: RegisterHook: hook does not implement any hook interface")
+	}
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks = append(hooks, hook)
+}
+
+// RepoInstalled notifies RepoInstalledHook that reposPath was installed in
+// current transaction.
+func RepoInstalled(reposPath pathutil.ReposPath) {
+	journalMu.Lock()
+	j := current
+	journalMu.Unlock()
+	if j == nil {
+		return
+	}
+	for _, hook := range registeredHooks() {
+		if h, ok := hook.(RepoInstalledHook); ok {
+			h.OnRepoInstalled(j, reposPath)
+		}
+	}
+}
+
+func notifyBegin(j *Journal) {
+	for _, hook := range registeredHooks() {
+		if h, ok := hook.(BeginHook); ok {
+			h.OnTrxBegin(j)
+		}
+	}
+}
+
+func notifyCommit(j *Journal) {
+	for _, hook := range registeredHooks() {
+		if h, ok := hook.(CommitHook); ok {
+			h.OnTrxCommit(j)
+		}
+	}
+}
+
+func notifyRollback(j *Journal) {
+	for _, hook := range registeredHooks() {
+		if h, ok := hook.(RollbackHook); ok {
+			h.OnTrxRollback(j)
+		}
+	}
+}
+
+func registeredHooks() []interface{} {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	return append([]interface{}(nil), hooks...)
+}
@@ -0,0 +1,138 @@
+package transaction
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// recordHook records the notified events.
+type recordHook struct {
+	events []string
+}
+
+func (h *recordHook) OnTrxBegin(j *Journal) {
+	h.events = append(h.events, "begin")
+}
+
+func (h *recordHook) OnRepoInstalled(j *Journal, reposPath pathutil.ReposPath) {
+	h.events = append(h.events, "installed "+reposPath.String())
+}
+
+func (h *recordHook) OnTrxCommit(j *Journal) {
+	h.events = append(h.events, fmt.Sprintf("commit #%d", j.ID))
+}
+
+func (h *recordHook) OnTrxRollback(j *Journal) {
+	h.events = append(h.events, fmt.Sprintf("rollback %v", j.Args))
+}
+
+// setUpHookEnv sets $VOLTPATH to a temporary directory, and registers a new
+// recordHook until the test finishes.
+func setUpHookEnv(t *testing.T) *recordHook {
+	t.Helper()
+	tempDir, err := ioutil.TempDir("", "volt-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, exists := os.LookupEnv("VOLTPATH")
+	oldHooks := registeredHooks()
+	t.Cleanup(func() {
+		hooksMu.Lock()
+		hooks = oldHooks
+		hooksMu.Unlock()
+		if exists {
+			os.Setenv("VOLTPATH", old)
+		} else {
+			os.Unsetenv("VOLTPATH")
+		}
+		os.RemoveAll(tempDir)
+	})
+	os.Setenv("VOLTPATH", tempDir)
+
+	h := &recordHook{}
+	RegisterHook(h)
+	return h
+}
+
+func checkEvents(t *testing.T, h *recordHook, expected ...string) {
+	t.Helper()
+	if !reflect.DeepEqual(h.events, expected) {
+		t.Errorf("expected events %q but got %q", expected, h.events)
+	}
+}
+
+// * Hooks are notified when a transaction began, a repository was installed,
+//   and the transaction was committed
+// * Hooks are notified when a transaction was rolled back
+// * Hooks are notified when the transaction interrupted by crashed volt
+//   process was rolled back
+// * Hooks are not notified of installed repositories outside transactions
+// * RegisterHook panics if hook does not implement any hook interface
+func TestHooks(t *testing.T) {
+	reposPath := pathutil.ReposPath("github.com/tyru/caw.vim")
+
+	t.Run("commit", func(t *testing.T) {
+		h := setUpHookEnv(t)
+		if err := Create(); err != nil {
+			t.Fatal(err)
+		}
+		defer Remove()
+		if err := Install(reposPath.FullPath()); err != nil {
+			t.Fatal(err)
+		}
+		RepoInstalled(reposPath)
+		if err := Commit(); err != nil {
+			t.Fatal(err)
+		}
+		checkEvents(t, h, "begin", "installed "+reposPath.String(), "commit #1")
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		h := setUpHookEnv(t)
+		if err := Create(); err != nil {
+			t.Fatal(err)
+		}
+		if err := Install(reposPath.FullPath()); err != nil {
+			t.Fatal(err)
+		}
+		os.MkdirAll(reposPath.FullPath(), 0755)
+		Remove()
+		checkEvents(t, h, "begin", fmt.Sprintf("rollback %v", os.Args[1:]))
+		if pathutil.Exists(reposPath.FullPath()) {
+			t.Error("installed directory was not removed: " + reposPath.FullPath())
+		}
+	})
+
+	t.Run("rollback of crashed process", func(t *testing.T) {
+		h := setUpHookEnv(t)
+		content := `{"pid": 1, "started_at": "2018-01-01T00:00:00Z", "args": ["get", "tyru/caw.vim"], "lock_json": null, "ops": []}`
+		if err := ioutil.WriteFile(pathutil.TrxJournal(), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := Create(); err != nil {
+			t.Fatal(err)
+		}
+		defer Remove()
+		checkEvents(t, h, "rollback [get tyru/caw.vim]", "begin")
+	})
+
+	t.Run("installed outside transaction", func(t *testing.T) {
+		h := setUpHookEnv(t)
+		RepoInstalled(reposPath)
+		checkEvents(t, h)
+	})
+
+	t.Run("invalid hook", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("RegisterHook did not panic")
+			}
+		}()
+		RegisterHook(struct{}{})
+	})
+}
@@ -0,0 +1,467 @@
+package transaction
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/vim-volt/volt/config"
+	"github.com/vim-volt/volt/fileutil"
+	"github.com/vim-volt/volt/gitutil"
+	"github.com/vim-volt/volt/lockjson"
+	"github.com/vim-volt/volt/logger"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// Types of Op
+const (
+	// OpInstall creates a file or a directory.
+	// Rollback removes it.
+	OpInstall = "install"
+	// OpUpgrade changes the version of a repository.
+	// Rollback checks out the previous version.
+	OpUpgrade = "upgrade"
+	// OpRemove removes a file or a directory. It is moved to
+	// $VOLTPATH/trx.backup until the transaction is committed.
+	// Rollback moves it back.
+	OpRemove = "remove"
+	// OpRename moves a file or a directory.
+	// Rollback moves it back.
+	OpRename = "rename"
+)
+
+// Op is an operation of a transaction. It is written to the journal before
+// it is applied, so that the transaction can be rolled back even if volt
+// crashed.
+type Op struct {
+	Type      string             `json:"type"`
+	Path      string             `json:"path,omitempty"`
+	ReposPath pathutil.ReposPath `json:"repos_path,omitempty"`
+	Version   string             `json:"version,omitempty"`
+	Head      string             `json:"head,omitempty"`
+	HeadRef   string             `json:"head_ref,omitempty"`
+	Backup    string             `json:"backup,omitempty"`
+}
+
+// Journal is the intent journal of a transaction, which is written to
+// $VOLTPATH/trx.journal.
+type Journal struct {
+	// ID is the ID of committed transaction in the history (see History)
+	ID          int        `json:"id,omitempty"`
+	PID         int        `json:"pid"`
+	StartedAt   time.Time  `json:"started_at"`
+	CommittedAt *time.Time `json:"committed_at,omitempty"`
+	Args        []string   `json:"args"`
+	// Message is the reason of the transaction given by -m option
+	Message string `json:"message,omitempty"`
+	// LockJSON is the content of lock.json when the transaction began.
+	// nil means lock.json did not exist.
+	LockJSON *string `json:"lock_json"`
+	// CommittedLockJSON is the content of lock.json when the transaction was
+	// committed. nil means lock.json did not exist.
+	CommittedLockJSON *string `json:"committed_lock_json,omitempty"`
+	Ops               []Op    `json:"ops"`
+}
+
+var (
+	current   *Journal
+	message   string
+	journalMu sync.Mutex
+)
+
+// SetMessage sets the message of the transaction begun by Create.
+// It is recorded to the journal, and shown by "volt log".
+func SetMessage(msg string) {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	message = msg
+}
+
+// begin rolls back the transaction which was interrupted (e.g. volt crashed),
+// and writes the journal of new transaction.
+func begin() error {
+	if err := recoverJournal(true); err != nil {
+		return err
+	}
+	j := &Journal{
+		PID:       os.Getpid(),
+		StartedAt: time.Now(),
+		Args:      os.Args[1:],
+		Message:   message,
+		Ops:       []Op{},
+	}
+	content, err := ioutil.ReadFile(pathutil.LockJSON())
+	if err == nil {
+		s := string(content)
+		j.LockJSON = &s
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err = j.write(); err != nil {
+		return err
+	}
+	current = j
+	notifyBegin(j)
+	return nil
+}
+
+// recoverJournal finishes the transaction of the journal left by other
+// process. If the transaction was being committed, the commit is resumed.
+// Otherwise, it is rolled back. If lock.json is restored and
+// failIfLockJSONRestored is true, it returns an error because the caller may
+// have read lock.json before the transaction began.
+func recoverJournal(failIfLockJSONRestored bool) error {
+	content, err := ioutil.ReadFile(pathutil.TrxJournal())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var j Journal
+	if err = json.Unmarshal(content, &j); err != nil {
+		return errors.New(pathutil.TrxJournal() + " is broken: remove the file manually to continue: " + err.Error())
+	}
+	cmdline := "volt " + strings.Join(j.Args, " ")
+	if j.CommittedAt != nil {
+		logger.Warnf("Resuming the commit of \"%s\" (PID %d) started at %s, which was interrupted ...",
+			cmdline, j.PID, j.StartedAt.Format(time.RFC3339))
+		if err = j.archive(); err != nil {
+			return errors.New("could not save the transaction to the history: " + err.Error())
+		}
+		os.RemoveAll(pathutil.TrxBackup())
+		if err = os.Remove(pathutil.TrxJournal()); err != nil {
+			return err
+		}
+		notifyCommit(&j)
+		return nil
+	}
+	logger.Warnf("Rolling back \"%s\" (PID %d) started at %s, which was interrupted ...",
+		cmdline, j.PID, j.StartedAt.Format(time.RFC3339))
+	lockJSONChanged := j.lockJSONChanged()
+	if err = j.rollback(true); err != nil {
+		return err
+	}
+	notifyRollback(&j)
+	if lockJSONChanged && failIfLockJSONRestored {
+		return errors.New("lock.json was restored to the state before \"" + cmdline + "\": please run the command again")
+	}
+	return nil
+}
+
+func (j *Journal) write() error {
+	content, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to temporary file and rename it not to leave broken journal
+	tmp := pathutil.TrxJournal() + ".tmp"
+	if err = ioutil.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, pathutil.TrxJournal())
+}
+
+// add writes op to the journal of current transaction.
+func add(op Op) error {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	if current == nil {
+		return errors.New("transaction has not begun")
+	}
+	current.Ops = append(current.Ops, op)
+	return current.write()
+}
+
+// Install records that path is going to be created.
+// If the transaction is rolled back, path is removed.
+func Install(path string) error {
+	return add(Op{Type: OpInstall, Path: path})
+}
+
+// Upgrade records that reposPath is going to be changed from version.
+// head and headRef are the state of HEAD (see gitutil.GetHEADState).
+// If the transaction is rolled back, version is checked out.
+func Upgrade(reposPath pathutil.ReposPath, version, head, headRef string) error {
+	return add(Op{
+		Type:      OpUpgrade,
+		ReposPath: reposPath,
+		Version:   version,
+		Head:      head,
+		HeadRef:   headRef,
+	})
+}
+
+// RemoveAll removes path and the empty parent directories.
+// path is moved to $VOLTPATH/trx.backup, and is removed when the transaction
+// is committed. If the transaction is rolled back, path is restored.
+func RemoveAll(path string) error {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	if current == nil {
+		return errors.New("transaction has not begun")
+	}
+	// The number of the operations is not unique if some of them were
+	// removed by Revert
+	n := len(current.Ops)
+	backup := filepath.Join(pathutil.TrxBackup(), strconv.Itoa(n))
+	for pathutil.Exists(backup) {
+		n++
+		backup = filepath.Join(pathutil.TrxBackup(), strconv.Itoa(n))
+	}
+	if err := current.move(OpRemove, path, backup); err != nil {
+		return err
+	}
+	fileutil.RemoveDirs(filepath.Dir(path))
+	return nil
+}
+
+// Rename moves oldpath to newpath like os.Rename.
+// If the transaction is rolled back, newpath is moved back to oldpath.
+func Rename(oldpath, newpath string) error {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	if current == nil {
+		return errors.New("transaction has not begun")
+	}
+	return current.move(OpRename, oldpath, newpath)
+}
+
+// Revert undoes the operations on path of current transaction in reverse
+// order like Rollback, and removes them from the journal. The other
+// operations are kept, so that they can be committed (e.g. "volt get
+// -partial"). The operations on repositories recorded by Upgrade are not
+// reverted.
+func Revert(path string) error {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	if current == nil {
+		return errors.New("transaction has not begun")
+	}
+	reverted := &Journal{LockJSON: current.LockJSON}
+	ops := make([]Op, 0, len(current.Ops))
+	for _, op := range current.Ops {
+		if op.Path == path {
+			reverted.Ops = append(reverted.Ops, op)
+		} else {
+			ops = append(ops, op)
+		}
+	}
+	if err := reverted.undoOps().ErrorOrNil(); err != nil {
+		return err
+	}
+	current.Ops = ops
+	return current.write()
+}
+
+// move records op of typ, and moves path to backup.
+func (j *Journal) move(typ, path, backup string) error {
+	j.Ops = append(j.Ops, Op{Type: typ, Path: path, Backup: backup})
+	if err := j.write(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(backup), 0755); err != nil {
+		return err
+	}
+	return os.Rename(path, backup)
+}
+
+// Commit finishes current transaction, and cannot be rolled back anymore.
+// If something was changed, the journal and the files removed by RemoveAll
+// are moved to the history (see History).
+// If SIGINT or SIGTERM was received, it returns ErrInterrupted without
+// committing, so the transaction is rolled back by Remove.
+func Commit() error {
+	if Interrupted() {
+		return ErrInterrupted
+	}
+	j, err := commit()
+	if err != nil {
+		return err
+	}
+	notifyCommit(j)
+	return nil
+}
+
+func commit() (*Journal, error) {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	if current == nil {
+		return nil, errors.New("transaction has not begun")
+	}
+	j := current
+	if current.changed() {
+		id, err := lockjson.NextTrxID()
+		if err != nil {
+			return nil, errors.New("could not allocate transaction ID: " + err.Error())
+		}
+		now := time.Now()
+		current.ID = id
+		current.CommittedAt = &now
+		if content, err := ioutil.ReadFile(pathutil.LockJSON()); err == nil {
+			s := string(content)
+			current.CommittedLockJSON = &s
+		}
+		// This is the commit marker. If volt crashed after this, the commit
+		// is resumed by Recover()
+		if err = current.write(); err != nil {
+			return nil, err
+		}
+		if err = current.archive(); err != nil {
+			// Do not roll back the committed transaction
+			current = nil
+			logger.Warn("could not save the transaction to the history (retried next time): " + err.Error())
+			return j, nil
+		}
+	}
+	if err := os.RemoveAll(pathutil.TrxBackup()); err != nil {
+		return nil, errors.New("could not remove " + pathutil.TrxBackup() + ": " + err.Error())
+	}
+	if err := os.Remove(pathutil.TrxJournal()); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	current = nil
+	return j, nil
+}
+
+// Rollback undoes the operations of current transaction in reverse order,
+// and restores lock.json.
+func Rollback() error {
+	journalMu.Lock()
+	j := current
+	current = nil
+	journalMu.Unlock()
+	if j == nil {
+		return errors.New("transaction has not begun")
+	}
+	if j.changed() {
+		logger.Info("Rolling back ...")
+	}
+	if err := j.rollback(false); err != nil {
+		return err
+	}
+	notifyRollback(j)
+	return nil
+}
+
+// changed returns true if the operations were applied or lock.json was
+// changed after the transaction began.
+func (j *Journal) changed() bool {
+	return len(j.Ops) > 0 || j.lockJSONChanged()
+}
+
+// lockJSONChanged returns true if lock.json was changed after the transaction
+// began.
+func (j *Journal) lockJSONChanged() bool {
+	content, err := ioutil.ReadFile(pathutil.LockJSON())
+	if err != nil {
+		return j.LockJSON != nil
+	}
+	return j.LockJSON == nil || *j.LockJSON != string(content)
+}
+
+// submodulesOf returns how submodules of reposPath were updated when the
+// transaction began (see lockjson.Repos.Submodules).
+func (j *Journal) submodulesOf(reposPath pathutil.ReposPath) string {
+	if j.LockJSON == nil {
+		return ""
+	}
+	lockJSON, err := lockjson.Parse([]byte(*j.LockJSON))
+	if err != nil {
+		return ""
+	}
+	repos, err := lockJSON.Repos.FindByPath(reposPath)
+	if err != nil {
+		return ""
+	}
+	return repos.Submodules
+}
+
+// rollback undoes the operations of j, and restores lock.json.
+// If recovering is false (rolling back in the process of j), lock.json is
+// restored only if it was written by volt, not to lose the changes by other
+// programs (see lockjson.ErrConflict).
+// The signatures of the versions checked out by rollback are not verified
+// (see "git.verify_signatures" in config.toml), because they are the versions
+// which were installed before the transaction began.
+func (j *Journal) rollback(recovering bool) error {
+	result := j.undoOps()
+
+	// Restore lock.json
+	content, readErr := ioutil.ReadFile(pathutil.LockJSON())
+	if readErr == nil && !recovering && !lockjson.WrittenByVolt(content) {
+		if j.LockJSON == nil || string(content) != *j.LockJSON {
+			logger.Debug("Not restoring " + pathutil.LockJSON() + " changed by other program")
+		}
+	} else if j.LockJSON == nil {
+		if err := os.Remove(pathutil.LockJSON()); err != nil && !os.IsNotExist(err) {
+			result = multierror.Append(result, err)
+		}
+	} else if readErr != nil || string(content) != *j.LockJSON {
+		logger.Debug("Restoring " + pathutil.LockJSON() + " ...")
+		if err := ioutil.WriteFile(pathutil.LockJSON(), []byte(*j.LockJSON), 0644); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	if result.ErrorOrNil() != nil {
+		// Keep the journal and the backup to roll back again
+		return errors.New("rollback failed: " + result.Error())
+	}
+	os.RemoveAll(pathutil.TrxBackup())
+	return os.Remove(pathutil.TrxJournal())
+}
+
+// undoOps undoes the operations of j in reverse order.
+func (j *Journal) undoOps() *multierror.Error {
+	var result *multierror.Error
+	var cfg *config.Config
+	for i := len(j.Ops) - 1; i >= 0; i-- {
+		op := &j.Ops[i]
+		switch op.Type {
+		case OpInstall:
+			if !pathutil.Exists(op.Path) {
+				continue
+			}
+			logger.Debug("Removing " + op.Path + " ...")
+			if err := os.RemoveAll(op.Path); err != nil {
+				result = multierror.Append(result, err)
+				continue
+			}
+			fileutil.RemoveDirs(filepath.Dir(op.Path))
+		case OpUpgrade:
+			if cfg == nil {
+				var err error
+				if cfg, err = config.Read(); err != nil {
+					result = multierror.Append(result, errors.New("could not read config.toml: "+err.Error()))
+					continue
+				}
+			}
+			logger.Debug("Checking out " + op.ReposPath.String() + " to " + op.Version + " ...")
+			if err := gitutil.CheckoutVersion(op.ReposPath, op.Version, op.Head, op.HeadRef, cfg); err != nil {
+				result = multierror.Append(result, err)
+				continue
+			}
+			if err := gitutil.UpdateSubmodules(op.ReposPath, j.submodulesOf(op.ReposPath), cfg); err != nil {
+				result = multierror.Append(result, err)
+			}
+		case OpRemove, OpRename:
+			if !pathutil.Exists(op.Backup) {
+				continue
+			}
+			logger.Debug("Restoring " + op.Path + " ...")
+			os.MkdirAll(filepath.Dir(op.Path), 0755)
+			if err := os.Rename(op.Backup, op.Path); err != nil {
+				result = multierror.Append(result, err)
+			}
+		}
+	}
+	return result
+}
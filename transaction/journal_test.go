@@ -0,0 +1,74 @@
+package transaction
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// * Revert undoes the operations on the path, and keeps the other operations
+// * RemoveAll does not overwrite the backup of the kept operations after
+//   Revert
+// * The kept operations are rolled back
+func TestRevert(t *testing.T) {
+	setUpHookEnv(t)
+	if err := Create(); err != nil {
+		t.Fatal(err)
+	}
+	defer Remove()
+
+	dirOf := func(name string) string {
+		return filepath.Join(pathutil.VoltPath(), "repos", name)
+	}
+	writeFile := func(name, file string) {
+		t.Helper()
+		if err := os.MkdirAll(dirOf(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dirOf(name), file), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		writeFile(name, "old")
+	}
+
+	// Replace a
+	if err := RemoveAll(dirOf("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(dirOf("a")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile("a", "new")
+	if err := RemoveAll(dirOf("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Revert(dirOf("a")); err != nil {
+		t.Fatal("Revert() failed: " + err.Error())
+	}
+	if !pathutil.Exists(filepath.Join(dirOf("a"), "old")) || pathutil.Exists(filepath.Join(dirOf("a"), "new")) {
+		t.Error("a was not reverted")
+	}
+	if pathutil.Exists(dirOf("b")) {
+		t.Error("b was reverted")
+	}
+
+	for _, name := range []string{"c", "d"} {
+		if err := RemoveAll(dirOf(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Rollback(); err != nil {
+		t.Fatal("Rollback() failed: " + err.Error())
+	}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if !pathutil.Exists(filepath.Join(dirOf(name), "old")) {
+			t.Errorf("%s was not restored", name)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// +build !windows
+
+package transaction
+
+import "syscall"
+
+// processExists returns true if the process of pid is running.
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,16 @@
+// +build windows
+
+package transaction
+
+import "os"
+
+// processExists returns true if the process of pid is running.
+func processExists(pid int) bool {
+	// On Windows, os.FindProcess() fails if the process does not exist
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,140 @@
+package transaction
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vim-volt/volt/fileutil"
+	"github.com/vim-volt/volt/pathutil"
+)
+
+// resumeStateFile is the state file in $VOLTPATH/trx.resume/.
+const resumeStateFile = "state.json"
+
+// resumeStashDir is the directory of the stashed repositories in
+// $VOLTPATH/trx.resume/.
+const resumeStashDir = "repos"
+
+// ResumeState is the state of a failed transaction, which is saved to
+// $VOLTPATH/trx.resume/ and resumed by "volt resume".
+type ResumeState struct {
+	Args     []string  `json:"args"`
+	FailedAt time.Time `json:"failed_at"`
+	// Done is the repositories processed successfully before the failure
+	Done []ResumeStep `json:"done"`
+}
+
+// ResumeStep is a repository processed successfully in a failed transaction.
+type ResumeStep struct {
+	ReposPath pathutil.ReposPath `json:"repos_path"`
+	Version   string             `json:"version"`
+	Head      string             `json:"head,omitempty"`
+	HeadRef   string             `json:"head_ref,omitempty"`
+	// Installed is true if the repository was installed by the transaction
+	Installed bool `json:"installed,omitempty"`
+	// Stash is the clone of the installed repository kept in
+	// $VOLTPATH/trx.resume/ after rollback. Empty if it was not kept.
+	Stash string `json:"stash,omitempty"`
+}
+
+// SaveResume saves done of current transaction which is failing, so that
+// "volt resume" can run the command of args (e.g. ["get", "-u"]) again
+// skipping them.
+// If rollback is true, the repositories installed by the transaction are
+// moved to $VOLTPATH/trx.resume/ not to be removed by Rollback.
+// It must be called before Rollback or Commit.
+func SaveResume(args []string, done []ResumeStep, rollback bool) error {
+	journalMu.Lock()
+	began := current != nil
+	journalMu.Unlock()
+	if !began {
+		return errors.New("transaction has not begun")
+	}
+	if err := RemoveResume(); err != nil {
+		return err
+	}
+	state := &ResumeState{Args: args, FailedAt: time.Now(), Done: done}
+	for i := range state.Done {
+		step := &state.Done[i]
+		if !rollback || !step.Installed {
+			continue
+		}
+		stash := filepath.Join(pathutil.TrxResume(), resumeStashDir, filepath.FromSlash(step.ReposPath.String()))
+		if err := os.MkdirAll(filepath.Dir(stash), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(step.ReposPath.FullPath(), stash); err != nil {
+			return err
+		}
+		fileutil.RemoveDirs(filepath.Dir(step.ReposPath.FullPath()))
+		step.Stash = stash
+	}
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(pathutil.TrxResume(), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(pathutil.TrxResume(), resumeStateFile), content, 0644)
+}
+
+// ReadResume reads the state saved by SaveResume.
+// It returns nil if there is no failed transaction to resume.
+func ReadResume() (*ResumeState, error) {
+	content, err := ioutil.ReadFile(filepath.Join(pathutil.TrxResume(), resumeStateFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state ResumeState
+	if err = json.Unmarshal(content, &state); err != nil {
+		return nil, errors.New("could not parse resume state: " + err.Error())
+	}
+	return &state, nil
+}
+
+// RemoveResume removes the state saved by SaveResume with the stashed
+// repositories.
+func RemoveResume() error {
+	return os.RemoveAll(pathutil.TrxResume())
+}
+
+// Step returns the step of reposPath done by the failed transaction.
+// It returns nil if s is nil or reposPath was not done.
+func (s *ResumeState) Step(reposPath pathutil.ReposPath) *ResumeStep {
+	if s == nil {
+		return nil
+	}
+	for i := range s.Done {
+		if s.Done[i].ReposPath == reposPath {
+			return &s.Done[i]
+		}
+	}
+	return nil
+}
+
+// Restore moves the stashed repository back to $VOLTPATH/repos as an
+// installation of current transaction.
+func (step *ResumeStep) Restore() error {
+	if step.Stash == "" || !pathutil.Exists(step.Stash) {
+		return errors.New("repository was not stashed: " + step.ReposPath.String())
+	}
+	fullpath := step.ReposPath.FullPath()
+	if pathutil.Exists(fullpath) {
+		return errors.New("repository exists: " + fullpath)
+	}
+	if err := Install(fullpath); err != nil {
+		return errors.New("failed to write transaction journal: " + err.Error())
+	}
+	if err := os.MkdirAll(filepath.Dir(fullpath), 0755); err != nil {
+		return err
+	}
+	return os.Rename(step.Stash, fullpath)
+}
@@ -0,0 +1,91 @@
+package transaction
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/vim-volt/volt/logger"
+)
+
+// ErrInterrupted is returned by Commit when SIGINT or SIGTERM was received
+// during the transaction.
+var ErrInterrupted = errors.New("interrupted by signal: all changes were rolled back")
+
+// exitInterrupted is the exit status when volt exited by the second signal.
+const exitInterrupted = 130
+
+var (
+	interrupted  int32
+	signalCh     chan os.Signal
+	stopSignalCh chan struct{}
+	termState    *terminal.State
+)
+
+// Interrupted returns true if SIGINT or SIGTERM was received during current
+// transaction. Commands must not start new operations (e.g. installing next
+// repository) after that.
+func Interrupted() bool {
+	return atomic.LoadInt32(&interrupted) != 0
+}
+
+// watchSignals catches SIGINT and SIGTERM until unwatchSignals is called.
+// On the first signal, running operations are not stopped by volt, but the
+// transaction is rolled back instead of being committed. Note that git
+// processes are not protected from the signal sent to the process group (e.g.
+// Ctrl-C), because they may ask a password on the terminal.
+// On the second signal, volt exits immediately, and the transaction is rolled
+// back by next volt process (see Recover).
+func watchSignals() {
+	atomic.StoreInt32(&interrupted, 0)
+	if fd := int(os.Stdin.Fd()); terminal.IsTerminal(fd) {
+		termState, _ = terminal.GetState(fd)
+	}
+	signalCh = make(chan os.Signal, 2)
+	stopSignalCh = make(chan struct{})
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+	go func(signalCh chan os.Signal, stop chan struct{}) {
+		for {
+			select {
+			case <-signalCh:
+				if atomic.CompareAndSwapInt32(&interrupted, 0, 1) {
+					logger.Warn("Interrupted: rolling back after running operations finish ... " +
+						"(send the signal again to exit immediately)")
+					continue
+				}
+				restoreTerminal()
+				logger.Error("Aborted: the transaction will be rolled back when volt runs next time")
+				os.Exit(exitInterrupted)
+			case <-stop:
+				return
+			}
+		}
+	}(signalCh, stopSignalCh)
+}
+
+// unwatchSignals stops catching signals, and restores the state of the
+// terminal if interrupted.
+func unwatchSignals() {
+	if signalCh == nil {
+		return
+	}
+	signal.Stop(signalCh)
+	close(stopSignalCh)
+	signalCh = nil
+	if Interrupted() {
+		restoreTerminal()
+	}
+}
+
+// restoreTerminal restores the state of the terminal saved when the
+// transaction began, because interrupted processes (e.g. git asking a
+// password) may leave it changed.
+func restoreTerminal() {
+	if termState != nil {
+		terminal.Restore(int(os.Stdin.Fd()), termState)
+	}
+}
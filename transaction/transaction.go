@@ -2,61 +2,137 @@ package transaction
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/vim-volt/volt/config"
 	"github.com/vim-volt/volt/logger"
 	"github.com/vim-volt/volt/pathutil"
 )
 
-// Create creates $VOLTPATH/trx.lock file
+// lockPollInterval is the interval to check if trx.lock was removed by other
+// volt process.
+const lockPollInterval = 200 * time.Millisecond
+
+// Create creates $VOLTPATH/trx.lock file.
+// If other volt process has the lock, it waits for the process to finish
+// until "wait" in [lock] section of config.toml.
 func Create() error {
-	ownPid := []byte(strconv.Itoa(os.Getpid()))
 	trxLockFile := pathutil.TrxLock()
 
+	cfg, err := config.Read()
+	if err != nil {
+		return errors.New("failed to begin transaction: could not read config.toml: " + err.Error())
+	}
+	wait, err := time.ParseDuration(cfg.Lock.Wait)
+	if err != nil {
+		return errors.New("failed to begin transaction: " + err.Error())
+	}
+	deadline := time.Now().Add(wait)
+
 	// Create trx.lock parent directories
-	err := os.MkdirAll(filepath.Dir(trxLockFile), 0755)
+	err = os.MkdirAll(filepath.Dir(trxLockFile), 0755)
 	if err != nil {
 		return errors.New("failed to begin transaction: " + err.Error())
 	}
 
-	// Return error if the file exists
-	if pathutil.Exists(trxLockFile) {
-		return errors.New("failed to begin transaction: " + pathutil.TrxLock() + " exists: if no other volt process is currently running, this probably means a volt process crashed earlier. Make sure no other volt process is running and remove the file manually to continue")
+	waiting := false
+	for {
+		err = createLock(trxLockFile)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return errors.New("failed to begin transaction: " + err.Error())
+		}
+		pid, startedAt, err := readLock(trxLockFile)
+		if os.IsNotExist(err) {
+			// Removed just now
+			continue
+		}
+		owner := "PID " + pid
+		if startedAt != "" {
+			owner += " started at " + startedAt
+		}
+		if !time.Now().Before(deadline) {
+			return errors.New("failed to begin transaction: operation in progress by " + owner + ": if no other volt process is currently running, this probably means a volt process crashed earlier. Make sure no other volt process is running and remove " + trxLockFile + " manually to continue (the changes of the crashed process are rolled back)")
+		}
+		if !waiting {
+			logger.Infof("Waiting for the operation in progress by %s (up to %s) ...", owner, wait)
+			waiting = true
+		}
+		time.Sleep(lockPollInterval)
 	}
 
-	// Write pid to trx.lock file
-	err = ioutil.WriteFile(trxLockFile, ownPid, 0644)
-	if err != nil {
+	// Write the journal
+	if err = begin(); err != nil {
+		os.Remove(trxLockFile)
 		return errors.New("failed to begin transaction: " + err.Error())
 	}
+	watchSignals()
+	return nil
+}
 
-	// Read pid from trx.lock file
-	pid, err := ioutil.ReadFile(trxLockFile)
+// createLock creates trx.lock file exclusively, and writes the PID and the
+// time. If the file exists, it returns an error which satisfies os.IsExist().
+func createLock(trxLockFile string) error {
+	f, err := os.OpenFile(trxLockFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
 	if err != nil {
-		return errors.New("failed to begin transaction: " + err.Error())
+		return err
 	}
+	_, err = fmt.Fprintf(f, "%d\n%s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(trxLockFile)
+	}
+	return err
+}
 
-	if string(pid) != string(ownPid) {
-		return errors.New("transaction lock was taken by PID " + string(pid))
+// readLock returns the PID and the time written to trx.lock file.
+// The time is empty if trx.lock was created by old volt.
+func readLock(trxLockFile string) (string, string, error) {
+	content, err := ioutil.ReadFile(trxLockFile)
+	if err != nil {
+		return "", "", err
 	}
-	return nil
+	lines := strings.SplitN(strings.TrimSpace(string(content)), "\n", 2)
+	if len(lines) < 2 {
+		return lines[0], "", nil
+	}
+	return lines[0], lines[1], nil
 }
 
-// Remove removes $VOLTPATH/trx.lock file
+// Remove rolls back current transaction if it was not committed,
+// and removes $VOLTPATH/trx.lock file
 func Remove() {
+	defer unwatchSignals()
+
+	journalMu.Lock()
+	uncommitted := current != nil
+	journalMu.Unlock()
+	if uncommitted {
+		if err := Rollback(); err != nil {
+			logger.Error(err.Error())
+		}
+	}
+
 	// Read pid from trx.lock file
 	trxLockFile := pathutil.TrxLock()
-	pid, err := ioutil.ReadFile(trxLockFile)
+	pid, _, err := readLock(trxLockFile)
 	if err != nil {
 		logger.Error("trx.lock was already removed")
 		return
 	}
 
 	// Remove trx.lock if pid is same
-	if string(pid) != strconv.Itoa(os.Getpid()) {
+	if pid != strconv.Itoa(os.Getpid()) {
 		logger.Error("Cannot remove another process's trx.lock")
 		return
 	}
@@ -66,3 +142,44 @@ func Remove() {
 		return
 	}
 }
+
+// Recover finishes the transaction which was interrupted (e.g. volt crashed
+// or was killed) before running a command, not to proceed on top of
+// half-applied state. If the transaction was being committed, the commit is
+// resumed. Otherwise, it is rolled back.
+// It does nothing if no transaction was interrupted, or other volt process
+// is running the transaction.
+func Recover() error {
+	if !pathutil.Exists(pathutil.TrxJournal()) {
+		return nil
+	}
+	trxLockFile := pathutil.TrxLock()
+	pid, _, err := readLock(trxLockFile)
+	if err == nil {
+		n, err := strconv.Atoi(pid)
+		if err != nil || processExists(n) {
+			// In progress
+			return nil
+		}
+		logger.Debugf("Removing %s of PID %d which is not running ...", trxLockFile, n)
+		if err = os.Remove(trxLockFile); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err = createLock(trxLockFile); err != nil {
+		if os.IsExist(err) {
+			// Other volt process began a transaction just now
+			return nil
+		}
+		return err
+	}
+	defer os.Remove(trxLockFile)
+	if err = recoverJournal(false); err != nil {
+		return errors.New("could not recover the interrupted transaction: " + err.Error() +
+			": fix the problem and run volt again, or remove " + pathutil.TrxJournal() + " manually to give up")
+	}
+	return nil
+}